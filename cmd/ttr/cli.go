@@ -0,0 +1,474 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/analysis"
+	"github.com/benvon/thermostat-telemetry-reader/internal/core"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// logLevels are the accepted values of --log-level
+var logLevels = []string{"debug", "info", "warn", "error"}
+
+// globalOptions are the flags every command accepts, before or after its name
+type globalOptions struct {
+	configFile string
+	profile    string
+	logLevel   string
+}
+
+// register adds the shared flags to flags. Values already parsed carry over
+// as defaults, so flags given before the command name are kept.
+func (o *globalOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&o.configFile, "config", o.configFile, "Path to configuration file")
+	flags.StringVar(&o.profile, "profile", o.profile, "Profile overlay merged over the configuration file, e.g. prod for config.prod.yaml (defaults to $TTR_PROFILE)")
+	flags.StringVar(&o.logLevel, "log-level", o.logLevel, "Log level: debug, info, warn or error (defaults to ttr.log_level)")
+}
+
+// loadConfig loads the configuration file and profile, then applies --log-level
+func (o *globalOptions) loadConfig() (*config.Config, error) {
+	if o.logLevel != "" && !slices.Contains(logLevels, o.logLevel) {
+		return nil, fmt.Errorf("invalid --log-level %q: must be one of %s", o.logLevel, strings.Join(logLevels, ", "))
+	}
+	cfg, err := config.LoadConfigProfile(o.configFile, o.profile)
+	if err != nil {
+		return nil, fmt.Errorf("loading configuration: %w", err)
+	}
+	if o.logLevel != "" {
+		cfg.TTR.LogLevel = o.logLevel
+	}
+	return cfg, nil
+}
+
+// commandFunc runs a command with its positional arguments
+type commandFunc func(ctx context.Context, opts *globalOptions, args []string) error
+
+// command is a ttr subcommand
+type command struct {
+	name    string
+	args    string // positional arguments, for usage
+	summary string
+	// setup registers the command's own flags and returns the function that runs it
+	setup func(flags *flag.FlagSet) commandFunc
+}
+
+// commands returns every subcommand in the order usage lists them
+func commands() []command {
+	return []command{
+		{name: "run", summary: "Collect telemetry until interrupted (the default command)", setup: setupRun},
+		{name: "init", summary: "Write an example configuration file", setup: setupInit},
+		{name: "validate", summary: "Check the configuration and, optionally, the providers and sinks it names", setup: setupValidate},
+		{name: "auth", summary: "Check that each provider authenticates and lists thermostats", setup: setupAuth},
+		{name: "backfill", summary: "Fill the backfill window from the stored offsets, then exit", setup: setupBackfill},
+		{name: "offsets", args: "dump|restore [file] | rewind --thermostat ID --to TIME", summary: "Dump, restore or rewind the offset store", setup: setupOffsets},
+		{name: "status", summary: "Show the health and scheduler state of a running instance", setup: setupStatus},
+		{name: "import-nest-takeout", args: "file", summary: "Import Nest thermostat history from a Google Takeout .zip or directory", setup: setupNestImport},
+		{name: "lint-docs", args: "[file...]", summary: "Lint canonical documents in NDJSON files or stdin", setup: setupLintDocs},
+		{name: "simulate", args: "[file...]", summary: "Project runtime and energy under alternate setpoints from runtime_5m history", setup: setupSimulate},
+		{name: "scaffold", args: "provider|sink name", summary: "Generate a skeleton provider or sink package wired to its conformance suite", setup: setupScaffold},
+		{name: "decrypt", args: "value|-", summary: "Decrypt values written by the encrypt_fields transform", setup: setupDecrypt},
+		{name: "completion", args: "bash|zsh|fish", summary: "Print a shell completion script", setup: setupCompletion},
+		{name: "version", summary: "Show version information and compiled integrations", setup: setupVersion},
+		{name: "help", args: "[command]", summary: "Show usage for ttr or a command", setup: setupHelp},
+	}
+}
+
+// findCommand returns the command called name
+func findCommand(name string) (command, bool) {
+	all := commands()
+	index := slices.IndexFunc(all, func(cmd command) bool { return cmd.name == name })
+	if index < 0 {
+		return command{}, false
+	}
+	return all[index], true
+}
+
+// commandFlags returns the flag set of cmd, with the shared flags, and the
+// function that runs it
+func commandFlags(cmd command, opts *globalOptions) (*flag.FlagSet, commandFunc) {
+	flags := flag.NewFlagSet("ttr "+cmd.name, flag.ContinueOnError)
+	opts.register(flags)
+	run := cmd.setup(flags)
+	flags.Usage = func() {
+		out := flags.Output()
+		fmt.Fprintf(out, "Usage: ttr %s [flags] %s\n\n%s\n\nFlags:\n", cmd.name, cmd.args, cmd.summary)
+		flags.PrintDefaults()
+	}
+	return flags, run
+}
+
+// execute runs the command named by the first argument, or run when there is
+// none, and returns the process exit code
+func execute(ctx context.Context, opts *globalOptions, args []string) int {
+	name := "run"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	flags, run := commandFlags(cmd, opts)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if err := run(ctx, opts, flags.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "ttr %s failed: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// printUsage writes the top-level usage with every command
+func printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: ttr [flags] [command] [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands() {
+		fmt.Fprintf(out, "  %-20s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintf(out, "\nFlags, accepted before or after the command:\n")
+	flags := flag.NewFlagSet("ttr", flag.ContinueOnError)
+	flags.SetOutput(out)
+	(&globalOptions{configFile: defaultConfigFile}).register(flags)
+	flags.PrintDefaults()
+	fmt.Fprintf(out, "\nRun 'ttr help <command>' for a command's own flags.\n")
+}
+
+// noArgs wraps a command that takes no positional arguments
+func noArgs(name string, run func(ctx context.Context, opts *globalOptions) error) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("%s takes no arguments, got %q", name, strings.Join(args, " "))
+		}
+		return run(ctx, opts)
+	}
+}
+
+func setupRun(flags *flag.FlagSet) commandFunc {
+	return noArgs("run", runService)
+}
+
+func setupInit(flags *flag.FlagSet) commandFunc {
+	force := flags.Bool("force", false, "Overwrite an existing configuration file")
+	return noArgs("init", func(ctx context.Context, opts *globalOptions) error {
+		if _, err := os.Stat(opts.configFile); err == nil && !*force {
+			return fmt.Errorf("%s already exists; use --force to overwrite it", opts.configFile)
+		}
+		if err := config.CreateExampleConfig(opts.configFile); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote example configuration to %s\n", opts.configFile)
+		fmt.Printf("Set ECOBEE_CLIENT_ID, ECOBEE_REFRESH_TOKEN and ELASTIC_API_KEY, then check it with: ttr validate --config %s\n", opts.configFile)
+		return nil
+	})
+}
+
+func setupValidate(flags *flag.FlagSet) commandFunc {
+	printConfig := flags.Bool("print", false, "Print the effective configuration, with secrets redacted")
+	selfTest := flags.Bool("self-test", false, "Also connect to every enabled provider and sink, as fail_fast does at startup")
+	return noArgs("validate", func(ctx context.Context, opts *globalOptions) error {
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			return err
+		}
+		if *printConfig {
+			cfg.PrintEffectiveConfig()
+		}
+		if *selfTest {
+			logger := setupLogger(cfg.TTR.LogLevel, os.Stderr)
+			providers, err := initializeProviders(cfg, logger)
+			if err != nil {
+				return fmt.Errorf("initializing providers: %w", err)
+			}
+			sinks, err := initializeSinks(cfg, logger)
+			if err != nil {
+				return fmt.Errorf("initializing sinks: %w", err)
+			}
+			if err := printSelfTest(ctx, providers, sinks); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Configuration %s is valid\n", opts.configFile)
+		return nil
+	})
+}
+
+func setupAuth(flags *flag.FlagSet) commandFunc {
+	return noArgs("auth", func(ctx context.Context, opts *globalOptions) error {
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			return err
+		}
+		providers, err := initializeProviders(cfg, setupLogger(cfg.TTR.LogLevel, os.Stderr))
+		if err != nil {
+			return fmt.Errorf("initializing providers: %w", err)
+		}
+		if len(providers) == 0 {
+			return fmt.Errorf("no providers are enabled")
+		}
+		return printSelfTest(ctx, providers, nil)
+	})
+}
+
+// printSelfTest runs a self-test within selfTestTimeout and prints its report
+// to stdout, returning an error if any check failed
+func printSelfTest(ctx context.Context, providers []model.Provider, sinks []model.Sink) error {
+	testCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	report := core.SelfTest(testCtx, providers, sinks)
+	fmt.Print(report)
+	if report.Failed() {
+		return fmt.Errorf("one or more checks failed")
+	}
+	return nil
+}
+
+func setupBackfill(flags *flag.FlagSet) commandFunc {
+	window := flags.Duration("window", 0, "How far back to fill, e.g. 720h (defaults to ttr.backfill_window)")
+	return noArgs("backfill", func(ctx context.Context, opts *globalOptions) error {
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			return err
+		}
+		if *window > 0 {
+			cfg.TTR.BackfillWindow = *window
+		}
+		logger := setupLogger(cfg.TTR.LogLevel, os.Stdout)
+		ctx, cancel := shutdownContext(ctx, logger)
+		defer cancel()
+
+		app, err := initializeApp(ctx, cfg, logger)
+		if err != nil {
+			return fmt.Errorf("initializing application: %w", err)
+		}
+		if err := openSinks(ctx, app); err != nil {
+			return err
+		}
+		defer closeSinks(ctx, app, logger)
+		defer closeOffsetStore(app, logger)
+
+		logger.Info("Starting backfill", "window", cfg.TTR.BackfillWindow)
+		if err := app.Scheduler.Backfill(ctx); err != nil {
+			return fmt.Errorf("backfill: %w", err)
+		}
+		logger.Info("Backfill finished")
+		return nil
+	})
+}
+
+func setupOffsets(flags *flag.FlagSet) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			return err
+		}
+		// Logs go to stderr so a dump written to stdout stays valid JSON
+		return runOffsetsCommand(ctx, cfg, args, setupLogger(cfg.TTR.LogLevel, os.Stderr))
+	}
+}
+
+// statusTimeout bounds each request the status command makes
+const statusTimeout = 10 * time.Second
+
+func setupStatus(flags *flag.FlagSet) commandFunc {
+	url := flags.String("url", "", "Base URL of the health server (defaults to localhost on ttr.health_port under ttr.http.base_path)")
+	return noArgs("status", func(ctx context.Context, opts *globalOptions) error {
+		base := *url
+		if base == "" {
+			cfg, err := opts.loadConfig()
+			if err != nil {
+				return err
+			}
+			base = fmt.Sprintf("http://localhost:%d%s", cfg.TTR.HealthPort, cfg.TTR.HTTP.BasePath)
+		}
+		base = strings.TrimSuffix(base, "/")
+
+		client := &http.Client{Timeout: statusTimeout}
+		healthy, err := printStatus(ctx, client, base+"/healthz")
+		if err != nil {
+			return err
+		}
+		if _, err := printStatus(ctx, client, base+"/scheduler"); err != nil {
+			return err
+		}
+		if !healthy {
+			return fmt.Errorf("instance at %s is not healthy", base)
+		}
+		return nil
+	})
+}
+
+// printStatus fetches url and prints its indented JSON body, reporting whether
+// the response was 200 OK
+func printStatus(ctx context.Context, client *http.Client, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var body any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, fmt.Errorf("decoding %s: %w", url, err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(body); err != nil {
+		return false, err
+	}
+	return resp.StatusCode == http.StatusOK, nil
+}
+
+func setupNestImport(flags *flag.FlagSet) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: ttr import-nest-takeout file")
+		}
+		if runTakeoutImport == nil {
+			return fmt.Errorf("nest import is not compiled into this binary; rebuild without integration tags or add -tags nest")
+		}
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			return err
+		}
+		logger := setupLogger(cfg.TTR.LogLevel, os.Stdout)
+		ctx, cancel := shutdownContext(ctx, logger)
+		defer cancel()
+
+		app, err := initializeApp(ctx, cfg, logger)
+		if err != nil {
+			return fmt.Errorf("initializing application: %w", err)
+		}
+		if err := runTakeoutImport(ctx, app, args[0], logger); err != nil {
+			return fmt.Errorf("nest takeout import: %w", err)
+		}
+		logger.Info("Nest takeout import finished")
+		return nil
+	}
+}
+
+func setupLintDocs(flags *flag.FlagSet) commandFunc {
+	// Document linting needs no configuration
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		return runLintDocs(args, os.Stdin, os.Stdout)
+	}
+}
+
+func setupSimulate(flags *flag.FlagSet) commandFunc {
+	var schedule analysis.SimulationSchedule
+	flags.Float64Var(&schedule.HeatOffsetC, "heat-offset", 0, "Add this many °C to heat setpoints no override replaces, e.g. -1")
+	flags.Float64Var(&schedule.CoolOffsetC, "cool-offset", 0, "Add this many °C to cool setpoints no override replaces, e.g. 1")
+	flags.Func("set", "Replace setpoints while a climate is active, as CLIMATE=HEAT/COOL with - to keep one, e.g. Sleep=17/- (repeatable)", func(value string) error {
+		climate, override, err := parseClimateOverride(value)
+		if err != nil {
+			return err
+		}
+		if schedule.Climates == nil {
+			schedule.Climates = make(map[string]analysis.SetpointOverride)
+		}
+		schedule.Climates[climate] = override
+		return nil
+	})
+	flags.Func("window", "Replace setpoints between two times of day, as HH:MM-HH:MM=HEAT/COOL, e.g. 22:00-06:00=16/- (repeatable; later windows win)", func(value string) error {
+		window, err := parseSetpointWindow(value)
+		if err != nil {
+			return err
+		}
+		schedule.Windows = append(schedule.Windows, window)
+		return nil
+	})
+	timezone := flags.String("timezone", "UTC", "Timezone windows follow, e.g. America/Chicago")
+	heatingKW := flags.Float64("heating-kw", 3, "Heating power draw in kW, for energy estimates")
+	coolingKW := flags.Float64("cooling-kw", 3, "Cooling power draw in kW, for energy estimates")
+	asJSON := flags.Bool("json", false, "Print results as JSON")
+	// Simulation needs no configuration
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		location, err := time.LoadLocation(*timezone)
+		if err != nil {
+			return fmt.Errorf("invalid --timezone: %w", err)
+		}
+		config := analysis.SimulationConfig{HeatingKW: *heatingKW, CoolingKW: *coolingKW, Location: location}
+		return runSimulate(args, os.Stdin, os.Stdout, schedule, config, *asJSON)
+	}
+}
+
+func setupScaffold(flags *flag.FlagSet) commandFunc {
+	dir := flags.String("dir", ".", "Repository root to write into")
+	force := flags.Bool("force", false, "Overwrite existing files")
+	// Scaffolding needs no configuration
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("usage: ttr scaffold provider|sink name")
+		}
+		return runScaffold(*dir, args[0], args[1], *force, os.Stdout)
+	}
+}
+
+func setupDecrypt(flags *flag.FlagSet) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: ttr decrypt value|-")
+		}
+		return runDecrypt(args[0], os.Stdin, os.Stdout)
+	}
+}
+
+func setupCompletion(flags *flag.FlagSet) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: ttr completion bash|zsh|fish")
+		}
+		return writeCompletion(os.Stdout, args[0])
+	}
+}
+
+func setupVersion(flags *flag.FlagSet) commandFunc {
+	return noArgs("version", func(ctx context.Context, opts *globalOptions) error {
+		info := buildInfo()
+		fmt.Printf("%s version %s\n", appName, info.Version)
+		fmt.Printf("commit: %s, built %s with %s\n", info.Commit, info.Date, info.GoVersion)
+		fmt.Printf("integrations: %s\n", strings.Join(compiledIntegrations(), ", "))
+		return nil
+	})
+}
+
+func setupHelp(flags *flag.FlagSet) commandFunc {
+	return func(ctx context.Context, opts *globalOptions, args []string) error {
+		if len(args) == 0 {
+			printUsage(os.Stdout)
+			return nil
+		}
+		cmd, ok := findCommand(args[0])
+		if !ok {
+			return fmt.Errorf("unknown command %q", args[0])
+		}
+		cmdFlags, _ := commandFlags(cmd, &globalOptions{configFile: defaultConfigFile})
+		cmdFlags.SetOutput(os.Stdout)
+		cmdFlags.Usage()
+		return nil
+	}
+}
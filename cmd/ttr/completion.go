@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// commandArgs are the fixed positional arguments offered for a command;
+// commands not listed complete file names
+var commandArgs = map[string][]string{
+	"offsets":    {"dump", "restore", "rewind"},
+	"completion": {"bash", "zsh", "fish"},
+}
+
+// completionFlag is a flag as offered by completion scripts
+type completionFlag struct {
+	name      string
+	usage     string
+	takesFile bool
+	takesArg  bool
+}
+
+// commandCompletionFlags returns the flags of cmd, including the shared flags
+func commandCompletionFlags(cmd command) []completionFlag {
+	flags, _ := commandFlags(cmd, &globalOptions{})
+	return completionFlags(flags)
+}
+
+// sharedCompletionFlags returns the flags every command accepts
+func sharedCompletionFlags() []completionFlag {
+	flags := flag.NewFlagSet("ttr", flag.ContinueOnError)
+	(&globalOptions{}).register(flags)
+	return completionFlags(flags)
+}
+
+// completionFlags describes the flags in flags
+func completionFlags(flags *flag.FlagSet) []completionFlag {
+	var result []completionFlag
+	flags.VisitAll(func(f *flag.Flag) {
+		boolFlag, ok := f.Value.(interface{ IsBoolFlag() bool })
+		result = append(result, completionFlag{
+			name:      f.Name,
+			usage:     f.Usage,
+			takesFile: f.Name == "config",
+			takesArg:  !ok || !boolFlag.IsBoolFlag(),
+		})
+	})
+	return result
+}
+
+// writeCompletion writes the completion script for shell, completing the
+// name the binary was run as
+func writeCompletion(out io.Writer, shell string) error {
+	program := filepath.Base(os.Args[0])
+	switch shell {
+	case "bash":
+		return writeBashCompletion(out, program)
+	case "zsh":
+		// zsh runs the bash script through its bash compatibility layer
+		if _, err := fmt.Fprintf(out, "#compdef %s\nautoload -U +X bashcompinit && bashcompinit\n", program); err != nil {
+			return err
+		}
+		return writeBashCompletion(out, program)
+	case "fish":
+		return writeFishCompletion(out, program)
+	default:
+		return fmt.Errorf("unsupported shell %q: use bash, zsh or fish", shell)
+	}
+}
+
+// writeBashCompletion writes a bash completion function for program
+func writeBashCompletion(out io.Writer, program string) error {
+	var b strings.Builder
+	var names, valueFlags []string
+	for _, cmd := range commands() {
+		names = append(names, cmd.name)
+		for _, f := range commandCompletionFlags(cmd) {
+			if f.takesArg && !slices.Contains(valueFlags, "-"+f.name) {
+				valueFlags = append(valueFlags, "-"+f.name, "--"+f.name)
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, "# bash completion for %s; load it with: source <(%s completion bash)\n", program, program)
+	fmt.Fprintf(&b, "_ttr_completion() {\n")
+	fmt.Fprintf(&b, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} command= i\n")
+	fmt.Fprintf(&b, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(&b, "\t\tcase ${COMP_WORDS[i]} in\n")
+	fmt.Fprintf(&b, "\t\t%s) ((i++)) ;;\n", strings.Join(valueFlags, "|"))
+	fmt.Fprintf(&b, "\t\t-*) ;;\n")
+	fmt.Fprintf(&b, "\t\t*) command=${COMP_WORDS[i]}; break ;;\n")
+	fmt.Fprintf(&b, "\t\tesac\n")
+	fmt.Fprintf(&b, "\tdone\n")
+	fmt.Fprintf(&b, "\tcase $prev in\n")
+	fmt.Fprintf(&b, "\t-config|--config) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n")
+	fmt.Fprintf(&b, "\t-log-level|--log-level) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(logLevels, " "))
+	fmt.Fprintf(&b, "\tesac\n")
+	fmt.Fprintf(&b, "\tcase $command in\n")
+	fmt.Fprintf(&b, "\t\"\") COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", strings.Join(append(names, flagWords(sharedCompletionFlags())...), " "))
+	for _, cmd := range commands() {
+		words := flagWords(commandCompletionFlags(cmd))
+		words = append(words, commandArgs[cmd.name]...)
+		if cmd.name == "help" {
+			words = append(words, names...)
+		}
+		fmt.Fprintf(&b, "\t%s) COMPREPLY=($(compgen -W %q -- \"$cur\")) ;;\n", cmd.name, strings.Join(words, " "))
+	}
+	fmt.Fprintf(&b, "\tesac\n")
+	fmt.Fprintf(&b, "}\n")
+	fmt.Fprintf(&b, "complete -o default -F _ttr_completion %s\n", program)
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// writeFishCompletion writes fish completions for program
+func writeFishCompletion(out io.Writer, program string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s; load it with: %s completion fish | source\n", program, program)
+	shared := sharedCompletionFlags()
+	for _, f := range shared {
+		fmt.Fprintf(&b, "complete -c %s -l %s%s -d %s\n", program, f.name, fishFlagArgs(f), fishQuote(f.usage))
+	}
+	for _, cmd := range commands() {
+		fmt.Fprintf(&b, "complete -c %s -f -n __fish_use_subcommand -a %s -d %s\n", program, cmd.name, fishQuote(cmd.summary))
+		for _, f := range commandCompletionFlags(cmd) {
+			if slices.ContainsFunc(shared, func(sharedFlag completionFlag) bool { return sharedFlag.name == f.name }) {
+				continue
+			}
+			fmt.Fprintf(&b, "complete -c %s -n '__fish_seen_subcommand_from %s' -l %s%s -d %s\n", program, cmd.name, f.name, fishFlagArgs(f), fishQuote(f.usage))
+		}
+		if args := commandArgs[cmd.name]; len(args) > 0 {
+			fmt.Fprintf(&b, "complete -c %s -f -n '__fish_seen_subcommand_from %s' -a %s\n", program, cmd.name, fishQuote(strings.Join(args, " ")))
+		}
+	}
+	fmt.Fprintf(&b, "complete -c %s -l log-level -x -a %s\n", program, fishQuote(strings.Join(logLevels, " ")))
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// flagWords returns the --name form of flags
+func flagWords(flags []completionFlag) []string {
+	words := make([]string, 0, len(flags))
+	for _, f := range flags {
+		words = append(words, "--"+f.name)
+	}
+	return words
+}
+
+// fishFlagArgs returns the fish options describing a flag's argument
+func fishFlagArgs(f completionFlag) string {
+	switch {
+	case f.takesFile:
+		return " -r -F"
+	case f.takesArg:
+		return " -r"
+	default:
+		return ""
+	}
+}
+
+// fishQuote quotes s for a fish script
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
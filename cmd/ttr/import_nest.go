@@ -0,0 +1,39 @@
+//go:build nest || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/nest"
+)
+
+func init() {
+	runTakeoutImport = runNestImport
+}
+
+// runNestImport opens the sinks and writes the runtime history in a Nest
+// takeout export to them
+func runNestImport(ctx context.Context, app *Application, takeoutPath string, logger *slog.Logger) error {
+	thermostats, err := nest.OpenTakeout(takeoutPath)
+	if err != nil {
+		return err
+	}
+
+	if err := openSinks(ctx, app); err != nil {
+		return err
+	}
+	defer closeSinks(ctx, app, logger)
+
+	for _, thermostat := range thermostats {
+		logger.Info("Importing Nest thermostat history",
+			"thermostat", thermostat.Ref.ID,
+			"rows", len(thermostat.Rows))
+		if _, err := app.Scheduler.ImportRuntime(ctx, nest.ProviderName, thermostat.Ref, thermostat.Rows); err != nil {
+			return fmt.Errorf("importing thermostat %s: %w", thermostat.Ref.ID, err)
+		}
+	}
+	return nil
+}
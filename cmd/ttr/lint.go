@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/core"
+)
+
+// runLintDocs lints canonical documents in NDJSON files, or stdin when no
+// files are given or a file is "-". Violations are written to out, one per
+// line. It returns an error if any document has an error-severity violation.
+func runLintDocs(files []string, stdin io.Reader, out io.Writer) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	documents, errorCount, warningCount := 0, 0, 0
+	for _, path := range files {
+		report, err := lintFile(path, stdin)
+		if err != nil {
+			return err
+		}
+		name := path
+		if name == "-" {
+			name = "stdin"
+		}
+		for _, violation := range report.Violations {
+			field := ""
+			if violation.Field != "" {
+				field = violation.Field + ": "
+			}
+			if _, err := fmt.Fprintf(out, "%s:%d: %s: %s%s\n", name, violation.Line, violation.Severity, field, violation.Message); err != nil {
+				return err
+			}
+		}
+		documents += report.Documents
+		errorCount += report.Errors()
+		warningCount += len(report.Violations) - report.Errors()
+	}
+
+	if _, err := fmt.Fprintf(out, "%d documents, %d errors, %d warnings\n", documents, errorCount, warningCount); err != nil {
+		return err
+	}
+	if errorCount > 0 {
+		return fmt.Errorf("found %d lint errors", errorCount)
+	}
+	return nil
+}
+
+func lintFile(path string, stdin io.Reader) (*core.LintReport, error) {
+	if path == "-" {
+		return core.LintDocuments(stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	report, err := core.LintDocuments(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return report, nil
+}
@@ -1,87 +1,315 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/benvon/thermostat-telemetry-reader/internal/analysis"
 	"github.com/benvon/thermostat-telemetry-reader/internal/core"
-	"github.com/benvon/thermostat-telemetry-reader/internal/providers/ecobee"
-	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/elasticsearch"
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+	"github.com/benvon/thermostat-telemetry-reader/internal/schedule"
 	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/pipeline"
 )
 
+// Flags kept for scripts written before ttr had commands; each runs the
+// command that replaced it
 var (
-	configFile  = flag.String("config", "config.yaml", "Path to configuration file")
-	versionFlag = flag.Bool("version", false, "Show version information")
+	versionFlag = flag.Bool("version", false, "Show version information (deprecated: use the version command)")
+	decryptFlag = flag.String("decrypt", "", "Decrypt a value, or - for one value per line from stdin, then exit (deprecated: use the decrypt command)")
+	nestTakeout = flag.String("import-nest-takeout", "", "Import Nest thermostat history from a Google Takeout .zip or directory, then exit (deprecated: use the import-nest-takeout command)")
 )
 
 const appName = "thermostat-telemetry-reader"
 
-var appVersion = "dev"
+// defaultConfigFile is read when --config is not given
+const defaultConfigFile = "config.yaml"
 
 func main() {
+	opts := &globalOptions{configFile: defaultConfigFile}
+	opts.register(flag.CommandLine)
+	flag.Usage = func() {
+		printUsage(flag.CommandLine.Output())
+	}
 	flag.Parse()
 
-	if *versionFlag {
-		fmt.Printf("%s version %s\n", appName, appVersion)
-		os.Exit(0)
+	os.Exit(execute(context.Background(), opts, legacyArgs(flag.Args())))
+}
+
+// legacyArgs turns the deprecated flags into the commands that replaced them
+func legacyArgs(args []string) []string {
+	switch {
+	case *versionFlag:
+		return []string{"version"}
+	case *decryptFlag != "":
+		return []string{"decrypt", *decryptFlag}
+	case *nestTakeout != "":
+		return []string{"import-nest-takeout", *nestTakeout}
+	default:
+		return args
 	}
+}
 
-	// Load configuration
-	cfg, err := config.LoadConfig(*configFile)
+// runService collects telemetry until SIGINT or SIGTERM
+func runService(ctx context.Context, opts *globalOptions) error {
+	cfg, err := opts.loadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+
+	fingerprint, err := cfg.Fingerprint()
+	if err != nil {
+		return fmt.Errorf("fingerprinting configuration: %w", err)
 	}
 
 	// Set up logging
-	logger := setupLogger(cfg.TTR.LogLevel)
+	logger := setupLogger(cfg.TTR.LogLevel, os.Stdout)
 	logger.Info("Starting thermostat telemetry reader",
-		"version", appVersion,
-		"config_file", *configFile)
+		"version", version,
+		"config_file", opts.configFile,
+		"config_fingerprint", fingerprint)
 
 	// Create context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := shutdownContext(ctx, logger)
 	defer cancel()
 
-	// Set up signal handling for graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		sig := <-sigChan
-		logger.Info("Received signal, shutting down gracefully", "signal", sig)
-		cancel()
-	}()
-
 	// Initialize components
 	app, err := initializeApp(ctx, cfg, logger)
 	if err != nil {
-		logger.Error("Failed to initialize application", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("initializing application: %w", err)
+	}
+	app.Metrics.SetConfigFingerprint(fingerprint)
+	defer func() {
+		// ctx is cancelled by now; give the sinks time to flush
+		closeCtx, closeCancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+		defer closeCancel()
+		closeSinks(closeCtx, app, logger)
+		closeOffsetStore(app, logger)
+	}()
+
+	// Verify providers and sinks before reporting readiness
+	if cfg.TTR.FailFast {
+		if err := runSelfTest(ctx, app, logger); err != nil {
+			return fmt.Errorf("startup self-test: %w", err)
+		}
 	}
 
+	// Re-read credential files and check the configuration files for edits
+	// on SIGHUP and, if configured, on an interval
+	watchCredentials(ctx, app, cfg.TTR.CredentialsReloadInterval, configChangeCheck(opts, app.Metrics, logger), logger)
+
+	// Let providers refresh tokens and the like off the polling path
+	startBackgroundWorkers(ctx, app, logger)
+
 	// Start health and metrics servers
 	if err := startHealthServers(ctx, app, cfg, logger); err != nil {
-		logger.Error("Failed to start health servers", "error", err)
-		os.Exit(1)
+		return fmt.Errorf("starting health servers: %w", err)
 	}
 
 	// Start the main scheduler
 	logger.Info("Starting scheduler")
-	if err := app.Scheduler.Start(ctx); err != nil && err != context.Canceled {
-		logger.Error("Scheduler failed", "error", err)
-		os.Exit(1)
+	if err := app.Scheduler.Start(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("scheduler: %w", err)
 	}
 
 	logger.Info("Application stopped")
+	return nil
+}
+
+// shutdownContext returns a context that is cancelled on SIGINT or SIGTERM
+func shutdownContext(ctx context.Context, logger *slog.Logger) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		defer signal.Stop(sigChan)
+		select {
+		case sig := <-sigChan:
+			logger.Info("Received signal, shutting down gracefully", "signal", sig)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+// openSinks opens every sink for a one-off command; the service leaves that
+// to its health checks
+func openSinks(ctx context.Context, app *Application) error {
+	for _, sink := range app.Sinks {
+		if err := sink.Open(ctx); err != nil {
+			return fmt.Errorf("opening sink %s: %w", sink.Info().Name, err)
+		}
+	}
+	return nil
+}
+
+// closeSinks closes every sink, logging failures
+func closeSinks(ctx context.Context, app *Application, logger *slog.Logger) {
+	for _, sink := range app.Sinks {
+		if err := sink.Close(ctx); err != nil {
+			logger.Warn("Failed to close sink", "sink", sink.Info().Name, "error", err)
+		}
+	}
+}
+
+// closeOffsetStore closes the offset store if it holds a file or connection
+func closeOffsetStore(app *Application, logger *slog.Logger) {
+	closer, ok := app.OffsetStore.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := closer.Close(); err != nil {
+		logger.Warn("Failed to close offset store", "error", err)
+	}
+}
+
+// watchCredentials reloads provider and sink credential files, then runs
+// checkConfig, on SIGHUP and every interval when interval is positive
+func watchCredentials(ctx context.Context, app *Application, interval time.Duration, checkConfig func(), logger *slog.Logger) {
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		tick = ticker.C
+		go func() {
+			<-ctx.Done()
+			ticker.Stop()
+		}()
+	}
+
+	go func() {
+		defer signal.Stop(hupChan)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hupChan:
+				logger.Info("Received SIGHUP, reloading credentials")
+			case <-tick:
+			}
+			core.ReloadCredentials(app.Providers, app.Sinks, logger)
+			checkConfig()
+		}
+	}()
+}
+
+// configChangeCheck returns a check that re-reads the configuration files
+// and warns when they no longer give the running configuration. Settings
+// other than credential files only apply on restart, so an edited file that
+// was never restarted into is easy to miss.
+func configChangeCheck(opts *globalOptions, metrics *core.MetricsCollector, logger *slog.Logger) func() {
+	return func() {
+		cfg, err := opts.loadConfig()
+		if err != nil {
+			logger.Warn("Failed to re-read configuration for change detection", "error", err)
+			return
+		}
+		fingerprint, err := cfg.Fingerprint()
+		if err != nil {
+			logger.Warn("Failed to fingerprint configuration", "error", err)
+			return
+		}
+		if metrics.RecordDiskConfig(fingerprint) {
+			logger.Warn("Configuration files have changed since startup; restart to apply them",
+				"running_fingerprint", metrics.GetVersionInfo().ConfigFingerprint,
+				"disk_fingerprint", fingerprint)
+		}
+	}
+}
+
+// startBackgroundWorkers runs the background work of every provider that has
+// any until ctx is cancelled
+func startBackgroundWorkers(ctx context.Context, app *Application, logger *slog.Logger) {
+	for _, provider := range app.Providers {
+		worker, ok := provider.(model.BackgroundWorker)
+		if !ok {
+			continue
+		}
+		logger.Debug("Starting provider background worker", "provider", provider.Info().Name)
+		go worker.RunBackground(ctx)
+	}
+}
+
+// runDecrypt decrypts values written by the encrypt_fields transform using the
+// key in TTR_ENCRYPTION_KEY. A value of "-" decrypts each line of in.
+func runDecrypt(value string, in io.Reader, out io.Writer) error {
+	encoded := os.Getenv(pipeline.DefaultEncryptionKeyEnv)
+	if encoded == "" {
+		return fmt.Errorf("%s is not set", pipeline.DefaultEncryptionKeyEnv)
+	}
+	key, err := pipeline.ParseEncryptionKey(encoded)
+	if err != nil {
+		return err
+	}
+	fieldCipher, err := pipeline.NewFieldCipher(key)
+	if err != nil {
+		return err
+	}
+
+	if value != "-" {
+		plaintext, err := fieldCipher.Decrypt(value)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(out, plaintext)
+		return err
+	}
+
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		plaintext, err := fieldCipher.Decrypt(line)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(out, plaintext); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// selfTestTimeout bounds the startup self-test so an unreachable service cannot hang startup
+const selfTestTimeout = time.Minute
+
+// runSelfTest checks every provider and sink, printing a diagnostic report to
+// stderr if any check fails
+func runSelfTest(ctx context.Context, app *Application, logger *slog.Logger) error {
+	logger.Info("Running startup self-test", "providers", len(app.Providers), "sinks", len(app.Sinks))
+
+	testCtx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	report := core.SelfTest(testCtx, app.Providers, app.Sinks)
+	if report.Failed() {
+		fmt.Fprintf(os.Stderr, "Startup self-test report:\n%s", report)
+		return fmt.Errorf("one or more components failed the self-test")
+	}
+
+	logger.Info("Startup self-test passed", "checks", len(report.Checks))
+	return nil
 }
 
 // Application holds all the application components
@@ -90,6 +318,7 @@ type Application struct {
 	Providers     []model.Provider
 	Sinks         []model.Sink
 	Normalizer    *core.Normalizer
+	OffsetStore   core.OffsetStore
 	Scheduler     *core.Scheduler
 	HealthChecker *core.HealthChecker
 	Metrics       *core.MetricsCollector
@@ -118,29 +347,130 @@ func initializeApp(ctx context.Context, cfg *config.Config, logger *slog.Logger)
 	app.Sinks = sinks
 
 	// Initialize normalizer
-	normalizer, err := core.NewNormalizer(cfg.TTR.Timezone)
+	normalizer, err := core.NewNormalizer(cfg.TTR.Timezone,
+		core.WithTemperaturePrecision(cfg.TTR.TemperaturePrecision),
+		core.WithCalibration(cfg.TTR.Calibration.Thermostats, cfg.TTR.Calibration.Sensors))
 	if err != nil {
 		return nil, fmt.Errorf("initializing normalizer: %w", err)
 	}
 	app.Normalizer = normalizer
 
 	// Initialize offset store
-	// Try to use SQLite for persistent storage, fall back to in-memory if unavailable
-	var offsetStore core.OffsetStore
-	sqliteStore, err := core.NewSQLiteOffsetStore("./data/offsets.db")
+	offsetStore, err := initializeOffsetStore(ctx, cfg.TTR.OffsetStore, logger)
 	if err != nil {
-		logger.Warn("Failed to initialize SQLite offset store, using in-memory store", "error", err)
-		offsetStore = core.NewMemoryOffsetStore()
-	} else {
-		logger.Info("Using SQLite offset store", "path", "./data/offsets.db")
-		offsetStore = sqliteStore
+		return nil, fmt.Errorf("initializing offset store: %w", err)
 	}
+	app.OffsetStore = offsetStore
 
 	// Initialize metrics collector
 	metrics := core.NewMetricsCollector()
+	metrics.SetBuildInfo(buildInfo())
 	app.Metrics = metrics
+	for _, provider := range app.Providers {
+		metrics.TrackProviderTraffic(provider)
+	}
 
 	// Initialize scheduler
+	schedulerOpts := []core.SchedulerOption{
+		core.WithAnalyzers(initializeAnalyzers(cfg, logger)...),
+		core.WithMetadata(metadataConfig(cfg)),
+		core.WithLiveTier(liveConfig(cfg)),
+		core.WithBackfillPolicy(core.BackfillPolicy(cfg.TTR.BackfillFailurePolicy)),
+		core.WithProvenance(version, instanceID(cfg, logger)),
+		core.WithThermostatTags(cfg.TTR.Tags.Thermostats),
+		core.WithInflightLimit(core.InflightConfig{
+			MaxDocuments: cfg.TTR.Inflight.MaxDocuments,
+			MaxBytes:     int64(cfg.TTR.Inflight.MaxBytes),
+		}),
+	}
+	if cfg.TTR.Analysis.SensorAnomalies.Enabled {
+		schedulerOpts = append(schedulerOpts, core.WithAnomalyDetector(initializeAnomalyDetector(cfg, logger)))
+	}
+	if cfg.TTR.Analysis.Conflicts.Enabled {
+		schedulerOpts = append(schedulerOpts, core.WithAnomalyDetector(initializeConflictDetector(cfg, logger)))
+	}
+	if dispatcher := initializeNotifications(cfg, logger); dispatcher.Len() > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithNotifier(dispatcher, cfg.TTR.Notify.FailureAfter))
+	}
+	healthOpts := []core.HealthOption{
+		core.WithErrorBudget(metrics, core.ErrorBudget{
+			Window:        cfg.TTR.Health.ErrorWindow,
+			DegradedRate:  cfg.TTR.Health.DegradedErrorRate,
+			UnhealthyRate: cfg.TTR.Health.UnhealthyErrorRate,
+			MinRequests:   cfg.TTR.Health.MinRequests,
+		}),
+		core.WithCheckTimeouts(cfg.TTR.Health.CheckTimeout, cfg.TTR.Health.Timeout),
+	}
+	if cfg.TTR.Health.WatchdogAfter > 0 {
+		watchdog := core.NewWriteWatchdog(cfg.TTR.Health.WatchdogAfter)
+		schedulerOpts = append(schedulerOpts, core.WithWriteWatchdog(watchdog))
+		healthOpts = append(healthOpts, core.WithWatchdogCheck(watchdog))
+	}
+	if window := cfg.TTR.Health.ReconcileWindow; window > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithReconciliation(window))
+		logger.Info("Document reconciliation enabled", "window", window)
+	}
+	if cycles := cfg.TTR.Health.LeakWindowCycles; cycles > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithResourceMonitor(cycles))
+	}
+	if gapFill := cfg.TTR.GapFill; gapFill.Policy != string(core.GapFillNone) {
+		schedulerOpts = append(schedulerOpts, core.WithGapFill(core.GapFillPolicy(gapFill.Policy), gapFill.MaxBins))
+		logger.Info("Runtime gap fill enabled", "policy", gapFill.Policy, "max_bins", gapFill.MaxBins)
+	}
+	if faults := cfg.TTR.Faults; faults.Enabled {
+		schedulerOpts = append(schedulerOpts, core.WithFaultInjection(core.FaultInjection{
+			ProviderErrorRate: faults.ProviderErrorRate,
+			SinkDelay:         faults.SinkDelay,
+			SinkDelayRate:     faults.SinkDelayRate,
+			DropRate:          faults.DropRate,
+			Seed:              uint64(faults.Seed),
+		}))
+		logger.Warn("Fault injection enabled; provider calls and sink writes will fail on purpose",
+			"provider_error_rate", faults.ProviderErrorRate,
+			"sink_delay", faults.SinkDelay,
+			"sink_delay_rate", faults.SinkDelayRate,
+			"drop_rate", faults.DropRate)
+	}
+	if quality := cfg.TTR.Analysis.DataQuality; quality.Enabled {
+		tracker := core.NewDataQualityTracker(core.DataQualityConfig{Window: quality.Window, Interval: quality.Interval})
+		metrics.TrackDataQuality(tracker)
+		schedulerOpts = append(schedulerOpts, core.WithAnalyzers(tracker))
+		logger.Info("Data quality scores enabled", "window", quality.Window, "interval", quality.Interval)
+	}
+	maintenanceOpts, err := maintenanceWindows(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("initializing maintenance windows: %w", err)
+	}
+	schedulerOpts = append(schedulerOpts, maintenanceOpts...)
+	schedulerOpts = append(schedulerOpts, requestBudgets(cfg, logger)...)
+	schedulerOpts = append(schedulerOpts, settlingDelays(cfg)...)
+	schedulerOpts = append(schedulerOpts, binAlignments(cfg)...)
+	schedulerOpts = append(schedulerOpts, snapshotCaches(cfg, logger)...)
+	schedulerOpts = append(schedulerOpts, callTimeouts(cfg)...)
+	if pacing := cfg.TTR.BackfillMaxRequestsPerCycle; pacing > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithBackfillPacing(pacing))
+		logger.Info("Paced backfill enabled", "max_requests_per_cycle", pacing)
+	}
+	if window := cfg.TTR.BackfillOverwriteWindow; window > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithBackfillOverwriteWindow(window))
+		logger.Info("Backfill resumes from stored offsets", "overwrite_window", window)
+	}
+
+	verified, err := verifiedSinks(cfg, sinks)
+	if err != nil {
+		return nil, fmt.Errorf("initializing write verification: %w", err)
+	}
+	if len(verified) > 0 {
+		schedulerOpts = append(schedulerOpts, core.WithWriteVerification(verified...))
+		logger.Info("Write verification enabled", "sinks", verified)
+	}
+
+	strategy, err := initializeStrategy(cfg, logger)
+	if err != nil {
+		return nil, fmt.Errorf("initializing schedule strategy: %w", err)
+	}
+	schedulerOpts = append(schedulerOpts, core.WithStrategy(strategy))
+
 	scheduler := core.NewScheduler(
 		providers,
 		sinks,
@@ -150,51 +480,355 @@ func initializeApp(ctx context.Context, cfg *config.Config, logger *slog.Logger)
 		cfg.TTR.BackfillWindow,
 		metrics,
 		logger,
+		schedulerOpts...,
 	)
 	app.Scheduler = scheduler
 
 	// Initialize health checker
-	healthChecker := core.NewHealthChecker(providers, sinks)
+	healthChecker := core.NewHealthChecker(providers, sinks, healthOpts...)
 	app.HealthChecker = healthChecker
 
 	return app, nil
 }
 
-// initializeProviders initializes all configured providers
-func initializeProviders(cfg *config.Config, logger *slog.Logger) ([]model.Provider, error) {
-	var providers []model.Provider
+// initializeOffsetStore opens the configured offset store. Local stores fall
+// back to an in-memory store if they are unavailable; a postgres store is
+// shared between replicas, so failing to reach it is an error instead.
+func initializeOffsetStore(ctx context.Context, storeConfig config.OffsetStoreConfig, logger *slog.Logger) (core.OffsetStore, error) {
+	var (
+		store core.OffsetStore
+		err   error
+	)
+	path := storeConfig.Path
+	switch storeConfig.Type {
+	case "memory":
+		logger.Info("Using in-memory offset store")
+		return core.NewMemoryOffsetStore(), nil
+	case "postgres":
+		pgStore, err := core.NewPostgresOffsetStore(ctx, storeConfig.DSN, core.PostgresOptions{MaxConns: storeConfig.MaxConns})
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Using postgres offset store", "max_conns", storeConfig.MaxConns)
+		return pgStore, nil
+	case "bolt":
+		if path == "" {
+			path = "./data/offsets.bolt"
+		}
+		store, err = core.NewBoltOffsetStore(path)
+	default:
+		if path == "" {
+			path = "./data/offsets.db"
+		}
+		store, err = core.NewSQLiteOffsetStore(path)
+	}
 
-	enabledProviders := cfg.GetEnabledProviders()
-	for _, providerConfig := range enabledProviders {
-		switch providerConfig.Name {
-		case "ecobee":
-			provider, err := initializeEcobeeProvider(providerConfig, logger)
+	if err != nil {
+		logger.Warn("Failed to initialize offset store, using in-memory store", "type", storeConfig.Type, "path", path, "error", err)
+		return core.NewMemoryOffsetStore(), nil
+	}
+	logger.Info("Using offset store", "type", storeConfig.Type, "path", path)
+	return store, nil
+}
+
+// initializeStrategy builds the configured polling strategy
+func initializeStrategy(cfg *config.Config, logger *slog.Logger) (core.Strategy, error) {
+	switch cfg.TTR.Schedule.Strategy {
+	case "cron":
+		location, err := time.LoadLocation(cfg.TTR.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("loading timezone %s: %w", cfg.TTR.Timezone, err)
+		}
+		strategy, err := schedule.NewCron(cfg.TTR.Schedule.Cron, location)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Using cron polling schedule", "cron", cfg.TTR.Schedule.Cron, "timezone", cfg.TTR.Timezone)
+		return strategy, nil
+	case "adaptive":
+		logger.Info("Using adaptive polling schedule",
+			"min_interval", cfg.TTR.Schedule.MinInterval,
+			"max_interval", cfg.TTR.Schedule.MaxInterval)
+		return schedule.NewAdaptive(cfg.TTR.Schedule.MinInterval, cfg.TTR.Schedule.MaxInterval), nil
+	default:
+		return schedule.NewFixed(cfg.TTR.PollInterval), nil
+	}
+}
+
+// maintenanceWindows converts provider maintenance windows to scheduler options.
+// Windows without a timezone use ttr.timezone.
+func maintenanceWindows(cfg *config.Config) ([]core.SchedulerOption, error) {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		windows := make([]core.MaintenanceWindow, 0, len(providerConfig.MaintenanceWindows))
+		for _, windowConfig := range providerConfig.MaintenanceWindows {
+			days, err := windowConfig.Weekdays()
 			if err != nil {
-				return nil, fmt.Errorf("initializing ecobee provider: %w", err)
+				return nil, fmt.Errorf("provider %s: %w", providerConfig.Name, err)
 			}
-			providers = append(providers, provider)
-		default:
-			logger.Warn("Unknown provider type", "provider", providerConfig.Name)
+			start, end, err := windowConfig.ClockRange()
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: %w", providerConfig.Name, err)
+			}
+			timezone := windowConfig.Timezone
+			if timezone == "" {
+				timezone = cfg.TTR.Timezone
+			}
+			location, err := time.LoadLocation(timezone)
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: loading timezone %s: %w", providerConfig.Name, timezone, err)
+			}
+
+			windows = append(windows, core.MaintenanceWindow{
+				Days:     days,
+				Start:    start,
+				End:      end,
+				Location: location,
+			})
 		}
+		opts = append(opts, core.WithMaintenanceWindows(providerConfig.Name, windows...))
 	}
+	return opts, nil
+}
 
-	return providers, nil
+// requestBudgets converts provider request budgets to scheduler options
+func requestBudgets(cfg *config.Config, logger *slog.Logger) []core.SchedulerOption {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		budget := providerConfig.RequestBudget
+		if budget.PerHour == 0 && budget.PerDay == 0 {
+			continue
+		}
+		logger.Info("Provider request budget enabled",
+			"provider", providerConfig.Name,
+			"per_hour", budget.PerHour,
+			"per_day", budget.PerDay)
+		opts = append(opts, core.WithRequestBudget(providerConfig.Name, core.RequestBudget{
+			PerHour: budget.PerHour,
+			PerDay:  budget.PerDay,
+		}))
+	}
+	return opts
 }
 
-// initializeEcobeeProvider initializes the Ecobee provider
-func initializeEcobeeProvider(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error) {
-	clientID, ok := providerConfig.Settings["client_id"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing or invalid client_id in ecobee provider config")
+// settlingDelays converts provider settling delays to scheduler options
+func settlingDelays(cfg *config.Config) []core.SchedulerOption {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		if providerConfig.SettlingDelay > 0 {
+			opts = append(opts, core.WithSettlingDelay(providerConfig.Name, providerConfig.SettlingDelay))
+		}
 	}
+	return opts
+}
 
-	refreshToken, ok := providerConfig.Settings["refresh_token"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing or invalid refresh_token in ecobee provider config")
+// binAlignments converts provider align_bins settings to scheduler options
+func binAlignments(cfg *config.Config) []core.SchedulerOption {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		if providerConfig.AlignBins {
+			opts = append(opts, core.WithBinAlignment(providerConfig.Name))
+		}
+	}
+	return opts
+}
+
+// snapshotCaches converts provider snapshot cache ages to scheduler options
+func snapshotCaches(cfg *config.Config, logger *slog.Logger) []core.SchedulerOption {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		if providerConfig.SnapshotCacheMaxAge > 0 {
+			logger.Info("Snapshot cache enabled", "provider", providerConfig.Name, "max_age", providerConfig.SnapshotCacheMaxAge)
+			opts = append(opts, core.WithSnapshotCache(providerConfig.Name, providerConfig.SnapshotCacheMaxAge))
+		}
+	}
+	return opts
+}
+
+// callTimeouts converts provider request and sink write timeouts to
+// scheduler options
+func callTimeouts(cfg *config.Config) []core.SchedulerOption {
+	var opts []core.SchedulerOption
+	for _, providerConfig := range cfg.GetEnabledProviders() {
+		opts = append(opts, core.WithProviderTimeout(providerConfig.Name, providerConfig.RequestTimeout))
+	}
+	for _, sinkConfig := range cfg.GetEnabledSinks() {
+		opts = append(opts, core.WithSinkTimeout(sinkConfig.Name, sinkConfig.WriteTimeout))
+	}
+	return opts
+}
+
+// liveConfig converts live tier settings to scheduler configuration
+func liveConfig(cfg *config.Config) core.LiveConfig {
+	if !cfg.TTR.Live.Enabled {
+		return core.LiveConfig{}
+	}
+	return core.LiveConfig{
+		Interval:    cfg.TTR.Live.Interval,
+		Thermostats: cfg.TTR.Live.Thermostats,
+	}
+}
+
+// metadataConfig converts location metadata settings to scheduler configuration
+func metadataConfig(cfg *config.Config) core.MetadataConfig {
+	overrides := make(map[string]model.Location, len(cfg.TTR.Metadata.Thermostats))
+	for thermostatID, location := range cfg.TTR.Metadata.Thermostats {
+		overrides[thermostatID] = model.Location{
+			City:          location.City,
+			Region:        location.Region,
+			Country:       location.Country,
+			PostalCode:    location.PostalCode,
+			Latitude:      location.Latitude,
+			Longitude:     location.Longitude,
+			SquareFootage: location.SquareFootage,
+			HVACType:      location.HVACType,
+			TimeZone:      location.TimeZone,
+		}
+	}
+
+	return core.MetadataConfig{
+		RefreshInterval: cfg.TTR.Metadata.RefreshInterval,
+		InjectFields:    cfg.TTR.Metadata.InjectFields,
+		Overrides:       overrides,
+	}
+}
+
+// initializeAnalyzers initializes all enabled analyzers
+func initializeAnalyzers(cfg *config.Config, logger *slog.Logger) []core.Analyzer {
+	var analyzers []core.Analyzer
+
+	// Daily periods follow each thermostat's reported timezone, or ttr.timezone
+	location, err := time.LoadLocation(cfg.TTR.Timezone)
+	if err != nil {
+		logger.Warn("Failed to load timezone for analysis periods, using UTC", "timezone", cfg.TTR.Timezone, "error", err)
+		location = time.UTC
+	}
+
+	if cfg.TTR.Analysis.HeatPump.Enabled {
+		heatPumpConfig := analysis.DefaultHeatPumpConfig()
+		heatPumpConfig.Period = cfg.TTR.Analysis.HeatPump.Period
+		heatPumpConfig.Location = location
+		analyzers = append(analyzers, analysis.NewHeatPumpAnalyzer(heatPumpConfig))
+		logger.Info("Heat pump analysis enabled", "period", heatPumpConfig.Period)
+	}
+
+	if cfg.TTR.Analysis.ScheduleAdherence.Enabled {
+		adherenceConfig := analysis.DefaultScheduleAdherenceConfig()
+		adherenceConfig.Period = cfg.TTR.Analysis.ScheduleAdherence.Period
+		adherenceConfig.Location = location
+		analyzers = append(analyzers, analysis.NewScheduleAdherenceAnalyzer(adherenceConfig))
+		logger.Info("Schedule adherence analysis enabled", "period", adherenceConfig.Period)
+	}
+
+	if cfg.TTR.Analysis.Household.Enabled {
+		householdConfig := analysis.DefaultHouseholdConfig()
+		householdConfig.Period = cfg.TTR.Analysis.Household.Period
+		householdConfig.Location = location
+		analyzers = append(analyzers, analysis.NewHouseholdAnalyzer(householdConfig))
+		logger.Info("Household analysis enabled", "period", householdConfig.Period)
+	}
+
+	if cfg.TTR.Analysis.Staging.Enabled {
+		stagingConfig := analysis.DefaultStagingConfig()
+		stagingConfig.Period = cfg.TTR.Analysis.Staging.Period
+		stagingConfig.AuxWindow = cfg.TTR.Analysis.Staging.AuxWindow
+		stagingConfig.Location = location
+		analyzers = append(analyzers, analysis.NewStagingAnalyzer(stagingConfig))
+		logger.Info("Staging analysis enabled", "period", stagingConfig.Period, "aux_window", stagingConfig.AuxWindow)
+	}
+
+	if cfg.TTR.Analysis.Recommendations.Enabled {
+		setpointConfig := analysis.DefaultSetpointConfig()
+		setpointConfig.Period = cfg.TTR.Analysis.Recommendations.Period
+		setpointConfig.HeatingKW = cfg.TTR.Analysis.Recommendations.HeatingKW
+		setpointConfig.CoolingKW = cfg.TTR.Analysis.Recommendations.CoolingKW
+		setpointConfig.Location = location
+		analyzers = append(analyzers, analysis.NewSetpointAnalyzer(setpointConfig))
+		logger.Info("Setpoint recommendations enabled", "period", setpointConfig.Period)
+	}
+
+	return analyzers
+}
+
+// initializeNotifications builds a dispatcher for the configured notification
+// channels
+func initializeNotifications(cfg *config.Config, logger *slog.Logger) *notify.Dispatcher {
+	dispatcher := notify.NewDispatcher(logger)
+	for _, channel := range cfg.TTR.Notify.Channels {
+		var notifier notify.Notifier
+		switch channel.Type {
+		case "pushover":
+			notifier = notify.NewPushover(channel.Token, channel.User)
+		case "telegram":
+			notifier = notify.NewTelegram(channel.Token, channel.ChatID)
+		case "ntfy":
+			notifier = notify.NewNtfy(channel.Server, channel.Topic, channel.Token)
+		}
+		dispatcher.Add(channel.Name, notifier, notify.Limit{RepeatInterval: channel.RepeatInterval, MaxPerHour: channel.MaxPerHour})
+		logger.Info("Notification channel enabled",
+			"channel", channel.Name,
+			"type", channel.Type,
+			"repeat_interval", channel.RepeatInterval,
+			"max_per_hour", channel.MaxPerHour)
+	}
+	return dispatcher
+}
+
+// initializeAnomalyDetector builds the sensor anomaly detector from config
+func initializeAnomalyDetector(cfg *config.Config, logger *slog.Logger) *analysis.SensorAnomalyDetector {
+	anomalies := cfg.TTR.Analysis.SensorAnomalies
+	detectorConfig := analysis.SensorAnomalyConfig{
+		StuckDuration:      anomalies.StuckDuration,
+		MaxJumpC:           anomalies.MaxJumpC,
+		DivergenceC:        anomalies.DivergenceC,
+		DivergenceDuration: anomalies.DivergenceDuration,
+	}
+	logger.Info("Sensor anomaly detection enabled",
+		"stuck_duration", detectorConfig.StuckDuration,
+		"max_jump_c", detectorConfig.MaxJumpC,
+		"divergence_c", detectorConfig.DivergenceC)
+	return analysis.NewSensorAnomalyDetector(detectorConfig)
+}
+
+// initializeConflictDetector builds the heat/cool and mode conflict detector from config
+func initializeConflictDetector(cfg *config.Config, logger *slog.Logger) *analysis.ConflictDetector {
+	conflicts := cfg.TTR.Analysis.Conflicts
+	detectorConfig := analysis.ConflictConfig{
+		SimultaneousDuration: conflicts.SimultaneousDuration,
+		ModeDuration:         conflicts.ModeDuration,
+		HeatMaxOutdoorC:      conflicts.HeatMaxOutdoorC,
+		CoolMinOutdoorC:      conflicts.CoolMinOutdoorC,
+	}
+	logger.Info("Conflict detection enabled",
+		"simultaneous_duration", detectorConfig.SimultaneousDuration,
+		"mode_duration", detectorConfig.ModeDuration,
+		"heat_max_outdoor_c", detectorConfig.HeatMaxOutdoorC,
+		"cool_min_outdoor_c", detectorConfig.CoolMinOutdoorC)
+	return analysis.NewConflictDetector(detectorConfig)
+}
+
+// initializeProviders initializes all configured providers
+func initializeProviders(cfg *config.Config, logger *slog.Logger) ([]model.Provider, error) {
+	var providers []model.Provider
+
+	enabledProviders := cfg.GetEnabledProviders()
+	for _, providerConfig := range enabledProviders {
+		factory, ok := providerFactories[providerConfig.Name]
+		if !ok {
+			if slices.Contains(knownProviders, providerConfig.Name) {
+				return nil, fmt.Errorf("the %s provider is not compiled into this binary; rebuild without integration tags or add -tags %s", providerConfig.Name, providerConfig.Name)
+			}
+			logger.Warn("Unknown provider type", "provider", providerConfig.Name)
+			continue
+		}
+		provider, err := factory(providerConfig, logger)
+		if err != nil {
+			return nil, fmt.Errorf("initializing %s provider: %w", providerConfig.Name, err)
+		}
+		providers = append(providers, provider)
 	}
 
-	logger.Info("Initializing Ecobee provider", "client_id", clientID)
-	return ecobee.NewProvider(clientID, refreshToken), nil
+	return providers, nil
 }
 
 // initializeSinks initializes all configured sinks
@@ -203,45 +837,216 @@ func initializeSinks(cfg *config.Config, logger *slog.Logger) ([]model.Sink, err
 
 	enabledSinks := cfg.GetEnabledSinks()
 	for _, sinkConfig := range enabledSinks {
-		switch sinkConfig.Name {
-		case "elasticsearch":
-			sink, err := initializeElasticsearchSink(sinkConfig, logger)
-			if err != nil {
-				return nil, fmt.Errorf("initializing elasticsearch sink: %w", err)
+		factory, ok := sinkFactories[sinkConfig.Name]
+		if !ok {
+			if slices.Contains(knownSinks, sinkConfig.Name) {
+				return nil, fmt.Errorf("the %s sink is not compiled into this binary; rebuild without integration tags or add -tags %s", sinkConfig.Name, sinkConfig.Name)
 			}
-			sinks = append(sinks, sink)
-		default:
 			logger.Warn("Unknown sink type", "sink", sinkConfig.Name)
+			continue
 		}
+		sink, err := factory(sinkConfig, logger)
+		if err != nil {
+			return nil, fmt.Errorf("initializing %s sink: %w", sinkConfig.Name, err)
+		}
+
+		sink, err = applyValidateOnly(sink, sinkConfig, logger)
+		if err != nil {
+			return nil, err
+		}
+
+		sink, err = wrapSinkPipeline(sink, sinkConfig, cfg.TTR.Fields, logger)
+		if err != nil {
+			return nil, fmt.Errorf("initializing %s sink pipeline: %w", sinkConfig.Name, err)
+		}
+		// Route outside the pipeline so documents a sink never receives are not transformed
+		sinks = append(sinks, pipeline.NewRouter(sink, sinkConfig.DocTypes))
 	}
 
 	return sinks, nil
 }
 
-// initializeElasticsearchSink initializes the Elasticsearch sink
-func initializeElasticsearchSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
-	url, ok := sinkConfig.Settings["url"].(string)
-	if !ok {
-		return nil, fmt.Errorf("missing or invalid url in elasticsearch sink config")
+// commonSinkSettings are the settings every sink accepts, handled around the
+// sink rather than by it
+type commonSinkSettings struct {
+	ValidateOnly bool `settings:"validate_only"`
+	VerifyWrites bool `settings:"verify_writes"`
+}
+
+// applyValidateOnly puts a sink in validate-only mode when its validate_only
+// setting is true. Sinks that can validate against their backend do so;
+// others only serialize documents.
+func applyValidateOnly(sink model.Sink, sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	var s commonSinkSettings
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+	if !s.ValidateOnly {
+		return sink, nil
 	}
 
-	apiKey, _ := sinkConfig.Settings["api_key"].(string)
-	indexPrefix, ok := sinkConfig.Settings["index_prefix"].(string)
-	if !ok {
-		indexPrefix = "ttr"
+	if validating, ok := sink.(model.ValidatingSink); ok {
+		validating.ValidateOnly()
+		logger.Warn("Sink is in validate-only mode; documents are checked against the backend but not stored",
+			"sink", sinkConfig.Name)
+		return sink, nil
 	}
+	logger.Warn("Sink is in validate-only mode; documents are serialized but not written",
+		"sink", sinkConfig.Name)
+	return pipeline.NewValidator(sink), nil
+}
 
-	createTemplates, ok := sinkConfig.Settings["create_templates"].(bool)
-	if !ok {
-		createTemplates = true
+// verifiedSinks returns the names of sinks whose verify_writes setting is
+// true. Each must be able to read back documents, and cannot also be in
+// validate-only mode.
+func verifiedSinks(cfg *config.Config, sinks []model.Sink) ([]string, error) {
+	var names []string
+	for _, sinkConfig := range cfg.GetEnabledSinks() {
+		var s commonSinkSettings
+		if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+			return nil, err
+		}
+		if !s.VerifyWrites {
+			continue
+		}
+		if s.ValidateOnly {
+			return nil, fmt.Errorf("the %s sink cannot verify writes in validate-only mode", sinkConfig.Name)
+		}
+
+		index := slices.IndexFunc(sinks, func(sink model.Sink) bool { return sink.Info().Name == sinkConfig.Name })
+		if index < 0 || !core.SupportsWriteVerification(sinks[index]) {
+			return nil, fmt.Errorf("the %s sink cannot read back documents to verify writes", sinkConfig.Name)
+		}
+		names = append(names, sinkConfig.Name)
+	}
+	return names, nil
+}
+
+// wrapSinkPipeline wraps a sink with its configured transforms, if any.
+// The raw_payload setting runs first so later transforms see the trimmed
+// payload, then the global field selection, then the sink's own transforms.
+func wrapSinkPipeline(sink model.Sink, sinkConfig config.SinkConfig, fields map[string]config.FieldsConfig, logger *slog.Logger) (model.Sink, error) {
+	transforms := append(fieldTransforms(fields), sinkConfig.Transforms...)
+
+	if rawPayload, ok := sinkConfig.Settings["raw_payload"]; ok {
+		mode, ok := rawPayload.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid raw_payload in %s sink config", sinkConfig.Name)
+		}
+		if mode != pipeline.RawPayloadFull {
+			rawPayloadTransform := config.TransformConfig{
+				Name:     pipeline.RawPayloadTransform,
+				Settings: map[string]any{"mode": mode},
+			}
+			transforms = append([]config.TransformConfig{rawPayloadTransform}, transforms...)
+		}
+	}
+
+	if len(transforms) == 0 {
+		return sink, nil
 	}
 
-	logger.Info("Initializing Elasticsearch sink",
-		"url", url,
-		"index_prefix", indexPrefix,
-		"create_templates", createTemplates)
+	steps := make([]pipeline.Step, 0, len(transforms))
+	for _, transformConfig := range transforms {
+		transform, err := pipeline.Build(transformConfig.Name, transformConfig.Settings)
+		if err != nil {
+			return nil, err
+		}
+		if transformConfig.Name == pipeline.EncryptFieldsTransform && rawPayloadMode(sinkConfig) == pipeline.RawPayloadFull {
+			logger.Warn("Provider payloads still hold unencrypted thermostat names; set raw_payload to summary or off",
+				"sink", sinkConfig.Name)
+		}
+		steps = append(steps, pipeline.Step{
+			Name:      transformConfig.Name,
+			Types:     transformConfig.Types,
+			Transform: transform,
+		})
+		logger.Info("Added sink transform",
+			"sink", sinkConfig.Name,
+			"transform", transformConfig.Name,
+			"types", transformConfig.Types)
+	}
 
-	return elasticsearch.NewSink(url, apiKey, indexPrefix, createTemplates), nil
+	return pipeline.NewSink(sink, steps...), nil
+}
+
+// fieldTransforms turns the per-document-type field selection into
+// keep_fields and drop_fields steps, in a stable order
+func fieldTransforms(fields map[string]config.FieldsConfig) []config.TransformConfig {
+	var transforms []config.TransformConfig
+	for _, docType := range slices.Sorted(maps.Keys(fields)) {
+		selection := fields[docType]
+		if len(selection.Include) > 0 {
+			transforms = append(transforms, config.TransformConfig{
+				Name:     pipeline.KeepFieldsTransform,
+				Types:    []string{docType},
+				Settings: map[string]any{"fields": selection.Include},
+			})
+		}
+		if len(selection.Exclude) > 0 {
+			transforms = append(transforms, config.TransformConfig{
+				Name:     pipeline.DropFieldsTransform,
+				Types:    []string{docType},
+				Settings: map[string]any{"fields": selection.Exclude},
+			})
+		}
+	}
+	return transforms
+}
+
+// rawPayloadMode returns a sink's raw_payload setting, defaulting to full
+func rawPayloadMode(sinkConfig config.SinkConfig) string {
+	if mode, ok := sinkConfig.Settings["raw_payload"].(string); ok && mode != "" {
+		return mode
+	}
+	return pipeline.RawPayloadFull
+}
+
+// newHTTPServer creates a server on port with the configured timeouts,
+// header limit, base path and CORS policy
+func newHTTPServer(port int, handler http.Handler, settings config.HTTPConfig) *http.Server {
+	handler = core.WithBasePath(settings.BasePath, handler)
+	handler = core.WithCORS(core.CORSConfig{
+		AllowedOrigins:   settings.CORS.AllowedOrigins,
+		AllowCredentials: settings.CORS.AllowCredentials,
+		MaxAge:           settings.CORS.MaxAge,
+	}, handler)
+
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           handler,
+		ReadHeaderTimeout: settings.ReadHeaderTimeout,
+		ReadTimeout:       settings.ReadTimeout,
+		WriteTimeout:      settings.WriteTimeout,
+		IdleTimeout:       settings.IdleTimeout,
+		MaxHeaderBytes:    settings.MaxHeaderBytes,
+	}
+}
+
+// initializeController opens the control audit log and creates the thermostat
+// controller behind the admin control endpoint. The log is closed on shutdown.
+func initializeController(ctx context.Context, settings config.ControlConfig, providers []model.Provider, configFingerprint string, logger *slog.Logger) (*core.ThermostatController, error) {
+	if err := os.MkdirAll(filepath.Dir(settings.AuditLog), 0o750); err != nil {
+		return nil, fmt.Errorf("creating audit log directory: %w", err)
+	}
+	audit, err := os.OpenFile(settings.AuditLog, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("opening audit log: %w", err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = audit.Close()
+	}()
+
+	if !settings.ReadOnly {
+		logger.Warn("Thermostat control is enabled; admin API callers can change setpoints", "audit_log", settings.AuditLog)
+	}
+	return core.NewThermostatController(providers, core.ControlConfig{
+		ReadOnly:          settings.ReadOnly,
+		MinSetpointC:      settings.MinSetpointC,
+		MaxSetpointC:      settings.MaxSetpointC,
+		ConfigFingerprint: configFingerprint,
+	}, audit, logger), nil
 }
 
 // startHealthServers starts the health and metrics HTTP servers
@@ -250,15 +1055,21 @@ func startHealthServers(ctx context.Context, app *Application, cfg *config.Confi
 	healthMux := http.NewServeMux()
 	healthMux.Handle("/healthz", app.HealthChecker.ServeHealth())
 	healthMux.Handle("/metrics", app.Metrics.ServeMetrics())
+	healthMux.Handle("/metrics/prometheus", app.Metrics.ServePrometheus())
+	healthMux.Handle("/scheduler", app.Metrics.ServeScheduler())
+	healthMux.Handle("/version", app.Metrics.ServeVersion())
+	if cfg.TTR.AdminToken != "" {
+		healthMux.Handle("/admin/offsets/rewind", app.Scheduler.ServeRewind(cfg.TTR.AdminToken))
 
-	healthServer := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.TTR.HealthPort),
-		Handler:           healthMux,
-		ReadHeaderTimeout: 10 * time.Second,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
+		controller, err := initializeController(ctx, cfg.TTR.Control, app.Providers, app.Metrics.GetVersionInfo().ConfigFingerprint, logger)
+		if err != nil {
+			return fmt.Errorf("initializing control: %w", err)
+		}
+		healthMux.Handle("/admin/control", controller.ServeControl(cfg.TTR.AdminToken))
 	}
 
+	healthServer := newHTTPServer(cfg.TTR.HealthPort, healthMux, cfg.TTR.HTTP)
+
 	go func() {
 		logger.Info("Starting health server", "port", cfg.TTR.HealthPort)
 		if err := healthServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -269,14 +1080,9 @@ func startHealthServers(ctx context.Context, app *Application, cfg *config.Confi
 	// Start metrics server
 	metricsMux := http.NewServeMux()
 	metricsMux.Handle("/metrics", app.Metrics.ServeMetrics())
+	metricsMux.Handle("/metrics/prometheus", app.Metrics.ServePrometheus())
 
-	metricsServer := &http.Server{
-		Addr:              fmt.Sprintf(":%d", cfg.TTR.MetricsPort),
-		Handler:           metricsMux,
-		ReadHeaderTimeout: 10 * time.Second,
-		ReadTimeout:       30 * time.Second,
-		WriteTimeout:      30 * time.Second,
-	}
+	metricsServer := newHTTPServer(cfg.TTR.MetricsPort, metricsMux, cfg.TTR.HTTP)
 
 	go func() {
 		logger.Info("Starting metrics server", "port", cfg.TTR.MetricsPort)
@@ -306,8 +1112,8 @@ func startHealthServers(ctx context.Context, app *Application, cfg *config.Confi
 	return nil
 }
 
-// setupLogger configures structured logging
-func setupLogger(level string) *slog.Logger {
+// setupLogger configures structured logging to out
+func setupLogger(level string, out io.Writer) *slog.Logger {
 	var logLevel slog.Level
 	switch level {
 	case "debug":
@@ -326,6 +1132,20 @@ func setupLogger(level string) *slog.Logger {
 		Level: logLevel,
 	}
 
-	handler := slog.NewJSONHandler(os.Stdout, opts)
+	handler := slog.NewJSONHandler(out, opts)
 	return slog.New(handler)
 }
+
+// instanceID returns the instance name stamped on written documents: the
+// configured instance_id, or the hostname
+func instanceID(cfg *config.Config, logger *slog.Logger) string {
+	if cfg.TTR.InstanceID != "" {
+		return cfg.TTR.InstanceID
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Warn("Failed to read hostname for ingest metadata, set ttr.instance_id", "error", err)
+		return "unknown"
+	}
+	return hostname
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/core"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+)
+
+const offsetsUsage = "usage: ttr [-config file] offsets dump|restore [file], or offsets rewind --thermostat ID --to RFC3339-time"
+
+// runOffsetsCommand dumps the configured offset store to JSON, restores it
+// from JSON, or rewinds one thermostat's offsets. The file defaults to stdout
+// for dump and stdin for restore; "-" selects them explicitly.
+func runOffsetsCommand(ctx context.Context, cfg *config.Config, args []string, logger *slog.Logger) error {
+	if len(args) < 1 {
+		return fmt.Errorf("%s", offsetsUsage)
+	}
+	path := "-"
+	var rewind *rewindArgs
+	switch {
+	case args[0] == "rewind":
+		var err error
+		if rewind, err = parseRewindArgs(args[1:]); err != nil {
+			return err
+		}
+	case len(args) == 2:
+		path = args[1]
+	case len(args) > 2:
+		return fmt.Errorf("%s", offsetsUsage)
+	}
+
+	store, err := initializeOffsetStore(ctx, cfg.TTR.OffsetStore, logger)
+	if err != nil {
+		return fmt.Errorf("initializing offset store: %w", err)
+	}
+	if _, fellBack := store.(*core.MemoryOffsetStore); fellBack && cfg.TTR.OffsetStore.Type != "memory" {
+		return fmt.Errorf("%s offset store is unavailable", cfg.TTR.OffsetStore.Type)
+	}
+	if closer, ok := store.(io.Closer); ok {
+		defer func() {
+			_ = closer.Close()
+		}()
+	}
+
+	switch args[0] {
+	case "dump":
+		return dumpOffsets(ctx, store, path)
+	case "restore":
+		return restoreOffsets(ctx, store, path, logger)
+	case "rewind":
+		return rewindOffsets(ctx, store, rewind)
+	default:
+		return fmt.Errorf("unknown offsets command %q; %s", args[0], offsetsUsage)
+	}
+}
+
+// dumpOffsets writes the contents of store as indented JSON to path
+func dumpOffsets(ctx context.Context, store core.OffsetStore, path string) error {
+	dump, err := core.DumpOffsets(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	out := io.Writer(os.Stdout)
+	if path != "-" {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+		if err != nil {
+			return fmt.Errorf("creating dump file: %w", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		out = file
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(dump); err != nil {
+		return fmt.Errorf("writing dump: %w", err)
+	}
+	return nil
+}
+
+// restoreOffsets loads a JSON dump from path into store
+func restoreOffsets(ctx context.Context, store core.OffsetStore, path string, logger *slog.Logger) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening dump file: %w", err)
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		in = file
+	}
+
+	var dump core.OffsetDump
+	if err := json.NewDecoder(in).Decode(&dump); err != nil {
+		return fmt.Errorf("decoding dump: %w", err)
+	}
+
+	restored, err := core.RestoreOffsets(ctx, store, &dump)
+	if err != nil {
+		return err
+	}
+	logger.Info("Restored offsets", "entries", restored, "thermostats", len(dump.Thermostats), "exported_at", dump.ExportedAt)
+	return nil
+}
+
+// rewindArgs are the flags of the offsets rewind command
+type rewindArgs struct {
+	thermostat string
+	to         time.Time
+}
+
+// parseRewindArgs parses --thermostat and --to
+func parseRewindArgs(args []string) (*rewindArgs, error) {
+	flags := flag.NewFlagSet("offsets rewind", flag.ContinueOnError)
+	thermostat := flags.String("thermostat", "", "Thermostat ID whose offsets are rewound")
+	to := flags.String("to", "", "Time to rewind to, in RFC 3339 form, e.g. 2024-05-01T00:00:00Z")
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if *thermostat == "" || *to == "" || flags.NArg() > 0 {
+		return nil, fmt.Errorf("%s", offsetsUsage)
+	}
+	parsed, err := time.Parse(time.RFC3339, *to)
+	if err != nil {
+		return nil, fmt.Errorf("parsing --to: %w", err)
+	}
+	return &rewindArgs{thermostat: *thermostat, to: parsed}, nil
+}
+
+// rewindOffsets rewinds a thermostat's offsets and prints what moved
+func rewindOffsets(ctx context.Context, store core.OffsetStore, args *rewindArgs) error {
+	result, err := core.RewindOffsets(ctx, store, args.thermostat, args.to)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
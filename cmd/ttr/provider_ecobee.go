@@ -0,0 +1,70 @@
+//go:build ecobee || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/ecobee"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerProvider("ecobee", initializeEcobeeProvider)
+}
+
+// ecobeeSettings are the Ecobee provider's settings. Credentials may come
+// from files instead, which are re-read on reload. The access token is
+// refreshed in the background TokenRefreshMargin before it expires, brought
+// forward by up to TokenRefreshJitter. Responses larger than
+// MaxResponseBytes are rejected.
+type ecobeeSettings struct {
+	ClientID           string        `settings:"client_id"`
+	ClientIDFile       string        `settings:"client_id_file"`
+	RefreshToken       string        `settings:"refresh_token"`
+	RefreshTokenFile   string        `settings:"refresh_token_file"`
+	TokenRefreshMargin time.Duration `settings:"token_refresh_margin"`
+	TokenRefreshJitter time.Duration `settings:"token_refresh_jitter"`
+	MaxResponseBytes   int64         `settings:"max_response_bytes"`
+}
+
+// initializeEcobeeProvider initializes the Ecobee provider
+func initializeEcobeeProvider(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error) {
+	s := ecobeeSettings{
+		TokenRefreshMargin: ecobee.DefaultRefreshMargin,
+		TokenRefreshJitter: ecobee.DefaultRefreshJitter,
+		MaxResponseBytes:   bodylimit.DefaultMaxBytes,
+	}
+	if err := decodeProviderSettings(providerConfig, &s); err != nil {
+		return nil, err
+	}
+	if s.ClientID == "" && s.ClientIDFile == "" {
+		return nil, fmt.Errorf("ecobee provider config: missing client_id or client_id_file")
+	}
+	if s.RefreshToken == "" && s.RefreshTokenFile == "" {
+		return nil, fmt.Errorf("ecobee provider config: missing refresh_token or refresh_token_file")
+	}
+	if s.TokenRefreshMargin < 0 || s.TokenRefreshJitter < 0 {
+		return nil, fmt.Errorf("ecobee provider config: token_refresh_margin and token_refresh_jitter must not be negative")
+	}
+
+	provider := ecobee.NewProvider(s.ClientID, s.RefreshToken)
+	if err := provider.UseCredentialFiles(s.ClientIDFile, s.RefreshTokenFile); err != nil {
+		return nil, fmt.Errorf("ecobee provider: %w", err)
+	}
+	if s.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("ecobee provider config: max_response_bytes must be positive")
+	}
+	provider.SetRefreshMargin(s.TokenRefreshMargin, s.TokenRefreshJitter)
+	provider.SetMaxResponseBytes(s.MaxResponseBytes)
+
+	logger.Info("Initializing Ecobee provider",
+		"client_id", s.ClientID,
+		"client_id_file", s.ClientIDFile,
+		"refresh_token_file", s.RefreshTokenFile)
+	return provider, nil
+}
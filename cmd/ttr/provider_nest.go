@@ -0,0 +1,61 @@
+//go:build nest || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/nest"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerProvider("nest", initializeNestProvider)
+}
+
+// nestSettings are the Nest provider's settings: the Device Access project
+// and the OAuth client and refresh token authorized for it. The client
+// secret and refresh token may come from files instead, which are re-read
+// on reload. Responses larger than MaxResponseBytes are rejected.
+type nestSettings struct {
+	ProjectID        string `settings:"project_id,required"`
+	ClientID         string `settings:"client_id,required"`
+	ClientSecret     string `settings:"client_secret"`
+	ClientSecretFile string `settings:"client_secret_file"`
+	RefreshToken     string `settings:"refresh_token"`
+	RefreshTokenFile string `settings:"refresh_token_file"`
+	MaxResponseBytes int64  `settings:"max_response_bytes"`
+}
+
+// initializeNestProvider initializes the Nest provider
+func initializeNestProvider(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error) {
+	s := nestSettings{MaxResponseBytes: bodylimit.DefaultMaxBytes}
+	if err := decodeProviderSettings(providerConfig, &s); err != nil {
+		return nil, err
+	}
+	if s.ClientSecret == "" && s.ClientSecretFile == "" {
+		return nil, fmt.Errorf("nest provider config: missing client_secret or client_secret_file")
+	}
+	if s.RefreshToken == "" && s.RefreshTokenFile == "" {
+		return nil, fmt.Errorf("nest provider config: missing refresh_token or refresh_token_file")
+	}
+	if s.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("nest provider config: max_response_bytes must be positive")
+	}
+
+	provider := nest.NewProvider(s.ProjectID, s.ClientID, s.ClientSecret, s.RefreshToken)
+	if err := provider.UseCredentialFiles(s.ClientSecretFile, s.RefreshTokenFile); err != nil {
+		return nil, fmt.Errorf("nest provider: %w", err)
+	}
+	provider.SetMaxResponseBytes(s.MaxResponseBytes)
+
+	logger.Info("Initializing Nest provider",
+		"project_id", s.ProjectID,
+		"client_id", s.ClientID,
+		"client_secret_file", s.ClientSecretFile,
+		"refresh_token_file", s.RefreshTokenFile)
+	return provider, nil
+}
@@ -0,0 +1,59 @@
+//go:build tado || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/tado"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerProvider("tado", initializeTadoProvider)
+}
+
+// tadoSettings are the Tado provider's settings: the OAuth client and the
+// refresh token authorized for the account. The refresh token may come from
+// a file instead, which is re-read on reload and holds the rotated token
+// across restarts. Responses larger than MaxResponseBytes are rejected.
+type tadoSettings struct {
+	ClientID         string `settings:"client_id"`
+	RefreshToken     string `settings:"refresh_token"`
+	RefreshTokenFile string `settings:"refresh_token_file"`
+	MaxResponseBytes int64  `settings:"max_response_bytes"`
+}
+
+// initializeTadoProvider initializes the Tado provider
+func initializeTadoProvider(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error) {
+	s := tadoSettings{ClientID: tado.DefaultClientID, MaxResponseBytes: bodylimit.DefaultMaxBytes}
+	if err := decodeProviderSettings(providerConfig, &s); err != nil {
+		return nil, err
+	}
+	if s.RefreshToken == "" && s.RefreshTokenFile == "" {
+		return nil, fmt.Errorf("tado provider config: missing refresh_token or refresh_token_file")
+	}
+	if s.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("tado provider config: max_response_bytes must be positive")
+	}
+
+	provider := tado.NewProvider(s.ClientID, s.RefreshToken)
+	if s.RefreshTokenFile != "" {
+		if err := provider.UseCredentialFile(s.RefreshTokenFile); err != nil {
+			return nil, fmt.Errorf("tado provider: %w", err)
+		}
+	}
+	provider.SetMaxResponseBytes(s.MaxResponseBytes)
+
+	logger.Info("Initializing Tado provider",
+		"client_id", s.ClientID,
+		"refresh_token_file", s.RefreshTokenFile)
+	if s.RefreshTokenFile == "" {
+		logger.Warn("Tado rotates refresh tokens and the rotated token is only saved to refresh_token_file; " +
+			"without one, a restart needs a newly authorized refresh_token")
+	}
+	return provider, nil
+}
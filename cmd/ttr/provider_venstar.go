@@ -0,0 +1,56 @@
+//go:build venstar || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/venstar"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerProvider("venstar", initializeVenstarProvider)
+}
+
+// venstarSettings are the Venstar provider's settings: the addresses of the
+// units to poll and whether to also find units with SSDP discovery.
+// Responses larger than MaxResponseBytes are rejected.
+type venstarSettings struct {
+	Hosts            []string      `settings:"hosts"`
+	Discover         bool          `settings:"discover"`
+	DiscoveryTimeout time.Duration `settings:"discovery_timeout"`
+	MaxResponseBytes int64         `settings:"max_response_bytes"`
+}
+
+// initializeVenstarProvider initializes the Venstar provider
+func initializeVenstarProvider(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error) {
+	s := venstarSettings{DiscoveryTimeout: 3 * time.Second, MaxResponseBytes: bodylimit.DefaultMaxBytes}
+	if err := decodeProviderSettings(providerConfig, &s); err != nil {
+		return nil, err
+	}
+	if len(s.Hosts) == 0 && !s.Discover {
+		return nil, fmt.Errorf("venstar provider config: missing hosts or discover")
+	}
+	if s.DiscoveryTimeout <= 0 {
+		return nil, fmt.Errorf("venstar provider config: discovery_timeout must be positive")
+	}
+	if s.MaxResponseBytes <= 0 {
+		return nil, fmt.Errorf("venstar provider config: max_response_bytes must be positive")
+	}
+
+	provider := venstar.NewProvider(s.Hosts)
+	if s.Discover {
+		provider.EnableDiscovery(s.DiscoveryTimeout)
+	}
+	provider.SetMaxResponseBytes(s.MaxResponseBytes)
+
+	logger.Info("Initializing Venstar provider",
+		"hosts", s.Hosts,
+		"discover", s.Discover)
+	return provider, nil
+}
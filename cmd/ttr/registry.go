@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/settings"
+)
+
+// Integrations are compiled in by build tag. A build without any integration
+// tag includes all of them; naming some, e.g. -tags "ecobee,elasticsearch",
+// builds a binary with only those. Each integration's file registers its
+// factory from init and carries the constraint
+//
+//	//go:build <name> || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+//
+// so a new integration must be added to every constraint and to these lists.
+var (
+	knownProviders = []string{"ecobee", "nest", "venstar", "tado"}
+	knownSinks     = []string{"elasticsearch", "duckdb", "csv", "sheets", "nats", "kinesis", "eventhubs", "homeassistant"}
+)
+
+// providerFactory builds a provider from its configuration
+type providerFactory func(providerConfig config.ProviderConfig, logger *slog.Logger) (model.Provider, error)
+
+// sinkFactory builds a sink from its configuration
+type sinkFactory func(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error)
+
+var (
+	providerFactories = make(map[string]providerFactory)
+	sinkFactories     = make(map[string]sinkFactory)
+)
+
+// registerProvider makes a compiled-in provider available by config name
+func registerProvider(name string, factory providerFactory) {
+	providerFactories[name] = factory
+}
+
+// registerSink makes a compiled-in sink available by config name
+func registerSink(name string, factory sinkFactory) {
+	sinkFactories[name] = factory
+}
+
+// compiledIntegrations returns the names of the providers, sinks and importers
+// built into this binary
+func compiledIntegrations() []string {
+	var names []string
+	for name := range providerFactories {
+		names = append(names, name)
+	}
+	for name := range sinkFactories {
+		names = append(names, name)
+	}
+	if runTakeoutImport != nil {
+		names = append(names, "nest")
+	}
+	slices.Sort(names)
+	// The nest tag builds both the provider and the takeout importer
+	return slices.Compact(names)
+}
+
+// runTakeoutImport imports a Nest takeout export; nil when the nest importer
+// is not compiled in
+var runTakeoutImport func(ctx context.Context, app *Application, takeoutPath string, logger *slog.Logger) error
+
+// decodeProviderSettings decodes a provider's settings into target, whose
+// fields hold the defaults; errors name the provider
+func decodeProviderSettings(providerConfig config.ProviderConfig, target any) error {
+	if err := settings.Decode(providerConfig.Settings, target); err != nil {
+		return fmt.Errorf("%s provider config: %w", providerConfig.Name, err)
+	}
+	return nil
+}
+
+// decodeSinkSettings decodes a sink's settings into target, whose fields hold
+// the defaults; errors name the sink
+func decodeSinkSettings(sinkConfig config.SinkConfig, target any) error {
+	if err := settings.Decode(sinkConfig.Settings, target); err != nil {
+		return fmt.Errorf("%s sink config: %w", sinkConfig.Name, err)
+	}
+	return nil
+}
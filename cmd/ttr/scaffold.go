@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/scaffold"
+)
+
+// scaffoldKinds are the integrations ttr scaffold generates, with where their
+// packages go and the registry list each must be added to
+var scaffoldKinds = map[string]struct {
+	render   func(name, module string) (map[string][]byte, error)
+	packages string
+	known    string
+}{
+	"provider": {render: scaffold.Provider, packages: "internal/providers", known: "knownProviders"},
+	"sink":     {render: scaffold.Sink, packages: "internal/sinks", known: "knownSinks"},
+}
+
+// runScaffold writes a skeleton provider or sink into the repository at dir.
+// Existing files are left alone unless force is set.
+func runScaffold(dir, kind, name string, force bool, out io.Writer) error {
+	generator, ok := scaffoldKinds[kind]
+	if !ok {
+		return fmt.Errorf("unknown scaffold kind %q: must be provider or sink", kind)
+	}
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("reading go.mod (run from the repository root or pass --dir): %w", err)
+	}
+	module, err := scaffold.ModulePath(goMod)
+	if err != nil {
+		return err
+	}
+	files, err := generator.render(name, module)
+	if err != nil {
+		return err
+	}
+
+	var paths []string
+	for path := range files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	if !force {
+		for _, path := range paths {
+			if _, err := os.Stat(filepath.Join(dir, path)); err == nil {
+				return fmt.Errorf("%s already exists; pass --force to overwrite", path)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+		}
+	}
+
+	for _, path := range paths {
+		target := filepath.Join(dir, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return fmt.Errorf("creating directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(target, files[path], 0o644); err != nil {
+			return fmt.Errorf("writing %s: %w", path, err)
+		}
+		if _, err := fmt.Fprintf(out, "wrote %s\n", path); err != nil {
+			return err
+		}
+	}
+
+	_, err = fmt.Fprintf(out, `
+Next steps:
+  1. Add %[1]s to the build constraint of every provider_*.go and sink_*.go
+     in cmd/ttr, and to %[3]s in cmd/ttr/registry.go
+  2. Replace the placeholder requests in %[2]s/%[1]s with the API's,
+     keeping the fake in its tests in step
+  3. Run go test ./%[2]s/%[1]s until the conformance suite passes
+`, name, generator.packages, generator.known)
+	return err
+}
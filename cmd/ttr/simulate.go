@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/analysis"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// maxSimulateLineBytes bounds one NDJSON line
+const maxSimulateLineBytes = 4 << 20
+
+// runSimulate reads runtime_5m documents from NDJSON files, or stdin when no
+// files are given or a file is "-", replays them under the schedule and
+// writes a report to out. Documents of other types are skipped.
+func runSimulate(files []string, stdin io.Reader, out io.Writer, schedule analysis.SimulationSchedule, config analysis.SimulationConfig, asJSON bool) error {
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	var rows []*model.Runtime5m
+	for _, path := range files {
+		fileRows, err := readRuntimeFile(path, stdin)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, fileRows...)
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no runtime_5m documents found")
+	}
+
+	results := analysis.Simulate(rows, schedule, config)
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(results)
+	}
+
+	for _, result := range results {
+		name := result.ThermostatID
+		if result.ThermostatName != "" {
+			name = fmt.Sprintf("%s (%s)", result.ThermostatName, result.ThermostatID)
+		}
+		if _, err := fmt.Fprintf(out, "%s: %s to %s, %d bins\n", name, result.From.Format(time.RFC3339), result.To.Format(time.RFC3339), result.Bins); err != nil {
+			return err
+		}
+		if result.Skipped != "" {
+			if _, err := fmt.Fprintf(out, "  not simulated: %s\n", result.Skipped); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := fmt.Fprintf(out, "  heat: %.1f h actual, %.1f h modeled, %.1f h with schedule (%+.1f kWh)\n",
+			result.ActualHeatHours, result.BaselineHeatHours, result.ScenarioHeatHours, result.HeatKWhChange); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "  cool: %.1f h actual, %.1f h modeled, %.1f h with schedule (%+.1f kWh)\n",
+			result.ActualCoolHours, result.BaselineCoolHours, result.ScenarioCoolHours, result.CoolKWhChange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRuntimeFile decodes the runtime_5m documents in one NDJSON file
+func readRuntimeFile(path string, stdin io.Reader) ([]*model.Runtime5m, error) {
+	r := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		r = file
+	}
+
+	var rows []*model.Runtime5m
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSimulateLineBytes)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var row model.Runtime5m
+		if err := json.Unmarshal(line, &row); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		if row.Type == "runtime_5m" && row.ThermostatID != "" {
+			rows = append(rows, &row)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return rows, nil
+}
+
+// parseSetpointPair parses "HEAT/COOL" in °C, where "-" keeps a setpoint
+func parseSetpointPair(value string) (analysis.SetpointOverride, error) {
+	heat, cool, ok := strings.Cut(value, "/")
+	if !ok {
+		return analysis.SetpointOverride{}, fmt.Errorf("setpoints %q must be HEAT/COOL, with - to keep one", value)
+	}
+	var override analysis.SetpointOverride
+	for _, part := range []struct {
+		text   string
+		target **float64
+	}{{heat, &override.HeatC}, {cool, &override.CoolC}} {
+		if part.text == "-" {
+			continue
+		}
+		c, err := strconv.ParseFloat(part.text, 64)
+		if err != nil {
+			return analysis.SetpointOverride{}, fmt.Errorf("invalid setpoint %q: %w", part.text, err)
+		}
+		*part.target = &c
+	}
+	return override, nil
+}
+
+// parseClimateOverride parses "CLIMATE=HEAT/COOL"
+func parseClimateOverride(value string) (string, analysis.SetpointOverride, error) {
+	climate, setpoints, ok := strings.Cut(value, "=")
+	if !ok || climate == "" {
+		return "", analysis.SetpointOverride{}, fmt.Errorf("climate override %q must be CLIMATE=HEAT/COOL", value)
+	}
+	override, err := parseSetpointPair(setpoints)
+	return climate, override, err
+}
+
+// parseSetpointWindow parses "HH:MM-HH:MM=HEAT/COOL"
+func parseSetpointWindow(value string) (analysis.SetpointWindow, error) {
+	span, setpoints, ok := strings.Cut(value, "=")
+	startText, endText, spanOK := strings.Cut(span, "-")
+	if !ok || !spanOK {
+		return analysis.SetpointWindow{}, fmt.Errorf("window %q must be HH:MM-HH:MM=HEAT/COOL", value)
+	}
+	start, err := time.Parse("15:04", startText)
+	if err != nil {
+		return analysis.SetpointWindow{}, fmt.Errorf("invalid window start %q: %w", startText, err)
+	}
+	end, err := time.Parse("15:04", endText)
+	if err != nil {
+		return analysis.SetpointWindow{}, fmt.Errorf("invalid window end %q: %w", endText, err)
+	}
+	override, err := parseSetpointPair(setpoints)
+	if err != nil {
+		return analysis.SetpointWindow{}, err
+	}
+	sinceMidnight := func(t time.Time) time.Duration {
+		return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	}
+	return analysis.SetpointWindow{Start: sinceMidnight(start), End: sinceMidnight(end), SetpointOverride: override}, nil
+}
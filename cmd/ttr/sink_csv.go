@@ -0,0 +1,37 @@
+//go:build csv || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+
+	csvsink "github.com/benvon/thermostat-telemetry-reader/internal/sinks/csv"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("csv", initializeCSVSink)
+}
+
+// csvSettings are the CSV sink's settings
+type csvSettings struct {
+	Directory string   `settings:"directory"`
+	Columns   []string `settings:"columns"`
+}
+
+// initializeCSVSink initializes the CSV sink
+func initializeCSVSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := csvSettings{Directory: "./data/csv"}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+	if slices.Contains(s.Columns, "") {
+		return nil, fmt.Errorf("csv sink config: invalid columns: entries must not be empty")
+	}
+
+	logger.Info("Initializing CSV sink", "directory", s.Directory, "columns", s.Columns)
+	return csvsink.NewSink(s.Directory, s.Columns), nil
+}
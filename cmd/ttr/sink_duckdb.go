@@ -0,0 +1,44 @@
+//go:build (duckdb || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)) && cgo && !purego
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/duckdb"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("duckdb", initializeDuckDBSink)
+}
+
+// duckDBSettings are the DuckDB sink's settings
+type duckDBSettings struct {
+	Path               string        `settings:"path"`
+	CheckpointInterval time.Duration `settings:"checkpoint_interval"`
+	Rotation           string        `settings:"rotation"`
+}
+
+// initializeDuckDBSink initializes the DuckDB sink
+func initializeDuckDBSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := duckDBSettings{Path: "./data/telemetry.duckdb", CheckpointInterval: 15 * time.Minute, Rotation: duckdb.RotateNone}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+	switch s.Rotation {
+	case duckdb.RotateNone, duckdb.RotateDaily, duckdb.RotateMonthly:
+	default:
+		return nil, fmt.Errorf("duckdb sink config: invalid rotation %q: must be one of: none, daily, monthly", s.Rotation)
+	}
+
+	options := duckdb.Options{CheckpointInterval: s.CheckpointInterval, Rotation: s.Rotation}
+	logger.Info("Initializing DuckDB sink",
+		"path", s.Path,
+		"checkpoint_interval", options.CheckpointInterval,
+		"rotation", options.Rotation)
+	return duckdb.NewSink(s.Path, options), nil
+}
@@ -0,0 +1,21 @@
+//go:build (duckdb || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)) && (purego || !cgo)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("duckdb", initializeDuckDBSink)
+}
+
+// initializeDuckDBSink reports that DuckDB is unavailable: it links a C
+// library, which purego and CGO_ENABLED=0 builds leave out
+func initializeDuckDBSink(_ config.SinkConfig, _ *slog.Logger) (model.Sink, error) {
+	return nil, fmt.Errorf("the duckdb sink needs a cgo build; this binary was built with -tags purego or CGO_ENABLED=0")
+}
@@ -0,0 +1,66 @@
+//go:build elasticsearch || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/elasticsearch"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("elasticsearch", initializeElasticsearchSink)
+}
+
+// elasticsearchSettings are the Elasticsearch sink's settings
+type elasticsearchSettings struct {
+	URL             *url.URL `settings:"url,required"`
+	APIKey          string   `settings:"api_key"`
+	APIKeyFile      string   `settings:"api_key_file"`
+	IndexPrefix     string   `settings:"index_prefix"`
+	IndexName       string   `settings:"index_name"`
+	CreateTemplates bool     `settings:"create_templates"`
+	Pipeline        string   `settings:"pipeline"`
+	Routing         string   `settings:"routing"`
+	Compress        bool     `settings:"compress"`
+}
+
+// initializeElasticsearchSink initializes the Elasticsearch sink
+func initializeElasticsearchSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := elasticsearchSettings{IndexPrefix: "ttr", CreateTemplates: true}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Initializing Elasticsearch sink",
+		"url", s.URL.Redacted(),
+		"index_prefix", s.IndexPrefix,
+		"create_templates", s.CreateTemplates)
+
+	sink := elasticsearch.NewSink(s.URL.String(), s.APIKey, s.IndexPrefix, s.CreateTemplates)
+	sink.UseLogger(logger)
+	if s.IndexName != "" {
+		if err := sink.UseIndexName(s.IndexName); err != nil {
+			return nil, fmt.Errorf("elasticsearch sink: %w", err)
+		}
+	}
+	if s.Pipeline != "" {
+		sink.UseIngestPipeline(s.Pipeline)
+	}
+	if s.Routing != "" {
+		sink.UseRouting(s.Routing)
+	}
+	if s.Compress {
+		sink.UseCompression(true)
+	}
+	if s.APIKeyFile != "" {
+		if err := sink.UseAPIKeyFile(s.APIKeyFile); err != nil {
+			return nil, fmt.Errorf("elasticsearch sink: %w", err)
+		}
+	}
+	return sink, nil
+}
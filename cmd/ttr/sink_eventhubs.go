@@ -0,0 +1,44 @@
+//go:build eventhubs || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/eventhubs"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("eventhubs", initializeEventHubsSink)
+}
+
+// eventHubsSettings are the Azure Event Hubs sink's settings
+type eventHubsSettings struct {
+	ConnectionString string `settings:"connection_string,required"`
+	EventHub         string `settings:"event_hub"`
+}
+
+// initializeEventHubsSink initializes the Azure Event Hubs sink
+func initializeEventHubsSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	var s eventHubsSettings
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+	connection, err := eventhubs.ParseConnectionString(s.ConnectionString)
+	if err != nil {
+		return nil, fmt.Errorf("eventhubs sink config: invalid connection_string: %w", err)
+	}
+
+	sink := eventhubs.NewSink(connection, s.EventHub)
+	eventHub := s.EventHub
+	if eventHub == "" {
+		eventHub = connection.EventHub
+	}
+	logger.Info("Initializing Event Hubs sink",
+		"endpoint", connection.Endpoint,
+		"event_hub", eventHub)
+	return sink, nil
+}
@@ -0,0 +1,36 @@
+//go:build homeassistant || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"log/slog"
+	"net/url"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/homeassistant"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("homeassistant", initializeHomeAssistantSink)
+}
+
+// homeAssistantSettings are the Home Assistant sink's settings
+type homeAssistantSettings struct {
+	URL           *url.URL `settings:"url,required"`
+	Token         string   `settings:"token,required"`
+	EntityPattern string   `settings:"entity_pattern"`
+}
+
+// initializeHomeAssistantSink initializes the Home Assistant REST sink
+func initializeHomeAssistantSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := homeAssistantSettings{EntityPattern: homeassistant.DefaultEntityPattern}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+
+	logger.Info("Initializing Home Assistant sink",
+		"url", s.URL.Redacted(),
+		"entity_pattern", s.EntityPattern)
+	return homeassistant.NewSink(s.URL.String(), s.Token, s.EntityPattern), nil
+}
@@ -0,0 +1,63 @@
+//go:build kinesis || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/kinesis"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("kinesis", initializeKinesisSink)
+}
+
+// kinesisSettings are the AWS Kinesis sink's settings. Region and access
+// keys default to the standard AWS environment variables.
+type kinesisSettings struct {
+	Stream          string `settings:"stream,required"`
+	Region          string `settings:"region"`
+	Endpoint        string `settings:"endpoint"`
+	AccessKeyID     string `settings:"access_key_id"`
+	SecretAccessKey string `settings:"secret_access_key"`
+	SessionToken    string `settings:"session_token"`
+}
+
+// initializeKinesisSink initializes the AWS Kinesis sink. Access keys come
+// from the sink settings or the standard AWS environment variables.
+func initializeKinesisSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := kinesisSettings{
+		Region:          os.Getenv("AWS_REGION"),
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+	if s.Region == "" {
+		return nil, fmt.Errorf("kinesis sink config: missing region")
+	}
+	if s.AccessKeyID == "" || s.SecretAccessKey == "" {
+		return nil, fmt.Errorf("kinesis sink needs access_key_id and secret_access_key, in its settings or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+
+	logger.Info("Initializing Kinesis sink",
+		"stream", s.Stream,
+		"region", s.Region,
+		"endpoint", s.Endpoint)
+	return kinesis.NewSink(kinesis.Options{
+		Stream:   s.Stream,
+		Region:   s.Region,
+		Endpoint: s.Endpoint,
+		Credentials: kinesis.Credentials{
+			AccessKeyID:     s.AccessKeyID,
+			SecretAccessKey: s.SecretAccessKey,
+			SessionToken:    s.SessionToken,
+		},
+	}), nil
+}
@@ -0,0 +1,59 @@
+//go:build nats || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	natssink "github.com/benvon/thermostat-telemetry-reader/internal/sinks/nats"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("nats", initializeNATSSink)
+}
+
+// natsSettings are the NATS JetStream sink's settings
+type natsSettings struct {
+	URL             string        `settings:"url"`
+	Stream          string        `settings:"stream"`
+	SubjectPrefix   string        `settings:"subject_prefix"`
+	MaxAge          time.Duration `settings:"max_age"`
+	DuplicateWindow time.Duration `settings:"duplicate_window"`
+	Embedded        bool          `settings:"embedded"`
+	StoreDir        string        `settings:"store_dir"`
+	Listen          string        `settings:"listen"`
+}
+
+// initializeNATSSink initializes the NATS JetStream sink
+func initializeNATSSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := natsSettings{StoreDir: "./data/nats"}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+
+	options := natssink.Options{
+		URL:             s.URL,
+		Stream:          s.Stream,
+		SubjectPrefix:   s.SubjectPrefix,
+		MaxAge:          s.MaxAge,
+		DuplicateWindow: s.DuplicateWindow,
+	}
+	if s.Embedded {
+		options.Embedded = &natssink.EmbeddedOptions{StoreDir: s.StoreDir, Listen: s.Listen}
+	} else if options.URL == "" {
+		return nil, fmt.Errorf("nats sink config needs a url or embedded: true")
+	}
+
+	sink := natssink.NewSink(options)
+	logger.Info("Initializing NATS sink",
+		"url", options.URL,
+		"embedded", options.Embedded != nil,
+		"stream", options.Stream,
+		"subject_prefix", options.SubjectPrefix,
+		"max_age", options.MaxAge)
+	return sink, nil
+}
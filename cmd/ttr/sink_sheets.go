@@ -0,0 +1,46 @@
+//go:build sheets || !(ecobee || nest || venstar || tado || elasticsearch || duckdb || csv || sheets || nats || kinesis || eventhubs || homeassistant)
+
+package main
+
+import (
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/sinks/sheets"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/config"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func init() {
+	registerSink("sheets", initializeSheetsSink)
+}
+
+// sheetsSettings are the Google Sheets sink's settings
+type sheetsSettings struct {
+	CredentialsFile string `settings:"credentials_file,required"`
+	SpreadsheetID   string `settings:"spreadsheet_id,required"`
+	Sheet           string `settings:"sheet"`
+	Mode            string `settings:"mode"`
+	Units           string `settings:"units"`
+	DateFormat      string `settings:"date_format"`
+}
+
+// initializeSheetsSink initializes the Google Sheets sink
+func initializeSheetsSink(sinkConfig config.SinkConfig, logger *slog.Logger) (model.Sink, error) {
+	s := sheetsSettings{Sheet: "Sheet1", Mode: sheets.ModeDaily}
+	if err := decodeSinkSettings(sinkConfig, &s); err != nil {
+		return nil, err
+	}
+
+	account, err := sheets.LoadServiceAccount(s.CredentialsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Initializing Google Sheets sink",
+		"spreadsheet_id", s.SpreadsheetID,
+		"sheet", s.Sheet,
+		"mode", s.Mode,
+		"service_account", account.ClientEmail)
+
+	return sheets.NewSink(account, s.SpreadsheetID, s.Sheet, s.Mode, sheets.Locale{Units: s.Units, DateFormat: s.DateFormat})
+}
@@ -0,0 +1,262 @@
+package analysis
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+const (
+	// ScheduleAdherenceAnalyzerName identifies schedule adherence analysis documents
+	ScheduleAdherenceAnalyzerName = "schedule_adherence"
+
+	// holdRetention is how long an ended hold is remembered so late runtime
+	// rows from while it was active are still attributed to it
+	holdRetention = 24 * time.Hour
+)
+
+// ScheduleAdherenceConfig controls hold and schedule adherence analysis
+type ScheduleAdherenceConfig struct {
+	// Period is the length of each analysis document's window; whole days
+	// follow each thermostat's local calendar, and weeks start on Monday
+	Period time.Duration
+	// Location is the timezone for thermostats that report none; nil means UTC
+	Location *time.Location
+}
+
+// DefaultScheduleAdherenceConfig returns weekly analysis
+func DefaultScheduleAdherenceConfig() ScheduleAdherenceConfig {
+	return ScheduleAdherenceConfig{Period: 7 * 24 * time.Hour}
+}
+
+// ScheduleAdherenceAnalyzer measures how long manual holds last and what share
+// of time a thermostat follows its programmed schedule.
+//
+// Holds are learned from snapshot events. A hold that disappears from a
+// snapshot before its scheduled end was cancelled and is treated as ending
+// then. Runtime bins covered by a hold count as off-schedule; adherence is the
+// share of observed bins that were not. Completed holds are counted in the
+// period in which they end.
+type ScheduleAdherenceAnalyzer struct {
+	config      ScheduleAdherenceConfig
+	mu          sync.Mutex
+	thermostats map[string]*adherenceState
+	zones       thermostatZones
+}
+
+// adherenceState tracks one thermostat's holds and periods
+type adherenceState struct {
+	name          string
+	householdID   string
+	location      *time.Location
+	lastEventTime time.Time
+	holds         map[string]*trackedHold
+	periods       map[time.Time]*adherencePeriod
+}
+
+// trackedHold is a hold seen in a snapshot. A zero end means indefinite.
+type trackedHold struct {
+	start    time.Time
+	end      time.Time
+	recorded bool
+}
+
+// adherencePeriod accumulates counts for one analysis window
+type adherencePeriod struct {
+	end          time.Time
+	bins         int
+	holdBins     int
+	holds        int
+	holdDuration time.Duration
+	longestHold  time.Duration
+}
+
+// NewScheduleAdherenceAnalyzer creates a schedule adherence analyzer
+func NewScheduleAdherenceAnalyzer(config ScheduleAdherenceConfig) *ScheduleAdherenceAnalyzer {
+	return &ScheduleAdherenceAnalyzer{
+		config:      config,
+		thermostats: make(map[string]*adherenceState),
+		zones:       newThermostatZones(config.Location),
+	}
+}
+
+// Name identifies the analyzer
+func (a *ScheduleAdherenceAnalyzer) Name() string {
+	return ScheduleAdherenceAnalyzerName
+}
+
+// ObserveTimezone sets the timezone a thermostat's periods follow. Periods
+// already open keep the bounds they were created with.
+func (a *ScheduleAdherenceAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.zones.set(thermostatID, loc)
+	if state, ok := a.thermostats[thermostatID]; ok {
+		state.location = loc
+	}
+}
+
+// ObserveEvents updates the tracked holds from a snapshot's events
+func (a *ScheduleAdherenceAnalyzer) ObserveEvents(thermostatID string, events []model.Event, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := a.stateFor(thermostatID)
+	seen := make(map[string]bool)
+	for _, event := range events {
+		if event.Kind != "hold" || event.Start.IsZero() {
+			continue
+		}
+		key := event.Name + "|" + event.Start.Format(time.RFC3339)
+		seen[key] = true
+
+		hold, ok := state.holds[key]
+		if !ok {
+			if !event.Running {
+				continue
+			}
+			hold = &trackedHold{start: event.Start}
+			state.holds[key] = hold
+		}
+		if !hold.recorded {
+			hold.end = event.End
+		}
+	}
+
+	for key, hold := range state.holds {
+		// A hold missing from the snapshot before its end was cancelled
+		if !seen[key] && (hold.end.IsZero() || hold.end.After(now)) {
+			hold.end = now
+		}
+		if !hold.recorded && !hold.end.IsZero() && !hold.end.After(now) {
+			a.recordHold(state, hold)
+		}
+		if hold.recorded && hold.end.Before(now.Add(-holdRetention)) {
+			delete(state.holds, key)
+		}
+	}
+}
+
+// Observe records a runtime row. Rows at or before the last seen bin for a
+// thermostat are ignored so overlapping provider fetches are not double counted.
+func (a *ScheduleAdherenceAnalyzer) Observe(row *model.Runtime5m) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := a.stateFor(row.ThermostatID)
+	state.name = row.ThermostatName
+	state.householdID = row.HouseholdID
+	if !row.EventTime.After(state.lastEventTime) {
+		return
+	}
+	state.lastEventTime = row.EventTime
+
+	period := a.periodFor(state, row.EventTime)
+	period.bins++
+	for _, hold := range state.holds {
+		if hold.covers(row.EventTime) {
+			period.holdBins++
+			break
+		}
+	}
+}
+
+// Flush emits analysis documents for periods that ended at least flushGrace before now
+func (a *ScheduleAdherenceAnalyzer) Flush(now time.Time) []*model.Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-flushGrace)
+	var results []*model.Analysis
+
+	for thermostatID, state := range a.thermostats {
+		for start, period := range state.periods {
+			if period.end.After(cutoff) {
+				continue
+			}
+			results = append(results, a.buildAnalysis(thermostatID, state, start, period.end, period))
+			delete(state.periods, start)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ThermostatID != results[j].ThermostatID {
+			return results[i].ThermostatID < results[j].ThermostatID
+		}
+		return results[i].PeriodStart.Before(results[j].PeriodStart)
+	})
+
+	return results
+}
+
+// stateFor returns the tracking state for a thermostat, creating it if needed
+func (a *ScheduleAdherenceAnalyzer) stateFor(thermostatID string) *adherenceState {
+	state, ok := a.thermostats[thermostatID]
+	if !ok {
+		state = &adherenceState{
+			location: a.zones.get(thermostatID),
+			holds:    make(map[string]*trackedHold),
+			periods:  make(map[time.Time]*adherencePeriod),
+		}
+		a.thermostats[thermostatID] = state
+	}
+	return state
+}
+
+// periodFor returns the accumulator for the period containing t
+func (a *ScheduleAdherenceAnalyzer) periodFor(state *adherenceState, t time.Time) *adherencePeriod {
+	start, end := periodWindow(t, a.config.Period, state.location)
+	period, ok := state.periods[start]
+	if !ok {
+		period = &adherencePeriod{end: end}
+		state.periods[start] = period
+	}
+	return period
+}
+
+// recordHold counts a completed hold in the period it ended
+func (a *ScheduleAdherenceAnalyzer) recordHold(state *adherenceState, hold *trackedHold) {
+	duration := hold.end.Sub(hold.start)
+	period := a.periodFor(state, hold.end)
+	period.holds++
+	period.holdDuration += duration
+	if duration > period.longestHold {
+		period.longestHold = duration
+	}
+	hold.recorded = true
+}
+
+// covers reports whether the hold was active at t
+func (h *trackedHold) covers(t time.Time) bool {
+	return !t.Before(h.start) && (h.end.IsZero() || t.Before(h.end))
+}
+
+// buildAnalysis converts a finished period into an analysis document
+func (a *ScheduleAdherenceAnalyzer) buildAnalysis(thermostatID string, state *adherenceState, start, end time.Time, period *adherencePeriod) *model.Analysis {
+	results := map[string]any{
+		"observed_minutes":   period.bins * int(binSize/time.Minute),
+		"hold_minutes":       period.holdBins * int(binSize/time.Minute),
+		"holds_completed":    period.holds,
+		"longest_hold_hours": period.longestHold.Hours(),
+	}
+	if period.bins > 0 {
+		results["schedule_adherence"] = 1 - float64(period.holdBins)/float64(period.bins)
+	}
+	if period.holds > 0 {
+		results["avg_hold_hours"] = (period.holdDuration / time.Duration(period.holds)).Hours()
+	}
+
+	return &model.Analysis{
+		Type:           "analysis",
+		Analyzer:       ScheduleAdherenceAnalyzerName,
+		ThermostatID:   thermostatID,
+		ThermostatName: state.name,
+		HouseholdID:    state.householdID,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		Results:        results,
+	}
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestScheduleAdherenceAnalyzer(t *testing.T) {
+	week := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC) // a Monday
+	holdStart := week.Add(34 * time.Hour)               // Tuesday 10:00
+	hold := model.Event{
+		Kind:    "hold",
+		Name:    "manual",
+		Running: true,
+		Start:   holdStart,
+		End:     time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	analyzer := NewScheduleAdherenceAnalyzer(DefaultScheduleAdherenceConfig())
+	analyzer.ObserveEvents("t1", []model.Event{hold}, holdStart.Add(5*time.Minute))
+	for i := range 36 {
+		analyzer.Observe(heatRow(holdStart.Add(time.Duration(i)*binSize), 5))
+		if i == 23 {
+			// The hold is gone from the next snapshot, so it was cancelled
+			analyzer.ObserveEvents("t1", nil, holdStart.Add(2*time.Hour))
+		}
+	}
+
+	if results := analyzer.Flush(week.Add(7 * 24 * time.Hour)); len(results) != 0 {
+		t.Fatalf("Expected no analysis before grace period, got %d", len(results))
+	}
+
+	results := analyzer.Flush(week.Add(7*24*time.Hour + 2*time.Hour))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 analysis, got %d", len(results))
+	}
+	analysis := results[0]
+
+	if analysis.Analyzer != ScheduleAdherenceAnalyzerName || !analysis.PeriodStart.Equal(week) {
+		t.Errorf("Unexpected analysis %s for period starting %v", analysis.Analyzer, analysis.PeriodStart)
+	}
+
+	expected := map[string]any{
+		"observed_minutes":   180,
+		"hold_minutes":       120,
+		"holds_completed":    1,
+		"longest_hold_hours": 2.0,
+		"avg_hold_hours":     2.0,
+	}
+	for key, want := range expected {
+		if got := analysis.Results[key]; got != want {
+			t.Errorf("Expected %s %v, got %v", key, want, got)
+		}
+	}
+	if got := analysis.Results["schedule_adherence"].(float64); got < 0.33 || got > 0.34 {
+		t.Errorf("Expected schedule_adherence of one third, got %v", got)
+	}
+}
+
+func TestScheduleAdherenceIgnoresOtherEvents(t *testing.T) {
+	week := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	analyzer := NewScheduleAdherenceAnalyzer(DefaultScheduleAdherenceConfig())
+
+	analyzer.ObserveEvents("t1", []model.Event{
+		{Kind: "vacation", Running: true, Start: week},
+		{Kind: "hold", Running: false, Start: week},
+	}, week.Add(time.Hour))
+	analyzer.Observe(heatRow(week.Add(2*time.Hour), 5))
+
+	results := analyzer.Flush(week.Add(8 * 24 * time.Hour))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 analysis, got %d", len(results))
+	}
+	if got := results[0].Results["schedule_adherence"]; got != 1.0 {
+		t.Errorf("Expected full adherence, got %v", got)
+	}
+}
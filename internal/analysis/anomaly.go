@@ -0,0 +1,166 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// SensorAnomalyConfig tunes sensor fault detection
+type SensorAnomalyConfig struct {
+	// StuckDuration is how long a sensor must report the same value before it
+	// is considered stuck
+	StuckDuration time.Duration
+	// MaxJumpC is the largest plausible change between consecutive 5-minute bins
+	MaxJumpC float64
+	// DivergenceC is the gap from the thermostat's own reading beyond which a
+	// sensor is considered to diverge
+	DivergenceC float64
+	// DivergenceDuration is how long the gap must last before it is reported,
+	// so a sensor in a briefly opened room is not flagged
+	DivergenceDuration time.Duration
+}
+
+// DefaultSensorAnomalyConfig returns thresholds that tolerate normal household variation
+func DefaultSensorAnomalyConfig() SensorAnomalyConfig {
+	return SensorAnomalyConfig{
+		StuckDuration:      6 * time.Hour,
+		MaxJumpC:           5.0,
+		DivergenceC:        5.0,
+		DivergenceDuration: time.Hour,
+	}
+}
+
+// stuckTolerance is the change below which consecutive readings count as identical
+const stuckTolerance = 0.01
+
+// SensorAnomalyDetector flags remote sensors that report constant values for
+// long periods, jump implausibly between bins, or diverge from the thermostat's
+// own reading. Stuck and divergence alerts are raised once when the condition
+// starts and again only after it has cleared; every jump is reported.
+type SensorAnomalyDetector struct {
+	config      SensorAnomalyConfig
+	mu          sync.Mutex
+	thermostats map[string]*anomalyState
+}
+
+// anomalyState tracks one thermostat's sensors
+type anomalyState struct {
+	lastEventTime time.Time
+	sensors       map[string]*sensorState
+}
+
+// sensorState tracks one sensor's recent readings
+type sensorState struct {
+	last           float64
+	lastTime       time.Time
+	unchangedSince time.Time
+	stuckAlerted   bool
+	divergingSince time.Time
+	divergeAlerted bool
+}
+
+// NewSensorAnomalyDetector creates a sensor anomaly detector
+func NewSensorAnomalyDetector(config SensorAnomalyConfig) *SensorAnomalyDetector {
+	return &SensorAnomalyDetector{
+		config:      config,
+		thermostats: make(map[string]*anomalyState),
+	}
+}
+
+// Observe checks a runtime row's sensor readings and returns any new alerts.
+// Rows at or before the last seen bin for a thermostat are ignored so
+// overlapping provider fetches do not repeat alerts.
+func (d *SensorAnomalyDetector) Observe(row *model.Runtime5m) []*model.Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.thermostats[row.ThermostatID]
+	if !ok {
+		state = &anomalyState{sensors: make(map[string]*sensorState)}
+		d.thermostats[row.ThermostatID] = state
+	}
+	if !row.EventTime.After(state.lastEventTime) {
+		return nil
+	}
+	state.lastEventTime = row.EventTime
+
+	sensorIDs := make([]string, 0, len(row.Sensors))
+	for id := range row.Sensors {
+		sensorIDs = append(sensorIDs, id)
+	}
+	sort.Strings(sensorIDs)
+
+	var alerts []*model.Alert
+	for _, id := range sensorIDs {
+		value := row.Sensors[id]
+		sensor, ok := state.sensors[id]
+		if !ok {
+			state.sensors[id] = &sensorState{last: value, lastTime: row.EventTime, unchangedSince: row.EventTime}
+			continue
+		}
+		alerts = append(alerts, d.check(row, id, sensor, value)...)
+	}
+	return alerts
+}
+
+// check updates a sensor's state with a new reading and returns alerts it triggers
+func (d *SensorAnomalyDetector) check(row *model.Runtime5m, id string, sensor *sensorState, value float64) []*model.Alert {
+	var alerts []*model.Alert
+	newAlert := func(kind, message string, details map[string]any) {
+		alerts = append(alerts, &model.Alert{
+			Type:           "alert",
+			Kind:           kind,
+			EventTime:      row.EventTime,
+			ThermostatID:   row.ThermostatID,
+			ThermostatName: row.ThermostatName,
+			HouseholdID:    row.HouseholdID,
+			SensorID:       id,
+			ValueC:         value,
+			Message:        message,
+			Details:        details,
+		})
+	}
+
+	// Jumps are only meaningful between adjacent bins; gaps in the data can
+	// hide a gradual change
+	change := math.Abs(value - sensor.last)
+	if row.EventTime.Sub(sensor.lastTime) <= 3*binSize && change > d.config.MaxJumpC {
+		newAlert(model.AlertKindJump,
+			fmt.Sprintf("Sensor %s changed %.1f°C in one interval", id, change),
+			map[string]any{"previous_c": sensor.last, "change_c": change})
+	}
+
+	if change >= stuckTolerance {
+		sensor.unchangedSince = row.EventTime
+		sensor.stuckAlerted = false
+	} else if stuckFor := row.EventTime.Sub(sensor.unchangedSince); stuckFor >= d.config.StuckDuration && !sensor.stuckAlerted {
+		sensor.stuckAlerted = true
+		newAlert(model.AlertKindStuck,
+			fmt.Sprintf("Sensor %s has reported %.1f°C for %s", id, value, stuckFor),
+			map[string]any{"unchanged_since": sensor.unchangedSince, "unchanged_hours": stuckFor.Hours()})
+	}
+
+	if row.AvgTempC != nil && math.Abs(value-*row.AvgTempC) > d.config.DivergenceC {
+		if sensor.divergingSince.IsZero() {
+			sensor.divergingSince = row.EventTime
+		}
+		if row.EventTime.Sub(sensor.divergingSince) >= d.config.DivergenceDuration && !sensor.divergeAlerted {
+			sensor.divergeAlerted = true
+			newAlert(model.AlertKindDivergence,
+				fmt.Sprintf("Sensor %s differs from the thermostat reading by %.1f°C", id, math.Abs(value-*row.AvgTempC)),
+				map[string]any{"thermostat_c": *row.AvgTempC, "diverging_since": sensor.divergingSince})
+		}
+	} else {
+		sensor.divergingSince = time.Time{}
+		sensor.divergeAlerted = false
+	}
+
+	sensor.last = value
+	sensor.lastTime = row.EventTime
+	return alerts
+}
@@ -0,0 +1,113 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func sensorRow(at time.Time, thermostatC float64, sensors map[string]float64) *model.Runtime5m {
+	return &model.Runtime5m{
+		Type:         "runtime_5m",
+		ThermostatID: "t1",
+		EventTime:    at,
+		AvgTempC:     &thermostatC,
+		Sensors:      sensors,
+	}
+}
+
+func TestSensorAnomalyDetector(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		rows  func() []*model.Runtime5m
+		kinds []string
+	}{
+		{
+			name: "steady varying sensor raises nothing",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 100 {
+					rows = append(rows, sensorRow(start.Add(time.Duration(i)*binSize), 21, map[string]float64{"rs1": 20 + float64(i%3)*0.1}))
+				}
+				return rows
+			},
+		},
+		{
+			name: "constant reading is stuck once",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 100 { // a little over 8 hours
+					rows = append(rows, sensorRow(start.Add(time.Duration(i)*binSize), 21, map[string]float64{"rs1": 20.5}))
+				}
+				return rows
+			},
+			kinds: []string{model.AlertKindStuck},
+		},
+		{
+			name: "implausible jump between bins",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					sensorRow(start, 21, map[string]float64{"rs1": 20}),
+					sensorRow(start.Add(binSize), 21, map[string]float64{"rs1": 27}),
+				}
+			},
+			kinds: []string{model.AlertKindJump},
+		},
+		{
+			name: "jump across a data gap is ignored",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					sensorRow(start, 21, map[string]float64{"rs1": 20}),
+					sensorRow(start.Add(2*time.Hour), 21, map[string]float64{"rs1": 24.5}),
+				}
+			},
+		},
+		{
+			name: "sustained divergence from thermostat",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 20 {
+					rows = append(rows, sensorRow(start.Add(time.Duration(i)*binSize), 21, map[string]float64{"rs1": 14 + float64(i%2)*0.2}))
+				}
+				return rows
+			},
+			kinds: []string{model.AlertKindDivergence},
+		},
+		{
+			name: "duplicate rows are ignored",
+			rows: func() []*model.Runtime5m {
+				row := sensorRow(start.Add(binSize), 21, map[string]float64{"rs1": 27})
+				return []*model.Runtime5m{sensorRow(start, 21, map[string]float64{"rs1": 20}), row, row}
+			},
+			kinds: []string{model.AlertKindJump},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewSensorAnomalyDetector(DefaultSensorAnomalyConfig())
+
+			var kinds []string
+			for _, row := range tt.rows() {
+				for _, alert := range detector.Observe(row) {
+					if alert.SensorID != "rs1" || alert.ThermostatID != "t1" {
+						t.Errorf("Unexpected alert target %s/%s", alert.ThermostatID, alert.SensorID)
+					}
+					kinds = append(kinds, alert.Kind)
+				}
+			}
+
+			if len(kinds) != len(tt.kinds) {
+				t.Fatalf("Expected alerts %v, got %v", tt.kinds, kinds)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.kinds[i] {
+					t.Errorf("Expected alerts %v, got %v", tt.kinds, kinds)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package analysis
+
+import (
+	"time"
+)
+
+// calendarDay is the nominal length of a day; periods that are whole
+// multiples of it follow the local calendar
+const calendarDay = 24 * time.Hour
+
+// calendarEpoch is a Monday, so weekly periods start on Mondays
+var calendarEpoch = time.Date(1970, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// periodWindow returns the analysis window of length period containing t.
+// Periods of whole days follow the calendar in loc: they start at local
+// midnight (weekly ones on Monday) and last 23 or 25 hours across a daylight
+// saving change. Other periods are fixed-length windows aligned to UTC.
+func periodWindow(t time.Time, period time.Duration, loc *time.Location) (start, end time.Time) {
+	if period%calendarDay != 0 {
+		start = t.UTC().Truncate(period)
+		return start, start.Add(period)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	days := int(period / calendarDay)
+	year, month, date := t.In(loc).Date()
+	index := int(time.Date(year, month, date, 0, 0, 0, 0, time.UTC).Sub(calendarEpoch) / calendarDay)
+	first := index - ((index%days)+days)%days
+
+	start = time.Date(calendarEpoch.Year(), calendarEpoch.Month(), calendarEpoch.Day()+first, 0, 0, 0, 0, loc)
+	end = time.Date(calendarEpoch.Year(), calendarEpoch.Month(), calendarEpoch.Day()+first+days, 0, 0, 0, 0, loc)
+	return start.UTC(), end.UTC()
+}
+
+// thermostatZones remembers the timezone each thermostat's calendar periods
+// follow, falling back to a default for thermostats that report none
+type thermostatZones struct {
+	fallback *time.Location
+	zones    map[string]*time.Location
+}
+
+func newThermostatZones(fallback *time.Location) thermostatZones {
+	if fallback == nil {
+		fallback = time.UTC
+	}
+	return thermostatZones{fallback: fallback, zones: make(map[string]*time.Location)}
+}
+
+// set records a thermostat's timezone
+func (z thermostatZones) set(thermostatID string, loc *time.Location) {
+	z.zones[thermostatID] = loc
+}
+
+// get returns a thermostat's timezone, or the fallback
+func (z thermostatZones) get(thermostatID string) *time.Location {
+	if loc, ok := z.zones[thermostatID]; ok {
+		return loc
+	}
+	return z.fallback
+}
@@ -0,0 +1,111 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodWindow(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		t         time.Time
+		period    time.Duration
+		loc       *time.Location
+		wantStart time.Time
+		wantHours float64
+	}{
+		{
+			name:      "UTC day",
+			t:         time.Date(2025, 1, 10, 23, 55, 0, 0, time.UTC),
+			period:    24 * time.Hour,
+			wantStart: time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+			wantHours: 24,
+		},
+		{
+			name:      "local day rolls over at local midnight",
+			t:         time.Date(2025, 1, 11, 3, 0, 0, 0, time.UTC), // 22:00 on the 10th in New York
+			period:    24 * time.Hour,
+			loc:       newYork,
+			wantStart: time.Date(2025, 1, 10, 0, 0, 0, 0, newYork),
+			wantHours: 24,
+		},
+		{
+			name:      "spring forward day is 23 hours",
+			t:         time.Date(2025, 3, 9, 12, 0, 0, 0, newYork),
+			period:    24 * time.Hour,
+			loc:       newYork,
+			wantStart: time.Date(2025, 3, 9, 0, 0, 0, 0, newYork),
+			wantHours: 23,
+		},
+		{
+			name:      "fall back day is 25 hours",
+			t:         time.Date(2025, 11, 2, 1, 30, 0, 0, newYork).Add(time.Hour), // second 01:30
+			period:    24 * time.Hour,
+			loc:       newYork,
+			wantStart: time.Date(2025, 11, 2, 0, 0, 0, 0, newYork),
+			wantHours: 25,
+		},
+		{
+			name:      "week starts on local Monday across a change",
+			t:         time.Date(2025, 3, 30, 23, 30, 0, 0, london), // Sunday, clocks went forward that morning
+			period:    7 * 24 * time.Hour,
+			loc:       london,
+			wantStart: time.Date(2025, 3, 24, 0, 0, 0, 0, london),
+			wantHours: 7*24 - 1,
+		},
+		{
+			name:      "sub-day periods stay aligned to UTC",
+			t:         time.Date(2025, 3, 9, 7, 30, 0, 0, time.UTC),
+			period:    6 * time.Hour,
+			loc:       newYork,
+			wantStart: time.Date(2025, 3, 9, 6, 0, 0, 0, time.UTC),
+			wantHours: 6,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end := periodWindow(tt.t, tt.period, tt.loc)
+			if !start.Equal(tt.wantStart) {
+				t.Errorf("Expected start %v, got %v", tt.wantStart, start)
+			}
+			if hours := end.Sub(start).Hours(); hours != tt.wantHours {
+				t.Errorf("Expected a %g-hour period, got %g", tt.wantHours, hours)
+			}
+			if tt.t.Before(start) || !tt.t.Before(end) {
+				t.Errorf("Expected %v within [%v, %v)", tt.t, start, end)
+			}
+		})
+	}
+}
+
+func TestHeatPumpAnalyzerLocalDays(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("Failed to load timezone: %v", err)
+	}
+	analyzer := NewHeatPumpAnalyzer(DefaultHeatPumpConfig())
+	analyzer.ObserveTimezone("t1", newYork)
+
+	// 23:00 and 01:00 local fall on different local days
+	lateEvening := time.Date(2025, 3, 8, 23, 0, 0, 0, newYork)
+	analyzer.Observe(heatRow(lateEvening, 2, "compHeat1"))
+	analyzer.Observe(heatRow(lateEvening.Add(2*time.Hour), 2, "compHeat1"))
+
+	results := analyzer.Flush(time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC))
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 daily documents, got %d", len(results))
+	}
+	wantStart := time.Date(2025, 3, 9, 0, 0, 0, 0, newYork)
+	if second := results[1]; !second.PeriodStart.Equal(wantStart) || second.PeriodEnd.Sub(second.PeriodStart) != 23*time.Hour {
+		t.Errorf("Expected the spring forward day from %v lasting 23h, got %v to %v", wantStart, second.PeriodStart, second.PeriodEnd)
+	}
+}
@@ -0,0 +1,270 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ConflictConfig tunes detection of thermostats working against each other or
+// against the weather
+type ConflictConfig struct {
+	// SimultaneousDuration is how long zones of one household must heat and
+	// cool at the same time before it is reported
+	SimultaneousDuration time.Duration
+	// ModeDuration is how long a thermostat's mode must conflict with the
+	// outdoor temperature before it is reported
+	ModeDuration time.Duration
+	// HeatMaxOutdoorC is the outdoor temperature at or above which heat mode
+	// conflicts with the weather
+	HeatMaxOutdoorC float64
+	// CoolMinOutdoorC is the outdoor temperature at or below which cool mode
+	// conflicts with the weather
+	CoolMinOutdoorC float64
+}
+
+// DefaultConflictConfig returns thresholds that ignore brief overlaps and
+// shoulder-season weather
+func DefaultConflictConfig() ConflictConfig {
+	return ConflictConfig{
+		SimultaneousDuration: 30 * time.Minute,
+		ModeDuration:         3 * time.Hour,
+		HeatMaxOutdoorC:      24,
+		CoolMinOutdoorC:      10,
+	}
+}
+
+// conflictRetention is how long a household's bins are kept for matching
+// rows from thermostats that report late
+const conflictRetention = 24 * time.Hour
+
+// ConflictDetector raises alerts when zones of one household heat and cool
+// at the same time, and when a thermostat stays in heat mode on a warm day or
+// cool mode on a cold one. Like sensor divergence, each conflict is raised
+// once when it has lasted long enough and again only after it has cleared.
+//
+// Zones of a household are matched by 5-minute bin, so thermostats whose rows
+// arrive in different polls are still compared. Household alerts carry the
+// household ID and no thermostat ID; the zones involved are in the details.
+type ConflictDetector struct {
+	config      ConflictConfig
+	mu          sync.Mutex
+	thermostats map[string]*modeConflictState
+	households  map[string]*householdConflicts
+}
+
+// modeConflictState tracks one thermostat's mode against the weather
+type modeConflictState struct {
+	lastEventTime time.Time
+	since         time.Time
+	alerted       bool
+}
+
+// householdConflicts tracks which zones of a household heated and cooled in
+// recent bins
+type householdConflicts struct {
+	names  map[string]string
+	bins   map[time.Time]*conflictBin
+	latest time.Time
+}
+
+// conflictBin is a household bin that may already have been reported
+type conflictBin struct {
+	householdBin
+	alerted bool
+}
+
+// NewConflictDetector creates a conflict detector
+func NewConflictDetector(config ConflictConfig) *ConflictDetector {
+	return &ConflictDetector{
+		config:      config,
+		thermostats: make(map[string]*modeConflictState),
+		households:  make(map[string]*householdConflicts),
+	}
+}
+
+// Observe checks a runtime row for conflicts and returns any new alerts. Rows
+// at or before the last seen bin for a thermostat are ignored so overlapping
+// provider fetches do not repeat alerts.
+func (d *ConflictDetector) Observe(row *model.Runtime5m) []*model.Alert {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.thermostats[row.ThermostatID]
+	if !ok {
+		state = &modeConflictState{}
+		d.thermostats[row.ThermostatID] = state
+	}
+	if !row.EventTime.After(state.lastEventTime) {
+		return nil
+	}
+	state.lastEventTime = row.EventTime
+
+	var alerts []*model.Alert
+	if alert := d.checkMode(row, state); alert != nil {
+		alerts = append(alerts, alert)
+	}
+	if alert := d.checkSimultaneous(row); alert != nil {
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// checkMode updates a thermostat's mode conflict and returns an alert once it
+// has lasted ModeDuration
+func (d *ConflictDetector) checkMode(row *model.Runtime5m, state *modeConflictState) *model.Alert {
+	conflict := row.OutdoorTempC != nil &&
+		(row.Mode == "heat" && *row.OutdoorTempC >= d.config.HeatMaxOutdoorC ||
+			row.Mode == "cool" && *row.OutdoorTempC <= d.config.CoolMinOutdoorC)
+	if !conflict {
+		state.since = time.Time{}
+		state.alerted = false
+		return nil
+	}
+	if state.since.IsZero() {
+		state.since = row.EventTime
+	}
+	lasted := row.EventTime.Sub(state.since)
+	if lasted < d.config.ModeDuration || state.alerted {
+		return nil
+	}
+	state.alerted = true
+
+	outdoor := *row.OutdoorTempC
+	return &model.Alert{
+		Type:           "alert",
+		Kind:           model.AlertKindModeConflict,
+		EventTime:      row.EventTime,
+		ThermostatID:   row.ThermostatID,
+		ThermostatName: row.ThermostatName,
+		HouseholdID:    row.HouseholdID,
+		ValueC:         outdoor,
+		Message:        fmt.Sprintf("Thermostat has been in %s mode for %s while it is %.1f°C outside", row.Mode, lasted, outdoor),
+		Details:        map[string]any{"mode": row.Mode, "conflict_since": state.since, "conflict_hours": lasted.Hours()},
+	}
+}
+
+// checkSimultaneous records whether the row's thermostat heated or cooled and
+// returns an alert once zones of its household have heated and cooled at the
+// same time for SimultaneousDuration
+func (d *ConflictDetector) checkSimultaneous(row *model.Runtime5m) *model.Alert {
+	if row.HouseholdID == "" {
+		return nil
+	}
+	household, ok := d.households[row.HouseholdID]
+	if !ok {
+		household = &householdConflicts{names: make(map[string]string), bins: make(map[time.Time]*conflictBin)}
+		d.households[row.HouseholdID] = household
+	}
+	household.names[row.ThermostatID] = row.ThermostatName
+	household.prune(row.EventTime)
+
+	heating := isHeating(row)
+	cooling := hasAny(row.Equipment, "compCool1", "compCool2")
+	if !heating && !cooling {
+		return nil
+	}
+	start := row.EventTime.Truncate(binSize)
+	bin, ok := household.bins[start]
+	if !ok {
+		bin = &conflictBin{householdBin: householdBin{heating: make(map[string]bool), cooling: make(map[string]bool)}}
+		household.bins[start] = bin
+	}
+	if heating {
+		bin.heating[row.ThermostatID] = true
+	}
+	if cooling {
+		bin.cooling[row.ThermostatID] = true
+	}
+	if !bin.heatingWhileCooling() {
+		return nil
+	}
+
+	// The run of conflicting bins around this one; a late zone can join
+	// runs on either side
+	first, last := start, start
+	for b := household.bins[first.Add(-binSize)]; b != nil && b.heatingWhileCooling(); b = household.bins[first.Add(-binSize)] {
+		first = first.Add(-binSize)
+	}
+	for b := household.bins[last.Add(binSize)]; b != nil && b.heatingWhileCooling(); b = household.bins[last.Add(binSize)] {
+		last = last.Add(binSize)
+	}
+
+	alerted := false
+	heatingZones, coolingZones := make(map[string]bool), make(map[string]bool)
+	for t := first; !t.After(last); t = t.Add(binSize) {
+		b := household.bins[t]
+		alerted = alerted || b.alerted
+		for id := range b.heating {
+			heatingZones[id] = true
+		}
+		for id := range b.cooling {
+			coolingZones[id] = true
+		}
+	}
+	lasted := last.Sub(first)
+	if !alerted && lasted < d.config.SimultaneousDuration {
+		return nil
+	}
+	for t := first; !t.After(last); t = t.Add(binSize) {
+		household.bins[t].alerted = true
+	}
+	if alerted {
+		return nil
+	}
+
+	heatingIDs, coolingIDs := sortedIDs(heatingZones), sortedIDs(coolingZones)
+	alert := &model.Alert{
+		Type:        "alert",
+		Kind:        model.AlertKindHeatCoolConflict,
+		EventTime:   first,
+		HouseholdID: row.HouseholdID,
+		Message: fmt.Sprintf("%s heating while %s cooling for %s",
+			household.describe(heatingIDs), household.describe(coolingIDs), lasted),
+		Details: map[string]any{"heating": heatingIDs, "cooling": coolingIDs, "conflict_minutes": lasted.Minutes()},
+	}
+	if row.OutdoorTempC != nil {
+		alert.ValueC = *row.OutdoorTempC
+	}
+	return alert
+}
+
+// prune drops bins older than conflictRetention before the latest row seen
+func (h *householdConflicts) prune(at time.Time) {
+	if !at.After(h.latest) {
+		return
+	}
+	h.latest = at
+	for start := range h.bins {
+		if start.Before(at.Add(-conflictRetention)) {
+			delete(h.bins, start)
+		}
+	}
+}
+
+// describe names thermostats for alert messages
+func (h *householdConflicts) describe(ids []string) string {
+	names := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if name := h.names[id]; name != "" {
+			names = append(names, name)
+		} else {
+			names = append(names, id)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// sortedIDs returns the keys of a set in order
+func sortedIDs(set map[string]bool) []string {
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
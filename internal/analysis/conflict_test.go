@@ -0,0 +1,187 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestConflictDetectorSimultaneous(t *testing.T) {
+	start := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	at := func(bin int) time.Time { return start.Add(time.Duration(bin) * binSize) }
+
+	tests := []struct {
+		name    string
+		observe func(d *ConflictDetector) []*model.Alert
+		alerts  int
+		first   time.Time
+	}{
+		{
+			name: "zones fighting for 30 minutes",
+			observe: func(d *ConflictDetector) []*model.Alert {
+				var alerts []*model.Alert
+				for i := range 12 {
+					alerts = append(alerts, d.Observe(zoneRow("upstairs", at(i), 24, "compCool1"))...)
+					alerts = append(alerts, d.Observe(zoneRow("downstairs", at(i), 20, "auxHeat1"))...)
+				}
+				return alerts
+			},
+			alerts: 1,
+			first:  start,
+		},
+		{
+			name: "zones reported in separate polls",
+			observe: func(d *ConflictDetector) []*model.Alert {
+				var alerts []*model.Alert
+				for i := range 7 {
+					alerts = append(alerts, d.Observe(zoneRow("upstairs", at(i), 24, "compCool1"))...)
+				}
+				for i := range 7 {
+					alerts = append(alerts, d.Observe(zoneRow("downstairs", at(i), 20, "compHeat1"))...)
+				}
+				return alerts
+			},
+			alerts: 1,
+			first:  start,
+		},
+		{
+			name: "brief overlap",
+			observe: func(d *ConflictDetector) []*model.Alert {
+				var alerts []*model.Alert
+				for i := range 4 {
+					alerts = append(alerts, d.Observe(zoneRow("upstairs", at(i), 24, "compCool1"))...)
+					alerts = append(alerts, d.Observe(zoneRow("downstairs", at(i), 20, "auxHeat1"))...)
+				}
+				return alerts
+			},
+		},
+		{
+			name: "conflict raised again after clearing",
+			observe: func(d *ConflictDetector) []*model.Alert {
+				var alerts []*model.Alert
+				for i := range 20 {
+					cooling := "compCool1"
+					if i >= 7 && i < 10 {
+						cooling = "fan"
+					}
+					alerts = append(alerts, d.Observe(zoneRow("upstairs", at(i), 24, cooling))...)
+					alerts = append(alerts, d.Observe(zoneRow("downstairs", at(i), 20, "auxHeat1"))...)
+				}
+				return alerts
+			},
+			alerts: 2,
+			first:  start,
+		},
+		{
+			name: "different households",
+			observe: func(d *ConflictDetector) []*model.Alert {
+				var alerts []*model.Alert
+				for i := range 12 {
+					other := zoneRow("cabin", at(i), 20, "auxHeat1")
+					other.HouseholdID = "h2"
+					alerts = append(alerts, d.Observe(zoneRow("upstairs", at(i), 24, "compCool1"))...)
+					alerts = append(alerts, d.Observe(other)...)
+				}
+				return alerts
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alerts := tt.observe(NewConflictDetector(DefaultConflictConfig()))
+			if len(alerts) != tt.alerts {
+				t.Fatalf("Expected %d alerts, got %d: %+v", tt.alerts, len(alerts), alerts)
+			}
+			if len(alerts) == 0 {
+				return
+			}
+			alert := alerts[0]
+			if alert.Kind != model.AlertKindHeatCoolConflict || alert.HouseholdID != "h1" || alert.ThermostatID != "" || !alert.EventTime.Equal(tt.first) {
+				t.Errorf("Unexpected alert %+v", alert)
+			}
+			if heating := alert.Details["heating"].([]string); !slices.Equal(heating, []string{"downstairs"}) {
+				t.Errorf("Expected downstairs heating, got %v", heating)
+			}
+			if cooling := alert.Details["cooling"].([]string); !slices.Equal(cooling, []string{"upstairs"}) {
+				t.Errorf("Expected upstairs cooling, got %v", cooling)
+			}
+		})
+	}
+}
+
+func TestConflictDetectorMode(t *testing.T) {
+	start := time.Date(2025, 6, 10, 12, 0, 0, 0, time.UTC)
+	row := func(i int, mode string, outdoorC float64) *model.Runtime5m {
+		r := heatRow(start.Add(time.Duration(i)*binSize), outdoorC)
+		r.Mode = mode
+		return r
+	}
+
+	tests := []struct {
+		name   string
+		rows   func() []*model.Runtime5m
+		alerts int
+	}{
+		{
+			name: "heat mode on a hot afternoon",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 48 {
+					rows = append(rows, row(i, "heat", 28))
+				}
+				return rows
+			},
+			alerts: 1,
+		},
+		{
+			name: "cool mode on a cold night",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 37 {
+					rows = append(rows, row(i, "cool", 4))
+				}
+				return rows
+			},
+			alerts: 1,
+		},
+		{
+			name: "conflict shorter than the duration",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 30 {
+					rows = append(rows, row(i, "heat", 28))
+				}
+				return rows
+			},
+		},
+		{
+			name: "mode matching the weather",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := range 48 {
+					rows = append(rows, row(i, "cool", 28), row(i, "auto", 28))
+				}
+				return rows
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := NewConflictDetector(DefaultConflictConfig())
+			var alerts []*model.Alert
+			for _, r := range tt.rows() {
+				alerts = append(alerts, detector.Observe(r)...)
+			}
+			if len(alerts) != tt.alerts {
+				t.Fatalf("Expected %d alerts, got %d: %+v", tt.alerts, len(alerts), alerts)
+			}
+			for _, alert := range alerts {
+				if alert.Kind != model.AlertKindModeConflict || alert.ThermostatID != "t1" || alert.SensorID != "" {
+					t.Errorf("Unexpected alert %+v", alert)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+const (
+	// HeatPumpAnalyzerName identifies heat pump analysis documents
+	HeatPumpAnalyzerName = "heat_pump"
+
+	// binSize is the width of a canonical runtime bin
+	binSize = 5 * time.Minute
+
+	// flushGrace delays emitting a period so late-arriving runtime rows
+	// (Ecobee uploads lag by up to an hour) are still counted
+	flushGrace = time.Hour
+)
+
+// HeatPumpConfig tunes defrost detection and balance point estimation
+type HeatPumpConfig struct {
+	// Period is the length of each analysis document's window; whole days
+	// follow each thermostat's local calendar
+	Period time.Duration
+	// Location is the timezone for thermostats that report none; nil means UTC
+	Location *time.Location
+	// DefrostMaxOutdoorC is the warmest outdoor temperature at which frost can
+	// form on the outdoor coil; aux bursts above it are never defrosts
+	DefrostMaxOutdoorC float64
+	// MaxDefrostBins is the longest run of aux heat (in 5-minute bins) that is
+	// still attributed to a defrost cycle rather than the heat pump falling behind
+	MaxDefrostBins int
+	// MinBucketSamples is the minimum number of heating bins in an outdoor
+	// temperature bucket before it contributes to the balance point estimate
+	MinBucketSamples int
+	// AuxShareThreshold is the share of heating bins needing aux heat above
+	// which the heat pump is considered unable to carry the load alone
+	AuxShareThreshold float64
+}
+
+// DefaultHeatPumpConfig returns daily analysis with conservative defrost settings
+func DefaultHeatPumpConfig() HeatPumpConfig {
+	return HeatPumpConfig{
+		Period:             24 * time.Hour,
+		DefrostMaxOutdoorC: 5.0,
+		MaxDefrostBins:     2,
+		MinBucketSamples:   3,
+		AuxShareThreshold:  0.5,
+	}
+}
+
+// HeatPumpAnalyzer detects defrost cycles and estimates the practical balance
+// point of a heat pump from outdoor temperature, compressor and aux heat runtime.
+//
+// A defrost shows up in 5-minute data as a short burst of aux heat while the
+// compressor runs (the air handler tempers the cold air while the coil is
+// reversed), or as cooling-stage runtime while the thermostat is in heat mode.
+// Longer aux runs mean the heat pump could not keep up; the warmest outdoor
+// temperature where that happens for most heating time is the balance point.
+type HeatPumpAnalyzer struct {
+	config      HeatPumpConfig
+	mu          sync.Mutex
+	thermostats map[string]*heatPumpState
+	zones       thermostatZones
+}
+
+// heatPumpState tracks one thermostat across bins and periods
+type heatPumpState struct {
+	name          string
+	householdID   string
+	location      *time.Location
+	lastEventTime time.Time
+	pendingRun    []*model.Runtime5m
+	periods       map[time.Time]*heatPumpPeriod
+}
+
+// heatPumpPeriod accumulates counts for one analysis window
+type heatPumpPeriod struct {
+	end           time.Time
+	heatingBins   int
+	auxBins       int
+	defrostCycles int
+	defrostBins   int
+	reversalBins  int
+	buckets       map[int]*outdoorBucket
+}
+
+// outdoorBucket counts heating bins in a 1°C outdoor temperature band
+type outdoorBucket struct {
+	heatingBins int
+	auxBins     int
+}
+
+// NewHeatPumpAnalyzer creates a heat pump analyzer
+func NewHeatPumpAnalyzer(config HeatPumpConfig) *HeatPumpAnalyzer {
+	return &HeatPumpAnalyzer{
+		config:      config,
+		thermostats: make(map[string]*heatPumpState),
+		zones:       newThermostatZones(config.Location),
+	}
+}
+
+// Name identifies the analyzer
+func (a *HeatPumpAnalyzer) Name() string {
+	return HeatPumpAnalyzerName
+}
+
+// ObserveTimezone sets the timezone a thermostat's periods follow. Periods
+// already open keep the bounds they were created with.
+func (a *HeatPumpAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.zones.set(thermostatID, loc)
+	if state, ok := a.thermostats[thermostatID]; ok {
+		state.location = loc
+	}
+}
+
+// Observe records a runtime row. Rows at or before the last seen bin for a
+// thermostat are ignored so overlapping provider fetches are not double counted.
+func (a *HeatPumpAnalyzer) Observe(row *model.Runtime5m) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state := a.stateFor(row)
+	if !row.EventTime.After(state.lastEventTime) {
+		return
+	}
+	state.lastEventTime = row.EventTime
+
+	if a.isDefrostCandidate(row) {
+		a.recordHeating(state, row)
+		if row.Mode == "heat" && hasAny(row.Equipment, "compCool1", "compCool2") {
+			a.periodFor(state, row.EventTime).reversalBins++
+		}
+		state.pendingRun = append(state.pendingRun, row)
+		return
+	}
+
+	a.resolveRun(state)
+
+	if isHeating(row) {
+		a.recordHeating(state, row)
+		if hasAux(row) {
+			a.recordAux(state, row)
+		}
+	}
+}
+
+// Flush emits analysis documents for periods that ended at least flushGrace before now
+func (a *HeatPumpAnalyzer) Flush(now time.Time) []*model.Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-flushGrace)
+	var results []*model.Analysis
+
+	for thermostatID, state := range a.thermostats {
+		// A run with no following bin for the whole grace period is complete
+		if !state.lastEventTime.Add(binSize).After(cutoff) {
+			a.resolveRun(state)
+		}
+
+		for start, period := range state.periods {
+			if period.end.After(cutoff) || a.runStartsIn(state, start, period.end) {
+				continue
+			}
+			results = append(results, a.buildAnalysis(thermostatID, state, start, period.end, period))
+			delete(state.periods, start)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ThermostatID != results[j].ThermostatID {
+			return results[i].ThermostatID < results[j].ThermostatID
+		}
+		return results[i].PeriodStart.Before(results[j].PeriodStart)
+	})
+
+	return results
+}
+
+// stateFor returns the tracking state for the row's thermostat, creating it if needed
+func (a *HeatPumpAnalyzer) stateFor(row *model.Runtime5m) *heatPumpState {
+	state, ok := a.thermostats[row.ThermostatID]
+	if !ok {
+		state = &heatPumpState{
+			location: a.zones.get(row.ThermostatID),
+			periods:  make(map[time.Time]*heatPumpPeriod),
+		}
+		a.thermostats[row.ThermostatID] = state
+	}
+	state.name = row.ThermostatName
+	state.householdID = row.HouseholdID
+	return state
+}
+
+// periodFor returns the accumulator for the period containing t
+func (a *HeatPumpAnalyzer) periodFor(state *heatPumpState, t time.Time) *heatPumpPeriod {
+	start, end := periodWindow(t, a.config.Period, state.location)
+	period, ok := state.periods[start]
+	if !ok {
+		period = &heatPumpPeriod{end: end, buckets: make(map[int]*outdoorBucket)}
+		state.periods[start] = period
+	}
+	return period
+}
+
+// isDefrostCandidate reports whether a bin looks like part of a defrost cycle
+func (a *HeatPumpAnalyzer) isDefrostCandidate(row *model.Runtime5m) bool {
+	if row.OutdoorTempC == nil || *row.OutdoorTempC > a.config.DefrostMaxOutdoorC {
+		return false
+	}
+	compressorHeating := hasAny(row.Equipment, "compHeat1", "compHeat2")
+	reversed := row.Mode == "heat" && hasAny(row.Equipment, "compCool1", "compCool2")
+	return (compressorHeating && hasAux(row)) || reversed
+}
+
+// resolveRun classifies the pending run of candidate bins as a defrost cycle
+// when it was short, or as genuine aux heat when it lasted too long
+func (a *HeatPumpAnalyzer) resolveRun(state *heatPumpState) {
+	if len(state.pendingRun) == 0 {
+		return
+	}
+
+	if len(state.pendingRun) <= a.config.MaxDefrostBins {
+		period := a.periodFor(state, state.pendingRun[0].EventTime)
+		period.defrostCycles++
+		period.defrostBins += len(state.pendingRun)
+	} else {
+		for _, row := range state.pendingRun {
+			if hasAux(row) {
+				a.recordAux(state, row)
+			}
+		}
+	}
+
+	state.pendingRun = nil
+}
+
+// runStartsIn reports whether an unresolved run began inside the period
+func (a *HeatPumpAnalyzer) runStartsIn(state *heatPumpState, start, end time.Time) bool {
+	if len(state.pendingRun) == 0 {
+		return false
+	}
+	runStart := state.pendingRun[0].EventTime
+	return !runStart.Before(start) && runStart.Before(end)
+}
+
+// recordHeating counts a bin in which the heat pump or aux heat ran
+func (a *HeatPumpAnalyzer) recordHeating(state *heatPumpState, row *model.Runtime5m) {
+	period := a.periodFor(state, row.EventTime)
+	period.heatingBins++
+	if bucket := period.bucket(row); bucket != nil {
+		bucket.heatingBins++
+	}
+}
+
+// recordAux counts a bin in which aux heat supplemented the heat pump
+func (a *HeatPumpAnalyzer) recordAux(state *heatPumpState, row *model.Runtime5m) {
+	period := a.periodFor(state, row.EventTime)
+	period.auxBins++
+	if bucket := period.bucket(row); bucket != nil {
+		bucket.auxBins++
+	}
+}
+
+// bucket returns the outdoor temperature bucket for a row, or nil without outdoor data
+func (p *heatPumpPeriod) bucket(row *model.Runtime5m) *outdoorBucket {
+	if row.OutdoorTempC == nil {
+		return nil
+	}
+	key := int(math.Floor(*row.OutdoorTempC))
+	b, ok := p.buckets[key]
+	if !ok {
+		b = &outdoorBucket{}
+		p.buckets[key] = b
+	}
+	return b
+}
+
+// buildAnalysis converts a finished period into an analysis document
+func (a *HeatPumpAnalyzer) buildAnalysis(thermostatID string, state *heatPumpState, start, end time.Time, period *heatPumpPeriod) *model.Analysis {
+	auxShare := make(map[string]float64, len(period.buckets))
+	for key, bucket := range period.buckets {
+		if bucket.heatingBins > 0 {
+			auxShare[strconv.Itoa(key)] = float64(bucket.auxBins) / float64(bucket.heatingBins)
+		}
+	}
+
+	results := map[string]any{
+		"heating_minutes":        period.heatingBins * int(binSize/time.Minute),
+		"aux_heat_minutes":       period.auxBins * int(binSize/time.Minute),
+		"defrost_cycles":         period.defrostCycles,
+		"defrost_minutes":        period.defrostBins * int(binSize/time.Minute),
+		"reversal_bins":          period.reversalBins,
+		"aux_share_by_outdoor_c": auxShare,
+	}
+	if balancePoint, ok := a.estimateBalancePoint(period); ok {
+		results["balance_point_c"] = balancePoint
+	}
+
+	return &model.Analysis{
+		Type:           "analysis",
+		Analyzer:       HeatPumpAnalyzerName,
+		ThermostatID:   thermostatID,
+		ThermostatName: state.name,
+		HouseholdID:    state.householdID,
+		PeriodStart:    start,
+		PeriodEnd:      end,
+		Results:        results,
+	}
+}
+
+// estimateBalancePoint returns the upper edge of the warmest outdoor bucket in
+// which aux heat was needed for at least AuxShareThreshold of heating time
+func (a *HeatPumpAnalyzer) estimateBalancePoint(period *heatPumpPeriod) (float64, bool) {
+	found := false
+	warmest := math.MinInt
+	for key, bucket := range period.buckets {
+		if bucket.heatingBins < a.config.MinBucketSamples {
+			continue
+		}
+		share := float64(bucket.auxBins) / float64(bucket.heatingBins)
+		if share >= a.config.AuxShareThreshold && key > warmest {
+			warmest = key
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+	return float64(warmest + 1), true
+}
+
+// isHeating reports whether the heat pump or aux heat ran during the bin
+func isHeating(row *model.Runtime5m) bool {
+	return hasAny(row.Equipment, "compHeat1", "compHeat2") || hasAux(row)
+}
+
+// hasAux reports whether any auxiliary heat stage ran during the bin
+func hasAux(row *model.Runtime5m) bool {
+	return hasAny(row.Equipment, "auxHeat1", "auxHeat2", "auxHeat3")
+}
+
+// hasAny reports whether any of the named equipment keys is on
+func hasAny(equipment map[string]bool, keys ...string) bool {
+	for _, key := range keys {
+		if equipment[key] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,171 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func heatRow(at time.Time, outdoorC float64, equipment ...string) *model.Runtime5m {
+	equip := make(map[string]bool, len(equipment))
+	for _, key := range equipment {
+		equip[key] = true
+	}
+	return &model.Runtime5m{
+		Type:           "runtime_5m",
+		ThermostatID:   "t1",
+		ThermostatName: "Hallway",
+		EventTime:      at,
+		Mode:           "heat",
+		OutdoorTempC:   &outdoorC,
+		Equipment:      equip,
+	}
+}
+
+func TestHeatPumpAnalyzer(t *testing.T) {
+	day := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	afterDay := day.Add(26 * time.Hour)
+
+	tests := []struct {
+		name            string
+		rows            func() []*model.Runtime5m
+		wantHeating     int
+		wantAux         int
+		wantDefrosts    int
+		wantReversals   int
+		wantBalancePt   *float64
+		wantNoBalancePt bool
+	}{
+		{
+			name: "short aux burst with compressor is a defrost",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					heatRow(day.Add(0*binSize), -2, "compHeat1"),
+					heatRow(day.Add(1*binSize), -2, "compHeat1", "auxHeat1"),
+					heatRow(day.Add(2*binSize), -2, "compHeat1"),
+				}
+			},
+			wantHeating:     15,
+			wantAux:         0,
+			wantDefrosts:    1,
+			wantNoBalancePt: true,
+		},
+		{
+			name: "cooling stage in heat mode counts as reversal",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					heatRow(day.Add(0*binSize), 0, "compHeat1"),
+					heatRow(day.Add(1*binSize), 0, "compCool1"),
+					heatRow(day.Add(2*binSize), 0, "compHeat1"),
+				}
+			},
+			wantHeating:     15,
+			wantDefrosts:    1,
+			wantReversals:   1,
+			wantNoBalancePt: true,
+		},
+		{
+			name: "long aux run is supplemental heat not defrost",
+			rows: func() []*model.Runtime5m {
+				var rows []*model.Runtime5m
+				for i := 0; i < 6; i++ {
+					rows = append(rows, heatRow(day.Add(time.Duration(i)*binSize), -10.5, "compHeat1", "auxHeat1"))
+				}
+				return append(rows, heatRow(day.Add(6*binSize), -10.5, "compHeat1"))
+			},
+			wantHeating:   35,
+			wantAux:       30,
+			wantDefrosts:  0,
+			wantBalancePt: floatPtr(-10),
+		},
+		{
+			name: "aux above defrost threshold is never a defrost",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					heatRow(day.Add(0*binSize), 8, "compHeat1", "auxHeat1"),
+					heatRow(day.Add(1*binSize), 8, "compHeat1"),
+				}
+			},
+			wantHeating:     10,
+			wantAux:         5,
+			wantNoBalancePt: true,
+		},
+		{
+			name: "duplicate rows are ignored",
+			rows: func() []*model.Runtime5m {
+				row := heatRow(day, 3, "compHeat1")
+				return []*model.Runtime5m{row, row, row}
+			},
+			wantHeating:     5,
+			wantNoBalancePt: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewHeatPumpAnalyzer(DefaultHeatPumpConfig())
+			for _, row := range tt.rows() {
+				analyzer.Observe(row)
+			}
+
+			results := analyzer.Flush(afterDay)
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 analysis, got %d", len(results))
+			}
+			analysis := results[0]
+
+			if analysis.Analyzer != HeatPumpAnalyzerName {
+				t.Errorf("Expected analyzer %q, got %q", HeatPumpAnalyzerName, analysis.Analyzer)
+			}
+			if !analysis.PeriodStart.Equal(day) || !analysis.PeriodEnd.Equal(day.Add(24*time.Hour)) {
+				t.Errorf("Unexpected period %v - %v", analysis.PeriodStart, analysis.PeriodEnd)
+			}
+			if got := analysis.Results["heating_minutes"]; got != tt.wantHeating {
+				t.Errorf("Expected heating_minutes %d, got %v", tt.wantHeating, got)
+			}
+			if got := analysis.Results["aux_heat_minutes"]; got != tt.wantAux {
+				t.Errorf("Expected aux_heat_minutes %d, got %v", tt.wantAux, got)
+			}
+			if got := analysis.Results["defrost_cycles"]; got != tt.wantDefrosts {
+				t.Errorf("Expected defrost_cycles %d, got %v", tt.wantDefrosts, got)
+			}
+			if got := analysis.Results["reversal_bins"]; got != tt.wantReversals {
+				t.Errorf("Expected reversal_bins %d, got %v", tt.wantReversals, got)
+			}
+
+			balancePoint, ok := analysis.Results["balance_point_c"]
+			if tt.wantNoBalancePt && ok {
+				t.Errorf("Expected no balance point, got %v", balancePoint)
+			}
+			if tt.wantBalancePt != nil && balancePoint != *tt.wantBalancePt {
+				t.Errorf("Expected balance point %v, got %v", *tt.wantBalancePt, balancePoint)
+			}
+		})
+	}
+}
+
+func TestHeatPumpAnalyzerFlushTiming(t *testing.T) {
+	day := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := NewHeatPumpAnalyzer(DefaultHeatPumpConfig())
+	analyzer.Observe(heatRow(day.Add(23*time.Hour), 1, "compHeat1"))
+
+	t.Run("period still open", func(t *testing.T) {
+		if results := analyzer.Flush(day.Add(24 * time.Hour)); len(results) != 0 {
+			t.Errorf("Expected no analysis before grace period, got %d", len(results))
+		}
+	})
+
+	t.Run("period emitted once after grace", func(t *testing.T) {
+		if results := analyzer.Flush(day.Add(25 * time.Hour)); len(results) != 1 {
+			t.Errorf("Expected 1 analysis after grace period, got %d", len(results))
+		}
+		if results := analyzer.Flush(day.Add(26 * time.Hour)); len(results) != 0 {
+			t.Errorf("Expected period to be emitted only once, got %d", len(results))
+		}
+	})
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
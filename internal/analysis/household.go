@@ -0,0 +1,258 @@
+package analysis
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// HouseholdAnalyzerName identifies household analysis documents
+const HouseholdAnalyzerName = "household"
+
+// HouseholdConfig controls household aggregation
+type HouseholdConfig struct {
+	// Period is the length of each analysis document's window; whole days
+	// follow the local calendar of the household's first thermostat
+	Period time.Duration
+	// Location is the timezone for thermostats that report none; nil means UTC
+	Location *time.Location
+}
+
+// DefaultHouseholdConfig returns daily analysis
+func DefaultHouseholdConfig() HouseholdConfig {
+	return HouseholdConfig{Period: 24 * time.Hour}
+}
+
+// HouseholdAnalyzer aggregates the thermostats sharing a household ID into
+// whole-home analysis documents: the average indoor temperature across
+// zones, total equipment runtime, and how long one zone heated while another
+// cooled, which usually means zones fighting over a shared space.
+//
+// Documents carry the household ID and no thermostat ID. Rows without a
+// household ID are ignored, and periods in which only one thermostat of a
+// household reported are dropped, since its own documents already cover it.
+type HouseholdAnalyzer struct {
+	config        HouseholdConfig
+	mu            sync.Mutex
+	households    map[string]*householdState
+	memberships   map[string]string // thermostat ID to household ID
+	lastEventTime map[string]time.Time
+	zones         thermostatZones
+}
+
+// householdState tracks one household's periods
+type householdState struct {
+	location *time.Location
+	periods  map[time.Time]*householdPeriod
+}
+
+// householdPeriod accumulates one household's analysis window
+type householdPeriod struct {
+	end         time.Time
+	thermostats map[string]bool
+	bins        map[time.Time]*householdBin
+	tempSum     float64
+	tempCount   int
+	heatSeconds int
+	coolSeconds int
+	fanSeconds  int
+}
+
+// householdBin records which thermostats heated and cooled in one 5-minute bin
+type householdBin struct {
+	heating map[string]bool
+	cooling map[string]bool
+}
+
+// Equipment counted as heating, cooling and fan runtime
+var (
+	heatEquipment = []string{"compHeat1", "compHeat2", "auxHeat1", "auxHeat2", "auxHeat3"}
+	coolEquipment = []string{"compCool1", "compCool2"}
+	fanEquipment  = []string{"fan"}
+)
+
+// NewHouseholdAnalyzer creates a household analyzer
+func NewHouseholdAnalyzer(config HouseholdConfig) *HouseholdAnalyzer {
+	return &HouseholdAnalyzer{
+		config:        config,
+		households:    make(map[string]*householdState),
+		memberships:   make(map[string]string),
+		lastEventTime: make(map[string]time.Time),
+		zones:         newThermostatZones(config.Location),
+	}
+}
+
+// Name identifies the analyzer
+func (a *HouseholdAnalyzer) Name() string {
+	return HouseholdAnalyzerName
+}
+
+// ObserveTimezone records a thermostat's timezone. A household's periods
+// follow the timezone of the thermostat it was first seen with; periods
+// already open keep the bounds they were created with.
+func (a *HouseholdAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.zones.set(thermostatID, loc)
+}
+
+// Observe records a runtime row. Rows at or before the last seen bin for a
+// thermostat are ignored so overlapping provider fetches are not double counted.
+func (a *HouseholdAnalyzer) Observe(row *model.Runtime5m) {
+	if row.HouseholdID == "" {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !row.EventTime.After(a.lastEventTime[row.ThermostatID]) {
+		return
+	}
+	a.lastEventTime[row.ThermostatID] = row.EventTime
+	a.memberships[row.ThermostatID] = row.HouseholdID
+
+	state, ok := a.households[row.HouseholdID]
+	if !ok {
+		state = &householdState{
+			location: a.zones.get(row.ThermostatID),
+			periods:  make(map[time.Time]*householdPeriod),
+		}
+		a.households[row.HouseholdID] = state
+	}
+
+	start, end := periodWindow(row.EventTime, a.config.Period, state.location)
+	period, ok := state.periods[start]
+	if !ok {
+		period = &householdPeriod{
+			end:         end,
+			thermostats: make(map[string]bool),
+			bins:        make(map[time.Time]*householdBin),
+		}
+		state.periods[start] = period
+	}
+	period.thermostats[row.ThermostatID] = true
+
+	if row.AvgTempC != nil {
+		period.tempSum += *row.AvgTempC
+		period.tempCount++
+	}
+	heat := runtimeSeconds(row, heatEquipment)
+	cool := runtimeSeconds(row, coolEquipment)
+	period.heatSeconds += heat
+	period.coolSeconds += cool
+	period.fanSeconds += runtimeSeconds(row, fanEquipment)
+
+	if heat == 0 && cool == 0 {
+		return
+	}
+	binStart := row.EventTime.Truncate(binSize)
+	bin, ok := period.bins[binStart]
+	if !ok {
+		bin = &householdBin{heating: make(map[string]bool), cooling: make(map[string]bool)}
+		period.bins[binStart] = bin
+	}
+	if heat > 0 {
+		bin.heating[row.ThermostatID] = true
+	}
+	if cool > 0 {
+		bin.cooling[row.ThermostatID] = true
+	}
+}
+
+// Flush emits analysis documents for periods that ended at least flushGrace before now
+func (a *HouseholdAnalyzer) Flush(now time.Time) []*model.Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-flushGrace)
+	var results []*model.Analysis
+
+	for householdID, state := range a.households {
+		for start, period := range state.periods {
+			if period.end.After(cutoff) {
+				continue
+			}
+			if len(period.thermostats) > 1 {
+				results = append(results, buildHouseholdAnalysis(householdID, start, period))
+			}
+			delete(state.periods, start)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].HouseholdID != results[j].HouseholdID {
+			return results[i].HouseholdID < results[j].HouseholdID
+		}
+		return results[i].PeriodStart.Before(results[j].PeriodStart)
+	})
+
+	return results
+}
+
+// buildHouseholdAnalysis converts a finished period into an analysis document
+func buildHouseholdAnalysis(householdID string, start time.Time, period *householdPeriod) *model.Analysis {
+	thermostats := make([]string, 0, len(period.thermostats))
+	for id := range period.thermostats {
+		thermostats = append(thermostats, id)
+	}
+	sort.Strings(thermostats)
+
+	simultaneousBins := 0
+	for _, bin := range period.bins {
+		if bin.heatingWhileCooling() {
+			simultaneousBins++
+		}
+	}
+
+	results := map[string]any{
+		"thermostats":                    thermostats,
+		"heat_runtime_minutes":           float64(period.heatSeconds) / 60,
+		"cool_runtime_minutes":           float64(period.coolSeconds) / 60,
+		"fan_runtime_minutes":            float64(period.fanSeconds) / 60,
+		"simultaneous_heat_cool_minutes": simultaneousBins * int(binSize/time.Minute),
+	}
+	if period.tempCount > 0 {
+		results["avg_temp_c"] = period.tempSum / float64(period.tempCount)
+	}
+
+	return &model.Analysis{
+		Type:        "analysis",
+		Analyzer:    HouseholdAnalyzerName,
+		HouseholdID: householdID,
+		PeriodStart: start,
+		PeriodEnd:   period.end,
+		Results:     results,
+	}
+}
+
+// heatingWhileCooling reports whether one thermostat heated while a
+// different one cooled during the bin
+func (b *householdBin) heatingWhileCooling() bool {
+	for heating := range b.heating {
+		for cooling := range b.cooling {
+			if heating != cooling {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runtimeSeconds returns how long any of the named equipment ran during the
+// bin: the longest reported run time, or the whole bin for equipment that was
+// on without one
+func runtimeSeconds(row *model.Runtime5m, equipment []string) int {
+	seconds := 0
+	for _, key := range equipment {
+		if reported, ok := row.EquipmentSecs[key]; ok {
+			seconds = max(seconds, reported)
+		} else if row.Equipment[key] {
+			seconds = max(seconds, int(binSize/time.Second))
+		}
+	}
+	return seconds
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// zoneRow builds a runtime row for one zone of household h1
+func zoneRow(thermostatID string, at time.Time, tempC float64, equipment ...string) *model.Runtime5m {
+	on := make(map[string]bool, len(equipment))
+	for _, key := range equipment {
+		on[key] = true
+	}
+	return &model.Runtime5m{
+		ThermostatID: thermostatID,
+		HouseholdID:  "h1",
+		EventTime:    at,
+		AvgTempC:     &tempC,
+		Equipment:    on,
+	}
+}
+
+func TestHouseholdAnalyzer(t *testing.T) {
+	day := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := NewHouseholdAnalyzer(DefaultHouseholdConfig())
+
+	// Upstairs cools for an hour while downstairs heats during the last
+	// 15 minutes of it
+	for i := range 12 {
+		at := day.Add(12*time.Hour + time.Duration(i)*binSize)
+		analyzer.Observe(zoneRow("upstairs", at, 24, "compCool1", "fan"))
+		if i >= 9 {
+			analyzer.Observe(zoneRow("downstairs", at, 20, "auxHeat1"))
+		} else {
+			analyzer.Observe(zoneRow("downstairs", at, 20))
+		}
+	}
+	// A re-fetched row is not counted twice
+	analyzer.Observe(zoneRow("upstairs", day.Add(12*time.Hour), 24, "compCool1", "fan"))
+	// Rows without a household are ignored
+	analyzer.Observe(&model.Runtime5m{ThermostatID: "garage", EventTime: day.Add(13 * time.Hour)})
+
+	partial := &model.Runtime5m{
+		ThermostatID:  "upstairs",
+		HouseholdID:   "h1",
+		EventTime:     day.Add(14 * time.Hour),
+		Equipment:     map[string]bool{"compCool1": true},
+		EquipmentSecs: map[string]int{"compCool1": 120},
+	}
+	analyzer.Observe(partial)
+
+	if results := analyzer.Flush(day.Add(24 * time.Hour)); len(results) != 0 {
+		t.Fatalf("Expected no analysis before grace period, got %d", len(results))
+	}
+	results := analyzer.Flush(day.Add(26 * time.Hour))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 analysis, got %d", len(results))
+	}
+	analysis := results[0]
+	if analysis.Analyzer != HouseholdAnalyzerName || analysis.HouseholdID != "h1" || analysis.ThermostatID != "" || !analysis.PeriodStart.Equal(day) {
+		t.Errorf("Unexpected analysis %+v", analysis)
+	}
+
+	expected := map[string]any{
+		"avg_temp_c":                     22.0,
+		"heat_runtime_minutes":           15.0,
+		"cool_runtime_minutes":           62.0,
+		"fan_runtime_minutes":            60.0,
+		"simultaneous_heat_cool_minutes": 15,
+	}
+	for key, want := range expected {
+		if got := analysis.Results[key]; got != want {
+			t.Errorf("Expected %s %v, got %v", key, want, got)
+		}
+	}
+	if got := analysis.Results["thermostats"]; !slices.Equal(got.([]string), []string{"downstairs", "upstairs"}) {
+		t.Errorf("Expected both zones listed, got %v", got)
+	}
+}
+
+func TestHouseholdAnalyzerSingleThermostat(t *testing.T) {
+	day := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := NewHouseholdAnalyzer(DefaultHouseholdConfig())
+	analyzer.Observe(zoneRow("upstairs", day.Add(time.Hour), 22, "compCool1"))
+
+	if results := analyzer.Flush(day.Add(26 * time.Hour)); len(results) != 0 {
+		t.Errorf("Expected no analysis for a single-thermostat household, got %+v", results)
+	}
+}
+
+func TestHouseholdAnalyzerSameZoneHeatAndCool(t *testing.T) {
+	day := time.Date(2025, 6, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := NewHouseholdAnalyzer(DefaultHouseholdConfig())
+	analyzer.Observe(zoneRow("upstairs", day.Add(time.Hour), 22, "compCool1", "auxHeat1"))
+	analyzer.Observe(zoneRow("downstairs", day.Add(time.Hour), 22))
+
+	results := analyzer.Flush(day.Add(26 * time.Hour))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 analysis, got %d", len(results))
+	}
+	if got := results[0].Results["simultaneous_heat_cool_minutes"]; got != 0 {
+		t.Errorf("Expected one zone switching modes not to count, got %v", got)
+	}
+}
@@ -0,0 +1,569 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// SetpointAnalyzerName identifies setpoint analysis documents
+const SetpointAnalyzerName = "setpoints"
+
+const (
+	// maxRecovery is how long a recovery may take before it counts as a miss
+	maxRecovery = 3 * time.Hour
+
+	// recoveryToleranceC is how close to the new setpoint the indoor
+	// temperature must get for a recovery to be complete
+	recoveryToleranceC = 0.5
+)
+
+// SetpointConfig tunes the setpoint recommendation rules
+type SetpointConfig struct {
+	// Period is the length of each analysis document's window; whole days
+	// follow each thermostat's local calendar
+	Period time.Duration
+	// Location is the timezone for thermostats that report none; nil means UTC
+	Location *time.Location
+	// HeatingKW and CoolingKW are the electrical power drawn while heating
+	// or cooling runs, for converting runtime into energy
+	HeatingKW float64
+	CoolingKW float64
+	// StepC is the setpoint change recommended at a time
+	StepC float64
+	// FastRecovery is the median recovery time at or below which a setback
+	// is considered shallow enough to deepen
+	FastRecovery time.Duration
+	// MinRecoveries is the number of recoveries from a setback needed
+	// before it is judged
+	MinRecoveries int
+	// DegreeDayBaseC is the outdoor temperature degree days are counted from
+	DegreeDayBaseC float64
+}
+
+// DefaultSetpointConfig returns weekly analysis recommending 1°C steps
+func DefaultSetpointConfig() SetpointConfig {
+	return SetpointConfig{
+		Period:         7 * 24 * time.Hour,
+		HeatingKW:      3.0,
+		CoolingKW:      3.0,
+		StepC:          1.0,
+		FastRecovery:   30 * time.Minute,
+		MinRecoveries:  3,
+		DegreeDayBaseC: 18.0,
+	}
+}
+
+// SetpointAnalyzer recommends setpoint changes from observed behavior. It is
+// rule based and strictly analytic: nothing is written back to thermostats.
+//
+// Each period it fits daily heating and cooling runtime against degree days,
+// giving the runtime one degree of indoor-outdoor difference costs per day,
+// and times recoveries: how long the indoor temperature takes to reach the
+// setpoint after a climate change raises the heat setpoint or lowers the cool
+// setpoint. A setback climate that recovered quickly every time could be set
+// back further; the recommendation estimates the energy the deeper setback
+// would have saved over the period from the time spent in that climate.
+//
+// Flush returns the evidence as analysis documents; the recommendations drawn
+// from the same periods are returned by Recommendations.
+type SetpointAnalyzer struct {
+	config          SetpointConfig
+	mu              sync.Mutex
+	thermostats     map[string]*setpointState
+	zones           thermostatZones
+	recommendations []*model.Recommendation
+}
+
+// setpointState tracks one thermostat across bins and periods
+type setpointState struct {
+	name          string
+	householdID   string
+	location      *time.Location
+	lastEventTime time.Time
+	climate       string
+	setHeatC      *float64
+	setCoolC      *float64
+	recovery      *recovery
+	periods       map[time.Time]*setpointPeriod
+}
+
+// recovery is a climate change in progress toward a more demanding setpoint
+type recovery struct {
+	heating bool
+	from    string // the setback climate being recovered from
+	to      string
+	start   time.Time
+	targetC float64
+}
+
+// setpointPeriod accumulates one analysis window
+type setpointPeriod struct {
+	end      time.Time
+	days     map[time.Time]*degreeDay
+	climates map[string]*climateStats
+}
+
+// degreeDay accumulates one local day's outdoor temperature and runtime
+type degreeDay struct {
+	outdoorSum   float64
+	outdoorCount int
+	heatSeconds  int
+	coolSeconds  int
+}
+
+// climateStats accumulates setpoints and recoveries for one climate
+type climateStats struct {
+	bins           int
+	heatSetSum     float64
+	heatSetBins    int
+	coolSetSum     float64
+	coolSetBins    int
+	heatRecoveries []time.Duration
+	coolRecoveries []time.Duration
+	heatMisses     int
+	coolMisses     int
+}
+
+// NewSetpointAnalyzer creates a setpoint analyzer
+func NewSetpointAnalyzer(config SetpointConfig) *SetpointAnalyzer {
+	return &SetpointAnalyzer{
+		config:      config,
+		thermostats: make(map[string]*setpointState),
+		zones:       newThermostatZones(config.Location),
+	}
+}
+
+// Name identifies the analyzer
+func (a *SetpointAnalyzer) Name() string {
+	return SetpointAnalyzerName
+}
+
+// ObserveTimezone sets the timezone a thermostat's periods follow. Periods
+// already open keep the bounds they were created with.
+func (a *SetpointAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.zones.set(thermostatID, loc)
+	if state, ok := a.thermostats[thermostatID]; ok {
+		state.location = loc
+	}
+}
+
+// Observe records a runtime row. Rows at or before the last seen bin for a
+// thermostat are ignored so overlapping provider fetches are not double counted.
+func (a *SetpointAnalyzer) Observe(row *model.Runtime5m) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.thermostats[row.ThermostatID]
+	if !ok {
+		state = &setpointState{
+			location: a.zones.get(row.ThermostatID),
+			periods:  make(map[time.Time]*setpointPeriod),
+		}
+		a.thermostats[row.ThermostatID] = state
+	}
+	if !row.EventTime.After(state.lastEventTime) {
+		return
+	}
+	state.lastEventTime = row.EventTime
+	state.name = row.ThermostatName
+	state.householdID = row.HouseholdID
+
+	period := a.periodFor(state, row.EventTime)
+	dayStart, _ := periodWindow(row.EventTime, calendarDay, state.location)
+	day, ok := period.days[dayStart]
+	if !ok {
+		day = &degreeDay{}
+		period.days[dayStart] = day
+	}
+	if row.OutdoorTempC != nil {
+		day.outdoorSum += *row.OutdoorTempC
+		day.outdoorCount++
+	}
+	day.heatSeconds += runtimeSeconds(row, heatEquipment)
+	day.coolSeconds += runtimeSeconds(row, coolEquipment)
+
+	if row.Climate != "" {
+		stats := period.climate(row.Climate)
+		stats.bins++
+		if row.SetHeatC != nil && (row.Mode == "heat" || row.Mode == "auto") {
+			stats.heatSetSum += *row.SetHeatC
+			stats.heatSetBins++
+		}
+		if row.SetCoolC != nil && (row.Mode == "cool" || row.Mode == "auto") {
+			stats.coolSetSum += *row.SetCoolC
+			stats.coolSetBins++
+		}
+	}
+
+	a.trackRecovery(state, row)
+	state.climate = row.Climate
+	state.setHeatC = row.SetHeatC
+	state.setCoolC = row.SetCoolC
+}
+
+// trackRecovery finishes the recovery in progress when the setpoint is
+// reached, and starts one when a climate change raises the heat setpoint or
+// lowers the cool setpoint above or below the indoor temperature
+func (a *SetpointAnalyzer) trackRecovery(state *setpointState, row *model.Runtime5m) {
+	if rec := state.recovery; rec != nil {
+		elapsed := row.EventTime.Add(binSize).Sub(rec.start)
+		switch {
+		case rec.reached(row):
+			stats := a.periodFor(state, rec.start).climate(rec.from)
+			if rec.heating {
+				stats.heatRecoveries = append(stats.heatRecoveries, elapsed)
+			} else {
+				stats.coolRecoveries = append(stats.coolRecoveries, elapsed)
+			}
+			state.recovery = nil
+		case row.Climate != rec.to:
+			// Interrupted by another climate change; neither fast nor slow
+			state.recovery = nil
+		case elapsed > maxRecovery:
+			stats := a.periodFor(state, rec.start).climate(rec.from)
+			if rec.heating {
+				stats.heatMisses++
+			} else {
+				stats.coolMisses++
+			}
+			state.recovery = nil
+		}
+	}
+
+	if state.climate == "" || row.Climate == state.climate || row.AvgTempC == nil {
+		return
+	}
+	var rec *recovery
+	switch {
+	case (row.Mode == "heat" || row.Mode == "auto") && raised(state.setHeatC, row.SetHeatC):
+		rec = &recovery{heating: true, targetC: *row.SetHeatC}
+	case (row.Mode == "cool" || row.Mode == "auto") && raised(row.SetCoolC, state.setCoolC):
+		rec = &recovery{targetC: *row.SetCoolC}
+	default:
+		return
+	}
+	rec.from, rec.to, rec.start = state.climate, row.Climate, row.EventTime
+	if !rec.reached(row) {
+		state.recovery = rec
+	}
+}
+
+// raised reports whether a setpoint went up from before to after
+func raised(before, after *float64) bool {
+	return before != nil && after != nil && *after > *before
+}
+
+// reached reports whether the row's indoor temperature is within tolerance
+// of the recovery's target
+func (r *recovery) reached(row *model.Runtime5m) bool {
+	if row.AvgTempC == nil {
+		return false
+	}
+	if r.heating {
+		return *row.AvgTempC >= r.targetC-recoveryToleranceC
+	}
+	return *row.AvgTempC <= r.targetC+recoveryToleranceC
+}
+
+// periodFor returns the accumulator for the period containing t
+func (a *SetpointAnalyzer) periodFor(state *setpointState, t time.Time) *setpointPeriod {
+	start, end := periodWindow(t, a.config.Period, state.location)
+	period, ok := state.periods[start]
+	if !ok {
+		period = &setpointPeriod{
+			end:      end,
+			days:     make(map[time.Time]*degreeDay),
+			climates: make(map[string]*climateStats),
+		}
+		state.periods[start] = period
+	}
+	return period
+}
+
+// climate returns the stats for a climate, creating them if needed
+func (p *setpointPeriod) climate(name string) *climateStats {
+	stats, ok := p.climates[name]
+	if !ok {
+		stats = &climateStats{}
+		p.climates[name] = stats
+	}
+	return stats
+}
+
+// Flush emits analysis documents for periods that ended at least flushGrace
+// before now, and queues the recommendations drawn from them. A period is
+// held back while a recovery that started in it is still in progress.
+func (a *SetpointAnalyzer) Flush(now time.Time) []*model.Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-flushGrace)
+	var results []*model.Analysis
+
+	for thermostatID, state := range a.thermostats {
+		// A recovery with no following bin for the whole grace period is abandoned
+		if !state.lastEventTime.Add(binSize).After(cutoff) {
+			state.recovery = nil
+		}
+
+		for start, period := range state.periods {
+			if period.end.After(cutoff) {
+				continue
+			}
+			if rec := state.recovery; rec != nil && !rec.start.Before(start) && rec.start.Before(period.end) {
+				continue
+			}
+			analysis, recommendations := a.evaluate(thermostatID, state, start, period)
+			results = append(results, analysis)
+			a.recommendations = append(a.recommendations, recommendations...)
+			delete(state.periods, start)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ThermostatID != results[j].ThermostatID {
+			return results[i].ThermostatID < results[j].ThermostatID
+		}
+		return results[i].PeriodStart.Before(results[j].PeriodStart)
+	})
+
+	return results
+}
+
+// Recommendations returns the recommendations drawn from periods flushed
+// since the last call
+func (a *SetpointAnalyzer) Recommendations() []*model.Recommendation {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	recommendations := a.recommendations
+	a.recommendations = nil
+	sort.Slice(recommendations, func(i, j int) bool {
+		ri, rj := recommendations[i], recommendations[j]
+		if ri.ThermostatID != rj.ThermostatID {
+			return ri.ThermostatID < rj.ThermostatID
+		}
+		if !ri.PeriodStart.Equal(rj.PeriodStart) {
+			return ri.PeriodStart.Before(rj.PeriodStart)
+		}
+		if ri.Kind != rj.Kind {
+			return ri.Kind < rj.Kind
+		}
+		return ri.Climate < rj.Climate
+	})
+	return recommendations
+}
+
+// evaluate builds a finished period's analysis document and applies the
+// recommendation rules to it
+func (a *SetpointAnalyzer) evaluate(thermostatID string, state *setpointState, start time.Time, period *setpointPeriod) (*model.Analysis, []*model.Recommendation) {
+	heatSlope, heatFit := a.fitDegreeDays(period, true)
+	coolSlope, coolFit := a.fitDegreeDays(period, false)
+
+	var heatDegreeDays, coolDegreeDays float64
+	var heatSeconds, coolSeconds int
+	for _, day := range period.days {
+		if hdd, cdd, ok := a.degreeDays(day); ok {
+			heatDegreeDays += hdd
+			coolDegreeDays += cdd
+		}
+		heatSeconds += day.heatSeconds
+		coolSeconds += day.coolSeconds
+	}
+
+	results := map[string]any{
+		"degree_day_base_c":   a.config.DegreeDayBaseC,
+		"heating_degree_days": heatDegreeDays,
+		"cooling_degree_days": coolDegreeDays,
+		"heat_runtime_hours":  float64(heatSeconds) / 3600,
+		"cool_runtime_hours":  float64(coolSeconds) / 3600,
+	}
+	if heatFit {
+		results["heat_runtime_hours_per_degree_day"] = heatSlope
+	}
+	if coolFit {
+		results["cool_runtime_hours_per_degree_day"] = coolSlope
+	}
+
+	base := recommendationBase{
+		thermostatID: thermostatID,
+		state:        state,
+		start:        start,
+		end:          period.end,
+	}
+	var recommendations []*model.Recommendation
+	climates := make(map[string]any, len(period.climates))
+	for name, stats := range period.climates {
+		climates[name] = stats.results()
+		if heatFit {
+			if rec := a.recommend(base, name, stats, true, heatSlope); rec != nil {
+				recommendations = append(recommendations, rec)
+			}
+		}
+		if coolFit {
+			if rec := a.recommend(base, name, stats, false, coolSlope); rec != nil {
+				recommendations = append(recommendations, rec)
+			}
+		}
+	}
+	results["climates"] = climates
+	results["recommendations"] = len(recommendations)
+
+	return &model.Analysis{
+		Type:           "analysis",
+		Analyzer:       SetpointAnalyzerName,
+		ThermostatID:   thermostatID,
+		ThermostatName: state.name,
+		HouseholdID:    state.householdID,
+		PeriodStart:    start,
+		PeriodEnd:      period.end,
+		Results:        results,
+	}, recommendations
+}
+
+// recommendationBase carries the period a recommendation is drawn from
+type recommendationBase struct {
+	thermostatID string
+	state        *setpointState
+	start, end   time.Time
+}
+
+// recommend applies the setback rule to a climate: when every recovery from
+// it in the period finished and the median took no longer than FastRecovery,
+// the setback could go StepC further. The savings estimate is the runtime the
+// fitted degree-day slope attributes to StepC over the time spent in the
+// climate, at HeatingKW or CoolingKW.
+func (a *SetpointAnalyzer) recommend(base recommendationBase, climate string, stats *climateStats, heating bool, slope float64) *model.Recommendation {
+	recoveries, misses, setSum, setBins := stats.coolRecoveries, stats.coolMisses, stats.coolSetSum, stats.coolSetBins
+	kind, kw, step, verb := model.RecommendationRaiseCoolSetpoint, a.config.CoolingKW, a.config.StepC, "raised"
+	if heating {
+		recoveries, misses, setSum, setBins = stats.heatRecoveries, stats.heatMisses, stats.heatSetSum, stats.heatSetBins
+		kind, kw, step, verb = model.RecommendationLowerHeatSetpoint, a.config.HeatingKW, -a.config.StepC, "lowered"
+	}
+	if len(recoveries) < a.config.MinRecoveries || misses > 0 || setBins == 0 {
+		return nil
+	}
+	median := medianMinutes(recoveries)
+	if median > a.config.FastRecovery.Minutes() {
+		return nil
+	}
+
+	hours := float64(setBins) * binSize.Hours()
+	runtimeSaved := slope * a.config.StepC * hours / 24
+	kwh := runtimeSaved * kw
+	current := roundTenth(setSum / float64(setBins))
+	mode := "cool"
+	if heating {
+		mode = "heat"
+	}
+
+	return &model.Recommendation{
+		Type:               "recommendation",
+		Kind:               kind,
+		ThermostatID:       base.thermostatID,
+		ThermostatName:     base.state.name,
+		HouseholdID:        base.state.householdID,
+		PeriodStart:        base.start,
+		PeriodEnd:          base.end,
+		Climate:            climate,
+		CurrentSetpointC:   current,
+		SuggestedSetpointC: roundTenth(current + step),
+		EstimatedKWhSaved:  kwh,
+		Message: fmt.Sprintf("%s %s setpoint could be %s %g°C; estimated %.1f kWh saved",
+			climate, mode, verb, a.config.StepC, kwh),
+		Details: map[string]any{
+			"recoveries":                    len(recoveries),
+			"recovery_minutes_median":       median,
+			"climate_hours":                 hours,
+			"runtime_hours_per_degree_day":  slope,
+			"estimated_runtime_hours_saved": runtimeSaved,
+		},
+	}
+}
+
+// degreeDays returns a day's heating and cooling degree days, or false
+// without outdoor data
+func (a *SetpointAnalyzer) degreeDays(day *degreeDay) (hdd, cdd float64, ok bool) {
+	if day.outdoorCount == 0 {
+		return 0, 0, false
+	}
+	outdoor := day.outdoorSum / float64(day.outdoorCount)
+	return max(0, a.config.DegreeDayBaseC-outdoor), max(0, outdoor-a.config.DegreeDayBaseC), true
+}
+
+// fitDegreeDays fits daily heating or cooling runtime hours against degree
+// days by least squares and returns the slope. The fit needs at least three
+// days with outdoor data and differing degree days, and a positive slope.
+func (a *SetpointAnalyzer) fitDegreeDays(period *setpointPeriod, heating bool) (float64, bool) {
+	var xs, ys []float64
+	for _, day := range period.days {
+		hdd, cdd, ok := a.degreeDays(day)
+		if !ok {
+			continue
+		}
+		if heating {
+			xs, ys = append(xs, hdd), append(ys, float64(day.heatSeconds)/3600)
+		} else {
+			xs, ys = append(xs, cdd), append(ys, float64(day.coolSeconds)/3600)
+		}
+	}
+	if len(xs) < 3 {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := range xs {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(len(xs))
+	meanY /= float64(len(ys))
+
+	var covariance, variance float64
+	for i := range xs {
+		covariance += (xs[i] - meanX) * (ys[i] - meanY)
+		variance += (xs[i] - meanX) * (xs[i] - meanX)
+	}
+	if variance == 0 {
+		return 0, false
+	}
+	slope := covariance / variance
+	return slope, slope > 0
+}
+
+// results summarizes a climate for the analysis document
+func (s *climateStats) results() map[string]any {
+	results := map[string]any{
+		"hours":                float64(s.bins) * binSize.Hours(),
+		"heat_recoveries":      len(s.heatRecoveries),
+		"heat_recovery_misses": s.heatMisses,
+		"cool_recoveries":      len(s.coolRecoveries),
+		"cool_recovery_misses": s.coolMisses,
+	}
+	if s.heatSetBins > 0 {
+		results["avg_heat_setpoint_c"] = s.heatSetSum / float64(s.heatSetBins)
+	}
+	if s.coolSetBins > 0 {
+		results["avg_cool_setpoint_c"] = s.coolSetSum / float64(s.coolSetBins)
+	}
+	if len(s.heatRecoveries) > 0 {
+		results["heat_recovery_minutes_median"] = medianMinutes(s.heatRecoveries)
+	}
+	if len(s.coolRecoveries) > 0 {
+		results["cool_recovery_minutes_median"] = medianMinutes(s.coolRecoveries)
+	}
+	return results
+}
+
+// roundTenth rounds a temperature to 0.1°C
+func roundTenth(c float64) float64 {
+	return math.Round(c*10) / 10
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// setbackWeek builds a week of rows for a thermostat that sets back to 17°C
+// from 22:00 to 06:00 and takes recoveryBins bins to reach 21°C each
+// morning. Day d is 10-d°C outside and heats for half an hour per degree day.
+func setbackWeek(week time.Time, recoveryBins int) []*model.Runtime5m {
+	var rows []*model.Runtime5m
+	for d := range 7 {
+		day := week.AddDate(0, 0, d)
+		outdoor := float64(10 - d)
+		heatingBins := (8 + d) * 6
+		for i := range 288 {
+			at := day.Add(time.Duration(i) * binSize)
+			row := heatRow(at, outdoor)
+			setHeat, indoor := 21.0, 21.0
+			row.Climate = "Home"
+			if at.Hour() < 6 || at.Hour() >= 22 {
+				row.Climate, setHeat, indoor = "Sleep", 17.0, 17.0
+			} else if i-72 < recoveryBins {
+				indoor = 18.0
+			}
+			row.SetHeatC, row.AvgTempC = &setHeat, &indoor
+			if i < heatingBins {
+				row.Equipment["compHeat1"] = true
+			}
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestSetpointAnalyzer(t *testing.T) {
+	week := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC) // a Monday
+
+	tests := []struct {
+		name             string
+		recoveryBins     int
+		wantMedian       float64
+		wantRecommending bool
+	}{
+		{name: "quick recoveries suggest a deeper setback", recoveryBins: 2, wantMedian: 15, wantRecommending: true},
+		{name: "slow recoveries do not", recoveryBins: 12, wantMedian: 65},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewSetpointAnalyzer(DefaultSetpointConfig())
+			for _, row := range setbackWeek(week, tt.recoveryBins) {
+				analyzer.Observe(row)
+			}
+
+			if results := analyzer.Flush(week.AddDate(0, 0, 7)); len(results) != 0 {
+				t.Fatalf("Expected no analysis before grace period, got %d", len(results))
+			}
+			results := analyzer.Flush(week.AddDate(0, 0, 7).Add(2 * time.Hour))
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 analysis, got %d", len(results))
+			}
+			analysis := results[0]
+			if analysis.Analyzer != SetpointAnalyzerName || !analysis.PeriodStart.Equal(week) {
+				t.Errorf("Unexpected analysis %+v", analysis)
+			}
+			if got := analysis.Results["heat_runtime_hours_per_degree_day"]; math.Abs(got.(float64)-0.5) > 1e-9 {
+				t.Errorf("Expected 0.5 runtime hours per degree day, got %v", got)
+			}
+			if _, ok := analysis.Results["cool_runtime_hours_per_degree_day"]; ok {
+				t.Error("Expected no cooling fit without cooling degree days")
+			}
+			sleep := analysis.Results["climates"].(map[string]any)["Sleep"].(map[string]any)
+			if sleep["heat_recoveries"] != 7 || sleep["heat_recovery_minutes_median"] != tt.wantMedian {
+				t.Errorf("Unexpected Sleep climate results %v", sleep)
+			}
+
+			recommendations := analyzer.Recommendations()
+			if !tt.wantRecommending {
+				if len(recommendations) != 0 {
+					t.Errorf("Expected no recommendations, got %+v", recommendations)
+				}
+				return
+			}
+			if len(recommendations) != 1 {
+				t.Fatalf("Expected 1 recommendation, got %d", len(recommendations))
+			}
+			rec := recommendations[0]
+			if rec.Kind != model.RecommendationLowerHeatSetpoint || rec.Climate != "Sleep" || rec.CurrentSetpointC != 17 || rec.SuggestedSetpointC != 16 {
+				t.Errorf("Unexpected recommendation %+v", rec)
+			}
+			// 0.5 h per degree day over 56 hours of Sleep at 3 kW
+			if math.Abs(rec.EstimatedKWhSaved-3.5) > 1e-9 {
+				t.Errorf("Expected 3.5 kWh saved, got %v", rec.EstimatedKWhSaved)
+			}
+			if rec.Message != "Sleep heat setpoint could be lowered 1°C; estimated 3.5 kWh saved" {
+				t.Errorf("Unexpected message %q", rec.Message)
+			}
+			if again := analyzer.Recommendations(); len(again) != 0 {
+				t.Errorf("Expected recommendations to be returned once, got %d more", len(again))
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// minFitBins is the fewest usable bin pairs, and minEquipmentBins the fewest
+// bins with heating or cooling running, a thermal model is fitted from
+const (
+	minFitBins       = 288
+	minEquipmentBins = 12
+)
+
+// SimulationConfig controls how history is replayed
+type SimulationConfig struct {
+	// HeatingKW and CoolingKW convert runtime hours to energy
+	HeatingKW float64
+	CoolingKW float64
+	// Location is the timezone schedule windows follow; nil means UTC
+	Location *time.Location
+}
+
+// SetpointOverride replaces one or both setpoints; a nil setpoint is kept
+type SetpointOverride struct {
+	HeatC *float64 `json:"heat_c,omitempty"`
+	CoolC *float64 `json:"cool_c,omitempty"`
+}
+
+// SetpointWindow overrides setpoints between two times of day. A window
+// whose end is before its start crosses midnight.
+type SetpointWindow struct {
+	Start time.Duration // since local midnight
+	End   time.Duration
+	SetpointOverride
+}
+
+// SimulationSchedule is an alternate setpoint schedule. Climate overrides
+// apply while the named climate was active, then windows in order; offsets
+// are added to historical setpoints that neither replaced.
+type SimulationSchedule struct {
+	HeatOffsetC float64
+	CoolOffsetC float64
+	Climates    map[string]SetpointOverride
+	Windows     []SetpointWindow
+}
+
+// ThermalModel is a first-order fit of how indoor temperature changes over
+// one bin: LossRate × (outdoor − indoor) + HeatRate × the fraction of the bin
+// heating ran − CoolRate × the fraction cooling ran. A zero rate means there
+// was too little runtime to fit it.
+type ThermalModel struct {
+	LossRate float64 `json:"loss_rate"`
+	HeatRate float64 `json:"heat_rate_c"`
+	CoolRate float64 `json:"cool_rate_c"`
+	Bins     int     `json:"bins"`
+}
+
+// SimulationResult compares one thermostat's modeled runtime under its
+// historical setpoints (the baseline) and the alternate schedule. Comparing
+// two runs of the same model cancels most of its bias; the actual runtime is
+// included to judge how well the baseline tracks reality.
+type SimulationResult struct {
+	ThermostatID      string       `json:"thermostat_id"`
+	ThermostatName    string       `json:"thermostat_name"`
+	From              time.Time    `json:"from"`
+	To                time.Time    `json:"to"`
+	Bins              int          `json:"bins"`
+	Model             ThermalModel `json:"model"`
+	ActualHeatHours   float64      `json:"actual_heat_hours"`
+	ActualCoolHours   float64      `json:"actual_cool_hours"`
+	BaselineHeatHours float64      `json:"baseline_heat_hours"`
+	BaselineCoolHours float64      `json:"baseline_cool_hours"`
+	ScenarioHeatHours float64      `json:"scenario_heat_hours"`
+	ScenarioCoolHours float64      `json:"scenario_cool_hours"`
+	// HeatKWhChange and CoolKWhChange are scenario minus baseline energy;
+	// negative values are savings
+	HeatKWhChange float64 `json:"heat_kwh_change"`
+	CoolKWhChange float64 `json:"cool_kwh_change"`
+	// Skipped explains why a thermostat could not be simulated
+	Skipped string `json:"skipped,omitempty"`
+}
+
+// Simulate fits a thermal model to each thermostat's runtime rows and
+// replays them under the historical setpoints and the schedule, returning
+// results ordered by thermostat ID. Rows may arrive in any order; duplicate
+// bins are ignored. The modeled thermostat holds its setpoint exactly, only
+// heats or cools when its historical mode allowed it, and restarts from the
+// measured temperature after a gap in the data.
+func Simulate(rows []*model.Runtime5m, schedule SimulationSchedule, config SimulationConfig) []SimulationResult {
+	byThermostat := make(map[string][]*model.Runtime5m)
+	for _, row := range rows {
+		byThermostat[row.ThermostatID] = append(byThermostat[row.ThermostatID], row)
+	}
+
+	results := make([]SimulationResult, 0, len(byThermostat))
+	for thermostatID, thermostatRows := range byThermostat {
+		sort.SliceStable(thermostatRows, func(i, j int) bool {
+			return thermostatRows[i].EventTime.Before(thermostatRows[j].EventTime)
+		})
+		deduped := thermostatRows[:0]
+		for _, row := range thermostatRows {
+			if len(deduped) == 0 || row.EventTime.After(deduped[len(deduped)-1].EventTime) {
+				deduped = append(deduped, row)
+			}
+		}
+		results = append(results, simulateThermostat(thermostatID, deduped, schedule, config))
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ThermostatID < results[j].ThermostatID })
+	return results
+}
+
+// simulateThermostat fits and replays one thermostat's time-ordered rows
+func simulateThermostat(thermostatID string, rows []*model.Runtime5m, schedule SimulationSchedule, config SimulationConfig) SimulationResult {
+	last := rows[len(rows)-1]
+	result := SimulationResult{
+		ThermostatID:   thermostatID,
+		ThermostatName: last.ThermostatName,
+		From:           rows[0].EventTime,
+		To:             last.EventTime.Add(binSize),
+		Bins:           len(rows),
+	}
+	for _, row := range rows {
+		result.ActualHeatHours += heatFraction(row) * binSize.Hours()
+		result.ActualCoolHours += coolFraction(row) * binSize.Hours()
+	}
+
+	thermal, err := fitThermalModel(rows)
+	if err != nil {
+		result.Skipped = err.Error()
+		return result
+	}
+	result.Model = thermal
+
+	location := config.Location
+	if location == nil {
+		location = time.UTC
+	}
+	result.BaselineHeatHours, result.BaselineCoolHours = thermal.replay(rows, func(row *model.Runtime5m) (*float64, *float64) {
+		return row.SetHeatC, row.SetCoolC
+	})
+	result.ScenarioHeatHours, result.ScenarioCoolHours = thermal.replay(rows, func(row *model.Runtime5m) (*float64, *float64) {
+		return schedule.setpoints(row, location)
+	})
+	result.HeatKWhChange = (result.ScenarioHeatHours - result.BaselineHeatHours) * config.HeatingKW
+	result.CoolKWhChange = (result.ScenarioCoolHours - result.BaselineCoolHours) * config.CoolingKW
+	return result
+}
+
+// setpoints returns the schedule's setpoints for a historical row
+func (s SimulationSchedule) setpoints(row *model.Runtime5m, location *time.Location) (heat, cool *float64) {
+	heatReplaced, coolReplaced := false, false
+	apply := func(override SetpointOverride) {
+		if override.HeatC != nil {
+			heat, heatReplaced = override.HeatC, true
+		}
+		if override.CoolC != nil {
+			cool, coolReplaced = override.CoolC, true
+		}
+	}
+
+	if override, ok := s.Climates[row.Climate]; ok {
+		apply(override)
+	}
+	local := row.EventTime.In(location)
+	sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	for _, window := range s.Windows {
+		if window.contains(sinceMidnight) {
+			apply(window.SetpointOverride)
+		}
+	}
+
+	if !heatReplaced && row.SetHeatC != nil {
+		shifted := *row.SetHeatC + s.HeatOffsetC
+		heat = &shifted
+	}
+	if !coolReplaced && row.SetCoolC != nil {
+		shifted := *row.SetCoolC + s.CoolOffsetC
+		cool = &shifted
+	}
+	return heat, cool
+}
+
+// contains reports whether a time of day falls inside the window
+func (w SetpointWindow) contains(sinceMidnight time.Duration) bool {
+	if w.Start <= w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+// fitThermalModel fits the model by least squares over pairs of consecutive
+// bins that both have indoor and outdoor temperatures. Heating and cooling
+// rates are only fitted when enough bins ran that equipment.
+func fitThermalModel(rows []*model.Runtime5m) (ThermalModel, error) {
+	type sample struct {
+		x []float64 // outdoor − indoor, heat fraction, cool fraction
+		y float64   // change in indoor temperature
+	}
+	var samples []sample
+	heatBins, coolBins := 0, 0
+	for i := 0; i+1 < len(rows); i++ {
+		row, next := rows[i], rows[i+1]
+		if next.EventTime.Sub(row.EventTime) != binSize || row.AvgTempC == nil || next.AvgTempC == nil || row.OutdoorTempC == nil {
+			continue
+		}
+		heat, cool := heatFraction(row), coolFraction(row)
+		if heat > 0 {
+			heatBins++
+		}
+		if cool > 0 {
+			coolBins++
+		}
+		samples = append(samples, sample{
+			x: []float64{*row.OutdoorTempC - *row.AvgTempC, heat, -cool},
+			y: *next.AvgTempC - *row.AvgTempC,
+		})
+	}
+	if len(samples) < minFitBins {
+		return ThermalModel{}, fmt.Errorf("%d usable bins with indoor and outdoor temperatures; %d needed", len(samples), minFitBins)
+	}
+
+	// Only fit the terms there is data for
+	columns := []int{0}
+	if heatBins >= minEquipmentBins {
+		columns = append(columns, 1)
+	}
+	if coolBins >= minEquipmentBins {
+		columns = append(columns, 2)
+	}
+	if len(columns) == 1 {
+		return ThermalModel{}, fmt.Errorf("too little heating or cooling runtime to fit")
+	}
+
+	n := len(columns)
+	normal := make([][]float64, n)
+	for i := range normal {
+		normal[i] = make([]float64, n+1)
+	}
+	for _, s := range samples {
+		for i, ci := range columns {
+			for j, cj := range columns {
+				normal[i][j] += s.x[ci] * s.x[cj]
+			}
+			normal[i][n] += s.x[ci] * s.y
+		}
+	}
+	coefficients, ok := solveLinear(normal)
+	if !ok {
+		return ThermalModel{}, fmt.Errorf("runtime does not vary enough to fit a thermal model")
+	}
+
+	thermal := ThermalModel{Bins: len(samples)}
+	for i, column := range columns {
+		switch column {
+		case 0:
+			thermal.LossRate = coefficients[i]
+		case 1:
+			thermal.HeatRate = coefficients[i]
+		case 2:
+			thermal.CoolRate = coefficients[i]
+		}
+	}
+	if thermal.LossRate <= 0 || thermal.LossRate >= 1 || thermal.HeatRate < 0 || thermal.CoolRate < 0 {
+		return ThermalModel{}, fmt.Errorf("fitted thermal model is not physical (loss %.4f, heat %.3f, cool %.3f)", thermal.LossRate, thermal.HeatRate, thermal.CoolRate)
+	}
+	return thermal, nil
+}
+
+// solveLinear solves an augmented n×(n+1) system by Gaussian elimination
+// with partial pivoting
+func solveLinear(m [][]float64) ([]float64, bool) {
+	n := len(m)
+	for col := range n {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := col + 1; row < n; row++ {
+			factor := m[row][col] / m[col][col]
+			for k := col; k <= n; k++ {
+				m[row][k] -= factor * m[col][k]
+			}
+		}
+	}
+
+	solution := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		sum := m[row][n]
+		for k := row + 1; k < n; k++ {
+			sum -= m[row][k] * solution[k]
+		}
+		solution[row] = sum / m[row][row]
+	}
+	return solution, true
+}
+
+// replay runs the model over the rows with the setpoints chosen for each,
+// returning modeled heating and cooling hours
+func (m ThermalModel) replay(rows []*model.Runtime5m, setpoints func(*model.Runtime5m) (heat, cool *float64)) (heatHours, coolHours float64) {
+	var indoor, outdoor *float64
+	var previous time.Time
+	for _, row := range rows {
+		// Restart from the measurement after a gap
+		if indoor == nil || row.EventTime.Sub(previous) != binSize {
+			indoor = nil
+			if row.AvgTempC != nil {
+				measured := *row.AvgTempC
+				indoor = &measured
+			}
+		}
+		previous = row.EventTime
+		if row.OutdoorTempC != nil {
+			outdoor = row.OutdoorTempC
+		}
+		if indoor == nil || outdoor == nil {
+			continue
+		}
+
+		drift := m.LossRate * (*outdoor - *indoor)
+		next := *indoor + drift
+		heat, cool := setpoints(row)
+		if heat != nil && m.HeatRate > 0 && heatAllowed(row.Mode) && next < *heat {
+			fraction := math.Min(1, (*heat-next)/m.HeatRate)
+			next += fraction * m.HeatRate
+			heatHours += fraction * binSize.Hours()
+		}
+		if cool != nil && m.CoolRate > 0 && coolAllowed(row.Mode) && next > *cool {
+			fraction := math.Min(1, (next-*cool)/m.CoolRate)
+			next -= fraction * m.CoolRate
+			coolHours += fraction * binSize.Hours()
+		}
+		indoor = &next
+	}
+	return heatHours, coolHours
+}
+
+// heatAllowed reports whether a thermostat mode permits heating
+func heatAllowed(mode string) bool {
+	return mode == "heat" || mode == "auto"
+}
+
+// coolAllowed reports whether a thermostat mode permits cooling
+func coolAllowed(mode string) bool {
+	return mode == "cool" || mode == "auto"
+}
+
+// heatFraction returns the fraction of the bin any heating equipment ran
+func heatFraction(row *model.Runtime5m) float64 {
+	return float64(runtimeSeconds(row, heatEquipment)) / binSize.Seconds()
+}
+
+// coolFraction returns the fraction of the bin any cooling equipment ran
+func coolFraction(row *model.Runtime5m) float64 {
+	return float64(runtimeSeconds(row, coolEquipment)) / binSize.Seconds()
+}
@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// simulatedHistory builds days of rows for a home that loses 1% of the
+// indoor-outdoor difference per bin and gains 0.5°C per bin of heating, held
+// at 20°C with a 17°C Sleep setback from 22:00 to 06:00
+func simulatedHistory(start time.Time, days int) []*model.Runtime5m {
+	const lossRate, heatRate = 0.01, 0.5
+	var rows []*model.Runtime5m
+	indoor := 20.0
+	for i := range days * 288 {
+		at := start.Add(time.Duration(i) * binSize)
+		outdoor := 2 + 4*math.Sin(float64(i)/288*2*math.Pi)
+		setHeat, setCool, climate := 20.0, 26.0, "Home"
+		if at.Hour() < 6 || at.Hour() >= 22 {
+			setHeat, climate = 17.0, "Sleep"
+		}
+
+		row := heatRow(at, outdoor)
+		measured := indoor
+		row.Climate, row.SetHeatC, row.SetCoolC, row.AvgTempC = climate, &setHeat, &setCool, &measured
+		row.EquipmentSecs = map[string]int{}
+
+		next := indoor + lossRate*(outdoor-indoor)
+		if next < setHeat {
+			seconds := int(math.Round(math.Min(1, (setHeat-next)/heatRate) * 300))
+			row.Equipment["compHeat1"] = seconds > 0
+			row.EquipmentSecs["compHeat1"] = seconds
+			next += float64(seconds) / 300 * heatRate
+		}
+		indoor = next
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func TestSimulate(t *testing.T) {
+	start := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	rows := simulatedHistory(start, 3)
+	lower, warmer := 16.0, 27.0
+	config := SimulationConfig{HeatingKW: 3, CoolingKW: 3}
+
+	t.Run("unchanged schedule matches the baseline", func(t *testing.T) {
+		results := Simulate(rows, SimulationSchedule{}, config)
+		if len(results) != 1 {
+			t.Fatalf("Expected 1 result, got %d", len(results))
+		}
+		result := results[0]
+		if result.Skipped != "" {
+			t.Fatalf("Unexpected skip: %s", result.Skipped)
+		}
+		if math.Abs(result.Model.LossRate-0.01) > 0.0005 || math.Abs(result.Model.HeatRate-0.5) > 0.025 || result.Model.CoolRate != 0 {
+			t.Errorf("Unexpected thermal model %+v", result.Model)
+		}
+		if math.Abs(result.BaselineHeatHours-result.ActualHeatHours) > 0.05*result.ActualHeatHours {
+			t.Errorf("Expected baseline near the actual %.2f heat hours, got %.2f", result.ActualHeatHours, result.BaselineHeatHours)
+		}
+		if result.ScenarioHeatHours != result.BaselineHeatHours || result.HeatKWhChange != 0 {
+			t.Errorf("Expected no change, got %+v", result)
+		}
+		if !result.From.Equal(start) || !result.To.Equal(start.AddDate(0, 0, 3)) || result.Bins != 864 {
+			t.Errorf("Unexpected range %v-%v with %d bins", result.From, result.To, result.Bins)
+		}
+	})
+
+	t.Run("deeper setback saves energy", func(t *testing.T) {
+		schedule := SimulationSchedule{Climates: map[string]SetpointOverride{"Sleep": {HeatC: &lower}}}
+		result := Simulate(rows, schedule, config)[0]
+		saved := result.BaselineHeatHours - result.ScenarioHeatHours
+		if saved <= 0 {
+			t.Fatalf("Expected fewer heat hours, got %.2f instead of %.2f", result.ScenarioHeatHours, result.BaselineHeatHours)
+		}
+		if math.Abs(result.HeatKWhChange+saved*3) > 1e-9 {
+			t.Errorf("Expected %.2f kWh saved, got a change of %.2f", saved*3, result.HeatKWhChange)
+		}
+	})
+
+	t.Run("higher offset costs energy", func(t *testing.T) {
+		result := Simulate(rows, SimulationSchedule{HeatOffsetC: 1, CoolOffsetC: 1}, config)[0]
+		if result.ScenarioHeatHours <= result.BaselineHeatHours || result.HeatKWhChange <= 0 {
+			t.Errorf("Expected more heat hours, got %+v", result)
+		}
+		if result.ScenarioCoolHours != 0 || result.CoolKWhChange != 0 {
+			t.Errorf("Expected no cooling without a fitted cooling rate, got %+v", result)
+		}
+	})
+
+	t.Run("too little history", func(t *testing.T) {
+		result := Simulate(rows[:100], SimulationSchedule{Windows: []SetpointWindow{{SetpointOverride: SetpointOverride{CoolC: &warmer}}}}, config)[0]
+		if result.Skipped == "" || result.ActualHeatHours == 0 {
+			t.Errorf("Expected a skipped result with actual runtime, got %+v", result)
+		}
+	})
+}
+
+func TestSimulationScheduleSetpoints(t *testing.T) {
+	day := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	value := func(c float64) *float64 { return &c }
+	schedule := SimulationSchedule{
+		HeatOffsetC: -1,
+		Climates:    map[string]SetpointOverride{"Away": {HeatC: value(15)}},
+		Windows: []SetpointWindow{
+			{Start: 22 * time.Hour, End: 6 * time.Hour, SetpointOverride: SetpointOverride{HeatC: value(16), CoolC: value(28)}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		climate  string
+		wantHeat float64
+		wantCool float64
+	}{
+		{name: "offset only", at: day.Add(12 * time.Hour), climate: "Home", wantHeat: 19, wantCool: 25},
+		{name: "climate override is not offset", at: day.Add(12 * time.Hour), climate: "Away", wantHeat: 15, wantCool: 25},
+		{name: "window after midnight", at: day.Add(3 * time.Hour), climate: "Home", wantHeat: 16, wantCool: 28},
+		{name: "window wins over climate", at: day.Add(23 * time.Hour), climate: "Away", wantHeat: 16, wantCool: 28},
+		{name: "window end is exclusive", at: day.Add(6 * time.Hour), climate: "Home", wantHeat: 19, wantCool: 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := heatRow(tt.at, 0)
+			row.Climate, row.SetHeatC, row.SetCoolC = tt.climate, value(20), value(25)
+			heat, cool := schedule.setpoints(row, time.UTC)
+			if heat == nil || cool == nil || *heat != tt.wantHeat || *cool != tt.wantCool {
+				t.Errorf("Expected %v/%v, got %v/%v", tt.wantHeat, tt.wantCool, heat, cool)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package analysis
+
+import (
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// StagingAnalyzerName identifies equipment staging analysis documents
+const StagingAnalyzerName = "staging"
+
+// StagingConfig controls equipment staging analysis
+type StagingConfig struct {
+	// Period is the length of each analysis document's window; whole days
+	// follow each thermostat's local calendar
+	Period time.Duration
+	// Location is the timezone for thermostats that report none; nil means UTC
+	Location *time.Location
+	// AuxWindow is how soon after a heat setpoint raise aux heat must engage
+	// to be attributed to it
+	AuxWindow time.Duration
+}
+
+// DefaultStagingConfig returns daily analysis attributing aux heat within
+// 30 minutes of a setpoint raise
+func DefaultStagingConfig() StagingConfig {
+	return StagingConfig{Period: 24 * time.Hour, AuxWindow: 30 * time.Minute}
+}
+
+// StagingAnalyzer measures how equipment stages up, for tuning a thermostat's
+// stage delta and aux heat settings: how long stage 1 heating or cooling runs
+// before stage 2 engages, and how often aux heat engages soon after the heat
+// setpoint is raised.
+//
+// A run is a sequence of consecutive bins with stage 1 or stage 2 on; a
+// missing bin ends it. Stage-up latency is the time from the start of the run
+// to the bin stage 2 first ran in, less the part of that bin before stage 2
+// started when the provider reports run seconds. Runs and setpoint raises
+// count toward the period they started in. Periods without either produce no
+// document.
+type StagingAnalyzer struct {
+	config      StagingConfig
+	mu          sync.Mutex
+	thermostats map[string]*stagingState
+	zones       thermostatZones
+}
+
+// stagingState tracks one thermostat across bins and periods
+type stagingState struct {
+	name          string
+	householdID   string
+	location      *time.Location
+	lastEventTime time.Time
+	lastSetHeatC  *float64
+	heat          stageRun
+	cool          stageRun
+	raisedAt      time.Time // latest setpoint raise still waiting for aux heat
+	periods       map[time.Time]*stagingPeriod
+}
+
+// stageRun is the equipment run in progress for one of heating or cooling
+type stageRun struct {
+	start  time.Time // zero when no run is in progress
+	staged bool
+}
+
+// stagingPeriod accumulates one analysis window
+type stagingPeriod struct {
+	end            time.Time
+	heat           stageCounts
+	cool           stageCounts
+	setpointRaises int
+	auxLatencies   []time.Duration // one per raise followed by aux heat
+}
+
+// stageCounts counts runs and the stage-up latency of those reaching stage 2
+type stageCounts struct {
+	runs      int
+	latencies []time.Duration
+}
+
+// NewStagingAnalyzer creates an equipment staging analyzer
+func NewStagingAnalyzer(config StagingConfig) *StagingAnalyzer {
+	return &StagingAnalyzer{
+		config:      config,
+		thermostats: make(map[string]*stagingState),
+		zones:       newThermostatZones(config.Location),
+	}
+}
+
+// Name identifies the analyzer
+func (a *StagingAnalyzer) Name() string {
+	return StagingAnalyzerName
+}
+
+// ObserveTimezone sets the timezone a thermostat's periods follow. Periods
+// already open keep the bounds they were created with.
+func (a *StagingAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.zones.set(thermostatID, loc)
+	if state, ok := a.thermostats[thermostatID]; ok {
+		state.location = loc
+	}
+}
+
+// Observe records a runtime row. Rows at or before the last seen bin for a
+// thermostat are ignored so overlapping provider fetches are not double counted.
+func (a *StagingAnalyzer) Observe(row *model.Runtime5m) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.thermostats[row.ThermostatID]
+	if !ok {
+		state = &stagingState{
+			location: a.zones.get(row.ThermostatID),
+			periods:  make(map[time.Time]*stagingPeriod),
+		}
+		a.thermostats[row.ThermostatID] = state
+	}
+	if !row.EventTime.After(state.lastEventTime) {
+		return
+	}
+	if !state.lastEventTime.IsZero() && row.EventTime.Sub(state.lastEventTime) > binSize {
+		state.heat, state.cool = stageRun{}, stageRun{}
+	}
+	state.lastEventTime = row.EventTime
+	state.name = row.ThermostatName
+	state.householdID = row.HouseholdID
+
+	a.trackRun(state, &state.heat, row, "compHeat1", "compHeat2", func(p *stagingPeriod) *stageCounts { return &p.heat })
+	a.trackRun(state, &state.cool, row, "compCool1", "compCool2", func(p *stagingPeriod) *stageCounts { return &p.cool })
+	a.trackRaise(state, row)
+}
+
+// trackRun extends, ends or stages up a thermostat's heating or cooling run
+func (a *StagingAnalyzer) trackRun(state *stagingState, run *stageRun, row *model.Runtime5m, stage1, stage2 string, counts func(*stagingPeriod) *stageCounts) {
+	if !row.Equipment[stage1] && !row.Equipment[stage2] {
+		*run = stageRun{}
+		return
+	}
+	if run.start.IsZero() {
+		*run = stageRun{start: row.EventTime}
+		counts(a.periodFor(state, run.start)).runs++
+	}
+	if !row.Equipment[stage2] || run.staged {
+		return
+	}
+	run.staged = true
+
+	latency := row.EventTime.Sub(run.start)
+	if seconds, ok := row.EquipmentSecs[stage2]; ok && seconds > 0 && seconds < int(binSize/time.Second) {
+		latency += binSize - time.Duration(seconds)*time.Second
+	}
+	c := counts(a.periodFor(state, run.start))
+	c.latencies = append(c.latencies, latency)
+}
+
+// trackRaise records heat setpoint raises and whether aux heat followed
+// within AuxWindow
+func (a *StagingAnalyzer) trackRaise(state *stagingState, row *model.Runtime5m) {
+	if row.SetHeatC != nil {
+		if state.lastSetHeatC != nil && *row.SetHeatC > *state.lastSetHeatC && (row.Mode == "heat" || row.Mode == "auto") {
+			state.raisedAt = row.EventTime
+			a.periodFor(state, row.EventTime).setpointRaises++
+		}
+		setHeatC := *row.SetHeatC
+		state.lastSetHeatC = &setHeatC
+	}
+
+	if state.raisedAt.IsZero() {
+		return
+	}
+	latency := row.EventTime.Sub(state.raisedAt)
+	if latency > a.config.AuxWindow {
+		state.raisedAt = time.Time{}
+		return
+	}
+	if hasAux(row) {
+		period := a.periodFor(state, state.raisedAt)
+		period.auxLatencies = append(period.auxLatencies, latency)
+		state.raisedAt = time.Time{}
+	}
+}
+
+// periodFor returns the accumulator for the period containing t
+func (a *StagingAnalyzer) periodFor(state *stagingState, t time.Time) *stagingPeriod {
+	start, end := periodWindow(t, a.config.Period, state.location)
+	period, ok := state.periods[start]
+	if !ok {
+		period = &stagingPeriod{end: end}
+		state.periods[start] = period
+	}
+	return period
+}
+
+// Flush emits analysis documents for periods that ended at least flushGrace
+// before now. A period is held back while a run or raise that started in it
+// may still stage up or be followed by aux heat.
+func (a *StagingAnalyzer) Flush(now time.Time) []*model.Analysis {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	cutoff := now.Add(-flushGrace)
+	var results []*model.Analysis
+
+	for thermostatID, state := range a.thermostats {
+		// Nothing more can extend a run with no following bin for the whole
+		// grace period
+		if !state.lastEventTime.Add(binSize).After(cutoff) {
+			state.heat, state.cool, state.raisedAt = stageRun{}, stageRun{}, time.Time{}
+		}
+
+		for start, period := range state.periods {
+			if period.end.After(cutoff) || state.pendingIn(start, period.end) {
+				continue
+			}
+			results = append(results, a.buildAnalysis(thermostatID, state, start, period))
+			delete(state.periods, start)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].ThermostatID != results[j].ThermostatID {
+			return results[i].ThermostatID < results[j].ThermostatID
+		}
+		return results[i].PeriodStart.Before(results[j].PeriodStart)
+	})
+
+	return results
+}
+
+// pendingIn reports whether an unstaged run or an unresolved setpoint raise
+// began inside the period
+func (s *stagingState) pendingIn(start, end time.Time) bool {
+	in := func(t time.Time) bool { return !t.IsZero() && !t.Before(start) && t.Before(end) }
+	return (in(s.heat.start) && !s.heat.staged) || (in(s.cool.start) && !s.cool.staged) || in(s.raisedAt)
+}
+
+// buildAnalysis converts a finished period into an analysis document
+func (a *StagingAnalyzer) buildAnalysis(thermostatID string, state *stagingState, start time.Time, period *stagingPeriod) *model.Analysis {
+	results := map[string]any{
+		"setpoint_raises":          period.setpointRaises,
+		"aux_after_setpoint_raise": len(period.auxLatencies),
+		"aux_window_minutes":       a.config.AuxWindow.Minutes(),
+	}
+	period.heat.addResults(results, "heat")
+	period.cool.addResults(results, "cool")
+	if period.setpointRaises > 0 {
+		results["aux_after_setpoint_raise_share"] = float64(len(period.auxLatencies)) / float64(period.setpointRaises)
+	}
+	if len(period.auxLatencies) > 0 {
+		results["aux_after_setpoint_raise_minutes_avg"] = averageMinutes(period.auxLatencies)
+	}
+
+	return &model.Analysis{
+		Type:           "analysis",
+		Analyzer:       StagingAnalyzerName,
+		ThermostatID:   thermostatID,
+		ThermostatName: state.name,
+		HouseholdID:    state.householdID,
+		PeriodStart:    start,
+		PeriodEnd:      period.end,
+		Results:        results,
+	}
+}
+
+// addResults adds the run counts and stage-up latencies for heating or
+// cooling, prefixed with the mode
+func (c *stageCounts) addResults(results map[string]any, prefix string) {
+	results[prefix+"_runs"] = c.runs
+	results[prefix+"_stage2_runs"] = len(c.latencies)
+	if c.runs > 0 {
+		results[prefix+"_stage2_share"] = float64(len(c.latencies)) / float64(c.runs)
+	}
+	if len(c.latencies) > 0 {
+		results[prefix+"_stage_up_minutes_avg"] = averageMinutes(c.latencies)
+		results[prefix+"_stage_up_minutes_median"] = medianMinutes(c.latencies)
+	}
+}
+
+// averageMinutes returns the mean of durations in minutes
+func averageMinutes(durations []time.Duration) float64 {
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+	return sum.Minutes() / float64(len(durations))
+}
+
+// medianMinutes returns the median of durations in minutes
+func medianMinutes(durations []time.Duration) float64 {
+	sorted := slices.Clone(durations)
+	slices.Sort(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid].Minutes()
+	}
+	return (sorted[mid-1] + sorted[mid]).Minutes() / 2
+}
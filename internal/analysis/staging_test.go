@@ -0,0 +1,144 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestStagingAnalyzer(t *testing.T) {
+	day := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	at := func(bin int) time.Time { return day.Add(6*time.Hour + time.Duration(bin)*binSize) }
+	withSetpoint := func(row *model.Runtime5m, setHeatC float64) *model.Runtime5m {
+		row.SetHeatC = &setHeatC
+		return row
+	}
+
+	tests := []struct {
+		name string
+		rows func() []*model.Runtime5m
+		want map[string]any
+	}{
+		{
+			name: "stage 2 engages after 15 minutes",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					heatRow(at(0), -5, "compHeat1"),
+					heatRow(at(1), -5, "compHeat1"),
+					heatRow(at(2), -5, "compHeat1"),
+					heatRow(at(3), -5, "compHeat1", "compHeat2"),
+					heatRow(at(4), -5, "compHeat1", "compHeat2"),
+					heatRow(at(5), -5),
+					heatRow(at(6), -5, "compHeat1"),
+				}
+			},
+			want: map[string]any{
+				"heat_runs":                    2,
+				"heat_stage2_runs":             1,
+				"heat_stage2_share":            0.5,
+				"heat_stage_up_minutes_avg":    15.0,
+				"heat_stage_up_minutes_median": 15.0,
+				"cool_runs":                    0,
+			},
+		},
+		{
+			name: "partial stage 2 bin shortens the latency",
+			rows: func() []*model.Runtime5m {
+				staged := heatRow(at(1), -5, "compHeat1", "compHeat2")
+				staged.EquipmentSecs = map[string]int{"compHeat1": 300, "compHeat2": 60}
+				return []*model.Runtime5m{heatRow(at(0), -5, "compHeat1"), staged}
+			},
+			want: map[string]any{
+				"heat_runs":                 1,
+				"heat_stage_up_minutes_avg": 9.0,
+			},
+		},
+		{
+			name: "missing bin ends the run",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					heatRow(at(0), -5, "compHeat1"),
+					heatRow(at(2), -5, "compHeat1", "compHeat2"),
+				}
+			},
+			want: map[string]any{
+				"heat_runs":                 2,
+				"heat_stage_up_minutes_avg": 0.0,
+			},
+		},
+		{
+			name: "aux heat after a setpoint raise",
+			rows: func() []*model.Runtime5m {
+				return []*model.Runtime5m{
+					withSetpoint(heatRow(at(0), -5), 18),
+					withSetpoint(heatRow(at(1), -5, "compHeat1"), 21),
+					withSetpoint(heatRow(at(2), -5, "compHeat1"), 21),
+					withSetpoint(heatRow(at(3), -5, "compHeat1", "auxHeat1"), 21),
+					// Lowered and raised again; aux comes too late
+					withSetpoint(heatRow(at(20), -5), 17),
+					withSetpoint(heatRow(at(21), -5, "compHeat1"), 19),
+					withSetpoint(heatRow(at(40), -5, "compHeat1", "auxHeat1"), 19),
+				}
+			},
+			want: map[string]any{
+				"setpoint_raises":                      2,
+				"aux_after_setpoint_raise":             1,
+				"aux_after_setpoint_raise_share":       0.5,
+				"aux_after_setpoint_raise_minutes_avg": 10.0,
+				"aux_window_minutes":                   30.0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer := NewStagingAnalyzer(DefaultStagingConfig())
+			for _, row := range tt.rows() {
+				analyzer.Observe(row)
+			}
+
+			if results := analyzer.Flush(day.Add(24 * time.Hour)); len(results) != 0 {
+				t.Fatalf("Expected no analysis before grace period, got %d", len(results))
+			}
+			results := analyzer.Flush(day.Add(26 * time.Hour))
+			if len(results) != 1 {
+				t.Fatalf("Expected 1 analysis, got %d", len(results))
+			}
+			analysis := results[0]
+			if analysis.Analyzer != StagingAnalyzerName || analysis.ThermostatID != "t1" || !analysis.PeriodStart.Equal(day) {
+				t.Errorf("Unexpected analysis %+v", analysis)
+			}
+			for key, want := range tt.want {
+				if got := analysis.Results[key]; got != want {
+					t.Errorf("Expected %s %v, got %v", key, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestStagingAnalyzerHoldsPendingRaise(t *testing.T) {
+	day := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := NewStagingAnalyzer(DefaultStagingConfig())
+	withSetpoint := func(row *model.Runtime5m, setHeatC float64) *model.Runtime5m {
+		row.SetHeatC = &setHeatC
+		return row
+	}
+
+	// A raise just before midnight is followed by aux heat just after it
+	analyzer.Observe(withSetpoint(heatRow(day.Add(24*time.Hour-2*binSize), -5), 18))
+	analyzer.Observe(withSetpoint(heatRow(day.Add(24*time.Hour-binSize), -5, "compHeat1"), 21))
+	analyzer.Observe(withSetpoint(heatRow(day.Add(24*time.Hour), -5, "compHeat1"), 21))
+	if results := analyzer.Flush(day.Add(25 * time.Hour)); len(results) != 0 {
+		t.Fatalf("Expected the period held for the pending raise, got %+v", results)
+	}
+	analyzer.Observe(withSetpoint(heatRow(day.Add(24*time.Hour+binSize), -5, "compHeat1", "auxHeat1"), 21))
+
+	results := analyzer.Flush(day.Add(26 * time.Hour))
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 analysis, got %d", len(results))
+	}
+	if got := results[0].Results["aux_after_setpoint_raise"]; got != 1 {
+		t.Errorf("Expected aux heat attributed to the raise, got %v", got)
+	}
+}
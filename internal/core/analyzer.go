@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Analyzer derives periodic analysis documents from normalized runtime data.
+// The scheduler feeds every runtime row it writes to each analyzer and
+// collects finished periods after each cycle.
+type Analyzer interface {
+	// Name identifies the analyzer in logs and analysis documents
+	Name() string
+
+	// Observe records a normalized runtime row
+	Observe(row *model.Runtime5m)
+
+	// Flush returns analysis documents for periods that are complete as of now
+	Flush(now time.Time) []*model.Analysis
+}
+
+// EventObserver is implemented by analyzers that also need provider events
+// (holds, vacations) from device snapshots
+type EventObserver interface {
+	// ObserveEvents records the events reported by a thermostat's latest snapshot
+	ObserveEvents(thermostatID string, events []model.Event, now time.Time)
+}
+
+// Recommender is implemented by analyzers that also draw recommendations
+// from the periods they flush
+type Recommender interface {
+	// Recommendations returns the recommendations drawn since the last call
+	Recommendations() []*model.Recommendation
+}
+
+// PollObserver is implemented by analyzers that also need poll outcomes
+type PollObserver interface {
+	// ObservePoll records whether polling a thermostat failed
+	ObservePoll(thermostatID string, failed bool, now time.Time)
+}
+
+// observePoll feeds a thermostat's poll outcome to analyzers that track them
+func (s *Scheduler) observePoll(thermostatID string, failed bool, now time.Time) {
+	for _, analyzer := range s.analyzers {
+		if observer, ok := analyzer.(PollObserver); ok {
+			observer.ObservePoll(thermostatID, failed, now)
+		}
+	}
+}
+
+// TimezoneObserver is implemented by analyzers whose periods follow each
+// thermostat's local calendar
+type TimezoneObserver interface {
+	// ObserveTimezone records the timezone a thermostat is in
+	ObserveTimezone(thermostatID string, loc *time.Location)
+}
+
+// observeTimezone passes a thermostat's reported timezone to analyzers that
+// track them. Unknown zone names are logged and ignored.
+func (s *Scheduler) observeTimezone(thermostatID, timezone string) {
+	if timezone == "" {
+		return
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		s.logger.Warn("Ignoring unknown thermostat timezone", "thermostat", thermostatID, "timezone", timezone, "error", err)
+		return
+	}
+	for _, analyzer := range s.analyzers {
+		if observer, ok := analyzer.(TimezoneObserver); ok {
+			observer.ObserveTimezone(thermostatID, loc)
+		}
+	}
+}
+
+// observeEvents feeds snapshot events to analyzers that track them
+func (s *Scheduler) observeEvents(thermostatID string, events []model.Event, now time.Time) {
+	for _, analyzer := range s.analyzers {
+		if observer, ok := analyzer.(EventObserver); ok {
+			observer.ObserveEvents(thermostatID, events, now)
+		}
+	}
+}
+
+// observeRuntime feeds a normalized runtime row to all registered analyzers
+// and records equipment activity for the scheduling strategy
+func (s *Scheduler) observeRuntime(row *model.Runtime5m) {
+	s.recordActivity(row)
+	for _, analyzer := range s.analyzers {
+		analyzer.Observe(row)
+	}
+}
+
+// flushAnalyzers collects completed analysis periods, and recommendations
+// drawn from them, and writes them to all sinks
+func (s *Scheduler) flushAnalyzers(ctx context.Context, now time.Time) {
+	var docs []model.Doc
+	for _, analyzer := range s.analyzers {
+		for _, analysis := range analyzer.Flush(now) {
+			docID, err := s.idGenerator.GenerateAnalysisID(analysis)
+			if err != nil {
+				s.logger.Error("Failed to generate document ID for analysis", "analyzer", analyzer.Name(), "error", err)
+				continue
+			}
+			docs = append(docs, model.Doc{
+				ID:   docID,
+				Type: "analysis",
+				Body: analysis,
+			})
+		}
+
+		recommender, ok := analyzer.(Recommender)
+		if !ok {
+			continue
+		}
+		for _, recommendation := range recommender.Recommendations() {
+			docID, err := s.idGenerator.GenerateRecommendationID(recommendation)
+			if err != nil {
+				s.logger.Error("Failed to generate document ID for recommendation", "analyzer", analyzer.Name(), "error", err)
+				continue
+			}
+			docs = append(docs, model.Doc{
+				ID:   docID,
+				Type: "recommendation",
+				Body: recommendation,
+			})
+		}
+	}
+
+	if len(docs) == 0 {
+		return
+	}
+
+	s.logger.Debug("Writing analysis documents", "count", len(docs))
+	if err := s.writeToAllSinks(ctx, docs); err != nil {
+		s.logger.Error("Failed to write analysis documents", "error", err)
+	}
+}
@@ -0,0 +1,151 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// stubAnalyzer records observed rows and returns a fixed analysis on flush
+type stubAnalyzer struct {
+	observed []*model.Runtime5m
+	pending  []*model.Analysis
+}
+
+func (a *stubAnalyzer) Name() string { return "stub" }
+
+func (a *stubAnalyzer) Observe(row *model.Runtime5m) {
+	a.observed = append(a.observed, row)
+}
+
+func (a *stubAnalyzer) Flush(now time.Time) []*model.Analysis {
+	flushed := a.pending
+	a.pending = nil
+	return flushed
+}
+
+// eventAnalyzer is a stub analyzer that also observes snapshot events
+type eventAnalyzer struct {
+	stubAnalyzer
+	events []model.Event
+}
+
+func (a *eventAnalyzer) ObserveEvents(thermostatID string, events []model.Event, now time.Time) {
+	a.events = append(a.events, events...)
+}
+
+// zoneAnalyzer is a stub analyzer that also observes thermostat timezones
+type zoneAnalyzer struct {
+	stubAnalyzer
+	zones map[string]*time.Location
+}
+
+func (a *zoneAnalyzer) ObserveTimezone(thermostatID string, loc *time.Location) {
+	a.zones[thermostatID] = loc
+}
+
+// recommendingAnalyzer is a stub analyzer that also draws recommendations
+type recommendingAnalyzer struct {
+	stubAnalyzer
+	recommendations []*model.Recommendation
+}
+
+func (a *recommendingAnalyzer) Recommendations() []*model.Recommendation {
+	drawn := a.recommendations
+	a.recommendations = nil
+	return drawn
+}
+
+// recordingSink captures written documents
+type recordingSink struct {
+	mockSink
+	docs []model.Doc
+}
+
+func (s *recordingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	s.docs = append(s.docs, docs...)
+	return model.WriteResult{SuccessCount: len(docs)}, nil
+}
+
+func TestFlushAnalyzers(t *testing.T) {
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	analyzer := &stubAnalyzer{
+		pending: []*model.Analysis{{
+			Type:         "analysis",
+			Analyzer:     "stub",
+			ThermostatID: "t1",
+			PeriodStart:  start,
+			PeriodEnd:    start.Add(24 * time.Hour),
+			Results:      map[string]any{"value": 1},
+		}},
+	}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, NewMemoryOffsetStore(), WithAnalyzers(analyzer))
+
+	t.Run("observe fans out to analyzers", func(t *testing.T) {
+		scheduler.observeRuntime(&model.Runtime5m{ThermostatID: "t1", EventTime: start})
+		if len(analyzer.observed) != 1 {
+			t.Errorf("Expected 1 observed row, got %d", len(analyzer.observed))
+		}
+	})
+
+	t.Run("flush writes analysis documents", func(t *testing.T) {
+		scheduler.flushAnalyzers(testContext(t), start.Add(48*time.Hour))
+		if len(sink.docs) != 1 {
+			t.Fatalf("Expected 1 document written, got %d", len(sink.docs))
+		}
+		doc := sink.docs[0]
+		if doc.Type != "analysis" {
+			t.Errorf("Expected document type analysis, got %s", doc.Type)
+		}
+		if doc.ID == "" {
+			t.Error("Expected a deterministic document ID")
+		}
+	})
+
+	t.Run("empty flush writes nothing", func(t *testing.T) {
+		scheduler.flushAnalyzers(testContext(t), start.Add(72*time.Hour))
+		if len(sink.docs) != 1 {
+			t.Errorf("Expected no additional documents, got %d total", len(sink.docs))
+		}
+	})
+}
+
+func TestFlushAnalyzersRecommendations(t *testing.T) {
+	start := time.Date(2025, 1, 6, 0, 0, 0, 0, time.UTC)
+	analyzer := &recommendingAnalyzer{
+		recommendations: []*model.Recommendation{{
+			Type:         "recommendation",
+			Kind:         model.RecommendationLowerHeatSetpoint,
+			ThermostatID: "t1",
+			PeriodStart:  start,
+			PeriodEnd:    start.Add(7 * 24 * time.Hour),
+			Climate:      "Sleep",
+		}},
+	}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, NewMemoryOffsetStore(), WithAnalyzers(analyzer))
+
+	scheduler.flushAnalyzers(testContext(t), start.Add(8*24*time.Hour))
+	if len(sink.docs) != 1 {
+		t.Fatalf("Expected 1 document written, got %d", len(sink.docs))
+	}
+	doc := sink.docs[0]
+	if doc.Type != "recommendation" || doc.ID != "t1:recommendation:lower_heat_setpoint:Sleep:2025-01-06T00:00:00Z" {
+		t.Errorf("Unexpected document %s of type %s", doc.ID, doc.Type)
+	}
+}
+
+func TestObserveEvents(t *testing.T) {
+	plain := &stubAnalyzer{}
+	observer := &eventAnalyzer{}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithAnalyzers(plain, observer))
+
+	scheduler.observeEvents("t1", []model.Event{{Kind: "hold", Running: true}}, time.Now())
+
+	if len(observer.events) != 1 {
+		t.Errorf("Expected events to reach the event observer, got %d", len(observer.events))
+	}
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// AnomalyDetector checks normalized runtime rows for sensor faults or HVAC
+// conflicts
+type AnomalyDetector interface {
+	// Observe records a runtime row and returns alerts for faults it reveals
+	Observe(row *model.Runtime5m) []*model.Alert
+}
+
+// WithAnomalyDetector registers a detector that receives every normalized
+// runtime row; its alerts are written as alert documents. Detectors run in
+// the order they are registered.
+func WithAnomalyDetector(detector AnomalyDetector) SchedulerOption {
+	return func(s *Scheduler) {
+		s.anomalies = append(s.anomalies, detector)
+	}
+}
+
+// detectAnomalies returns alert documents for the faults a runtime row reveals
+func (s *Scheduler) detectAnomalies(row *model.Runtime5m) []model.Doc {
+	var docs []model.Doc
+	for _, detector := range s.anomalies {
+		for _, alert := range detector.Observe(row) {
+			docID, err := s.idGenerator.GenerateAlertID(alert)
+			if err != nil {
+				s.logger.Error("Failed to generate document ID for alert", "error", err)
+				continue
+			}
+			s.metrics.RecordAlert(alert.Kind)
+			s.logger.Warn("Anomaly detected",
+				"thermostat", alert.ThermostatID,
+				"household", alert.HouseholdID,
+				"sensor", alert.SensorID,
+				"kind", alert.Kind,
+				"message", alert.Message)
+			s.notifyAlert(alert)
+			docs = append(docs, model.Doc{
+				ID:   docID,
+				Type: "alert",
+				Body: alert,
+			})
+		}
+	}
+	return docs
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// stubDetector raises one alert for every row it sees
+type stubDetector struct{}
+
+func (stubDetector) Observe(row *model.Runtime5m) []*model.Alert {
+	return []*model.Alert{{
+		Type:         "alert",
+		Kind:         model.AlertKindStuck,
+		EventTime:    row.EventTime,
+		ThermostatID: row.ThermostatID,
+		SensorID:     "rs1",
+	}}
+}
+
+// conflictStub raises one household alert for every row it sees
+type conflictStub struct{}
+
+func (conflictStub) Observe(row *model.Runtime5m) []*model.Alert {
+	return []*model.Alert{{
+		Type:        "alert",
+		Kind:        model.AlertKindHeatCoolConflict,
+		EventTime:   row.EventTime,
+		HouseholdID: "h1",
+	}}
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	row := &model.Runtime5m{ThermostatID: "t1", EventTime: time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)}
+
+	t.Run("alerts become documents and metrics", func(t *testing.T) {
+		scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithAnomalyDetector(stubDetector{}))
+
+		docs := scheduler.detectAnomalies(row)
+		if len(docs) != 1 {
+			t.Fatalf("Expected 1 alert document, got %d", len(docs))
+		}
+		if docs[0].Type != "alert" || docs[0].ID != "t1:alert:rs1:sensor_stuck:2025-01-10T12:00:00Z" {
+			t.Errorf("Unexpected document %s of type %s", docs[0].ID, docs[0].Type)
+		}
+		if got := scheduler.metrics.GetMetrics().Alerts[model.AlertKindStuck]; got != 1 {
+			t.Errorf("Expected 1 stuck alert in metrics, got %d", got)
+		}
+	})
+
+	t.Run("every detector sees the row", func(t *testing.T) {
+		scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore(),
+			WithAnomalyDetector(stubDetector{}), WithAnomalyDetector(conflictStub{}))
+
+		docs := scheduler.detectAnomalies(row)
+		if len(docs) != 2 {
+			t.Fatalf("Expected 2 alert documents, got %d", len(docs))
+		}
+		if docs[1].ID != "h1:alert::heat_cool_conflict:2025-01-10T12:00:00Z" {
+			t.Errorf("Unexpected household alert ID %s", docs[1].ID)
+		}
+	})
+
+	t.Run("no detector means no alerts", func(t *testing.T) {
+		scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore())
+		if docs := scheduler.detectAnomalies(row); len(docs) != 0 {
+			t.Errorf("Expected no documents, got %d", len(docs))
+		}
+	})
+}
@@ -0,0 +1,335 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// BackfillPolicy decides what happens when part of the initial backfill fails
+type BackfillPolicy string
+
+const (
+	// BackfillAbort stops the scheduler on the first failure
+	BackfillAbort BackfillPolicy = "abort"
+	// BackfillSkip logs the failure and continues with the next thermostat or provider
+	BackfillSkip BackfillPolicy = "skip"
+	// BackfillRetry retries the failed step with exponential backoff, then skips it
+	BackfillRetry BackfillPolicy = "retry"
+)
+
+// backfillChunk is the longest range requested from a provider in one
+// backfill call. Offsets are recorded after each chunk, and cancellation is
+// checked between chunks.
+const backfillChunk = 7 * 24 * time.Hour
+
+// backfillRetryConfig is the backoff used by the retry policy
+var backfillRetryConfig = retry.Config{
+	MaxRetries:   3,
+	InitialDelay: 30 * time.Second,
+	MaxDelay:     5 * time.Minute,
+	Multiplier:   2.0,
+	Jitter:       true,
+}
+
+// WithBackfillPolicy sets how initial backfill failures are handled
+func WithBackfillPolicy(policy BackfillPolicy) SchedulerOption {
+	return func(s *Scheduler) {
+		s.backfillPolicy = policy
+	}
+}
+
+// backfillStep runs one backfill step, retrying it with backoff under the
+// retry policy. Throttled steps are not retried: the throttle is recorded and
+// the provider is picked up again once it expires.
+func (s *Scheduler) backfillStep(ctx context.Context, step func() error) error {
+	err := step()
+	if err == nil || s.backfillPolicy != BackfillRetry {
+		return err
+	}
+
+	for attempt := 1; attempt <= s.backfillRetry.MaxRetries; attempt++ {
+		var throttled *retry.ThrottledError
+		if errors.As(err, &throttled) {
+			return err
+		}
+
+		delay := s.backfillRetry.Backoff(attempt)
+		s.logger.Warn("Backfill step failed, retrying", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("backfill cancelled: %w", ctx.Err())
+		case <-time.After(delay):
+		}
+
+		if err = step(); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// abortBackfill reports whether a failure ends the initial backfill: always
+// under the abort policy, and under any policy once the context is cancelled
+func (s *Scheduler) abortBackfill(ctx context.Context) bool {
+	return s.backfillPolicy == BackfillAbort || ctx.Err() != nil
+}
+
+// WithBackfillPacing spreads backfill over polling cycles instead of running
+// it before polling starts. After each cycle's polls, backfill makes at most
+// maxRequestsPerCycle provider requests, and none that a provider's request
+// budget needs for polling. Live polls keep running in between.
+func WithBackfillPacing(maxRequestsPerCycle int) SchedulerOption {
+	return func(s *Scheduler) {
+		s.backfillPacing = maxRequestsPerCycle
+	}
+}
+
+// Backfill performs the initial backfill on its own, without polling
+// afterwards, and flushes analyzers. Pacing is ignored so that every
+// thermostat is filled before it returns.
+func (s *Scheduler) Backfill(ctx context.Context) error {
+	pacing := s.backfillPacing
+	s.backfillPacing = 0
+	defer func() {
+		s.backfillPacing = pacing
+	}()
+
+	s.startCycle(PhaseBackfilling, time.Now())
+	if err := s.performInitialBackfill(ctx); err != nil {
+		return err
+	}
+	s.flushAnalyzers(ctx, time.Now())
+	return nil
+}
+
+// WithBackfillOverwriteWindow makes the initial backfill resume from each
+// thermostat's stored runtime offset instead of re-fetching the whole backfill
+// window, starting window before the offset. Providers revise recent data
+// (e.g. late sensor uploads), so the trailing window is fetched again on
+// every startup and overwrites the stored documents through their
+// deterministic IDs, while older data already stored is not requested again.
+func WithBackfillOverwriteWindow(window time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.overwriteWindow = window
+	}
+}
+
+// backfillJob is a thermostat's queued paced backfill
+type backfillJob struct {
+	provider   model.Provider
+	thermostat model.ThermostatRef
+	// next is where the next chunk starts; the job is done once it reaches to
+	next, to time.Time
+	// metadataLoaded is set once location metadata has been fetched, so
+	// backfilled rows are enriched
+	metadataLoaded bool
+	failures       int
+}
+
+// catchUpStart returns where a thermostat's initial backfill starts: the
+// window start, or its stored runtime offset when the process was down for
+// longer than the backfill window, so the gap is caught up rather than
+// skipped. With an overwrite window, a thermostat whose offset lies within
+// the backfill window starts that long before its offset instead.
+func (s *Scheduler) catchUpStart(ctx context.Context, thermostat model.ThermostatRef, windowStart, now time.Time) time.Time {
+	lastRuntime, err := s.offsetStore.GetLastRuntimeTime(ctx, thermostat.ID)
+	if err != nil {
+		s.logger.Warn("Failed to get last runtime time, backfilling the configured window", "thermostat", thermostat.ID, "error", err)
+		return windowStart
+	}
+	if lastRuntime.IsZero() {
+		return windowStart
+	}
+	if !lastRuntime.Before(windowStart) {
+		if s.overwriteWindow <= 0 {
+			return windowStart
+		}
+		start := lastRuntime.Add(-s.overwriteWindow)
+		if start.Before(windowStart) {
+			start = windowStart
+		}
+		s.logger.Debug("Resuming backfill before stored offset",
+			"thermostat", thermostat.ID,
+			"last_runtime", lastRuntime,
+			"overwrite_window", s.overwriteWindow)
+		return start
+	}
+
+	s.logger.Warn("Extended downtime detected, catching up from stored offset",
+		"thermostat", thermostat.ID,
+		"last_runtime", lastRuntime,
+		"gap", now.Sub(lastRuntime).Round(time.Minute),
+		"backfill_window", s.backfillWindow)
+	return lastRuntime
+}
+
+// startBackfill backfills a thermostat from from to to, or queues it when
+// backfill is paced. It reports whether the backfill was queued.
+func (s *Scheduler) startBackfill(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, from, to time.Time) (bool, error) {
+	if s.backfillPacing <= 0 {
+		return false, s.backfillThermostat(ctx, provider, thermostat, from, to)
+	}
+	if s.backfillQueued(thermostat.ID) {
+		return true, nil
+	}
+
+	s.logger.Info("Queueing paced backfill",
+		"thermostat", thermostat.ID,
+		"from", from,
+		"to", to)
+	s.backfillQueue = append(s.backfillQueue, &backfillJob{
+		provider:   provider,
+		thermostat: thermostat,
+		next:       from,
+		to:         to,
+	})
+	s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, ThermostatBackfilling)
+	return true, nil
+}
+
+// seedRuntimeOffset starts a thermostat's runtime offset at the end of a
+// backfill that found no rows. Polling only fetches runtime from a stored
+// offset, so a thermostat without history, such as one of a provider that
+// samples runtime from its polls, would otherwise never be fetched again. The
+// offset is seeded a bin before the bin in progress at end, less the settling
+// delay, so rows still to come are fetched once they close.
+func (s *Scheduler) seedRuntimeOffset(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, end time.Time) {
+	lastRuntime, err := s.offsetStore.GetLastRuntimeTime(ctx, thermostat.ID)
+	if err != nil || !lastRuntime.IsZero() {
+		return
+	}
+	seed := end.Add(-s.settlingDelays[provider.Info().Name]).Truncate(runtimeBin).Add(-runtimeBin)
+	s.logger.Debug("Seeding runtime offset after a backfill without rows", "thermostat", thermostat.ID, "offset", seed)
+	if err := s.offsetStore.SetLastRuntimeTime(ctx, thermostat.ID, seed); err != nil {
+		s.logger.Error("Failed to seed runtime offset", "thermostat", thermostat.ID, "error", err)
+	}
+}
+
+// backfillQueued reports whether a thermostat has a paced backfill pending.
+// Polling leaves such thermostats' runtime to the backfill, which advances
+// their offset chunk by chunk.
+func (s *Scheduler) backfillQueued(thermostatID string) bool {
+	return slices.ContainsFunc(s.backfillQueue, func(job *backfillJob) bool {
+		return job.thermostat.ID == thermostatID
+	})
+}
+
+// runPacedBackfill works through queued backfills after a polling cycle,
+// within the per-cycle request limit. Providers that are in maintenance,
+// throttled, or without spare request budget are left for a later cycle.
+func (s *Scheduler) runPacedBackfill(ctx context.Context) {
+	if len(s.backfillQueue) == 0 {
+		return
+	}
+	if scope, until := s.firstThrottledSink(ctx); !until.IsZero() {
+		s.logger.Debug("Deferring paced backfill while sink is throttled", "scope", scope, "until", until)
+		return
+	}
+
+	s.backfillRunning = true
+	defer func() {
+		s.backfillRunning = false
+	}()
+
+	requests := 0
+	blocked := make(map[string]bool)
+	deferred := make(map[*backfillJob]bool)
+	for requests < s.backfillPacing && ctx.Err() == nil {
+		index := slices.IndexFunc(s.backfillQueue, func(job *backfillJob) bool {
+			return !blocked[job.provider.Info().Name] && !deferred[job]
+		})
+		if index < 0 {
+			break
+		}
+		job := s.backfillQueue[index]
+		name := job.provider.Info().Name
+		if !s.backfillAllowed(ctx, job.provider) {
+			blocked[name] = true
+			continue
+		}
+
+		requests++
+		err := s.backfillJobStep(ctx, job)
+		switch {
+		case err == nil && !job.next.Before(job.to):
+			s.seedRuntimeOffset(ctx, job.provider, job.thermostat, job.to)
+			s.backfillQueue = slices.Delete(s.backfillQueue, index, index+1)
+			s.metrics.RecordThermostatStatus(name, job.thermostat.ID, ThermostatOK)
+			s.logger.Info("Paced backfill complete", "provider", name, "thermostat", job.thermostat.ID)
+		case err == nil:
+		case s.recordThrottle(ctx, providerScope(job.provider), err):
+			blocked[name] = true
+			s.metrics.RecordProviderStatus(name, ThermostatThrottled)
+		default:
+			job.failures++
+			if s.backfillPolicy == BackfillRetry && job.failures <= s.backfillRetry.MaxRetries {
+				s.logger.Warn("Paced backfill step failed, retrying next cycle",
+					"provider", name,
+					"thermostat", job.thermostat.ID,
+					"attempt", job.failures,
+					"error", err)
+				deferred[job] = true
+				continue
+			}
+			s.logger.Error("Failed to backfill thermostat",
+				"provider", name,
+				"thermostat", job.thermostat.ID,
+				"error", err)
+			s.backfillQueue = slices.Delete(s.backfillQueue, index, index+1)
+			s.metrics.RecordThermostatStatus(name, job.thermostat.ID, ThermostatFailed)
+		}
+	}
+
+	s.logger.Debug("Paced backfill cycle finished", "requests", requests, "queued", len(s.backfillQueue))
+}
+
+// backfillAllowed reports whether a paced backfill request to provider may
+// be made now
+func (s *Scheduler) backfillAllowed(ctx context.Context, provider model.Provider) bool {
+	now := time.Now()
+	if s.inMaintenance(provider, now) {
+		return false
+	}
+	if until := s.throttledUntil(ctx, providerScope(provider)); !until.IsZero() {
+		return false
+	}
+	if budget, ok := s.budgets[provider.Info().Name]; ok && budget.spare(now) <= 0 {
+		return false
+	}
+	return true
+}
+
+// backfillJobStep makes one request for a paced backfill: the metadata
+// refresh first, for providers that serve metadata, then one chunk at a time
+func (s *Scheduler) backfillJobStep(ctx context.Context, job *backfillJob) error {
+	if !job.metadataLoaded {
+		job.metadataLoaded = true
+		if err := s.refreshMetadata(ctx, job.provider, job.thermostat); err != nil {
+			s.logger.Warn("Failed to refresh device metadata", "thermostat", job.thermostat.ID, "error", err)
+			var throttled *retry.ThrottledError
+			if errors.As(err, &throttled) {
+				job.metadataLoaded = false
+				return err
+			}
+		}
+		if _, ok := job.provider.(model.MetadataProvider); ok {
+			return nil
+		}
+	}
+
+	chunkEnd := job.next.Add(backfillChunk)
+	if chunkEnd.After(job.to) {
+		chunkEnd = job.to
+	}
+	if err := s.backfillRange(ctx, job.provider, job.thermostat, job.next, chunkEnd); err != nil {
+		return err
+	}
+	job.next = chunkEnd
+	return nil
+}
@@ -0,0 +1,301 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// flakyRuntimeProvider fails its first runtime requests and records the
+// ranges it was asked for
+type flakyRuntimeProvider struct {
+	mockProvider
+	failures int
+	ranges   [][2]time.Time
+}
+
+func (p *flakyRuntimeProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	p.ranges = append(p.ranges, [2]time.Time{from, to})
+	if p.failures > 0 {
+		p.failures--
+		return nil, errors.New("runtime report unavailable")
+	}
+	return nil, nil
+}
+
+func TestInitialBackfillPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      BackfillPolicy
+		failures    int
+		expectError bool
+		expectCalls int
+	}{
+		{name: "skip continues past a failure", policy: BackfillSkip, failures: 1, expectCalls: 1},
+		{name: "abort stops on the first failure", policy: BackfillAbort, failures: 1, expectError: true, expectCalls: 1},
+		{name: "retry recovers from a transient failure", policy: BackfillRetry, failures: 2, expectCalls: 3},
+		{name: "retry gives up after max retries", policy: BackfillRetry, failures: 10, expectCalls: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}, failures: tt.failures}
+			scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithBackfillPolicy(tt.policy))
+			scheduler.backfillRetry = retry.Config{MaxRetries: 3, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1}
+
+			err := scheduler.performInitialBackfill(testContext(t))
+			if tt.expectError && err == nil {
+				t.Error("Expected backfill to fail")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if len(provider.ranges) != tt.expectCalls {
+				t.Errorf("Expected %d runtime requests, got %d", tt.expectCalls, len(provider.ranges))
+			}
+		})
+	}
+}
+
+func TestInitialBackfillChunksAndCancellation(t *testing.T) {
+	t.Run("long windows are fetched in chunks", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore())
+		scheduler.backfillWindow = 2*backfillChunk + time.Hour
+
+		if err := scheduler.performInitialBackfill(testContext(t)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(provider.ranges) != 3 {
+			t.Fatalf("Expected 3 chunks, got %d", len(provider.ranges))
+		}
+		for i := 1; i < len(provider.ranges); i++ {
+			if !provider.ranges[i][0].Equal(provider.ranges[i-1][1]) {
+				t.Errorf("Expected chunk %d to start where chunk %d ended", i, i-1)
+			}
+		}
+		if last := provider.ranges[2]; last[1].Sub(last[0]) != time.Hour {
+			t.Errorf("Expected the last chunk to cover the remaining hour, got %v", last[1].Sub(last[0]))
+		}
+	})
+
+	t.Run("cancelled context stops before fetching", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithBackfillPolicy(BackfillRetry))
+
+		ctx, cancel := context.WithCancel(testContext(t))
+		cancel()
+
+		err := scheduler.performInitialBackfill(ctx)
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected a cancellation error, got %v", err)
+		}
+		if len(provider.ranges) != 0 {
+			t.Errorf("Expected no runtime requests, got %d", len(provider.ranges))
+		}
+	})
+}
+
+func TestPacedBackfill(t *testing.T) {
+	t.Run("requests are spread across cycles", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithBackfillPacing(2))
+		scheduler.backfillWindow = 3 * backfillChunk
+		ctx := testContext(t)
+
+		if err := scheduler.performInitialBackfill(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(provider.ranges) != 0 || !scheduler.backfillQueued("therm-1") {
+			t.Fatalf("Expected the backfill to be queued without requests, got %d", len(provider.ranges))
+		}
+
+		expected := []int{2, 3}
+		for cycle, calls := range expected {
+			scheduler.runPacedBackfill(ctx)
+			if len(provider.ranges) != calls {
+				t.Errorf("Cycle %d: expected %d runtime requests, got %d", cycle+1, calls, len(provider.ranges))
+			}
+		}
+		if scheduler.backfillQueued("therm-1") {
+			t.Error("Expected the queue to drain")
+		}
+		if counts := scheduler.metrics.GetMetrics().Scheduler.Thermostats; counts[ThermostatOK] != 1 || counts[ThermostatBackfilling] != 0 {
+			t.Errorf("Expected the thermostat to be ok after the backfill, got %v", counts)
+		}
+	})
+
+	t.Run("polling leaves queued thermostats to the backfill", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, store, WithBackfillPacing(1))
+		ctx := testContext(t)
+		if err := store.SetLastRuntimeTime(ctx, "therm-1", time.Now().Add(-time.Hour)); err != nil {
+			t.Fatalf("Failed to set offset: %v", err)
+		}
+
+		if _, err := scheduler.startBackfill(ctx, provider, model.ThermostatRef{ID: "therm-1"}, time.Now().Add(-time.Hour), time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(provider.ranges) != 0 {
+			t.Errorf("Expected no runtime requests while queued, got %d", len(provider.ranges))
+		}
+	})
+
+	t.Run("spare request budget limits backfill", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(),
+			WithBackfillPacing(10), WithRequestBudget("test", RequestBudget{PerHour: 1000}))
+		ctx := testContext(t)
+		budget := scheduler.budgets["test"]
+		budget.record(time.Now(), false)
+		budget.hourUsed = 1000
+
+		if _, err := scheduler.startBackfill(ctx, provider, model.ThermostatRef{ID: "therm-1"}, time.Now().Add(-time.Hour), time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		scheduler.runPacedBackfill(ctx)
+		if len(provider.ranges) != 0 {
+			t.Errorf("Expected no runtime requests without spare budget, got %d", len(provider.ranges))
+		}
+	})
+
+	t.Run("failed steps follow the retry policy", func(t *testing.T) {
+		provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}, failures: 10}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(),
+			WithBackfillPacing(5), WithBackfillPolicy(BackfillRetry))
+		scheduler.backfillRetry = retry.Config{MaxRetries: 2}
+		ctx := testContext(t)
+
+		if _, err := scheduler.startBackfill(ctx, provider, model.ThermostatRef{ID: "therm-1"}, time.Now().Add(-time.Hour), time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for cycle := 1; cycle <= 3; cycle++ {
+			scheduler.runPacedBackfill(ctx)
+			if len(provider.ranges) != cycle {
+				t.Errorf("Cycle %d: expected one attempt per cycle, got %d in total", cycle, len(provider.ranges))
+			}
+		}
+		if scheduler.backfillQueued("therm-1") {
+			t.Error("Expected the job to be dropped after max retries")
+		}
+	})
+}
+
+func TestBackfillIgnoresPacing(t *testing.T) {
+	provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+	scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithBackfillPacing(1))
+	scheduler.backfillWindow = 3 * backfillChunk
+
+	if err := scheduler.Backfill(testContext(t)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(provider.ranges) != 3 || scheduler.backfillQueued("therm-1") {
+		t.Errorf("Expected 3 runtime requests and nothing queued, got %d", len(provider.ranges))
+	}
+	if scheduler.backfillPacing != 1 {
+		t.Errorf("Expected pacing to be restored, got %d", scheduler.backfillPacing)
+	}
+}
+
+func TestCatchUpAfterExtendedDowntime(t *testing.T) {
+	tests := []struct {
+		name         string
+		offsetAge    time.Duration
+		overwrite    time.Duration
+		expectStart  time.Duration // age of the first requested range
+		expectRanges int
+	}{
+		{name: "no stored offset backfills the window", expectStart: 24 * time.Hour, expectRanges: 1},
+		{name: "recent offset backfills the window", offsetAge: time.Hour, expectStart: 24 * time.Hour, expectRanges: 1},
+		{name: "offset before the window catches up from it", offsetAge: 10 * 24 * time.Hour, expectStart: 10 * 24 * time.Hour, expectRanges: 2},
+		{name: "overwrite window resumes before the offset", offsetAge: time.Hour, overwrite: 6 * time.Hour, expectStart: 7 * time.Hour, expectRanges: 1},
+		{name: "overwrite window stays within the backfill window", offsetAge: 20 * time.Hour, overwrite: 6 * time.Hour, expectStart: 24 * time.Hour, expectRanges: 1},
+		{name: "overwrite window without offset backfills the window", overwrite: 6 * time.Hour, expectStart: 24 * time.Hour, expectRanges: 1},
+		{name: "overwrite window still catches up extended downtime", offsetAge: 10 * 24 * time.Hour, overwrite: 6 * time.Hour, expectStart: 10 * 24 * time.Hour, expectRanges: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &flakyRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+			store := NewMemoryOffsetStore()
+			ctx := testContext(t)
+			if tt.offsetAge > 0 {
+				if err := store.SetLastRuntimeTime(ctx, "therm-1", time.Now().Add(-tt.offsetAge)); err != nil {
+					t.Fatalf("Failed to set offset: %v", err)
+				}
+			}
+			scheduler := newTestScheduler(provider, &mockSink{name: "test"}, store, WithBackfillOverwriteWindow(tt.overwrite))
+
+			if err := scheduler.performInitialBackfill(ctx); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(provider.ranges) != tt.expectRanges {
+				t.Fatalf("Expected %d runtime requests, got %d", tt.expectRanges, len(provider.ranges))
+			}
+			if age := time.Since(provider.ranges[0][0]); age < tt.expectStart || age > tt.expectStart+time.Minute {
+				t.Errorf("Expected backfill to start %v ago, got %v", tt.expectStart, age)
+			}
+		})
+	}
+}
+
+// sampledRuntimeProvider has no runtime history and reports the bins closed
+// since it started, as providers that sample runtime from their polls do.
+// Tests close bins by adding them to bins.
+type sampledRuntimeProvider struct {
+	mockProvider
+	bins  []time.Time
+	calls int
+}
+
+func (p *sampledRuntimeProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	p.calls++
+	var rows []model.RuntimeRow
+	for _, bin := range p.bins {
+		if bin.After(from) {
+			rows = append(rows, model.RuntimeRow{ThermostatRef: tr, EventTime: bin, Mode: "heat", AvgTempC: floatPtr(20)})
+		}
+	}
+	return rows, nil
+}
+
+func TestRuntimeAfterBackfillWithoutHistory(t *testing.T) {
+	provider := &sampledRuntimeProvider{mockProvider: mockProvider{name: "test"}}
+	sink := &recordingSink{mockSink: mockSink{name: "test"}}
+	scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore())
+	ctx := testContext(t)
+
+	if err := scheduler.performInitialBackfill(ctx); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Bins close as polls go on
+	start := time.Now().Truncate(runtimeBin)
+	for poll := range 3 {
+		provider.bins = append(provider.bins, start.Add(time.Duration(poll-1)*runtimeBin))
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	var eventTimes []time.Time
+	for _, doc := range sink.docs {
+		if runtime, ok := doc.Body.(*model.Runtime5m); ok {
+			eventTimes = append(eventTimes, runtime.EventTime)
+		}
+	}
+	if len(eventTimes) != 2 || !eventTimes[0].Equal(start) || !eventTimes[1].Equal(start.Add(runtimeBin)) {
+		t.Errorf("Expected runtime documents for the bins closed after the backfill, got %v", eventTimes)
+	}
+	if provider.calls != 4 {
+		t.Errorf("Expected a runtime request on the backfill and every poll, got %d", provider.calls)
+	}
+}
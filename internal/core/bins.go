@@ -0,0 +1,35 @@
+package core
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/runtimebin"
+)
+
+// WithBinAlignment aggregates the named provider's runtime readings into
+// canonical 5-minute bins with runtimebin.Align before they are normalized.
+// Providers that report readings at arbitrary times, such as push sources,
+// then still produce one runtime_5m document per bin.
+func WithBinAlignment(provider string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.alignBins[provider] = true
+	}
+}
+
+// alignedRows aggregates readings into bins for providers with bin alignment.
+// The bin still open at now is held back and the runtime offset stops at the
+// start of the last complete bin, so the next poll fetches its readings again
+// and re-emits the same bin.
+func (s *Scheduler) alignedRows(provider string, thermostatID string, rows []model.RuntimeRow, now time.Time) []model.RuntimeRow {
+	if !s.alignBins[provider] || len(rows) == 0 {
+		return rows
+	}
+
+	bins := runtimebin.Align(rows, now)
+	s.logger.Debug("Aligned runtime readings into bins",
+		"thermostat", thermostatID,
+		"readings", len(rows),
+		"bins", len(bins))
+	return bins
+}
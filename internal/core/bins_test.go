@@ -0,0 +1,44 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestBinAlignmentHoldsOpenBin(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "push"}
+	binStart := time.Now().Truncate(runtimeBin).Add(-2 * runtimeBin)
+	var readings []model.RuntimeRow
+	for offset := time.Duration(0); binStart.Add(offset).Before(time.Now()); offset += 90 * time.Second {
+		readings = append(readings, model.RuntimeRow{ThermostatRef: thermostat, EventTime: binStart.Add(offset), Mode: "heat", AvgTempC: floatPtr(20)})
+	}
+
+	sink := &recordingSink{mockSink: mockSink{name: "test"}}
+	store := NewMemoryOffsetStore()
+	provider := &mockProvider{name: "push"}
+	scheduler := newTestScheduler(provider, sink, store, WithBinAlignment("push"))
+
+	if err := scheduler.processRuntime(testContext(t), provider, thermostat, readings); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var eventTimes []time.Time
+	for _, doc := range sink.docs {
+		if runtime, ok := doc.Body.(*model.Runtime5m); ok {
+			eventTimes = append(eventTimes, runtime.EventTime)
+		}
+	}
+	if len(eventTimes) != 2 || !eventTimes[0].Equal(binStart) || !eventTimes[1].Equal(binStart.Add(runtimeBin)) {
+		t.Errorf("Expected runtime documents for the two closed bins, got %v", eventTimes)
+	}
+
+	offset, err := store.GetLastRuntimeTime(testContext(t), thermostat.ID)
+	if err != nil {
+		t.Fatalf("Failed to read offset: %v", err)
+	}
+	if !offset.Equal(binStart.Add(runtimeBin)) {
+		t.Errorf("Expected offset at the last closed bin %v, got %v", binStart.Add(runtimeBin), offset)
+	}
+}
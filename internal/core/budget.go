@@ -0,0 +1,271 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+)
+
+// RequestBudget limits a provider's API calls per clock hour and per UTC
+// day. Zero means no limit.
+type RequestBudget struct {
+	PerHour int
+	PerDay  int
+}
+
+// WithRequestBudget counts the named provider's API calls against a budget.
+// When the calls per polling cycle forecast the budget running out before it
+// resets, cycles are spread out so it lasts, and live polls pause once it
+// is spent.
+func WithRequestBudget(provider string, budget RequestBudget) SchedulerOption {
+	return func(s *Scheduler) {
+		if budget.PerHour <= 0 && budget.PerDay <= 0 {
+			return
+		}
+		s.budgets[provider] = &budgetTracker{budget: budget}
+	}
+}
+
+// BudgetMetrics reports a provider's request budget. Limits of zero mean no
+// limit, and their remaining counts are not meaningful.
+type BudgetMetrics struct {
+	HourlyLimit     int `json:"hourly_limit"`
+	HourlyRemaining int `json:"hourly_remaining"`
+	DailyLimit      int `json:"daily_limit"`
+	DailyRemaining  int `json:"daily_remaining"`
+	// RequestsPerCycle is the forecast of calls each polling cycle makes
+	RequestsPerCycle float64 `json:"requests_per_cycle"`
+	// StretchSeconds is how much the next cycle was delayed to stay in budget
+	StretchSeconds float64 `json:"stretch_seconds,omitempty"`
+}
+
+// budgetTracker counts a provider's calls in the current hour and day and
+// forecasts calls per polling cycle
+type budgetTracker struct {
+	mu     sync.Mutex
+	budget RequestBudget
+
+	hourStart, dayStart time.Time
+	hourUsed, dayUsed   int
+
+	// cycleUsed counts calls since the last cycle ended. The first cycle end
+	// follows the initial backfill, so it starts counting without forecasting.
+	cycleUsed int
+	counting  bool
+	perCycle  float64
+	// interval is the strategy's latest interval between cycles
+	interval time.Duration
+}
+
+// budgetSmoothing weights the latest cycle in the calls-per-cycle forecast
+const budgetSmoothing = 0.5
+
+// roll starts new hour and day windows once they have passed
+func (b *budgetTracker) roll(now time.Time) {
+	if hour := now.Truncate(time.Hour); !hour.Equal(b.hourStart) {
+		b.hourStart, b.hourUsed = hour, 0
+	}
+	utc := now.UTC()
+	if day := time.Date(utc.Year(), utc.Month(), utc.Day(), 0, 0, 0, 0, time.UTC); !day.Equal(b.dayStart) {
+		b.dayStart, b.dayUsed = day, 0
+	}
+}
+
+// record counts one call. Paced backfill calls are left out of the
+// per-cycle forecast, since backfill only spends what polling leaves spare.
+func (b *budgetTracker) record(now time.Time, backfill bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(now)
+	b.hourUsed++
+	b.dayUsed++
+	if !backfill {
+		b.cycleUsed++
+	}
+}
+
+// spare returns how many calls can be made now without cutting into the
+// calls forecast for polling cycles before each window resets
+func (b *budgetTracker) spare(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(now)
+	spare := math.MaxInt
+	for _, window := range []struct {
+		limit, used int
+		reset       time.Time
+	}{
+		{limit: b.budget.PerHour, used: b.hourUsed, reset: b.hourStart.Add(time.Hour)},
+		{limit: b.budget.PerDay, used: b.dayUsed, reset: b.dayStart.AddDate(0, 0, 1)},
+	} {
+		if window.limit <= 0 {
+			continue
+		}
+		reserved := 0
+		if b.interval > 0 {
+			cycles := math.Floor(float64(window.reset.Sub(now)) / float64(b.interval))
+			reserved = int(math.Ceil(cycles * b.perCycle))
+		}
+		spare = min(spare, window.limit-window.used-reserved)
+	}
+	return spare
+}
+
+// exhausted reports whether either window has no calls left
+func (b *budgetTracker) exhausted(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(now)
+	return (b.budget.PerHour > 0 && b.hourUsed >= b.budget.PerHour) ||
+		(b.budget.PerDay > 0 && b.dayUsed >= b.budget.PerDay)
+}
+
+// endCycle folds the calls of the cycle that just ended into the forecast
+// and returns the delay before the next cycle that keeps the forecast within
+// budget, at least interval
+func (b *budgetTracker) endCycle(now time.Time, interval time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(now)
+	if b.counting {
+		if b.perCycle == 0 {
+			b.perCycle = float64(b.cycleUsed)
+		} else {
+			b.perCycle = budgetSmoothing*float64(b.cycleUsed) + (1-budgetSmoothing)*b.perCycle
+		}
+	}
+	b.counting = true
+	b.cycleUsed = 0
+	b.interval = interval
+
+	delay := interval
+	delay = max(delay, b.windowDelay(now, interval, b.budget.PerHour, b.hourUsed, b.hourStart.Add(time.Hour)))
+	delay = max(delay, b.windowDelay(now, interval, b.budget.PerDay, b.dayUsed, b.dayStart.AddDate(0, 0, 1)))
+	return delay
+}
+
+// windowDelay returns the delay between cycles that spreads the calls left
+// in one window until it resets
+func (b *budgetTracker) windowDelay(now time.Time, interval time.Duration, limit, used int, reset time.Time) time.Duration {
+	if limit <= 0 {
+		return 0
+	}
+	untilReset := reset.Sub(now)
+	if used >= limit {
+		return untilReset
+	}
+	if b.perCycle <= 0 || interval <= 0 {
+		return 0
+	}
+
+	affordable := math.Floor(float64(limit-used) / b.perCycle)
+	if affordable < 1 {
+		return untilReset
+	}
+	if planned := math.Floor(float64(untilReset) / float64(interval)); planned <= affordable {
+		return 0
+	}
+	return time.Duration(float64(untilReset) / affordable)
+}
+
+// metrics returns the budget's current state
+func (b *budgetTracker) metrics(now time.Time, stretch time.Duration) BudgetMetrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.roll(now)
+	snapshot := BudgetMetrics{
+		HourlyLimit:      b.budget.PerHour,
+		HourlyRemaining:  max(b.budget.PerHour-b.hourUsed, 0),
+		DailyLimit:       b.budget.PerDay,
+		DailyRemaining:   max(b.budget.PerDay-b.dayUsed, 0),
+		RequestsPerCycle: b.perCycle,
+	}
+	if stretch > 0 {
+		snapshot.StretchSeconds = stretch.Seconds()
+	}
+	return snapshot
+}
+
+// recordProviderRequest counts one provider API call in metrics and against
+// the provider's budget, and notes it as the latest fetch for ingest metadata
+func (s *Scheduler) recordProviderRequest(provider string) {
+	s.metrics.RecordProviderRequest(provider)
+	s.recordFetch(time.Now())
+	if budget, ok := s.budgets[provider]; ok {
+		now := time.Now()
+		budget.record(now, s.backfillRunning)
+		s.metrics.RecordProviderBudget(provider, budget.metrics(now, 0))
+	}
+}
+
+// budgetExhausted reports whether a provider has spent its request budget
+func (s *Scheduler) budgetExhausted(provider string, now time.Time) bool {
+	budget, ok := s.budgets[provider]
+	return ok && budget.exhausted(now)
+}
+
+// budgetedDelay stretches the delay before the next cycle when a provider's
+// forecast calls would exceed its budget before the budget resets
+func (s *Scheduler) budgetedDelay(now time.Time, delay time.Duration) time.Duration {
+	stretched := delay
+	for _, provider := range sortedKeys(s.budgets) {
+		budget := s.budgets[provider]
+		needed := budget.endCycle(now, delay)
+		stretch := needed - delay
+		s.metrics.RecordProviderBudget(provider, budget.metrics(now, stretch))
+		if stretch > 0 {
+			s.logger.Info("Stretching poll interval to stay within request budget",
+				"provider", provider,
+				"interval", delay,
+				"stretched_to", needed.Round(time.Second),
+				"requests_per_cycle", budget.perCycle)
+		}
+		stretched = max(stretched, needed)
+	}
+	return stretched
+}
+
+// RecordProviderBudget records a provider's request budget state
+func (m *MetricsCollector) RecordProviderBudget(providerName string, budget BudgetMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.providerBudgets[providerName] = budget
+}
+
+// writeBudgetGauges writes the limits and remaining calls of every provider
+// with a request budget, labelled by window
+func writeBudgetGauges(w io.Writer, providers map[string]ProviderMetrics) {
+	names := sortedKeys(providers)
+	write := func(name, help string, values func(BudgetMetrics) (hour, day int)) {
+		fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+		fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+		for _, provider := range names {
+			budget := providers[provider].Budget
+			if budget == nil {
+				continue
+			}
+			hour, day := values(*budget)
+			if budget.HourlyLimit > 0 {
+				fmt.Fprintf(w, "%s{provider=\"%s\",window=\"hour\"} %d\n", name, escapeLabel(provider), hour)
+			}
+			if budget.DailyLimit > 0 {
+				fmt.Fprintf(w, "%s{provider=\"%s\",window=\"day\"} %d\n", name, escapeLabel(provider), day)
+			}
+		}
+	}
+
+	write("ttr_provider_budget_limit", "Provider API calls allowed per window", func(b BudgetMetrics) (int, int) {
+		return b.HourlyLimit, b.DailyLimit
+	})
+	write("ttr_provider_budget_remaining", "Provider API calls left in the current window", func(b BudgetMetrics) (int, int) {
+		return b.HourlyRemaining, b.DailyRemaining
+	})
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetForecast(t *testing.T) {
+	start := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	interval := 5 * time.Minute
+
+	tests := []struct {
+		name     string
+		budget   RequestBudget
+		calls    int // per cycle, after the initial backfill
+		cycles   int
+		expected time.Duration
+	}{
+		{name: "within budget", budget: RequestBudget{PerHour: 1000}, calls: 10, cycles: 1, expected: interval},
+		// 60 calls left for the 55 minutes to 11:00 afford 6 cycles
+		{name: "hourly forecast exceeds budget", budget: RequestBudget{PerHour: 70}, calls: 10, cycles: 1, expected: 55 * time.Minute / 6},
+		{name: "hourly budget spent", budget: RequestBudget{PerHour: 20}, calls: 10, cycles: 2, expected: 50 * time.Minute},
+		// 490 calls left for the 13h55m to midnight afford 49 cycles
+		{name: "daily forecast exceeds budget", budget: RequestBudget{PerDay: 500}, calls: 10, cycles: 1, expected: (13*time.Hour + 55*time.Minute) / 49},
+		{name: "no forecast before the first cycle", budget: RequestBudget{PerHour: 10}, calls: 5, cycles: 0, expected: interval},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &budgetTracker{budget: tt.budget}
+			now := start
+			// The initial backfill is not a polling cycle
+			for range 50 {
+				tracker.record(now, false)
+			}
+			tracker.hourUsed, tracker.dayUsed = 0, 0
+
+			delay := tracker.endCycle(now, interval)
+			for range tt.cycles {
+				now = now.Add(interval)
+				for range tt.calls {
+					tracker.record(now, false)
+				}
+				delay = tracker.endCycle(now, interval)
+			}
+
+			if diff := delay - tt.expected; diff < -time.Second || diff > time.Second {
+				t.Errorf("Expected delay %v, got %v", tt.expected, delay)
+			}
+		})
+	}
+}
+
+func TestBudgetWindowsReset(t *testing.T) {
+	tracker := &budgetTracker{budget: RequestBudget{PerHour: 2, PerDay: 3}}
+	now := time.Date(2025, 1, 10, 23, 30, 0, 0, time.UTC)
+
+	tracker.record(now, false)
+	tracker.record(now, false)
+	if !tracker.exhausted(now) {
+		t.Error("Expected the hourly budget to be spent")
+	}
+	if tracker.exhausted(now.Add(31 * time.Minute)) {
+		t.Error("Expected a new hour and day to reset the budget")
+	}
+
+	metrics := tracker.metrics(now.Add(31*time.Minute), 0)
+	if metrics.HourlyRemaining != 2 || metrics.DailyRemaining != 3 {
+		t.Errorf("Expected full budgets after the reset, got %+v", metrics)
+	}
+}
+
+func TestBudgetMetrics(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "es"}, NewMemoryOffsetStore(),
+		WithRequestBudget("ecobee", RequestBudget{PerHour: 100, PerDay: 1000}),
+		WithRequestBudget("unlimited", RequestBudget{}))
+
+	if _, ok := scheduler.budgets["unlimited"]; ok {
+		t.Error("Expected an empty budget not to be tracked")
+	}
+
+	scheduler.recordProviderRequest("ecobee")
+	scheduler.recordProviderRequest("ecobee")
+
+	budget := scheduler.metrics.GetMetrics().Providers["ecobee"].Budget
+	if budget == nil {
+		t.Fatal("Expected request budget in provider metrics")
+	}
+	if budget.HourlyRemaining != 98 || budget.DailyRemaining != 998 {
+		t.Errorf("Unexpected budget metrics: %+v", budget)
+	}
+
+	recorder := httptest.NewRecorder()
+	scheduler.metrics.ServePrometheus().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics/prometheus", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		`ttr_provider_budget_limit{provider="ecobee",window="hour"} 100`,
+		`ttr_provider_budget_remaining{provider="ecobee",window="hour"} 98`,
+		`ttr_provider_budget_remaining{provider="ecobee",window="day"} 998`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in output:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BuildInfo describes the running binary: its release version, the commit
+// and date it was built from, and the Go toolchain that built it
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// VersionInfo is served on /version: the running build and the
+// configuration it loaded
+type VersionInfo struct {
+	BuildInfo
+	// ConfigFingerprint identifies the configuration loaded at startup
+	ConfigFingerprint string `json:"config_fingerprint,omitempty"`
+	// DiskConfigFingerprint is set when the configuration files, as last
+	// checked, no longer give the running configuration; a restart applies it
+	DiskConfigFingerprint string `json:"disk_config_fingerprint,omitempty"`
+}
+
+// SetBuildInfo records the running binary's build information, served on
+// /version and as the ttr_build_info metric
+func (m *MetricsCollector) SetBuildInfo(info BuildInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buildInfo = info
+}
+
+// SetConfigFingerprint records the fingerprint of the configuration loaded
+// at startup
+func (m *MetricsCollector) SetConfigFingerprint(fingerprint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.configFingerprint = fingerprint
+}
+
+// RecordDiskConfig records the fingerprint of the configuration files as
+// just re-read, and reports whether it differs from the running one
+func (m *MetricsCollector) RecordDiskConfig(fingerprint string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if fingerprint == m.configFingerprint {
+		m.diskConfigFingerprint = ""
+		return false
+	}
+	m.diskConfigFingerprint = fingerprint
+	return true
+}
+
+// GetVersionInfo returns the build and configuration information
+func (m *MetricsCollector) GetVersionInfo() VersionInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return VersionInfo{
+		BuildInfo:             m.buildInfo,
+		ConfigFingerprint:     m.configFingerprint,
+		DiskConfigFingerprint: m.diskConfigFingerprint,
+	}
+}
+
+// ServeVersion returns an HTTP handler reporting the build and configuration
+// information
+func (m *MetricsCollector) ServeVersion() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(m.GetVersionInfo())
+	})
+}
+
+// writeBuildInfo writes the build information and configuration fingerprint
+// as constant gauges whose labels carry the values, so dashboards can join
+// them to other series, and whether the configuration files have changed
+func (m *MetricsCollector) writeBuildInfo(w io.Writer) {
+	info := m.GetVersionInfo()
+
+	fmt.Fprintf(w, "# HELP ttr_build_info Build information of the running binary; always 1\n")
+	fmt.Fprintf(w, "# TYPE ttr_build_info gauge\n")
+	fmt.Fprintf(w, "ttr_build_info{version=\"%s\",commit=\"%s\",date=\"%s\",go_version=\"%s\"} 1\n",
+		escapeLabel(info.Version), escapeLabel(info.Commit), escapeLabel(info.Date), escapeLabel(info.GoVersion))
+
+	if info.ConfigFingerprint == "" {
+		return
+	}
+	fmt.Fprintf(w, "# HELP ttr_config_info Fingerprint of the configuration loaded at startup; always 1\n")
+	fmt.Fprintf(w, "# TYPE ttr_config_info gauge\n")
+	fmt.Fprintf(w, "ttr_config_info{fingerprint=\"%s\"} 1\n", escapeLabel(info.ConfigFingerprint))
+	changed := 0
+	if info.DiskConfigFingerprint != "" {
+		changed = 1
+	}
+	writeGauge(w, "ttr_config_changed", "1 when the configuration files no longer give the running configuration", changed)
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBuildInfo(t *testing.T) {
+	metrics := NewMetricsCollector()
+	info := BuildInfo{Version: "v1.2.3", Commit: "abc123", Date: "2025-01-10T00:00:00Z", GoVersion: "go1.25.0"}
+	metrics.SetBuildInfo(info)
+
+	t.Run("version endpoint serves the build information", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		metrics.ServeVersion().ServeHTTP(recorder, httptest.NewRequest("GET", "/version", nil))
+
+		var served BuildInfo
+		if err := json.NewDecoder(recorder.Body).Decode(&served); err != nil {
+			t.Fatalf("Failed to decode build info: %v", err)
+		}
+		if served != info {
+			t.Errorf("Expected %+v, got %+v", info, served)
+		}
+	})
+
+	t.Run("build info metric carries the values as labels", func(t *testing.T) {
+		var out strings.Builder
+		metrics.writePrometheus(&out)
+
+		expected := `ttr_build_info{version="v1.2.3",commit="abc123",date="2025-01-10T00:00:00Z",go_version="go1.25.0"} 1`
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected %s in output:\n%s", expected, out.String())
+		}
+	})
+
+	t.Run("configuration changes on disk are reported", func(t *testing.T) {
+		metrics.SetConfigFingerprint("aaaa")
+		if metrics.RecordDiskConfig("aaaa") {
+			t.Error("Expected an unchanged configuration not to be reported")
+		}
+		if !metrics.RecordDiskConfig("bbbb") {
+			t.Error("Expected a changed configuration to be reported")
+		}
+
+		info := metrics.GetVersionInfo()
+		if info.ConfigFingerprint != "aaaa" || info.DiskConfigFingerprint != "bbbb" {
+			t.Errorf("Unexpected fingerprints %+v", info)
+		}
+		var out strings.Builder
+		metrics.writePrometheus(&out)
+		for _, expected := range []string{`ttr_config_info{fingerprint="aaaa"} 1`, "ttr_config_changed 1"} {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("Expected %s in output:\n%s", expected, out.String())
+			}
+		}
+
+		// Reverting the edit clears the change
+		metrics.RecordDiskConfig("aaaa")
+		if info := metrics.GetVersionInfo(); info.DiskConfigFingerprint != "" {
+			t.Errorf("Expected the change cleared, got %+v", info)
+		}
+	})
+}
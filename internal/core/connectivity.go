@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// RecordThermostatConnected records whether a thermostat's provider reports
+// it connected
+func (m *MetricsCollector) RecordThermostatConnected(thermostatID string, connected bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connectivity[thermostatID] = connected
+}
+
+// checkConnectivity records the connectivity a thermostat's summary reports
+// and writes a connectivity document when it changes. A thermostat first seen
+// disconnected gets one too, but not one first seen connected, so restarts do
+// not repeat documents for healthy thermostats. Summaries that do not report
+// connectivity count as connected.
+func (s *Scheduler) checkConnectivity(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, summary model.Summary) bool {
+	if summary.Connected == nil {
+		return true
+	}
+	connected := *summary.Connected
+	s.metrics.RecordThermostatConnected(thermostat.ID, connected)
+
+	previous, known := s.connected[thermostat.ID]
+	s.connected[thermostat.ID] = connected
+	if (known && previous == connected) || (!known && connected) {
+		return connected
+	}
+
+	if connected {
+		s.logger.Info("Thermostat reconnected", "provider", provider.Info().Name, "thermostat", thermostat.ID)
+	} else {
+		s.logger.Warn("Thermostat disconnected, skipping runtime fetches until it reconnects",
+			"provider", provider.Info().Name,
+			"thermostat", thermostat.ID)
+	}
+
+	status := &model.Connectivity{
+		Type:           "connectivity",
+		EventTime:      time.Now().UTC().Truncate(time.Second),
+		ThermostatID:   thermostat.ID,
+		ThermostatName: thermostat.Name,
+		HouseholdID:    thermostat.HouseholdID,
+		Connected:      connected,
+	}
+	docID, err := s.idGenerator.GenerateConnectivityID(status)
+	if err != nil {
+		s.logger.Error("Failed to generate document ID for connectivity", "error", err)
+		return connected
+	}
+	if err := s.writeToAllSinks(ctx, []model.Doc{{ID: docID, Type: "connectivity", Body: status}}); err != nil {
+		s.logger.Error("Failed to write connectivity document", "thermostat", thermostat.ID, "error", err)
+	}
+	return connected
+}
+
+// disconnected reports whether a thermostat was last reported disconnected
+func (s *Scheduler) disconnected(thermostatID string) bool {
+	connected, known := s.connected[thermostatID]
+	return known && !connected
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// connectivityProvider reports its thermostat connected or not and counts
+// runtime requests
+type connectivityProvider struct {
+	mockProvider
+	connected    bool
+	runtimeCalls int
+}
+
+func (p *connectivityProvider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	connected := p.connected
+	return model.Summary{ThermostatRef: tr, Connected: &connected}, nil
+}
+
+func (p *connectivityProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	p.runtimeCalls++
+	return p.mockProvider.GetRuntime(ctx, tr, from, to)
+}
+
+func TestDisconnectedThermostat(t *testing.T) {
+	ctx := testContext(t)
+	store := NewMemoryOffsetStore()
+	if err := store.SetLastRuntimeTime(ctx, "therm-1", time.Now().Add(-time.Hour)); err != nil {
+		t.Fatalf("SetLastRuntimeTime failed: %v", err)
+	}
+	provider := &connectivityProvider{mockProvider: mockProvider{name: "ecobee"}}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(provider, sink, store)
+
+	// connectivityDocs returns the connectivity documents written so far
+	connectivityDocs := func() []*model.Connectivity {
+		var docs []*model.Connectivity
+		for _, doc := range sink.docs {
+			if doc.Type == "connectivity" {
+				docs = append(docs, doc.Body.(*model.Connectivity))
+			}
+		}
+		return docs
+	}
+
+	for range 2 {
+		if err := scheduler.pollProvider(ctx, provider); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if provider.runtimeCalls != 0 {
+		t.Errorf("Expected no runtime requests while disconnected, got %d", provider.runtimeCalls)
+	}
+	if docs := connectivityDocs(); len(docs) != 1 || docs[0].Connected || docs[0].ThermostatName != "Test" {
+		t.Errorf("Expected one disconnected document, got %+v", docs)
+	}
+	if state := scheduler.metrics.GetSchedulerState(); state.Thermostats[ThermostatDisconnected] != 1 {
+		t.Errorf("Expected a disconnected thermostat, got %v", state.Thermostats)
+	}
+	if connected, ok := scheduler.metrics.GetMetrics().Connected["therm-1"]; !ok || connected {
+		t.Errorf("Expected the connectivity gauge to be false, got %v (present %v)", connected, ok)
+	}
+
+	provider.connected = true
+	if err := scheduler.pollProvider(ctx, provider); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if provider.runtimeCalls != 1 {
+		t.Errorf("Expected runtime to be fetched after reconnecting, got %d requests", provider.runtimeCalls)
+	}
+	if docs := connectivityDocs(); len(docs) != 2 || !docs[1].Connected {
+		t.Errorf("Expected a reconnected document, got %+v", docs)
+	}
+	if state := scheduler.metrics.GetSchedulerState(); state.Thermostats[ThermostatOK] != 1 {
+		t.Errorf("Expected the thermostat to be ok, got %v", state.Thermostats)
+	}
+	if !scheduler.metrics.GetMetrics().Connected["therm-1"] {
+		t.Error("Expected the connectivity gauge to be true")
+	}
+}
+
+func TestConnectivityFirstSeenConnected(t *testing.T) {
+	provider := &connectivityProvider{mockProvider: mockProvider{name: "ecobee"}, connected: true}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore())
+
+	if err := scheduler.pollProvider(testContext(t), provider); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, doc := range sink.docs {
+		if doc.Type == "connectivity" {
+			t.Errorf("Expected no connectivity document for a connected thermostat, got %+v", doc.Body)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Control errors, mapped to HTTP statuses by ServeControl
+var (
+	// ErrControlReadOnly is returned for every command while control is read-only
+	ErrControlReadOnly = errors.New("control is read-only")
+	// ErrInvalidControlCommand is returned for commands that fail validation
+	ErrInvalidControlCommand = errors.New("invalid control command")
+	// ErrControlUnsupported is returned when no provider that supports control
+	// lists the thermostat
+	ErrControlUnsupported = errors.New("thermostat not found on a provider that supports control")
+)
+
+// Control audit outcomes
+const (
+	ControlOutcomeApplied  = "applied"
+	ControlOutcomeRejected = "rejected"
+	ControlOutcomeFailed   = "failed"
+)
+
+// ControlConfig controls the thermostat control subsystem
+type ControlConfig struct {
+	// ReadOnly rejects every command; it is the default so nothing is ever
+	// changed on a thermostat without opting in
+	ReadOnly bool
+	// MinSetpointC and MaxSetpointC bound the setpoints a hold may set
+	MinSetpointC float64
+	MaxSetpointC float64
+	// ConfigFingerprint is recorded on every audit entry, naming the
+	// configuration the command was checked against
+	ConfigFingerprint string
+}
+
+// ControlAuditEntry is one line of the control audit log
+type ControlAuditEntry struct {
+	Time         time.Time            `json:"time"`
+	Remote       string               `json:"remote,omitempty"`
+	ThermostatID string               `json:"thermostat_id"`
+	Provider     string               `json:"provider,omitempty"`
+	Command      model.ControlCommand `json:"command"`
+	Outcome      string               `json:"outcome"`
+	Error        string               `json:"error,omitempty"`
+	// ConfigFingerprint identifies the configuration the collector was running
+	ConfigFingerprint string `json:"config_fingerprint,omitempty"`
+}
+
+// ThermostatController pushes setpoint and climate changes to thermostats whose
+// provider implements model.Controller. It is separate from the scheduler:
+// collection never changes a thermostat, and every command, including those
+// rejected, is written to the audit log as a JSON line.
+type ThermostatController struct {
+	providers []model.Provider
+	config    ControlConfig
+	logger    *slog.Logger
+
+	mu    sync.Mutex // serializes commands and audit writes
+	audit io.Writer
+}
+
+// NewThermostatController creates a controller writing its audit log to audit
+func NewThermostatController(providers []model.Provider, config ControlConfig, audit io.Writer, logger *slog.Logger) *ThermostatController {
+	return &ThermostatController{
+		providers: providers,
+		config:    config,
+		audit:     audit,
+		logger:    logger,
+	}
+}
+
+// Apply validates a command and sends it to the thermostat's provider.
+// remote identifies the caller in the audit log.
+func (c *ThermostatController) Apply(ctx context.Context, remote, thermostatID string, cmd model.ControlCommand) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := ControlAuditEntry{Time: time.Now().UTC(), Remote: remote, ThermostatID: thermostatID, Command: cmd,
+		ConfigFingerprint: c.config.ConfigFingerprint}
+	err := c.apply(ctx, &entry, cmd)
+	switch {
+	case err == nil:
+		entry.Outcome = ControlOutcomeApplied
+		c.logger.Info("Applied control command", "thermostat", thermostatID, "provider", entry.Provider, "action", cmd.Action)
+	case errors.Is(err, ErrControlReadOnly), errors.Is(err, ErrInvalidControlCommand), errors.Is(err, ErrControlUnsupported):
+		entry.Outcome, entry.Error = ControlOutcomeRejected, err.Error()
+		c.logger.Warn("Rejected control command", "thermostat", thermostatID, "action", cmd.Action, "error", err)
+	default:
+		entry.Outcome, entry.Error = ControlOutcomeFailed, err.Error()
+		c.logger.Error("Failed to apply control command", "thermostat", thermostatID, "provider", entry.Provider, "error", err)
+	}
+
+	if auditErr := c.writeAudit(entry); auditErr != nil {
+		c.logger.Error("Failed to write control audit log", "error", auditErr)
+	}
+	return err
+}
+
+// apply performs a command, recording the provider on the audit entry
+func (c *ThermostatController) apply(ctx context.Context, entry *ControlAuditEntry, cmd model.ControlCommand) error {
+	if c.config.ReadOnly {
+		return ErrControlReadOnly
+	}
+	if err := c.validate(cmd); err != nil {
+		return err
+	}
+
+	for _, provider := range c.providers {
+		controller, ok := provider.(model.Controller)
+		if !ok {
+			continue
+		}
+		thermostats, err := provider.ListThermostats(ctx)
+		if err != nil {
+			return fmt.Errorf("listing thermostats for %s: %w", provider.Info().Name, err)
+		}
+		for _, thermostat := range thermostats {
+			if thermostat.ID != entry.ThermostatID {
+				continue
+			}
+			entry.Provider = provider.Info().Name
+			return controller.Control(ctx, thermostat, cmd)
+		}
+	}
+	return ErrControlUnsupported
+}
+
+// validate checks a command's action and keeps setpoints within bounds
+func (c *ThermostatController) validate(cmd model.ControlCommand) error {
+	switch cmd.Action {
+	case model.ControlActionResume:
+		if cmd.HeatSetpointC != nil || cmd.CoolSetpointC != nil || cmd.Climate != "" || cmd.HoldHours != 0 {
+			return fmt.Errorf("%w: resume takes no hold settings", ErrInvalidControlCommand)
+		}
+		return nil
+	case model.ControlActionHold:
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidControlCommand, cmd.Action)
+	}
+
+	if cmd.HoldHours < 0 {
+		return fmt.Errorf("%w: hold_hours cannot be negative", ErrInvalidControlCommand)
+	}
+	if cmd.Climate != "" {
+		if cmd.HeatSetpointC != nil || cmd.CoolSetpointC != nil {
+			return fmt.Errorf("%w: a hold sets either a climate or setpoints", ErrInvalidControlCommand)
+		}
+		return nil
+	}
+	if cmd.HeatSetpointC == nil || cmd.CoolSetpointC == nil {
+		return fmt.Errorf("%w: a hold needs a climate or both setpoints", ErrInvalidControlCommand)
+	}
+	for _, setpoint := range []float64{*cmd.HeatSetpointC, *cmd.CoolSetpointC} {
+		if setpoint < c.config.MinSetpointC || setpoint > c.config.MaxSetpointC {
+			return fmt.Errorf("%w: setpoints must be between %g°C and %g°C", ErrInvalidControlCommand, c.config.MinSetpointC, c.config.MaxSetpointC)
+		}
+	}
+	if *cmd.HeatSetpointC >= *cmd.CoolSetpointC {
+		return fmt.Errorf("%w: heat setpoint must be below cool setpoint", ErrInvalidControlCommand)
+	}
+	return nil
+}
+
+// writeAudit appends an entry to the audit log
+func (c *ThermostatController) writeAudit(entry ControlAuditEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshaling audit entry: %w", err)
+	}
+	_, err = c.audit.Write(append(line, '\n'))
+	return err
+}
+
+// controlBody is the JSON body of a control request
+type controlBody struct {
+	Thermostat string `json:"thermostat"`
+	model.ControlCommand
+}
+
+// ServeControl provides an HTTP handler that applies control commands.
+// Callers must send token as a bearer token.
+func (c *ThermostatController) ServeControl(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !bearerAuthorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var body controlBody
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.Thermostat == "" {
+			http.Error(w, "thermostat is required", http.StatusBadRequest)
+			return
+		}
+
+		err := c.Apply(r.Context(), r.RemoteAddr, body.Thermostat, body.ControlCommand)
+		switch {
+		case errors.Is(err, ErrControlReadOnly):
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case errors.Is(err, ErrInvalidControlCommand):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, ErrControlUnsupported):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, "control command failed", http.StatusBadGateway)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(map[string]string{"outcome": ControlOutcomeApplied})
+	})
+}
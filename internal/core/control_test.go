@@ -0,0 +1,187 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// controlProvider is a mock provider that records control commands
+type controlProvider struct {
+	mockProvider
+	commands []model.ControlCommand
+	fail     bool
+}
+
+func (p *controlProvider) Control(ctx context.Context, tr model.ThermostatRef, cmd model.ControlCommand) error {
+	if p.fail {
+		return fmt.Errorf("mock control error")
+	}
+	p.commands = append(p.commands, cmd)
+	return nil
+}
+
+func TestThermostatControllerApply(t *testing.T) {
+	heat, cool, tooHot := 20.0, 25.0, 40.0
+	config := ControlConfig{MinSetpointC: 10, MaxSetpointC: 32, ConfigFingerprint: "0123abcd"}
+
+	tests := []struct {
+		name        string
+		readOnly    bool
+		fail        bool
+		thermostat  string
+		cmd         model.ControlCommand
+		wantErr     error
+		wantOutcome string
+	}{
+		{
+			name:        "setpoint hold",
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &heat, CoolSetpointC: &cool},
+			wantOutcome: ControlOutcomeApplied,
+		},
+		{
+			name:        "read-only",
+			readOnly:    true,
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionResume},
+			wantErr:     ErrControlReadOnly,
+			wantOutcome: ControlOutcomeRejected,
+		},
+		{
+			name:        "setpoint out of bounds",
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &heat, CoolSetpointC: &tooHot},
+			wantErr:     ErrInvalidControlCommand,
+			wantOutcome: ControlOutcomeRejected,
+		},
+		{
+			name:        "heat above cool",
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &cool, CoolSetpointC: &heat},
+			wantErr:     ErrInvalidControlCommand,
+			wantOutcome: ControlOutcomeRejected,
+		},
+		{
+			name:        "hold without setpoints or climate",
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &heat},
+			wantErr:     ErrInvalidControlCommand,
+			wantOutcome: ControlOutcomeRejected,
+		},
+		{
+			name:        "unknown thermostat",
+			thermostat:  "therm-2",
+			cmd:         model.ControlCommand{Action: model.ControlActionHold, Climate: "away"},
+			wantErr:     ErrControlUnsupported,
+			wantOutcome: ControlOutcomeRejected,
+		},
+		{
+			name:        "provider failure",
+			fail:        true,
+			thermostat:  "therm-1",
+			cmd:         model.ControlCommand{Action: model.ControlActionResume},
+			wantOutcome: ControlOutcomeFailed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &controlProvider{mockProvider: mockProvider{name: "test"}, fail: tt.fail}
+			var audit bytes.Buffer
+			config := config
+			config.ReadOnly = tt.readOnly
+			controller := NewThermostatController([]model.Provider{provider}, config, &audit, slog.Default())
+
+			err := controller.Apply(context.Background(), "127.0.0.1", tt.thermostat, tt.cmd)
+			switch {
+			case tt.wantOutcome == ControlOutcomeFailed:
+				if err == nil {
+					t.Error("Expected an error")
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if applied := len(provider.commands) == 1; applied != (tt.wantOutcome == ControlOutcomeApplied) {
+				t.Errorf("Unexpected commands sent: %+v", provider.commands)
+			}
+
+			var entry ControlAuditEntry
+			if err := json.Unmarshal(audit.Bytes(), &entry); err != nil {
+				t.Fatalf("Failed to decode audit entry %q: %v", audit.String(), err)
+			}
+			if entry.Outcome != tt.wantOutcome || entry.ThermostatID != tt.thermostat || entry.Remote != "127.0.0.1" ||
+				entry.ConfigFingerprint != "0123abcd" {
+				t.Errorf("Unexpected audit entry %+v", entry)
+			}
+		})
+	}
+
+	t.Run("providers without control are skipped", func(t *testing.T) {
+		var audit bytes.Buffer
+		controller := NewThermostatController([]model.Provider{&mockProvider{name: "test"}}, config, &audit, slog.Default())
+		err := controller.Apply(context.Background(), "", "therm-1", model.ControlCommand{Action: model.ControlActionResume})
+		if !errors.Is(err, ErrControlUnsupported) {
+			t.Errorf("Expected ErrControlUnsupported, got %v", err)
+		}
+	})
+}
+
+func TestServeControl(t *testing.T) {
+	const token = "0123456789abcdef"
+	provider := &controlProvider{mockProvider: mockProvider{name: "test"}}
+	var audit bytes.Buffer
+	controller := NewThermostatController([]model.Provider{provider}, ControlConfig{MinSetpointC: 10, MaxSetpointC: 32}, &audit, slog.Default())
+	readOnly := NewThermostatController([]model.Provider{provider}, ControlConfig{ReadOnly: true}, &audit, slog.Default())
+
+	hold := `{"thermostat": "therm-1", "action": "hold", "heat_setpoint_c": 20, "cool_setpoint_c": 25, "hold_hours": 2}`
+	tests := []struct {
+		name       string
+		controller *ThermostatController
+		method     string
+		auth       string
+		body       string
+		expected   int
+	}{
+		{name: "wrong method", controller: controller, method: http.MethodGet, auth: "Bearer " + token, expected: http.StatusMethodNotAllowed},
+		{name: "wrong token", controller: controller, method: http.MethodPost, auth: "Bearer nope", body: hold, expected: http.StatusUnauthorized},
+		{name: "missing thermostat", controller: controller, method: http.MethodPost, auth: "Bearer " + token, body: `{"action": "resume"}`, expected: http.StatusBadRequest},
+		{name: "invalid command", controller: controller, method: http.MethodPost, auth: "Bearer " + token, body: `{"thermostat": "therm-1", "action": "off"}`, expected: http.StatusBadRequest},
+		{name: "unknown thermostat", controller: controller, method: http.MethodPost, auth: "Bearer " + token, body: `{"thermostat": "therm-2", "action": "resume"}`, expected: http.StatusNotFound},
+		{name: "read-only", controller: readOnly, method: http.MethodPost, auth: "Bearer " + token, body: hold, expected: http.StatusForbidden},
+		{name: "applied", controller: controller, method: http.MethodPost, auth: "Bearer " + token, body: hold, expected: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/admin/control", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				request.Header.Set("Authorization", tt.auth)
+			}
+			recorder := httptest.NewRecorder()
+			tt.controller.ServeControl(token).ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	if len(provider.commands) != 1 {
+		t.Fatalf("Expected 1 command sent, got %d", len(provider.commands))
+	}
+	if cmd := provider.commands[0]; cmd.HoldHours != 2 || *cmd.HeatSetpointC != 20 || *cmd.CoolSetpointC != 25 {
+		t.Errorf("Unexpected command %+v", cmd)
+	}
+	// Unauthorized and malformed requests never reach the audit log
+	if lines := strings.Count(audit.String(), "\n"); lines != 4 {
+		t.Errorf("Expected 4 audit entries, got %d", lines)
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"log/slog"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// wrappedSink is implemented by sink decorators such as pipeline.Sink
+type wrappedSink interface {
+	Unwrap() model.Sink
+}
+
+// ReloadCredentials re-reads the credential files of every provider and sink
+// that supports it. Failures are logged and leave the previous credentials in
+// use, so a half-written secret file does not interrupt collection. It returns
+// the number of components whose credentials changed.
+func ReloadCredentials(providers []model.Provider, sinks []model.Sink, logger *slog.Logger) int {
+	changed := 0
+	reload := func(component string, target any) {
+		reloader, ok := target.(model.CredentialReloader)
+		if !ok {
+			return
+		}
+		updated, err := reloader.ReloadCredentials()
+		if err != nil {
+			logger.Error("Failed to reload credentials", "component", component, "error", err)
+			return
+		}
+		if updated {
+			changed++
+			logger.Info("Reloaded credentials", "component", component)
+		}
+	}
+
+	for _, provider := range providers {
+		reload("provider_"+provider.Info().Name, provider)
+	}
+	for _, sink := range sinks {
+		for {
+			wrapped, ok := sink.(wrappedSink)
+			if !ok {
+				break
+			}
+			sink = wrapped.Unwrap()
+		}
+		reload("sink_"+sink.Info().Name, sink)
+	}
+	return changed
+}
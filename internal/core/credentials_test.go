@@ -0,0 +1,53 @@
+package core
+
+import (
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+type reloadingSink struct {
+	mockSink
+	changed bool
+	err     error
+	calls   int
+}
+
+func (s *reloadingSink) ReloadCredentials() (bool, error) {
+	s.calls++
+	return s.changed, s.err
+}
+
+type decoratedSink struct {
+	model.Sink
+}
+
+func (s *decoratedSink) Unwrap() model.Sink {
+	return s.Sink
+}
+
+func TestReloadCredentials(t *testing.T) {
+	rotated := &reloadingSink{mockSink: mockSink{name: "rotated"}, changed: true}
+	unchanged := &reloadingSink{mockSink: mockSink{name: "unchanged"}}
+	failing := &reloadingSink{mockSink: mockSink{name: "failing"}, changed: true, err: errors.New("secret file is empty")}
+
+	sinks := []model.Sink{
+		&decoratedSink{Sink: &decoratedSink{Sink: rotated}},
+		unchanged,
+		failing,
+		&mockSink{name: "static"},
+	}
+	providers := []model.Provider{&mockProvider{name: "static"}}
+
+	changed := ReloadCredentials(providers, sinks, slog.Default())
+	if changed != 1 {
+		t.Errorf("Expected 1 changed component, got %d", changed)
+	}
+	for _, sink := range []*reloadingSink{rotated, unchanged, failing} {
+		if sink.calls != 1 {
+			t.Errorf("Expected sink %s to be reloaded once, got %d", sink.name, sink.calls)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+const (
+	// DataQualityAnalyzerName identifies data quality analysis documents
+	DataQualityAnalyzerName = "data_quality"
+
+	// qualityReportingLag is how far behind now the runtime window ends, since
+	// providers publish 5-minute bins late (Ecobee by up to an hour); bins
+	// inside the lag are not yet expected
+	qualityReportingLag = time.Hour
+
+	// runtimeBin is the width of a canonical runtime bin
+	runtimeBin = 5 * time.Minute
+)
+
+// Data quality score weights; they sum to 1
+const (
+	completenessWeight = 0.5
+	coverageWeight     = 0.3
+	pollSuccessWeight  = 0.2
+)
+
+// DataQualityConfig sets the rolling window and how often documents are written
+type DataQualityConfig struct {
+	// Window is how far back bins and poll outcomes are scored
+	Window time.Duration
+	// Interval is how often a data quality document is written per thermostat
+	Interval time.Duration
+}
+
+// DataQuality is a thermostat's rolling data quality. Score weights bin
+// completeness, sensor coverage and poll success 50/30/20 and ranges from 0
+// (no usable telemetry) to 1.
+type DataQuality struct {
+	Score          float64 `json:"score"`
+	Completeness   float64 `json:"completeness"`    // received / expected 5-minute bins
+	SensorCoverage float64 `json:"sensor_coverage"` // readings present / readings expected in received bins
+	ErrorRate      float64 `json:"error_rate"`      // failed / total polls
+	BinsExpected   int     `json:"bins_expected"`
+	BinsReceived   int     `json:"bins_received"`
+	Polls          int     `json:"polls"`
+	FailedPolls    int     `json:"failed_polls"`
+}
+
+// DataQualityTracker scores each thermostat's telemetry over a rolling
+// window. It is registered as an analyzer, so it sees every normalized
+// runtime row and poll outcome, and writes a data_quality analysis document
+// per thermostat every interval. The metrics collector reads the current
+// scores for /metrics.
+type DataQualityTracker struct {
+	config      DataQualityConfig
+	mu          sync.Mutex
+	thermostats map[string]*qualityState
+	lastFlush   time.Time
+}
+
+// qualityState tracks one thermostat's bins and polls
+type qualityState struct {
+	name        string
+	householdID string
+	firstBin    time.Time
+	bins        map[time.Time]qualityBin
+	polls       rollingWindow
+}
+
+// qualityBin records which readings a runtime bin carried
+type qualityBin struct {
+	hasTemp bool
+	sensors []string
+}
+
+// NewDataQualityTracker creates a data quality tracker
+func NewDataQualityTracker(config DataQualityConfig) *DataQualityTracker {
+	return &DataQualityTracker{
+		config:      config,
+		thermostats: make(map[string]*qualityState),
+	}
+}
+
+// Name identifies the analyzer
+func (t *DataQualityTracker) Name() string {
+	return DataQualityAnalyzerName
+}
+
+// Observe records a runtime bin; repeated rows for a bin replace each other
+func (t *DataQualityTracker) Observe(row *model.Runtime5m) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.stateFor(row.ThermostatID)
+	state.name = row.ThermostatName
+	state.householdID = row.HouseholdID
+
+	bin := row.EventTime.UTC().Truncate(runtimeBin)
+	if state.firstBin.IsZero() || bin.Before(state.firstBin) {
+		state.firstBin = bin
+	}
+	sensors := make([]string, 0, len(row.Sensors))
+	for id := range row.Sensors {
+		sensors = append(sensors, id)
+	}
+	state.bins[bin] = qualityBin{hasTemp: row.AvgTempC != nil, sensors: sensors}
+}
+
+// ObservePoll records the outcome of polling a thermostat
+func (t *DataQualityTracker) ObservePoll(thermostatID string, failed bool, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state := t.stateFor(thermostatID)
+	state.polls.prune(now.Add(-t.config.Window))
+	state.polls.requests = append(state.polls.requests, now)
+	if failed {
+		state.polls.errors = append(state.polls.errors, now)
+	}
+}
+
+// Scores returns the current data quality of every thermostat with bins due
+func (t *DataQualityTracker) Scores(now time.Time) map[string]DataQuality {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	scores := make(map[string]DataQuality, len(t.thermostats))
+	for thermostatID, state := range t.thermostats {
+		if quality, ok := t.score(state, now); ok {
+			scores[thermostatID] = quality
+		}
+	}
+	return scores
+}
+
+// Flush returns a data quality document per thermostat once each interval
+func (t *DataQualityTracker) Flush(now time.Time) []*model.Analysis {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	at := now.UTC().Truncate(t.config.Interval)
+	if !at.After(t.lastFlush) {
+		return nil
+	}
+	t.lastFlush = at
+
+	end := at.Add(-qualityReportingLag)
+	start := end.Add(-t.config.Window)
+	var results []*model.Analysis
+	for thermostatID, state := range t.thermostats {
+		for bin := range state.bins {
+			if bin.Before(start) {
+				delete(state.bins, bin)
+			}
+		}
+		quality, ok := t.score(state, at)
+		if !ok {
+			continue
+		}
+		results = append(results, &model.Analysis{
+			Type:           "analysis",
+			Analyzer:       DataQualityAnalyzerName,
+			ThermostatID:   thermostatID,
+			ThermostatName: state.name,
+			HouseholdID:    state.householdID,
+			PeriodStart:    start,
+			PeriodEnd:      end,
+			Results: map[string]any{
+				"score":           quality.Score,
+				"completeness":    quality.Completeness,
+				"sensor_coverage": quality.SensorCoverage,
+				"error_rate":      quality.ErrorRate,
+				"bins_expected":   quality.BinsExpected,
+				"bins_received":   quality.BinsReceived,
+				"polls":           quality.Polls,
+				"failed_polls":    quality.FailedPolls,
+			},
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].ThermostatID < results[j].ThermostatID
+	})
+	return results
+}
+
+// TrackDataQuality includes the tracker's scores in metrics
+func (m *MetricsCollector) TrackDataQuality(tracker *DataQualityTracker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dataQuality = tracker
+}
+
+// stateFor returns the tracking state for a thermostat, creating it if
+// needed. Callers must hold t.mu.
+func (t *DataQualityTracker) stateFor(thermostatID string) *qualityState {
+	state, ok := t.thermostats[thermostatID]
+	if !ok {
+		state = &qualityState{bins: make(map[time.Time]qualityBin)}
+		t.thermostats[thermostatID] = state
+	}
+	return state
+}
+
+// score computes a thermostat's data quality as of now. Bins are expected
+// from the window start, or the first bin seen if later, so a restart does
+// not count bins from before the process started as missing. It reports
+// false until at least one bin is due. Callers must hold t.mu.
+func (t *DataQualityTracker) score(state *qualityState, now time.Time) (DataQuality, bool) {
+	end := now.UTC().Add(-qualityReportingLag).Truncate(runtimeBin)
+	start := end.Add(-t.config.Window)
+	if state.firstBin.IsZero() {
+		return DataQuality{}, false
+	}
+	expectedFrom := start
+	if state.firstBin.After(start) {
+		expectedFrom = state.firstBin
+	}
+	if !end.After(expectedFrom) {
+		return DataQuality{}, false
+	}
+
+	var quality DataQuality
+	quality.BinsExpected = int(end.Sub(expectedFrom) / runtimeBin)
+
+	known := make(map[string]bool)
+	readings := 0
+	for bin, entry := range state.bins {
+		if bin.Before(start) || !bin.Before(end) {
+			continue
+		}
+		quality.BinsReceived++
+		if entry.hasTemp {
+			readings++
+		}
+		readings += len(entry.sensors)
+		for _, id := range entry.sensors {
+			known[id] = true
+		}
+	}
+
+	quality.Completeness = math.Min(float64(quality.BinsReceived)/float64(quality.BinsExpected), 1)
+	if quality.BinsReceived > 0 {
+		quality.SensorCoverage = float64(readings) / float64(quality.BinsReceived*(1+len(known)))
+	}
+
+	counts := state.polls.counts(now.Add(-t.config.Window))
+	quality.Polls, quality.FailedPolls = counts.requests, counts.errors
+	if counts.requests > 0 {
+		quality.ErrorRate = float64(counts.errors) / float64(counts.requests)
+	}
+
+	quality.Score = roundRatio(completenessWeight*quality.Completeness +
+		coverageWeight*quality.SensorCoverage +
+		pollSuccessWeight*(1-quality.ErrorRate))
+	quality.Completeness = roundRatio(quality.Completeness)
+	quality.SensorCoverage = roundRatio(quality.SensorCoverage)
+	quality.ErrorRate = roundRatio(quality.ErrorRate)
+	return quality, true
+}
+
+// roundRatio rounds a 0-1 ratio to three decimal places
+func roundRatio(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestDataQualityTracker(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	windowStart := now.Add(-qualityReportingLag - 2*time.Hour)
+
+	tests := []struct {
+		name         string
+		firstBin     time.Time
+		skip         map[int]bool // bin indexes not delivered
+		failedPolls  int
+		score        float64
+		completeness float64
+		coverage     float64
+		expected     int
+	}{
+		{
+			name:         "gaps, missing sensor readings and failed polls",
+			firstBin:     windowStart,
+			skip:         map[int]bool{3: true, 4: true, 10: true, 20: true},
+			failedPolls:  1,
+			score:        0.784,
+			completeness: 0.833,
+			coverage:     0.725,
+			expected:     24,
+		},
+		{
+			name:         "bins before the first one seen are not expected",
+			firstBin:     windowStart.Add(time.Hour),
+			score:        0.925,
+			completeness: 1,
+			coverage:     0.75,
+			expected:     12,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewDataQualityTracker(DataQualityConfig{Window: 2 * time.Hour, Interval: time.Hour})
+			for i := 0; ; i++ {
+				bin := tt.firstBin.Add(time.Duration(i) * runtimeBin)
+				if !bin.Before(now) {
+					break
+				}
+				if tt.skip[i] {
+					continue
+				}
+				row := &model.Runtime5m{ThermostatID: "t1", EventTime: bin, AvgTempC: floatPtr(21)}
+				if i%2 == 0 {
+					row.Sensors = map[string]float64{"s1": 20.5}
+				}
+				tracker.Observe(row)
+			}
+			for i := range 4 {
+				tracker.ObservePoll("t1", i < tt.failedPolls, now.Add(-time.Duration(i)*time.Minute))
+			}
+
+			quality, ok := tracker.Scores(now)["t1"]
+			if !ok {
+				t.Fatal("Expected a score for t1")
+			}
+			if quality.Score != tt.score || quality.Completeness != tt.completeness || quality.SensorCoverage != tt.coverage || quality.BinsExpected != tt.expected {
+				t.Errorf("Unexpected data quality: %+v", quality)
+			}
+
+			docs := tracker.Flush(now)
+			if len(docs) != 1 || docs[0].Analyzer != DataQualityAnalyzerName || docs[0].Results["score"] != tt.score {
+				t.Fatalf("Unexpected documents: %+v", docs)
+			}
+			if !docs[0].PeriodStart.Equal(windowStart) || !docs[0].PeriodEnd.Equal(now.Add(-qualityReportingLag)) {
+				t.Errorf("Unexpected period %v to %v", docs[0].PeriodStart, docs[0].PeriodEnd)
+			}
+			if docs := tracker.Flush(now.Add(30 * time.Minute)); len(docs) != 0 {
+				t.Errorf("Expected one document per interval, got %d more", len(docs))
+			}
+		})
+	}
+
+	t.Run("no score before a bin is due", func(t *testing.T) {
+		tracker := NewDataQualityTracker(DataQualityConfig{Window: 2 * time.Hour, Interval: time.Hour})
+		tracker.Observe(&model.Runtime5m{ThermostatID: "t1", EventTime: now.Add(-10 * time.Minute)})
+		if scores := tracker.Scores(now); len(scores) != 0 {
+			t.Errorf("Expected no scores, got %+v", scores)
+		}
+	})
+}
+
+func TestDataQualityMetrics(t *testing.T) {
+	tracker := NewDataQualityTracker(DataQualityConfig{Window: 2 * time.Hour, Interval: time.Hour})
+	tracker.Observe(&model.Runtime5m{ThermostatID: "t1", EventTime: time.Now().Add(-2 * time.Hour), AvgTempC: floatPtr(21)})
+
+	metrics := NewMetricsCollector()
+	metrics.TrackDataQuality(tracker)
+	if _, ok := metrics.GetMetrics().DataQuality["t1"]; !ok {
+		t.Fatal("Expected data quality in metrics")
+	}
+
+	var out bytes.Buffer
+	metrics.writePrometheus(&out)
+	if !strings.Contains(out.String(), `ttr_data_quality_score{thermostat="t1"}`) {
+		t.Errorf("Expected a data quality gauge, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"time"
+)
+
+// defaultErrorWindow is how long request outcomes are kept when no error budget is configured
+const defaultErrorWindow = 15 * time.Minute
+
+// ErrorBudget sets the rolling error rates at which a provider or sink is
+// reported as degraded or unhealthy
+type ErrorBudget struct {
+	// Window is how far back request outcomes are counted
+	Window time.Duration
+	// DegradedRate and UnhealthyRate are error fractions (0-1); a rate above
+	// either marks the provider or sink warn or fail respectively
+	DegradedRate  float64
+	UnhealthyRate float64
+	// MinRequests is the fewest requests in the window before the rate is
+	// judged, so a single failure after startup does not fail the service
+	MinRequests int
+}
+
+// ErrorRate is a provider's or sink's error rate over the error budget window
+type ErrorRate struct {
+	Requests int     `json:"requests"`
+	Errors   int     `json:"errors"`
+	Rate     float64 `json:"rate"`
+	Status   string  `json:"status"` // "pass", "fail", "warn"
+}
+
+// HealthOption configures optional HealthChecker behavior
+type HealthOption func(*HealthChecker)
+
+// WithErrorBudget makes health checks include rolling error rates from metrics
+// and degrade the overall status when a rate exceeds the budget
+func WithErrorBudget(metrics *MetricsCollector, budget ErrorBudget) HealthOption {
+	return func(h *HealthChecker) {
+		metrics.setErrorWindow(budget.Window)
+		h.metrics = metrics
+		h.budget = budget
+	}
+}
+
+// judge returns the error rate for counts and its status under the budget
+func (b ErrorBudget) judge(counts windowCounts) ErrorRate {
+	rate := ErrorRate{Requests: counts.requests, Errors: counts.errors, Status: "pass"}
+	if counts.requests == 0 {
+		return rate
+	}
+	rate.Rate = float64(counts.errors) / float64(counts.requests)
+	if counts.requests < b.MinRequests {
+		return rate
+	}
+
+	switch {
+	case rate.Rate > b.UnhealthyRate:
+		rate.Status = "fail"
+	case rate.Rate > b.DegradedRate:
+		rate.Status = "warn"
+	}
+	return rate
+}
+
+// windowCounts is the number of requests and errors seen in a rolling window
+type windowCounts struct {
+	requests int
+	errors   int
+}
+
+// rollingWindow records when requests and errors happened for one provider or sink
+type rollingWindow struct {
+	requests []time.Time
+	errors   []time.Time
+}
+
+// prune drops entries older than cutoff. Entries are appended in time order,
+// so the retained ones are always a suffix.
+func (w *rollingWindow) prune(cutoff time.Time) {
+	w.requests = dropBefore(w.requests, cutoff)
+	w.errors = dropBefore(w.errors, cutoff)
+}
+
+// counts returns the requests and errors at or after since
+func (w *rollingWindow) counts(since time.Time) windowCounts {
+	return windowCounts{
+		requests: len(dropBefore(w.requests, since)),
+		errors:   len(dropBefore(w.errors, since)),
+	}
+}
+
+// dropBefore returns the suffix of times at or after cutoff
+func dropBefore(times []time.Time, cutoff time.Time) []time.Time {
+	for i, t := range times {
+		if !t.Before(cutoff) {
+			return times[i:]
+		}
+	}
+	return nil
+}
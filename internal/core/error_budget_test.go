@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestErrorBudgetJudge(t *testing.T) {
+	budget := ErrorBudget{Window: 15 * time.Minute, DegradedRate: 0.2, UnhealthyRate: 0.5, MinRequests: 5}
+
+	tests := []struct {
+		name     string
+		counts   windowCounts
+		expected string
+	}{
+		{name: "no requests", counts: windowCounts{}, expected: "pass"},
+		{name: "within budget", counts: windowCounts{requests: 10, errors: 2}, expected: "pass"},
+		{name: "degraded", counts: windowCounts{requests: 10, errors: 3}, expected: "warn"},
+		{name: "unhealthy", counts: windowCounts{requests: 10, errors: 6}, expected: "fail"},
+		{name: "too few requests to judge", counts: windowCounts{requests: 2, errors: 2}, expected: "pass"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rate := budget.judge(tt.counts); rate.Status != tt.expected {
+				t.Errorf("Expected %s, got %s (rate %.2f)", tt.expected, rate.Status, rate.Rate)
+			}
+		})
+	}
+}
+
+func TestCheckHealthErrorBudget(t *testing.T) {
+	budget := ErrorBudget{Window: 15 * time.Minute, DegradedRate: 0.2, UnhealthyRate: 0.5, MinRequests: 5}
+
+	t.Run("provider error rate degrades status", func(t *testing.T) {
+		metrics := NewMetricsCollector()
+		for i := range 10 {
+			metrics.RecordProviderRequest("ecobee")
+			if i < 3 {
+				metrics.RecordProviderError("ecobee")
+			}
+		}
+		checker := NewHealthChecker(
+			[]model.Provider{&mockProvider{name: "ecobee", tokenValid: true}},
+			[]model.Sink{&mockSink{name: "elasticsearch"}},
+			WithErrorBudget(metrics, budget),
+		)
+
+		status := checker.CheckHealth(context.Background())
+
+		if status.Status != "degraded" {
+			t.Errorf("Expected status 'degraded', got %s", status.Status)
+		}
+		rate := status.ErrorRates["provider_ecobee"]
+		if rate.Requests != 10 || rate.Errors != 3 || rate.Status != "warn" {
+			t.Errorf("Unexpected provider error rate: %+v", rate)
+		}
+	})
+
+	t.Run("failing sink writes make status unhealthy", func(t *testing.T) {
+		metrics := NewMetricsCollector()
+		for range 5 {
+			metrics.RecordSinkError("elasticsearch")
+		}
+		checker := NewHealthChecker(
+			[]model.Provider{&mockProvider{name: "ecobee", tokenValid: true}},
+			[]model.Sink{&mockSink{name: "elasticsearch"}},
+			WithErrorBudget(metrics, budget),
+		)
+
+		status := checker.CheckHealth(context.Background())
+
+		if status.Status != "unhealthy" {
+			t.Errorf("Expected status 'unhealthy', got %s", status.Status)
+		}
+		if rate := status.ErrorRates["sink_elasticsearch"]; rate.Rate != 1 {
+			t.Errorf("Expected sink error rate 1, got %+v", rate)
+		}
+	})
+
+	t.Run("old errors fall out of the window", func(t *testing.T) {
+		window := &rollingWindow{}
+		now := time.Now()
+		window.requests = []time.Time{now.Add(-time.Hour), now}
+		window.errors = []time.Time{now.Add(-time.Hour)}
+
+		counts := window.counts(now.Add(-budget.Window))
+		if counts.requests != 1 || counts.errors != 0 {
+			t.Errorf("Expected only the recent request, got %+v", counts)
+		}
+	})
+}
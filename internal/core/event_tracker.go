@@ -0,0 +1,76 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// defaultEventRetention bounds how long ended events are remembered. Runtime
+// data lags snapshots, so events must outlive their end time for a while to
+// classify the transitions they caused.
+const defaultEventRetention = 24 * time.Hour
+
+// eventTracker remembers recent provider events per thermostat so that
+// runtime-derived transitions can be attributed to utility programs rather
+// than to people adjusting the thermostat
+type eventTracker struct {
+	mu        sync.Mutex
+	events    map[string][]model.Event
+	retention time.Duration
+}
+
+// newEventTracker creates an event tracker that forgets events ended longer than retention ago
+func newEventTracker(retention time.Duration) *eventTracker {
+	return &eventTracker{
+		events:    make(map[string][]model.Event),
+		retention: retention,
+	}
+}
+
+// Observe merges the events from a snapshot into the tracked set and prunes expired ones
+func (t *eventTracker) Observe(thermostatID string, events []model.Event, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	candidates := make([]model.Event, 0, len(events)+len(t.events[thermostatID]))
+	candidates = append(candidates, events...)
+	candidates = append(candidates, t.events[thermostatID]...)
+
+	merged := make([]model.Event, 0, len(candidates))
+	seen := make(map[string]bool)
+	for _, event := range candidates {
+		key := eventKey(event)
+		if seen[key] || t.expired(event, now) {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, event)
+	}
+
+	t.events[thermostatID] = merged
+}
+
+// DemandResponseAt returns the demand-response event covering the given time, if any
+func (t *eventTracker) DemandResponseAt(thermostatID string, at time.Time) (model.Event, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, event := range t.events[thermostatID] {
+		if event.Kind == model.EventKindDemandResponse && event.Covers(at) {
+			return event, true
+		}
+	}
+	return model.Event{}, false
+}
+
+// expired reports whether an event ended before the retention horizon
+func (t *eventTracker) expired(event model.Event, now time.Time) bool {
+	return !event.End.IsZero() && event.End.Before(now.Add(-t.retention))
+}
+
+// eventKey identifies an event across snapshots; newer snapshots win on merge
+func eventKey(event model.Event) string {
+	return event.Kind + "|" + event.Name + "|" + event.Start.Format(time.RFC3339)
+}
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestEventTracker(t *testing.T) {
+	start := time.Date(2024, 7, 15, 16, 0, 0, 0, time.UTC)
+	drEvent := model.Event{
+		Kind:  model.EventKindDemandResponse,
+		Name:  "Peak Relief",
+		Start: start,
+		End:   start.Add(3 * time.Hour),
+	}
+	holdEvent := model.Event{
+		Kind:  "hold",
+		Name:  "auto",
+		Start: start,
+	}
+
+	t.Run("demand response covers event window", func(t *testing.T) {
+		tracker := newEventTracker(defaultEventRetention)
+		tracker.Observe("therm-1", []model.Event{holdEvent, drEvent}, start)
+
+		tests := []struct {
+			name     string
+			at       time.Time
+			expected bool
+		}{
+			{"before start", start.Add(-time.Minute), false},
+			{"at start", start, true},
+			{"during", start.Add(time.Hour), true},
+			{"at end", start.Add(3 * time.Hour), false},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				event, ok := tracker.DemandResponseAt("therm-1", tt.at)
+				if ok != tt.expected {
+					t.Fatalf("Expected covered=%v, got %v", tt.expected, ok)
+				}
+				if ok && event.Name != "Peak Relief" {
+					t.Errorf("Expected Peak Relief event, got %s", event.Name)
+				}
+			})
+		}
+	})
+
+	t.Run("events are remembered after they leave the snapshot", func(t *testing.T) {
+		tracker := newEventTracker(defaultEventRetention)
+		tracker.Observe("therm-1", []model.Event{drEvent}, start)
+		tracker.Observe("therm-1", nil, start.Add(4*time.Hour))
+
+		if _, ok := tracker.DemandResponseAt("therm-1", start.Add(time.Hour)); !ok {
+			t.Error("Expected demand response event to be retained")
+		}
+	})
+
+	t.Run("expired events are pruned", func(t *testing.T) {
+		tracker := newEventTracker(time.Hour)
+		tracker.Observe("therm-1", []model.Event{drEvent}, start)
+		tracker.Observe("therm-1", nil, start.Add(5*time.Hour))
+
+		if _, ok := tracker.DemandResponseAt("therm-1", start.Add(time.Hour)); ok {
+			t.Error("Expected expired event to be pruned")
+		}
+	})
+
+	t.Run("thermostats are tracked independently", func(t *testing.T) {
+		tracker := newEventTracker(defaultEventRetention)
+		tracker.Observe("therm-1", []model.Event{drEvent}, start)
+
+		if _, ok := tracker.DemandResponseAt("therm-2", start.Add(time.Hour)); ok {
+			t.Error("Expected no demand response for other thermostat")
+		}
+	})
+}
+
+func TestClassifyTransition(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "es"}, NewMemoryOffsetStore())
+	at := time.Date(2024, 7, 15, 17, 0, 0, 0, time.UTC)
+	prev := model.State{Mode: "cool", SetCoolC: floatPtr(23.0), Climate: "Home"}
+	next := model.State{Mode: "cool", SetCoolC: floatPtr(25.0), Climate: "Home"}
+
+	if info := scheduler.classifyTransition("therm-1", at, prev, next); info.Kind != "hold" {
+		t.Errorf("Expected hold without demand response, got %s", info.Kind)
+	}
+
+	scheduler.events.Observe("therm-1", []model.Event{{
+		Kind:  model.EventKindDemandResponse,
+		Name:  "Utility Event",
+		Start: at.Add(-time.Hour),
+		End:   at.Add(time.Hour),
+	}}, at)
+
+	info := scheduler.classifyTransition("therm-1", at, prev, next)
+	if info.Kind != model.EventKindDemandResponse {
+		t.Errorf("Expected demand_response, got %s", info.Kind)
+	}
+	if info.Name != "Utility Event" {
+		t.Errorf("Expected event name to be carried, got %s", info.Name)
+	}
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ErrInjectedFault is the cause of failures added by fault injection
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultInjection sets how often failures are injected into provider calls and
+// sink writes, so retries, offsets and reconciliation can be exercised under
+// failure in CI. Rates are fractions from 0 to 1.
+type FaultInjection struct {
+	// ProviderErrorRate fails this fraction of provider calls as if they
+	// timed out
+	ProviderErrorRate float64
+	// SinkDelay is added before SinkDelayRate of sink writes; a delay past
+	// the sink's write timeout fails the write
+	SinkDelay     time.Duration
+	SinkDelayRate float64
+	// DropRate withholds this fraction of documents from sinks and reports
+	// them as failed
+	DropRate float64
+	// Seed makes the injected faults repeatable; zero seeds randomly
+	Seed uint64
+}
+
+// faultInjector decides which calls and documents fail
+type faultInjector struct {
+	FaultInjection
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// WithFaultInjection injects failures into provider calls and sink writes.
+// It is meant for testing and must not be enabled in production.
+func WithFaultInjection(faults FaultInjection) SchedulerOption {
+	return func(s *Scheduler) {
+		seed := faults.Seed
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+		s.faults = &faultInjector{
+			FaultInjection: faults,
+			// #nosec G404 - Non-cryptographic random is sufficient for fault injection
+			rng: rand.New(rand.NewPCG(seed, seed)),
+		}
+	}
+}
+
+// trip reports whether a fault with the given rate fires
+func (f *faultInjector) trip(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Float64() < rate
+}
+
+// failsProviderCall reports whether to fail the next provider call
+func (f *faultInjector) failsProviderCall() bool {
+	return f != nil && f.trip(f.ProviderErrorRate)
+}
+
+// writeSink writes docs to sink, delaying the write and dropping documents
+// at random when fault injection is enabled
+func (s *Scheduler) writeSink(ctx context.Context, sink model.Sink, docs []model.Doc) (model.WriteResult, error) {
+	if s.faults == nil {
+		return sink.Write(ctx, docs)
+	}
+
+	if s.faults.trip(s.faults.SinkDelayRate) && s.faults.SinkDelay > 0 {
+		s.logger.Debug("Injecting sink delay", "sink", sink.Info().Name, "delay", s.faults.SinkDelay)
+		select {
+		case <-time.After(s.faults.SinkDelay):
+		case <-ctx.Done():
+			return model.WriteResult{}, fmt.Errorf("delaying write: %w", context.Cause(ctx))
+		}
+	}
+
+	kept := make([]model.Doc, 0, len(docs))
+	var dropped []string
+	for _, doc := range docs {
+		if s.faults.trip(s.faults.DropRate) {
+			dropped = append(dropped, doc.ID)
+			continue
+		}
+		kept = append(kept, doc)
+	}
+
+	var result model.WriteResult
+	if len(kept) > 0 {
+		var err error
+		result, err = sink.Write(ctx, kept)
+		if err != nil {
+			return result, err
+		}
+	}
+	if len(dropped) > 0 {
+		s.logger.Debug("Injecting dropped documents", "sink", sink.Info().Name, "count", len(dropped))
+	}
+	for _, id := range dropped {
+		result.ErrorCount++
+		result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", id, ErrInjectedFault))
+	}
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestFaultInjectionFailsProviderCalls(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "test"}, NewMemoryOffsetStore(),
+		WithFaultInjection(FaultInjection{ProviderErrorRate: 1}))
+
+	ctx, cancel := scheduler.providerContext(testContext(t), "ecobee")
+	defer cancel()
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.Errorf("Expected an expired context, got %v", ctx.Err())
+	}
+	if !errors.Is(context.Cause(ctx), ErrInjectedFault) {
+		t.Errorf("Expected the injected fault as cause, got %v", context.Cause(ctx))
+	}
+}
+
+func TestFaultInjectionDropsDocuments(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "ecobee"}
+	start := time.Now().Truncate(runtimeBin).Add(-time.Hour)
+	rows := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: start, Mode: "heat", AvgTempC: floatPtr(20)},
+		{ThermostatRef: thermostat, EventTime: start.Add(runtimeBin), Mode: "heat", AvgTempC: floatPtr(20)},
+	}
+
+	sink := &recordingSink{mockSink: mockSink{name: "test"}}
+	store := NewMemoryOffsetStore()
+	provider := &mockProvider{name: "ecobee"}
+	scheduler := newTestScheduler(provider, sink, store, WithFaultInjection(FaultInjection{DropRate: 1, Seed: 1}))
+
+	if err := scheduler.processRuntime(testContext(t), provider, thermostat, rows); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(sink.docs) != 0 {
+		t.Errorf("Expected every document dropped, sink received %d", len(sink.docs))
+	}
+	if count := scheduler.metrics.GetMetrics().Sinks["test"].ErrorsTotal; count != 1 {
+		t.Errorf("Expected the dropped documents recorded as a sink error, got %d errors", count)
+	}
+}
+
+func TestFaultInjectionDelayPastWriteTimeout(t *testing.T) {
+	sink := &recordingSink{mockSink: mockSink{name: "test"}}
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, sink, NewMemoryOffsetStore(),
+		WithSinkTimeout("test", 10*time.Millisecond),
+		WithFaultInjection(FaultInjection{SinkDelay: time.Second, SinkDelayRate: 1}))
+
+	docs := []model.Doc{{ID: "doc-1", Type: "runtime_5m", Body: &model.Runtime5m{}}}
+	if clean := scheduler.writeBatch(testContext(t), docs); clean {
+		t.Error("Expected the delayed write to fail")
+	}
+	if len(sink.docs) != 0 {
+		t.Errorf("Expected no documents written, got %d", len(sink.docs))
+	}
+}
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// firmwareChange returns a firmware_change document when a snapshot reports
+// another firmware version than the thermostat's previous snapshot did. The
+// first snapshot after startup only sets the baseline, as do snapshots
+// without a version.
+func (s *Scheduler) firmwareChange(thermostat model.ThermostatRef, snapshot *model.DeviceSnapshot) *model.Doc {
+	previous, known := s.firmware[thermostat.ID]
+	if !known || snapshot.FirmwareVersion == "" || snapshot.FirmwareVersion == previous {
+		return nil
+	}
+
+	change := &model.FirmwareChange{
+		Type:           "firmware_change",
+		EventTime:      snapshot.CollectedAt,
+		ThermostatID:   thermostat.ID,
+		ThermostatName: snapshot.ThermostatName,
+		HouseholdID:    thermostat.HouseholdID,
+		Model:          snapshot.Model,
+		PrevVersion:    previous,
+		NextVersion:    snapshot.FirmwareVersion,
+	}
+	docID, err := s.idGenerator.GenerateFirmwareChangeID(change)
+	if err != nil {
+		s.logger.Error("Failed to generate document ID for firmware change", "error", err)
+		return nil
+	}
+	s.logger.Info("Thermostat firmware changed",
+		"thermostat", thermostat.ID,
+		"from", previous,
+		"to", snapshot.FirmwareVersion)
+	return &model.Doc{ID: docID, Type: "firmware_change", Body: change}
+}
+
+// recordFirmware remembers the firmware version of a written snapshot
+func (s *Scheduler) recordFirmware(thermostatID string, snapshot *model.DeviceSnapshot) {
+	if snapshot.FirmwareVersion != "" {
+		s.firmware[thermostatID] = snapshot.FirmwareVersion
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// firmwareProvider returns snapshots reporting its current firmware version
+type firmwareProvider struct {
+	mockProvider
+	firmware string
+}
+
+func (p *firmwareProvider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	return model.Snapshot{ThermostatRef: tr, CollectedAt: time.Now(), Model: "athenaSmart", FirmwareVersion: p.firmware}, nil
+}
+
+func TestFirmwareChange(t *testing.T) {
+	ctx := testContext(t)
+	provider := &firmwareProvider{mockProvider: mockProvider{name: "ecobee"}}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore())
+	thermostat := model.ThermostatRef{ID: "therm-1", Name: "Hallway", Provider: "ecobee"}
+
+	for _, firmware := range []string{"", "4.8.7.132", "4.8.7.132", "4.8.8.21"} {
+		provider.firmware = firmware
+		if err := scheduler.fetchAndProcessSnapshot(ctx, provider, thermostat, "rev"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	var changes []*model.FirmwareChange
+	for _, doc := range sink.docs {
+		if doc.Type == "firmware_change" {
+			changes = append(changes, doc.Body.(*model.FirmwareChange))
+		}
+	}
+	if len(changes) != 1 {
+		t.Fatalf("Expected 1 firmware change, got %d", len(changes))
+	}
+	change := changes[0]
+	if change.PrevVersion != "4.8.7.132" || change.NextVersion != "4.8.8.21" || change.Model != "athenaSmart" || change.ThermostatName != "Hallway" {
+		t.Errorf("Unexpected firmware change %+v", change)
+	}
+	if snapshot := sink.docs[0].Body.(*model.DeviceSnapshot); snapshot.Model != "athenaSmart" {
+		t.Errorf("Expected the snapshot to carry the model, got %q", snapshot.Model)
+	}
+}
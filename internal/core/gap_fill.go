@@ -0,0 +1,91 @@
+package core
+
+import (
+	"maps"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// GapFillPolicy decides how missing runtime bins between fetched rows are
+// filled
+type GapFillPolicy string
+
+const (
+	// GapFillNone leaves gaps empty
+	GapFillNone GapFillPolicy = "none"
+	// GapFillForward repeats the row before the gap in each missing bin
+	GapFillForward GapFillPolicy = "forward"
+	// GapFillNull writes a row with no readings in each missing bin, so a
+	// missing bin is not mistaken for one with the equipment off
+	GapFillNull GapFillPolicy = "null"
+)
+
+// gapFill holds the gap fill policy
+type gapFill struct {
+	policy  GapFillPolicy
+	maxBins int
+}
+
+// WithGapFill fills gaps of up to maxBins missing bins between runtime rows
+// with rows flagged filled. Longer gaps, such as outages, are left empty.
+func WithGapFill(policy GapFillPolicy, maxBins int) SchedulerOption {
+	return func(s *Scheduler) {
+		if policy == "" || policy == GapFillNone || maxBins <= 0 {
+			return
+		}
+		s.gapFill = &gapFill{policy: policy, maxBins: maxBins}
+	}
+}
+
+// filledRows fills the gaps between rows under the gap fill policy. Only
+// gaps between rows fetched together are filled, so the runtime offset is
+// unchanged.
+func (s *Scheduler) filledRows(thermostatID string, rows []model.RuntimeRow) []model.RuntimeRow {
+	if s.gapFill == nil || len(rows) < 2 {
+		return rows
+	}
+
+	filled := make([]model.RuntimeRow, 0, len(rows))
+	for i, row := range rows {
+		if i > 0 {
+			prev := rows[i-1]
+			missing := int(row.EventTime.Sub(prev.EventTime)/runtimeBin) - 1
+			if missing > 0 && missing <= s.gapFill.maxBins {
+				for bin := 1; bin <= missing; bin++ {
+					filled = append(filled, s.gapFill.row(prev, bin))
+				}
+			}
+		}
+		filled = append(filled, row)
+	}
+
+	if added := len(filled) - len(rows); added > 0 {
+		s.logger.Debug("Filled missing runtime bins",
+			"thermostat", thermostatID,
+			"bins", added,
+			"policy", s.gapFill.policy)
+	}
+	return filled
+}
+
+// row builds the filled row for the bin'th bin after prev
+func (g *gapFill) row(prev model.RuntimeRow, bin int) model.RuntimeRow {
+	eventTime := prev.EventTime.Add(runtimeBin * time.Duration(bin))
+	if g.policy == GapFillNull {
+		return model.RuntimeRow{
+			ThermostatRef: prev.ThermostatRef,
+			Unit:          prev.Unit,
+			EventTime:     eventTime,
+			Filled:        true,
+		}
+	}
+
+	row := prev
+	row.EventTime = eventTime
+	row.Equipment = maps.Clone(prev.Equipment)
+	row.EquipmentSecs = maps.Clone(prev.EquipmentSecs)
+	row.Sensors = maps.Clone(prev.Sensors)
+	row.Filled = true
+	return row
+}
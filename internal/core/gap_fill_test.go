@@ -0,0 +1,84 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestGapFill(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "ecobee"}
+	start := time.Now().Truncate(runtimeBin).Add(-2 * time.Hour)
+	row := func(offset time.Duration, mode string, fan bool) model.RuntimeRow {
+		return model.RuntimeRow{ThermostatRef: thermostat, EventTime: start.Add(offset), Mode: mode,
+			AvgTempC: floatPtr(20), Equipment: map[string]bool{"fan": fan}}
+	}
+	// two missing bins after the first row, then a gap longer than max_bins
+	rows := []model.RuntimeRow{row(0, "heat", true), row(15*time.Minute, "heat", false), row(time.Hour, "heat", false)}
+
+	tests := []struct {
+		name        string
+		policy      GapFillPolicy
+		expectDocs  int
+		expectModes []string
+		expectFan   []bool
+	}{
+		{name: "none leaves gaps", policy: GapFillNone, expectDocs: 3},
+		{name: "forward repeats the previous row", policy: GapFillForward, expectDocs: 5,
+			expectModes: []string{"heat", "heat"}, expectFan: []bool{true, true}},
+		{name: "null writes empty rows", policy: GapFillNull, expectDocs: 5,
+			expectModes: []string{"", ""}, expectFan: []bool{false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{mockSink: mockSink{name: "test"}}
+			store := NewMemoryOffsetStore()
+			provider := &mockProvider{name: "ecobee"}
+			scheduler := newTestScheduler(provider, sink, store, WithGapFill(tt.policy, 4))
+
+			if err := scheduler.processRuntime(testContext(t), provider, thermostat, rows); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var runtimes []*model.Runtime5m
+			for _, doc := range sink.docs {
+				switch doc.Type {
+				case "runtime_5m":
+					runtimes = append(runtimes, doc.Body.(*model.Runtime5m))
+				case "transition":
+					t.Errorf("Unexpected transition at %v", doc.Body.(*model.Transition).EventTime)
+				}
+			}
+			if len(runtimes) != tt.expectDocs {
+				t.Fatalf("Expected %d runtime documents, got %d", tt.expectDocs, len(runtimes))
+			}
+
+			var modes []string
+			var fan []bool
+			for _, runtime := range runtimes {
+				if runtime.Filled {
+					modes = append(modes, runtime.Mode)
+					fan = append(fan, runtime.Equipment["fan"])
+				}
+			}
+			if len(modes) != len(tt.expectModes) {
+				t.Fatalf("Expected %d filled documents, got %d", len(tt.expectModes), len(modes))
+			}
+			for i := range modes {
+				if modes[i] != tt.expectModes[i] || fan[i] != tt.expectFan[i] {
+					t.Errorf("Filled document %d: expected mode %q fan %v, got mode %q fan %v", i, tt.expectModes[i], tt.expectFan[i], modes[i], fan[i])
+				}
+			}
+
+			offset, err := store.GetLastRuntimeTime(testContext(t), thermostat.ID)
+			if err != nil {
+				t.Fatalf("Failed to read offset: %v", err)
+			}
+			if !offset.Equal(start.Add(time.Hour)) {
+				t.Errorf("Expected offset %v, got %v", start.Add(time.Hour), offset)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"sync"
 	"time"
@@ -11,19 +12,35 @@ import (
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
 )
 
+// Default health check timeouts
+const (
+	defaultCheckTimeout  = 5 * time.Second
+	defaultHealthTimeout = 10 * time.Second
+)
+
 // HealthChecker provides health check functionality
 type HealthChecker struct {
-	providers []model.Provider
-	sinks     []model.Sink
-	mu        sync.RWMutex
-	status    HealthStatus
+	providers     []model.Provider
+	sinks         []model.Sink
+	metrics       *MetricsCollector
+	budget        ErrorBudget
+	watchdog      *WriteWatchdog
+	checkTimeout  time.Duration
+	healthTimeout time.Duration
+	mu            sync.RWMutex
+	status        HealthStatus
 }
 
 // HealthStatus represents the overall health status
 type HealthStatus struct {
-	Status    string                 `json:"status"` // "healthy", "degraded", "unhealthy"
-	Timestamp time.Time              `json:"timestamp"`
-	Checks    map[string]CheckResult `json:"checks"`
+	SchemaVersion int                    `json:"schema_version"`
+	Status        string                 `json:"status"` // "healthy", "degraded", "unhealthy"
+	Timestamp     time.Time              `json:"timestamp"`
+	Checks        map[string]CheckResult `json:"checks"`
+	// ErrorRates are rolling error rates keyed like Checks; present only
+	// when an error budget is configured
+	ErrorRates   map[string]ErrorRate `json:"error_rates,omitempty"`
+	Deprecations []Deprecation        `json:"deprecations,omitempty"`
 }
 
 // CheckResult represents the result of a health check
@@ -32,6 +49,8 @@ type CheckResult struct {
 	Message     string `json:"message,omitempty"`
 	DurationMS  int64  `json:"duration_ms"`
 	LastChecked string `json:"last_checked"`
+	// TimedOut is set when the check did not finish within its timeout
+	TimedOut bool `json:"timed_out,omitempty"`
 }
 
 // newCheckResult creates a CheckResult with proper formatting
@@ -45,56 +64,161 @@ func newCheckResult(status, message string, duration time.Duration) CheckResult
 }
 
 // NewHealthChecker creates a new health checker
-func NewHealthChecker(providers []model.Provider, sinks []model.Sink) *HealthChecker {
-	return &HealthChecker{
-		providers: providers,
-		sinks:     sinks,
+func NewHealthChecker(providers []model.Provider, sinks []model.Sink, opts ...HealthOption) *HealthChecker {
+	h := &HealthChecker{
+		providers:     providers,
+		sinks:         sinks,
+		checkTimeout:  defaultCheckTimeout,
+		healthTimeout: defaultHealthTimeout,
 		status: HealthStatus{
-			Status: "healthy",
-			Checks: make(map[string]CheckResult),
+			SchemaVersion: HealthSchemaVersion,
+			Status:        "healthy",
+			Checks:        make(map[string]CheckResult),
 		},
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// WithCheckTimeouts bounds each provider and sink check by check, and all
+// checks together by overall
+func WithCheckTimeouts(check, overall time.Duration) HealthOption {
+	return func(h *HealthChecker) {
+		h.checkTimeout = check
+		h.healthTimeout = overall
+	}
 }
 
-// CheckHealth performs all health checks
+// componentCheck is one provider or sink check
+type componentCheck struct {
+	name string
+	run  func(ctx context.Context) CheckResult
+	// timeoutStatus is reported when the check times out, matching how
+	// serious a failure of the component is
+	timeoutStatus string
+}
+
+// CheckHealth performs all health checks concurrently
 func (h *HealthChecker) CheckHealth(ctx context.Context) HealthStatus {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	checks := make(map[string]CheckResult)
-
-	// Check providers
+	components := make([]componentCheck, 0, len(h.providers)+len(h.sinks))
 	for _, provider := range h.providers {
-		check := h.checkProvider(ctx, provider)
-		checks[fmt.Sprintf("provider_%s", provider.Info().Name)] = check
+		components = append(components, componentCheck{
+			name:          fmt.Sprintf("provider_%s", provider.Info().Name),
+			run:           func(ctx context.Context) CheckResult { return h.checkProvider(ctx, provider) },
+			timeoutStatus: "warn",
+		})
 	}
-
-	// Check sinks
 	for _, sink := range h.sinks {
-		check := h.checkSink(ctx, sink)
-		checks[fmt.Sprintf("sink_%s", sink.Info().Name)] = check
+		components = append(components, componentCheck{
+			name:          fmt.Sprintf("sink_%s", sink.Info().Name),
+			run:           func(ctx context.Context) CheckResult { return h.checkSink(ctx, sink) },
+			timeoutStatus: "fail",
+		})
+	}
+	checks := h.runChecks(ctx, components)
+	if h.watchdog != nil {
+		checks["watchdog"] = h.watchdog.check(time.Now())
 	}
 
+	errorRates := h.errorRates()
+
 	// Determine overall status
-	overallStatus := "healthy"
+	statuses := make([]string, 0, len(checks)+len(errorRates))
 	for _, check := range checks {
-		if check.Status == "fail" {
+		statuses = append(statuses, check.Status)
+	}
+	for _, rate := range errorRates {
+		statuses = append(statuses, rate.Status)
+	}
+	overallStatus := "healthy"
+	for _, status := range statuses {
+		if status == "fail" {
 			overallStatus = "unhealthy"
 			break
-		} else if check.Status == "warn" {
+		} else if status == "warn" {
 			overallStatus = "degraded"
 		}
 	}
 
 	h.status = HealthStatus{
-		Status:    overallStatus,
-		Timestamp: time.Now(),
-		Checks:    checks,
+		SchemaVersion: HealthSchemaVersion,
+		Status:        overallStatus,
+		Timestamp:     time.Now(),
+		Checks:        checks,
+		ErrorRates:    errorRates,
+		Deprecations:  healthDeprecations,
 	}
 
 	return h.status
 }
 
+// runChecks runs every check in its own goroutine. A check that outlives its
+// timeout or the overall deadline is reported as timed out; it is left to
+// finish in the background and its result is discarded.
+func (h *HealthChecker) runChecks(ctx context.Context, components []componentCheck) map[string]CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, h.healthTimeout)
+	defer cancel()
+
+	type namedResult struct {
+		name   string
+		result CheckResult
+	}
+	results := make(chan namedResult, len(components))
+	for _, component := range components {
+		go func() {
+			start := time.Now()
+			checkCtx, cancel := context.WithTimeout(ctx, h.checkTimeout)
+			defer cancel()
+
+			done := make(chan CheckResult, 1)
+			go func() {
+				done <- component.run(checkCtx)
+			}()
+
+			var result CheckResult
+			select {
+			case result = <-done:
+			case <-checkCtx.Done():
+			}
+			if checkCtx.Err() != nil {
+				result = newCheckResult(component.timeoutStatus, fmt.Sprintf("Check timed out after %v", time.Since(start).Round(time.Millisecond)), time.Since(start))
+				result.TimedOut = true
+			}
+			results <- namedResult{name: component.name, result: result}
+		}()
+	}
+
+	checks := make(map[string]CheckResult, len(components))
+	for range components {
+		named := <-results
+		checks[named.name] = named.result
+	}
+	return checks
+}
+
+// errorRates judges each provider's and sink's rolling error rate against the
+// error budget. It returns nil when no budget is configured.
+func (h *HealthChecker) errorRates() map[string]ErrorRate {
+	if h.metrics == nil {
+		return nil
+	}
+
+	providers, sinks := h.metrics.recentCounts(time.Now().Add(-h.budget.Window))
+	rates := make(map[string]ErrorRate, len(providers)+len(sinks))
+	for name, counts := range providers {
+		rates[fmt.Sprintf("provider_%s", name)] = h.budget.judge(counts)
+	}
+	for name, counts := range sinks {
+		rates[fmt.Sprintf("sink_%s", name)] = h.budget.judge(counts)
+	}
+	return rates
+}
+
 // GetStatus returns the current health status
 func (h *HealthChecker) GetStatus() HealthStatus {
 	h.mu.RLock()
@@ -128,12 +252,8 @@ func (h *HealthChecker) checkProvider(ctx context.Context, provider model.Provid
 func (h *HealthChecker) checkSink(ctx context.Context, sink model.Sink) CheckResult {
 	start := time.Now()
 
-	// Create a short-lived context for the health check
-	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
 	// Test sink connectivity by attempting to open it
-	if err := sink.Open(checkCtx); err != nil {
+	if err := sink.Open(ctx); err != nil {
 		return newCheckResult("fail", fmt.Sprintf("Sink connectivity failed: %v", err), time.Since(start))
 	}
 
@@ -143,6 +263,9 @@ func (h *HealthChecker) checkSink(ctx context.Context, sink model.Sink) CheckRes
 // ServeHealth provides an HTTP handler for health checks
 func (h *HealthChecker) ServeHealth() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptSchemaVersion(w, r, HealthSchemaVersion) {
+			return
+		}
 		ctx := r.Context()
 		status := h.CheckHealth(ctx)
 
@@ -162,9 +285,10 @@ func (h *HealthChecker) ServeHealth() http.Handler {
 		if err := json.NewEncoder(w).Encode(status); err != nil {
 			// Log error but don't change status code (already written)
 			errorResp := HealthStatus{
-				Status:    "unhealthy",
-				Timestamp: time.Now(),
-				Checks:    map[string]CheckResult{},
+				SchemaVersion: HealthSchemaVersion,
+				Status:        "unhealthy",
+				Timestamp:     time.Now(),
+				Checks:        map[string]CheckResult{},
 			}
 			_ = json.NewEncoder(w).Encode(errorResp)
 		}
@@ -179,50 +303,152 @@ type MetricsCollector struct {
 	providerRequests    map[string]int64
 	providerErrors      map[string]int64
 	providerLastRequest map[string]time.Time
+	providerBudgets     map[string]BudgetMetrics
+
+	// Snapshot cache lookups, keyed by provider
+	snapshotCacheHits   map[string]int64
+	snapshotCacheMisses map[string]int64
+
+	// Bytes downloaded, rows parsed and snapshots fetched, keyed by provider
+	providerBytes   map[string]model.TrafficReporter
+	providerTraffic map[string]*providerTraffic
 
 	// Sink metrics
 	sinkWrites           map[string]int64
 	sinkErrors           map[string]int64
 	sinkLastWrite        map[string]time.Time
 	sinkDocumentsWritten map[string]int64
+	sinkLatency          map[string]*latencyHistogram
+	sinkBatches          map[string]*sinkBatchStats
+
+	// Read-back verifications of written documents
+	sinkVerifications        map[string]int64
+	sinkVerificationFailures map[string]int64
+
+	// Alert metrics, keyed by alert kind
+	alerts map[string]int64
+
+	// The latest reconciliation report and how many reports had discrepancies
+	reconciliation              *ReconciliationReport
+	reconciliationDiscrepancies int64
+
+	// The latest resource sample and growth warnings, keyed by resource
+	resources      *ResourceUsage
+	resourceGrowth map[string]int64
+
+	// Whether each thermostat is connected, for providers that report it
+	connectivity map[string]bool
+
+	// Documents handed to sinks but not yet acknowledged
+	inflight InflightMetrics
+
+	// Rolling request outcomes for error budgets
+	errorWindow     time.Duration
+	providerWindows map[string]*rollingWindow
+	sinkWindows     map[string]*rollingWindow
+
+	// Scheduler phase, cycle times and thermostat statuses
+	scheduler schedulerTracker
+
+	// Per-thermostat data quality scores, when tracked
+	dataQuality *DataQualityTracker
 
 	// General metrics
 	startTime time.Time
+	buildInfo BuildInfo
+
+	// Fingerprints of the running configuration and, when they differ, of
+	// the configuration files as last read
+	configFingerprint     string
+	diskConfigFingerprint string
 }
 
 // Metrics represents the overall metrics structure
 type Metrics struct {
+	SchemaVersion int                        `json:"schema_version"`
 	UptimeSeconds float64                    `json:"uptime_seconds"`
 	Providers     map[string]ProviderMetrics `json:"providers"`
 	Sinks         map[string]SinkMetrics     `json:"sinks"`
+	Alerts        map[string]int64           `json:"alerts,omitempty"`
+	Connected     map[string]bool            `json:"connected,omitempty"` // keyed by thermostat ID
+	Inflight      InflightMetrics            `json:"inflight"`
+	Scheduler     SchedulerState             `json:"scheduler"`
+	DataQuality   map[string]DataQuality     `json:"data_quality,omitempty"` // keyed by thermostat ID
+	Deprecations  []Deprecation              `json:"deprecations,omitempty"`
+	// Reconciliation is the latest reconciliation report, when enabled
+	Reconciliation              *ReconciliationReport `json:"reconciliation,omitempty"`
+	ReconciliationDiscrepancies int64                 `json:"reconciliation_discrepancies_total,omitempty"`
+	// Resources is the latest resource sample, when monitored
+	Resources              *ResourceUsage   `json:"resources,omitempty"`
+	ResourceGrowthWarnings map[string]int64 `json:"resource_growth_warnings_total,omitempty"`
 }
 
 // ProviderMetrics represents metrics for a provider
 type ProviderMetrics struct {
-	RequestsTotal   int64  `json:"requests_total"`
-	ErrorsTotal     int64  `json:"errors_total"`
-	LastRequestTime string `json:"last_request_time"`
+	RequestsTotal   int64          `json:"requests_total"`
+	ErrorsTotal     int64          `json:"errors_total"`
+	LastRequestTime string         `json:"last_request_time"`
+	Budget          *BudgetMetrics `json:"request_budget,omitempty"`
+	// Snapshot requests answered from the cache and made because it missed
+	SnapshotCacheHits   int64 `json:"snapshot_cache_hits_total,omitempty"`
+	SnapshotCacheMisses int64 `json:"snapshot_cache_misses_total,omitempty"`
+	// Response bytes downloaded, runtime rows returned and rejected by
+	// normalization, and snapshots requested rather than cached
+	BytesFetched        int64 `json:"bytes_fetched_total,omitempty"`
+	RuntimeRowsParsed   int64 `json:"runtime_rows_parsed_total,omitempty"`
+	RuntimeRowsRejected int64 `json:"runtime_rows_rejected_total,omitempty"`
+	SnapshotsFetched    int64 `json:"snapshots_fetched_total,omitempty"`
+}
+
+// InflightMetrics represents the batch being written to sinks and the
+// batch limits. Zero limits mean no limit.
+type InflightMetrics struct {
+	Documents    int   `json:"documents"`
+	Bytes        int64 `json:"bytes"`
+	MaxDocuments int   `json:"max_documents"`
+	MaxBytes     int64 `json:"max_bytes"`
 }
 
 // SinkMetrics represents metrics for a sink
 type SinkMetrics struct {
-	WritesTotal      int64  `json:"writes_total"`
-	ErrorsTotal      int64  `json:"errors_total"`
-	DocumentsWritten int64  `json:"documents_written"`
-	LastWriteTime    string `json:"last_write_time"`
+	WritesTotal      int64             `json:"writes_total"`
+	ErrorsTotal      int64             `json:"errors_total"`
+	DocumentsWritten int64             `json:"documents_written"`
+	LastWriteTime    string            `json:"last_write_time"`
+	Latency          *LatencyHistogram `json:"event_to_write_latency,omitempty"`
+	Batches          *BatchMetrics     `json:"batches,omitempty"`
+	// Verifications counts written documents read back to check they were stored
+	Verifications        int64 `json:"verifications_total,omitempty"`
+	VerificationFailures int64 `json:"verification_failures_total,omitempty"`
 }
 
 // NewMetricsCollector creates a new metrics collector
 func NewMetricsCollector() *MetricsCollector {
 	return &MetricsCollector{
-		providerRequests:     make(map[string]int64),
-		providerErrors:       make(map[string]int64),
-		providerLastRequest:  make(map[string]time.Time),
-		sinkWrites:           make(map[string]int64),
-		sinkErrors:           make(map[string]int64),
-		sinkLastWrite:        make(map[string]time.Time),
-		sinkDocumentsWritten: make(map[string]int64),
-		startTime:            time.Now(),
+		providerRequests:         make(map[string]int64),
+		providerErrors:           make(map[string]int64),
+		providerLastRequest:      make(map[string]time.Time),
+		providerBudgets:          make(map[string]BudgetMetrics),
+		snapshotCacheHits:        make(map[string]int64),
+		snapshotCacheMisses:      make(map[string]int64),
+		providerBytes:            make(map[string]model.TrafficReporter),
+		providerTraffic:          make(map[string]*providerTraffic),
+		sinkWrites:               make(map[string]int64),
+		sinkErrors:               make(map[string]int64),
+		sinkLastWrite:            make(map[string]time.Time),
+		sinkDocumentsWritten:     make(map[string]int64),
+		sinkLatency:              make(map[string]*latencyHistogram),
+		sinkBatches:              make(map[string]*sinkBatchStats),
+		sinkVerifications:        make(map[string]int64),
+		sinkVerificationFailures: make(map[string]int64),
+		alerts:                   make(map[string]int64),
+		resourceGrowth:           make(map[string]int64),
+		connectivity:             make(map[string]bool),
+		errorWindow:              defaultErrorWindow,
+		providerWindows:          make(map[string]*rollingWindow),
+		sinkWindows:              make(map[string]*rollingWindow),
+		scheduler:                schedulerTracker{phase: PhaseStarting, thermostats: make(map[string]thermostatStatus)},
+		startTime:                time.Now(),
 	}
 }
 
@@ -231,8 +457,11 @@ func (m *MetricsCollector) RecordProviderRequest(providerName string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	now := time.Now()
 	m.providerRequests[providerName]++
-	m.providerLastRequest[providerName] = time.Now()
+	m.providerLastRequest[providerName] = now
+	window := m.window(m.providerWindows, providerName, now)
+	window.requests = append(window.requests, now)
 }
 
 // RecordProviderError records a provider error
@@ -241,6 +470,9 @@ func (m *MetricsCollector) RecordProviderError(providerName string) {
 	defer m.mu.Unlock()
 
 	m.providerErrors[providerName]++
+	now := time.Now()
+	window := m.window(m.providerWindows, providerName, now)
+	window.errors = append(window.errors, now)
 }
 
 // RecordSinkWrite records a sink write operation
@@ -248,17 +480,83 @@ func (m *MetricsCollector) RecordSinkWrite(sinkName string, documentCount int64)
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	now := time.Now()
 	m.sinkWrites[sinkName]++
 	m.sinkDocumentsWritten[sinkName] += documentCount
-	m.sinkLastWrite[sinkName] = time.Now()
+	m.sinkLastWrite[sinkName] = now
+	window := m.window(m.sinkWindows, sinkName, now)
+	window.requests = append(window.requests, now)
 }
 
-// RecordSinkError records a sink error
+// RecordSinkError records a sink error. For error rates it also counts as a
+// write attempt, since failed writes are not recorded with RecordSinkWrite.
 func (m *MetricsCollector) RecordSinkError(sinkName string) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.sinkErrors[sinkName]++
+	now := time.Now()
+	window := m.window(m.sinkWindows, sinkName, now)
+	window.requests = append(window.requests, now)
+	window.errors = append(window.errors, now)
+}
+
+// RecordAlert records an alert of the given kind
+func (m *MetricsCollector) RecordAlert(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.alerts[kind]++
+}
+
+// RecordInflight records the batch being written and the batch limits
+func (m *MetricsCollector) RecordInflight(docs int, bytes int64, maxDocs int, maxBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.inflight.Documents = docs
+	m.inflight.Bytes = bytes
+	m.inflight.MaxDocuments = maxDocs
+	m.inflight.MaxBytes = maxBytes
+}
+
+// window returns the pruned rolling window for name, creating it if needed.
+// Callers must hold m.mu.
+func (m *MetricsCollector) window(windows map[string]*rollingWindow, name string, now time.Time) *rollingWindow {
+	window, ok := windows[name]
+	if !ok {
+		window = &rollingWindow{}
+		windows[name] = window
+	}
+	window.prune(now.Add(-m.errorWindow))
+	return window
+}
+
+// setErrorWindow sets how long request outcomes are kept for error rates
+func (m *MetricsCollector) setErrorWindow(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if d > 0 {
+		m.errorWindow = d
+	}
+}
+
+// recentCounts returns request and error counts since the given time for
+// every provider and sink that has recorded a request
+func (m *MetricsCollector) recentCounts(since time.Time) (providers, sinks map[string]windowCounts) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	providers = make(map[string]windowCounts, len(m.providerWindows))
+	for name, window := range m.providerWindows {
+		providers[name] = window.counts(since)
+	}
+	sinks = make(map[string]windowCounts, len(m.sinkWindows))
+	for name, window := range m.sinkWindows {
+		sinks[name] = window.counts(since)
+	}
+	return providers, sinks
 }
 
 // GetMetrics returns current metrics
@@ -267,27 +565,84 @@ func (m *MetricsCollector) GetMetrics() Metrics {
 	defer m.mu.RUnlock()
 
 	metrics := Metrics{
+		SchemaVersion: MetricsSchemaVersion,
+		Deprecations:  metricsDeprecations,
 		UptimeSeconds: time.Since(m.startTime).Seconds(),
 		Providers:     make(map[string]ProviderMetrics),
 		Sinks:         make(map[string]SinkMetrics),
+		Scheduler:     m.schedulerState(),
+		Inflight:      m.inflight,
 	}
 
 	// Provider metrics
 	for name, requests := range m.providerRequests {
-		metrics.Providers[name] = ProviderMetrics{
-			RequestsTotal:   requests,
-			ErrorsTotal:     m.providerErrors[name],
-			LastRequestTime: m.providerLastRequest[name].Format(time.RFC3339),
+		providerMetrics := ProviderMetrics{
+			RequestsTotal:       requests,
+			ErrorsTotal:         m.providerErrors[name],
+			LastRequestTime:     m.providerLastRequest[name].Format(time.RFC3339),
+			SnapshotCacheHits:   m.snapshotCacheHits[name],
+			SnapshotCacheMisses: m.snapshotCacheMisses[name],
+		}
+		if budget, ok := m.providerBudgets[name]; ok {
+			providerMetrics.Budget = &budget
 		}
+		m.addTraffic(name, &providerMetrics)
+		metrics.Providers[name] = providerMetrics
 	}
 
 	// Sink metrics
 	for name, writes := range m.sinkWrites {
-		metrics.Sinks[name] = SinkMetrics{
+		sinkMetrics := SinkMetrics{
 			WritesTotal:      writes,
 			ErrorsTotal:      m.sinkErrors[name],
 			DocumentsWritten: m.sinkDocumentsWritten[name],
 			LastWriteTime:    m.sinkLastWrite[name].Format(time.RFC3339),
+
+			Verifications:        m.sinkVerifications[name],
+			VerificationFailures: m.sinkVerificationFailures[name],
+		}
+		if histogram, ok := m.sinkLatency[name]; ok {
+			snapshot := histogram.snapshot()
+			sinkMetrics.Latency = &snapshot
+		}
+		if stats, ok := m.sinkBatches[name]; ok {
+			snapshot := stats.snapshot()
+			sinkMetrics.Batches = &snapshot
+		}
+		metrics.Sinks[name] = sinkMetrics
+	}
+
+	if m.reconciliation != nil {
+		report := *m.reconciliation
+		metrics.Reconciliation = &report
+		metrics.ReconciliationDiscrepancies = m.reconciliationDiscrepancies
+	}
+
+	if m.resources != nil {
+		usage := *m.resources
+		usage.SinkConnections = maps.Clone(usage.SinkConnections)
+		metrics.Resources = &usage
+		if len(m.resourceGrowth) > 0 {
+			metrics.ResourceGrowthWarnings = maps.Clone(m.resourceGrowth)
+		}
+	}
+
+	if m.dataQuality != nil {
+		metrics.DataQuality = m.dataQuality.Scores(time.Now())
+	}
+
+	// Alert metrics
+	if len(m.alerts) > 0 {
+		metrics.Alerts = make(map[string]int64, len(m.alerts))
+		for kind, count := range m.alerts {
+			metrics.Alerts[kind] = count
+		}
+	}
+
+	if len(m.connectivity) > 0 {
+		metrics.Connected = make(map[string]bool, len(m.connectivity))
+		for id, connected := range m.connectivity {
+			metrics.Connected[id] = connected
 		}
 	}
 
@@ -297,6 +652,9 @@ func (m *MetricsCollector) GetMetrics() Metrics {
 // ServeMetrics provides an HTTP handler for metrics
 func (m *MetricsCollector) ServeMetrics() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !acceptSchemaVersion(w, r, MetricsSchemaVersion) {
+			return
+		}
 		metrics := m.GetMetrics()
 
 		w.Header().Set("Content-Type", "application/json")
@@ -456,3 +456,75 @@ func TestGetStatus(t *testing.T) {
 		t.Errorf("Expected 2 checks in cached status, got %d", len(status.Checks))
 	}
 }
+
+// slowProvider lists thermostats after a delay, ignoring cancellation
+type slowProvider struct {
+	mockProvider
+	delay time.Duration
+}
+
+func (p *slowProvider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	time.Sleep(p.delay)
+	return p.mockProvider.ListThermostats(ctx)
+}
+
+// hangingSink blocks in Open until its context is done
+type hangingSink struct {
+	mockSink
+}
+
+func (s *hangingSink) Open(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestCheckHealthTimeouts(t *testing.T) {
+	t.Run("slow checks run concurrently and time out", func(t *testing.T) {
+		providers := []model.Provider{
+			&slowProvider{mockProvider: mockProvider{name: "slow", tokenValid: true}, delay: time.Second},
+			&slowProvider{mockProvider: mockProvider{name: "quick", tokenValid: true}},
+		}
+		sinks := []model.Sink{&hangingSink{mockSink: mockSink{name: "hanging"}}, &mockSink{name: "ok"}}
+		checker := NewHealthChecker(providers, sinks, WithCheckTimeouts(50*time.Millisecond, time.Second))
+
+		start := time.Now()
+		status := checker.CheckHealth(context.Background())
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected checks to finish near the check timeout, took %v", elapsed)
+		}
+
+		tests := []struct {
+			name     string
+			status   string
+			timedOut bool
+		}{
+			{name: "provider_slow", status: "warn", timedOut: true},
+			{name: "provider_quick", status: "pass"},
+			{name: "sink_hanging", status: "fail", timedOut: true},
+			{name: "sink_ok", status: "pass"},
+		}
+		for _, tt := range tests {
+			check := status.Checks[tt.name]
+			if check.Status != tt.status || check.TimedOut != tt.timedOut {
+				t.Errorf("Expected %s to be %s (timed out: %v), got %+v", tt.name, tt.status, tt.timedOut, check)
+			}
+		}
+		if status.Status != "unhealthy" {
+			t.Errorf("Expected status 'unhealthy', got %s", status.Status)
+		}
+	})
+
+	t.Run("overall deadline caps every check", func(t *testing.T) {
+		provider := &slowProvider{mockProvider: mockProvider{name: "slow", tokenValid: true}, delay: time.Second}
+		checker := NewHealthChecker([]model.Provider{provider}, nil, WithCheckTimeouts(time.Second, 50*time.Millisecond))
+
+		start := time.Now()
+		status := checker.CheckHealth(context.Background())
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected the overall deadline to end the checks, took %v", elapsed)
+		}
+		if check := status.Checks["provider_slow"]; !check.TimedOut || check.DurationMS < 50 {
+			t.Errorf("Expected a timed out check of at least 50ms, got %+v", check)
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lets browser dashboards on other origins call the HTTP endpoints
+type CORSConfig struct {
+	// AllowedOrigins are origins such as https://dash.example.com; "*" allows
+	// any origin. Empty disables CORS.
+	AllowedOrigins []string
+	// AllowCredentials lets browsers send cookies and Authorization headers
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache a preflight response
+	MaxAge time.Duration
+}
+
+// CORS headers sent on every allowed request
+const (
+	corsAllowedMethods = "GET, POST, OPTIONS"
+	corsAllowedHeaders = "Authorization, Content-Type"
+	corsExposedHeaders = schemaVersionHeader
+)
+
+// WithCORS adds CORS headers for allowed origins and answers their preflight
+// requests. Requests from other origins pass through without CORS headers,
+// so browsers block them.
+func WithCORS(config CORSConfig, next http.Handler) http.Handler {
+	if len(config.AllowedOrigins) == 0 {
+		return next
+	}
+	anyOrigin := slices.Contains(config.AllowedOrigins, "*")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || (!anyOrigin && !slices.Contains(config.AllowedOrigins, origin)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := w.Header()
+		if anyOrigin && !config.AllowCredentials {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Set("Access-Control-Allow-Origin", origin)
+		}
+		if config.AllowCredentials {
+			header.Set("Access-Control-Allow-Credentials", "true")
+		}
+		header.Set("Access-Control-Expose-Headers", corsExposedHeaders)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Set("Access-Control-Allow-Methods", corsAllowedMethods)
+			header.Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+			if config.MaxAge > 0 {
+				header.Set("Access-Control-Max-Age", strconv.Itoa(int(config.MaxAge.Seconds())))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WithBasePath serves next both at the root and under basePath, e.g.
+// /ttr/healthz as well as /healthz, for reverse proxies that forward a
+// sub-path without stripping it. An empty base path serves the root only.
+func WithBasePath(basePath string, next http.Handler) http.Handler {
+	basePath = strings.TrimSuffix(basePath, "/")
+	if basePath == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath || strings.HasPrefix(r.URL.Path, basePath+"/") {
+			http.StripPrefix(basePath, next).ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name              string
+		config            CORSConfig
+		method            string
+		origin            string
+		preflight         bool
+		expectStatus      int
+		expectOrigin      string
+		expectMaxAge      string
+		expectCredentials bool
+	}{
+		{name: "disabled", origin: "https://dash.example.com", expectStatus: http.StatusOK},
+		{name: "allowed origin", config: CORSConfig{AllowedOrigins: []string{"https://dash.example.com"}}, origin: "https://dash.example.com", expectStatus: http.StatusOK, expectOrigin: "https://dash.example.com"},
+		{name: "other origin", config: CORSConfig{AllowedOrigins: []string{"https://dash.example.com"}}, origin: "https://evil.example.com", expectStatus: http.StatusOK},
+		{name: "any origin", config: CORSConfig{AllowedOrigins: []string{"*"}}, origin: "https://dash.example.com", expectStatus: http.StatusOK, expectOrigin: "*"},
+		{name: "credentials echo the origin", config: CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, origin: "https://dash.example.com", expectStatus: http.StatusOK, expectOrigin: "https://dash.example.com", expectCredentials: true},
+		{name: "preflight", config: CORSConfig{AllowedOrigins: []string{"https://dash.example.com"}, MaxAge: 10 * time.Minute}, method: http.MethodOptions, origin: "https://dash.example.com", preflight: true, expectStatus: http.StatusNoContent, expectOrigin: "https://dash.example.com", expectMaxAge: "600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/metrics", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			recorder := httptest.NewRecorder()
+			WithCORS(tt.config, next).ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectStatus {
+				t.Errorf("Expected HTTP %d, got %d", tt.expectStatus, recorder.Code)
+			}
+			header := recorder.Header()
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.expectOrigin {
+				t.Errorf("Expected allowed origin %q, got %q", tt.expectOrigin, got)
+			}
+			if got := header.Get("Access-Control-Max-Age"); got != tt.expectMaxAge {
+				t.Errorf("Expected max age %q, got %q", tt.expectMaxAge, got)
+			}
+			if got := header.Get("Access-Control-Allow-Credentials") == "true"; got != tt.expectCredentials {
+				t.Errorf("Expected credentials allowed %v, got %v", tt.expectCredentials, got)
+			}
+		})
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name         string
+		basePath     string
+		path         string
+		expectStatus int
+	}{
+		{name: "root without base path", path: "/healthz", expectStatus: http.StatusOK},
+		{name: "prefixed path", basePath: "/ttr", path: "/ttr/healthz", expectStatus: http.StatusOK},
+		{name: "trailing slash in base path", basePath: "/ttr/", path: "/ttr/healthz", expectStatus: http.StatusOK},
+		{name: "root stays available", basePath: "/ttr", path: "/healthz", expectStatus: http.StatusOK},
+		{name: "similar prefix is not stripped", basePath: "/ttr", path: "/ttrx/healthz", expectStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			WithBasePath(tt.basePath, mux).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if recorder.Code != tt.expectStatus {
+				t.Errorf("Expected HTTP %d, got %d", tt.expectStatus, recorder.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// importBatch is the number of runtime rows normalized and written together
+// during an import
+const importBatch = 2016
+
+// ImportRuntime normalizes historical runtime rows from an offline source
+// (such as a takeout archive) and writes them to all sinks in batches. Unlike
+// polling it does not touch runtime offsets, so an import can run alongside
+// collection for the same sinks. It returns the number of rows imported
+// before ctx was cancelled.
+func (s *Scheduler) ImportRuntime(ctx context.Context, source string, thermostat model.ThermostatRef, rows []model.RuntimeRow) (int, error) {
+	s.startIngestCycle("import", time.Now())
+	imported := 0
+	for start := 0; start < len(rows); start += importBatch {
+		if err := ctx.Err(); err != nil {
+			return imported, err
+		}
+
+		end := min(start+importBatch, len(rows))
+		docs, _ := s.runtimeDocs(source, thermostat, nil, rows[start:end])
+		if err := s.writeToAllSinks(ctx, docs); err != nil {
+			return imported, err
+		}
+		imported = end
+
+		s.logger.Info("Imported runtime rows",
+			"source", source,
+			"thermostat", thermostat.ID,
+			"rows", imported,
+			"total", len(rows))
+	}
+
+	s.flushAnalyzers(ctx, time.Now())
+	return imported, nil
+}
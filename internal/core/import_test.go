@@ -0,0 +1,67 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestImportRuntime(t *testing.T) {
+	thermostat := model.ThermostatRef{Provider: "nest", ID: "nest-1", Name: "Hallway"}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := make([]model.RuntimeRow, importBatch+10)
+	for i := range rows {
+		mode := "heat"
+		if i >= importBatch+5 {
+			mode = "off"
+		}
+		rows[i] = model.RuntimeRow{
+			ThermostatRef: thermostat,
+			EventTime:     start.Add(time.Duration(i) * 15 * time.Minute),
+			Mode:          mode,
+			AvgTempC:      floatPtr(20.0),
+		}
+	}
+
+	t.Run("writes every row without touching offsets", func(t *testing.T) {
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, store)
+
+		imported, err := scheduler.ImportRuntime(testContext(t), "nest", thermostat, rows)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if imported != len(rows) {
+			t.Errorf("Expected %d rows imported, got %d", len(rows), imported)
+		}
+
+		counts := make(map[string]int)
+		for _, doc := range sink.docs {
+			counts[doc.Type]++
+		}
+		if counts["runtime_5m"] != len(rows) || counts["transition"] != 1 {
+			t.Errorf("Unexpected documents written: %v", counts)
+		}
+
+		last, _ := store.GetLastRuntimeTime(testContext(t), thermostat.ID)
+		if !last.IsZero() {
+			t.Errorf("Expected no runtime offset, got %v", last)
+		}
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, NewMemoryOffsetStore())
+
+		ctx, cancel := context.WithCancel(testContext(t))
+		cancel()
+		imported, err := scheduler.ImportRuntime(ctx, "nest", thermostat, rows)
+		if !errors.Is(err, context.Canceled) || imported != 0 {
+			t.Errorf("Expected cancellation before any rows, got %d rows and %v", imported, err)
+		}
+	})
+}
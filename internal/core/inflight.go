@@ -0,0 +1,86 @@
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// InflightConfig bounds the documents handed to sinks in one write
+type InflightConfig struct {
+	// MaxDocuments limits the documents per write; zero means no limit
+	MaxDocuments int
+	// MaxBytes limits the JSON size per write; zero means no limit
+	MaxBytes int64
+}
+
+// WithInflightLimit splits larger writes into batches within the limits.
+// Sink writes run one at a time on the scheduler goroutine, and each batch is
+// acknowledged by every sink before the next is handed over, so sinks never
+// buffer more than the limits at once. The documents of a poll or backfill
+// chunk are still all normalized before the first batch is written.
+func WithInflightLimit(config InflightConfig) SchedulerOption {
+	return func(s *Scheduler) {
+		s.inflight = newInflightLimiter(config, s.metrics)
+	}
+}
+
+// inflightLimiter splits writes into batches and records the batch being
+// written
+type inflightLimiter struct {
+	config  InflightConfig
+	metrics *MetricsCollector
+}
+
+func newInflightLimiter(config InflightConfig, metrics *MetricsCollector) *inflightLimiter {
+	metrics.RecordInflight(0, 0, config.MaxDocuments, config.MaxBytes)
+	return &inflightLimiter{config: config, metrics: metrics}
+}
+
+// writing records batch as handed to sinks, or nothing in flight when batch
+// is nil
+func (l *inflightLimiter) writing(batch *inflightBatch) {
+	if batch == nil {
+		l.metrics.RecordInflight(0, 0, l.config.MaxDocuments, l.config.MaxBytes)
+		return
+	}
+	l.metrics.RecordInflight(len(batch.docs), batch.bytes, l.config.MaxDocuments, l.config.MaxBytes)
+}
+
+// inflightBatch is a slice of documents written together
+type inflightBatch struct {
+	docs  []model.Doc
+	bytes int64
+}
+
+// batches splits docs into batches within the configured limits, measuring
+// each document by its JSON size. A document larger than the byte limit is
+// written on its own.
+func (l *inflightLimiter) batches(docs []model.Doc) []inflightBatch {
+	var batches []inflightBatch
+	current := inflightBatch{}
+	for i, doc := range docs {
+		size := docSize(doc)
+		full := (l.config.MaxDocuments > 0 && len(current.docs) >= l.config.MaxDocuments) ||
+			(l.config.MaxBytes > 0 && current.bytes+size > l.config.MaxBytes)
+		if len(current.docs) > 0 && full {
+			batches = append(batches, current)
+			current = inflightBatch{}
+		}
+		current.docs = docs[i-len(current.docs) : i+1]
+		current.bytes += size
+	}
+	if len(current.docs) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// docSize estimates a document's size as its JSON encoding
+func docSize(doc model.Doc) int64 {
+	data, err := json.Marshal(doc.Body)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
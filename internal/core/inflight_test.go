@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func inflightDocs(n int) []model.Doc {
+	docs := make([]model.Doc, n)
+	for i := range docs {
+		docs[i] = model.Doc{ID: fmt.Sprintf("doc-%d", i), Type: "runtime_5m", Body: map[string]any{"n": i}}
+	}
+	return docs
+}
+
+func TestInflightBatches(t *testing.T) {
+	docs := inflightDocs(5) // each body encodes to 7 bytes, e.g. {"n":0}
+
+	tests := []struct {
+		name     string
+		config   InflightConfig
+		expected []int
+	}{
+		{name: "unlimited", config: InflightConfig{}, expected: []int{5}},
+		{name: "document limit", config: InflightConfig{MaxDocuments: 2}, expected: []int{2, 2, 1}},
+		{name: "byte limit", config: InflightConfig{MaxBytes: 21}, expected: []int{3, 2}},
+		{name: "document larger than byte limit", config: InflightConfig{MaxBytes: 1}, expected: []int{1, 1, 1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newInflightLimiter(tt.config, NewMetricsCollector())
+			var sizes []int
+			for _, batch := range limiter.batches(docs) {
+				sizes = append(sizes, len(batch.docs))
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.expected) {
+				t.Errorf("Expected batch sizes %v, got %v", tt.expected, sizes)
+			}
+		})
+	}
+}
+
+func TestWriteToAllSinksInflightLimit(t *testing.T) {
+	sink := &batchRecordingSink{}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, NewMemoryOffsetStore(),
+		WithInflightLimit(InflightConfig{MaxDocuments: 2}))
+
+	if err := scheduler.writeToAllSinks(testContext(t), inflightDocs(5)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fmt.Sprint(sink.batches) != "[2 2 1]" {
+		t.Errorf("Expected writes of [2 2 1] documents, got %v", sink.batches)
+	}
+	if inflight := scheduler.metrics.GetMetrics().Inflight.Documents; inflight != 0 {
+		t.Errorf("Expected nothing in flight after writing, got %d", inflight)
+	}
+}
+
+type batchRecordingSink struct {
+	mockSink
+	batches []int
+}
+
+func (s *batchRecordingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	s.batches = append(s.batches, len(docs))
+	return model.WriteResult{SuccessCount: len(docs)}, nil
+}
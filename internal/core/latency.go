@@ -0,0 +1,309 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// latencyBuckets are the upper bounds of the event-to-write latency
+// histogram. Providers publish 5-minute runtime bins with a delay of several
+// minutes, so the buckets span minutes to a day; backfilled rows land in +Inf.
+var latencyBuckets = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+}
+
+// latencyHistogram counts observations per bucket, non-cumulatively
+type latencyHistogram struct {
+	counts []int64 // one per latencyBuckets entry, plus +Inf
+	sum    float64 // seconds
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, len(latencyBuckets)+1)}
+}
+
+// observe adds one latency to the histogram
+func (h *latencyHistogram) observe(d time.Duration) {
+	i, _ := slices.BinarySearch(latencyBuckets, d)
+	h.counts[i]++
+	h.sum += d.Seconds()
+}
+
+// snapshot returns the histogram with cumulative bucket counts
+func (h *latencyHistogram) snapshot() LatencyHistogram {
+	snapshot := LatencyHistogram{
+		Buckets:    make(map[string]int64, len(h.counts)),
+		SumSeconds: h.sum,
+	}
+	for i, count := range h.counts {
+		snapshot.Count += count
+		snapshot.Buckets[bucketLabel(i)] = snapshot.Count
+	}
+	return snapshot
+}
+
+// bucketLabel returns the Prometheus "le" label for bucket i
+func bucketLabel(i int) string {
+	if i == len(latencyBuckets) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(latencyBuckets[i].Seconds(), 'g', -1, 64)
+}
+
+// LatencyHistogram is a cumulative histogram of the time from a runtime
+// row's event time to its sink acknowledging the write
+type LatencyHistogram struct {
+	Buckets    map[string]int64 `json:"buckets"` // keyed by upper bound in seconds, or +Inf
+	Count      int64            `json:"count"`
+	SumSeconds float64          `json:"sum_seconds"`
+}
+
+// RecordSinkLatency records event-to-write latencies for a sink
+func (m *MetricsCollector) RecordSinkLatency(sinkName string, latencies []time.Duration) {
+	if len(latencies) == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	histogram, ok := m.sinkLatency[sinkName]
+	if !ok {
+		histogram = newLatencyHistogram()
+		m.sinkLatency[sinkName] = histogram
+	}
+	for _, latency := range latencies {
+		histogram.observe(latency)
+	}
+}
+
+// docTypeFilter is implemented by sinks that receive only some document
+// types, such as pipeline.Router
+type docTypeFilter interface {
+	Accepts(docType string) bool
+}
+
+// eventLatencies returns how long ago each runtime_5m document in docs was
+// measured, or nothing if the sink does not receive runtime_5m documents
+func eventLatencies(sink model.Sink, docs []model.Doc, now time.Time) []time.Duration {
+	if filter, ok := sink.(docTypeFilter); ok && !filter.Accepts("runtime_5m") {
+		return nil
+	}
+
+	var latencies []time.Duration
+	for _, doc := range docs {
+		runtime, ok := doc.Body.(*model.Runtime5m)
+		if !ok || doc.Type != "runtime_5m" {
+			continue
+		}
+		latencies = append(latencies, max(now.Sub(runtime.EventTime), 0))
+	}
+	return latencies
+}
+
+// ServePrometheus provides an HTTP handler for metrics in the Prometheus text
+// exposition format
+func (m *MetricsCollector) ServePrometheus() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		m.writePrometheus(w)
+	})
+}
+
+// writePrometheus writes counters and latency histograms in the Prometheus
+// text exposition format
+func (m *MetricsCollector) writePrometheus(w io.Writer) {
+	metrics := m.GetMetrics()
+
+	m.mu.RLock()
+	latency := make(map[string]LatencyHistogram, len(m.sinkLatency))
+	for name, histogram := range m.sinkLatency {
+		latency[name] = histogram.snapshot()
+	}
+	m.mu.RUnlock()
+
+	fmt.Fprintf(w, "# HELP ttr_uptime_seconds Seconds since the process started\n")
+	fmt.Fprintf(w, "# TYPE ttr_uptime_seconds gauge\n")
+	fmt.Fprintf(w, "ttr_uptime_seconds %g\n", metrics.UptimeSeconds)
+	m.writeBuildInfo(w)
+
+	providers := sortedKeys(metrics.Providers)
+	writeCounter(w, "ttr_provider_requests_total", "Provider API requests", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].RequestsTotal
+	})
+	writeCounter(w, "ttr_provider_errors_total", "Failed provider API requests", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].ErrorsTotal
+	})
+	writeCounter(w, "ttr_provider_snapshot_cache_hits_total", "Snapshots served from the cache without a provider request", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].SnapshotCacheHits
+	})
+	writeCounter(w, "ttr_provider_snapshot_cache_misses_total", "Snapshot cache lookups that needed a provider request", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].SnapshotCacheMisses
+	})
+	writeCounter(w, "ttr_provider_bytes_fetched_total", "Response bytes downloaded from provider APIs", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].BytesFetched
+	})
+	writeCounter(w, "ttr_provider_runtime_rows_parsed_total", "Runtime rows returned by providers", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].RuntimeRowsParsed
+	})
+	writeCounter(w, "ttr_provider_runtime_rows_rejected_total", "Runtime rows that failed normalization", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].RuntimeRowsRejected
+	})
+	writeCounter(w, "ttr_provider_snapshots_fetched_total", "Snapshots requested from providers rather than served from the cache", "provider", providers, func(name string) int64 {
+		return metrics.Providers[name].SnapshotsFetched
+	})
+	writeBudgetGauges(w, metrics.Providers)
+
+	sinks := sortedKeys(metrics.Sinks)
+	writeCounter(w, "ttr_sink_writes_total", "Sink write calls", "sink", sinks, func(name string) int64 {
+		return metrics.Sinks[name].WritesTotal
+	})
+	writeCounter(w, "ttr_sink_errors_total", "Failed sink writes", "sink", sinks, func(name string) int64 {
+		return metrics.Sinks[name].ErrorsTotal
+	})
+	writeCounter(w, "ttr_sink_documents_written_total", "Documents written to sinks", "sink", sinks, func(name string) int64 {
+		return metrics.Sinks[name].DocumentsWritten
+	})
+	writeCounter(w, "ttr_sink_verifications_total", "Written documents read back from sinks", "sink", sinks, func(name string) int64 {
+		return metrics.Sinks[name].Verifications
+	})
+	writeCounter(w, "ttr_sink_verification_failures_total", "Written documents missing or different when read back", "sink", sinks, func(name string) int64 {
+		return metrics.Sinks[name].VerificationFailures
+	})
+
+	batches := make(map[string]BatchMetrics, len(sinks))
+	for _, name := range sinks {
+		if batch := metrics.Sinks[name].Batches; batch != nil {
+			batches[name] = *batch
+		}
+	}
+	writeBatchMetrics(w, batches)
+
+	writeGauge(w, "ttr_inflight_documents", "Documents in the batch being written to sinks", metrics.Inflight.Documents)
+	writeGauge(w, "ttr_inflight_bytes", "JSON bytes of the batch being written to sinks", metrics.Inflight.Bytes)
+	writeGauge(w, "ttr_inflight_max_documents", "Documents per write batch limit, 0 when unlimited", metrics.Inflight.MaxDocuments)
+	writeGauge(w, "ttr_inflight_max_bytes", "JSON bytes per write batch limit, 0 when unlimited", metrics.Inflight.MaxBytes)
+
+	thermostats := sortedKeys(metrics.DataQuality)
+	writeRatioGauge(w, "ttr_data_quality_score", "Rolling data quality score, 0 to 1", thermostats, func(id string) float64 {
+		return metrics.DataQuality[id].Score
+	})
+	writeRatioGauge(w, "ttr_data_quality_completeness", "Share of expected 5-minute bins received", thermostats, func(id string) float64 {
+		return metrics.DataQuality[id].Completeness
+	})
+	writeRatioGauge(w, "ttr_data_quality_sensor_coverage", "Share of expected temperature readings present in received bins", thermostats, func(id string) float64 {
+		return metrics.DataQuality[id].SensorCoverage
+	})
+	writeRatioGauge(w, "ttr_data_quality_error_rate", "Share of thermostat polls that failed", thermostats, func(id string) float64 {
+		return metrics.DataQuality[id].ErrorRate
+	})
+
+	writeRatioGauge(w, "ttr_thermostat_connected", "Whether the provider reports the thermostat connected, 1 or 0", sortedKeys(metrics.Connected), func(id string) float64 {
+		if metrics.Connected[id] {
+			return 1
+		}
+		return 0
+	})
+
+	if report := metrics.Reconciliation; report != nil {
+		writeGauge(w, "ttr_reconciliation_unaccounted_rows", "Runtime rows fetched in the last reconciliation window but not turned into documents", report.UnaccountedRows)
+		sinkNames := sortedKeys(report.Sinks)
+		fmt.Fprintf(w, "# HELP ttr_reconciliation_unaccounted_documents Documents handed to a sink in the last reconciliation window that it neither acknowledged nor reported failed\n")
+		fmt.Fprintf(w, "# TYPE ttr_reconciliation_unaccounted_documents gauge\n")
+		for _, name := range sinkNames {
+			fmt.Fprintf(w, "ttr_reconciliation_unaccounted_documents{sink=\"%s\"} %d\n", escapeLabel(name), report.Sinks[name].Unaccounted)
+		}
+		fmt.Fprintf(w, "# HELP ttr_reconciliation_discrepancies_total Reconciliation windows that did not reconcile\n")
+		fmt.Fprintf(w, "# TYPE ttr_reconciliation_discrepancies_total counter\n")
+		fmt.Fprintf(w, "ttr_reconciliation_discrepancies_total %d\n", metrics.ReconciliationDiscrepancies)
+	}
+
+	if usage := metrics.Resources; usage != nil {
+		writeGauge(w, "ttr_goroutines", "Goroutines running at the end of the last polling cycle", usage.Goroutines)
+		if usage.OpenFDs >= 0 {
+			writeGauge(w, "ttr_open_fds", "Open file descriptors at the end of the last polling cycle", usage.OpenFDs)
+		}
+		fmt.Fprintf(w, "# HELP ttr_sink_open_connections Connections a sink holds open at the end of the last polling cycle\n")
+		fmt.Fprintf(w, "# TYPE ttr_sink_open_connections gauge\n")
+		for _, name := range sortedKeys(usage.SinkConnections) {
+			fmt.Fprintf(w, "ttr_sink_open_connections{sink=\"%s\"} %d\n", escapeLabel(name), usage.SinkConnections[name])
+		}
+		fmt.Fprintf(w, "# HELP ttr_resource_growth_warnings_total Times a resource count was reported as growing without bound\n")
+		fmt.Fprintf(w, "# TYPE ttr_resource_growth_warnings_total counter\n")
+		for _, resource := range sortedKeys(metrics.ResourceGrowthWarnings) {
+			fmt.Fprintf(w, "ttr_resource_growth_warnings_total{%s} %d\n", resourceLabels(resource), metrics.ResourceGrowthWarnings[resource])
+		}
+	}
+
+	const histogramName = "ttr_sink_event_to_write_seconds"
+	fmt.Fprintf(w, "# HELP %s Time from a runtime row's event time to its sink acknowledging the write\n", histogramName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", histogramName)
+	for _, name := range sortedKeys(latency) {
+		histogram := latency[name]
+		label := escapeLabel(name)
+		for i := range len(latencyBuckets) + 1 {
+			le := bucketLabel(i)
+			fmt.Fprintf(w, "%s_bucket{sink=\"%s\",le=\"%s\"} %d\n", histogramName, label, le, histogram.Buckets[le])
+		}
+		fmt.Fprintf(w, "%s_sum{sink=\"%s\"} %g\n", histogramName, label, histogram.SumSeconds)
+		fmt.Fprintf(w, "%s_count{sink=\"%s\"} %d\n", histogramName, label, histogram.Count)
+	}
+}
+
+// writeGauge writes one unlabelled gauge
+func writeGauge[N int | int64](w io.Writer, name, help string, value N) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "%s %d\n", name, value)
+}
+
+// writeRatioGauge writes one gauge family labelled by thermostat
+func writeRatioGauge(w io.Writer, name, help string, thermostats []string, value func(string) float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	for _, id := range thermostats {
+		fmt.Fprintf(w, "%s{thermostat=\"%s\"} %g\n", name, escapeLabel(id), value(id))
+	}
+}
+
+// writeCounter writes one labelled counter family
+func writeCounter(w io.Writer, name, help, labelName string, labels []string, value func(string) int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, label := range labels {
+		fmt.Fprintf(w, "%s{%s=\"%s\"} %d\n", name, labelName, escapeLabel(label), value(label))
+	}
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// sortedKeys returns the keys of m in order, for stable output
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package core
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/pipeline"
+)
+
+func TestSinkLatency(t *testing.T) {
+	now := time.Now()
+	runtimeDoc := func(age time.Duration) model.Doc {
+		return model.Doc{ID: age.String(), Type: "runtime_5m", Body: &model.Runtime5m{EventTime: now.Add(-age)}}
+	}
+	docs := []model.Doc{
+		runtimeDoc(4 * time.Minute),
+		runtimeDoc(5 * time.Minute),
+		runtimeDoc(72 * time.Hour),
+		{ID: "transition", Type: "transition", Body: &model.Transition{EventTime: now.Add(-time.Hour)}},
+	}
+
+	tests := []struct {
+		name     string
+		sink     model.Sink
+		expected int
+	}{
+		{name: "runtime documents only", sink: &mockSink{name: "all"}, expected: 3},
+		{name: "sink not receiving runtime", sink: pipeline.NewRouter(&mockSink{name: "transitions"}, []string{"transition"}), expected: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if latencies := eventLatencies(tt.sink, docs, now); len(latencies) != tt.expected {
+				t.Errorf("Expected %d latencies, got %v", tt.expected, latencies)
+			}
+		})
+	}
+
+	metrics := NewMetricsCollector()
+	metrics.RecordSinkWrite("es", 3)
+	metrics.RecordSinkLatency("es", eventLatencies(&mockSink{name: "es"}, docs, now))
+
+	latency := metrics.GetMetrics().Sinks["es"].Latency
+	if latency == nil {
+		t.Fatal("Expected latency histogram in sink metrics")
+	}
+	if latency.Count != 3 || latency.Buckets["300"] != 2 || latency.Buckets["86400"] != 2 || latency.Buckets["+Inf"] != 3 {
+		t.Errorf("Unexpected histogram: %+v", latency)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServePrometheus().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics/prometheus", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE ttr_sink_event_to_write_seconds histogram",
+		`ttr_sink_event_to_write_seconds_bucket{sink="es",le="60"} 0`,
+		`ttr_sink_event_to_write_seconds_bucket{sink="es",le="300"} 2`,
+		`ttr_sink_event_to_write_seconds_bucket{sink="es",le="+Inf"} 3`,
+		`ttr_sink_event_to_write_seconds_count{sink="es"} 3`,
+		`ttr_sink_documents_written_total{sink="es"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in output:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,394 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Lint severities. Errors are documents that break the canonical schema;
+// warnings are values a sink accepts but that point to a provider or
+// normalization problem.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// Plausible ranges, in °C or percent, for canonical values
+const (
+	minIndoorTempC  = -40.0
+	maxIndoorTempC  = 60.0
+	minOutdoorTempC = -70.0
+	maxOutdoorTempC = 60.0
+	minSetpointC    = 0.0
+	maxSetpointC    = 40.0
+)
+
+// maxLintLineBytes bounds one NDJSON line
+const maxLintLineBytes = 4 << 20
+
+// LintViolation is one problem found in a canonical document
+type LintViolation struct {
+	Line     int    `json:"line"`
+	Field    string `json:"field,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// LintReport summarizes a lint run
+type LintReport struct {
+	Documents  int             `json:"documents"`
+	ByType     map[string]int  `json:"by_type"`
+	Violations []LintViolation `json:"violations"`
+}
+
+// Errors returns the number of error-severity violations
+func (r *LintReport) Errors() int {
+	count := 0
+	for _, violation := range r.Violations {
+		if violation.Severity == LintError {
+			count++
+		}
+	}
+	return count
+}
+
+// canonicalValues are the values the normalizer emits for enumerated fields
+type canonicalValues struct {
+	modes      map[string]bool
+	climates   map[string]bool
+	equipment  map[string]bool
+	eventKinds map[string]bool
+	alertKinds map[string]bool
+}
+
+// valuesOf returns the distinct values of a normalizer mapping
+func valuesOf(mapping map[string]string) map[string]bool {
+	values := make(map[string]bool, len(mapping))
+	for _, value := range mapping {
+		values[value] = true
+	}
+	return values
+}
+
+// docLinter checks canonical documents against the schema the normalizer produces
+type docLinter struct {
+	canonical canonicalValues
+	now       time.Time
+	line      int
+	report    *LintReport
+}
+
+func newDocLinter(now time.Time) (*docLinter, error) {
+	normalizer, err := NewNormalizer("UTC")
+	if err != nil {
+		return nil, err
+	}
+	eventKinds := valuesOf(normalizer.eventKindMap)
+	eventKinds["unknown"] = true
+
+	return &docLinter{
+		canonical: canonicalValues{
+			modes:      valuesOf(normalizer.modeMap),
+			climates:   valuesOf(normalizer.climateMap),
+			equipment:  valuesOf(normalizer.equipmentKeyMap),
+			eventKinds: eventKinds,
+			alertKinds: map[string]bool{
+				model.AlertKindStuck:            true,
+				model.AlertKindJump:             true,
+				model.AlertKindDivergence:       true,
+				model.AlertKindHeatCoolConflict: true,
+				model.AlertKindModeConflict:     true,
+			},
+		},
+		now:    now,
+		report: &LintReport{ByType: make(map[string]int), Violations: []LintViolation{}},
+	}, nil
+}
+
+// LintDocuments reads canonical documents, one JSON object per line, and
+// checks each against the canonical schema and plausible value ranges.
+// Blank lines are skipped.
+func LintDocuments(r io.Reader) (*LintReport, error) {
+	linter, err := newDocLinter(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLintLineBytes)
+	for scanner.Scan() {
+		linter.line++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		linter.lintDocument(line)
+	}
+	if err := scanner.Err(); err != nil {
+		return linter.report, fmt.Errorf("reading documents: %w", err)
+	}
+	return linter.report, nil
+}
+
+func (l *docLinter) add(severity, field, format string, args ...any) {
+	l.report.Violations = append(l.report.Violations, LintViolation{
+		Line:     l.line,
+		Field:    field,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// decodeStrict decodes a document, rejecting fields the canonical type lacks
+func (l *docLinter) decodeStrict(line []byte, doc any) bool {
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(doc); err != nil {
+		l.add(LintError, "", "does not match the canonical schema: %v", err)
+		return false
+	}
+	return true
+}
+
+func (l *docLinter) lintDocument(line []byte) {
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(line, &header); err != nil {
+		l.add(LintError, "", "invalid JSON: %v", err)
+		return
+	}
+	l.report.Documents++
+	l.report.ByType[header.Type]++
+
+	switch header.Type {
+	case "runtime_5m":
+		var doc model.Runtime5m
+		if l.decodeStrict(line, &doc) {
+			l.lintRuntime5m(&doc)
+		}
+	case "runtime_live":
+		var doc model.RuntimeLive
+		if l.decodeStrict(line, &doc) {
+			l.lintRuntimeLive(&doc)
+		}
+	case "transition":
+		var doc model.Transition
+		if l.decodeStrict(line, &doc) {
+			l.lintTransition(&doc)
+		}
+	case "device_snapshot":
+		var doc model.DeviceSnapshot
+		if l.decodeStrict(line, &doc) {
+			l.requireThermostat(doc.ThermostatID)
+			l.requireTime("collected_at", doc.CollectedAt)
+		}
+	case "device_metadata":
+		var doc model.DeviceMetadata
+		if l.decodeStrict(line, &doc) {
+			l.lintMetadata(&doc)
+		}
+	case "analysis":
+		var doc model.Analysis
+		if l.decodeStrict(line, &doc) {
+			l.lintAnalysis(&doc)
+		}
+	case "alert":
+		var doc model.Alert
+		if l.decodeStrict(line, &doc) {
+			l.lintAlert(&doc)
+		}
+	case "recommendation":
+		var doc model.Recommendation
+		if l.decodeStrict(line, &doc) {
+			l.lintRecommendation(&doc)
+		}
+	case "firmware_change":
+		var doc model.FirmwareChange
+		if l.decodeStrict(line, &doc) {
+			l.requireThermostat(doc.ThermostatID)
+			l.requireTime("event_time", doc.EventTime)
+			if doc.NextVersion == "" {
+				l.add(LintError, "next_version", "missing firmware version")
+			}
+		}
+	case "connectivity":
+		var doc model.Connectivity
+		if l.decodeStrict(line, &doc) {
+			l.requireThermostat(doc.ThermostatID)
+			l.requireTime("event_time", doc.EventTime)
+		}
+	case "":
+		l.add(LintError, "type", "missing document type")
+	default:
+		l.add(LintError, "type", "unknown document type %q", header.Type)
+	}
+}
+
+func (l *docLinter) lintRuntime5m(doc *model.Runtime5m) {
+	l.requireThermostat(doc.ThermostatID)
+	if l.requireTime("event_time", doc.EventTime) && !doc.EventTime.Equal(doc.EventTime.Truncate(5*time.Minute)) {
+		l.add(LintWarning, "event_time", "%s is not aligned to a 5-minute bin", doc.EventTime.Format(time.RFC3339))
+	}
+	if !doc.Filled || doc.Mode != "" {
+		l.checkEnum("mode", doc.Mode, l.canonical.modes)
+		l.checkEnum("climate", doc.Climate, l.canonical.climates)
+	}
+	l.checkSetpoints(doc.SetHeatC, doc.SetCoolC)
+	l.checkRange("avg_temp_c", doc.AvgTempC, minIndoorTempC, maxIndoorTempC)
+	l.checkRange("outdoor_temp_c", doc.OutdoorTempC, minOutdoorTempC, maxOutdoorTempC)
+	l.checkHumidity("outdoor_humidity_pct", doc.OutdoorHumidity)
+
+	for key := range doc.Equipment {
+		l.checkEnum("equip."+key, key, l.canonical.equipment)
+	}
+	for key, seconds := range doc.EquipmentSecs {
+		l.checkEnum("equip_seconds."+key, key, l.canonical.equipment)
+		if seconds < 0 || seconds > 300 {
+			l.add(LintError, "equip_seconds."+key, "%d seconds is outside a 5-minute bin", seconds)
+		}
+	}
+	for id, temp := range doc.Sensors {
+		l.checkRange("sensors."+id, &temp, minIndoorTempC, maxIndoorTempC)
+	}
+}
+
+func (l *docLinter) lintRuntimeLive(doc *model.RuntimeLive) {
+	l.requireThermostat(doc.ThermostatID)
+	l.requireTime("event_time", doc.EventTime)
+	l.checkEnum("mode", doc.Mode, l.canonical.modes)
+	l.checkSetpoints(doc.SetHeatC, doc.SetCoolC)
+	l.checkRange("temp_c", doc.TempC, minIndoorTempC, maxIndoorTempC)
+	l.checkHumidity("humidity_pct", doc.Humidity)
+	for key := range doc.Equipment {
+		l.checkEnum("equip."+key, key, l.canonical.equipment)
+	}
+}
+
+func (l *docLinter) lintTransition(doc *model.Transition) {
+	l.requireThermostat(doc.ThermostatID)
+	l.requireTime("event_time", doc.EventTime)
+	l.checkEnum("event.kind", doc.Event.Kind, l.canonical.eventKinds)
+	for field, state := range map[string]model.State{"prev": doc.Prev, "next": doc.Next} {
+		l.checkEnum(field+".mode", state.Mode, l.canonical.modes)
+		l.checkEnum(field+".climate", state.Climate, l.canonical.climates)
+		l.checkRange(field+".set_heat_c", state.SetHeatC, minSetpointC, maxSetpointC)
+		l.checkRange(field+".set_cool_c", state.SetCoolC, minSetpointC, maxSetpointC)
+	}
+	if statesEqual(doc.Prev, doc.Next) {
+		l.add(LintWarning, "next", "transition does not change the state")
+	}
+}
+
+func (l *docLinter) lintMetadata(doc *model.DeviceMetadata) {
+	l.requireThermostat(doc.ThermostatID)
+	l.requireTime("collected_at", doc.CollectedAt)
+	l.checkRange("location.latitude", doc.Location.Latitude, -90, 90)
+	l.checkRange("location.longitude", doc.Location.Longitude, -180, 180)
+}
+
+func (l *docLinter) lintAnalysis(doc *model.Analysis) {
+	// Household analyses cover several thermostats
+	if doc.HouseholdID == "" {
+		l.requireThermostat(doc.ThermostatID)
+	}
+	if doc.Analyzer == "" {
+		l.add(LintError, "analyzer", "missing analyzer name")
+	}
+	if l.requireTime("period_start", doc.PeriodStart) && l.requireTime("period_end", doc.PeriodEnd) && !doc.PeriodEnd.After(doc.PeriodStart) {
+		l.add(LintError, "period_end", "period ends before it starts")
+	}
+}
+
+func (l *docLinter) lintAlert(doc *model.Alert) {
+	// Heat/cool conflicts span a household's thermostats
+	if doc.Kind != model.AlertKindHeatCoolConflict || doc.HouseholdID == "" {
+		l.requireThermostat(doc.ThermostatID)
+	}
+	l.requireTime("event_time", doc.EventTime)
+	if !l.canonical.alertKinds[doc.Kind] {
+		l.add(LintError, "kind", "unknown alert kind %q", doc.Kind)
+	}
+	if strings.HasPrefix(doc.Kind, "sensor_") && doc.SensorID == "" {
+		l.add(LintError, "sensor_id", "missing sensor ID")
+	}
+}
+
+func (l *docLinter) lintRecommendation(doc *model.Recommendation) {
+	l.requireThermostat(doc.ThermostatID)
+	if doc.Kind != model.RecommendationLowerHeatSetpoint && doc.Kind != model.RecommendationRaiseCoolSetpoint {
+		l.add(LintError, "kind", "unknown recommendation kind %q", doc.Kind)
+	}
+	if l.requireTime("period_start", doc.PeriodStart) && l.requireTime("period_end", doc.PeriodEnd) && !doc.PeriodEnd.After(doc.PeriodStart) {
+		l.add(LintError, "period_end", "period ends before it starts")
+	}
+	if doc.SuggestedSetpointC == doc.CurrentSetpointC {
+		l.add(LintWarning, "suggested_setpoint_c", "suggests no change")
+	}
+}
+
+func (l *docLinter) requireThermostat(id string) {
+	if id == "" {
+		l.add(LintError, "thermostat_id", "missing thermostat ID")
+	}
+}
+
+// requireTime reports a missing timestamp and warns about one in the future.
+// It returns whether the timestamp is set.
+func (l *docLinter) requireTime(field string, t time.Time) bool {
+	if t.IsZero() {
+		l.add(LintError, field, "missing timestamp")
+		return false
+	}
+	if t.After(l.now.Add(time.Hour)) {
+		l.add(LintWarning, field, "%s is in the future", t.Format(time.RFC3339))
+	}
+	return true
+}
+
+// checkEnum warns about values the normalizer would not produce; the
+// normalizer passes unmapped provider values through unchanged
+func (l *docLinter) checkEnum(field, value string, allowed map[string]bool) {
+	if value == "" {
+		l.add(LintError, field, "missing value")
+		return
+	}
+	if !allowed[value] {
+		l.add(LintWarning, field, "unrecognized value %q", value)
+	}
+}
+
+func (l *docLinter) checkRange(field string, value *float64, low, high float64) {
+	if value != nil && (*value < low || *value > high) {
+		l.add(LintError, field, "%g is outside the plausible range %g to %g", *value, low, high)
+	}
+}
+
+func (l *docLinter) checkHumidity(field string, value *int) {
+	if value != nil && (*value < 0 || *value > 100) {
+		l.add(LintError, field, "%d%% is outside 0 to 100", *value)
+	}
+}
+
+func (l *docLinter) checkSetpoints(heat, cool *float64) {
+	l.checkRange("set_heat_c", heat, minSetpointC, maxSetpointC)
+	l.checkRange("set_cool_c", cool, minSetpointC, maxSetpointC)
+	if heat != nil && cool != nil && *heat > *cool {
+		l.add(LintWarning, "set_heat_c", "heat setpoint %g is above cool setpoint %g", *heat, *cool)
+	}
+}
+
+// statesEqual compares two states exactly
+func statesEqual(a, b model.State) bool {
+	floatEqual := func(x, y *float64) bool {
+		return (x == nil && y == nil) || (x != nil && y != nil && *x == *y)
+	}
+	return a.Mode == b.Mode && a.Climate == b.Climate && floatEqual(a.SetHeatC, b.SetHeatC) && floatEqual(a.SetCoolC, b.SetCoolC)
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLintDocuments(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		errors   int
+		warnings int
+		field    string
+	}{
+		{
+			name:  "valid runtime",
+			input: `{"type":"runtime_5m","thermostat_id":"t1","event_time":"2025-01-10T12:05:00Z","mode":"heat","climate":"Home","set_heat_c":20.5,"set_cool_c":24,"avg_temp_c":21,"equip_seconds":{"compHeat1":120}}`,
+		},
+		{
+			name:  "blank lines are skipped",
+			input: "\n\n" + `{"type":"device_snapshot","thermostat_id":"t1","collected_at":"2025-01-10T12:00:00Z"}` + "\n\n",
+		},
+		{
+			name:   "invalid JSON",
+			input:  `{"type":`,
+			errors: 1,
+		},
+		{
+			name:   "unknown type",
+			input:  `{"type":"runtime_1m","thermostat_id":"t1"}`,
+			errors: 1,
+			field:  "type",
+		},
+		{
+			name:   "unknown field",
+			input:  `{"type":"device_snapshot","thermostat_id":"t1","collected_at":"2025-01-10T12:00:00Z","extra":1}`,
+			errors: 1,
+		},
+		{
+			name:   "missing thermostat and time",
+			input:  `{"type":"runtime_live","mode":"heat"}`,
+			errors: 2,
+			field:  "thermostat_id",
+		},
+		{
+			name:   "equipment seconds out of range",
+			input:  `{"type":"runtime_5m","thermostat_id":"t1","event_time":"2025-01-10T12:05:00Z","mode":"heat","climate":"Home","equip_seconds":{"compHeat1":600}}`,
+			errors: 1,
+			field:  "equip_seconds.compHeat1",
+		},
+		{
+			name:     "unaligned bin and unmapped mode",
+			input:    `{"type":"runtime_5m","thermostat_id":"t1","event_time":"2025-01-10T12:03:00Z","mode":"heatOnly","climate":"Home"}`,
+			warnings: 2,
+			field:    "event_time",
+		},
+		{
+			name:   "implausible temperature",
+			input:  `{"type":"runtime_live","thermostat_id":"t1","event_time":"2025-01-10T12:00:00Z","mode":"cool","temp_c":95}`,
+			errors: 1,
+			field:  "temp_c",
+		},
+		{
+			name:     "transition without a change",
+			input:    `{"type":"transition","thermostat_id":"t1","event_time":"2025-01-10T12:00:00Z","prev":{"mode":"heat","climate":"Home"},"next":{"mode":"heat","climate":"Home"},"event":{"kind":"hold"}}`,
+			warnings: 1,
+			field:    "next",
+		},
+		{
+			name:   "analysis period reversed",
+			input:  `{"type":"analysis","thermostat_id":"t1","analyzer":"duty_cycle","period_start":"2025-01-11T00:00:00Z","period_end":"2025-01-10T00:00:00Z"}`,
+			errors: 1,
+			field:  "period_end",
+		},
+		{
+			name:  "household conflict alert",
+			input: `{"type":"alert","kind":"heat_cool_conflict","household_id":"h1","event_time":"2025-01-10T12:00:00Z","message":"Downstairs heating while Upstairs cooling for 30m0s"}`,
+		},
+		{
+			name:   "sensor alert without a sensor",
+			input:  `{"type":"alert","kind":"sensor_stuck","thermostat_id":"t1","event_time":"2025-01-10T12:00:00Z"}`,
+			errors: 1,
+			field:  "sensor_id",
+		},
+		{
+			name:  "household analysis without a thermostat",
+			input: `{"type":"analysis","household_id":"h1","analyzer":"household","period_start":"2025-01-10T00:00:00Z","period_end":"2025-01-11T00:00:00Z"}`,
+		},
+		{
+			name:   "recommendation of an unknown kind",
+			input:  `{"type":"recommendation","kind":"open_windows","thermostat_id":"t1","climate":"Sleep","period_start":"2025-01-06T00:00:00Z","period_end":"2025-01-13T00:00:00Z","current_setpoint_c":17,"suggested_setpoint_c":16,"estimated_kwh_saved":4.2,"message":"..."}`,
+			errors: 1,
+			field:  "kind",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := LintDocuments(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			errors := report.Errors()
+			warnings := len(report.Violations) - errors
+			if errors != tt.errors || warnings != tt.warnings {
+				t.Fatalf("Expected %d errors and %d warnings, got %+v", tt.errors, tt.warnings, report.Violations)
+			}
+			if tt.field != "" && report.Violations[0].Field != tt.field {
+				t.Errorf("Expected first violation on %q, got %+v", tt.field, report.Violations[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package core
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// LiveConfig controls the optional live polling tier
+type LiveConfig struct {
+	// Interval is how often current readings are polled; zero disables the tier
+	Interval time.Duration
+	// Thermostats limits live polling to these thermostat IDs; empty means all
+	Thermostats []string
+}
+
+// WithLiveTier enables polling of current readings between report intervals.
+// Readings are written as runtime_live documents, which sinks only receive
+// when they opt in to that type.
+func WithLiveTier(config LiveConfig) SchedulerOption {
+	return func(s *Scheduler) {
+		s.liveConfig = config
+	}
+}
+
+// liveTargets is the cached list of thermostats polled by the live tier for one provider
+type liveTargets struct {
+	thermostats []model.ThermostatRef
+	fetchedAt   time.Time
+}
+
+// liveTicker returns the live tier ticker channel, or nil when the tier is
+// disabled so the scheduler's select never fires on it
+func (s *Scheduler) liveTicker() (<-chan time.Time, func()) {
+	if s.liveConfig.Interval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(s.liveConfig.Interval)
+	return ticker.C, ticker.Stop
+}
+
+// pollLive fetches current readings from every provider that supports them
+// and writes them as runtime_live documents. It never touches offsets, so a
+// failed live poll has no effect on historical collection.
+func (s *Scheduler) pollLive(ctx context.Context) {
+	for _, provider := range s.providers {
+		liveProvider, ok := provider.(model.LiveProvider)
+		if !ok {
+			continue
+		}
+		if s.inMaintenance(provider, time.Now()) {
+			continue
+		}
+		if until := s.throttledUntil(ctx, providerScope(provider)); !until.IsZero() {
+			s.logger.Debug("Skipping live poll for throttled provider", "provider", provider.Info().Name, "until", until)
+			continue
+		}
+		if s.budgetExhausted(provider.Info().Name, time.Now()) {
+			s.logger.Debug("Skipping live poll, request budget spent", "provider", provider.Info().Name)
+			continue
+		}
+
+		thermostats, err := s.liveThermostats(ctx, provider)
+		if err != nil {
+			s.logger.Warn("Failed to list thermostats for live polling", "provider", provider.Info().Name, "error", err)
+			s.recordThrottle(ctx, providerScope(provider), err)
+			continue
+		}
+
+		var docs []model.Doc
+		for _, thermostat := range thermostats {
+			s.recordProviderRequest(provider.Info().Name)
+			callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+			reading, err := liveProvider.GetLive(callCtx, thermostat)
+			cancel()
+			if err != nil {
+				s.metrics.RecordProviderError(provider.Info().Name)
+				s.logger.Warn("Failed to get live reading",
+					"provider", provider.Info().Name,
+					"thermostat", thermostat.ID,
+					"error", err)
+				if s.recordThrottle(ctx, providerScope(provider), err) {
+					break
+				}
+				continue
+			}
+
+			canonical, err := s.normalizer.NormalizeRuntimeLive(reading)
+			if err != nil {
+				s.logger.Error("Failed to normalize live reading", "error", err)
+				continue
+			}
+			docID, err := s.idGenerator.GenerateRuntimeLiveID(canonical)
+			if err != nil {
+				s.logger.Error("Failed to generate document ID for runtime_live", "error", err)
+				continue
+			}
+			docs = append(docs, model.Doc{
+				ID:   docID,
+				Type: "runtime_live",
+				Body: canonical,
+			})
+		}
+
+		if err := s.writeToAllSinks(ctx, docs); err != nil {
+			s.logger.Error("Failed to write live readings", "provider", provider.Info().Name, "error", err)
+		}
+	}
+}
+
+// liveThermostats returns the thermostats to poll for a provider. The list is
+// cached for one poll interval so the live tier does not add a listing call
+// to every tick.
+func (s *Scheduler) liveThermostats(ctx context.Context, provider model.Provider) ([]model.ThermostatRef, error) {
+	name := provider.Info().Name
+	if cached, ok := s.liveTargets[name]; ok && time.Since(cached.fetchedAt) < s.pollInterval {
+		return cached.thermostats, nil
+	}
+
+	s.recordProviderRequest(name)
+	callCtx, cancel := s.providerContext(ctx, name)
+	thermostats, err := provider.ListThermostats(callCtx)
+	cancel()
+	if err != nil {
+		s.metrics.RecordProviderError(name)
+		return nil, err
+	}
+
+	if len(s.liveConfig.Thermostats) > 0 {
+		selected := make([]model.ThermostatRef, 0, len(s.liveConfig.Thermostats))
+		for _, thermostat := range thermostats {
+			if slices.Contains(s.liveConfig.Thermostats, thermostat.ID) {
+				selected = append(selected, thermostat)
+			}
+		}
+		thermostats = selected
+	}
+
+	s.liveTargets[name] = liveTargets{thermostats: thermostats, fetchedAt: time.Now()}
+	return thermostats, nil
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/pipeline"
+)
+
+// liveProvider is a mock provider that also reports live readings
+type liveProvider struct {
+	mockProvider
+	listCalls int
+	liveCalls int
+}
+
+func (p *liveProvider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	p.listCalls++
+	return []model.ThermostatRef{
+		{ID: "therm-1", Name: "Hallway", Provider: p.name},
+		{ID: "therm-2", Name: "Basement", Provider: p.name},
+	}, nil
+}
+
+func (p *liveProvider) GetLive(ctx context.Context, tr model.ThermostatRef) (model.LiveReading, error) {
+	p.liveCalls++
+	return model.LiveReading{
+		ThermostatRef: tr,
+		ReadingTime:   time.Date(2025, 1, 10, 12, 1, 30, 0, time.UTC),
+		Mode:          "heat",
+		TempC:         floatPtr(21.0),
+	}, nil
+}
+
+func TestPollLive(t *testing.T) {
+	t.Run("writes runtime_live documents for selected thermostats", func(t *testing.T) {
+		provider := &liveProvider{mockProvider: mockProvider{name: "test"}}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithLiveTier(LiveConfig{
+			Interval:    30 * time.Second,
+			Thermostats: []string{"therm-2"},
+		}))
+
+		scheduler.pollLive(testContext(t))
+
+		if len(sink.docs) != 1 {
+			t.Fatalf("Expected one runtime_live document, got %d", len(sink.docs))
+		}
+		doc := sink.docs[0]
+		if doc.Type != "runtime_live" || doc.ID != "therm-2:live:2025-01-10T12:01:30Z" {
+			t.Errorf("Unexpected document %s of type %s", doc.ID, doc.Type)
+		}
+
+		// The thermostat list is reused until the next poll interval
+		scheduler.pollLive(testContext(t))
+		if provider.listCalls != 1 {
+			t.Errorf("Expected thermostat list to be cached, got %d list calls", provider.listCalls)
+		}
+		if provider.liveCalls != 2 {
+			t.Errorf("Expected 2 live calls, got %d", provider.liveCalls)
+		}
+	})
+
+	t.Run("providers without live support are skipped", func(t *testing.T) {
+		provider := &mockProvider{name: "test"}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithLiveTier(LiveConfig{Interval: 30 * time.Second}))
+
+		scheduler.pollLive(testContext(t))
+
+		if len(sink.docs) != 0 {
+			t.Errorf("Expected no documents, got %d", len(sink.docs))
+		}
+	})
+
+	t.Run("sinks that do not opt in receive nothing", func(t *testing.T) {
+		provider := &liveProvider{mockProvider: mockProvider{name: "test"}}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, pipeline.NewRouter(sink, nil), NewMemoryOffsetStore(), WithLiveTier(LiveConfig{Interval: 30 * time.Second}))
+
+		scheduler.pollLive(testContext(t))
+
+		if len(sink.docs) != 0 {
+			t.Errorf("Expected live documents to be routed away, got %d", len(sink.docs))
+		}
+	})
+}
+
+func TestLiveTickerDisabled(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore())
+
+	tick, stop := scheduler.liveTicker()
+	defer stop()
+
+	if tick != nil {
+		t.Error("Expected nil ticker channel when the live tier is disabled")
+	}
+}
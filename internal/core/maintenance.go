@@ -0,0 +1,147 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// MaintenanceWindow is a recurring period during which a provider is not polled
+type MaintenanceWindow struct {
+	// Days the window starts on; empty means every day
+	Days []time.Weekday
+	// Start and End are clock offsets from local midnight. A window whose End
+	// is not after Start runs past midnight into the next day.
+	Start time.Duration
+	End   time.Duration
+	// Location is the time zone Start and End are expressed in (UTC if nil)
+	Location *time.Location
+}
+
+// Contains reports whether t falls inside the window
+func (w MaintenanceWindow) Contains(t time.Time) bool {
+	location := w.Location
+	if location == nil {
+		location = time.UTC
+	}
+	local := t.In(location)
+
+	// Use the wall clock rather than time since midnight so DST days behave
+	clock := time.Duration(local.Hour())*time.Hour +
+		time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+
+	if w.Start < w.End {
+		return w.startsOn(local.Weekday()) && clock >= w.Start && clock < w.End
+	}
+
+	// Overnight window: the evening part started today, the morning part yesterday
+	if clock >= w.Start {
+		return w.startsOn(local.Weekday())
+	}
+	if clock < w.End {
+		return w.startsOn((local.Weekday() + 6) % 7)
+	}
+	return false
+}
+
+// startsOn reports whether the window opens on the given weekday
+func (w MaintenanceWindow) startsOn(day time.Weekday) bool {
+	return len(w.Days) == 0 || slices.Contains(w.Days, day)
+}
+
+// WithMaintenanceWindows pauses polling of the named provider during the given windows
+func WithMaintenanceWindows(provider string, windows ...MaintenanceWindow) SchedulerOption {
+	return func(s *Scheduler) {
+		if len(windows) == 0 {
+			return
+		}
+		s.maintenance[provider] = &maintenanceState{windows: windows}
+	}
+}
+
+// maintenanceState tracks a provider's windows and the current pause, if any
+type maintenanceState struct {
+	windows  []MaintenanceWindow
+	pausedAt time.Time
+}
+
+// active reports whether any window contains t
+func (m *maintenanceState) active(t time.Time) bool {
+	for _, window := range m.windows {
+		if window.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// inMaintenance reports whether provider is inside a maintenance window without
+// changing pause state. The live tier uses it so only the main poll handles resumes.
+func (s *Scheduler) inMaintenance(provider model.Provider, now time.Time) bool {
+	state, ok := s.maintenance[provider.Info().Name]
+	return ok && state.active(now)
+}
+
+// checkMaintenance reports whether provider should be skipped at now. It logs
+// once when a window starts and, on the first check after it ends, returns
+// resumed so the caller can catch up on the missed window.
+func (s *Scheduler) checkMaintenance(provider model.Provider, now time.Time) (paused, resumed bool) {
+	state, ok := s.maintenance[provider.Info().Name]
+	if !ok {
+		return false, false
+	}
+
+	if state.active(now) {
+		if state.pausedAt.IsZero() {
+			state.pausedAt = now
+			s.logger.Info("Provider maintenance window started, pausing polling", "provider", provider.Info().Name)
+		}
+		return true, false
+	}
+
+	if state.pausedAt.IsZero() {
+		return false, false
+	}
+
+	s.logger.Info("Provider maintenance window ended, resuming polling",
+		"provider", provider.Info().Name,
+		"paused_for", now.Sub(state.pausedAt).Round(time.Second))
+	state.pausedAt = time.Time{}
+	return false, true
+}
+
+// backfillAfterMaintenance catches up thermostats after a maintenance window.
+// Thermostats with a runtime offset catch up on the regular poll, which fetches
+// everything since the offset; thermostats whose initial backfill was skipped
+// during the window have no offset and are backfilled here.
+func (s *Scheduler) backfillAfterMaintenance(ctx context.Context, provider model.Provider, now time.Time) error {
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	thermostats, err := provider.ListThermostats(callCtx)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("listing thermostats: %w", err)
+	}
+
+	for _, thermostat := range thermostats {
+		lastRuntime, err := s.offsetStore.GetLastRuntimeTime(ctx, thermostat.ID)
+		if err != nil || !lastRuntime.IsZero() {
+			continue
+		}
+
+		if _, err := s.startBackfill(ctx, provider, thermostat, now.Add(-s.backfillWindow), now); err != nil {
+			s.logger.Error("Failed to backfill thermostat after maintenance",
+				"provider", provider.Info().Name,
+				"thermostat", thermostat.ID,
+				"error", err)
+			if s.recordThrottle(ctx, providerScope(provider), err) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	// 2025-01-12 is a Sunday
+	sunday := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 12, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		window   MaintenanceWindow
+		at       time.Time
+		expected bool
+	}{
+		{
+			name:     "inside daily window",
+			window:   MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour},
+			at:       sunday(2, 0),
+			expected: true,
+		},
+		{
+			name:     "end is exclusive",
+			window:   MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour},
+			at:       sunday(3, 0),
+			expected: false,
+		},
+		{
+			name:     "wrong day",
+			window:   MaintenanceWindow{Days: []time.Weekday{time.Saturday}, Start: time.Hour, End: 3 * time.Hour},
+			at:       sunday(2, 0),
+			expected: false,
+		},
+		{
+			name:     "overnight window evening part",
+			window:   MaintenanceWindow{Days: []time.Weekday{time.Sunday}, Start: 23 * time.Hour, End: time.Hour},
+			at:       sunday(23, 30),
+			expected: true,
+		},
+		{
+			name:     "overnight window morning part belongs to previous day",
+			window:   MaintenanceWindow{Days: []time.Weekday{time.Saturday}, Start: 23 * time.Hour, End: time.Hour},
+			at:       sunday(0, 30),
+			expected: true,
+		},
+		{
+			name:     "overnight window morning part of a different day",
+			window:   MaintenanceWindow{Days: []time.Weekday{time.Sunday}, Start: 23 * time.Hour, End: time.Hour},
+			at:       sunday(0, 30),
+			expected: false,
+		},
+		{
+			name:     "window in local time",
+			window:   MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour, Location: chicago},
+			at:       sunday(8, 0), // 02:00 in Chicago
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := tt.window.Contains(tt.at); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMaintenancePausesPolling(t *testing.T) {
+	ctx := testContext(t)
+	allDay := MaintenanceWindow{Start: 0, End: 24 * time.Hour}
+
+	t.Run("provider is not polled during a window", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "elasticsearch"}, NewMemoryOffsetStore(),
+			WithMaintenanceWindows("ecobee", allDay))
+
+		if err := scheduler.performInitialBackfill(ctx); err != nil {
+			t.Fatalf("performInitialBackfill failed: %v", err)
+		}
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 0 {
+			t.Errorf("Expected no provider calls during maintenance, got %d", provider.listCalls)
+		}
+	})
+
+	t.Run("other providers keep polling", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "elasticsearch"}, NewMemoryOffsetStore(),
+			WithMaintenanceWindows("nest", allDay))
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 1 {
+			t.Errorf("Expected 1 list call, got %d", provider.listCalls)
+		}
+	})
+
+	t.Run("resuming backfills thermostats without offsets", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		// A window on another weekday is never active today
+		otherDay := MaintenanceWindow{Days: []time.Weekday{(time.Now().Weekday() + 3) % 7}, Start: 0, End: 24 * time.Hour}
+		scheduler := newTestScheduler(provider, &mockSink{name: "elasticsearch"}, NewMemoryOffsetStore(),
+			WithMaintenanceWindows("ecobee", otherDay))
+		scheduler.maintenance["ecobee"].pausedAt = time.Now().Add(-2 * time.Hour)
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		// One listing for the catch-up backfill and one for the regular poll
+		if provider.listCalls != 2 {
+			t.Errorf("Expected 2 list calls after resuming, got %d", provider.listCalls)
+		}
+		if !scheduler.maintenance["ecobee"].pausedAt.IsZero() {
+			t.Error("Expected pause to be cleared after resuming")
+		}
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 3 {
+			t.Errorf("Expected catch-up to run only once, got %d list calls", provider.listCalls)
+		}
+	})
+}
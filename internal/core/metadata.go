@@ -0,0 +1,135 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// defaultMetadataRefresh is how often location metadata is re-read from providers
+const defaultMetadataRefresh = 24 * time.Hour
+
+// MetadataConfig controls device_metadata documents and runtime enrichment
+type MetadataConfig struct {
+	// RefreshInterval is how often provider metadata is re-fetched
+	RefreshInterval time.Duration
+	// InjectFields lists Location fields (by JSON name) copied into runtime_5m documents
+	InjectFields []string
+	// Overrides supplies or corrects location fields per thermostat ID
+	Overrides map[string]model.Location
+}
+
+// WithMetadata configures location metadata collection and runtime enrichment
+func WithMetadata(config MetadataConfig) SchedulerOption {
+	return func(s *Scheduler) {
+		if config.RefreshInterval <= 0 {
+			config.RefreshInterval = defaultMetadataRefresh
+		}
+		s.metadataConfig = config
+	}
+}
+
+// metadataCache holds the latest known location for each thermostat
+type metadataCache struct {
+	mu      sync.RWMutex
+	entries map[string]metadataEntry
+}
+
+// metadataEntry is a cached location and when it was last refreshed
+type metadataEntry struct {
+	location  model.Location
+	fetchedAt time.Time
+}
+
+// newMetadataCache creates an empty metadata cache
+func newMetadataCache() *metadataCache {
+	return &metadataCache{entries: make(map[string]metadataEntry)}
+}
+
+// get returns the cached entry for a thermostat
+func (c *metadataCache) get(thermostatID string) (metadataEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[thermostatID]
+	return entry, ok
+}
+
+// set stores the location for a thermostat
+func (c *metadataCache) set(thermostatID string, location model.Location, fetchedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[thermostatID] = metadataEntry{location: location, fetchedAt: fetchedAt}
+}
+
+// refreshMetadata fetches location metadata when it is missing or stale, merges
+// configured overrides, and writes a device_metadata document. Providers that
+// do not implement model.MetadataProvider rely on overrides alone.
+func (s *Scheduler) refreshMetadata(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef) error {
+	now := time.Now()
+	if entry, ok := s.metadata.get(thermostat.ID); ok && now.Sub(entry.fetchedAt) < s.metadataConfig.RefreshInterval {
+		return nil
+	}
+
+	metadata := model.Metadata{
+		ThermostatRef: thermostat,
+		CollectedAt:   now,
+	}
+
+	if metadataProvider, ok := provider.(model.MetadataProvider); ok {
+		s.recordProviderRequest(provider.Info().Name)
+		callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+		fetched, err := metadataProvider.GetMetadata(callCtx, thermostat)
+		cancel()
+		if err != nil {
+			s.metrics.RecordProviderError(provider.Info().Name)
+			return fmt.Errorf("getting metadata: %w", err)
+		}
+		metadata = fetched
+	}
+
+	if override, ok := s.metadataConfig.Overrides[thermostat.ID]; ok {
+		metadata.Location = metadata.Location.Merge(override)
+	}
+
+	// Cache even an empty location so providers without metadata are not asked every cycle
+	s.metadata.set(thermostat.ID, metadata.Location, now)
+	s.observeTimezone(thermostat.ID, metadata.Location.TimeZone)
+
+	if metadata.Location.IsZero() {
+		return nil
+	}
+
+	canonical := s.normalizer.NormalizeDeviceMetadata(metadata, provider.Info().Name)
+	docID, err := s.idGenerator.GenerateDeviceMetadataID(canonical)
+	if err != nil {
+		return fmt.Errorf("generating document ID for device_metadata: %w", err)
+	}
+
+	doc := model.Doc{
+		ID:   docID,
+		Type: "device_metadata",
+		Body: canonical,
+	}
+	if err := s.writeToAllSinks(ctx, []model.Doc{doc}); err != nil {
+		return fmt.Errorf("writing device metadata: %w", err)
+	}
+
+	return nil
+}
+
+// enrichRuntime copies the configured location fields into a runtime row.
+// It must run after the document ID is generated so metadata edits never
+// change runtime IDs.
+func (s *Scheduler) enrichRuntime(row *model.Runtime5m) {
+	if len(s.metadataConfig.InjectFields) == 0 {
+		return
+	}
+	entry, ok := s.metadata.get(row.ThermostatID)
+	if !ok {
+		return
+	}
+	row.Location = entry.location.Select(s.metadataConfig.InjectFields)
+}
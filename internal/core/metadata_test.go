@@ -0,0 +1,163 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// metadataProvider is a mock provider that also reports location metadata
+type metadataProvider struct {
+	mockProvider
+	location      model.Location
+	metadataCalls int
+}
+
+func (p *metadataProvider) GetMetadata(ctx context.Context, tr model.ThermostatRef) (model.Metadata, error) {
+	p.metadataCalls++
+	return model.Metadata{ThermostatRef: tr, CollectedAt: time.Now(), Location: p.location}, nil
+}
+
+func TestRefreshMetadata(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "therm-1", Name: "Hallway", Provider: "test"}
+	sqft := 1800
+
+	t.Run("writes merged metadata and caches it", func(t *testing.T) {
+		provider := &metadataProvider{
+			mockProvider: mockProvider{name: "test"},
+			location:     model.Location{City: "Chicago", Region: "IL"},
+		}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithMetadata(MetadataConfig{
+			Overrides: map[string]model.Location{
+				"therm-1": {SquareFootage: &sqft, HVACType: "heat_pump"},
+			},
+		}))
+
+		if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(sink.docs) != 1 || sink.docs[0].Type != "device_metadata" {
+			t.Fatalf("Expected one device_metadata document, got %+v", sink.docs)
+		}
+
+		metadata, ok := sink.docs[0].Body.(*model.DeviceMetadata)
+		if !ok {
+			t.Fatalf("Expected *model.DeviceMetadata body, got %T", sink.docs[0].Body)
+		}
+		if metadata.Location.City != "Chicago" || metadata.Location.HVACType != "heat_pump" {
+			t.Errorf("Expected provider and override fields to be merged, got %+v", metadata.Location)
+		}
+
+		// A second refresh inside the interval uses the cache
+		if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if provider.metadataCalls != 1 || len(sink.docs) != 1 {
+			t.Errorf("Expected cached metadata to be reused, got %d calls and %d docs", provider.metadataCalls, len(sink.docs))
+		}
+	})
+
+	t.Run("config overrides alone produce metadata", func(t *testing.T) {
+		provider := &mockProvider{name: "test"}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithMetadata(MetadataConfig{
+			Overrides: map[string]model.Location{"therm-1": {City: "Denver"}},
+		}))
+
+		if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(sink.docs) != 1 {
+			t.Errorf("Expected one device_metadata document, got %d", len(sink.docs))
+		}
+	})
+
+	t.Run("passes the timezone to analyzers", func(t *testing.T) {
+		provider := &metadataProvider{
+			mockProvider: mockProvider{name: "test"},
+			location:     model.Location{City: "Chicago", TimeZone: "America/Chicago"},
+		}
+		analyzer := &zoneAnalyzer{zones: make(map[string]*time.Location)}
+		scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(),
+			WithAnalyzers(analyzer),
+			WithMetadata(MetadataConfig{Overrides: map[string]model.Location{"therm-2": {TimeZone: "Not/AZone"}}}))
+
+		if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := scheduler.refreshMetadata(testContext(t), provider, model.ThermostatRef{ID: "therm-2"}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if loc := analyzer.zones["therm-1"]; loc == nil || loc.String() != "America/Chicago" {
+			t.Errorf("Expected America/Chicago, got %v", loc)
+		}
+		if _, ok := analyzer.zones["therm-2"]; ok {
+			t.Error("Expected an unknown timezone to be ignored")
+		}
+	})
+
+	t.Run("no metadata writes nothing", func(t *testing.T) {
+		provider := &mockProvider{name: "test"}
+		sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+		scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore())
+
+		if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(sink.docs) != 0 {
+			t.Errorf("Expected no documents, got %d", len(sink.docs))
+		}
+	})
+}
+
+func TestEnrichRuntime(t *testing.T) {
+	provider := &metadataProvider{
+		mockProvider: mockProvider{name: "test"},
+		location:     model.Location{City: "Chicago", PostalCode: "60601", HVACType: "heat_pump"},
+	}
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithMetadata(MetadataConfig{
+		InjectFields: []string{"city", "hvac_type"},
+	}))
+
+	thermostat := model.ThermostatRef{ID: "therm-1", Name: "Hallway"}
+	if err := scheduler.refreshMetadata(testContext(t), provider, thermostat); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	row := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "therm-1", EventTime: time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)}
+	idBefore, err := scheduler.idGenerator.GenerateRuntime5mID(row)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+
+	scheduler.enrichRuntime(row)
+
+	if row.Location["city"] != "Chicago" || row.Location["hvac_type"] != "heat_pump" {
+		t.Errorf("Expected selected fields to be injected, got %v", row.Location)
+	}
+	if _, ok := row.Location["postal_code"]; ok {
+		t.Error("Expected unselected fields to be left out")
+	}
+
+	// The scheduler generates IDs before enrichment; the enriched body hashing
+	// differently is why the order matters
+	idAfter, err := scheduler.idGenerator.GenerateRuntime5mID(row)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if idBefore == idAfter {
+		t.Error("Expected enrichment to change the body hash")
+	}
+
+	t.Run("unknown thermostat is left alone", func(t *testing.T) {
+		other := &model.Runtime5m{ThermostatID: "therm-2"}
+		scheduler.enrichRuntime(other)
+		if other.Location != nil {
+			t.Errorf("Expected no location, got %v", other.Location)
+		}
+	})
+}
@@ -3,24 +3,62 @@ package core
 import (
 	"fmt"
 	"log/slog"
+	"math"
 	"strings"
 	"time"
 
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+// DefaultTemperaturePrecision is the step canonical temperatures are rounded to
+const DefaultTemperaturePrecision = 0.1
+
+// plausibleMinC and plausibleMaxC bound the Celsius readings a thermostat can
+// realistically report. Values outside usually mean Fahrenheit stored as Celsius.
+const (
+	plausibleMinC = -50.0
+	plausibleMaxC = 60.0
 )
 
 // Normalizer converts provider-specific data to canonical format
 type Normalizer struct {
 	timezone        *time.Location
+	precision       float64
 	modeMap         map[string]string
 	climateMap      map[string]string
 	equipmentKeyMap map[string]string
 	eventKindMap    map[string]string
-	logger          *slog.Logger
+	// thermostatOffsets and sensorOffsets are added to measured temperatures,
+	// keyed by thermostat ID and sensor ID respectively
+	thermostatOffsets map[string]float64
+	sensorOffsets     map[string]float64
+	logger            *slog.Logger
+}
+
+// NormalizerOption configures optional normalizer behavior
+type NormalizerOption func(*Normalizer)
+
+// WithTemperaturePrecision sets the step (in °C) canonical temperatures are
+// rounded to
+func WithTemperaturePrecision(precision float64) NormalizerOption {
+	return func(n *Normalizer) {
+		n.precision = precision
+	}
+}
+
+// WithCalibration corrects measured temperatures at ingest by adding a fixed
+// offset (in °C) per thermostat and per sensor ID. Offsets apply before
+// rounding; setpoints are never adjusted.
+func WithCalibration(thermostatOffsets, sensorOffsets map[string]float64) NormalizerOption {
+	return func(n *Normalizer) {
+		n.thermostatOffsets = thermostatOffsets
+		n.sensorOffsets = sensorOffsets
+	}
 }
 
 // NewNormalizer creates a new normalizer
-func NewNormalizer(timezone string) (*Normalizer, error) {
+func NewNormalizer(timezone string, opts ...NormalizerOption) (*Normalizer, error) {
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
 		return nil, fmt.Errorf("loading timezone %s: %w", timezone, err)
@@ -29,9 +67,10 @@ func NewNormalizer(timezone string) (*Normalizer, error) {
 	// Use default logger if none provided
 	logger := slog.Default()
 
-	return &Normalizer{
-		timezone: loc,
-		logger:   logger,
+	n := &Normalizer{
+		timezone:  loc,
+		precision: DefaultTemperaturePrecision,
+		logger:    logger,
 		modeMap: map[string]string{
 			"heat":      "heat",
 			"heating":   "heat",
@@ -58,21 +97,33 @@ func NewNormalizer(timezone string) (*Normalizer, error) {
 			"VACATION": "Vacation",
 		},
 		equipmentKeyMap: map[string]string{
-			"compHeat1":   "compHeat1",
-			"compheat1":   "compHeat1",
-			"comp_heat_1": "compHeat1",
-			"compHeat2":   "compHeat2",
-			"compheat2":   "compHeat2",
-			"comp_heat_2": "compHeat2",
-			"compCool1":   "compCool1",
-			"compcool1":   "compCool1",
-			"comp_cool_1": "compCool1",
-			"compCool2":   "compCool2",
-			"compcool2":   "compCool2",
-			"comp_cool_2": "compCool2",
-			"fan":         "fan",
-			"Fan":         "fan",
-			"FAN":         "fan",
+			"compHeat1":    "compHeat1",
+			"compheat1":    "compHeat1",
+			"comp_heat_1":  "compHeat1",
+			"compHeat2":    "compHeat2",
+			"compheat2":    "compHeat2",
+			"comp_heat_2":  "compHeat2",
+			"compCool1":    "compCool1",
+			"compcool1":    "compCool1",
+			"comp_cool_1":  "compCool1",
+			"compCool2":    "compCool2",
+			"compcool2":    "compCool2",
+			"comp_cool_2":  "compCool2",
+			"auxHeat1":     "auxHeat1",
+			"auxheat1":     "auxHeat1",
+			"aux_heat_1":   "auxHeat1",
+			"auxHeat2":     "auxHeat2",
+			"auxheat2":     "auxHeat2",
+			"aux_heat_2":   "auxHeat2",
+			"auxHeat3":     "auxHeat3",
+			"auxheat3":     "auxHeat3",
+			"aux_heat_3":   "auxHeat3",
+			"fan":          "fan",
+			"Fan":          "fan",
+			"FAN":          "fan",
+			"humidifier":   "humidifier",
+			"dehumidifier": "dehumidifier",
+			"ventilator":   "ventilator",
 		},
 		eventKindMap: map[string]string{
 			"hold":            "hold",
@@ -86,12 +137,33 @@ func NewNormalizer(timezone string) (*Normalizer, error) {
 			"scheduled":       "schedule",
 			"manual":          "manual",
 			"manual_override": "manual",
+			"demand_response": model.EventKindDemandResponse,
+			"demandresponse":  model.EventKindDemandResponse,
+			"eco+":            model.EventKindDemandResponse,
+			"peak_relief":     model.EventKindDemandResponse,
 		},
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n, nil
 }
 
 // NormalizeRuntime5m converts provider runtime data to canonical format
 func (n *Normalizer) NormalizeRuntime5m(providerData model.RuntimeRow, provider string) (*model.Runtime5m, error) {
+	temps, err := n.runtimeCelsius(providerData)
+	if err != nil {
+		return nil, fmt.Errorf("thermostat %s: %w", providerData.ThermostatRef.ID, err)
+	}
+	n.warnImplausible(providerData.ThermostatRef.ID, providerData.EventTime, map[string]*float64{
+		"set_heat_c":     temps.SetHeatC,
+		"set_cool_c":     temps.SetCoolC,
+		"avg_temp_c":     temps.AvgTempC,
+		"outdoor_temp_c": temps.OutdoorTempC,
+	})
+
 	// Convert to canonical format
 	canonical := &model.Runtime5m{
 		Type:            "runtime_5m",
@@ -101,19 +173,56 @@ func (n *Normalizer) NormalizeRuntime5m(providerData model.RuntimeRow, provider
 		EventTime:       n.convertToUTC(providerData.EventTime),
 		Mode:            n.normalizeMode(providerData.Mode),
 		Climate:         n.normalizeClimate(providerData.Climate),
-		SetHeatC:        n.passThroughTemperature(providerData.SetHeatC),
-		SetCoolC:        n.passThroughTemperature(providerData.SetCoolC),
-		AvgTempC:        n.passThroughTemperature(providerData.AvgTempC),
-		OutdoorTempC:    n.passThroughTemperature(providerData.OutdoorTempC),
+		SetHeatC:        n.normalizeTemperature(temps.SetHeatC),
+		SetCoolC:        n.normalizeTemperature(temps.SetCoolC),
+		AvgTempC:        n.normalizeTemperature(n.calibrate(temps.AvgTempC, n.thermostatOffsets[providerData.ThermostatRef.ID])),
+		OutdoorTempC:    n.normalizeTemperature(temps.OutdoorTempC),
 		OutdoorHumidity: providerData.OutdoorHumidity,
 		Equipment:       n.normalizeEquipment(providerData.Equipment),
-		Sensors:         n.normalizeSensors(providerData.Sensors),
+		EquipmentSecs:   n.normalizeEquipmentSeconds(providerData.EquipmentSecs),
+		Sensors:         n.normalizeSensors(temps.Sensors),
 		Provider:        n.createProviderData(provider, providerData),
+		Filled:          providerData.Filled,
+	}
+	if providerData.Filled && providerData.Mode == "" {
+		// A null-filled bin has no readings; the default mode would report
+		// the system as off
+		canonical.Mode, canonical.Climate = "", ""
 	}
 
 	return canonical, nil
 }
 
+// NormalizeRuntimeLive converts a provider live reading to canonical format.
+// The raw provider payload is left out to keep live documents small.
+func (n *Normalizer) NormalizeRuntimeLive(providerData model.LiveReading) (*model.RuntimeLive, error) {
+	var err error
+	for _, temp := range []**float64{&providerData.SetHeatC, &providerData.SetCoolC, &providerData.TempC} {
+		if *temp, err = toCelsius(*temp, providerData.Unit); err != nil {
+			return nil, fmt.Errorf("thermostat %s: %w", providerData.ThermostatRef.ID, err)
+		}
+	}
+	n.warnImplausible(providerData.ThermostatRef.ID, providerData.ReadingTime, map[string]*float64{
+		"set_heat_c": providerData.SetHeatC,
+		"set_cool_c": providerData.SetCoolC,
+		"temp_c":     providerData.TempC,
+	})
+
+	return &model.RuntimeLive{
+		Type:           "runtime_live",
+		ThermostatID:   providerData.ThermostatRef.ID,
+		ThermostatName: providerData.ThermostatRef.Name,
+		HouseholdID:    providerData.ThermostatRef.HouseholdID,
+		EventTime:      n.convertToUTC(providerData.ReadingTime),
+		Mode:           n.normalizeMode(providerData.Mode),
+		SetHeatC:       n.normalizeTemperature(providerData.SetHeatC),
+		SetCoolC:       n.normalizeTemperature(providerData.SetCoolC),
+		TempC:          n.normalizeTemperature(n.calibrate(providerData.TempC, n.thermostatOffsets[providerData.ThermostatRef.ID])),
+		Humidity:       providerData.Humidity,
+		Equipment:      n.normalizeEquipment(providerData.Equipment),
+	}, nil
+}
+
 // NormalizeTransition creates a transition document from state changes
 func (n *Normalizer) NormalizeTransition(
 	thermostatRef model.ThermostatRef,
@@ -141,16 +250,58 @@ func (n *Normalizer) NormalizeDeviceSnapshot(
 	provider string,
 ) *model.DeviceSnapshot {
 	return &model.DeviceSnapshot{
-		Type:           "device_snapshot",
+		Type:            "device_snapshot",
+		CollectedAt:     n.convertToUTC(providerData.CollectedAt),
+		ThermostatID:    providerData.ThermostatRef.ID,
+		ThermostatName:  providerData.ThermostatRef.Name,
+		Revision:        providerData.Revision,
+		Model:           providerData.Model,
+		FirmwareVersion: providerData.FirmwareVersion,
+		Program:         providerData.Program,
+		EventsActive:    providerData.EventsActive,
+		Events:          n.normalizeEvents(providerData.Events),
+		Provider:        n.createProviderData(provider, providerData),
+	}
+}
+
+// NormalizeDeviceMetadata converts provider metadata to canonical format
+func (n *Normalizer) NormalizeDeviceMetadata(
+	providerData model.Metadata,
+	provider string,
+) *model.DeviceMetadata {
+	return &model.DeviceMetadata{
+		Type:           "device_metadata",
 		CollectedAt:    n.convertToUTC(providerData.CollectedAt),
 		ThermostatID:   providerData.ThermostatRef.ID,
 		ThermostatName: providerData.ThermostatRef.Name,
-		Program:        providerData.Program,
-		EventsActive:   providerData.EventsActive,
+		HouseholdID:    providerData.ThermostatRef.HouseholdID,
+		Location:       providerData.Location,
 		Provider:       n.createProviderData(provider, providerData),
 	}
 }
 
+// normalizeEvents maps provider event kinds to canonical kinds and converts times to UTC
+func (n *Normalizer) normalizeEvents(events []model.Event) []model.Event {
+	if events == nil {
+		return nil
+	}
+
+	normalized := make([]model.Event, 0, len(events))
+	for _, event := range events {
+		kind := n.normalizeEventKind(event.Kind)
+		if kind == "unknown" && event.Name != "" {
+			kind = n.inferEventKindFromName(event.Name)
+		}
+		event.Kind = kind
+		event.Start = n.convertToUTC(event.Start)
+		event.End = n.convertToUTC(event.End)
+		event.SetHeatC = n.normalizeTemperature(event.SetHeatC)
+		event.SetCoolC = n.normalizeTemperature(event.SetCoolC)
+		normalized = append(normalized, event)
+	}
+	return normalized
+}
+
 // convertToUTC converts a time to UTC, preserving the original timezone info
 func (n *Normalizer) convertToUTC(t time.Time) time.Time {
 	if t.IsZero() {
@@ -197,12 +348,102 @@ func (n *Normalizer) normalizeClimate(climate string) string {
 	return climate // Keep original if not recognized
 }
 
-// passThroughTemperature passes temperature values through unchanged
-// The normalizer now assumes that providers have already converted temperatures to Celsius
-func (n *Normalizer) passThroughTemperature(temp *float64) *float64 {
-	// Simply pass through the temperature value
-	// Providers are responsible for converting their temperature formats to Celsius
-	return temp
+// normalizeTemperature rounds a Celsius temperature to the configured precision.
+// Providers are responsible for converting their temperature formats to Celsius;
+// rounding strips conversion noise (22.222222222222218) so documents stay small
+// and hash-based IDs don't change with floating point artifacts.
+func (n *Normalizer) normalizeTemperature(temp *float64) *float64 {
+	if temp == nil || n.precision <= 0 {
+		return temp
+	}
+	rounded := roundToPrecision(*temp, n.precision)
+	return &rounded
+}
+
+// runtimeTemperatures holds a runtime row's temperatures converted to Celsius
+type runtimeTemperatures struct {
+	SetHeatC     *float64
+	SetCoolC     *float64
+	AvgTempC     *float64
+	OutdoorTempC *float64
+	Sensors      map[string]float64
+}
+
+// runtimeCelsius converts a runtime row's temperatures from its declared unit
+// to Celsius. The row itself is left untouched so the provider payload keeps
+// the values as delivered.
+func (n *Normalizer) runtimeCelsius(row model.RuntimeRow) (runtimeTemperatures, error) {
+	temps := runtimeTemperatures{Sensors: row.Sensors}
+	var err error
+	if temps.SetHeatC, err = toCelsius(row.SetHeatC, row.Unit); err != nil {
+		return temps, err
+	}
+	if temps.SetCoolC, err = toCelsius(row.SetCoolC, row.Unit); err != nil {
+		return temps, err
+	}
+	if temps.AvgTempC, err = toCelsius(row.AvgTempC, row.Unit); err != nil {
+		return temps, err
+	}
+	if temps.OutdoorTempC, err = toCelsius(row.OutdoorTempC, row.Unit); err != nil {
+		return temps, err
+	}
+	if row.Sensors == nil || row.Unit == "" || row.Unit == temperature.Celsius {
+		return temps, nil
+	}
+	temps.Sensors = make(map[string]float64, len(row.Sensors))
+	for sensorID, temp := range row.Sensors {
+		converted, err := toCelsius(&temp, row.Unit)
+		if err != nil {
+			return temps, err
+		}
+		temps.Sensors[sensorID] = *converted
+	}
+	return temps, nil
+}
+
+// toCelsius converts a temperature in the given unit to Celsius. An empty unit
+// means the provider already delivers Celsius.
+func toCelsius(temp *float64, unit temperature.Unit) (*float64, error) {
+	if temp == nil || unit == "" || unit == temperature.Celsius {
+		return temp, nil
+	}
+	converted, err := temperature.ConvertToCelsius(temp, temperature.Format{Unit: unit, Scale: temperature.ScaleNone})
+	if err != nil {
+		return nil, fmt.Errorf("converting temperature: %w", err)
+	}
+	return converted, nil
+}
+
+// warnImplausible logs Celsius values no thermostat would report, which
+// usually means a provider stored another unit without declaring it
+func (n *Normalizer) warnImplausible(thermostatID string, eventTime time.Time, temps map[string]*float64) {
+	for field, temp := range temps {
+		if temp == nil || (*temp >= plausibleMinC && *temp <= plausibleMaxC) {
+			continue
+		}
+		n.logger.Warn("Implausible Celsius temperature encountered",
+			"thermostat", thermostatID,
+			"event_time", eventTime,
+			"field", field,
+			"value", *temp,
+			"suggestion", "check that the provider converts to Celsius or declares its unit")
+	}
+}
+
+// calibrate adds a calibration offset to a measured temperature
+func (n *Normalizer) calibrate(temp *float64, offset float64) *float64 {
+	if temp == nil || offset == 0 {
+		return temp
+	}
+	calibrated := *temp + offset
+	return &calibrated
+}
+
+// roundToPrecision rounds v to the nearest multiple of precision. Dividing by
+// the reciprocal (rather than multiplying by precision) yields the closest
+// float64 to the decimal result, e.g. 22.2 instead of 22.200000000000003.
+func roundToPrecision(v, precision float64) float64 {
+	return math.Round(v/precision) / (1 / precision)
 }
 
 // normalizeEquipment ensures equipment state is properly formatted
@@ -221,6 +462,21 @@ func (n *Normalizer) normalizeEquipment(equipment map[string]bool) map[string]bo
 	return normalized
 }
 
+// normalizeEquipmentSeconds applies the canonical equipment key names to
+// per-bin runtime seconds
+func (n *Normalizer) normalizeEquipmentSeconds(seconds map[string]int) map[string]int {
+	if seconds == nil {
+		return nil
+	}
+
+	normalized := make(map[string]int, len(seconds))
+	for key, value := range seconds {
+		normalized[n.normalizeEquipmentKey(key)] = value
+	}
+
+	return normalized
+}
+
 // normalizeEquipmentKey converts equipment key names to canonical format
 func (n *Normalizer) normalizeEquipmentKey(key string) string {
 	if normalized, ok := n.equipmentKeyMap[key]; ok {
@@ -241,10 +497,10 @@ func (n *Normalizer) normalizeSensors(sensors map[string]float64) map[string]flo
 		return nil
 	}
 
-	// Pass through sensor temperatures (providers should already have converted to Celsius)
+	// Calibrate and round sensor temperatures (providers should already have converted to Celsius)
 	normalized := make(map[string]float64)
 	for sensorID, temp := range sensors {
-		normalized[sensorID] = *n.passThroughTemperature(&temp)
+		normalized[sensorID] = *n.normalizeTemperature(n.calibrate(&temp, n.sensorOffsets[sensorID]))
 	}
 
 	return normalized
@@ -254,8 +510,8 @@ func (n *Normalizer) normalizeSensors(sensors map[string]float64) map[string]flo
 func (n *Normalizer) normalizeState(state model.State) model.State {
 	return model.State{
 		Mode:     n.normalizeMode(state.Mode),
-		SetHeatC: n.passThroughTemperature(state.SetHeatC),
-		SetCoolC: n.passThroughTemperature(state.SetCoolC),
+		SetHeatC: n.normalizeTemperature(state.SetHeatC),
+		SetCoolC: n.normalizeTemperature(state.SetCoolC),
 		Climate:  n.normalizeClimate(state.Climate),
 	}
 }
@@ -300,6 +556,13 @@ func (n *Normalizer) normalizeEventKind(kind string) string {
 func (n *Normalizer) inferEventKindFromName(name string) string {
 	nameLower := strings.ToLower(name)
 
+	// Utility programs are checked first: their names often contain "hold"
+	if strings.Contains(nameLower, "demand response") ||
+		strings.Contains(nameLower, "peak relief") ||
+		strings.Contains(nameLower, "eco+") {
+		return model.EventKindDemandResponse
+	}
+
 	if strings.Contains(nameLower, "hold") {
 		if strings.Contains(nameLower, "vacation") {
 			return "vacation"
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
 )
 
 func TestNewNormalizer(t *testing.T) {
@@ -238,6 +239,8 @@ func TestNormalizeEventKind(t *testing.T) {
 		{"scheduled", "schedule"},
 		{"manual", "manual"},
 		{"manual_override", "manual"},
+		{"demand_response", "demand_response"},
+		{"demandResponse", "demand_response"},
 		{"", "unknown"},
 		{"unknown", "unknown"},
 	}
@@ -267,6 +270,8 @@ func TestInferEventKindFromName(t *testing.T) {
 		{"Resume Schedule", "resume"},
 		{"Scheduled Change", "schedule"},
 		{"Manual Override", "manual"},
+		{"Peak Relief Hold", "demand_response"},
+		{"eco+ Demand Response", "demand_response"},
 		{"Unknown Event", "unknown"},
 		{"", "unknown"},
 	}
@@ -360,6 +365,7 @@ func TestNormalizeDeviceSnapshot(t *testing.T) {
 			Provider: "ecobee",
 		},
 		CollectedAt:  now,
+		Revision:     "250110120000",
 		Program:      map[string]any{"name": "test_program"},
 		EventsActive: []any{map[string]any{"type": "hold"}},
 	}
@@ -379,11 +385,55 @@ func TestNormalizeDeviceSnapshot(t *testing.T) {
 	if canonical.Program == nil {
 		t.Error("Expected program to be set")
 	}
+	if canonical.Revision != "250110120000" {
+		t.Errorf("Expected revision 250110120000, got %s", canonical.Revision)
+	}
 	if len(canonical.EventsActive) != 1 {
 		t.Errorf("Expected 1 active event, got %d", len(canonical.EventsActive))
 	}
 }
 
+func TestNormalizeRuntimeLive(t *testing.T) {
+	normalizer, err := NewNormalizer("UTC")
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+
+	reading := model.LiveReading{
+		ThermostatRef: model.ThermostatRef{ID: "test-thermostat", Name: "Test Thermostat", Provider: "ecobee"},
+		ReadingTime:   time.Date(2025, 1, 10, 12, 1, 30, 0, time.UTC),
+		Mode:          "HEAT",
+		SetHeatC:      floatPtr(20.00000001),
+		TempC:         floatPtr(21.04),
+		Humidity:      intPtr(40),
+		Equipment:     map[string]bool{"compHeat1": true, "fan": true},
+	}
+
+	canonical, err := normalizer.NormalizeRuntimeLive(reading)
+	if err != nil {
+		t.Fatalf("Failed to normalize live reading: %v", err)
+	}
+
+	if canonical.Type != "runtime_live" {
+		t.Errorf("Expected type runtime_live, got %s", canonical.Type)
+	}
+	if canonical.Mode != "heat" {
+		t.Errorf("Expected mode heat, got %s", canonical.Mode)
+	}
+	if canonical.SetHeatC == nil || *canonical.SetHeatC != 20.0 {
+		t.Errorf("Expected rounded heat setpoint 20.0, got %v", canonical.SetHeatC)
+	}
+	if canonical.TempC == nil || *canonical.TempC != 21.0 {
+		t.Errorf("Expected rounded temperature 21.0, got %v", canonical.TempC)
+	}
+	if !canonical.Equipment["compHeat1"] || !canonical.Equipment["fan"] {
+		t.Errorf("Expected equipment to be preserved, got %v", canonical.Equipment)
+	}
+	if !canonical.EventTime.Equal(reading.ReadingTime) {
+		t.Errorf("Expected event time %v, got %v", reading.ReadingTime, canonical.EventTime)
+	}
+}
+
 func TestNormalizeEquipment(t *testing.T) {
 	normalizer, err := NewNormalizer("UTC")
 	if err != nil {
@@ -406,14 +456,18 @@ func TestNormalizeEquipment(t *testing.T) {
 
 	t.Run("normalized keys", func(t *testing.T) {
 		input := map[string]bool{
-			"compheat1": true,
-			"Fan":       true,
+			"compheat1":  true,
+			"Fan":        true,
+			"aux_heat_2": true,
 		}
 		result := normalizer.normalizeEquipment(input)
 
 		if result["compHeat1"] != true {
 			t.Error("Expected compHeat1 to be normalized and true")
 		}
+		if result["auxHeat2"] != true {
+			t.Error("Expected auxHeat2 to be normalized and true")
+		}
 		if result["fan"] != true {
 			t.Error("Expected fan to be normalized and true")
 		}
@@ -430,6 +484,16 @@ func TestNormalizeEquipment(t *testing.T) {
 			t.Error("Expected unknown key to be preserved")
 		}
 	})
+
+	t.Run("runtime seconds use the same keys", func(t *testing.T) {
+		result := normalizer.normalizeEquipmentSeconds(map[string]int{"compheat1": 300, "ventilator": 45})
+		if result["compHeat1"] != 300 || result["ventilator"] != 45 {
+			t.Errorf("Expected normalized runtime seconds, got %v", result)
+		}
+		if normalizer.normalizeEquipmentSeconds(nil) != nil {
+			t.Error("Expected nil result for nil input")
+		}
+	})
 }
 
 func TestNormalizeSensors(t *testing.T) {
@@ -474,16 +538,12 @@ func TestNormalizeSensors(t *testing.T) {
 	})
 }
 
-func TestPassThroughTemperature(t *testing.T) {
-	normalizer, err := NewNormalizer("UTC")
-	if err != nil {
-		t.Fatalf("Failed to create normalizer: %v", err)
-	}
-
+func TestNormalizeTemperature(t *testing.T) {
 	tests := []struct {
-		name     string
-		input    *float64
-		expected *float64
+		name      string
+		precision *float64
+		input     *float64
+		expected  *float64
 	}{
 		{
 			name:     "nil input",
@@ -496,25 +556,62 @@ func TestPassThroughTemperature(t *testing.T) {
 			expected: floatPtr(0.0),
 		},
 		{
-			name:     "positive temperature",
+			name:     "already rounded temperature",
 			input:    floatPtr(22.2),
 			expected: floatPtr(22.2),
 		},
 		{
 			name:     "negative temperature",
-			input:    floatPtr(-15.5),
-			expected: floatPtr(-15.5),
+			input:    floatPtr(-15.55),
+			expected: floatPtr(-15.6),
+		},
+		{
+			name:     "fahrenheit conversion noise",
+			input:    floatPtr(22.222222222222218),
+			expected: floatPtr(22.2),
+		},
+		{
+			name:     "multiplication artifact",
+			input:    floatPtr(22.200000000000003),
+			expected: floatPtr(22.2),
 		},
 		{
-			name:     "high precision temperature",
-			input:    floatPtr(20.123456789),
-			expected: floatPtr(20.123456789),
+			name:      "half degree precision",
+			precision: floatPtr(0.5),
+			input:     floatPtr(20.8),
+			expected:  floatPtr(21.0),
+		},
+		{
+			name:      "hundredth precision",
+			precision: floatPtr(0.01),
+			input:     floatPtr(20.123456789),
+			expected:  floatPtr(20.12),
+		},
+		{
+			name:      "rounding disabled",
+			precision: floatPtr(0),
+			input:     floatPtr(20.123456789),
+			expected:  floatPtr(20.123456789),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := normalizer.passThroughTemperature(tt.input)
+			var opts []NormalizerOption
+			if tt.precision != nil {
+				opts = append(opts, WithTemperaturePrecision(*tt.precision))
+			}
+			normalizer, err := NewNormalizer("UTC", opts...)
+			if err != nil {
+				t.Fatalf("Failed to create normalizer: %v", err)
+			}
+
+			var original float64
+			if tt.input != nil {
+				original = *tt.input
+			}
+
+			result := normalizer.normalizeTemperature(tt.input)
 
 			if tt.expected == nil {
 				if result != nil {
@@ -524,20 +621,215 @@ func TestPassThroughTemperature(t *testing.T) {
 			}
 
 			if result == nil {
-				t.Errorf("Expected %f, got nil", *tt.expected)
+				t.Errorf("Expected %v, got nil", *tt.expected)
 				return
 			}
 
+			// Exact comparison: rounded values must be identical for ID hashing
 			if *result != *tt.expected {
-				t.Errorf("Expected %f, got %f", *tt.expected, *result)
+				t.Errorf("Expected %v, got %v", *tt.expected, *result)
+			}
+
+			if *tt.input != original {
+				t.Error("Expected input value not to be modified")
 			}
+		})
+	}
+}
+
+func TestTemperaturePrecisionIDStability(t *testing.T) {
+	idGenerator := model.NewIDGenerator()
+	eventTime := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	ref := model.ThermostatRef{ID: "t1", Name: "Hallway"}
+
+	transitionID := func(t *testing.T, normalizer *Normalizer, setHeat float64) string {
+		transition := normalizer.NormalizeTransition(
+			ref,
+			eventTime,
+			model.State{Mode: "heat", SetHeatC: floatPtr(20.0), Climate: "Home"},
+			model.State{Mode: "heat", SetHeatC: floatPtr(setHeat), Climate: "Home"},
+			model.EventInfo{Kind: "manual"},
+			"ecobee",
+			nil,
+		)
+		id, err := idGenerator.GenerateTransitionID(transition)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		return id
+	}
+
+	runtimeID := func(t *testing.T, normalizer *Normalizer, avgTemp float64) string {
+		row := model.RuntimeRow{
+			ThermostatRef: ref,
+			EventTime:     eventTime,
+			Mode:          "heat",
+			AvgTempC:      floatPtr(avgTemp),
+		}
+		canonical, err := normalizer.NormalizeRuntime5m(row, "ecobee")
+		if err != nil {
+			t.Fatalf("Failed to normalize runtime: %v", err)
+		}
+		id, err := idGenerator.GenerateRuntime5mID(canonical)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		return id
+	}
+
+	defaultNormalizer, err := NewNormalizer("UTC")
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+	coarseNormalizer, err := NewNormalizer("UTC", WithTemperaturePrecision(0.5))
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+
+	t.Run("conversion noise does not change transition IDs", func(t *testing.T) {
+		if transitionID(t, defaultNormalizer, 22.222222222222218) != transitionID(t, defaultNormalizer, 22.22222222222222) {
+			t.Error("Expected setpoints that round identically to produce the same transition ID")
+		}
+	})
+
+	t.Run("same input and precision yields the same runtime ID", func(t *testing.T) {
+		if runtimeID(t, defaultNormalizer, 22.222222222222218) != runtimeID(t, defaultNormalizer, 22.222222222222218) {
+			t.Error("Expected runtime IDs to be deterministic")
+		}
+	})
+
+	t.Run("changing precision changes IDs", func(t *testing.T) {
+		if runtimeID(t, defaultNormalizer, 22.3) == runtimeID(t, coarseNormalizer, 22.3) {
+			t.Error("Expected a precision change to produce a new runtime ID")
+		}
+		if transitionID(t, defaultNormalizer, 22.3) == transitionID(t, coarseNormalizer, 22.3) {
+			t.Error("Expected a precision change to produce a new transition ID")
+		}
+	})
+}
+
+func TestCalibration(t *testing.T) {
+	normalizer, err := NewNormalizer("UTC", WithCalibration(
+		map[string]float64{"t1": -0.8},
+		map[string]float64{"rs:100": 0.5},
+	))
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+
+	row := model.RuntimeRow{
+		ThermostatRef: model.ThermostatRef{ID: "t1"},
+		EventTime:     time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC),
+		Mode:          "heat",
+		SetHeatC:      floatPtr(21.0),
+		AvgTempC:      floatPtr(22.3),
+		Sensors:       map[string]float64{"rs:100": 19.6, "rs:101": 20.0},
+	}
+
+	t.Run("runtime readings are offset", func(t *testing.T) {
+		canonical, err := normalizer.NormalizeRuntime5m(row, "ecobee")
+		if err != nil {
+			t.Fatalf("Failed to normalize runtime: %v", err)
+		}
+		if *canonical.AvgTempC != 21.5 {
+			t.Errorf("Expected calibrated average temperature 21.5, got %v", *canonical.AvgTempC)
+		}
+		if *canonical.SetHeatC != 21.0 {
+			t.Errorf("Expected setpoint to be left alone, got %v", *canonical.SetHeatC)
+		}
+		if canonical.Sensors["rs:100"] != 20.1 || canonical.Sensors["rs:101"] != 20.0 {
+			t.Errorf("Unexpected calibrated sensors: %v", canonical.Sensors)
+		}
+	})
+
+	t.Run("live readings are offset", func(t *testing.T) {
+		live, err := normalizer.NormalizeRuntimeLive(model.LiveReading{
+			ThermostatRef: model.ThermostatRef{ID: "t1"},
+			TempC:         floatPtr(22.3),
+		})
+		if err != nil {
+			t.Fatalf("Failed to normalize live reading: %v", err)
+		}
+		if *live.TempC != 21.5 {
+			t.Errorf("Expected calibrated live temperature 21.5, got %v", *live.TempC)
+		}
+	})
+
+	t.Run("uncalibrated thermostat is unchanged", func(t *testing.T) {
+		other := row
+		other.ThermostatRef = model.ThermostatRef{ID: "t2"}
+		canonical, err := normalizer.NormalizeRuntime5m(other, "ecobee")
+		if err != nil {
+			t.Fatalf("Failed to normalize runtime: %v", err)
+		}
+		if *canonical.AvgTempC != 22.3 {
+			t.Errorf("Expected 22.3, got %v", *canonical.AvgTempC)
+		}
+	})
+}
 
-			// Verify it's the same pointer (pass-through behavior)
-			if result != tt.input {
-				t.Error("Expected result to be the same pointer as input (pass-through)")
+func TestNormalizeDeclaredUnits(t *testing.T) {
+	normalizer, err := NewNormalizer("UTC")
+	if err != nil {
+		t.Fatalf("Failed to create normalizer: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		unit        temperature.Unit
+		avgTemp     float64
+		sensorTemp  float64
+		expected    float64
+		expectError bool
+	}{
+		{name: "undeclared unit is celsius", avgTemp: 21.5, sensorTemp: 21.5, expected: 21.5},
+		{name: "explicit celsius", unit: temperature.Celsius, avgTemp: 21.5, sensorTemp: 21.5, expected: 21.5},
+		{name: "fahrenheit is converted", unit: temperature.Fahrenheit, avgTemp: 72.0, sensorTemp: 72.0, expected: 22.2},
+		{name: "kelvin is converted", unit: temperature.Kelvin, avgTemp: 294.65, sensorTemp: 294.65, expected: 21.5},
+		{name: "unknown unit is rejected", unit: "rankine", avgTemp: 530.0, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := model.RuntimeRow{
+				ThermostatRef: model.ThermostatRef{ID: "t1"},
+				Unit:          tt.unit,
+				Mode:          "heat",
+				AvgTempC:      floatPtr(tt.avgTemp),
+				Sensors:       map[string]float64{"rs:100": tt.sensorTemp},
+			}
+			canonical, err := normalizer.NormalizeRuntime5m(row, "test")
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error for unsupported unit")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if *canonical.AvgTempC != tt.expected || canonical.Sensors["rs:100"] != tt.expected {
+				t.Errorf("Expected %v, got avg %v and sensors %v", tt.expected, *canonical.AvgTempC, canonical.Sensors)
+			}
+			if *row.AvgTempC != tt.avgTemp {
+				t.Error("Expected the provider row to keep its original value")
 			}
 		})
 	}
+
+	t.Run("live reading in fahrenheit is converted", func(t *testing.T) {
+		live, err := normalizer.NormalizeRuntimeLive(model.LiveReading{
+			ThermostatRef: model.ThermostatRef{ID: "t1"},
+			Unit:          temperature.Fahrenheit,
+			TempC:         floatPtr(72.0),
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if *live.TempC != 22.2 {
+			t.Errorf("Expected 22.2, got %v", *live.TempC)
+		}
+	})
 }
 
 func TestConvertToUTC(t *testing.T) {
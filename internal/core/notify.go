@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// notifyTimeout bounds delivering one notification to every channel
+const notifyTimeout = 30 * time.Second
+
+// notifyQueueSize is how many notifications may wait for delivery before
+// further ones are dropped
+const notifyQueueSize = 64
+
+// failureState is one provider's or sink's run of uninterrupted failures
+type failureState struct {
+	since    time.Time
+	notified bool
+}
+
+// WithNotifier sends sensor alerts to notifier, along with a notification
+// when a provider's polls or a sink's writes have failed for failureAfter
+// without a success in between, and another when they recover
+func WithNotifier(notifier notify.Notifier, failureAfter time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.notifier = notifier
+		s.failureAfter = failureAfter
+		s.notifications = make(chan notify.Message, notifyQueueSize)
+	}
+}
+
+// notify queues msg for delivery in the background, so a slow push service
+// cannot delay polling. Messages are delivered in order; a full queue drops
+// the message.
+func (s *Scheduler) notify(msg notify.Message) {
+	if s.notifier == nil {
+		return
+	}
+
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+	select {
+	case s.notifications <- msg:
+	default:
+		s.logger.Warn("Dropping notification, delivery queue is full", "key", msg.Key)
+	}
+	if !s.notifying {
+		s.notifying = true
+		go s.deliverNotifications()
+	}
+}
+
+// deliverNotifications sends queued notifications one at a time, logging
+// delivery errors, and exits once the queue is empty so no goroutine
+// outlives the notifications it was started for
+func (s *Scheduler) deliverNotifications() {
+	for {
+		s.notifyMu.Lock()
+		var msg notify.Message
+		select {
+		case msg = <-s.notifications:
+			s.notifyMu.Unlock()
+		default:
+			s.notifying = false
+			s.notifyMu.Unlock()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+		if err := s.notifier.Notify(ctx, msg); err != nil {
+			s.logger.Warn("Failed to send notification", "key", msg.Key, "error", err)
+		}
+		cancel()
+	}
+}
+
+// notifyAlert sends a sensor or conflict alert
+func (s *Scheduler) notifyAlert(alert *model.Alert) {
+	name := alert.ThermostatName
+	if name == "" {
+		name = alert.ThermostatID
+	}
+	title := fmt.Sprintf("Sensor alert on %s", name)
+	switch alert.Kind {
+	case model.AlertKindHeatCoolConflict:
+		title = "Zones heating and cooling at once"
+	case model.AlertKindModeConflict:
+		title = fmt.Sprintf("Mode conflicts with the weather on %s", name)
+	}
+	s.notify(notify.Message{
+		Key:      "alert:" + alert.ThermostatID + ":" + alert.HouseholdID + ":" + alert.SensorID + ":" + alert.Kind,
+		Title:    title,
+		Body:     alert.Message,
+		Priority: notify.PriorityHigh,
+	})
+}
+
+// trackFailure records the outcome of a poll or write for scope, a provider
+// or sink throttle scope, and notifies when a run of failures reaches
+// failureAfter or ends after having been reported. action describes the
+// operation for messages, e.g. "Polling provider ecobee".
+func (s *Scheduler) trackFailure(scope, action string, err error, now time.Time) {
+	if s.notifier == nil {
+		return
+	}
+
+	state := s.failures[scope]
+	if err == nil {
+		if state != nil && state.notified {
+			s.notify(notify.Message{
+				Key:   scope + ":recovered",
+				Title: action + " has recovered",
+				Body:  fmt.Sprintf("Succeeded again after failing for %s", now.Sub(state.since).Round(time.Second)),
+			})
+		}
+		delete(s.failures, scope)
+		return
+	}
+
+	if state == nil {
+		state = &failureState{since: now}
+		s.failures[scope] = state
+	}
+	if state.notified || now.Sub(state.since) < s.failureAfter {
+		return
+	}
+	state.notified = true
+	s.notify(notify.Message{
+		Key:      scope,
+		Title:    fmt.Sprintf("%s has failed for %s", action, now.Sub(state.since).Round(time.Minute)),
+		Body:     err.Error(),
+		Priority: notify.PriorityHigh,
+	})
+}
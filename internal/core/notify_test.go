@@ -0,0 +1,95 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// channelNotifier passes notifications to a channel, since the scheduler
+// sends them in the background
+type channelNotifier chan notify.Message
+
+func (c channelNotifier) Notify(ctx context.Context, msg notify.Message) error {
+	c <- msg
+	return nil
+}
+
+// expectNotifications waits for count notifications and checks no more follow
+func expectNotifications(t *testing.T, notifier channelNotifier, count int) []notify.Message {
+	t.Helper()
+	var messages []notify.Message
+	for len(messages) < count {
+		select {
+		case msg := <-notifier:
+			messages = append(messages, msg)
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %d notifications, got %d", count, len(messages))
+		}
+	}
+	select {
+	case msg := <-notifier:
+		t.Errorf("Unexpected notification %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	return messages
+}
+
+func TestFailureNotifications(t *testing.T) {
+	notifier := make(channelNotifier, 10)
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "es"}, NewMemoryOffsetStore(),
+		WithNotifier(notifier, time.Hour))
+
+	start := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	failure := errors.New("connection refused")
+	steps := []struct {
+		at  time.Duration
+		err error
+	}{
+		{at: 0, err: failure},
+		{at: 30 * time.Minute, err: failure},
+		{at: time.Hour, err: failure},     // reported
+		{at: 2 * time.Hour, err: failure}, // already reported
+		{at: 3 * time.Hour},               // recovered
+		{at: 4 * time.Hour},               // still healthy
+		{at: 5 * time.Hour, err: failure}, // a new run, not yet long enough
+		{at: 5*time.Hour + time.Minute},   // recovered before it was reported
+	}
+	for _, step := range steps {
+		scheduler.trackFailure("sink:es", "Writing to sink es", step.err, start.Add(step.at))
+	}
+
+	messages := expectNotifications(t, notifier, 2)
+	if messages[0].Title != "Writing to sink es has failed for 1h0m0s" || messages[0].Body != "connection refused" ||
+		messages[0].Priority != notify.PriorityHigh || messages[0].Key != "sink:es" {
+		t.Errorf("Unexpected failure notification %+v", messages[0])
+	}
+	if messages[1].Title != "Writing to sink es has recovered" || !strings.Contains(messages[1].Body, "3h0m0s") {
+		t.Errorf("Unexpected recovery notification %+v", messages[1])
+	}
+}
+
+func TestSinkFailureNotification(t *testing.T) {
+	notifier := make(channelNotifier, 10)
+	sink := &mockSink{name: "es", shouldFail: true}
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, sink, NewMemoryOffsetStore(), WithNotifier(notifier, 0))
+
+	docs := []model.Doc{{ID: "d1", Type: "runtime_5m", Body: map[string]any{}}}
+	scheduler.writeBatch(testContext(t), docs)
+	scheduler.writeBatch(testContext(t), docs)
+	sink.shouldFail = false
+	scheduler.writeBatch(testContext(t), docs)
+
+	messages := expectNotifications(t, notifier, 2)
+	if messages[0].Key != "sink:es" || messages[0].Body != "mock write error" {
+		t.Errorf("Unexpected failure notification %+v", messages[0])
+	}
+	if messages[1].Key != "sink:es:recovered" {
+		t.Errorf("Unexpected recovery notification %+v", messages[1])
+	}
+}
@@ -0,0 +1,388 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names in the bolt offset store
+var (
+	boltRuntimeBucket  = []byte("runtime_offsets")
+	boltSnapshotBucket = []byte("snapshot_offsets")
+	boltThrottleBucket = []byte("throttle_state")
+	boltStateBucket    = []byte("last_state")
+	boltWrittenBucket  = []byte("written_documents")
+	boltEmittedBucket  = []byte("emitted_transitions")
+)
+
+// BoltOffsetStore implements OffsetStore, StateStore and TransitionStore in a single bbolt
+// file, for single-binary deployments that want no SQL engine at all
+type BoltOffsetStore struct {
+	db *bolt.DB
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewBoltOffsetStore opens or creates a bolt offset store at path. Only one
+// process can open the file; a second one fails after a short wait.
+func NewBoltOffsetStore(path string) (*BoltOffsetStore, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltRuntimeBucket, boltSnapshotBucket, boltThrottleBucket, boltStateBucket, boltWrittenBucket, boltEmittedBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return fmt.Errorf("creating bucket %s: %w", bucket, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("initializing buckets: %w", err)
+	}
+
+	return &BoltOffsetStore{db: db}, nil
+}
+
+// getTime reads an RFC 3339 timestamp, returning zero time if the key is absent
+func (s *BoltOffsetStore) getTime(bucket []byte, key string) (time.Time, error) {
+	var t time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(bucket).Get([]byte(key))
+		if value == nil {
+			return nil
+		}
+		parsed, err := time.Parse(time.RFC3339, string(value))
+		if err != nil {
+			return fmt.Errorf("parsing timestamp: %w", err)
+		}
+		t = parsed
+		return nil
+	})
+	return t, err
+}
+
+// putTime stores an RFC 3339 timestamp
+func (s *BoltOffsetStore) putTime(bucket []byte, key string, t time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put([]byte(key), []byte(t.Format(time.RFC3339)))
+	})
+}
+
+// GetLastRuntimeTime returns the last runtime timestamp for a thermostat
+func (s *BoltOffsetStore) GetLastRuntimeTime(ctx context.Context, thermostatID string) (time.Time, error) {
+	t, err := s.getTime(boltRuntimeBucket, thermostatID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading last runtime time: %w", err)
+	}
+	return t, nil
+}
+
+// SetLastRuntimeTime sets the last runtime timestamp for a thermostat
+func (s *BoltOffsetStore) SetLastRuntimeTime(ctx context.Context, thermostatID string, timestamp time.Time) error {
+	if err := s.putTime(boltRuntimeBucket, thermostatID, timestamp); err != nil {
+		return fmt.Errorf("setting last runtime time: %w", err)
+	}
+	return nil
+}
+
+// GetLastSnapshotTime returns the last snapshot timestamp for a thermostat
+func (s *BoltOffsetStore) GetLastSnapshotTime(ctx context.Context, thermostatID string) (time.Time, error) {
+	t, err := s.getTime(boltSnapshotBucket, thermostatID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading last snapshot time: %w", err)
+	}
+	return t, nil
+}
+
+// SetLastSnapshotTime sets the last snapshot timestamp for a thermostat
+func (s *BoltOffsetStore) SetLastSnapshotTime(ctx context.Context, thermostatID string, timestamp time.Time) error {
+	if err := s.putTime(boltSnapshotBucket, thermostatID, timestamp); err != nil {
+		return fmt.Errorf("setting last snapshot time: %w", err)
+	}
+	return nil
+}
+
+// GetThrottledUntil returns the time before which a provider or sink must not be called
+func (s *BoltOffsetStore) GetThrottledUntil(ctx context.Context, scope string) (time.Time, error) {
+	t, err := s.getTime(boltThrottleBucket, scope)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("reading throttle state: %w", err)
+	}
+	return t, nil
+}
+
+// SetThrottledUntil records the time before which a provider or sink must not be called
+func (s *BoltOffsetStore) SetThrottledUntil(ctx context.Context, scope string, until time.Time) error {
+	if err := s.putTime(boltThrottleBucket, scope, until); err != nil {
+		return fmt.Errorf("setting throttle state: %w", err)
+	}
+	return nil
+}
+
+// GetLastState returns a thermostat's state as of its latest runtime row, or
+// nil if none is stored
+func (s *BoltOffsetStore) GetLastState(ctx context.Context, thermostatID string) (*RuntimeState, error) {
+	var state *RuntimeState
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltStateBucket).Get([]byte(thermostatID))
+		if value == nil {
+			return nil
+		}
+		state = &RuntimeState{}
+		return json.Unmarshal(value, state)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading last state: %w", err)
+	}
+	return state, nil
+}
+
+// SetLastState stores a thermostat's state as of its latest runtime row
+func (s *BoltOffsetStore) SetLastState(ctx context.Context, thermostatID string, state RuntimeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding last state: %w", err)
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltStateBucket).Put([]byte(thermostatID), data)
+	})
+	if err != nil {
+		return fmt.Errorf("setting last state: %w", err)
+	}
+	return nil
+}
+
+// WrittenDocuments returns which of the given document IDs were written to
+// every sink within the dedupe retention
+func (s *BoltOffsetStore) WrittenDocuments(ctx context.Context, ids []string) (map[string]bool, error) {
+	written := make(map[string]bool)
+	cutoff := time.Now().Add(-dedupeRetention)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltWrittenBucket)
+		for _, id := range ids {
+			value := bucket.Get([]byte(id))
+			if value == nil {
+				continue
+			}
+			at, err := time.Parse(time.RFC3339, string(value))
+			if err == nil && at.After(cutoff) {
+				written[id] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading written documents: %w", err)
+	}
+	return written, nil
+}
+
+// MarkWritten remembers document IDs written to every sink, pruning expired
+// IDs and transitions at most once per statePruneInterval
+func (s *BoltOffsetStore) MarkWritten(ctx context.Context, ids []string, at time.Time) error {
+	s.mu.Lock()
+	prune := at.Sub(s.lastPruned) >= statePruneInterval
+	if prune {
+		s.lastPruned = at
+	}
+	s.mu.Unlock()
+
+	value := []byte(at.Format(time.RFC3339))
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltWrittenBucket)
+		if prune {
+			if err := pruneWritten(bucket, at.Add(-dedupeRetention)); err != nil {
+				return err
+			}
+			if err := pruneEmitted(tx.Bucket(boltEmittedBucket), at.Add(-transitionLedgerRetention)); err != nil {
+				return err
+			}
+		}
+		for _, id := range ids {
+			if err := bucket.Put([]byte(id), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("marking written documents: %w", err)
+	}
+	return nil
+}
+
+// ForgetWritten clears the written document IDs
+func (s *BoltOffsetStore) ForgetWritten(ctx context.Context) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(boltWrittenBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(boltWrittenBucket)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("clearing written documents: %w", err)
+	}
+	return nil
+}
+
+// pruneWritten deletes document IDs written before cutoff
+func pruneWritten(bucket *bolt.Bucket, cutoff time.Time) error {
+	var expired [][]byte
+	err := bucket.ForEach(func(key, value []byte) error {
+		at, err := time.Parse(time.RFC3339, string(value))
+		if err != nil || at.Before(cutoff) {
+			expired = append(expired, slices.Clone(key)) // keys are invalid once the bucket changes
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListOffsets returns every stored offset, throttle deadline and last state
+func (s *BoltOffsetStore) ListOffsets(ctx context.Context) (*OffsetDump, error) {
+	dump := newOffsetDump()
+	dump.States = make(map[string]RuntimeState)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		setters := []struct {
+			bucket []byte
+			set    func(key string, t time.Time)
+		}{
+			{boltRuntimeBucket, func(id string, t time.Time) {
+				offsets := dump.Thermostats[id]
+				offsets.LastRuntime = t
+				dump.Thermostats[id] = offsets
+			}},
+			{boltSnapshotBucket, func(id string, t time.Time) {
+				offsets := dump.Thermostats[id]
+				offsets.LastSnapshot = t
+				dump.Thermostats[id] = offsets
+			}},
+			{boltThrottleBucket, func(scope string, t time.Time) { dump.Throttles[scope] = t }},
+		}
+		for _, setter := range setters {
+			err := tx.Bucket(setter.bucket).ForEach(func(key, value []byte) error {
+				t, err := time.Parse(time.RFC3339, string(value))
+				if err != nil {
+					return fmt.Errorf("parsing timestamp: %w", err)
+				}
+				setter.set(string(key), t)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return tx.Bucket(boltStateBucket).ForEach(func(key, value []byte) error {
+			var state RuntimeState
+			if err := json.Unmarshal(value, &state); err != nil {
+				return fmt.Errorf("decoding last state: %w", err)
+			}
+			dump.States[string(key)] = state
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing offsets: %w", err)
+	}
+	return dump, nil
+}
+
+// emittedKey is the key of a thermostat's transition at an event time
+func emittedKey(thermostatID string, eventTime int64) []byte {
+	return []byte(thermostatID + "\x00" + strconv.FormatInt(eventTime, 10))
+}
+
+// EmittedTransitions returns the IDs of transitions already emitted for a
+// thermostat, keyed by event time in Unix seconds
+func (s *BoltOffsetStore) EmittedTransitions(ctx context.Context, thermostatID string, eventTimes []time.Time) (map[int64]string, error) {
+	emitted := make(map[int64]string)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEmittedBucket)
+		for _, t := range eventTimes {
+			if id := bucket.Get(emittedKey(thermostatID, t.Unix())); id != nil {
+				emitted[t.Unix()] = string(id)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading emitted transitions: %w", err)
+	}
+	return emitted, nil
+}
+
+// RecordTransitions remembers emitted transition IDs by event time, keeping
+// the first ID recorded for each
+func (s *BoltOffsetStore) RecordTransitions(ctx context.Context, thermostatID string, transitions map[int64]string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltEmittedBucket)
+		for eventTime, id := range transitions {
+			key := emittedKey(thermostatID, eventTime)
+			if bucket.Get(key) != nil {
+				continue
+			}
+			if err := bucket.Put(key, []byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("recording emitted transitions: %w", err)
+	}
+	return nil
+}
+
+// pruneEmitted deletes transitions with event times before cutoff
+func pruneEmitted(bucket *bolt.Bucket, cutoff time.Time) error {
+	var expired [][]byte
+	err := bucket.ForEach(func(key, value []byte) error {
+		_, suffix, _ := bytes.Cut(key, []byte{0})
+		eventTime, err := strconv.ParseInt(string(suffix), 10, 64)
+		if err != nil || eventTime < cutoff.Unix() {
+			expired = append(expired, slices.Clone(key))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range expired {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the database file
+func (s *BoltOffsetStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func newTestBoltStore(t *testing.T) *BoltOffsetStore {
+	t.Helper()
+	store, err := NewBoltOffsetStore(filepath.Join(t.TempDir(), "offsets.bolt"))
+	if err != nil {
+		t.Fatalf("Failed to create offset store: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	return store
+}
+
+func TestBoltOffsetStore(t *testing.T) {
+	store := newTestBoltStore(t)
+	ctx := context.Background()
+	expected := time.Now().UTC().Truncate(time.Second)
+
+	offsets := []struct {
+		name string
+		set  func(string, time.Time) error
+		get  func(string) (time.Time, error)
+	}{
+		{
+			name: "runtime",
+			set:  func(id string, ts time.Time) error { return store.SetLastRuntimeTime(ctx, id, ts) },
+			get:  func(id string) (time.Time, error) { return store.GetLastRuntimeTime(ctx, id) },
+		},
+		{
+			name: "snapshot",
+			set:  func(id string, ts time.Time) error { return store.SetLastSnapshotTime(ctx, id, ts) },
+			get:  func(id string) (time.Time, error) { return store.GetLastSnapshotTime(ctx, id) },
+		},
+		{
+			name: "throttle",
+			set:  func(scope string, ts time.Time) error { return store.SetThrottledUntil(ctx, scope, ts) },
+			get:  func(scope string) (time.Time, error) { return store.GetThrottledUntil(ctx, scope) },
+		},
+	}
+	for _, tt := range offsets {
+		t.Run(tt.name, func(t *testing.T) {
+			if ts, err := tt.get("t1"); err != nil || !ts.IsZero() {
+				t.Errorf("Expected zero time when not set, got %v (error %v)", ts, err)
+			}
+			if err := tt.set("t1", expected); err != nil {
+				t.Fatalf("Failed to set: %v", err)
+			}
+			if ts, err := tt.get("t1"); err != nil || !ts.Equal(expected) {
+				t.Errorf("Expected %v, got %v (error %v)", expected, ts, err)
+			}
+		})
+	}
+
+	t.Run("state store", func(t *testing.T) { testStateStore(t, store, "") })
+	t.Run("transition store", func(t *testing.T) { testTransitionStore(t, store, "") })
+}
+
+func TestProcessRuntimeWithStateStore(t *testing.T) {
+	store := newTestBoltStore(t)
+	sink := &recordingSink{mockSink: mockSink{name: "test"}}
+	provider := &mockProvider{name: "test"}
+	scheduler := newTestScheduler(provider, sink, store)
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "test"}
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	row := func(i int, mode string) model.RuntimeRow {
+		return model.RuntimeRow{
+			ThermostatRef: thermostat,
+			EventTime:     start.Add(time.Duration(i) * 5 * time.Minute),
+			Mode:          mode,
+			AvgTempC:      floatPtr(20.0),
+		}
+	}
+	countTypes := func(docs []model.Doc) map[string]int {
+		counts := make(map[string]int)
+		for _, doc := range docs {
+			counts[doc.Type]++
+		}
+		return counts
+	}
+
+	first := []model.RuntimeRow{row(0, "heat"), row(1, "heat")}
+	if err := scheduler.processRuntime(testContext(t), provider, thermostat, first); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	t.Run("transition across polls", func(t *testing.T) {
+		sink.docs = nil
+		if err := scheduler.processRuntime(testContext(t), provider, thermostat, []model.RuntimeRow{row(2, "cool")}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if counts := countTypes(sink.docs); counts["runtime_5m"] != 1 || counts["transition"] != 1 {
+			t.Errorf("Expected one runtime and one transition document, got %v", counts)
+		}
+	})
+
+	t.Run("rows fetched again are skipped", func(t *testing.T) {
+		sink.docs = nil
+		if err := scheduler.processRuntime(testContext(t), provider, thermostat, first); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(sink.docs) != 0 {
+			t.Errorf("Expected no documents to be rewritten, got %v", countTypes(sink.docs))
+		}
+	})
+}
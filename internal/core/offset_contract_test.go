@@ -0,0 +1,92 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// testStateStore checks the StateStore behavior every persistent offset store
+// shares. Keys start with prefix, so stores in a shared database do not see
+// another run's entries.
+func testStateStore(t *testing.T, store StateStore, prefix string) {
+	ctx := context.Background()
+	thermostatID := prefix + "t1"
+	eventTime := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("last state", func(t *testing.T) {
+		if state, err := store.GetLastState(ctx, thermostatID); err != nil || state != nil {
+			t.Errorf("Expected no state, got %+v (error %v)", state, err)
+		}
+		want := RuntimeState{EventTime: eventTime, State: model.State{Mode: "heat", SetHeatC: floatPtr(21)}}
+		if err := store.SetLastState(ctx, thermostatID, want); err != nil {
+			t.Fatalf("Failed to set state: %v", err)
+		}
+		state, err := store.GetLastState(ctx, thermostatID)
+		if err != nil || state == nil || state.State.Mode != "heat" || *state.State.SetHeatC != 21 || !state.EventTime.Equal(eventTime) {
+			t.Errorf("Unexpected state %+v (error %v)", state, err)
+		}
+	})
+
+	t.Run("written documents expire", func(t *testing.T) {
+		oldID, newID := prefix+"old", prefix+"new"
+		if err := store.MarkWritten(ctx, []string{oldID}, time.Now().Add(-dedupeRetention-time.Hour)); err != nil {
+			t.Fatalf("Failed to mark: %v", err)
+		}
+		if err := store.MarkWritten(ctx, []string{newID, newID}, time.Now()); err != nil {
+			t.Fatalf("Failed to mark: %v", err)
+		}
+		written, err := store.WrittenDocuments(ctx, []string{oldID, newID, prefix + "unknown"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(written) != 1 || !written[newID] {
+			t.Errorf("Expected only the recent document, got %v", written)
+		}
+	})
+
+	t.Run("forgotten documents are written again", func(t *testing.T) {
+		if err := store.ForgetWritten(ctx); err != nil {
+			t.Fatalf("Failed to forget: %v", err)
+		}
+		if written, err := store.WrittenDocuments(ctx, []string{prefix + "new"}); err != nil || len(written) != 0 {
+			t.Errorf("Expected no written documents, got %v (error %v)", written, err)
+		}
+	})
+}
+
+// testTransitionStore checks the TransitionStore behavior every persistent
+// offset store shares
+func testTransitionStore(t *testing.T, store TransitionStore, prefix string) {
+	ctx := context.Background()
+	thermostatID := prefix + "t1"
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	eventTimes := []time.Time{start, start.Add(5 * time.Minute), start.Add(10 * time.Minute)}
+
+	if emitted, err := store.EmittedTransitions(ctx, thermostatID, eventTimes); err != nil || len(emitted) != 0 {
+		t.Errorf("Expected no emitted transitions, got %v (error %v)", emitted, err)
+	}
+
+	if err := store.RecordTransitions(ctx, thermostatID, map[int64]string{eventTimes[0].Unix(): "a"}); err != nil {
+		t.Fatalf("Failed to record transitions: %v", err)
+	}
+	// The first ID recorded for an event time is kept
+	if err := store.RecordTransitions(ctx, thermostatID, map[int64]string{eventTimes[0].Unix(): "b", eventTimes[1].Unix(): "c"}); err != nil {
+		t.Fatalf("Failed to record transitions: %v", err)
+	}
+
+	emitted, err := store.EmittedTransitions(ctx, thermostatID, eventTimes)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[int64]string{eventTimes[0].Unix(): "a", eventTimes[1].Unix(): "c"}
+	if !reflect.DeepEqual(emitted, expected) {
+		t.Errorf("Expected emitted transitions %v, got %v", expected, emitted)
+	}
+	if other, err := store.EmittedTransitions(ctx, prefix+"t2", eventTimes); err != nil || len(other) != 0 {
+		t.Errorf("Expected no transitions for another thermostat, got %v (error %v)", other, err)
+	}
+}
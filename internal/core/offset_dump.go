@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// offsetDumpVersion is the format version written by DumpOffsets
+const offsetDumpVersion = 1
+
+// OffsetDump is the contents of an offset store in a backend-neutral form,
+// used to move scheduling state between stores and to restore it after a loss
+type OffsetDump struct {
+	Version     int                          `json:"version"`
+	ExportedAt  time.Time                    `json:"exported_at"`
+	Thermostats map[string]ThermostatOffsets `json:"thermostats"`
+	Throttles   map[string]time.Time         `json:"throttles"`
+	// States holds last runtime states from stores implementing StateStore
+	States map[string]RuntimeState `json:"states,omitempty"`
+}
+
+// ThermostatOffsets are one thermostat's polling offsets
+type ThermostatOffsets struct {
+	LastRuntime  time.Time `json:"last_runtime,omitzero"`
+	LastSnapshot time.Time `json:"last_snapshot,omitzero"`
+}
+
+// OffsetLister is implemented by offset stores whose contents can be dumped
+type OffsetLister interface {
+	// ListOffsets returns every stored offset, throttle deadline and, for
+	// stores that keep them, last runtime state
+	ListOffsets(ctx context.Context) (*OffsetDump, error)
+}
+
+// newOffsetDump returns an empty dump for ListOffsets implementations to fill
+func newOffsetDump() *OffsetDump {
+	return &OffsetDump{
+		Thermostats: make(map[string]ThermostatOffsets),
+		Throttles:   make(map[string]time.Time),
+	}
+}
+
+// DumpOffsets reads the whole contents of store
+func DumpOffsets(ctx context.Context, store OffsetStore) (*OffsetDump, error) {
+	lister, ok := store.(OffsetLister)
+	if !ok {
+		return nil, fmt.Errorf("offset store %T cannot be listed", store)
+	}
+	dump, err := lister.ListOffsets(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing offsets: %w", err)
+	}
+	dump.Version = offsetDumpVersion
+	dump.ExportedAt = time.Now().UTC()
+	return dump, nil
+}
+
+// RestoreOffsets writes a dump into store and returns how many entries were
+// written. Last states are skipped for stores that do not keep them. Stores
+// that only move offsets forward, such as the postgres store, keep any later
+// offset they already hold.
+func RestoreOffsets(ctx context.Context, store OffsetStore, dump *OffsetDump) (int, error) {
+	if dump.Version != offsetDumpVersion {
+		return 0, fmt.Errorf("unsupported offset dump version %d, expected %d", dump.Version, offsetDumpVersion)
+	}
+
+	restored := 0
+	for id, offsets := range dump.Thermostats {
+		if !offsets.LastRuntime.IsZero() {
+			if err := store.SetLastRuntimeTime(ctx, id, offsets.LastRuntime); err != nil {
+				return restored, fmt.Errorf("restoring runtime offset for %s: %w", id, err)
+			}
+			restored++
+		}
+		if !offsets.LastSnapshot.IsZero() {
+			if err := store.SetLastSnapshotTime(ctx, id, offsets.LastSnapshot); err != nil {
+				return restored, fmt.Errorf("restoring snapshot offset for %s: %w", id, err)
+			}
+			restored++
+		}
+	}
+
+	for scope, until := range dump.Throttles {
+		if err := store.SetThrottledUntil(ctx, scope, until); err != nil {
+			return restored, fmt.Errorf("restoring throttle state for %s: %w", scope, err)
+		}
+		restored++
+	}
+
+	if stateStore, ok := store.(StateStore); ok {
+		for id, state := range dump.States {
+			if err := stateStore.SetLastState(ctx, id, state); err != nil {
+				return restored, fmt.Errorf("restoring last state for %s: %w", id, err)
+			}
+			restored++
+		}
+	}
+
+	return restored, nil
+}
@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestDumpAndRestoreOffsets(t *testing.T) {
+	ctx := context.Background()
+	runtime := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	snapshot := runtime.Add(3 * time.Minute)
+	throttled := runtime.Add(time.Hour)
+
+	source := newTestBoltStore(t)
+	if err := source.SetLastRuntimeTime(ctx, "t1", runtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetLastSnapshotTime(ctx, "t1", snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetLastSnapshotTime(ctx, "t2", snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetThrottledUntil(ctx, "provider:ecobee", throttled); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SetLastState(ctx, "t1", RuntimeState{EventTime: runtime, State: model.State{Mode: "heat"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dump, err := DumpOffsets(ctx, source)
+	if err != nil {
+		t.Fatalf("Failed to dump: %v", err)
+	}
+	data, err := json.Marshal(dump)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	var decoded OffsetDump
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	sqliteStore, err := NewSQLiteOffsetStore(filepath.Join(t.TempDir(), "offsets.db"))
+	if err != nil {
+		t.Fatalf("Failed to create offset store: %v", err)
+	}
+	defer func() {
+		_ = sqliteStore.Close()
+	}()
+
+	tests := []struct {
+		name     string
+		store    OffsetStore
+		restored int
+	}{
+		{name: "bolt to sqlite keeps last state", store: sqliteStore, restored: 5},
+		{name: "bolt to bolt keeps last state", store: newTestBoltStore(t), restored: 5},
+		{name: "bolt to memory", store: NewMemoryOffsetStore(), restored: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored, err := RestoreOffsets(ctx, tt.store, &decoded)
+			if err != nil {
+				t.Fatalf("Failed to restore: %v", err)
+			}
+			if restored != tt.restored {
+				t.Errorf("Expected %d entries restored, got %d", tt.restored, restored)
+			}
+
+			roundTrip, err := DumpOffsets(ctx, tt.store)
+			if err != nil {
+				t.Fatalf("Failed to dump restored store: %v", err)
+			}
+			if got := roundTrip.Thermostats["t1"]; !got.LastRuntime.Equal(runtime) || !got.LastSnapshot.Equal(snapshot) {
+				t.Errorf("Unexpected offsets for t1: %+v", got)
+			}
+			if got := roundTrip.Thermostats["t2"]; !got.LastRuntime.IsZero() || !got.LastSnapshot.Equal(snapshot) {
+				t.Errorf("Unexpected offsets for t2: %+v", got)
+			}
+			if got := roundTrip.Throttles["provider:ecobee"]; !got.Equal(throttled) {
+				t.Errorf("Expected throttle deadline %v, got %v", throttled, got)
+			}
+		})
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		if _, err := RestoreOffsets(ctx, NewMemoryOffsetStore(), &OffsetDump{Version: 99}); err == nil {
+			t.Error("Expected an error for an unknown dump version")
+		}
+	})
+}
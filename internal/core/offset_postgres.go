@@ -0,0 +1,531 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers the "pgx" database/sql driver
+)
+
+// postgresMigrationLock is the advisory lock key held while migrating, so
+// replicas starting together do not apply the same migration twice
+const postgresMigrationLock int64 = 0x747472_0001
+
+// postgresMigrations are applied in order; a migration's version is its index
+// plus one. Never edit an applied migration, append a new one instead.
+var postgresMigrations = []string{
+	`CREATE TABLE offset_tracking (
+		thermostat_id TEXT PRIMARY KEY,
+		last_runtime_time TIMESTAMPTZ,
+		last_snapshot_time TIMESTAMPTZ,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE TABLE throttle_state (
+		scope TEXT PRIMARY KEY,
+		throttled_until TIMESTAMPTZ NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE TABLE last_state (
+		thermostat_id TEXT PRIMARY KEY,
+		event_time TIMESTAMPTZ NOT NULL,
+		state JSONB NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE TABLE written_documents (
+		id TEXT PRIMARY KEY,
+		written_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX written_documents_written_at ON written_documents (written_at);
+	CREATE TABLE emitted_transitions (
+		thermostat_id TEXT NOT NULL,
+		event_time BIGINT NOT NULL,
+		transition_id TEXT NOT NULL,
+		PRIMARY KEY (thermostat_id, event_time)
+	);
+	CREATE INDEX emitted_transitions_event_time ON emitted_transitions (event_time)`,
+}
+
+// PostgresOptions configures the connection pool of a Postgres offset store
+type PostgresOptions struct {
+	// MaxConns caps open connections; zero leaves the pool unbounded
+	MaxConns int
+}
+
+// PostgresOffsetStore implements OffsetStore, StateStore and TransitionStore
+// in a shared Postgres database, so several replicas can poll from the same
+// offsets. Offsets, throttle deadlines and last states only move forward: a
+// replica finishing a slower poll cannot rewind what another replica already
+// advanced.
+type PostgresOffsetStore struct {
+	db *sql.DB
+
+	mu         sync.Mutex
+	lastPruned time.Time
+}
+
+// NewPostgresOffsetStore connects to Postgres and applies pending schema
+// migrations
+func NewPostgresOffsetStore(ctx context.Context, dsn string, opts PostgresOptions) (*PostgresOffsetStore, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening postgres database: %w", err)
+	}
+	db.SetMaxOpenConns(opts.MaxConns)
+	db.SetMaxIdleConns(max(opts.MaxConns, 2))
+	db.SetConnMaxIdleTime(5 * time.Minute)
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+
+	store := &PostgresOffsetStore{db: db}
+	if err := store.migrate(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+
+	return store, nil
+}
+
+// migrate applies migrations newer than the recorded schema version
+func (s *PostgresOffsetStore) migrate(ctx context.Context) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+		return fmt.Errorf("acquiring migration lock: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS ttr_schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("creating migrations table: %w", err)
+	}
+
+	var version int
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM ttr_schema_migrations`).Scan(&version); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if version > len(postgresMigrations) {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", version, len(postgresMigrations))
+	}
+
+	for i := version; i < len(postgresMigrations); i++ {
+		if _, err := tx.ExecContext(ctx, postgresMigrations[i]); err != nil {
+			return fmt.Errorf("applying migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO ttr_schema_migrations (version, applied_at) VALUES ($1, now())`, i+1); err != nil {
+			return fmt.Errorf("recording migration %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing migrations: %w", err)
+	}
+	return nil
+}
+
+// getTime runs a single-timestamp query, returning zero time if no row or a
+// NULL is found
+func (s *PostgresOffsetStore) getTime(ctx context.Context, query, key string) (time.Time, error) {
+	var t sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&t)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.Time, nil
+}
+
+// advanceTime stores t for key unless a later time is already stored. The
+// read and write run under a transaction-scoped advisory lock on lockKey, so
+// concurrent replicas updating the same key are serialized.
+func (s *PostgresOffsetStore) advanceTime(ctx context.Context, lockKey, currentQuery, upsertQuery, key string, t time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, lockKey); err != nil {
+		return fmt.Errorf("acquiring lock: %w", err)
+	}
+
+	var current sql.NullTime
+	err = tx.QueryRowContext(ctx, currentQuery, key).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("reading current value: %w", err)
+	}
+	if current.Valid && current.Time.After(t) {
+		return nil // another replica is already further along
+	}
+
+	if _, err := tx.ExecContext(ctx, upsertQuery, key, t, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetLastRuntimeTime returns the last runtime timestamp for a thermostat
+func (s *PostgresOffsetStore) GetLastRuntimeTime(ctx context.Context, thermostatID string) (time.Time, error) {
+	t, err := s.getTime(ctx, `SELECT last_runtime_time FROM offset_tracking WHERE thermostat_id = $1`, thermostatID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying last runtime time: %w", err)
+	}
+	return t, nil
+}
+
+// SetLastRuntimeTime sets the last runtime timestamp for a thermostat
+func (s *PostgresOffsetStore) SetLastRuntimeTime(ctx context.Context, thermostatID string, timestamp time.Time) error {
+	err := s.advanceTime(ctx, "ttr:runtime:"+thermostatID,
+		`SELECT last_runtime_time FROM offset_tracking WHERE thermostat_id = $1`,
+		`INSERT INTO offset_tracking (thermostat_id, last_runtime_time, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (thermostat_id) DO UPDATE SET
+			last_runtime_time = excluded.last_runtime_time,
+			updated_at = excluded.updated_at`,
+		thermostatID, timestamp)
+	if err != nil {
+		return fmt.Errorf("setting last runtime time: %w", err)
+	}
+	return nil
+}
+
+// RewindRuntimeTime sets the runtime offset even if it is earlier than the stored one
+func (s *PostgresOffsetStore) RewindRuntimeTime(ctx context.Context, thermostatID string, to time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE offset_tracking SET last_runtime_time = $2, updated_at = $3 WHERE thermostat_id = $1`,
+		thermostatID, to, time.Now())
+	if err != nil {
+		return fmt.Errorf("rewinding runtime time: %w", err)
+	}
+	return nil
+}
+
+// GetLastSnapshotTime returns the last snapshot timestamp for a thermostat
+func (s *PostgresOffsetStore) GetLastSnapshotTime(ctx context.Context, thermostatID string) (time.Time, error) {
+	t, err := s.getTime(ctx, `SELECT last_snapshot_time FROM offset_tracking WHERE thermostat_id = $1`, thermostatID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying last snapshot time: %w", err)
+	}
+	return t, nil
+}
+
+// SetLastSnapshotTime sets the last snapshot timestamp for a thermostat
+func (s *PostgresOffsetStore) SetLastSnapshotTime(ctx context.Context, thermostatID string, timestamp time.Time) error {
+	err := s.advanceTime(ctx, "ttr:snapshot:"+thermostatID,
+		`SELECT last_snapshot_time FROM offset_tracking WHERE thermostat_id = $1`,
+		`INSERT INTO offset_tracking (thermostat_id, last_snapshot_time, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (thermostat_id) DO UPDATE SET
+			last_snapshot_time = excluded.last_snapshot_time,
+			updated_at = excluded.updated_at`,
+		thermostatID, timestamp)
+	if err != nil {
+		return fmt.Errorf("setting last snapshot time: %w", err)
+	}
+	return nil
+}
+
+// RewindSnapshotTime sets the snapshot offset even if it is earlier than the stored one
+func (s *PostgresOffsetStore) RewindSnapshotTime(ctx context.Context, thermostatID string, to time.Time) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE offset_tracking SET last_snapshot_time = $2, updated_at = $3 WHERE thermostat_id = $1`,
+		thermostatID, to, time.Now())
+	if err != nil {
+		return fmt.Errorf("rewinding snapshot time: %w", err)
+	}
+	return nil
+}
+
+// GetThrottledUntil returns the time before which a provider or sink must not be called
+func (s *PostgresOffsetStore) GetThrottledUntil(ctx context.Context, scope string) (time.Time, error) {
+	t, err := s.getTime(ctx, `SELECT throttled_until FROM throttle_state WHERE scope = $1`, scope)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying throttle state: %w", err)
+	}
+	return t, nil
+}
+
+// SetThrottledUntil records the time before which a provider or sink must not be called
+func (s *PostgresOffsetStore) SetThrottledUntil(ctx context.Context, scope string, until time.Time) error {
+	err := s.advanceTime(ctx, "ttr:throttle:"+scope,
+		`SELECT throttled_until FROM throttle_state WHERE scope = $1`,
+		`INSERT INTO throttle_state (scope, throttled_until, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (scope) DO UPDATE SET
+			throttled_until = excluded.throttled_until,
+			updated_at = excluded.updated_at`,
+		scope, until)
+	if err != nil {
+		return fmt.Errorf("setting throttle state: %w", err)
+	}
+	return nil
+}
+
+// ListOffsets returns every stored offset, throttle deadline and last state
+func (s *PostgresOffsetStore) ListOffsets(ctx context.Context) (*OffsetDump, error) {
+	dump := newOffsetDump()
+	dump.States = make(map[string]RuntimeState)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT thermostat_id, last_runtime_time, last_snapshot_time FROM offset_tracking`)
+	if err != nil {
+		return nil, fmt.Errorf("querying offsets: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var id string
+		var runtime, snapshot sql.NullTime
+		if err := rows.Scan(&id, &runtime, &snapshot); err != nil {
+			return nil, fmt.Errorf("scanning offsets: %w", err)
+		}
+		dump.Thermostats[id] = ThermostatOffsets{LastRuntime: runtime.Time, LastSnapshot: snapshot.Time}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading offsets: %w", err)
+	}
+
+	throttleRows, err := s.db.QueryContext(ctx, `SELECT scope, throttled_until FROM throttle_state`)
+	if err != nil {
+		return nil, fmt.Errorf("querying throttle state: %w", err)
+	}
+	defer func() {
+		_ = throttleRows.Close()
+	}()
+	for throttleRows.Next() {
+		var scope string
+		var until time.Time
+		if err := throttleRows.Scan(&scope, &until); err != nil {
+			return nil, fmt.Errorf("scanning throttle state: %w", err)
+		}
+		dump.Throttles[scope] = until
+	}
+	if err := throttleRows.Err(); err != nil {
+		return nil, fmt.Errorf("reading throttle state: %w", err)
+	}
+
+	stateRows, err := s.db.QueryContext(ctx, `SELECT thermostat_id, state FROM last_state`)
+	if err != nil {
+		return nil, fmt.Errorf("querying last state: %w", err)
+	}
+	defer func() {
+		_ = stateRows.Close()
+	}()
+	for stateRows.Next() {
+		var id string
+		var data []byte
+		if err := stateRows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("scanning last state: %w", err)
+		}
+		var state RuntimeState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return nil, fmt.Errorf("decoding last state: %w", err)
+		}
+		dump.States[id] = state
+	}
+	if err := stateRows.Err(); err != nil {
+		return nil, fmt.Errorf("reading last state: %w", err)
+	}
+
+	return dump, nil
+}
+
+// GetLastState returns a thermostat's state as of its latest runtime row, or
+// nil if none is stored
+func (s *PostgresOffsetStore) GetLastState(ctx context.Context, thermostatID string) (*RuntimeState, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT state FROM last_state WHERE thermostat_id = $1`, thermostatID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying last state: %w", err)
+	}
+
+	var state RuntimeState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("decoding last state: %w", err)
+	}
+	return &state, nil
+}
+
+// SetLastState stores a thermostat's state as of its latest runtime row,
+// unless a state from a later row is already stored
+func (s *PostgresOffsetStore) SetLastState(ctx context.Context, thermostatID string, state RuntimeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding last state: %w", err)
+	}
+
+	query := `
+		INSERT INTO last_state (thermostat_id, event_time, state, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (thermostat_id) DO UPDATE SET
+			event_time = excluded.event_time,
+			state = excluded.state,
+			updated_at = excluded.updated_at
+		WHERE last_state.event_time <= excluded.event_time`
+	if _, err := s.db.ExecContext(ctx, query, thermostatID, state.EventTime, data, time.Now()); err != nil {
+		return fmt.Errorf("setting last state: %w", err)
+	}
+	return nil
+}
+
+// WrittenDocuments returns which of the given document IDs were written to
+// every sink within the dedupe retention
+func (s *PostgresOffsetStore) WrittenDocuments(ctx context.Context, ids []string) (map[string]bool, error) {
+	written := make(map[string]bool)
+	if len(ids) == 0 {
+		return written, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id FROM written_documents WHERE written_at >= $1 AND id = ANY($2)`,
+		time.Now().Add(-dedupeRetention), ids)
+	if err != nil {
+		return nil, fmt.Errorf("querying written documents: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scanning written documents: %w", err)
+		}
+		written[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading written documents: %w", err)
+	}
+	return written, nil
+}
+
+// MarkWritten remembers document IDs written to every sink, pruning expired
+// IDs and transitions at most once per statePruneInterval
+func (s *PostgresOffsetStore) MarkWritten(ctx context.Context, ids []string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if s.shouldPrune(at) {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM written_documents WHERE written_at < $1`, at.Add(-dedupeRetention)); err != nil {
+			return fmt.Errorf("pruning written documents: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM emitted_transitions WHERE event_time < $1`, at.Add(-transitionLedgerRetention).Unix()); err != nil {
+			return fmt.Errorf("pruning emitted transitions: %w", err)
+		}
+	}
+
+	// DISTINCT, since ON CONFLICT cannot update the same row twice
+	query := `INSERT INTO written_documents (id, written_at)
+		SELECT DISTINCT id, $2::timestamptz FROM unnest($1::text[]) AS id
+		ON CONFLICT (id) DO UPDATE SET written_at = excluded.written_at`
+	if _, err := tx.ExecContext(ctx, query, ids, at); err != nil {
+		return fmt.Errorf("marking written documents: %w", err)
+	}
+	return tx.Commit()
+}
+
+// shouldPrune reports whether expired entries are due to be pruned at at.
+// Each replica prunes on its own schedule; deleting already pruned rows is
+// harmless.
+func (s *PostgresOffsetStore) shouldPrune(at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at.Sub(s.lastPruned) < statePruneInterval {
+		return false
+	}
+	s.lastPruned = at
+	return true
+}
+
+// ForgetWritten clears the written document IDs
+func (s *PostgresOffsetStore) ForgetWritten(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM written_documents`); err != nil {
+		return fmt.Errorf("clearing written documents: %w", err)
+	}
+	return nil
+}
+
+// EmittedTransitions returns the IDs of transitions already emitted for a
+// thermostat, keyed by event time in Unix seconds
+func (s *PostgresOffsetStore) EmittedTransitions(ctx context.Context, thermostatID string, eventTimes []time.Time) (map[int64]string, error) {
+	emitted := make(map[int64]string)
+	if len(eventTimes) == 0 {
+		return emitted, nil
+	}
+	unix := make([]int64, len(eventTimes))
+	for i, t := range eventTimes {
+		unix[i] = t.Unix()
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT event_time, transition_id FROM emitted_transitions WHERE thermostat_id = $1 AND event_time = ANY($2)`,
+		thermostatID, unix)
+	if err != nil {
+		return nil, fmt.Errorf("querying emitted transitions: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var eventTime int64
+		var id string
+		if err := rows.Scan(&eventTime, &id); err != nil {
+			return nil, fmt.Errorf("scanning emitted transitions: %w", err)
+		}
+		emitted[eventTime] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading emitted transitions: %w", err)
+	}
+	return emitted, nil
+}
+
+// RecordTransitions remembers emitted transition IDs by event time, keeping
+// the first ID recorded for each, including one recorded by another replica
+func (s *PostgresOffsetStore) RecordTransitions(ctx context.Context, thermostatID string, transitions map[int64]string) error {
+	if len(transitions) == 0 {
+		return nil
+	}
+	eventTimes := make([]int64, 0, len(transitions))
+	ids := make([]string, 0, len(transitions))
+	for eventTime, id := range transitions {
+		eventTimes = append(eventTimes, eventTime)
+		ids = append(ids, id)
+	}
+
+	query := `INSERT INTO emitted_transitions (thermostat_id, event_time, transition_id)
+		SELECT $1, event_time, transition_id FROM unnest($2::bigint[], $3::text[]) AS t(event_time, transition_id)
+		ON CONFLICT (thermostat_id, event_time) DO NOTHING`
+	if _, err := s.db.ExecContext(ctx, query, thermostatID, eventTimes, ids); err != nil {
+		return fmt.Errorf("recording emitted transitions: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection pool
+func (s *PostgresOffsetStore) Close() error {
+	if s.db != nil {
+		return s.db.Close()
+	}
+	return nil
+}
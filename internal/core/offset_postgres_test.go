@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// TestPostgresOffsetStore runs against the database in TTR_TEST_POSTGRES_DSN
+// and is skipped when it is unset
+func TestPostgresOffsetStore(t *testing.T) {
+	dsn := os.Getenv("TTR_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TTR_TEST_POSTGRES_DSN not set")
+	}
+
+	ctx := context.Background()
+	store, err := NewPostgresOffsetStore(ctx, dsn, PostgresOptions{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("Failed to create offset store: %v", err)
+	}
+	defer func() {
+		_ = store.Close()
+	}()
+
+	// A second replica finds the schema already migrated
+	replica, err := NewPostgresOffsetStore(ctx, dsn, PostgresOptions{MaxConns: 4})
+	if err != nil {
+		t.Fatalf("Failed to open second store: %v", err)
+	}
+	defer func() {
+		_ = replica.Close()
+	}()
+
+	thermostatID := "pg-test-" + time.Now().Format("150405.000000000")
+	later := time.Now().UTC().Truncate(time.Second)
+	earlier := later.Add(-time.Hour)
+
+	t.Run("GetLastRuntimeTime returns zero time when not set", func(t *testing.T) {
+		ts, err := store.GetLastRuntimeTime(ctx, thermostatID)
+		if err != nil || !ts.IsZero() {
+			t.Errorf("Expected zero time, got %v (error %v)", ts, err)
+		}
+	})
+
+	t.Run("offsets are shared and only move forward", func(t *testing.T) {
+		if err := store.SetLastRuntimeTime(ctx, thermostatID, later); err != nil {
+			t.Fatalf("Failed to set runtime time: %v", err)
+		}
+		if err := replica.SetLastRuntimeTime(ctx, thermostatID, earlier); err != nil {
+			t.Fatalf("Failed to set runtime time: %v", err)
+		}
+		ts, err := replica.GetLastRuntimeTime(ctx, thermostatID)
+		if err != nil || !ts.Equal(later) {
+			t.Errorf("Expected %v, got %v (error %v)", later, ts, err)
+		}
+	})
+
+	t.Run("SetLastSnapshotTime and GetLastSnapshotTime", func(t *testing.T) {
+		if err := store.SetLastSnapshotTime(ctx, thermostatID, later); err != nil {
+			t.Fatalf("Failed to set snapshot time: %v", err)
+		}
+		ts, err := store.GetLastSnapshotTime(ctx, thermostatID)
+		if err != nil || !ts.Equal(later) {
+			t.Errorf("Expected %v, got %v (error %v)", later, ts, err)
+		}
+	})
+
+	t.Run("SetThrottledUntil and GetThrottledUntil", func(t *testing.T) {
+		if err := store.SetThrottledUntil(ctx, thermostatID, later); err != nil {
+			t.Fatalf("Failed to set throttle state: %v", err)
+		}
+		ts, err := store.GetThrottledUntil(ctx, thermostatID)
+		if err != nil || !ts.Equal(later) {
+			t.Errorf("Expected %v, got %v (error %v)", later, ts, err)
+		}
+	})
+
+	t.Run("last state only moves forward", func(t *testing.T) {
+		if err := store.SetLastState(ctx, thermostatID, RuntimeState{EventTime: later, State: model.State{Mode: "heat"}}); err != nil {
+			t.Fatalf("Failed to set state: %v", err)
+		}
+		if err := replica.SetLastState(ctx, thermostatID, RuntimeState{EventTime: earlier, State: model.State{Mode: "cool"}}); err != nil {
+			t.Fatalf("Failed to set state: %v", err)
+		}
+		state, err := replica.GetLastState(ctx, thermostatID)
+		if err != nil || state == nil || state.State.Mode != "heat" || !state.EventTime.Equal(later) {
+			t.Errorf("Expected the later heat state, got %+v (error %v)", state, err)
+		}
+	})
+
+	t.Run("state store", func(t *testing.T) { testStateStore(t, store, thermostatID+":") })
+	t.Run("transition store", func(t *testing.T) { testTransitionStore(t, store, thermostatID+":") })
+}
@@ -3,22 +3,27 @@ package core
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3" // SQLite driver
 )
 
-// SQLiteOffsetStore implements OffsetStore using SQLite
+// SQLiteOffsetStore implements OffsetStore, StateStore and TransitionStore
+// using SQLite
 // This provides persistent storage of polling offsets across restarts
 type SQLiteOffsetStore struct {
 	db *sql.DB
+
+	mu         sync.Mutex
+	lastPruned time.Time
 }
 
 // NewSQLiteOffsetStore creates a new SQLite-based offset store
 // The dbPath parameter specifies the path to the SQLite database file
 func NewSQLiteOffsetStore(dbPath string) (*SQLiteOffsetStore, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(sqliteDriver, dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("opening sqlite database: %w", err)
 	}
@@ -42,6 +47,28 @@ func (s *SQLiteOffsetStore) initSchema() error {
 			updated_at TEXT NOT NULL
 		);
 		CREATE INDEX IF NOT EXISTS idx_updated_at ON offset_tracking(updated_at);
+		CREATE TABLE IF NOT EXISTS throttle_state (
+			scope TEXT PRIMARY KEY,
+			throttled_until TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS last_state (
+			thermostat_id TEXT PRIMARY KEY,
+			state TEXT NOT NULL,
+			updated_at TEXT NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS written_documents (
+			id TEXT PRIMARY KEY,
+			written_at TEXT NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_written_at ON written_documents(written_at);
+		CREATE TABLE IF NOT EXISTS emitted_transitions (
+			thermostat_id TEXT NOT NULL,
+			event_time INTEGER NOT NULL,
+			transition_id TEXT NOT NULL,
+			PRIMARY KEY (thermostat_id, event_time)
+		);
+		CREATE INDEX IF NOT EXISTS idx_emitted_event_time ON emitted_transitions(event_time);
 	`
 
 	_, err := s.db.Exec(schema)
@@ -138,6 +165,320 @@ func (s *SQLiteOffsetStore) SetLastSnapshotTime(ctx context.Context, thermostatI
 	return nil
 }
 
+// GetThrottledUntil returns the time before which a provider or sink must not be called
+func (s *SQLiteOffsetStore) GetThrottledUntil(ctx context.Context, scope string) (time.Time, error) {
+	var timeStr string
+	query := `SELECT throttled_until FROM throttle_state WHERE scope = ?`
+
+	err := s.db.QueryRowContext(ctx, query, scope).Scan(&timeStr)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil // Not throttled
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("querying throttle state: %w", err)
+	}
+
+	t, err := time.Parse(time.RFC3339, timeStr)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing timestamp: %w", err)
+	}
+
+	return t, nil
+}
+
+// SetThrottledUntil records the time before which a provider or sink must not be called
+func (s *SQLiteOffsetStore) SetThrottledUntil(ctx context.Context, scope string, until time.Time) error {
+	query := `
+		INSERT INTO throttle_state (scope, throttled_until, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(scope) DO UPDATE SET
+			throttled_until = excluded.throttled_until,
+			updated_at = excluded.updated_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query, scope, until.Format(time.RFC3339), time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("setting throttle state: %w", err)
+	}
+
+	return nil
+}
+
+// ListOffsets returns every stored offset, throttle deadline and last state
+func (s *SQLiteOffsetStore) ListOffsets(ctx context.Context) (*OffsetDump, error) {
+	dump := newOffsetDump()
+	dump.States = make(map[string]RuntimeState)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT thermostat_id, last_runtime_time, last_snapshot_time FROM offset_tracking`)
+	if err != nil {
+		return nil, fmt.Errorf("querying offsets: %w", err)
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var id string
+		var runtimeStr, snapshotStr sql.NullString
+		if err := rows.Scan(&id, &runtimeStr, &snapshotStr); err != nil {
+			return nil, fmt.Errorf("scanning offsets: %w", err)
+		}
+		var offsets ThermostatOffsets
+		if offsets.LastRuntime, err = parseOptionalTime(runtimeStr); err != nil {
+			return nil, err
+		}
+		if offsets.LastSnapshot, err = parseOptionalTime(snapshotStr); err != nil {
+			return nil, err
+		}
+		dump.Thermostats[id] = offsets
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading offsets: %w", err)
+	}
+
+	throttleRows, err := s.db.QueryContext(ctx, `SELECT scope, throttled_until FROM throttle_state`)
+	if err != nil {
+		return nil, fmt.Errorf("querying throttle state: %w", err)
+	}
+	defer func() {
+		_ = throttleRows.Close()
+	}()
+	for throttleRows.Next() {
+		var scope, untilStr string
+		if err := throttleRows.Scan(&scope, &untilStr); err != nil {
+			return nil, fmt.Errorf("scanning throttle state: %w", err)
+		}
+		until, err := time.Parse(time.RFC3339, untilStr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp: %w", err)
+		}
+		dump.Throttles[scope] = until
+	}
+	if err := throttleRows.Err(); err != nil {
+		return nil, fmt.Errorf("reading throttle state: %w", err)
+	}
+
+	stateRows, err := s.db.QueryContext(ctx, `SELECT thermostat_id, state FROM last_state`)
+	if err != nil {
+		return nil, fmt.Errorf("querying last state: %w", err)
+	}
+	defer func() {
+		_ = stateRows.Close()
+	}()
+	for stateRows.Next() {
+		var id, data string
+		if err := stateRows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("scanning last state: %w", err)
+		}
+		var state RuntimeState
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return nil, fmt.Errorf("decoding last state: %w", err)
+		}
+		dump.States[id] = state
+	}
+	if err := stateRows.Err(); err != nil {
+		return nil, fmt.Errorf("reading last state: %w", err)
+	}
+
+	return dump, nil
+}
+
+// parseOptionalTime parses an RFC 3339 column, returning zero time for NULL or empty
+func parseOptionalTime(value sql.NullString) (time.Time, error) {
+	if !value.Valid || value.String == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value.String)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing timestamp: %w", err)
+	}
+	return t, nil
+}
+
+// GetLastState returns a thermostat's state as of its latest runtime row, or
+// nil if none is stored
+func (s *SQLiteOffsetStore) GetLastState(ctx context.Context, thermostatID string) (*RuntimeState, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT state FROM last_state WHERE thermostat_id = ?`, thermostatID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying last state: %w", err)
+	}
+
+	var state RuntimeState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("decoding last state: %w", err)
+	}
+	return &state, nil
+}
+
+// SetLastState stores a thermostat's state as of its latest runtime row
+func (s *SQLiteOffsetStore) SetLastState(ctx context.Context, thermostatID string, state RuntimeState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encoding last state: %w", err)
+	}
+
+	query := `
+		INSERT INTO last_state (thermostat_id, state, updated_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(thermostat_id) DO UPDATE SET
+			state = excluded.state,
+			updated_at = excluded.updated_at
+	`
+	if _, err := s.db.ExecContext(ctx, query, thermostatID, string(data), time.Now().Format(time.RFC3339)); err != nil {
+		return fmt.Errorf("setting last state: %w", err)
+	}
+	return nil
+}
+
+// sqliteMaxParams bounds the IDs bound to one IN query
+const sqliteMaxParams = 500
+
+// WrittenDocuments returns which of the given document IDs were written to
+// every sink within the dedupe retention
+func (s *SQLiteOffsetStore) WrittenDocuments(ctx context.Context, ids []string) (map[string]bool, error) {
+	written := make(map[string]bool)
+	cutoff := time.Now().Add(-dedupeRetention).UTC().Format(time.RFC3339)
+
+	for start := 0; start < len(ids); start += sqliteMaxParams {
+		batch := ids[start:min(start+sqliteMaxParams, len(ids))]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, cutoff)
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		query := `SELECT id FROM written_documents WHERE written_at >= ? AND id IN (?` + strings.Repeat(",?", len(batch)-1) + `)`
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("querying written documents: %w", err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("scanning written documents: %w", err)
+			}
+			written[id] = true
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading written documents: %w", err)
+		}
+	}
+	return written, nil
+}
+
+// MarkWritten remembers document IDs written to every sink, pruning expired
+// IDs and transitions at most once per statePruneInterval
+func (s *SQLiteOffsetStore) MarkWritten(ctx context.Context, ids []string, at time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if s.shouldPrune(at) {
+		cutoff := at.Add(-dedupeRetention).UTC().Format(time.RFC3339)
+		if _, err := tx.ExecContext(ctx, `DELETE FROM written_documents WHERE written_at < ?`, cutoff); err != nil {
+			return fmt.Errorf("pruning written documents: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM emitted_transitions WHERE event_time < ?`, at.Add(-transitionLedgerRetention).Unix()); err != nil {
+			return fmt.Errorf("pruning emitted transitions: %w", err)
+		}
+	}
+
+	writtenAt := at.UTC().Format(time.RFC3339)
+	for _, id := range ids {
+		query := `INSERT INTO written_documents (id, written_at) VALUES (?, ?)
+			ON CONFLICT(id) DO UPDATE SET written_at = excluded.written_at`
+		if _, err := tx.ExecContext(ctx, query, id, writtenAt); err != nil {
+			return fmt.Errorf("marking written documents: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// shouldPrune reports whether expired entries are due to be pruned at at
+func (s *SQLiteOffsetStore) shouldPrune(at time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if at.Sub(s.lastPruned) < statePruneInterval {
+		return false
+	}
+	s.lastPruned = at
+	return true
+}
+
+// ForgetWritten clears the written document IDs
+func (s *SQLiteOffsetStore) ForgetWritten(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM written_documents`); err != nil {
+		return fmt.Errorf("clearing written documents: %w", err)
+	}
+	return nil
+}
+
+// EmittedTransitions returns the IDs of transitions already emitted for a
+// thermostat, keyed by event time in Unix seconds
+func (s *SQLiteOffsetStore) EmittedTransitions(ctx context.Context, thermostatID string, eventTimes []time.Time) (map[int64]string, error) {
+	emitted := make(map[int64]string)
+	for start := 0; start < len(eventTimes); start += sqliteMaxParams {
+		batch := eventTimes[start:min(start+sqliteMaxParams, len(eventTimes))]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, thermostatID)
+		for _, t := range batch {
+			args = append(args, t.Unix())
+		}
+		query := `SELECT event_time, transition_id FROM emitted_transitions
+			WHERE thermostat_id = ? AND event_time IN (?` + strings.Repeat(",?", len(batch)-1) + `)`
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return nil, fmt.Errorf("querying emitted transitions: %w", err)
+		}
+		for rows.Next() {
+			var eventTime int64
+			var id string
+			if err := rows.Scan(&eventTime, &id); err != nil {
+				_ = rows.Close()
+				return nil, fmt.Errorf("scanning emitted transitions: %w", err)
+			}
+			emitted[eventTime] = id
+		}
+		err = rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("reading emitted transitions: %w", err)
+		}
+	}
+	return emitted, nil
+}
+
+// RecordTransitions remembers emitted transition IDs by event time
+func (s *SQLiteOffsetStore) RecordTransitions(ctx context.Context, thermostatID string, transitions map[int64]string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	for eventTime, id := range transitions {
+		query := `INSERT INTO emitted_transitions (thermostat_id, event_time, transition_id) VALUES (?, ?, ?)
+			ON CONFLICT(thermostat_id, event_time) DO NOTHING`
+		if _, err := tx.ExecContext(ctx, query, thermostatID, eventTime, id); err != nil {
+			return fmt.Errorf("recording emitted transitions: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
 // Close closes the database connection
 func (s *SQLiteOffsetStore) Close() error {
 	if s.db != nil {
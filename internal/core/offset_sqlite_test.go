@@ -148,4 +148,32 @@ func TestSQLiteOffsetStore(t *testing.T) {
 			t.Errorf("Expected %v for id2, got %v", time2, retrieved2)
 		}
 	})
+
+	t.Run("SetThrottledUntil and GetThrottledUntil", func(t *testing.T) {
+		scope := "provider:ecobee"
+
+		initial, err := store.GetThrottledUntil(ctx, scope)
+		if err != nil {
+			t.Fatalf("Failed to get throttle state: %v", err)
+		}
+		if !initial.IsZero() {
+			t.Errorf("Expected zero time, got %v", initial)
+		}
+
+		until := time.Now().UTC().Add(10 * time.Minute).Truncate(time.Second)
+		if err := store.SetThrottledUntil(ctx, scope, until); err != nil {
+			t.Fatalf("Failed to set throttle state: %v", err)
+		}
+
+		retrieved, err := store.GetThrottledUntil(ctx, scope)
+		if err != nil {
+			t.Fatalf("Failed to get throttle state: %v", err)
+		}
+		if !retrieved.Equal(until) {
+			t.Errorf("Expected %v, got %v", until, retrieved)
+		}
+	})
+
+	t.Run("state store", func(t *testing.T) { testStateStore(t, store, "") })
+	t.Run("transition store", func(t *testing.T) { testTransitionStore(t, store, "") })
 }
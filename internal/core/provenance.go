@@ -0,0 +1,74 @@
+package core
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// provenance is the ingest metadata stamped on written documents
+type provenance struct {
+	collectorVersion string
+	instance         string
+	cycleID          string
+	cycleStart       time.Time
+	lastFetch        time.Time
+}
+
+// WithProvenance stamps every written document with an ingest sub-object
+// naming the collector version, the instance, the scheduler cycle and when
+// its data was fetched, so records can be traced back to the run that wrote
+// them when several instances feed the same sinks
+func WithProvenance(collectorVersion, instance string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.provenance = &provenance{collectorVersion: collectorVersion, instance: instance}
+	}
+}
+
+// startCycle records the start of a backfill or polling cycle and gives
+// documents written during it a new cycle ID
+func (s *Scheduler) startCycle(phase string, now time.Time) {
+	s.metrics.RecordCycleStart(phase, now)
+	s.startIngestCycle(phase, now)
+}
+
+// startIngestCycle gives documents written from now on a new cycle ID, named
+// after the kind of cycle and its start time
+func (s *Scheduler) startIngestCycle(kind string, now time.Time) {
+	if s.provenance == nil {
+		return
+	}
+	s.provenance.cycleID = kind + "-" + now.UTC().Format("20060102T150405Z")
+	s.provenance.cycleStart = now
+	s.provenance.lastFetch = time.Time{}
+}
+
+// recordFetch notes that provider data was requested at
+func (s *Scheduler) recordFetch(at time.Time) {
+	if s.provenance != nil {
+		s.provenance.lastFetch = at
+	}
+}
+
+// stampIngest sets the ingest metadata of documents that carry it. The fetch
+// time is the cycle's last provider request, or the cycle start for documents
+// written before any request, such as imports.
+func (s *Scheduler) stampIngest(docs []model.Doc) {
+	if s.provenance == nil {
+		return
+	}
+	ingest := model.Ingest{
+		CollectorVersion: s.provenance.collectorVersion,
+		Instance:         s.provenance.instance,
+		CycleID:          s.provenance.cycleID,
+		FetchedAt:        s.provenance.lastFetch,
+	}
+	if ingest.FetchedAt.IsZero() {
+		ingest.FetchedAt = s.provenance.cycleStart
+	}
+	for _, doc := range docs {
+		if stamper, ok := doc.Body.(model.IngestStamper); ok {
+			stamper.SetIngest(ingest)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestStampIngest(t *testing.T) {
+	ctx := testContext(t)
+	sink := &recordingSink{mockSink: mockSink{name: "recording"}}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, sink, NewMemoryOffsetStore(), WithProvenance("1.2.3", "host-a"))
+	cycleStart := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	fetched := cycleStart.Add(30 * time.Second)
+
+	tests := []struct {
+		name          string
+		fetch         time.Time
+		expectFetched time.Time
+	}{
+		{name: "before any request the cycle start is used", expectFetched: cycleStart},
+		{name: "the last provider request is used", fetch: fetched, expectFetched: fetched},
+	}
+	scheduler.startCycle(PhasePolling, cycleStart)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.fetch.IsZero() {
+				scheduler.recordFetch(tt.fetch)
+			}
+			runtime := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1"}
+			if err := scheduler.writeToAllSinks(ctx, []model.Doc{{ID: "a", Type: "runtime_5m", Body: runtime}}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := model.Ingest{
+				CollectorVersion: "1.2.3",
+				Instance:         "host-a",
+				CycleID:          "polling-20250110T120000Z",
+				FetchedAt:        tt.expectFetched,
+			}
+			if runtime.Ingest == nil || *runtime.Ingest != expected {
+				t.Errorf("Expected ingest %+v, got %+v", expected, runtime.Ingest)
+			}
+		})
+	}
+
+	t.Run("a new cycle gets a new ID", func(t *testing.T) {
+		next := cycleStart.Add(5 * time.Minute)
+		scheduler.startCycle(PhasePolling, next)
+		alert := &model.Alert{Type: "alert", ThermostatID: "t1"}
+		if err := scheduler.writeToAllSinks(ctx, []model.Doc{{ID: "b", Type: "alert", Body: alert}}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if alert.Ingest == nil || alert.Ingest.CycleID != "polling-20250110T120500Z" || !alert.Ingest.FetchedAt.Equal(next) {
+			t.Errorf("Expected the new cycle's ingest metadata, got %+v", alert.Ingest)
+		}
+	})
+
+	t.Run("imports are their own cycle", func(t *testing.T) {
+		thermostat := model.ThermostatRef{Provider: "nest", ID: "nest-1", Name: "Hallway"}
+		rows := []model.RuntimeRow{{ThermostatRef: thermostat, EventTime: cycleStart, Mode: "heat", AvgTempC: floatPtr(20)}}
+		sink.docs = nil
+		if _, err := scheduler.ImportRuntime(ctx, "nest", thermostat, rows); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		runtime, ok := sink.docs[0].Body.(*model.Runtime5m)
+		if !ok || runtime.Ingest == nil || !strings.HasPrefix(runtime.Ingest.CycleID, "import-") {
+			t.Errorf("Expected an import cycle ID, got %+v", sink.docs[0].Body)
+		}
+	})
+}
+
+func TestStampIngestDisabled(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &recordingSink{mockSink: mockSink{name: "recording"}}, NewMemoryOffsetStore())
+	scheduler.startCycle(PhasePolling, time.Now())
+
+	runtime := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1"}
+	if err := scheduler.writeToAllSinks(testContext(t), []model.Doc{{ID: "a", Type: "runtime_5m", Body: runtime}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if runtime.Ingest != nil {
+		t.Errorf("Expected no ingest metadata without provenance, got %+v", runtime.Ingest)
+	}
+}
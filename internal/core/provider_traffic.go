@@ -0,0 +1,69 @@
+package core
+
+import (
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// providerTraffic counts what a provider's responses yielded, for measuring
+// the effect of bulk requests and caching on API efficiency
+type providerTraffic struct {
+	runtimeRowsParsed   int64
+	runtimeRowsRejected int64
+	snapshotsFetched    int64
+}
+
+// TrackProviderTraffic reports the bytes a provider downloads when it counts
+// them. Providers without model.TrafficReporter are ignored.
+func (m *MetricsCollector) TrackProviderTraffic(provider model.Provider) {
+	reporter, ok := provider.(model.TrafficReporter)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.providerBytes[provider.Info().Name] = reporter
+}
+
+// RecordRuntimeRows records runtime rows a provider returned and how many of
+// them failed normalization
+func (m *MetricsCollector) RecordRuntimeRows(providerName string, parsed, rejected int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	traffic := m.traffic(providerName)
+	traffic.runtimeRowsParsed += int64(parsed)
+	traffic.runtimeRowsRejected += int64(rejected)
+}
+
+// RecordSnapshotFetched records a snapshot requested from a provider rather
+// than served from the snapshot cache
+func (m *MetricsCollector) RecordSnapshotFetched(providerName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.traffic(providerName).snapshotsFetched++
+}
+
+// traffic returns the traffic counts for a provider, creating them if
+// needed. Callers must hold m.mu.
+func (m *MetricsCollector) traffic(providerName string) *providerTraffic {
+	traffic, ok := m.providerTraffic[providerName]
+	if !ok {
+		traffic = &providerTraffic{}
+		m.providerTraffic[providerName] = traffic
+	}
+	return traffic
+}
+
+// addTraffic fills in a provider's traffic counts. Callers must hold m.mu.
+func (m *MetricsCollector) addTraffic(name string, metrics *ProviderMetrics) {
+	if reporter, ok := m.providerBytes[name]; ok {
+		metrics.BytesFetched = reporter.BytesFetched()
+	}
+	if traffic, ok := m.providerTraffic[name]; ok {
+		metrics.RuntimeRowsParsed = traffic.runtimeRowsParsed
+		metrics.RuntimeRowsRejected = traffic.runtimeRowsRejected
+		metrics.SnapshotsFetched = traffic.snapshotsFetched
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// trafficProvider reports a fixed download count
+type trafficProvider struct {
+	mockProvider
+}
+
+func (p *trafficProvider) BytesFetched() int64 {
+	return 2048
+}
+
+func TestProviderTrafficMetrics(t *testing.T) {
+	ctx := testContext(t)
+	provider := &trafficProvider{mockProvider: mockProvider{name: "ecobee"}}
+	scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore())
+	scheduler.metrics.TrackProviderTraffic(provider)
+	thermostat := model.ThermostatRef{ID: "therm-1", Provider: "ecobee"}
+
+	temp := 21.5
+	start := time.Now().Add(-time.Hour).Truncate(5 * time.Minute)
+	rows := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: start, Mode: "heat", AvgTempC: &temp},
+		{ThermostatRef: thermostat, EventTime: start.Add(5 * time.Minute), Mode: "heat", AvgTempC: &temp, Unit: "rankine"},
+		{ThermostatRef: thermostat, EventTime: start.Add(10 * time.Minute), Mode: "heat", AvgTempC: &temp},
+	}
+	if err := scheduler.processRuntime(ctx, provider, thermostat, rows); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := scheduler.getSnapshot(ctx, provider, thermostat, "rev-1", time.Now()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	metrics := scheduler.metrics.GetMetrics().Providers["ecobee"]
+	if metrics.BytesFetched != 2048 || metrics.RuntimeRowsParsed != 3 || metrics.RuntimeRowsRejected != 1 || metrics.SnapshotsFetched != 1 {
+		t.Errorf("Expected 2048 bytes, 3 rows parsed, 1 rejected and 1 snapshot, got %+v", metrics)
+	}
+
+	var out strings.Builder
+	scheduler.metrics.writePrometheus(&out)
+	if !strings.Contains(out.String(), `ttr_provider_bytes_fetched_total{provider="ecobee"} 2048`) {
+		t.Errorf("Expected bytes fetched in Prometheus output, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ReconciliationReport compares, over one window, the runtime rows providers
+// returned with the runtime documents built from them, and the documents
+// handed to each sink with what the sink said became of them. Anything
+// unaccounted for was lost without an error being reported.
+type ReconciliationReport struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	// RowsFetched are settled runtime rows due to be written; each becomes
+	// a runtime document, fails normalization, or was already written
+	RowsFetched      int64 `json:"rows_fetched"`
+	RowsRejected     int64 `json:"rows_rejected"`
+	RowsSkipped      int64 `json:"rows_skipped"`
+	RuntimeDocuments int64 `json:"runtime_documents"`
+	UnaccountedRows  int64 `json:"unaccounted_rows"`
+
+	Sinks map[string]SinkReconciliation `json:"sinks,omitempty"`
+}
+
+// SinkReconciliation accounts for the documents handed to one sink. Failed
+// covers reported errors and whole failed writes; Dropped covers documents a
+// write pipeline filtered out.
+type SinkReconciliation struct {
+	Offered      int64 `json:"offered"`
+	Acknowledged int64 `json:"acknowledged"`
+	Failed       int64 `json:"failed"`
+	Dropped      int64 `json:"dropped"`
+	Unaccounted  int64 `json:"unaccounted"`
+}
+
+// Discrepancies describes everything unaccounted for in the report, in a
+// stable order; it is empty when the counts reconcile
+func (r ReconciliationReport) Discrepancies() []string {
+	var discrepancies []string
+	if r.UnaccountedRows != 0 {
+		discrepancies = append(discrepancies, fmt.Sprintf("%d runtime rows fetched but not turned into documents", r.UnaccountedRows))
+	}
+	for _, name := range sortedKeys(r.Sinks) {
+		if unaccounted := r.Sinks[name].Unaccounted; unaccounted != 0 {
+			discrepancies = append(discrepancies, fmt.Sprintf("sink %s: %d documents neither acknowledged nor reported failed", name, unaccounted))
+		}
+	}
+	return discrepancies
+}
+
+// reconciler accumulates the counts of the current window
+type reconciler struct {
+	window time.Duration
+	report ReconciliationReport
+}
+
+// WithReconciliation compares fetched rows with written documents over
+// windows of the given length, recording each report in metrics and logging
+// and notifying discrepancies. A zero window disables it.
+func WithReconciliation(window time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if window <= 0 {
+			return
+		}
+		s.reconcile = &reconciler{window: window}
+		s.reconcile.reset(time.Now())
+	}
+}
+
+// reset starts a new window at now
+func (r *reconciler) reset(now time.Time) {
+	r.report = ReconciliationReport{WindowStart: now, Sinks: make(map[string]SinkReconciliation)}
+}
+
+// reconcileRows records settled runtime rows due to be written
+func (s *Scheduler) reconcileRows(fetched int) {
+	if s.reconcile != nil {
+		s.reconcile.report.RowsFetched += int64(fetched)
+	}
+}
+
+// reconcileRejected records a runtime row that failed normalization
+func (s *Scheduler) reconcileRejected() {
+	if s.reconcile != nil {
+		s.reconcile.report.RowsRejected++
+	}
+}
+
+// reconcileRuntimeDocs records the runtime documents handed to sinks and
+// those skipped because every sink already accepted them
+func (s *Scheduler) reconcileRuntimeDocs(written, skipped []model.Doc) {
+	if s.reconcile == nil {
+		return
+	}
+	isRuntime := func(doc model.Doc) bool { return doc.Type == "runtime_5m" }
+	s.reconcile.report.RuntimeDocuments += int64(countFunc(written, isRuntime))
+	s.reconcile.report.RowsSkipped += int64(countFunc(skipped, isRuntime))
+}
+
+// reconcileSinkWrite records what a sink said became of a batch. A write
+// that failed outright fails the whole batch.
+func (s *Scheduler) reconcileSinkWrite(sinkName string, offered int, result model.WriteResult, err error) {
+	if s.reconcile == nil {
+		return
+	}
+	counts := s.reconcile.report.Sinks[sinkName]
+	counts.Offered += int64(offered)
+	if err != nil {
+		counts.Failed += int64(offered)
+	} else {
+		counts.Acknowledged += int64(result.SuccessCount)
+		counts.Failed += int64(result.ErrorCount)
+		counts.Dropped += int64(result.DroppedCount)
+	}
+	s.reconcile.report.Sinks[sinkName] = counts
+}
+
+// checkReconciliation closes the window once it has run its length,
+// recording the report and logging and notifying any discrepancies
+func (s *Scheduler) checkReconciliation(now time.Time) {
+	if s.reconcile == nil || now.Sub(s.reconcile.report.WindowStart) < s.reconcile.window {
+		return
+	}
+	report := s.reconcile.report
+	s.reconcile.reset(now)
+
+	report.WindowEnd = now
+	report.UnaccountedRows = report.RowsFetched - report.RowsRejected - report.RowsSkipped - report.RuntimeDocuments
+	for name, counts := range report.Sinks {
+		counts.Unaccounted = counts.Offered - counts.Acknowledged - counts.Failed - counts.Dropped
+		report.Sinks[name] = counts
+	}
+	s.metrics.RecordReconciliation(report)
+
+	discrepancies := report.Discrepancies()
+	if len(discrepancies) == 0 {
+		s.logger.Info("Reconciled fetched rows with written documents",
+			"window_start", report.WindowStart,
+			"rows_fetched", report.RowsFetched,
+			"runtime_documents", report.RuntimeDocuments)
+		return
+	}
+	s.logger.Error("Fetched rows and written documents do not reconcile",
+		"window_start", report.WindowStart,
+		"discrepancies", discrepancies)
+	s.notify(notify.Message{
+		Key:      "reconciliation",
+		Title:    "Documents lost without an error",
+		Body:     fmt.Sprintf("Since %s: %s", report.WindowStart.Format(time.RFC3339), strings.Join(discrepancies, "; ")),
+		Priority: notify.PriorityHigh,
+	})
+}
+
+// RecordReconciliation records the latest reconciliation report
+func (m *MetricsCollector) RecordReconciliation(report ReconciliationReport) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reconciliation = &report
+	if len(report.Discrepancies()) > 0 {
+		m.reconciliationDiscrepancies++
+	}
+}
+
+// countFunc counts the elements of s satisfying f
+func countFunc[T any](s []T, f func(T) bool) int {
+	count := 0
+	for _, v := range s {
+		if f(v) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/pipeline"
+)
+
+// lossySink acknowledges one document fewer than it is given without
+// reporting an error
+type lossySink struct {
+	mockSink
+}
+
+func (s *lossySink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	return model.WriteResult{SuccessCount: len(docs) - 1}, nil
+}
+
+func TestReconciliationReportsSilentLoss(t *testing.T) {
+	ctx := testContext(t)
+	notifier := make(channelNotifier, 10)
+	provider := &mockProvider{name: "ecobee"}
+	scheduler := newTestScheduler(provider, &lossySink{mockSink: mockSink{name: "es"}}, NewMemoryOffsetStore(),
+		WithNotifier(notifier, time.Hour), WithReconciliation(time.Hour))
+	thermostat := model.ThermostatRef{ID: "therm-1", Provider: "ecobee"}
+
+	temp := 21.5
+	start := time.Now().Add(-time.Hour).Truncate(5 * time.Minute)
+	rows := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: start, Mode: "heat", AvgTempC: &temp},
+		{ThermostatRef: thermostat, EventTime: start.Add(5 * time.Minute), Mode: "heat", AvgTempC: &temp, Unit: "rankine"},
+		{ThermostatRef: thermostat, EventTime: start.Add(10 * time.Minute), Mode: "heat", AvgTempC: &temp},
+	}
+	if err := scheduler.processRuntime(ctx, provider, thermostat, rows); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The window has not run its length yet
+	scheduler.checkReconciliation(time.Now())
+	if report := scheduler.metrics.GetMetrics().Reconciliation; report != nil {
+		t.Fatalf("Expected no report before the window closes, got %+v", report)
+	}
+
+	scheduler.checkReconciliation(time.Now().Add(time.Hour))
+	metrics := scheduler.metrics.GetMetrics()
+	report := metrics.Reconciliation
+	if report == nil {
+		t.Fatal("Expected a reconciliation report")
+	}
+	if report.RowsFetched != 3 || report.RowsRejected != 1 || report.RuntimeDocuments != 2 || report.UnaccountedRows != 0 {
+		t.Errorf("Expected 3 rows fetched, 1 rejected and 2 documents, got %+v", report)
+	}
+	if sink := report.Sinks["es"]; sink.Offered != 2 || sink.Acknowledged != 1 || sink.Unaccounted != 1 {
+		t.Errorf("Expected 1 of 2 documents unaccounted for, got %+v", sink)
+	}
+	if metrics.ReconciliationDiscrepancies != 1 {
+		t.Errorf("Expected 1 discrepancy, got %d", metrics.ReconciliationDiscrepancies)
+	}
+
+	messages := expectNotifications(t, notifier, 1)
+	if messages[0].Key != "reconciliation" || !strings.Contains(messages[0].Body, "sink es: 1 documents") {
+		t.Errorf("Unexpected notification %+v", messages[0])
+	}
+}
+
+func TestReconciliationCountsFailuresAndDrops(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "es", shouldFail: true}, NewMemoryOffsetStore(),
+		WithReconciliation(time.Hour))
+	scheduler.writeBatch(testContext(t), []model.Doc{{ID: "d1", Type: "device_snapshot", Body: map[string]any{}}})
+	scheduler.reconcileSinkWrite("pipeline", 3, model.WriteResult{SuccessCount: 1, ErrorCount: 1, DroppedCount: 1}, nil)
+
+	scheduler.checkReconciliation(time.Now().Add(time.Hour))
+	report := scheduler.metrics.GetMetrics().Reconciliation
+	if report == nil || len(report.Discrepancies()) != 0 {
+		t.Fatalf("Expected reported failures and drops to reconcile, got %+v", report)
+	}
+	if sink := report.Sinks["es"]; sink.Failed != 1 {
+		t.Errorf("Expected the failed write to count as failed, got %+v", sink)
+	}
+}
+
+func TestReconciliationCountsRoutedDocuments(t *testing.T) {
+	notifier := make(channelNotifier, 10)
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, pipeline.NewRouter(&mockSink{name: "es"}, nil), NewMemoryOffsetStore(),
+		WithNotifier(notifier, time.Hour), WithReconciliation(time.Hour))
+	scheduler.writeBatch(testContext(t), []model.Doc{{ID: "d1", Type: "runtime_live", Body: map[string]any{}}})
+	scheduler.writeBatch(testContext(t), []model.Doc{
+		{ID: "d2", Type: "device_snapshot", Body: map[string]any{}},
+		{ID: "d3", Type: "runtime_live", Body: map[string]any{}},
+	})
+
+	scheduler.checkReconciliation(time.Now().Add(time.Hour))
+	report := scheduler.metrics.GetMetrics().Reconciliation
+	if report == nil || len(report.Discrepancies()) != 0 {
+		t.Fatalf("Expected documents the sink does not route to reconcile, got %+v", report)
+	}
+	if sink := report.Sinks["es"]; sink.Offered != 3 || sink.Acknowledged != 1 || sink.Dropped != 2 {
+		t.Errorf("Expected 1 document acknowledged and 2 dropped, got %+v", sink)
+	}
+	expectNotifications(t, notifier, 0)
+}
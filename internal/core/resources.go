@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// resourceRisingWindows is how many windows in a row a resource's lowest
+// count must rise before it is reported as growing
+const resourceRisingWindows = 3
+
+// ResourceUsage is the latest sample of the process resources that grow
+// without bound when goroutines, files or connections leak
+type ResourceUsage struct {
+	SampledAt  time.Time `json:"sampled_at"`
+	Goroutines int       `json:"goroutines"`
+	// OpenFDs is -1 where the platform does not list open descriptors
+	OpenFDs int `json:"open_fds"`
+	// SinkConnections counts open connections for sinks that report them
+	SinkConnections map[string]int `json:"sink_connections,omitempty"`
+	// Growing names the resources currently reported as growing
+	Growing []string `json:"growing,omitempty"`
+}
+
+// resourceMonitor samples resources after each polling cycle, a quiet point
+// where writes have finished. Counts that churn normally fall back to the
+// same floor; a leak raises the lowest count of every window.
+type resourceMonitor struct {
+	windowCycles int
+	samples      int
+	floors       map[string]int   // lowest count in the current window
+	history      map[string][]int // floors of recent completed windows, oldest first
+	growing      map[string]bool
+}
+
+// WithResourceMonitor samples goroutines, open file descriptors and sink
+// connections after every polling cycle, records them in metrics, and warns
+// when a count's lowest value rises over several windows of windowCycles
+// cycles in a row. A zero window disables it.
+func WithResourceMonitor(windowCycles int) SchedulerOption {
+	return func(s *Scheduler) {
+		if windowCycles <= 0 {
+			return
+		}
+		s.resources = &resourceMonitor{
+			windowCycles: windowCycles,
+			floors:       make(map[string]int),
+			history:      make(map[string][]int),
+			growing:      make(map[string]bool),
+		}
+	}
+}
+
+// checkResources samples resources and reports counts that keep growing
+func (s *Scheduler) checkResources(now time.Time) {
+	if s.resources == nil {
+		return
+	}
+
+	usage := ResourceUsage{
+		SampledAt:  now,
+		Goroutines: runtime.NumGoroutine(),
+		OpenFDs:    openFDs(),
+	}
+	counts := map[string]int{"goroutines": usage.Goroutines}
+	if usage.OpenFDs >= 0 {
+		counts["open_fds"] = usage.OpenFDs
+	}
+	for _, sink := range s.sinks {
+		name := sink.Info().Name
+		for {
+			wrapped, ok := sink.(wrappedSink)
+			if !ok {
+				break
+			}
+			sink = wrapped.Unwrap()
+		}
+		reporter, ok := sink.(model.ConnectionReporter)
+		if !ok {
+			continue
+		}
+		if usage.SinkConnections == nil {
+			usage.SinkConnections = make(map[string]int)
+		}
+		usage.SinkConnections[name] = reporter.OpenConnections()
+		counts["sink_connections:"+name] = usage.SinkConnections[name]
+	}
+
+	for _, resource := range s.resources.observe(counts) {
+		history := s.resources.history[resource]
+		s.logger.Warn("Resource count keeps growing, possible leak",
+			"resource", resource,
+			"current", counts[resource],
+			"window_floors", history)
+		s.metrics.RecordResourceGrowth(resource)
+	}
+	usage.Growing = s.resources.growingResources()
+	s.metrics.RecordResourceUsage(usage)
+}
+
+// observe adds a sample and, when it completes a window, returns the
+// resources that newly started growing
+func (m *resourceMonitor) observe(counts map[string]int) []string {
+	for resource, count := range counts {
+		if floor, ok := m.floors[resource]; !ok || count < floor {
+			m.floors[resource] = count
+		}
+	}
+	m.samples++
+	if m.samples < m.windowCycles {
+		return nil
+	}
+
+	var started []string
+	for _, resource := range sortedKeys(m.floors) {
+		history := append(m.history[resource], m.floors[resource])
+		if len(history) > resourceRisingWindows+1 {
+			history = history[len(history)-resourceRisingWindows-1:]
+		}
+		m.history[resource] = history
+
+		rising := len(history) == resourceRisingWindows+1
+		for i := 1; rising && i < len(history); i++ {
+			rising = history[i] > history[i-1]
+		}
+		if rising && !m.growing[resource] {
+			started = append(started, resource)
+		}
+		m.growing[resource] = rising
+	}
+	m.samples = 0
+	clear(m.floors)
+	return started
+}
+
+// growingResources returns the resources currently growing, sorted
+func (m *resourceMonitor) growingResources() []string {
+	var growing []string
+	for resource, rising := range m.growing {
+		if rising {
+			growing = append(growing, resource)
+		}
+	}
+	slices.Sort(growing)
+	return growing
+}
+
+// openFDs counts the process's open file descriptors, or returns -1 where
+// /proc/self/fd is not available
+func openFDs() int {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return -1
+	}
+	// The directory being read holds a descriptor of its own
+	return len(entries) - 1
+}
+
+// RecordResourceUsage records the latest resource sample
+func (m *MetricsCollector) RecordResourceUsage(usage ResourceUsage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resources = &usage
+}
+
+// RecordResourceGrowth counts a resource being reported as growing
+func (m *MetricsCollector) RecordResourceGrowth(resource string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.resourceGrowth[resource]++
+}
+
+// resourceLabels splits a resource key into its Prometheus name suffix and
+// sink label, e.g. "sink_connections:elasticsearch"
+func resourceLabels(resource string) string {
+	kind, sink, ok := strings.Cut(resource, ":")
+	if !ok {
+		return fmt.Sprintf("resource=\"%s\"", escapeLabel(kind))
+	}
+	return fmt.Sprintf("resource=\"%s\",sink=\"%s\"", escapeLabel(kind), escapeLabel(sink))
+}
@@ -0,0 +1,70 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// connectionSink reports a fixed number of open connections
+type connectionSink struct {
+	mockSink
+	open int
+}
+
+func (s *connectionSink) OpenConnections() int { return s.open }
+
+func TestResourceMonitorReportsRisingFloors(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithResourceMonitor(2))
+	monitor := scheduler.resources
+
+	// goroutines churn within each window but fall back to the same floor;
+	// connections never drop below a floor that rises every window
+	windows := [][2]map[string]int{
+		{{"goroutines": 10, "conns": 1}, {"goroutines": 25, "conns": 3}},
+		{{"goroutines": 30, "conns": 2}, {"goroutines": 10, "conns": 4}},
+		{{"goroutines": 10, "conns": 3}, {"goroutines": 18, "conns": 5}},
+		{{"goroutines": 12, "conns": 4}, {"goroutines": 10, "conns": 6}},
+		{{"goroutines": 10, "conns": 5}, {"goroutines": 11, "conns": 7}},
+	}
+
+	var started [][]string
+	for _, window := range windows {
+		if got := monitor.observe(window[0]); got != nil {
+			t.Fatalf("Expected no report mid-window, got %v", got)
+		}
+		started = append(started, monitor.observe(window[1]))
+	}
+
+	expected := [][]string{nil, nil, nil, {"conns"}, nil}
+	if !reflect.DeepEqual(started, expected) {
+		t.Errorf("Expected growth reports %v, got %v", expected, started)
+	}
+	if growing := monitor.growingResources(); !reflect.DeepEqual(growing, []string{"conns"}) {
+		t.Errorf("Expected conns still growing, got %v", growing)
+	}
+}
+
+func TestCheckResourcesRecordsSinkConnections(t *testing.T) {
+	sink := &connectionSink{mockSink: mockSink{name: "elasticsearch"}, open: 3}
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, sink, NewMemoryOffsetStore(), WithResourceMonitor(1))
+
+	for range resourceRisingWindows + 1 {
+		scheduler.checkResources(time.Now())
+		sink.open++
+	}
+
+	metrics := scheduler.metrics.GetMetrics()
+	if metrics.Resources == nil {
+		t.Fatal("Expected resource usage in metrics")
+	}
+	if got := metrics.Resources.SinkConnections["elasticsearch"]; got != 6 {
+		t.Errorf("Expected 6 open connections, got %d", got)
+	}
+	if metrics.Resources.Goroutines <= 0 {
+		t.Errorf("Expected a goroutine count, got %d", metrics.Resources.Goroutines)
+	}
+	if got := metrics.ResourceGrowthWarnings["sink_connections:elasticsearch"]; got != 1 {
+		t.Errorf("Expected one growth warning for the sink, got %d", got)
+	}
+}
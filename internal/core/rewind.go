@@ -0,0 +1,187 @@
+package core
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrNothingToRewind is returned when a thermostat has no offset later than
+// the rewind target
+var ErrNothingToRewind = errors.New("no offset later than the rewind target")
+
+// OffsetRewinder is implemented by offset stores whose setters never move an
+// offset backwards, such as the postgres store
+type OffsetRewinder interface {
+	// RewindRuntimeTime sets the runtime offset even if it is earlier than the stored one
+	RewindRuntimeTime(ctx context.Context, thermostatID string, to time.Time) error
+
+	// RewindSnapshotTime sets the snapshot offset even if it is earlier than the stored one
+	RewindSnapshotTime(ctx context.Context, thermostatID string, to time.Time) error
+}
+
+// OffsetChange is one offset moved by a rewind
+type OffsetChange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// RewindResult reports which of a thermostat's offsets a rewind moved
+type RewindResult struct {
+	ThermostatID string        `json:"thermostat_id"`
+	Runtime      *OffsetChange `json:"runtime,omitempty"`
+	Snapshot     *OffsetChange `json:"snapshot,omitempty"`
+}
+
+// RewindOffsets moves a thermostat's runtime and snapshot offsets back to to,
+// so the next poll fetches everything since then again. Offsets already at or
+// before to are left alone. Deterministic document IDs make the rewrite
+// idempotent; a store's dedupe cache is cleared so the rows are not skipped.
+func RewindOffsets(ctx context.Context, store OffsetStore, thermostatID string, to time.Time) (*RewindResult, error) {
+	result := &RewindResult{ThermostatID: thermostatID}
+	rewinder, _ := store.(OffsetRewinder)
+
+	lastRuntime, err := store.GetLastRuntimeTime(ctx, thermostatID)
+	if err != nil {
+		return nil, err
+	}
+	if lastRuntime.After(to) {
+		if rewinder != nil {
+			err = rewinder.RewindRuntimeTime(ctx, thermostatID, to)
+		} else {
+			err = store.SetLastRuntimeTime(ctx, thermostatID, to)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Runtime = &OffsetChange{From: lastRuntime, To: to}
+	}
+
+	lastSnapshot, err := store.GetLastSnapshotTime(ctx, thermostatID)
+	if err != nil {
+		return nil, err
+	}
+	if lastSnapshot.After(to) {
+		if rewinder != nil {
+			err = rewinder.RewindSnapshotTime(ctx, thermostatID, to)
+		} else {
+			err = store.SetLastSnapshotTime(ctx, thermostatID, to)
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Snapshot = &OffsetChange{From: lastSnapshot, To: to}
+	}
+
+	if result.Runtime == nil && result.Snapshot == nil {
+		return nil, fmt.Errorf("thermostat %s: %w", thermostatID, ErrNothingToRewind)
+	}
+
+	if stateStore, ok := store.(StateStore); ok && result.Runtime != nil {
+		if err := stateStore.ForgetWritten(ctx); err != nil {
+			return nil, fmt.Errorf("clearing written documents: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// rewindRequest asks the polling loop to rewind offsets between cycles
+type rewindRequest struct {
+	thermostatID string
+	to           time.Time
+	reply        chan rewindReply
+}
+
+type rewindReply struct {
+	result *RewindResult
+	err    error
+}
+
+// Rewind rewinds a thermostat's offsets from within the polling loop, so a
+// poll in progress cannot overwrite the rewind with a later offset. It waits
+// for the current cycle, or the initial backfill, to finish.
+func (s *Scheduler) Rewind(ctx context.Context, thermostatID string, to time.Time) (*RewindResult, error) {
+	request := rewindRequest{thermostatID: thermostatID, to: to, reply: make(chan rewindReply, 1)}
+	select {
+	case s.rewinds <- request:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	select {
+	case reply := <-request.reply:
+		return reply.result, reply.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// handleRewind performs a rewind request for the polling loop
+func (s *Scheduler) handleRewind(ctx context.Context, request rewindRequest) {
+	result, err := RewindOffsets(ctx, s.offsetStore, request.thermostatID, request.to)
+	if err == nil {
+		s.logger.Info("Rewound offsets",
+			"thermostat", request.thermostatID,
+			"to", request.to,
+			"runtime", result.Runtime != nil,
+			"snapshot", result.Snapshot != nil)
+	}
+	request.reply <- rewindReply{result: result, err: err}
+}
+
+// rewindBody is the JSON body of a rewind request
+type rewindBody struct {
+	Thermostat string    `json:"thermostat"`
+	To         time.Time `json:"to"`
+}
+
+// ServeRewind provides an HTTP handler that rewinds offsets. Callers must send
+// token as a bearer token; requests are answered with the RewindResult.
+func (s *Scheduler) ServeRewind(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !bearerAuthorized(r, token) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var body rewindBody
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&body); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if body.Thermostat == "" || body.To.IsZero() {
+			http.Error(w, "thermostat and to are required", http.StatusBadRequest)
+			return
+		}
+
+		result, err := s.Rewind(r.Context(), body.Thermostat, body.To)
+		switch {
+		case errors.Is(err, ErrNothingToRewind):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			s.logger.Error("Failed to rewind offsets", "thermostat", body.Thermostat, "error", err)
+			http.Error(w, "rewind failed", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
+
+// bearerAuthorized reports whether a request carries token as its bearer token
+func bearerAuthorized(r *http.Request, token string) bool {
+	presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRewindOffsets(t *testing.T) {
+	ctx := context.Background()
+	to := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	later := to.Add(48 * time.Hour)
+
+	t.Run("rewinds offsets after the target", func(t *testing.T) {
+		store := NewMemoryOffsetStore()
+		_ = store.SetLastRuntimeTime(ctx, "t1", later)
+		_ = store.SetLastSnapshotTime(ctx, "t1", to.Add(-time.Hour))
+
+		result, err := RewindOffsets(ctx, store, "t1", to)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.Runtime == nil || !result.Runtime.From.Equal(later) || result.Snapshot != nil {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		if runtime, _ := store.GetLastRuntimeTime(ctx, "t1"); !runtime.Equal(to) {
+			t.Errorf("Expected runtime offset %v, got %v", to, runtime)
+		}
+		if snapshot, _ := store.GetLastSnapshotTime(ctx, "t1"); !snapshot.Equal(to.Add(-time.Hour)) {
+			t.Errorf("Expected snapshot offset to be untouched, got %v", snapshot)
+		}
+	})
+
+	t.Run("nothing to rewind", func(t *testing.T) {
+		store := NewMemoryOffsetStore()
+		_ = store.SetLastRuntimeTime(ctx, "t1", to)
+		if _, err := RewindOffsets(ctx, store, "t1", to); !errors.Is(err, ErrNothingToRewind) {
+			t.Errorf("Expected ErrNothingToRewind, got %v", err)
+		}
+		if _, err := RewindOffsets(ctx, store, "unknown", to); !errors.Is(err, ErrNothingToRewind) {
+			t.Errorf("Expected ErrNothingToRewind for an unknown thermostat, got %v", err)
+		}
+	})
+
+	t.Run("clears the dedupe cache", func(t *testing.T) {
+		store := newTestBoltStore(t)
+		_ = store.SetLastRuntimeTime(ctx, "t1", later)
+		_ = store.MarkWritten(ctx, []string{"doc"}, time.Now())
+
+		if _, err := RewindOffsets(ctx, store, "t1", to); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if written, _ := store.WrittenDocuments(ctx, []string{"doc"}); written["doc"] {
+			t.Error("Expected written documents to be forgotten")
+		}
+	})
+}
+
+func TestServeRewind(t *testing.T) {
+	const token = "0123456789abcdef"
+	store := NewMemoryOffsetStore()
+	_ = store.SetLastRuntimeTime(context.Background(), "t1", time.Now())
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, store)
+	handler := scheduler.ServeRewind(token)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			select {
+			case request := <-scheduler.rewinds:
+				scheduler.handleRewind(ctx, request)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	body := `{"thermostat": "t1", "to": "2024-05-01T00:00:00Z"}`
+	tests := []struct {
+		name     string
+		method   string
+		auth     string
+		body     string
+		expected int
+	}{
+		{name: "wrong method", method: http.MethodGet, auth: "Bearer " + token, expected: http.StatusMethodNotAllowed},
+		{name: "missing token", method: http.MethodPost, body: body, expected: http.StatusUnauthorized},
+		{name: "wrong token", method: http.MethodPost, auth: "Bearer nope", body: body, expected: http.StatusUnauthorized},
+		{name: "missing thermostat", method: http.MethodPost, auth: "Bearer " + token, body: `{"to": "2024-05-01T00:00:00Z"}`, expected: http.StatusBadRequest},
+		{name: "rewound", method: http.MethodPost, auth: "Bearer " + token, body: body, expected: http.StatusOK},
+		{name: "already rewound", method: http.MethodPost, auth: "Bearer " + token, body: body, expected: http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(tt.method, "/admin/offsets/rewind", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				request.Header.Set("Authorization", tt.auth)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != tt.expected {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expected, recorder.Code, recorder.Body.String())
+			}
+			if tt.expected == http.StatusOK {
+				var result RewindResult
+				if err := json.NewDecoder(recorder.Body).Decode(&result); err != nil || result.Runtime == nil {
+					t.Errorf("Unexpected response %+v (error %v)", result, err)
+				}
+			}
+		})
+	}
+}
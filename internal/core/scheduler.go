@@ -2,12 +2,16 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+	"github.com/benvon/thermostat-telemetry-reader/internal/schedule"
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
 )
 
 // OffsetStore manages persistence of polling offsets
@@ -23,13 +27,54 @@ type OffsetStore interface {
 
 	// SetLastSnapshotTime sets the last snapshot timestamp for a thermostat
 	SetLastSnapshotTime(ctx context.Context, thermostatID string, timestamp time.Time) error
+
+	// GetThrottledUntil returns the time before which a provider or sink must not be called
+	GetThrottledUntil(ctx context.Context, scope string) (time.Time, error)
+
+	// SetThrottledUntil records the time before which a provider or sink must not be called
+	SetThrottledUntil(ctx context.Context, scope string, until time.Time) error
 }
 
+// StateStore is implemented by offset stores that also keep each thermostat's
+// last runtime state, so transitions spanning two polls are detected, and the
+// IDs of recently written documents, so rows fetched again are not rewritten
+type StateStore interface {
+	// GetLastState returns a thermostat's state as of its latest runtime row, or nil
+	GetLastState(ctx context.Context, thermostatID string) (*RuntimeState, error)
+
+	// SetLastState stores a thermostat's state as of its latest runtime row
+	SetLastState(ctx context.Context, thermostatID string, state RuntimeState) error
+
+	// WrittenDocuments returns which of the given document IDs every sink accepted recently
+	WrittenDocuments(ctx context.Context, ids []string) (map[string]bool, error)
+
+	// MarkWritten records document IDs that every sink accepted
+	MarkWritten(ctx context.Context, ids []string, at time.Time) error
+
+	// ForgetWritten clears the written document IDs, so rewound rows are rewritten
+	ForgetWritten(ctx context.Context) error
+}
+
+// RuntimeState is a thermostat's state as of one runtime row
+type RuntimeState struct {
+	EventTime time.Time   `json:"event_time"`
+	State     model.State `json:"state"`
+}
+
+// dedupeRetention is how long written document IDs are remembered. It
+// covers rows fetched again when a grouped runtime request starts at another
+// thermostat's older offset.
+const dedupeRetention = 48 * time.Hour
+
+// statePruneInterval is how often expired document IDs are removed
+const statePruneInterval = time.Hour
+
 // MemoryOffsetStore is an in-memory implementation of OffsetStore for testing
 type MemoryOffsetStore struct {
 	mu                sync.RWMutex
 	lastRuntimeTimes  map[string]time.Time
 	lastSnapshotTimes map[string]time.Time
+	throttledUntil    map[string]time.Time
 }
 
 // NewMemoryOffsetStore creates a new in-memory offset store
@@ -37,6 +82,7 @@ func NewMemoryOffsetStore() *MemoryOffsetStore {
 	return &MemoryOffsetStore{
 		lastRuntimeTimes:  make(map[string]time.Time),
 		lastSnapshotTimes: make(map[string]time.Time),
+		throttledUntil:    make(map[string]time.Time),
 	}
 }
 
@@ -70,6 +116,43 @@ func (s *MemoryOffsetStore) SetLastSnapshotTime(ctx context.Context, thermostatI
 	return nil
 }
 
+// GetThrottledUntil returns the time before which a provider or sink must not be called
+func (s *MemoryOffsetStore) GetThrottledUntil(ctx context.Context, scope string) (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.throttledUntil[scope], nil
+}
+
+// SetThrottledUntil records the time before which a provider or sink must not be called
+func (s *MemoryOffsetStore) SetThrottledUntil(ctx context.Context, scope string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttledUntil[scope] = until
+	return nil
+}
+
+// ListOffsets returns every stored offset and throttle deadline
+func (s *MemoryOffsetStore) ListOffsets(ctx context.Context) (*OffsetDump, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	dump := newOffsetDump()
+	for id, t := range s.lastRuntimeTimes {
+		offsets := dump.Thermostats[id]
+		offsets.LastRuntime = t
+		dump.Thermostats[id] = offsets
+	}
+	for id, t := range s.lastSnapshotTimes {
+		offsets := dump.Thermostats[id]
+		offsets.LastSnapshot = t
+		dump.Thermostats[id] = offsets
+	}
+	for scope, until := range s.throttledUntil {
+		dump.Throttles[scope] = until
+	}
+	return dump, nil
+}
+
 // Scheduler manages the polling of thermostats and data collection
 type Scheduler struct {
 	providers      []model.Provider
@@ -78,9 +161,64 @@ type Scheduler struct {
 	offsetStore    OffsetStore
 	pollInterval   time.Duration
 	backfillWindow time.Duration
+	backfillPolicy BackfillPolicy
+	backfillRetry  retry.Config
 	idGenerator    model.DocumentIDGenerator
-	metrics        *MetricsCollector
-	logger         *slog.Logger
+	events         *eventTracker
+	analyzers      []Analyzer
+	anomalies      []AnomalyDetector
+	notifier       notify.Notifier
+	failureAfter   time.Duration
+	failures       map[string]*failureState
+	notifications  chan notify.Message
+	notifyMu       sync.Mutex
+	notifying      bool // a delivery goroutine is draining notifications
+	watchdog       *WriteWatchdog
+	reconcile      *reconciler
+	gapFill        *gapFill
+	resources      *resourceMonitor
+	faults         *faultInjector
+	metadata       *metadataCache
+	metadataConfig MetadataConfig
+	liveConfig     LiveConfig
+	inflight       *inflightLimiter
+	verify         map[string]bool
+	liveTargets    map[string]liveTargets
+	maintenance    map[string]*maintenanceState
+	budgets        map[string]*budgetTracker
+	settlingDelays map[string]time.Duration
+	alignBins      map[string]bool
+	// providerTimeouts and sinkTimeouts override the default bound on one
+	// provider call or sink write, by name
+	providerTimeouts map[string]time.Duration
+	sinkTimeouts     map[string]time.Duration
+	backfillPacing   int
+	backfillQueue    []*backfillJob
+	// backfillRunning is set while paced backfill makes requests
+	backfillRunning bool
+	overwriteWindow time.Duration
+	strategy        Strategy
+	activity        map[string]bool
+	connected       map[string]bool
+	firmware        map[string]string
+	snapshots       map[string]snapshotCacheEntry
+	snapshotMaxAge  map[string]time.Duration
+	provenance      *provenance
+	// tags are the configured tags of each thermostat, by thermostat ID
+	tags    map[string]map[string]string
+	rewinds chan rewindRequest
+	metrics *MetricsCollector
+	logger  *slog.Logger
+}
+
+// SchedulerOption configures optional scheduler behavior
+type SchedulerOption func(*Scheduler)
+
+// WithAnalyzers registers analyzers that receive every normalized runtime row
+func WithAnalyzers(analyzers ...Analyzer) SchedulerOption {
+	return func(s *Scheduler) {
+		s.analyzers = append(s.analyzers, analyzers...)
+	}
 }
 
 // NewScheduler creates a new scheduler
@@ -92,18 +230,45 @@ func NewScheduler(
 	pollInterval, backfillWindow time.Duration,
 	metrics *MetricsCollector,
 	logger *slog.Logger,
+	opts ...SchedulerOption,
 ) *Scheduler {
-	return &Scheduler{
-		providers:      providers,
-		sinks:          sinks,
-		normalizer:     normalizer,
-		offsetStore:    offsetStore,
-		pollInterval:   pollInterval,
-		backfillWindow: backfillWindow,
-		idGenerator:    model.NewIDGenerator(),
-		metrics:        metrics,
-		logger:         logger,
+	s := &Scheduler{
+		providers:        providers,
+		sinks:            sinks,
+		normalizer:       normalizer,
+		offsetStore:      offsetStore,
+		pollInterval:     pollInterval,
+		backfillWindow:   backfillWindow,
+		backfillPolicy:   BackfillSkip,
+		backfillRetry:    backfillRetryConfig,
+		idGenerator:      model.NewIDGenerator(),
+		events:           newEventTracker(defaultEventRetention),
+		metadata:         newMetadataCache(),
+		metadataConfig:   MetadataConfig{RefreshInterval: defaultMetadataRefresh},
+		liveTargets:      make(map[string]liveTargets),
+		maintenance:      make(map[string]*maintenanceState),
+		budgets:          make(map[string]*budgetTracker),
+		settlingDelays:   make(map[string]time.Duration),
+		alignBins:        make(map[string]bool),
+		providerTimeouts: make(map[string]time.Duration),
+		sinkTimeouts:     make(map[string]time.Duration),
+		failures:         make(map[string]*failureState),
+		strategy:         schedule.NewFixed(pollInterval),
+		activity:         make(map[string]bool),
+		connected:        make(map[string]bool),
+		firmware:         make(map[string]string),
+		snapshots:        make(map[string]snapshotCacheEntry),
+		snapshotMaxAge:   make(map[string]time.Duration),
+		rewinds:          make(chan rewindRequest),
+		metrics:          metrics,
+		logger:           logger,
 	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
 }
 
 // Start begins the polling scheduler
@@ -112,52 +277,141 @@ func (s *Scheduler) Start(ctx context.Context) error {
 		"poll_interval", s.pollInterval,
 		"backfill_window", s.backfillWindow,
 		"providers", len(s.providers),
-		"sinks", len(s.sinks))
+		"sinks", len(s.sinks),
+		"strategy", s.strategy.Name(),
+		"live_interval", s.liveConfig.Interval)
 
 	// Perform initial backfill for all thermostats
+	s.startCycle(PhaseBackfilling, time.Now())
 	if err := s.performInitialBackfill(ctx); err != nil {
+		if ctx.Err() != nil {
+			s.logger.Info("Initial backfill interrupted by context cancellation")
+			s.metrics.RecordSchedulerPhase(PhaseDraining)
+			return ctx.Err()
+		}
 		s.logger.Error("Initial backfill failed", "error", err)
 		return fmt.Errorf("initial backfill: %w", err)
 	}
+	s.runPacedBackfill(ctx)
+	s.flushAnalyzers(ctx, time.Now())
 
-	// Start the main polling loop
-	ticker := time.NewTicker(s.pollInterval)
-	defer ticker.Stop()
+	// Start the main polling loop. The strategy picks each cycle's start time.
+	// Live polls share the loop so they never run concurrently with a report
+	// poll; ticks missed during a long poll are dropped.
+	timer := time.NewTimer(s.nextCycleDelay())
+	defer timer.Stop()
+	liveTick, stopLive := s.liveTicker()
+	defer stopLive()
 
 	for {
 		select {
 		case <-ctx.Done():
 			s.logger.Info("Scheduler stopping due to context cancellation")
+			s.metrics.RecordSchedulerPhase(PhaseDraining)
 			return ctx.Err()
-		case <-ticker.C:
+		case <-timer.C:
+			s.startCycle(PhasePolling, time.Now())
 			if err := s.pollAllThermostats(ctx); err != nil {
 				s.logger.Error("Polling cycle failed", "error", err)
 				// Continue polling even if one cycle fails
 			}
+			s.checkWatchdog(time.Now())
+			s.runPacedBackfill(ctx)
+			s.checkReconciliation(time.Now())
+			s.checkResources(time.Now())
+			s.flushAnalyzers(ctx, time.Now())
+			timer.Reset(s.nextCycleDelay())
+		case <-liveTick:
+			s.pollLive(ctx)
+		case request := <-s.rewinds:
+			s.handleRewind(ctx, request)
 		}
 	}
 }
 
-// performInitialBackfill performs backfill for all thermostats
+// nextCycleDelay asks the strategy when the next polling cycle should start,
+// later if a request budget would otherwise run out. It is called as each
+// cycle ends, so it also records the cycle's end.
+func (s *Scheduler) nextCycleDelay() time.Duration {
+	now := time.Now()
+	next := s.strategy.Next(now, s.equipmentActive())
+	next = now.Add(s.budgetedDelay(now, next.Sub(now)))
+	s.logger.Debug("Next polling cycle scheduled", "strategy", s.strategy.Name(), "at", next)
+	s.metrics.RecordCycleEnd(now, next)
+	return next.Sub(now)
+}
+
+// performInitialBackfill performs backfill for all thermostats. Failures are
+// handled according to the backfill policy; cancellation stops the backfill
+// between thermostats and between chunks.
 func (s *Scheduler) performInitialBackfill(ctx context.Context) error {
-	s.logger.Info("Performing initial backfill")
+	s.logger.Info("Performing initial backfill", "failure_policy", s.backfillPolicy, "max_requests_per_cycle", s.backfillPacing)
 
 	now := time.Now()
 	backfillStart := now.Add(-s.backfillWindow)
 
 	for _, provider := range s.providers {
-		thermostats, err := provider.ListThermostats(ctx)
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("backfill cancelled: %w", err)
+		}
+		if paused, _ := s.checkMaintenance(provider, now); paused {
+			s.logger.Info("Deferring backfill until provider maintenance ends", "provider", provider.Info().Name)
+			s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatMaintenance)
+			continue
+		}
+		if until := s.throttledUntil(ctx, providerScope(provider)); !until.IsZero() {
+			s.logger.Warn("Skipping backfill for throttled provider", "provider", provider.Info().Name, "until", until)
+			s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatThrottled)
+			continue
+		}
+
+		var thermostats []model.ThermostatRef
+		err := s.backfillStep(ctx, func() error {
+			callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+			defer cancel()
+			var err error
+			thermostats, err = provider.ListThermostats(callCtx)
+			return err
+		})
 		if err != nil {
 			s.logger.Error("Failed to list thermostats", "provider", provider.Info().Name, "error", err)
+			s.recordThrottle(ctx, providerScope(provider), err)
+			if s.abortBackfill(ctx) {
+				return fmt.Errorf("provider %s: listing thermostats: %w", provider.Info().Name, err)
+			}
 			continue
 		}
 
 		for _, thermostat := range thermostats {
-			if err := s.backfillThermostat(ctx, provider, thermostat, backfillStart, now); err != nil {
+			s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, ThermostatBackfilling)
+		}
+		for _, thermostat := range thermostats {
+			if err := ctx.Err(); err != nil {
+				return fmt.Errorf("backfill cancelled: %w", err)
+			}
+			queued := false
+			err := s.backfillStep(ctx, func() error {
+				var err error
+				queued, err = s.startBackfill(ctx, provider, thermostat, s.catchUpStart(ctx, thermostat, backfillStart, now), now)
+				return err
+			})
+			if err != nil {
 				s.logger.Error("Failed to backfill thermostat",
 					"provider", provider.Info().Name,
 					"thermostat", thermostat.ID,
 					"error", err)
+				s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, ThermostatFailed)
+				if s.abortBackfill(ctx) {
+					return fmt.Errorf("provider %s: thermostat %s: %w", provider.Info().Name, thermostat.ID, err)
+				}
+				if s.recordThrottle(ctx, providerScope(provider), err) {
+					s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatThrottled)
+					break
+				}
+				continue
+			}
+			if !queued {
+				s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, ThermostatOK)
 			}
 		}
 	}
@@ -165,57 +419,56 @@ func (s *Scheduler) performInitialBackfill(ctx context.Context) error {
 	return nil
 }
 
-// backfillThermostat performs backfill for a single thermostat
+// backfillThermostat performs backfill for a single thermostat in chunks of
+// at most backfillChunk, recording the runtime offset after each chunk
 func (s *Scheduler) backfillThermostat(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, from, to time.Time) error {
 	s.logger.Info("Backfilling thermostat",
 		"thermostat", thermostat.ID,
 		"from", from,
 		"to", to)
 
-	// Record provider request
-	s.metrics.RecordProviderRequest(provider.Info().Name)
-
-	// Get runtime data for the backfill period
-	runtimeData, err := provider.GetRuntime(ctx, thermostat, from, to)
-	if err != nil {
-		s.metrics.RecordProviderError(provider.Info().Name)
-		return fmt.Errorf("getting runtime data: %w", err)
+	// Load location metadata first so backfilled rows are enriched
+	if err := s.refreshMetadata(ctx, provider, thermostat); err != nil {
+		s.logger.Warn("Failed to refresh device metadata", "thermostat", thermostat.ID, "error", err)
+		if s.recordThrottle(ctx, providerScope(provider), err) {
+			return err
+		}
 	}
 
-	// Normalize and write runtime data
-	var docs []model.Doc
-	for _, runtime := range runtimeData {
-		canonical, err := s.normalizer.NormalizeRuntime5m(runtime, provider.Info().Name)
-		if err != nil {
-			s.logger.Error("Failed to normalize runtime data", "error", err)
-			continue
+	for chunkStart := from; chunkStart.Before(to); chunkStart = chunkStart.Add(backfillChunk) {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("backfill cancelled: %w", err)
 		}
-
-		// Generate document ID
-		docID, err := s.idGenerator.GenerateRuntime5mID(canonical)
-		if err != nil {
-			s.logger.Error("Failed to generate document ID for runtime_5m", "error", err)
-			continue
+		chunkEnd := chunkStart.Add(backfillChunk)
+		if chunkEnd.After(to) {
+			chunkEnd = to
+		}
+		if err := s.backfillRange(ctx, provider, thermostat, chunkStart, chunkEnd); err != nil {
+			return err
 		}
-
-		docs = append(docs, model.Doc{
-			ID:   docID,
-			Type: "runtime_5m",
-			Body: canonical,
-		})
 	}
 
-	// Write to all sinks
-	if err := s.writeToAllSinks(ctx, docs); err != nil {
-		return fmt.Errorf("writing backfill data: %w", err)
+	s.seedRuntimeOffset(ctx, provider, thermostat, to)
+	return nil
+}
+
+// backfillRange fetches, normalizes and writes runtime data for one backfill chunk
+func (s *Scheduler) backfillRange(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, from, to time.Time) error {
+	// Record provider request
+	s.recordProviderRequest(provider.Info().Name)
+
+	// Get runtime data for the backfill period
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	runtimeData, err := provider.GetRuntime(callCtx, thermostat, from, to)
+	cancel()
+	if err != nil {
+		s.metrics.RecordProviderError(provider.Info().Name)
+		return fmt.Errorf("getting runtime data: %w", err)
 	}
 
-	// Update offset
-	if len(runtimeData) > 0 {
-		lastRuntime := runtimeData[len(runtimeData)-1].EventTime
-		if err := s.offsetStore.SetLastRuntimeTime(ctx, thermostat.ID, lastRuntime); err != nil {
-			s.logger.Error("Failed to update runtime offset", "error", err)
-		}
+	// Normalize and write runtime data, detecting transitions as polling does
+	if err := s.processRuntime(ctx, provider, thermostat, runtimeData); err != nil {
+		return fmt.Errorf("processing backfill data: %w", err)
 	}
 
 	return nil
@@ -225,8 +478,41 @@ func (s *Scheduler) backfillThermostat(ctx context.Context, provider model.Provi
 func (s *Scheduler) pollAllThermostats(ctx context.Context) error {
 	s.logger.Debug("Starting polling cycle")
 
+	// Fetching while a sink is throttled would advance offsets for data that
+	// cannot be written, so the whole cycle waits for the sink to recover
+	if scope, until := s.firstThrottledSink(ctx); !until.IsZero() {
+		s.logger.Warn("Skipping polling cycle while sink is throttled", "scope", scope, "until", until)
+		return nil
+	}
+
+	now := time.Now()
 	for _, provider := range s.providers {
-		if err := s.pollProvider(ctx, provider); err != nil {
+		paused, resumed := s.checkMaintenance(provider, now)
+		if paused {
+			s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatMaintenance)
+			continue
+		}
+		if until := s.throttledUntil(ctx, providerScope(provider)); !until.IsZero() {
+			s.logger.Warn("Skipping throttled provider", "provider", provider.Info().Name, "until", until)
+			s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatThrottled)
+			continue
+		}
+
+		if resumed {
+			if err := s.backfillAfterMaintenance(ctx, provider, now); err != nil {
+				s.recordThrottle(ctx, providerScope(provider), err)
+				s.logger.Error("Failed to backfill after maintenance", "provider", provider.Info().Name, "error", err)
+			}
+		}
+
+		err := s.pollProvider(ctx, provider)
+		s.trackFailure(providerScope(provider), "Polling provider "+provider.Info().Name, err, time.Now())
+		if err != nil {
+			status := ThermostatFailed
+			if s.recordThrottle(ctx, providerScope(provider), err) {
+				status = ThermostatThrottled
+			}
+			s.metrics.RecordProviderStatus(provider.Info().Name, status)
 			s.logger.Error("Failed to poll provider", "provider", provider.Info().Name, "error", err)
 		}
 	}
@@ -236,34 +522,181 @@ func (s *Scheduler) pollAllThermostats(ctx context.Context) error {
 
 // pollProvider polls all thermostats from a single provider
 func (s *Scheduler) pollProvider(ctx context.Context, provider model.Provider) error {
-	thermostats, err := provider.ListThermostats(ctx)
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	thermostats, err := provider.ListThermostats(callCtx)
+	cancel()
 	if err != nil {
 		return fmt.Errorf("listing thermostats: %w", err)
 	}
 
+	summaries, err := s.bulkSummaries(ctx, provider)
+	if err != nil {
+		var throttled *retry.ThrottledError
+		if errors.As(err, &throttled) {
+			return err
+		}
+		s.logger.Warn("Failed to get bulk summaries, falling back to per-thermostat requests",
+			"provider", provider.Info().Name,
+			"error", err)
+	}
+
+	cycle := &providerCycle{summaries: summaries}
+	_, cycle.bulkRuntime = provider.(model.BulkRuntimeProvider)
+
 	for _, thermostat := range thermostats {
-		if err := s.pollThermostat(ctx, provider, thermostat); err != nil {
+		if err := s.pollThermostat(ctx, provider, thermostat, cycle); err != nil {
 			s.logger.Error("Failed to poll thermostat",
 				"provider", provider.Info().Name,
 				"thermostat", thermostat.ID,
 				"error", err)
+			s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, ThermostatFailed)
+			s.observePoll(thermostat.ID, true, time.Now())
+			if s.recordThrottle(ctx, providerScope(provider), err) {
+				s.metrics.RecordProviderStatus(provider.Info().Name, ThermostatThrottled)
+				return nil
+			}
+			continue
+		}
+		status := ThermostatOK
+		if s.backfillQueued(thermostat.ID) {
+			status = ThermostatBackfilling
+		}
+		if s.disconnected(thermostat.ID) {
+			status = ThermostatDisconnected
 		}
+		s.metrics.RecordThermostatStatus(provider.Info().Name, thermostat.ID, status)
+		s.observePoll(thermostat.ID, false, time.Now())
 	}
 
+	s.fetchPendingRuntime(ctx, provider, cycle.pendingRuntime)
 	return nil
 }
 
-// pollThermostat polls a single thermostat
-func (s *Scheduler) pollThermostat(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef) error {
-	// Record provider request
-	s.metrics.RecordProviderRequest(provider.Info().Name)
+// providerCycle holds what one polling cycle has gathered for a provider
+type providerCycle struct {
+	// summaries are the provider's bulk summaries, if any
+	summaries map[string]model.Summary
+	// bulkRuntime defers runtime fetches to a grouped request after all
+	// thermostats have been polled
+	bulkRuntime    bool
+	pendingRuntime []pendingRuntime
+}
 
-	// Check if we need to fetch new data
-	summary, err := provider.GetSummary(ctx, thermostat)
+// pendingRuntime is a thermostat waiting for a grouped runtime fetch
+type pendingRuntime struct {
+	thermostat  model.ThermostatRef
+	lastRuntime time.Time
+}
+
+// fetchPendingRuntime fetches runtime data for the pending thermostats in one
+// grouped request starting at the earliest offset. Rows a thermostat already
+// has are rewritten under the same deterministic IDs. If the grouped request
+// fails for any reason other than throttling, each thermostat is fetched on
+// its own instead.
+func (s *Scheduler) fetchPendingRuntime(ctx context.Context, provider model.Provider, pending []pendingRuntime) {
+	if len(pending) == 0 {
+		return
+	}
+	bulkProvider, ok := provider.(model.BulkRuntimeProvider)
+	if !ok {
+		return
+	}
+
+	from := pending[0].lastRuntime
+	thermostats := make([]model.ThermostatRef, 0, len(pending))
+	for _, p := range pending {
+		if p.lastRuntime.Before(from) {
+			from = p.lastRuntime
+		}
+		thermostats = append(thermostats, p.thermostat)
+	}
+
+	s.logger.Debug("Fetching grouped runtime data", "provider", provider.Info().Name, "thermostats", len(thermostats), "since", from)
+	s.recordProviderRequest(provider.Info().Name)
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	rows, err := bulkProvider.GetRuntimeMulti(callCtx, thermostats, from, time.Now())
+	cancel()
 	if err != nil {
 		s.metrics.RecordProviderError(provider.Info().Name)
+		if s.recordThrottle(ctx, providerScope(provider), err) {
+			s.logger.Error("Failed to fetch grouped runtime data", "provider", provider.Info().Name, "error", err)
+			return
+		}
+		s.logger.Warn("Failed to fetch grouped runtime data, falling back to per-thermostat requests",
+			"provider", provider.Info().Name,
+			"error", err)
+		for _, p := range pending {
+			if err := s.fetchAndProcessRuntime(ctx, provider, p.thermostat, p.lastRuntime); err != nil {
+				s.logger.Error("Failed to fetch runtime data", "thermostat", p.thermostat.ID, "error", err)
+				if s.recordThrottle(ctx, providerScope(provider), err) {
+					return
+				}
+			}
+		}
+		return
+	}
+
+	for _, p := range pending {
+		if err := s.processRuntime(ctx, provider, p.thermostat, rows[p.thermostat.ID]); err != nil {
+			s.logger.Error("Failed to process runtime data", "thermostat", p.thermostat.ID, "error", err)
+		}
+	}
+}
+
+// bulkSummaries fetches summaries for all of a provider's thermostats in one
+// request when the provider supports it. It returns nil for other providers.
+func (s *Scheduler) bulkSummaries(ctx context.Context, provider model.Provider) (map[string]model.Summary, error) {
+	bulkProvider, ok := provider.(model.BulkSummaryProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	s.recordProviderRequest(provider.Info().Name)
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	defer cancel()
+	summaries, err := bulkProvider.GetSummaries(callCtx)
+	if err != nil {
+		s.metrics.RecordProviderError(provider.Info().Name)
+		return nil, fmt.Errorf("getting bulk summaries: %w", err)
+	}
+	return summaries, nil
+}
+
+// thermostatSummary returns the thermostat's summary from a bulk response, or
+// requests it individually when the bulk response does not include it
+func (s *Scheduler) thermostatSummary(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, summaries map[string]model.Summary) (model.Summary, error) {
+	if summary, ok := summaries[thermostat.ID]; ok {
+		summary.ThermostatRef = thermostat
+		return summary, nil
+	}
+
+	s.recordProviderRequest(provider.Info().Name)
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	defer cancel()
+	summary, err := provider.GetSummary(callCtx, thermostat)
+	if err != nil {
+		s.metrics.RecordProviderError(provider.Info().Name)
+		return model.Summary{}, err
+	}
+	return summary, nil
+}
+
+// pollThermostat polls a single thermostat. With grouped runtime fetching the
+// thermostat's runtime request is added to cycle instead of made here.
+func (s *Scheduler) pollThermostat(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, cycle *providerCycle) error {
+	// Check if we need to fetch new data
+	summary, err := s.thermostatSummary(ctx, provider, thermostat, cycle.summaries)
+	if err != nil {
 		return fmt.Errorf("getting summary: %w", err)
 	}
+	connected := s.checkConnectivity(ctx, provider, thermostat, summary)
+
+	if err := s.refreshMetadata(ctx, provider, thermostat); err != nil {
+		s.logger.Warn("Failed to refresh device metadata", "thermostat", thermostat.ID, "error", err)
+		if s.recordThrottle(ctx, providerScope(provider), err) {
+			return err
+		}
+	}
 
 	// Get last snapshot time
 	lastSnapshot, err := s.offsetStore.GetLastSnapshotTime(ctx, thermostat.ID)
@@ -277,11 +710,20 @@ func (s *Scheduler) pollThermostat(ctx context.Context, provider model.Provider,
 		(lastSnapshot.IsZero() || time.Since(lastSnapshot) >= 15*time.Minute)
 
 	if shouldFetchSnapshot {
-		if err := s.fetchAndProcessSnapshot(ctx, provider, thermostat); err != nil {
+		if err := s.fetchAndProcessSnapshot(ctx, provider, thermostat, summary.Revision); err != nil {
 			s.logger.Error("Failed to fetch snapshot", "thermostat", thermostat.ID, "error", err)
+			if s.recordThrottle(ctx, providerScope(provider), err) {
+				return err
+			}
 		}
 	}
 
+	// A disconnected thermostat has no new runtime; its offset stays put so
+	// the rows it uploads on reconnecting are fetched then
+	if !connected {
+		return nil
+	}
+
 	// Get last runtime time
 	lastRuntime, err := s.offsetStore.GetLastRuntimeTime(ctx, thermostat.ID)
 	if err != nil {
@@ -289,31 +731,42 @@ func (s *Scheduler) pollThermostat(ctx context.Context, provider model.Provider,
 		lastRuntime = time.Time{}
 	}
 
-	// Fetch runtime data if we have a last runtime time
-	if !lastRuntime.IsZero() {
+	// Fetch runtime data if we have a last runtime time and no paced
+	// backfill is still catching the thermostat up
+	if s.backfillQueued(thermostat.ID) {
+		return nil
+	}
+	if !lastRuntime.IsZero() && cycle.bulkRuntime {
+		cycle.pendingRuntime = append(cycle.pendingRuntime, pendingRuntime{thermostat: thermostat, lastRuntime: lastRuntime})
+	} else if !lastRuntime.IsZero() {
 		if err := s.fetchAndProcessRuntime(ctx, provider, thermostat, lastRuntime); err != nil {
 			s.logger.Error("Failed to fetch runtime data", "thermostat", thermostat.ID, "error", err)
+			s.recordThrottle(ctx, providerScope(provider), err)
 		}
 	}
 
 	return nil
 }
 
-// fetchAndProcessSnapshot fetches and processes a thermostat snapshot
-func (s *Scheduler) fetchAndProcessSnapshot(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef) error {
+// fetchAndProcessSnapshot fetches and processes a thermostat snapshot.
+// The summary revision is recorded on the snapshot when the provider does not
+// report one, so sinks can reference it instead of storing the raw payload.
+func (s *Scheduler) fetchAndProcessSnapshot(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, revision string) error {
 	s.logger.Debug("Fetching snapshot", "thermostat", thermostat.ID)
 
-	// Record provider request
-	s.metrics.RecordProviderRequest(provider.Info().Name)
-
-	snapshot, err := provider.GetSnapshot(ctx, thermostat, time.Time{})
+	snapshot, err := s.getSnapshot(ctx, provider, thermostat, revision, time.Now())
 	if err != nil {
-		s.metrics.RecordProviderError(provider.Info().Name)
 		return fmt.Errorf("getting snapshot: %w", err)
 	}
+	if snapshot.Revision == "" {
+		snapshot.Revision = revision
+	}
 
 	// Normalize snapshot
 	canonical := s.normalizer.NormalizeDeviceSnapshot(snapshot, provider.Info().Name)
+	now := time.Now()
+	s.events.Observe(thermostat.ID, canonical.Events, now)
+	s.observeEvents(thermostat.ID, canonical.Events, now)
 
 	// Generate document ID
 	docID, err := s.idGenerator.GenerateDeviceSnapshotID(canonical)
@@ -321,16 +774,20 @@ func (s *Scheduler) fetchAndProcessSnapshot(ctx context.Context, provider model.
 		return fmt.Errorf("generating document ID for device_snapshot: %w", err)
 	}
 
-	doc := model.Doc{
+	docs := []model.Doc{{
 		ID:   docID,
 		Type: "device_snapshot",
 		Body: canonical,
+	}}
+	if change := s.firmwareChange(thermostat, canonical); change != nil {
+		docs = append(docs, *change)
 	}
 
 	// Write to all sinks
-	if err := s.writeToAllSinks(ctx, []model.Doc{doc}); err != nil {
+	if err := s.writeToAllSinks(ctx, docs); err != nil {
 		return fmt.Errorf("writing snapshot: %w", err)
 	}
+	s.recordFirmware(thermostat.ID, canonical)
 
 	// Update offset
 	if err := s.offsetStore.SetLastSnapshotTime(ctx, thermostat.ID, snapshot.CollectedAt); err != nil {
@@ -345,30 +802,149 @@ func (s *Scheduler) fetchAndProcessRuntime(ctx context.Context, provider model.P
 	s.logger.Debug("Fetching runtime data", "thermostat", thermostat.ID, "since", lastRuntime)
 
 	// Record provider request
-	s.metrics.RecordProviderRequest(provider.Info().Name)
+	s.recordProviderRequest(provider.Info().Name)
 
 	now := time.Now()
-	runtimeData, err := provider.GetRuntime(ctx, thermostat, lastRuntime, now)
+	callCtx, cancel := s.providerContext(ctx, provider.Info().Name)
+	runtimeData, err := provider.GetRuntime(callCtx, thermostat, lastRuntime, now)
+	cancel()
 	if err != nil {
 		s.metrics.RecordProviderError(provider.Info().Name)
 		return fmt.Errorf("getting runtime data: %w", err)
 	}
 
+	return s.processRuntime(ctx, provider, thermostat, runtimeData)
+}
+
+// processRuntime normalizes and writes runtime rows for a thermostat, detects
+// transitions between them, and advances the runtime offset. Readings from
+// providers with bin alignment are first aggregated into bins, and rows inside
+// the provider's settling delay are left for a later poll. Gaps between the
+// remaining rows are filled under the gap fill policy.
+func (s *Scheduler) processRuntime(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, runtimeData []model.RuntimeRow) error {
+	s.metrics.RecordRuntimeRows(provider.Info().Name, len(runtimeData), 0)
+	runtimeData = s.alignedRows(provider.Info().Name, thermostat.ID, runtimeData, time.Now())
+	runtimeData = s.settledRows(provider.Info().Name, thermostat.ID, runtimeData, time.Now())
 	if len(runtimeData) == 0 {
 		s.logger.Debug("No new runtime data", "thermostat", thermostat.ID)
 		return nil
 	}
+	runtimeData = s.filledRows(thermostat.ID, runtimeData)
+	s.reconcileRows(len(runtimeData))
+
+	stateStore, _ := s.offsetStore.(StateStore)
+	prevState := s.storedState(ctx, stateStore, thermostat.ID, runtimeData[0].EventTime)
+	docs, lastState := s.runtimeDocs(provider.Info().Name, thermostat, prevState, runtimeData)
+	docs = s.suppressConflictingTransitions(ctx, thermostat.ID, docs)
+
+	// Write to all sinks
+	if err := s.writeRuntimeDocs(ctx, stateStore, docs); err != nil {
+		return fmt.Errorf("writing runtime data: %w", err)
+	}
+	s.recordTransitions(ctx, thermostat.ID, docs)
+	if stateStore != nil && lastState != nil {
+		state := RuntimeState{EventTime: runtimeData[len(runtimeData)-1].EventTime, State: *lastState}
+		if err := stateStore.SetLastState(ctx, thermostat.ID, state); err != nil {
+			s.logger.Error("Failed to store last runtime state", "thermostat", thermostat.ID, "error", err)
+		}
+	}
+
+	// Update offset
+	if len(runtimeData) > 0 {
+		lastRuntimeTime := runtimeData[len(runtimeData)-1].EventTime
+		if err := s.offsetStore.SetLastRuntimeTime(ctx, thermostat.ID, lastRuntimeTime); err != nil {
+			s.logger.Error("Failed to update runtime offset", "error", err)
+		}
+	}
+
+	return nil
+}
+
+// maxStoredStateGap is the longest gap between a stored state and the next
+// runtime row across which a transition is still reported
+const maxStoredStateGap = 30 * time.Minute
+
+// storedState returns a thermostat's stored state when the store keeps one
+// and it shortly precedes the first new row. Rows fetched again after a later
+// state was stored, or after an outage, start without one, so no transition
+// is invented for them.
+func (s *Scheduler) storedState(ctx context.Context, store StateStore, thermostatID string, firstRow time.Time) *model.State {
+	if store == nil {
+		return nil
+	}
+	stored, err := store.GetLastState(ctx, thermostatID)
+	if err != nil {
+		s.logger.Warn("Failed to read last runtime state", "thermostat", thermostatID, "error", err)
+		return nil
+	}
+	if stored == nil || !stored.EventTime.Before(firstRow) || firstRow.Sub(stored.EventTime) > maxStoredStateGap {
+		return nil
+	}
+	return &stored.State
+}
+
+// writeRuntimeDocs writes runtime documents, skipping those every sink
+// already accepted when the store keeps a dedupe cache
+func (s *Scheduler) writeRuntimeDocs(ctx context.Context, store StateStore, docs []model.Doc) error {
+	if store == nil {
+		s.reconcileRuntimeDocs(docs, nil)
+		return s.writeToAllSinks(ctx, docs)
+	}
 
-	// Normalize and write runtime data, and detect transitions
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	written, err := store.WrittenDocuments(ctx, ids)
+	if err != nil {
+		s.logger.Warn("Failed to read written documents, writing all", "error", err)
+	}
+	fresh := make([]model.Doc, 0, len(docs))
+	var skipped []model.Doc
+	for _, doc := range docs {
+		if written[doc.ID] {
+			skipped = append(skipped, doc)
+		} else {
+			fresh = append(fresh, doc)
+		}
+	}
+	s.reconcileRuntimeDocs(fresh, skipped)
+	if len(skipped) > 0 {
+		s.logger.Debug("Skipping documents already written", "count", len(skipped))
+	}
+
+	clean, err := s.writeAll(ctx, fresh)
+	if err != nil || !clean {
+		return err
+	}
+	freshIDs := make([]string, len(fresh))
+	for i, doc := range fresh {
+		freshIDs[i] = doc.ID
+	}
+	if err := store.MarkWritten(ctx, freshIDs, time.Now()); err != nil {
+		s.logger.Warn("Failed to record written documents", "error", err)
+	}
+	return nil
+}
+
+// runtimeDocs normalizes runtime rows into runtime_5m documents, along with
+// anomaly alerts and transitions detected between consecutive rows. The first
+// row is compared with prevState when it is not nil. It also returns the
+// state of the last row.
+func (s *Scheduler) runtimeDocs(providerName string, thermostat model.ThermostatRef, prevState *model.State, runtimeData []model.RuntimeRow) ([]model.Doc, *model.State) {
 	var docs []model.Doc
-	var prevState *model.State
 
 	for _, runtime := range runtimeData {
-		canonical, err := s.normalizer.NormalizeRuntime5m(runtime, provider.Info().Name)
+		canonical, err := s.normalizer.NormalizeRuntime5m(runtime, providerName)
 		if err != nil {
 			s.logger.Error("Failed to normalize runtime data", "error", err)
+			s.metrics.RecordRuntimeRows(providerName, 0, 1)
+			s.reconcileRejected()
 			continue
 		}
+		if !canonical.Filled {
+			s.observeRuntime(canonical)
+		}
 
 		// Generate document ID
 		docID, err := s.idGenerator.GenerateRuntime5mID(canonical)
@@ -376,12 +952,19 @@ func (s *Scheduler) fetchAndProcessRuntime(ctx context.Context, provider model.P
 			s.logger.Error("Failed to generate document ID for runtime_5m", "error", err)
 			continue
 		}
+		s.enrichRuntime(canonical)
 
 		docs = append(docs, model.Doc{
 			ID:   docID,
 			Type: "runtime_5m",
 			Body: canonical,
 		})
+		// Filled rows repeat or blank out a missing bin, so they are neither
+		// checked for anomalies nor compared for transitions
+		if canonical.Filled {
+			continue
+		}
+		docs = append(docs, s.detectAnomalies(canonical)...)
 
 		// Check for state transitions (compare with previous runtime row)
 		currentState := model.State{
@@ -398,10 +981,8 @@ func (s *Scheduler) fetchAndProcessRuntime(ctx context.Context, provider model.P
 				canonical.EventTime,
 				*prevState,
 				currentState,
-				model.EventInfo{
-					Kind: s.inferTransitionKind(*prevState, currentState),
-				},
-				provider.Info().Name,
+				s.classifyTransition(thermostat.ID, canonical.EventTime, *prevState, currentState),
+				providerName,
 				nil,
 			)
 
@@ -421,40 +1002,77 @@ func (s *Scheduler) fetchAndProcessRuntime(ctx context.Context, provider model.P
 		prevState = &currentState
 	}
 
-	// Write to all sinks
-	if err := s.writeToAllSinks(ctx, docs); err != nil {
-		return fmt.Errorf("writing runtime data: %w", err)
-	}
-
-	// Update offset
-	if len(runtimeData) > 0 {
-		lastRuntimeTime := runtimeData[len(runtimeData)-1].EventTime
-		if err := s.offsetStore.SetLastRuntimeTime(ctx, thermostat.ID, lastRuntimeTime); err != nil {
-			s.logger.Error("Failed to update runtime offset", "error", err)
-		}
-	}
-
-	return nil
+	return docs, prevState
 }
 
-// writeToAllSinks writes documents to all configured sinks
+// writeToAllSinks writes documents to all configured sinks, in batches
+// admitted by the in-flight limits when they are configured
 func (s *Scheduler) writeToAllSinks(ctx context.Context, docs []model.Doc) error {
+	_, err := s.writeAll(ctx, docs)
+	return err
+}
+
+// writeAll writes documents to all sinks and reports whether every sink
+// accepted every document
+func (s *Scheduler) writeAll(ctx context.Context, docs []model.Doc) (bool, error) {
 	if len(docs) == 0 {
-		return nil
+		return true, nil
+	}
+	s.stampIngest(docs)
+	s.stampTags(docs)
+	if s.inflight == nil {
+		return s.writeBatch(ctx, docs), nil
 	}
 
+	clean := true
+	for _, batch := range s.inflight.batches(docs) {
+		s.inflight.writing(&batch)
+		if !s.writeBatch(ctx, batch.docs) {
+			clean = false
+		}
+	}
+	s.inflight.writing(nil)
+	return clean, nil
+}
+
+// writeBatch writes one batch of documents to every sink and reports whether
+// all of them accepted it. Sink failures are logged and recorded rather than
+// returned, so one sink cannot block others.
+func (s *Scheduler) writeBatch(ctx context.Context, docs []model.Doc) bool {
+	clean := true
 	for _, sink := range s.sinks {
-		result, err := sink.Write(ctx, docs)
+		started := time.Now()
+		writeCtx, cancel := s.sinkContext(ctx, sink.Info().Name)
+		result, err := s.writeSink(writeCtx, sink, docs)
+		cancel()
+		elapsed := time.Since(started)
+		s.reconcileSinkWrite(sink.Info().Name, len(docs), result, err)
+		failure := err
+		if failure == nil && result.ErrorCount > 0 {
+			failure = fmt.Errorf("%d of %d documents failed: %v", result.ErrorCount, len(docs), result.Errors)
+		}
+		s.trackFailure(sinkScope(sink), "Writing to sink "+sink.Info().Name, failure, time.Now())
 		if err != nil {
 			s.logger.Error("Failed to write to sink",
 				"sink", sink.Info().Name,
 				"error", err)
 			s.metrics.RecordSinkError(sink.Info().Name)
+			s.recordThrottle(ctx, sinkScope(sink), err)
+			clean = false
 			continue
 		}
 
-		// Record metrics
+		// Record metrics. Latency is only observed for complete writes, since
+		// a partial failure does not say which documents landed.
 		s.metrics.RecordSinkWrite(sink.Info().Name, int64(result.SuccessCount))
+		s.metrics.RecordSinkBatch(sink.Info().Name, result.SuccessCount+result.ErrorCount, elapsed, result.PayloadBytes, result.SentBytes)
+		if s.watchdog != nil && result.SuccessCount > 0 {
+			s.watchdog.recordWrite(time.Now())
+		}
+		if result.ErrorCount == 0 {
+			s.metrics.RecordSinkLatency(sink.Info().Name, eventLatencies(sink, docs, time.Now()))
+			s.verifyWrite(ctx, sink, docs)
+		}
 
 		s.logger.Debug("Wrote to sink",
 			"sink", sink.Info().Name,
@@ -466,10 +1084,59 @@ func (s *Scheduler) writeToAllSinks(ctx context.Context, docs []model.Doc) error
 				"sink", sink.Info().Name,
 				"errors", result.Errors)
 			s.metrics.RecordSinkError(sink.Info().Name)
+			clean = false
 		}
 	}
+	return clean
+}
 
-	return nil
+// providerScope returns the throttle scope key for a provider
+func providerScope(provider model.Provider) string {
+	return "provider:" + provider.Info().Name
+}
+
+// sinkScope returns the throttle scope key for a sink
+func sinkScope(sink model.Sink) string {
+	return "sink:" + sink.Info().Name
+}
+
+// throttledUntil returns the active throttle deadline for a scope, or zero time
+// if the scope is not currently throttled
+func (s *Scheduler) throttledUntil(ctx context.Context, scope string) time.Time {
+	until, err := s.offsetStore.GetThrottledUntil(ctx, scope)
+	if err != nil {
+		s.logger.Warn("Failed to read throttle state, assuming not throttled", "scope", scope, "error", err)
+		return time.Time{}
+	}
+	if !time.Now().Before(until) {
+		return time.Time{}
+	}
+	return until
+}
+
+// firstThrottledSink returns the first sink that is currently throttled, if any
+func (s *Scheduler) firstThrottledSink(ctx context.Context) (string, time.Time) {
+	for _, sink := range s.sinks {
+		if until := s.throttledUntil(ctx, sinkScope(sink)); !until.IsZero() {
+			return sinkScope(sink), until
+		}
+	}
+	return "", time.Time{}
+}
+
+// recordThrottle persists the deadline carried by a ThrottledError so later
+// cycles (and restarts) respect it. It reports whether err was a throttle.
+func (s *Scheduler) recordThrottle(ctx context.Context, scope string, err error) bool {
+	var throttled *retry.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+
+	s.logger.Warn("Throttled by remote service", "scope", scope, "until", throttled.Until)
+	if err := s.offsetStore.SetThrottledUntil(ctx, scope, throttled.Until); err != nil {
+		s.logger.Error("Failed to persist throttle state", "scope", scope, "error", err)
+	}
+	return true
 }
 
 // hasStateChanged determines if the thermostat state has changed significantly
@@ -511,6 +1178,18 @@ func floatsEqual(a, b *float64, tolerance float64) bool {
 	return diff < tolerance
 }
 
+// classifyTransition attributes a transition to an active demand-response
+// event when one covers its time, falling back to state-based inference
+func (s *Scheduler) classifyTransition(thermostatID string, at time.Time, prev, current model.State) model.EventInfo {
+	if event, ok := s.events.DemandResponseAt(thermostatID, at); ok {
+		return model.EventInfo{
+			Kind: model.EventKindDemandResponse,
+			Name: event.Name,
+		}
+	}
+	return model.EventInfo{Kind: s.inferTransitionKind(prev, current)}
+}
+
 // inferTransitionKind infers the kind of transition based on state changes
 func (s *Scheduler) inferTransitionKind(prev, current model.State) string {
 	// Mode changes are manual or schedule-driven
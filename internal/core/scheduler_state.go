@@ -0,0 +1,134 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Scheduler phases reported by /scheduler and /metrics
+const (
+	PhaseStarting    = "starting"
+	PhaseBackfilling = "backfilling"
+	PhasePolling     = "polling"
+	PhaseIdle        = "idle"
+	PhaseDraining    = "draining"
+)
+
+// Thermostat statuses counted in the scheduler state
+const (
+	ThermostatBackfilling = "backfilling"
+	ThermostatOK          = "ok"
+	ThermostatFailed      = "error"
+	ThermostatThrottled   = "throttled"
+	ThermostatMaintenance = "maintenance"
+	// ThermostatDisconnected is a thermostat its provider reports offline;
+	// its runtime is not fetched until it reconnects
+	ThermostatDisconnected = "disconnected"
+)
+
+// SchedulerState describes what the scheduler is doing right now
+type SchedulerState struct {
+	Phase          string         `json:"phase"`
+	LastCycleStart string         `json:"last_cycle_start,omitempty"`
+	LastCycleEnd   string         `json:"last_cycle_end,omitempty"`
+	NextRun        string         `json:"next_run,omitempty"`
+	Thermostats    map[string]int `json:"thermostats"` // thermostat count per status
+}
+
+// schedulerTracker holds the scheduler state inside the metrics collector
+type schedulerTracker struct {
+	phase       string
+	cycleStart  time.Time
+	cycleEnd    time.Time
+	nextRun     time.Time
+	thermostats map[string]thermostatStatus
+}
+
+// thermostatStatus is the last known outcome for one thermostat
+type thermostatStatus struct {
+	provider string
+	status   string
+}
+
+// RecordSchedulerPhase records the scheduler's current phase
+func (m *MetricsCollector) RecordSchedulerPhase(phase string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.phase = phase
+}
+
+// RecordCycleStart records the start of a backfill or polling cycle
+func (m *MetricsCollector) RecordCycleStart(phase string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.phase = phase
+	m.scheduler.cycleStart = at
+}
+
+// RecordCycleEnd records the end of a cycle and when the next one is due
+func (m *MetricsCollector) RecordCycleEnd(at, next time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.phase = PhaseIdle
+	m.scheduler.cycleEnd = at
+	m.scheduler.nextRun = next
+}
+
+// RecordThermostatStatus records the outcome of the latest poll of a thermostat
+func (m *MetricsCollector) RecordThermostatStatus(provider, thermostatID, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.thermostats[thermostatID] = thermostatStatus{provider: provider, status: status}
+}
+
+// RecordProviderStatus sets the status of every known thermostat of a
+// provider, for cycles that skip the provider entirely
+func (m *MetricsCollector) RecordProviderStatus(provider, status string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, current := range m.scheduler.thermostats {
+		if current.provider == provider {
+			m.scheduler.thermostats[id] = thermostatStatus{provider: provider, status: status}
+		}
+	}
+}
+
+// GetSchedulerState returns the current scheduler state
+func (m *MetricsCollector) GetSchedulerState() SchedulerState {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.schedulerState()
+}
+
+// schedulerState builds the scheduler state; callers must hold the lock
+func (m *MetricsCollector) schedulerState() SchedulerState {
+	state := SchedulerState{
+		Phase:          m.scheduler.phase,
+		LastCycleStart: formatOptionalTime(m.scheduler.cycleStart),
+		LastCycleEnd:   formatOptionalTime(m.scheduler.cycleEnd),
+		NextRun:        formatOptionalTime(m.scheduler.nextRun),
+		Thermostats:    make(map[string]int),
+	}
+	for _, current := range m.scheduler.thermostats {
+		state.Thermostats[current.status]++
+	}
+	return state
+}
+
+// formatOptionalTime formats t as RFC 3339, or returns "" for the zero time
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// ServeScheduler provides an HTTP handler for the scheduler state
+func (m *MetricsCollector) ServeScheduler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(m.GetSchedulerState())
+	})
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSchedulerState(t *testing.T) {
+	provider := &mockProvider{name: "test"}
+	scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore())
+	metrics := scheduler.metrics
+
+	if phase := metrics.GetSchedulerState().Phase; phase != PhaseStarting {
+		t.Errorf("Expected initial phase %s, got %s", PhaseStarting, phase)
+	}
+
+	t.Run("backfill marks thermostats ok", func(t *testing.T) {
+		metrics.RecordCycleStart(PhaseBackfilling, time.Now())
+		if err := scheduler.performInitialBackfill(testContext(t)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		state := metrics.GetSchedulerState()
+		if state.Phase != PhaseBackfilling || state.LastCycleStart == "" {
+			t.Errorf("Expected a running backfill cycle, got %+v", state)
+		}
+		if state.Thermostats[ThermostatOK] != 1 {
+			t.Errorf("Expected 1 ok thermostat, got %v", state.Thermostats)
+		}
+	})
+
+	t.Run("cycle end records the next run", func(t *testing.T) {
+		scheduler.nextCycleDelay()
+		state := metrics.GetSchedulerState()
+		if state.Phase != PhaseIdle || state.LastCycleEnd == "" || state.NextRun == "" {
+			t.Errorf("Expected an idle scheduler with cycle times, got %+v", state)
+		}
+	})
+
+	t.Run("failed provider marks its thermostats", func(t *testing.T) {
+		provider.shouldFail = true
+		if err := scheduler.pollAllThermostats(testContext(t)); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		state := metrics.GetSchedulerState()
+		if state.Thermostats[ThermostatFailed] != 1 || state.Thermostats[ThermostatOK] != 0 {
+			t.Errorf("Expected 1 failed thermostat, got %v", state.Thermostats)
+		}
+	})
+
+	t.Run("state is served as JSON and included in metrics", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		metrics.ServeScheduler().ServeHTTP(recorder, httptest.NewRequest("GET", "/scheduler", nil))
+
+		var served SchedulerState
+		if err := json.NewDecoder(recorder.Body).Decode(&served); err != nil {
+			t.Fatalf("Failed to decode scheduler state: %v", err)
+		}
+		if served.Phase != PhaseIdle {
+			t.Errorf("Expected served phase %s, got %s", PhaseIdle, served.Phase)
+		}
+		if metrics.GetMetrics().Scheduler.Phase != PhaseIdle {
+			t.Error("Expected scheduler state in metrics")
+		}
+	})
+}
@@ -2,13 +2,25 @@ package core
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"testing"
 	"time"
 
+	"go.uber.org/goleak"
+
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
 )
 
+// TestMain fails the package if any test leaves goroutines running, so
+// scheduler loops, monitors and workers are all shut down by their contexts
+func TestMain(m *testing.M) {
+	// slowProvider deliberately ignores cancellation and finishes its sleep
+	// after the health check that timed it out
+	goleak.VerifyTestMain(m, goleak.IgnoreAnyFunction("github.com/benvon/thermostat-telemetry-reader/internal/core.(*slowProvider).ListThermostats"))
+}
+
 func TestMemoryOffsetStore(t *testing.T) {
 	t.Run("runtime time operations", func(t *testing.T) {
 		store := NewMemoryOffsetStore()
@@ -169,6 +181,256 @@ func TestNewScheduler(t *testing.T) {
 	}
 }
 
+func TestSchedulerRespectsThrottle(t *testing.T) {
+	ctx := testContext(t)
+
+	t.Run("throttled provider is skipped", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		sink := &mockSink{name: "elasticsearch"}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(provider, sink, store)
+
+		if err := store.SetThrottledUntil(ctx, "provider:ecobee", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("SetThrottledUntil failed: %v", err)
+		}
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 0 {
+			t.Errorf("Expected no provider calls while throttled, got %d", provider.listCalls)
+		}
+	})
+
+	t.Run("expired throttle is ignored", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		sink := &mockSink{name: "elasticsearch"}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(provider, sink, store)
+
+		if err := store.SetThrottledUntil(ctx, "provider:ecobee", time.Now().Add(-time.Minute)); err != nil {
+			t.Fatalf("SetThrottledUntil failed: %v", err)
+		}
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 1 {
+			t.Errorf("Expected 1 list call, got %d", provider.listCalls)
+		}
+	})
+
+	t.Run("throttled sink pauses the cycle", func(t *testing.T) {
+		provider := &countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		sink := &mockSink{name: "elasticsearch"}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(provider, sink, store)
+
+		if err := store.SetThrottledUntil(ctx, "sink:elasticsearch", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("SetThrottledUntil failed: %v", err)
+		}
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.listCalls != 0 {
+			t.Errorf("Expected no provider calls while sink is throttled, got %d", provider.listCalls)
+		}
+	})
+
+	t.Run("throttle error is persisted", func(t *testing.T) {
+		provider := &countingProvider{
+			mockProvider: mockProvider{name: "ecobee", tokenValid: true},
+			listErr:      retry.NewThrottledError(429, 5*time.Minute),
+		}
+		sink := &mockSink{name: "elasticsearch"}
+		store := NewMemoryOffsetStore()
+		scheduler := newTestScheduler(provider, sink, store)
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+
+		until, err := store.GetThrottledUntil(ctx, "provider:ecobee")
+		if err != nil {
+			t.Fatalf("GetThrottledUntil failed: %v", err)
+		}
+		if time.Until(until) < 4*time.Minute {
+			t.Errorf("Expected throttle deadline about 5 minutes out, got %v", until)
+		}
+	})
+}
+
+func TestSchedulerUsesBulkSummaries(t *testing.T) {
+	ctx := testContext(t)
+
+	t.Run("bulk summaries replace per-thermostat requests", func(t *testing.T) {
+		provider := &bulkSummaryProvider{countingProvider: countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}}
+		scheduler := newTestScheduler(provider, &mockSink{name: "elasticsearch"}, NewMemoryOffsetStore())
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.bulkCalls != 1 {
+			t.Errorf("Expected 1 bulk summary call, got %d", provider.bulkCalls)
+		}
+		if provider.summaryCalls != 0 {
+			t.Errorf("Expected no per-thermostat summary calls, got %d", provider.summaryCalls)
+		}
+	})
+
+	t.Run("bulk failure falls back to per-thermostat requests", func(t *testing.T) {
+		provider := &bulkSummaryProvider{
+			countingProvider: countingProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}},
+			bulkErr:          errors.New("bulk unavailable"),
+		}
+		scheduler := newTestScheduler(provider, &mockSink{name: "elasticsearch"}, NewMemoryOffsetStore())
+
+		if err := scheduler.pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.summaryCalls != 1 {
+			t.Errorf("Expected 1 per-thermostat summary call, got %d", provider.summaryCalls)
+		}
+	})
+}
+
+func TestSchedulerGroupsRuntimeRequests(t *testing.T) {
+	ctx := testContext(t)
+
+	newScheduler := func(provider model.Provider) *Scheduler {
+		store := NewMemoryOffsetStore()
+		for _, id := range []string{"therm-1", "therm-2"} {
+			_ = store.SetLastRuntimeTime(ctx, id, time.Now().Add(-time.Hour))
+		}
+		return newTestScheduler(provider, &mockSink{name: "elasticsearch"}, store)
+	}
+
+	t.Run("one runtime request per provider", func(t *testing.T) {
+		provider := &bulkRuntimeProvider{mockProvider: mockProvider{name: "ecobee", tokenValid: true}}
+		if err := newScheduler(provider).pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.multiCalls != 1 || provider.runtimeCalls != 0 {
+			t.Errorf("Expected 1 grouped request and no single requests, got %d and %d", provider.multiCalls, provider.runtimeCalls)
+		}
+		if len(provider.lastGroup) != 2 {
+			t.Errorf("Expected both thermostats in the group, got %d", len(provider.lastGroup))
+		}
+	})
+
+	t.Run("grouped failure falls back to per-thermostat requests", func(t *testing.T) {
+		provider := &bulkRuntimeProvider{
+			mockProvider: mockProvider{name: "ecobee", tokenValid: true},
+			multiErr:     errors.New("report unavailable"),
+		}
+		if err := newScheduler(provider).pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.runtimeCalls != 2 {
+			t.Errorf("Expected 2 single runtime requests, got %d", provider.runtimeCalls)
+		}
+	})
+
+	t.Run("grouped throttle skips the fallback", func(t *testing.T) {
+		provider := &bulkRuntimeProvider{
+			mockProvider: mockProvider{name: "ecobee", tokenValid: true},
+			multiErr:     retry.NewThrottledError(429, 5*time.Minute),
+		}
+		if err := newScheduler(provider).pollAllThermostats(ctx); err != nil {
+			t.Fatalf("pollAllThermostats failed: %v", err)
+		}
+		if provider.runtimeCalls != 0 {
+			t.Errorf("Expected no single runtime requests while throttled, got %d", provider.runtimeCalls)
+		}
+	})
+}
+
+// countingProvider wraps mockProvider to count calls and inject list errors
+type countingProvider struct {
+	mockProvider
+	listCalls int
+	listErr   error
+}
+
+func (p *countingProvider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	p.listCalls++
+	if p.listErr != nil {
+		return nil, p.listErr
+	}
+	return p.mockProvider.ListThermostats(ctx)
+}
+
+// bulkSummaryProvider is a counting provider that also returns bulk summaries
+type bulkSummaryProvider struct {
+	countingProvider
+	bulkCalls    int
+	summaryCalls int
+	bulkErr      error
+}
+
+func (p *bulkSummaryProvider) GetSummaries(ctx context.Context) (map[string]model.Summary, error) {
+	p.bulkCalls++
+	if p.bulkErr != nil {
+		return nil, p.bulkErr
+	}
+	return map[string]model.Summary{
+		"therm-1": {ThermostatRef: model.ThermostatRef{ID: "therm-1", Provider: p.name}},
+	}, nil
+}
+
+func (p *bulkSummaryProvider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	p.summaryCalls++
+	return p.mockProvider.GetSummary(ctx, tr)
+}
+
+// bulkRuntimeProvider is a mock provider with two thermostats that supports
+// grouped runtime requests
+type bulkRuntimeProvider struct {
+	mockProvider
+	multiCalls   int
+	runtimeCalls int
+	lastGroup    []model.ThermostatRef
+	multiErr     error
+}
+
+func (p *bulkRuntimeProvider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	return []model.ThermostatRef{
+		{ID: "therm-1", Provider: p.name},
+		{ID: "therm-2", Provider: p.name},
+	}, nil
+}
+
+func (p *bulkRuntimeProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	p.runtimeCalls++
+	return nil, nil
+}
+
+func (p *bulkRuntimeProvider) GetRuntimeMulti(ctx context.Context, trs []model.ThermostatRef, from, to time.Time) (map[string][]model.RuntimeRow, error) {
+	p.multiCalls++
+	p.lastGroup = trs
+	if p.multiErr != nil {
+		return nil, p.multiErr
+	}
+	return map[string][]model.RuntimeRow{}, nil
+}
+
+// newTestScheduler builds a scheduler around a single provider and sink
+func newTestScheduler(provider model.Provider, sink model.Sink, store OffsetStore, opts ...SchedulerOption) *Scheduler {
+	normalizer, _ := NewNormalizer("UTC")
+	return NewScheduler(
+		[]model.Provider{provider},
+		[]model.Sink{sink},
+		normalizer,
+		store,
+		5*time.Minute,
+		24*time.Hour,
+		NewMetricsCollector(),
+		slog.Default(),
+		opts...,
+	)
+}
+
 // Helper function
 func testContext(_ *testing.T) context.Context {
 	return context.Background()
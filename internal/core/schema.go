@@ -0,0 +1,60 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// Schema versions of the /healthz and /metrics JSON payloads. A version only
+// changes when a field is removed, renamed or changes meaning; new fields
+// keep the current version. A field is listed in the payload's deprecations
+// for at least one release before the version that removes it.
+const (
+	HealthSchemaVersion  = 1
+	MetricsSchemaVersion = 1
+)
+
+// schemaVersionHeader carries the payload's schema version on every response
+const schemaVersionHeader = "X-TTR-Schema-Version"
+
+// Deprecation announces a status payload field that a later schema version
+// removes
+type Deprecation struct {
+	// Field is the field's JSON path, with * for map keys, e.g.
+	// "sinks.*.last_write_time"
+	Field string `json:"field"`
+	// Replacement is the field to use instead, if any
+	Replacement string `json:"replacement,omitempty"`
+	// RemovedIn is the schema version without the field
+	RemovedIn int `json:"removed_in"`
+}
+
+// Fields of the current schemas that are due for removal
+var (
+	healthDeprecations  []Deprecation
+	metricsDeprecations []Deprecation
+)
+
+// acceptSchemaVersion checks the schema_version query parameter a client may
+// pin. A script written against another version gets 406 Not Acceptable and
+// the served version instead of a payload whose shape it does not expect.
+func acceptSchemaVersion(w http.ResponseWriter, r *http.Request, current int) bool {
+	w.Header().Set(schemaVersionHeader, strconv.Itoa(current))
+
+	requested := r.URL.Query().Get("schema_version")
+	if requested == "" || requested == strconv.Itoa(current) {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotAcceptable)
+	_ = json.NewEncoder(w).Encode(struct {
+		Error         string `json:"error"`
+		SchemaVersion int    `json:"schema_version"`
+	}{
+		Error:         "unsupported schema_version " + strconv.Quote(requested),
+		SchemaVersion: current,
+	})
+	return false
+}
@@ -0,0 +1,75 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestStatusSchemaVersions(t *testing.T) {
+	checker := NewHealthChecker([]model.Provider{&mockProvider{name: "ecobee", tokenValid: true}}, []model.Sink{&mockSink{name: "es"}})
+	metrics := NewMetricsCollector()
+
+	endpoints := []struct {
+		name    string
+		handler http.Handler
+		version int
+	}{
+		{name: "health", handler: checker.ServeHealth(), version: HealthSchemaVersion},
+		{name: "metrics", handler: metrics.ServeMetrics(), version: MetricsSchemaVersion},
+	}
+	tests := []struct {
+		name         string
+		query        string
+		expectStatus int
+	}{
+		{name: "unpinned", expectStatus: http.StatusOK},
+		{name: "pinned to the current version", query: "?schema_version=1", expectStatus: http.StatusOK},
+		{name: "pinned to another version", query: "?schema_version=2", expectStatus: http.StatusNotAcceptable},
+	}
+
+	for _, endpoint := range endpoints {
+		for _, tt := range tests {
+			t.Run(endpoint.name+" "+tt.name, func(t *testing.T) {
+				recorder := httptest.NewRecorder()
+				endpoint.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/"+tt.query, nil))
+
+				if recorder.Code != tt.expectStatus {
+					t.Errorf("Expected HTTP %d, got %d", tt.expectStatus, recorder.Code)
+				}
+				if header := recorder.Header().Get(schemaVersionHeader); header != "1" {
+					t.Errorf("Expected schema version header 1, got %q", header)
+				}
+				var body struct {
+					SchemaVersion int `json:"schema_version"`
+				}
+				if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+					t.Fatalf("Failed to decode response: %v", err)
+				}
+				if body.SchemaVersion != endpoint.version {
+					t.Errorf("Expected schema_version %d, got %d", endpoint.version, body.SchemaVersion)
+				}
+			})
+		}
+	}
+}
+
+func TestStatusDeprecations(t *testing.T) {
+	original := metricsDeprecations
+	t.Cleanup(func() { metricsDeprecations = original })
+	metricsDeprecations = []Deprecation{{Field: "sinks.*.last_write_time", Replacement: "sinks.*.last_write", RemovedIn: 2}}
+
+	recorder := httptest.NewRecorder()
+	NewMetricsCollector().ServeMetrics().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	var body Metrics
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Deprecations) != 1 || body.Deprecations[0] != metricsDeprecations[0] {
+		t.Errorf("Expected the deprecation to be listed, got %+v", body.Deprecations)
+	}
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// SelfTestCheck is the outcome of one startup self-test step
+type SelfTestCheck struct {
+	Component string // e.g. "provider_ecobee", "sink_elasticsearch"
+	Check     string
+	Err       error
+}
+
+// SelfTestReport collects the results of a startup self-test
+type SelfTestReport struct {
+	Checks []SelfTestCheck
+}
+
+// Failed reports whether any check failed
+func (r SelfTestReport) Failed() bool {
+	for _, check := range r.Checks {
+		if check.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats the report as one line per check for diagnostics
+func (r SelfTestReport) String() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		status, detail := "PASS", ""
+		if check.Err != nil {
+			status, detail = "FAIL", ": "+check.Err.Error()
+		}
+		fmt.Fprintf(&b, "[%s] %s %s%s\n", status, check.Component, check.Check, detail)
+	}
+	return b.String()
+}
+
+// SelfTest verifies every provider and sink before the service reports ready.
+// Providers must authenticate and list at least one thermostat; sinks must
+// open, which creates templates where configured, and accept an empty write.
+// All components are checked so the report lists every problem at once.
+func SelfTest(ctx context.Context, providers []model.Provider, sinks []model.Sink) SelfTestReport {
+	var report SelfTestReport
+
+	for _, provider := range providers {
+		component := fmt.Sprintf("provider_%s", provider.Info().Name)
+
+		authErr := selfTestAuth(ctx, provider.Auth())
+		report.Checks = append(report.Checks, SelfTestCheck{Component: component, Check: "authentication", Err: authErr})
+		if authErr != nil {
+			continue
+		}
+
+		thermostats, err := provider.ListThermostats(ctx)
+		if err == nil && len(thermostats) == 0 {
+			err = fmt.Errorf("no thermostats found")
+		}
+		report.Checks = append(report.Checks, SelfTestCheck{Component: component, Check: "list thermostats", Err: err})
+	}
+
+	for _, sink := range sinks {
+		component := fmt.Sprintf("sink_%s", sink.Info().Name)
+
+		openErr := sink.Open(ctx)
+		report.Checks = append(report.Checks, SelfTestCheck{Component: component, Check: "open", Err: openErr})
+		if openErr != nil {
+			continue
+		}
+
+		_, err := sink.Write(ctx, nil)
+		report.Checks = append(report.Checks, SelfTestCheck{Component: component, Check: "no-op write", Err: err})
+	}
+
+	return report
+}
+
+// selfTestAuth ensures the provider holds a valid token, refreshing it if needed
+func selfTestAuth(ctx context.Context, auth model.AuthManager) error {
+	if auth.IsTokenValid(ctx) {
+		return nil
+	}
+	if err := auth.RefreshToken(ctx); err != nil {
+		return fmt.Errorf("refreshing token: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestSelfTest(t *testing.T) {
+	tests := []struct {
+		name       string
+		provider   *mockProvider
+		sink       *mockSink
+		wantFailed bool
+		wantInfo   string
+	}{
+		{
+			name:     "all components pass",
+			provider: &mockProvider{name: "ecobee", tokenValid: true},
+			sink:     &mockSink{name: "elasticsearch"},
+			wantInfo: "[PASS] sink_elasticsearch no-op write",
+		},
+		{
+			name:       "provider auth fails",
+			provider:   &mockProvider{name: "ecobee", refreshFails: true},
+			sink:       &mockSink{name: "elasticsearch"},
+			wantFailed: true,
+			wantInfo:   "[FAIL] provider_ecobee authentication",
+		},
+		{
+			name:       "provider cannot list thermostats",
+			provider:   &mockProvider{name: "ecobee", tokenValid: true, shouldFail: true},
+			sink:       &mockSink{name: "elasticsearch"},
+			wantFailed: true,
+			wantInfo:   "[FAIL] provider_ecobee list thermostats",
+		},
+		{
+			name:       "sink fails to open",
+			provider:   &mockProvider{name: "ecobee", tokenValid: true},
+			sink:       &mockSink{name: "elasticsearch", shouldFail: true},
+			wantFailed: true,
+			wantInfo:   "[FAIL] sink_elasticsearch open",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := SelfTest(context.Background(), []model.Provider{tt.provider}, []model.Sink{tt.sink})
+
+			if report.Failed() != tt.wantFailed {
+				t.Errorf("Expected failed=%v, got report:\n%s", tt.wantFailed, report)
+			}
+			if !strings.Contains(report.String(), tt.wantInfo) {
+				t.Errorf("Expected report to contain %q, got:\n%s", tt.wantInfo, report)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// WithSettlingDelay holds back the named provider's runtime rows until their
+// bin started at least delay ago. Providers that revise their latest
+// intervals on later polls would otherwise have the first, incomplete values
+// frozen under the bin's document ID.
+func WithSettlingDelay(provider string, delay time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if delay <= 0 {
+			return
+		}
+		s.settlingDelays[provider] = delay
+	}
+}
+
+// settledRows drops runtime rows still inside the provider's settling delay.
+// The runtime offset stops before them, so the next poll fetches them again.
+func (s *Scheduler) settledRows(provider string, thermostatID string, rows []model.RuntimeRow, now time.Time) []model.RuntimeRow {
+	delay, ok := s.settlingDelays[provider]
+	if !ok {
+		return rows
+	}
+
+	cutoff := now.Add(-delay)
+	settled := make([]model.RuntimeRow, 0, len(rows))
+	for _, row := range rows {
+		if row.EventTime.After(cutoff) {
+			continue
+		}
+		settled = append(settled, row)
+	}
+	if held := len(rows) - len(settled); held > 0 {
+		s.logger.Debug("Holding back unsettled runtime rows",
+			"thermostat", thermostatID,
+			"rows", held,
+			"settling_delay", delay)
+	}
+	return settled
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestSettlingDelay(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "ecobee"}
+	now := time.Now().Truncate(5 * time.Minute)
+	rows := func(ages ...time.Duration) []model.RuntimeRow {
+		var result []model.RuntimeRow
+		for _, age := range ages {
+			result = append(result, model.RuntimeRow{ThermostatRef: thermostat, EventTime: now.Add(-age), Mode: "heat", AvgTempC: floatPtr(20.0)})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name         string
+		delay        time.Duration
+		rows         []model.RuntimeRow
+		expectDocs   int
+		expectOffset time.Time // zero when no offset is stored
+	}{
+		{name: "no delay writes every row", rows: rows(20*time.Minute, 10*time.Minute, 0), expectDocs: 3, expectOffset: now},
+		{name: "recent rows are held back", delay: 15 * time.Minute, rows: rows(25*time.Minute, 20*time.Minute, 10*time.Minute, 5*time.Minute), expectDocs: 2, expectOffset: now.Add(-20 * time.Minute)},
+		{name: "only unsettled rows leave the offset alone", delay: 15 * time.Minute, rows: rows(10*time.Minute, 5*time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{mockSink: mockSink{name: "test"}}
+			store := NewMemoryOffsetStore()
+			scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, sink, store, WithSettlingDelay("ecobee", tt.delay))
+
+			if err := scheduler.processRuntime(testContext(t), &mockProvider{name: "ecobee"}, thermostat, tt.rows); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			runtimeDocs := 0
+			for _, doc := range sink.docs {
+				if doc.Type == "runtime_5m" {
+					runtimeDocs++
+				}
+			}
+			if runtimeDocs != tt.expectDocs {
+				t.Errorf("Expected %d runtime documents, got %d", tt.expectDocs, runtimeDocs)
+			}
+
+			offset, err := store.GetLastRuntimeTime(testContext(t), thermostat.ID)
+			if err != nil {
+				t.Fatalf("Failed to read offset: %v", err)
+			}
+			if !offset.Equal(tt.expectOffset) {
+				t.Errorf("Expected offset %v, got %v", tt.expectOffset, offset)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// batchSizeBuckets are the upper bounds of the documents-per-write histogram.
+// Polls write a handful of documents while backfills write whole pages, so
+// the buckets span single documents to the largest bulk requests.
+var batchSizeBuckets = []int{1, 10, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// writeQuantiles are the sink write duration percentiles reported
+var writeQuantiles = []float64{0.5, 0.9, 0.99}
+
+// writeDurationWindow is how many recent writes the duration percentiles
+// are computed over
+const writeDurationWindow = 1024
+
+// sinkBatchStats accumulates the batches written to one sink
+type sinkBatchStats struct {
+	sizes        []int64 // one per batchSizeBuckets entry, plus +Inf; non-cumulative
+	documents    int64
+	payloadBytes int64
+	sentBytes    int64
+
+	durations   []time.Duration // ring of the most recent writes
+	next        int
+	durationSum float64 // seconds, over all writes
+	writes      int64
+}
+
+func newSinkBatchStats() *sinkBatchStats {
+	return &sinkBatchStats{sizes: make([]int64, len(batchSizeBuckets)+1)}
+}
+
+// observe adds one completed write
+func (b *sinkBatchStats) observe(documents int, duration time.Duration, payloadBytes, sentBytes int64) {
+	i, _ := slices.BinarySearch(batchSizeBuckets, documents)
+	b.sizes[i]++
+	b.documents += int64(documents)
+	b.payloadBytes += payloadBytes
+	b.sentBytes += sentBytes
+
+	if len(b.durations) < writeDurationWindow {
+		b.durations = append(b.durations, duration)
+	} else {
+		b.durations[b.next] = duration
+		b.next = (b.next + 1) % writeDurationWindow
+	}
+	b.durationSum += duration.Seconds()
+	b.writes++
+}
+
+// snapshot returns the stats with cumulative size buckets and duration
+// percentiles over the recent window
+func (b *sinkBatchStats) snapshot() BatchMetrics {
+	snapshot := BatchMetrics{
+		SizeBuckets:        make(map[string]int64, len(b.sizes)),
+		Documents:          b.documents,
+		PayloadBytes:       b.payloadBytes,
+		SentBytes:          b.sentBytes,
+		WriteDurationSum:   b.durationSum,
+		WriteDurationCount: b.writes,
+	}
+	for i, count := range b.sizes {
+		snapshot.Count += count
+		snapshot.SizeBuckets[batchSizeLabel(i)] = snapshot.Count
+	}
+	if b.sentBytes > 0 {
+		snapshot.CompressionRatio = float64(b.payloadBytes) / float64(b.sentBytes)
+	}
+
+	if len(b.durations) > 0 {
+		sorted := slices.Clone(b.durations)
+		slices.Sort(sorted)
+		snapshot.WriteDuration = make(map[string]float64, len(writeQuantiles))
+		for _, q := range writeQuantiles {
+			// Nearest rank, so small windows report their slowest write as
+			// the high percentiles rather than understating them
+			rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+			snapshot.WriteDuration[quantileLabel(q)] = sorted[max(rank, 0)].Seconds()
+		}
+	}
+	return snapshot
+}
+
+// batchSizeLabel returns the Prometheus "le" label for size bucket i
+func batchSizeLabel(i int) string {
+	if i == len(batchSizeBuckets) {
+		return "+Inf"
+	}
+	return strconv.Itoa(batchSizeBuckets[i])
+}
+
+// quantileLabel returns the Prometheus "quantile" label for q
+func quantileLabel(q float64) string {
+	return strconv.FormatFloat(q, 'g', -1, 64)
+}
+
+// BatchMetrics describes the writes a sink completed: how many documents
+// each carried, how many bytes they serialized to and sent, and how long the
+// sink took to acknowledge them
+type BatchMetrics struct {
+	SizeBuckets map[string]int64 `json:"size_buckets"` // keyed by upper bound in documents, or +Inf
+	Count       int64            `json:"count"`
+	Documents   int64            `json:"documents"`
+	// Byte counts are only reported by sinks that send request bodies;
+	// the ratio is payload over sent bytes, so 1 means uncompressed
+	PayloadBytes     int64   `json:"payload_bytes_total,omitempty"`
+	SentBytes        int64   `json:"sent_bytes_total,omitempty"`
+	CompressionRatio float64 `json:"compression_ratio,omitempty"`
+	// WriteDuration holds percentiles over the most recent writes, keyed by
+	// quantile; the sum and count cover every write
+	WriteDuration      map[string]float64 `json:"write_seconds,omitempty"`
+	WriteDurationSum   float64            `json:"write_seconds_sum"`
+	WriteDurationCount int64              `json:"write_seconds_count"`
+}
+
+// RecordSinkBatch records one completed sink write of documents, the time it
+// took and the bytes the sink reported serializing and sending
+func (m *MetricsCollector) RecordSinkBatch(sinkName string, documents int, duration time.Duration, payloadBytes, sentBytes int64) {
+	if documents == 0 {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats, ok := m.sinkBatches[sinkName]
+	if !ok {
+		stats = newSinkBatchStats()
+		m.sinkBatches[sinkName] = stats
+	}
+	stats.observe(documents, duration, payloadBytes, sentBytes)
+}
+
+// writeBatchMetrics writes the batch size histogram, byte counters,
+// compression ratio and write duration summary of each sink
+func writeBatchMetrics(w io.Writer, batches map[string]BatchMetrics) {
+	sinks := sortedKeys(batches)
+
+	const sizeName = "ttr_sink_batch_documents"
+	fmt.Fprintf(w, "# HELP %s Documents per sink write\n", sizeName)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", sizeName)
+	for _, name := range sinks {
+		batch := batches[name]
+		label := escapeLabel(name)
+		for i := range len(batchSizeBuckets) + 1 {
+			le := batchSizeLabel(i)
+			fmt.Fprintf(w, "%s_bucket{sink=\"%s\",le=\"%s\"} %d\n", sizeName, label, le, batch.SizeBuckets[le])
+		}
+		fmt.Fprintf(w, "%s_sum{sink=\"%s\"} %d\n", sizeName, label, batch.Documents)
+		fmt.Fprintf(w, "%s_count{sink=\"%s\"} %d\n", sizeName, label, batch.Count)
+	}
+
+	writeCounter(w, "ttr_sink_payload_bytes_total", "Serialized request bytes written to sinks, before compression", "sink", sinks, func(name string) int64 {
+		return batches[name].PayloadBytes
+	})
+	writeCounter(w, "ttr_sink_sent_bytes_total", "Request bytes sent to sinks, after compression", "sink", sinks, func(name string) int64 {
+		return batches[name].SentBytes
+	})
+
+	fmt.Fprintf(w, "# HELP ttr_sink_compression_ratio Payload bytes over sent bytes, for sinks that report them\n")
+	fmt.Fprintf(w, "# TYPE ttr_sink_compression_ratio gauge\n")
+	for _, name := range sinks {
+		if ratio := batches[name].CompressionRatio; ratio > 0 {
+			fmt.Fprintf(w, "ttr_sink_compression_ratio{sink=\"%s\"} %g\n", escapeLabel(name), ratio)
+		}
+	}
+
+	const durationName = "ttr_sink_write_duration_seconds"
+	fmt.Fprintf(w, "# HELP %s Time for a sink to acknowledge a write, over the last %d writes\n", durationName, writeDurationWindow)
+	fmt.Fprintf(w, "# TYPE %s summary\n", durationName)
+	for _, name := range sinks {
+		batch := batches[name]
+		label := escapeLabel(name)
+		for _, q := range writeQuantiles {
+			quantile := quantileLabel(q)
+			fmt.Fprintf(w, "%s{sink=\"%s\",quantile=\"%s\"} %g\n", durationName, label, quantile, batch.WriteDuration[quantile])
+		}
+		fmt.Fprintf(w, "%s_sum{sink=\"%s\"} %g\n", durationName, label, batch.WriteDurationSum)
+		fmt.Fprintf(w, "%s_count{sink=\"%s\"} %d\n", durationName, label, batch.WriteDurationCount)
+	}
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// byteReportingSink reports fixed payload and sent byte counts for each write
+type byteReportingSink struct {
+	mockSink
+}
+
+func (s *byteReportingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	return model.WriteResult{SuccessCount: len(docs), PayloadBytes: 400, SentBytes: 100}, nil
+}
+
+func TestSinkBatches(t *testing.T) {
+	metrics := NewMetricsCollector()
+	for _, write := range []struct {
+		documents int
+		duration  time.Duration
+	}{
+		{documents: 1, duration: 10 * time.Millisecond},
+		{documents: 40, duration: 20 * time.Millisecond},
+		{documents: 40, duration: 30 * time.Millisecond},
+		{documents: 8000, duration: 2 * time.Second},
+	} {
+		metrics.RecordSinkWrite("es", int64(write.documents))
+		metrics.RecordSinkBatch("es", write.documents, write.duration, int64(write.documents)*300, int64(write.documents)*100)
+	}
+	metrics.RecordSinkBatch("es", 0, time.Second, 0, 0)
+
+	batches := metrics.GetMetrics().Sinks["es"].Batches
+	if batches == nil {
+		t.Fatal("Expected batch metrics in sink metrics")
+	}
+	if batches.Count != 4 || batches.Documents != 8081 {
+		t.Errorf("Expected 4 batches of 8081 documents, got %+v", batches)
+	}
+	if batches.SizeBuckets["1"] != 1 || batches.SizeBuckets["50"] != 3 || batches.SizeBuckets["5000"] != 3 || batches.SizeBuckets["+Inf"] != 4 {
+		t.Errorf("Unexpected size buckets: %v", batches.SizeBuckets)
+	}
+	if batches.CompressionRatio != 3 {
+		t.Errorf("Expected a compression ratio of 3, got %g", batches.CompressionRatio)
+	}
+	if batches.WriteDuration["0.5"] != 0.02 || batches.WriteDuration["0.99"] != 2 {
+		t.Errorf("Unexpected write duration percentiles: %v", batches.WriteDuration)
+	}
+
+	recorder := httptest.NewRecorder()
+	metrics.ServePrometheus().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics/prometheus", nil))
+	body := recorder.Body.String()
+	for _, line := range []string{
+		"# TYPE ttr_sink_batch_documents histogram",
+		`ttr_sink_batch_documents_bucket{sink="es",le="10"} 1`,
+		`ttr_sink_batch_documents_bucket{sink="es",le="+Inf"} 4`,
+		`ttr_sink_batch_documents_sum{sink="es"} 8081`,
+		`ttr_sink_payload_bytes_total{sink="es"} 2424300`,
+		`ttr_sink_sent_bytes_total{sink="es"} 808100`,
+		`ttr_sink_compression_ratio{sink="es"} 3`,
+		"# TYPE ttr_sink_write_duration_seconds summary",
+		`ttr_sink_write_duration_seconds{sink="es",quantile="0.9"} 2`,
+		`ttr_sink_write_duration_seconds_count{sink="es"} 4`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("Expected %q in output:\n%s", line, body)
+		}
+	}
+}
+
+func TestSinkBatchDurationWindow(t *testing.T) {
+	stats := newSinkBatchStats()
+	for range writeDurationWindow {
+		stats.observe(1, time.Second, 0, 0)
+	}
+	for range writeDurationWindow {
+		stats.observe(1, time.Millisecond, 0, 0)
+	}
+
+	snapshot := stats.snapshot()
+	if snapshot.WriteDuration["0.99"] != 0.001 {
+		t.Errorf("Expected percentiles over the most recent writes only, got %v", snapshot.WriteDuration)
+	}
+	if snapshot.WriteDurationCount != 2*writeDurationWindow {
+		t.Errorf("Expected every write counted, got %d", snapshot.WriteDurationCount)
+	}
+	if snapshot.CompressionRatio != 0 {
+		t.Errorf("Expected no compression ratio without byte counts, got %g", snapshot.CompressionRatio)
+	}
+}
+
+func TestWriteBatchRecordsBatches(t *testing.T) {
+	ctx := testContext(t)
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &byteReportingSink{mockSink: mockSink{name: "es"}}, NewMemoryOffsetStore())
+	docs := []model.Doc{{ID: "a", Type: "transition"}, {ID: "b", Type: "transition"}}
+
+	if !scheduler.writeBatch(ctx, docs) {
+		t.Fatal("Expected a clean write")
+	}
+	batches := scheduler.metrics.GetMetrics().Sinks["es"].Batches
+	if batches == nil || batches.Count != 1 || batches.Documents != 2 || batches.PayloadBytes != 400 || batches.SentBytes != 100 {
+		t.Errorf("Expected one batch of 2 documents and its byte counts, got %+v", batches)
+	}
+}
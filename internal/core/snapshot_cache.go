@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// snapshotCacheEntry is the last snapshot response fetched for a thermostat
+type snapshotCacheEntry struct {
+	revision  string
+	snapshot  model.Snapshot
+	fetchedAt time.Time
+}
+
+// WithSnapshotCache reuses the named provider's last snapshot response for a
+// thermostat instead of requesting a new one while the thermostat's summary
+// revision is unchanged and the response is younger than maxAge. The revision
+// changes with the thermostat's settings, program and events, which is what a
+// snapshot holds, so a cached response is what the provider would return.
+func WithSnapshotCache(provider string, maxAge time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if maxAge <= 0 {
+			return
+		}
+		s.snapshotMaxAge[provider] = maxAge
+	}
+}
+
+// RecordSnapshotCache records a snapshot cache lookup for a provider
+func (m *MetricsCollector) RecordSnapshotCache(providerName string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if hit {
+		m.snapshotCacheHits[providerName]++
+	} else {
+		m.snapshotCacheMisses[providerName]++
+	}
+}
+
+// getSnapshot returns a thermostat's snapshot, from the cache when its
+// revision is unchanged and the cached response is fresh, otherwise from the
+// provider. A cached snapshot is collected again at now.
+func (s *Scheduler) getSnapshot(ctx context.Context, provider model.Provider, thermostat model.ThermostatRef, revision string, now time.Time) (model.Snapshot, error) {
+	name := provider.Info().Name
+	maxAge := s.snapshotMaxAge[name]
+	if maxAge > 0 && revision != "" {
+		entry, ok := s.snapshots[thermostat.ID]
+		hit := ok && entry.revision == revision && now.Sub(entry.fetchedAt) < maxAge
+		s.metrics.RecordSnapshotCache(name, hit)
+		if hit {
+			s.logger.Debug("Using cached snapshot", "thermostat", thermostat.ID, "revision", revision)
+			snapshot := entry.snapshot
+			snapshot.CollectedAt = now
+			return snapshot, nil
+		}
+	}
+
+	s.recordProviderRequest(name)
+	callCtx, cancel := s.providerContext(ctx, name)
+	snapshot, err := provider.GetSnapshot(callCtx, thermostat, time.Time{})
+	cancel()
+	if err != nil {
+		s.metrics.RecordProviderError(name)
+		delete(s.snapshots, thermostat.ID)
+		return model.Snapshot{}, err
+	}
+	s.metrics.RecordSnapshotFetched(name)
+	if maxAge > 0 && revision != "" {
+		s.snapshots[thermostat.ID] = snapshotCacheEntry{revision: revision, snapshot: snapshot, fetchedAt: now}
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// snapshotCountingProvider counts snapshot requests
+type snapshotCountingProvider struct {
+	mockProvider
+	snapshotCalls int
+}
+
+func (p *snapshotCountingProvider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	p.snapshotCalls++
+	return model.Snapshot{ThermostatRef: tr, CollectedAt: time.Now(), FirmwareVersion: "4.8.7.132"}, nil
+}
+
+func TestSnapshotCache(t *testing.T) {
+	ctx := testContext(t)
+	provider := &snapshotCountingProvider{mockProvider: mockProvider{name: "ecobee"}}
+	scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithSnapshotCache("ecobee", time.Hour))
+	thermostat := model.ThermostatRef{ID: "therm-1", Provider: "ecobee"}
+	start := time.Now()
+
+	tests := []struct {
+		name          string
+		revision      string
+		at            time.Duration
+		expectedCalls int
+	}{
+		{name: "first fetch misses", revision: "rev-1", at: 0, expectedCalls: 1},
+		{name: "unchanged revision hits", revision: "rev-1", at: 15 * time.Minute, expectedCalls: 1},
+		{name: "changed revision misses", revision: "rev-2", at: 30 * time.Minute, expectedCalls: 2},
+		{name: "expired entry misses", revision: "rev-2", at: 90 * time.Minute, expectedCalls: 3},
+		{name: "refreshed entry hits", revision: "rev-2", at: 105 * time.Minute, expectedCalls: 3},
+	}
+	for _, tt := range tests {
+		now := start.Add(tt.at)
+		snapshot, err := scheduler.getSnapshot(ctx, provider, thermostat, tt.revision, now)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if provider.snapshotCalls != tt.expectedCalls {
+			t.Errorf("%s: expected %d snapshot requests, got %d", tt.name, tt.expectedCalls, provider.snapshotCalls)
+		}
+		if provider.snapshotCalls == tt.expectedCalls && snapshot.FirmwareVersion != "4.8.7.132" {
+			t.Errorf("%s: expected the provider's snapshot, got %+v", tt.name, snapshot)
+		}
+	}
+
+	cached, err := scheduler.getSnapshot(ctx, provider, thermostat, "rev-2", start.Add(110*time.Minute))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !cached.CollectedAt.Equal(start.Add(110 * time.Minute)) {
+		t.Errorf("Expected a cached snapshot to be collected again, got %v", cached.CollectedAt)
+	}
+
+	metrics := scheduler.metrics.GetMetrics().Providers["ecobee"]
+	if metrics.SnapshotCacheHits != 3 || metrics.SnapshotCacheMisses != 3 || metrics.RequestsTotal != 3 {
+		t.Errorf("Expected 3 hits, 3 misses and 3 requests, got %+v", metrics)
+	}
+}
+
+func TestSnapshotCacheDisabled(t *testing.T) {
+	ctx := testContext(t)
+	provider := &snapshotCountingProvider{mockProvider: mockProvider{name: "ecobee"}}
+	scheduler := newTestScheduler(provider, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithSnapshotCache("nest", time.Hour))
+	thermostat := model.ThermostatRef{ID: "therm-1", Provider: "ecobee"}
+
+	for range 2 {
+		if _, err := scheduler.getSnapshot(ctx, provider, thermostat, "rev-1", time.Now()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if provider.snapshotCalls != 2 {
+		t.Errorf("Expected every snapshot to be requested without a cache, got %d requests", provider.snapshotCalls)
+	}
+	if metrics := scheduler.metrics.GetMetrics().Providers["ecobee"]; metrics.SnapshotCacheHits != 0 || metrics.SnapshotCacheMisses != 0 {
+		t.Errorf("Expected no cache lookups, got %+v", metrics)
+	}
+}
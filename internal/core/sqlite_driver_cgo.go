@@ -0,0 +1,11 @@
+//go:build !purego
+
+package core
+
+import (
+	_ "github.com/mattn/go-sqlite3" // SQLite driver
+)
+
+// sqliteDriver is the database/sql driver backing SQLiteOffsetStore. Build
+// with -tags purego to use a driver that does not need cgo.
+const sqliteDriver = "sqlite3"
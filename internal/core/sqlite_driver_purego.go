@@ -0,0 +1,11 @@
+//go:build purego
+
+package core
+
+import (
+	_ "modernc.org/sqlite" // Pure-Go SQLite driver
+)
+
+// sqliteDriver is the database/sql driver backing SQLiteOffsetStore. This
+// driver needs no cgo, so the store cross-compiles to ARM with CGO_ENABLED=0.
+const sqliteDriver = "sqlite"
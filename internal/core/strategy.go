@@ -0,0 +1,48 @@
+package core
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Strategy decides when the scheduler runs its next polling cycle.
+// Implementations live in internal/schedule.
+type Strategy interface {
+	// Name identifies the strategy in logs
+	Name() string
+
+	// Next returns when the next cycle should start. active reports whether
+	// any thermostat had heating or cooling running in its latest runtime row.
+	Next(now time.Time, active bool) time.Time
+}
+
+// WithStrategy replaces the default fixed poll interval with a scheduling strategy
+func WithStrategy(strategy Strategy) SchedulerOption {
+	return func(s *Scheduler) {
+		s.strategy = strategy
+	}
+}
+
+// recordActivity remembers whether heating or cooling equipment was running
+// in a thermostat's most recent runtime row. The fan alone does not count.
+func (s *Scheduler) recordActivity(row *model.Runtime5m) {
+	running := false
+	for key, on := range row.Equipment {
+		if on && key != "fan" {
+			running = true
+			break
+		}
+	}
+	s.activity[row.ThermostatID] = running
+}
+
+// equipmentActive reports whether any thermostat's latest runtime row had equipment running
+func (s *Scheduler) equipmentActive() bool {
+	for _, running := range s.activity {
+		if running {
+			return true
+		}
+	}
+	return false
+}
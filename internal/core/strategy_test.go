@@ -0,0 +1,68 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// stubStrategy records the activity flag it is given
+type stubStrategy struct {
+	delay      time.Duration
+	lastActive bool
+}
+
+func (s *stubStrategy) Name() string { return "stub" }
+
+func (s *stubStrategy) Next(now time.Time, active bool) time.Time {
+	s.lastActive = active
+	return now.Add(s.delay)
+}
+
+func TestStrategyReceivesEquipmentActivity(t *testing.T) {
+	strategy := &stubStrategy{delay: 2 * time.Minute}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithStrategy(strategy))
+
+	tests := []struct {
+		name     string
+		rows     []*model.Runtime5m
+		expected bool
+	}{
+		{
+			name:     "no data is idle",
+			expected: false,
+		},
+		{
+			name:     "fan alone is idle",
+			rows:     []*model.Runtime5m{{ThermostatID: "t1", Equipment: map[string]bool{"fan": true, "compHeat1": false}}},
+			expected: false,
+		},
+		{
+			name:     "heating on one thermostat is active",
+			rows:     []*model.Runtime5m{{ThermostatID: "t2", Equipment: map[string]bool{"compHeat1": true}}},
+			expected: true,
+		},
+		{
+			name:     "latest row replaces earlier activity",
+			rows:     []*model.Runtime5m{{ThermostatID: "t2", Equipment: map[string]bool{"compHeat1": false}}},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, row := range tt.rows {
+				scheduler.observeRuntime(row)
+			}
+
+			delay := scheduler.nextCycleDelay()
+			if strategy.lastActive != tt.expected {
+				t.Errorf("Expected active=%v, got %v", tt.expected, strategy.lastActive)
+			}
+			if delay <= time.Minute || delay > 2*time.Minute {
+				t.Errorf("Expected delay from strategy, got %v", delay)
+			}
+		})
+	}
+}
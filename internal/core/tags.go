@@ -0,0 +1,63 @@
+package core
+
+import (
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// WithThermostatTags adds each thermostat's configured tags, such as
+// floor: upstairs, to every written document about it under a "tags"
+// object, so dashboards can group by them without a lookup table. Tags are
+// keyed by thermostat ID; documents without a thermostat, such as household
+// analyses, carry none.
+func WithThermostatTags(tags map[string]map[string]string) SchedulerOption {
+	return func(s *Scheduler) {
+		s.tags = tags
+	}
+}
+
+// stampTags sets the tags of documents about a tagged thermostat. Like
+// ingest metadata, tags are added after document IDs are generated, so
+// changing them does not change IDs.
+func (s *Scheduler) stampTags(docs []model.Doc) {
+	if len(s.tags) == 0 {
+		return
+	}
+	for _, doc := range docs {
+		tags, ok := s.tags[documentThermostatID(doc.Body)]
+		if !ok {
+			continue
+		}
+		if stamper, ok := doc.Body.(model.TagStamper); ok {
+			stamper.SetTags(tags)
+		}
+	}
+}
+
+// documentThermostatID returns the ID of the thermostat a canonical document
+// is about, or "" for other documents
+func documentThermostatID(body any) string {
+	switch doc := body.(type) {
+	case *model.Runtime5m:
+		return doc.ThermostatID
+	case *model.RuntimeLive:
+		return doc.ThermostatID
+	case *model.Transition:
+		return doc.ThermostatID
+	case *model.DeviceSnapshot:
+		return doc.ThermostatID
+	case *model.DeviceMetadata:
+		return doc.ThermostatID
+	case *model.Analysis:
+		return doc.ThermostatID
+	case *model.Alert:
+		return doc.ThermostatID
+	case *model.Recommendation:
+		return doc.ThermostatID
+	case *model.FirmwareChange:
+		return doc.ThermostatID
+	case *model.Connectivity:
+		return doc.ThermostatID
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestStampTags(t *testing.T) {
+	upstairs := map[string]string{"floor": "upstairs", "zone": "bedrooms"}
+	scheduler := newTestScheduler(&mockProvider{name: "test"}, &recordingSink{mockSink: mockSink{name: "recording"}}, NewMemoryOffsetStore(),
+		WithThermostatTags(map[string]map[string]string{"t1": upstairs}))
+
+	runtime := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1"}
+	alert := &model.Alert{Type: "alert", ThermostatID: "t1"}
+	untagged := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t2"}
+	household := &model.Alert{Type: "alert", HouseholdID: "house-1"}
+	docs := []model.Doc{
+		{ID: "a", Type: "runtime_5m", Body: runtime},
+		{ID: "b", Type: "alert", Body: alert},
+		{ID: "c", Type: "runtime_5m", Body: untagged},
+		{ID: "d", Type: "alert", Body: household},
+	}
+	if err := scheduler.writeToAllSinks(testContext(t), docs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !maps.Equal(runtime.Tags, upstairs) || !maps.Equal(alert.Tags, upstairs) {
+		t.Errorf("Expected the thermostat's tags on its documents, got %v and %v", runtime.Tags, alert.Tags)
+	}
+	if untagged.Tags != nil || household.Tags != nil {
+		t.Errorf("Expected no tags on other documents, got %v and %v", untagged.Tags, household.Tags)
+	}
+}
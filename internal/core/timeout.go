@@ -0,0 +1,62 @@
+package core
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultProviderTimeout bounds one provider call when the provider's
+	// configuration sets no request_timeout
+	DefaultProviderTimeout = 2 * time.Minute
+
+	// DefaultSinkTimeout bounds one sink write when the sink's configuration
+	// sets no write_timeout
+	DefaultSinkTimeout = 2 * time.Minute
+)
+
+// WithProviderTimeout bounds each call to the named provider, including its
+// retries, so a hung connection fails that call instead of stalling the
+// poll cycle. Zero keeps DefaultProviderTimeout.
+func WithProviderTimeout(provider string, timeout time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if timeout <= 0 {
+			return
+		}
+		s.providerTimeouts[provider] = timeout
+	}
+}
+
+// WithSinkTimeout bounds each write to the named sink. Zero keeps
+// DefaultSinkTimeout.
+func WithSinkTimeout(sink string, timeout time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		if timeout <= 0 {
+			return
+		}
+		s.sinkTimeouts[sink] = timeout
+	}
+}
+
+// providerContext returns a context for one call to the named provider.
+// With fault injection, some calls get a context that has already timed out.
+func (s *Scheduler) providerContext(ctx context.Context, provider string) (context.Context, context.CancelFunc) {
+	if s.faults.failsProviderCall() {
+		s.logger.Debug("Injecting provider fault", "provider", provider)
+		return context.WithDeadlineCause(ctx, time.Now(), ErrInjectedFault)
+	}
+	timeout, ok := s.providerTimeouts[provider]
+	if !ok {
+		timeout = DefaultProviderTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// sinkContext returns a context for one write to the named sink
+func (s *Scheduler) sinkContext(ctx context.Context, sink string) (context.Context, context.CancelFunc) {
+	timeout, ok := s.sinkTimeouts[sink]
+	if !ok {
+		timeout = DefaultSinkTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// stalledProvider never answers runtime requests, like a stalled connection
+type stalledProvider struct {
+	mockProvider
+}
+
+func (p *stalledProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// stalledSink never finishes a write
+type stalledSink struct {
+	mockSink
+}
+
+func (s *stalledSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	<-ctx.Done()
+	return model.WriteResult{}, ctx.Err()
+}
+
+func TestProviderTimeout(t *testing.T) {
+	provider := &stalledProvider{mockProvider: mockProvider{name: "ecobee"}}
+	sink := &mockSink{name: "test"}
+	scheduler := newTestScheduler(provider, sink, NewMemoryOffsetStore(), WithProviderTimeout("ecobee", 20*time.Millisecond))
+
+	started := time.Now()
+	err := scheduler.fetchAndProcessRuntime(testContext(t), provider, model.ThermostatRef{ID: "t1", Provider: "ecobee"}, time.Now().Add(-time.Hour))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected the call to time out promptly, took %v", elapsed)
+	}
+}
+
+func TestSinkTimeout(t *testing.T) {
+	sink := &stalledSink{mockSink: mockSink{name: "stalled"}}
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, sink, NewMemoryOffsetStore(), WithSinkTimeout("stalled", 20*time.Millisecond))
+
+	started := time.Now()
+	if scheduler.writeBatch(testContext(t), []model.Doc{{ID: "d1", Type: "runtime_5m"}}) {
+		t.Error("Expected the timed out write to fail the batch")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("Expected the write to time out promptly, took %v", elapsed)
+	}
+}
+
+func TestDefaultTimeouts(t *testing.T) {
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "test"}, NewMemoryOffsetStore(), WithProviderTimeout("ecobee", 0))
+
+	for name, newContext := range map[string]func(context.Context, string) (context.Context, context.CancelFunc){
+		"provider": scheduler.providerContext,
+		"sink":     scheduler.sinkContext,
+	} {
+		ctx, cancel := newContext(context.Background(), "unconfigured")
+		deadline, ok := ctx.Deadline()
+		cancel()
+		if !ok || time.Until(deadline) > 2*time.Minute || time.Until(deadline) < time.Minute {
+			t.Errorf("Expected the default %s deadline about 2m away, got %v", name, deadline)
+		}
+	}
+}
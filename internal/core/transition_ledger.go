@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// transitionLedgerRetention is how long emitted transitions are remembered,
+// by event time. It exceeds the default backfill window, which re-derives
+// every transition in the window after a restart.
+const transitionLedgerRetention = 31 * 24 * time.Hour
+
+// TransitionStore is implemented by offset stores that remember which
+// transition was emitted for a thermostat at each event time. A transition
+// ID hashes the previous and next state, so one derived again from a
+// different previous state, e.g. rows fetched in another grouping after a
+// restart, would otherwise be written as a second transition.
+type TransitionStore interface {
+	// EmittedTransitions returns the IDs of transitions already emitted for a
+	// thermostat, keyed by event time in Unix seconds
+	EmittedTransitions(ctx context.Context, thermostatID string, eventTimes []time.Time) (map[int64]string, error)
+
+	// RecordTransitions remembers emitted transition IDs by event time
+	RecordTransitions(ctx context.Context, thermostatID string, transitions map[int64]string) error
+}
+
+// suppressConflictingTransitions drops transition documents for event times
+// at which a transition with a different ID was already emitted
+func (s *Scheduler) suppressConflictingTransitions(ctx context.Context, thermostatID string, docs []model.Doc) []model.Doc {
+	store, ok := s.offsetStore.(TransitionStore)
+	if !ok {
+		return docs
+	}
+
+	var eventTimes []time.Time
+	for _, doc := range docs {
+		if transition, ok := doc.Body.(*model.Transition); ok {
+			eventTimes = append(eventTimes, transition.EventTime)
+		}
+	}
+	if len(eventTimes) == 0 {
+		return docs
+	}
+
+	emitted, err := store.EmittedTransitions(ctx, thermostatID, eventTimes)
+	if err != nil {
+		s.logger.Warn("Failed to read emitted transitions, writing all", "thermostat", thermostatID, "error", err)
+		return docs
+	}
+
+	kept := docs[:0:0]
+	for _, doc := range docs {
+		if transition, ok := doc.Body.(*model.Transition); ok {
+			if id, seen := emitted[transition.EventTime.Unix()]; seen && id != doc.ID {
+				s.logger.Debug("Suppressing duplicate transition",
+					"thermostat", thermostatID,
+					"event_time", transition.EventTime,
+					"emitted_id", id)
+				continue
+			}
+		}
+		kept = append(kept, doc)
+	}
+	return kept
+}
+
+// recordTransitions remembers the transitions in docs once they are written
+func (s *Scheduler) recordTransitions(ctx context.Context, thermostatID string, docs []model.Doc) {
+	store, ok := s.offsetStore.(TransitionStore)
+	if !ok {
+		return
+	}
+
+	transitions := make(map[int64]string)
+	for _, doc := range docs {
+		if transition, ok := doc.Body.(*model.Transition); ok {
+			transitions[transition.EventTime.Unix()] = doc.ID
+		}
+	}
+	if len(transitions) == 0 {
+		return
+	}
+	if err := store.RecordTransitions(ctx, thermostatID, transitions); err != nil {
+		s.logger.Warn("Failed to record emitted transitions", "thermostat", thermostatID, "error", err)
+	}
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestTransitionsAcrossRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offsets.db")
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "test"}
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	row := func(i int, mode string) model.RuntimeRow {
+		return model.RuntimeRow{
+			ThermostatRef: thermostat,
+			EventTime:     start.Add(time.Duration(i) * 5 * time.Minute),
+			Mode:          mode,
+			AvgTempC:      floatPtr(20.0),
+		}
+	}
+
+	// run processes rows with a fresh scheduler and store, as after a restart,
+	// and returns the transition documents written
+	run := func(t *testing.T, rows ...model.RuntimeRow) []*model.Transition {
+		t.Helper()
+		store, err := NewSQLiteOffsetStore(path)
+		if err != nil {
+			t.Fatalf("Failed to open offset store: %v", err)
+		}
+		defer func() {
+			_ = store.Close()
+		}()
+		sink := &recordingSink{mockSink: mockSink{name: "test"}}
+		provider := &mockProvider{name: "test"}
+		scheduler := newTestScheduler(provider, sink, store)
+		if err := scheduler.processRuntime(testContext(t), provider, thermostat, rows); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		var transitions []*model.Transition
+		for _, doc := range sink.docs {
+			if transition, ok := doc.Body.(*model.Transition); ok {
+				transitions = append(transitions, transition)
+			}
+		}
+		return transitions
+	}
+
+	run(t, row(0, "heat"), row(1, "heat"))
+
+	t.Run("first row after restart is compared with the stored state", func(t *testing.T) {
+		transitions := run(t, row(2, "cool"))
+		if len(transitions) != 1 || transitions[0].Prev.Mode != "heat" || transitions[0].Next.Mode != "cool" {
+			t.Errorf("Expected one heat to cool transition, got %+v", transitions)
+		}
+	})
+
+	t.Run("transition derived again from another previous state is suppressed", func(t *testing.T) {
+		// Re-fetched rows where the row before the change now reads "off"
+		// would yield an off to cool transition at the same event time
+		if transitions := run(t, row(1, "off"), row(2, "cool")); len(transitions) != 0 {
+			t.Errorf("Expected no transitions, got %+v", transitions)
+		}
+	})
+}
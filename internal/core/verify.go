@@ -0,0 +1,202 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// WithWriteVerification reads back one sampled document after every complete
+// write to the named sinks and checks it was stored as written. Outcomes are
+// recorded as sink verification metrics, so routing or lifecycle policies
+// that silently send documents elsewhere show up as failures.
+func WithWriteVerification(sinkNames ...string) SchedulerOption {
+	return func(s *Scheduler) {
+		if s.verify == nil {
+			s.verify = make(map[string]bool, len(sinkNames))
+		}
+		for _, name := range sinkNames {
+			s.verify[name] = true
+		}
+	}
+}
+
+// docTransformer is implemented by sinks that rewrite documents before the
+// sink they wrap stores them, such as pipeline.Sink
+type docTransformer interface {
+	Apply(doc model.Doc) (model.Doc, bool, error)
+}
+
+// readBack is the path from a configured sink to the sink that stores its
+// documents
+type readBack struct {
+	reader       model.DocumentReader
+	filters      []docTypeFilter
+	transformers []docTransformer
+}
+
+// resolveReadBack unwraps sink until it finds a model.DocumentReader,
+// collecting the filters and transforms documents pass through on the way
+func resolveReadBack(sink model.Sink) (readBack, bool) {
+	var path readBack
+	for {
+		if reader, ok := sink.(model.DocumentReader); ok {
+			path.reader = reader
+			return path, true
+		}
+		if filter, ok := sink.(docTypeFilter); ok {
+			path.filters = append(path.filters, filter)
+		}
+		if transformer, ok := sink.(docTransformer); ok {
+			path.transformers = append(path.transformers, transformer)
+		}
+		wrapped, ok := sink.(wrappedSink)
+		if !ok {
+			return readBack{}, false
+		}
+		sink = wrapped.Unwrap()
+	}
+}
+
+// SupportsWriteVerification reports whether a sink, or a sink it wraps, can
+// read back the documents it stores
+func SupportsWriteVerification(sink model.Sink) bool {
+	_, ok := resolveReadBack(sink)
+	return ok
+}
+
+// stored returns doc as the storing sink received it, or false if a filter
+// or transform kept it from being stored
+func (p readBack) stored(doc model.Doc) (model.Doc, bool) {
+	for _, filter := range p.filters {
+		if !filter.Accepts(doc.Type) {
+			return doc, false
+		}
+	}
+	for _, transformer := range p.transformers {
+		var keep bool
+		var err error
+		doc, keep, err = transformer.Apply(doc)
+		if err != nil || !keep {
+			return doc, false
+		}
+	}
+	return doc, true
+}
+
+// sample picks a random document from docs that reached the storing sink
+func (p readBack) sample(docs []model.Doc) (model.Doc, bool) {
+	if len(docs) == 0 {
+		return model.Doc{}, false
+	}
+	// #nosec G404 - Non-cryptographic random is sufficient for sampling
+	start := rand.IntN(len(docs))
+	for i := range docs {
+		if doc, ok := p.stored(docs[(start+i)%len(docs)]); ok {
+			return doc, true
+		}
+	}
+	return model.Doc{}, false
+}
+
+// verifyWrite reads back one document of a complete write, if verification
+// is enabled for the sink, and records the outcome
+func (s *Scheduler) verifyWrite(ctx context.Context, sink model.Sink, docs []model.Doc) {
+	name := sink.Info().Name
+	if !s.verify[name] {
+		return
+	}
+	path, ok := resolveReadBack(sink)
+	if !ok {
+		return
+	}
+	doc, ok := path.sample(docs)
+	if !ok {
+		return
+	}
+
+	err := checkStoredDocument(ctx, path.reader, doc)
+	s.metrics.RecordSinkVerification(name, err == nil)
+	if err != nil {
+		s.logger.Warn("Write verification failed",
+			"sink", name,
+			"id", doc.ID,
+			"type", doc.Type,
+			"error", err)
+	}
+}
+
+// checkStoredDocument reads doc back and checks it holds what was written
+func checkStoredDocument(ctx context.Context, reader model.DocumentReader, doc model.Doc) error {
+	data, found, err := reader.ReadDocument(ctx, doc)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("document not found after a successful write")
+	}
+
+	written, err := json.Marshal(doc.Body)
+	if err != nil {
+		return fmt.Errorf("marshaling written document: %w", err)
+	}
+	var want, got any
+	if err := json.Unmarshal(written, &want); err != nil {
+		return fmt.Errorf("decoding written document: %w", err)
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		return fmt.Errorf("decoding stored document: %w", err)
+	}
+	if !containsJSON(got, want) {
+		return fmt.Errorf("stored document differs from the one written")
+	}
+	return nil
+}
+
+// containsJSON reports whether the decoded JSON value got holds everything in
+// want. Objects may have extra fields, such as those added by an ingest
+// pipeline; arrays and scalars must match exactly.
+func containsJSON(got, want any) bool {
+	switch want := want.(type) {
+	case map[string]any:
+		fields, ok := got.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key, value := range want {
+			if stored, ok := fields[key]; !ok || !containsJSON(stored, value) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		items, ok := got.([]any)
+		if !ok || len(items) != len(want) {
+			return false
+		}
+		for i := range want {
+			if !containsJSON(items[i], want[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(got, want)
+	}
+}
+
+// RecordSinkVerification records the outcome of reading back a written
+// document
+func (m *MetricsCollector) RecordSinkVerification(sinkName string, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sinkVerifications[sinkName]++
+	if !ok {
+		m.sinkVerificationFailures[sinkName]++
+	}
+}
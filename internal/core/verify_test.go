@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/pipeline"
+)
+
+// readingSink stores written bodies as JSON and reads them back. lose drops
+// writes while reporting success, like an index that routes documents away;
+// enrich adds a field, like an ingest pipeline.
+type readingSink struct {
+	mockSink
+	lose   bool
+	enrich bool
+	stored map[string][]byte
+}
+
+func newReadingSink(name string) *readingSink {
+	return &readingSink{mockSink: mockSink{name: name}, stored: make(map[string][]byte)}
+}
+
+func (s *readingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	for _, doc := range docs {
+		if s.lose {
+			continue
+		}
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			return model.WriteResult{}, err
+		}
+		if s.enrich {
+			data = append([]byte(`{"enriched":true,`), data[1:]...)
+		}
+		s.stored[doc.ID] = data
+	}
+	return model.WriteResult{SuccessCount: len(docs)}, nil
+}
+
+func (s *readingSink) ReadDocument(ctx context.Context, doc model.Doc) ([]byte, bool, error) {
+	data, ok := s.stored[doc.ID]
+	return data, ok, nil
+}
+
+func TestWriteVerification(t *testing.T) {
+	docs := []model.Doc{
+		{ID: "r1", Type: "runtime_5m", Body: &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1", EventTime: time.Now()}},
+		{ID: "t1", Type: "transition", Body: &model.Transition{Type: "transition", ThermostatID: "t1", EventTime: time.Now()}},
+	}
+	tagged := func(sink model.Sink) model.Sink {
+		tags, err := pipeline.Build("add_tags", map[string]any{"tags": map[string]any{"site": "home"}})
+		if err != nil {
+			t.Fatalf("Failed to build transform: %v", err)
+		}
+		return pipeline.NewSink(sink, pipeline.Step{Name: "add_tags", Transform: tags})
+	}
+	corrupting := func(sink *readingSink) model.Sink {
+		return &corruptingSink{readingSink: sink}
+	}
+
+	tests := []struct {
+		name             string
+		sink             func(*readingSink) model.Sink
+		lose             bool
+		enrich           bool
+		enabled          bool
+		expectedTotal    int64
+		expectedFailures int64
+	}{
+		{name: "stored as written", sink: func(s *readingSink) model.Sink { return s }, enabled: true, expectedTotal: 1},
+		{name: "through transforms and routing", sink: func(s *readingSink) model.Sink {
+			return pipeline.NewRouter(tagged(s), []string{"transition"})
+		}, enabled: true, expectedTotal: 1},
+		{name: "fields added by the backend", sink: func(s *readingSink) model.Sink { return s }, enrich: true, enabled: true, expectedTotal: 1},
+		{name: "write lost", sink: func(s *readingSink) model.Sink { return s }, lose: true, enabled: true, expectedTotal: 1, expectedFailures: 1},
+		{name: "stored document differs", sink: corrupting, enabled: true, expectedTotal: 1, expectedFailures: 1},
+		{name: "not enabled", sink: func(s *readingSink) model.Sink { return s }},
+		{name: "sink cannot read", sink: func(s *readingSink) model.Sink { return &mockSink{name: "es"} }, enabled: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			storage := newReadingSink("es")
+			storage.lose = tt.lose
+			storage.enrich = tt.enrich
+			var opts []SchedulerOption
+			if tt.enabled {
+				opts = append(opts, WithWriteVerification("es"))
+			}
+			scheduler := newTestScheduler(&mockProvider{name: "test"}, tt.sink(storage), NewMemoryOffsetStore(), opts...)
+
+			if !scheduler.writeBatch(context.Background(), docs) {
+				t.Fatal("Expected a clean write")
+			}
+
+			metrics := scheduler.metrics.GetMetrics().Sinks["es"]
+			if metrics.Verifications != tt.expectedTotal || metrics.VerificationFailures != tt.expectedFailures {
+				t.Errorf("Expected %d verifications with %d failures, got %d with %d",
+					tt.expectedTotal, tt.expectedFailures, metrics.Verifications, metrics.VerificationFailures)
+			}
+		})
+	}
+}
+
+// corruptingSink stores every document with an extra field
+type corruptingSink struct {
+	*readingSink
+}
+
+func (s *corruptingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result, err := s.readingSink.Write(ctx, docs)
+	for id := range s.stored {
+		s.stored[id] = []byte(`{"unexpected":true}`)
+	}
+	return result, err
+}
+
+func TestSupportsWriteVerification(t *testing.T) {
+	if !SupportsWriteVerification(pipeline.NewRouter(newReadingSink("es"), nil)) {
+		t.Error("Expected a wrapped reading sink to support verification")
+	}
+	if SupportsWriteVerification(&mockSink{name: "csv"}) {
+		t.Error("Expected a sink without ReadDocument not to support verification")
+	}
+}
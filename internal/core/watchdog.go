@@ -0,0 +1,138 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/notify"
+)
+
+// WriteWatchdog notices the most common silent failure: polling cycles keep
+// running but no sink writes a single document
+type WriteWatchdog struct {
+	mu      sync.Mutex
+	after   time.Duration
+	started time.Time
+	// lastWrite is when any sink last wrote at least one document
+	lastWrite time.Time
+	// lastPoll is when the last polling cycle finished
+	lastPoll time.Time
+	// notifiedAt is when the current stall was notified; zero when none is
+	notifiedAt time.Time
+}
+
+// NewWriteWatchdog creates a watchdog that reports a stall after polls have
+// run for after without any document being written
+func NewWriteWatchdog(after time.Duration) *WriteWatchdog {
+	return &WriteWatchdog{after: after, started: time.Now()}
+}
+
+// WithWriteWatchdog makes the scheduler feed the watchdog its polling cycles
+// and writes, and send a notification when it stalls and when writes resume
+func WithWriteWatchdog(watchdog *WriteWatchdog) SchedulerOption {
+	return func(s *Scheduler) {
+		s.watchdog = watchdog
+	}
+}
+
+// WithWatchdogCheck adds a "watchdog" check to health that fails while the
+// watchdog reports a stall
+func WithWatchdogCheck(watchdog *WriteWatchdog) HealthOption {
+	return func(h *HealthChecker) {
+		h.watchdog = watchdog
+	}
+}
+
+// recordWrite notes that documents were written at
+func (w *WriteWatchdog) recordWrite(at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastWrite = at
+}
+
+// recordPoll notes that a polling cycle finished at
+func (w *WriteWatchdog) recordPoll(at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.lastPoll = at
+}
+
+// stalled reports whether, at now, nothing has been written for the
+// watchdog's period while polling cycles ran, and since when nothing has been
+// written
+func (w *WriteWatchdog) stalled(now time.Time) (bool, time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stalledLocked(now)
+}
+
+func (w *WriteWatchdog) stalledLocked(now time.Time) (bool, time.Time) {
+	quietSince := w.started
+	if w.lastWrite.After(quietSince) {
+		quietSince = w.lastWrite
+	}
+	stalled := now.Sub(quietSince) >= w.after &&
+		w.lastPoll.After(quietSince) && now.Sub(w.lastPoll) < w.after
+	return stalled, quietSince
+}
+
+// transition reports whether something should be notified at now: a new
+// stall, with when writes stopped, or writes resuming after a notified stall,
+// with when they resumed
+func (w *WriteWatchdog) transition(now time.Time) (stalled, changed bool, at time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.notifiedAt.IsZero() {
+		if w.lastWrite.After(w.notifiedAt) {
+			w.notifiedAt = time.Time{}
+			return false, true, w.lastWrite
+		}
+		return true, false, time.Time{}
+	}
+	stalled, quietSince := w.stalledLocked(now)
+	if stalled {
+		w.notifiedAt = now
+		return true, true, quietSince
+	}
+	return false, false, time.Time{}
+}
+
+// check returns the watchdog's health check result
+func (w *WriteWatchdog) check(now time.Time) CheckResult {
+	if stalled, quietSince := w.stalled(now); stalled {
+		return newCheckResult("fail", fmt.Sprintf("No documents written for %s although polls are running", now.Sub(quietSince).Round(time.Second)), 0)
+	}
+	return newCheckResult("pass", "Documents are being written", 0)
+}
+
+// checkWatchdog records a finished polling cycle and notifies when the
+// watchdog stalls or recovers
+func (s *Scheduler) checkWatchdog(now time.Time) {
+	if s.watchdog == nil {
+		return
+	}
+	s.watchdog.recordPoll(now)
+
+	stalled, changed, at := s.watchdog.transition(now)
+	if !changed {
+		return
+	}
+	if stalled {
+		s.logger.Error("No documents written although polls are running", "since", at)
+		s.notify(notify.Message{
+			Key:      "watchdog",
+			Title:    fmt.Sprintf("No documents written for %s", now.Sub(at).Round(time.Minute)),
+			Body:     "Polling cycles are running but no sink has written a document",
+			Priority: notify.PriorityHigh,
+		})
+		return
+	}
+	s.logger.Info("Documents are being written again")
+	s.notify(notify.Message{
+		Key:   "watchdog:recovered",
+		Title: "Documents are being written again",
+		Body:  fmt.Sprintf("Writes resumed at %s", at.Format(time.RFC3339)),
+	})
+}
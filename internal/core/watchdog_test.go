@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestWriteWatchdogStalled(t *testing.T) {
+	start := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		lastWrite time.Duration // since start; negative for never
+		lastPoll  time.Duration // since start; negative for never
+		now       time.Duration
+		expect    bool
+	}{
+		{name: "shortly after startup", lastWrite: -1, lastPoll: 10 * time.Minute, now: 20 * time.Minute},
+		{name: "nothing written since startup", lastWrite: -1, lastPoll: 55 * time.Minute, now: time.Hour, expect: true},
+		{name: "recent write", lastWrite: 50 * time.Minute, lastPoll: 55 * time.Minute, now: time.Hour},
+		{name: "old write with polls running", lastWrite: 10 * time.Minute, lastPoll: 75 * time.Minute, now: 80 * time.Minute, expect: true},
+		{name: "no polls since the last write", lastWrite: 10 * time.Minute, lastPoll: 5 * time.Minute, now: 2 * time.Hour},
+		{name: "polls stopped too", lastWrite: 10 * time.Minute, lastPoll: 20 * time.Minute, now: 3 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			watchdog := &WriteWatchdog{after: time.Hour, started: start}
+			if tt.lastWrite >= 0 {
+				watchdog.recordWrite(start.Add(tt.lastWrite))
+			}
+			if tt.lastPoll >= 0 {
+				watchdog.recordPoll(start.Add(tt.lastPoll))
+			}
+			if stalled, _ := watchdog.stalled(start.Add(tt.now)); stalled != tt.expect {
+				t.Errorf("Expected stalled %v, got %v", tt.expect, stalled)
+			}
+		})
+	}
+}
+
+func TestWriteWatchdogNotifies(t *testing.T) {
+	notifier := make(channelNotifier, 10)
+	watchdog := NewWriteWatchdog(time.Hour)
+	watchdog.started = time.Now().Add(-2 * time.Hour)
+	scheduler := newTestScheduler(&mockProvider{name: "ecobee"}, &mockSink{name: "es"}, NewMemoryOffsetStore(),
+		WithNotifier(notifier, time.Hour), WithWriteWatchdog(watchdog))
+
+	// A stall is notified once however many cycles it lasts
+	scheduler.checkWatchdog(time.Now())
+	scheduler.checkWatchdog(time.Now())
+	stall := expectNotifications(t, notifier, 1)
+	if stall[0].Key != "watchdog" || stall[0].Title != "No documents written for 2h0m0s" {
+		t.Errorf("Unexpected stall notification %+v", stall[0])
+	}
+
+	health := NewHealthChecker(nil, nil, WithWatchdogCheck(watchdog))
+	if status := health.CheckHealth(context.Background()); status.Status != "unhealthy" || status.Checks["watchdog"].Status != "fail" {
+		t.Errorf("Expected an unhealthy watchdog check, got %+v", status)
+	}
+
+	scheduler.writeBatch(testContext(t), []model.Doc{{ID: "d1", Type: "runtime_5m", Body: map[string]any{}}})
+	scheduler.checkWatchdog(time.Now())
+	recovered := expectNotifications(t, notifier, 1)
+	if recovered[0].Key != "watchdog:recovered" {
+		t.Errorf("Unexpected recovery notification %+v", recovered[0])
+	}
+	if status := health.CheckHealth(context.Background()); status.Checks["watchdog"].Status != "pass" {
+		t.Errorf("Expected the watchdog check to pass after a write, got %+v", status.Checks["watchdog"])
+	}
+}
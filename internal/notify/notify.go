@@ -0,0 +1,160 @@
+// Package notify sends operator notifications, such as sensor alerts and
+// lasting poll or write failures, to push services. Each channel has its own
+// rate limit so a flapping component cannot flood a phone.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTimeout bounds one delivery to a push service
+const requestTimeout = 10 * time.Second
+
+// Priority is how urgently a message should be delivered
+type Priority int
+
+const (
+	// PriorityNormal is for recoveries and informational messages
+	PriorityNormal Priority = iota
+	// PriorityHigh is for alerts and failures that need attention
+	PriorityHigh
+)
+
+// Message is one notification
+type Message struct {
+	// Key identifies what the message is about, e.g. "sink:elasticsearch";
+	// repeats of a key are rate limited together
+	Key      string
+	Title    string
+	Body     string
+	Priority Priority
+}
+
+// Notifier delivers messages to one push service
+type Notifier interface {
+	Notify(ctx context.Context, msg Message) error
+}
+
+// Limit rate limits one channel
+type Limit struct {
+	// RepeatInterval is how long further messages with the same key are
+	// dropped after one is sent
+	RepeatInterval time.Duration
+	// MaxPerHour caps the messages sent in any rolling hour; zero means no cap
+	MaxPerHour int
+}
+
+// channel is a notifier and its rate limit state
+type channel struct {
+	name     string
+	notifier Notifier
+	limit    Limit
+	lastSent map[string]time.Time
+	sent     []time.Time
+}
+
+// Dispatcher sends each message to every channel whose limit allows it
+type Dispatcher struct {
+	mu       sync.Mutex
+	channels []*channel
+	logger   *slog.Logger
+	now      func() time.Time
+}
+
+// NewDispatcher creates a dispatcher without channels
+func NewDispatcher(logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{logger: logger, now: time.Now}
+}
+
+// Add registers a channel under name with its rate limit
+func (d *Dispatcher) Add(name string, notifier Notifier, limit Limit) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.channels = append(d.channels, &channel{
+		name:     name,
+		notifier: notifier,
+		limit:    limit,
+		lastSent: make(map[string]time.Time),
+	})
+}
+
+// Len returns the number of channels
+func (d *Dispatcher) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.channels)
+}
+
+// Notify sends msg to every channel that is not rate limited. A message a
+// channel drops is not an error; failed deliveries are joined into the
+// returned error and still count against the limit.
+func (d *Dispatcher) Notify(ctx context.Context, msg Message) error {
+	var allowed []*channel
+	d.mu.Lock()
+	now := d.now()
+	for _, ch := range d.channels {
+		if ch.allow(msg.Key, now) {
+			allowed = append(allowed, ch)
+		} else {
+			d.logger.Debug("Notification rate limited", "channel", ch.name, "key", msg.Key)
+		}
+	}
+	d.mu.Unlock()
+
+	var errs []error
+	for _, ch := range allowed {
+		if err := ch.notifier.Notify(ctx, msg); err != nil {
+			errs = append(errs, fmt.Errorf("channel %s: %w", ch.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// allow reports whether a message with key may be sent at now, and records
+// it as sent if so
+func (c *channel) allow(key string, now time.Time) bool {
+	if last, ok := c.lastSent[key]; ok && now.Sub(last) < c.limit.RepeatInterval {
+		return false
+	}
+	if c.limit.MaxPerHour > 0 {
+		cutoff := now.Add(-time.Hour)
+		kept := c.sent[:0]
+		for _, at := range c.sent {
+			if at.After(cutoff) {
+				kept = append(kept, at)
+			}
+		}
+		c.sent = kept
+		if len(c.sent) >= c.limit.MaxPerHour {
+			return false
+		}
+		c.sent = append(c.sent, now)
+	}
+	c.lastSent[key] = now
+	return true
+}
+
+// post sends a request and returns an error for non-2xx responses
+func post(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("request failed with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
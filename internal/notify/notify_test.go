@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingNotifier records the messages it is asked to send
+type recordingNotifier struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, msg Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, msg)
+	return r.err
+}
+
+func TestDispatcherLimits(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	dispatcher := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	dispatcher.now = func() time.Time { return now }
+
+	phone := &recordingNotifier{}
+	chat := &recordingNotifier{}
+	dispatcher.Add("phone", phone, Limit{RepeatInterval: time.Hour, MaxPerHour: 2})
+	dispatcher.Add("chat", chat, Limit{})
+
+	steps := []struct {
+		advance time.Duration
+		key     string
+		phone   int
+		chat    int
+	}{
+		{key: "sink:es", phone: 1, chat: 1},
+		{advance: time.Minute, key: "sink:es", phone: 1, chat: 2},         // repeat held back on the phone
+		{advance: time.Minute, key: "provider:ecobee", phone: 2, chat: 3}, // new key
+		{advance: time.Minute, key: "alert:t1", phone: 2, chat: 4},        // hourly cap reached
+		{advance: time.Hour, key: "sink:es", phone: 3, chat: 5},           // both limits have passed
+	}
+	for i, step := range steps {
+		now = now.Add(step.advance)
+		if err := dispatcher.Notify(context.Background(), Message{Key: step.key, Title: "t"}); err != nil {
+			t.Fatalf("Step %d: unexpected error: %v", i, err)
+		}
+		if len(phone.messages) != step.phone || len(chat.messages) != step.chat {
+			t.Errorf("Step %d: expected %d phone and %d chat messages, got %d and %d",
+				i, step.phone, step.chat, len(phone.messages), len(chat.messages))
+		}
+	}
+}
+
+func TestDispatcherJoinsErrors(t *testing.T) {
+	dispatcher := NewDispatcher(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	working := &recordingNotifier{}
+	dispatcher.Add("broken", &recordingNotifier{err: errors.New("unreachable")}, Limit{})
+	dispatcher.Add("working", working, Limit{})
+
+	err := dispatcher.Notify(context.Background(), Message{Key: "k", Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "channel broken: unreachable") {
+		t.Errorf("Expected the broken channel's error, got %v", err)
+	}
+	if len(working.messages) != 1 {
+		t.Errorf("Expected the working channel to be sent the message, got %d", len(working.messages))
+	}
+}
+
+func TestTransports(t *testing.T) {
+	msg := Message{Key: "sink:es", Title: "Sink failing", Body: "Writes have failed for 1h", Priority: PriorityHigh}
+
+	tests := []struct {
+		name  string
+		setup func(url string) Notifier
+		check func(t *testing.T, r *http.Request, body []byte)
+	}{
+		{
+			name: "pushover",
+			setup: func(url string) Notifier {
+				original := pushoverURL
+				pushoverURL = url + "/1/messages.json"
+				t.Cleanup(func() { pushoverURL = original })
+				return NewPushover("app-token", "user-key")
+			},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				if r.URL.Path != "/1/messages.json" {
+					t.Errorf("Unexpected path %s", r.URL.Path)
+				}
+				form := string(body)
+				for _, want := range []string{"token=app-token", "user=user-key", "title=Sink+failing", "priority=1"} {
+					if !strings.Contains(form, want) {
+						t.Errorf("Expected %q in form %s", want, form)
+					}
+				}
+			},
+		},
+		{
+			name: "telegram",
+			setup: func(url string) Notifier {
+				original := telegramURL
+				telegramURL = url
+				t.Cleanup(func() { telegramURL = original })
+				return NewTelegram("bot-token", "42")
+			},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				if r.URL.Path != "/botbot-token/sendMessage" {
+					t.Errorf("Unexpected path %s", r.URL.Path)
+				}
+				var payload map[string]any
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Fatalf("Failed to decode body: %v", err)
+				}
+				if payload["chat_id"] != "42" || payload["text"] != "Sink failing\nWrites have failed for 1h" || payload["disable_notification"] != false {
+					t.Errorf("Unexpected payload %v", payload)
+				}
+			},
+		},
+		{
+			name: "ntfy",
+			setup: func(url string) Notifier {
+				return NewNtfy(url+"/", "ttr-alerts", "tk_secret")
+			},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				if r.URL.Path != "/ttr-alerts" || r.Header.Get("Title") != "Sink failing" ||
+					r.Header.Get("Priority") != "high" || r.Header.Get("Authorization") != "Bearer tk_secret" {
+					t.Errorf("Unexpected request %s %v", r.URL.Path, r.Header)
+				}
+				if string(body) != "Writes have failed for 1h" {
+					t.Errorf("Unexpected body %q", body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				body, _ := io.ReadAll(r.Body)
+				tt.check(t, r, body)
+			}))
+			defer server.Close()
+
+			if err := tt.setup(server.URL).Notify(context.Background(), msg); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if requests != 1 {
+				t.Errorf("Expected 1 request, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestTelegramRedactsToken(t *testing.T) {
+	original := telegramURL
+	telegramURL = "http://127.0.0.1:1"
+	defer func() { telegramURL = original }()
+
+	err := NewTelegram("secret-bot-token", "42").Notify(context.Background(), Message{Title: "t"})
+	if err == nil {
+		t.Fatal("Expected an error for an unreachable server")
+	}
+	if strings.Contains(err.Error(), "secret-bot-token") {
+		t.Errorf("Expected the bot token to be redacted, got %v", err)
+	}
+}
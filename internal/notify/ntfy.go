@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultNtfyServer is the public ntfy server
+const DefaultNtfyServer = "https://ntfy.sh"
+
+// Ntfy publishes messages to an ntfy topic
+type Ntfy struct {
+	client   *http.Client
+	endpoint string
+	token    string
+}
+
+// NewNtfy creates an ntfy notifier for a topic on server, which defaults to
+// DefaultNtfyServer. token is an access token for protected topics and may be
+// empty.
+func NewNtfy(server, topic, token string) *Ntfy {
+	if server == "" {
+		server = DefaultNtfyServer
+	}
+	return &Ntfy{
+		client:   &http.Client{Timeout: requestTimeout},
+		endpoint: strings.TrimSuffix(server, "/") + "/" + topic,
+		token:    token,
+	}
+}
+
+// Notify publishes msg with its title and priority as headers
+func (n *Ntfy) Notify(ctx context.Context, msg Message) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.endpoint, strings.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("creating ntfy request: %w", err)
+	}
+	req.Header.Set("Title", msg.Title)
+	if msg.Priority == PriorityHigh {
+		req.Header.Set("Priority", "high")
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+	if err := post(n.client, req); err != nil {
+		return fmt.Errorf("publishing ntfy message: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// pushoverURL is the Pushover messages API, overridden in tests
+var pushoverURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover sends messages with the Pushover API
+type Pushover struct {
+	client *http.Client
+	token  string
+	user   string
+}
+
+// NewPushover creates a Pushover notifier for an application token and a
+// user or group key
+func NewPushover(token, user string) *Pushover {
+	return &Pushover{client: &http.Client{Timeout: requestTimeout}, token: token, user: user}
+}
+
+// Notify sends msg; high priority messages bypass the user's quiet hours
+func (p *Pushover) Notify(ctx context.Context, msg Message) error {
+	form := url.Values{
+		"token":   {p.token},
+		"user":    {p.user},
+		"title":   {msg.Title},
+		"message": {msg.Body},
+	}
+	if msg.Priority == PriorityHigh {
+		form.Set("priority", "1")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, pushoverURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("creating pushover request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := post(p.client, req); err != nil {
+		return fmt.Errorf("sending pushover message: %w", err)
+	}
+	return nil
+}
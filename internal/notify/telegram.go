@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// telegramURL is the Telegram Bot API base URL, overridden in tests
+var telegramURL = "https://api.telegram.org"
+
+// Telegram sends messages to a chat through a Telegram bot
+type Telegram struct {
+	client *http.Client
+	token  string
+	chatID string
+}
+
+// NewTelegram creates a Telegram notifier for a bot token and the chat the
+// bot posts to
+func NewTelegram(token, chatID string) *Telegram {
+	return &Telegram{client: &http.Client{Timeout: requestTimeout}, token: token, chatID: chatID}
+}
+
+// Notify sends msg as one text message; normal priority messages are sent
+// silently
+func (t *Telegram) Notify(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]any{
+		"chat_id":              t.chatID,
+		"text":                 msg.Title + "\n" + msg.Body,
+		"disable_notification": msg.Priority != PriorityHigh,
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling telegram message: %w", err)
+	}
+
+	endpoint := telegramURL + "/bot" + t.token + "/sendMessage"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating telegram request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := post(t.client, req); err != nil {
+		// Transport errors quote the URL, which holds the bot token
+		return fmt.Errorf("sending telegram message: %s", strings.ReplaceAll(err.Error(), t.token, "<redacted>"))
+	}
+	return nil
+}
@@ -1,38 +1,67 @@
 package ecobee
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
 	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/secret"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	ecobeeTokenURL = getEnvOrDefault("ECOBEE_TOKEN_URL", "https://api.ecobee.com/token")
-	ecobeeAPIURL   = getEnvOrDefault("ECOBEE_API_URL", "https://api.ecobee.com/1")
+	ecobeeTokenURL = cmp.Or(os.Getenv("ECOBEE_TOKEN_URL"), "https://api.ecobee.com/token")
+	ecobeeAPIURL   = cmp.Or(os.Getenv("ECOBEE_API_URL"), "https://api.ecobee.com/1")
 )
 
-// getEnvOrDefault returns the value of the environment variable if set, otherwise returns the default.
-func getEnvOrDefault(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
-	}
-	return defaultVal
-}
-
 // AuthManager implements authentication for the Ecobee API
 type AuthManager struct {
-	clientID     string
-	refreshToken string
-	accessToken  string
-	tokenExpiry  time.Time
-	httpClient   *http.Client
-	retryConfig  retry.Config
+	// credMu guards the credentials, which ReloadCredentials may replace
+	credMu           sync.Mutex
+	clientID         string
+	refreshToken     string
+	clientIDFile     *secret.File
+	refreshTokenFile *secret.File
+
+	// tokenMu guards the access token, which concurrent polls share. refreshes
+	// collapses overlapping refreshes into one token request, since each one
+	// rotates the refresh token and a racing second request would present a
+	// token Ecobee has already retired.
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenIssued time.Time
+	tokenExpiry time.Time
+	refreshes   singleflight.Group
+
+	// refreshMargin is how long before expiry RunRefresher replaces the
+	// token, less up to refreshJitter so instances sharing an account do
+	// not refresh in lockstep
+	refreshMargin time.Duration
+	refreshJitter time.Duration
+
+	httpClient  *http.Client
+	retryConfig retry.Config
+
+	// maxResponseBytes bounds every response body read from Ecobee and
+	// bytesFetched counts what was read of them
+	maxResponseBytes int64
+	bytesFetched     bodylimit.Counter
+
+	// throttle records the deadline when Ecobee answers 429 so that
+	// subsequent calls fail fast instead of hammering the API while it is
+	// rate limiting us
+	throttle retry.Throttle
 }
 
 // NewAuthManager creates a new Ecobee authentication manager
@@ -43,13 +72,48 @@ func NewAuthManager(clientID, refreshToken string) *AuthManager {
 	retryConfig.MaxDelay = 30 * time.Second
 
 	return &AuthManager{
-		clientID:     clientID,
-		refreshToken: refreshToken,
-		httpClient:   &http.Client{Timeout: 30 * time.Second},
-		retryConfig:  retryConfig,
+		clientID:         clientID,
+		refreshToken:     refreshToken,
+		refreshMargin:    DefaultRefreshMargin,
+		refreshJitter:    DefaultRefreshJitter,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		retryConfig:      retryConfig,
+		maxResponseBytes: bodylimit.DefaultMaxBytes,
 	}
 }
 
+const (
+	// DefaultRefreshMargin is how long before expiry the background
+	// refresher replaces the access token
+	DefaultRefreshMargin = 5 * time.Minute
+
+	// DefaultRefreshJitter is the most the background refresh is brought forward at random
+	DefaultRefreshJitter = time.Minute
+
+	// expirySkew is how long before expiry a token is no longer used, to
+	// allow for clock drift and requests in flight
+	expirySkew = 30 * time.Second
+
+	// failedRefreshDelay is how long the background refresher waits after a
+	// failed refresh before trying again
+	failedRefreshDelay = 30 * time.Second
+)
+
+// SetMaxResponseBytes sets the largest response body read from Ecobee; a
+// larger one fails with a *bodylimit.TooLargeError. Call it before use.
+func (a *AuthManager) SetMaxResponseBytes(limit int64) {
+	a.maxResponseBytes = limit
+}
+
+// SetRefreshMargin sets how long before expiry RunRefresher replaces the
+// token and the most that is brought forward at random
+func (a *AuthManager) SetRefreshMargin(margin, jitter time.Duration) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	a.refreshMargin = margin
+	a.refreshJitter = jitter
+}
+
 // tokenResponse represents the response from the token endpoint
 type tokenResponse struct {
 	AccessToken  string `json:"access_token"`
@@ -69,6 +133,9 @@ type Selection struct {
 	IncludeProgram         bool   `json:"includeProgram,omitempty"`
 	IncludeEquipmentStatus bool   `json:"includeEquipmentStatus,omitempty"`
 	IncludeAlerts          bool   `json:"includeAlerts,omitempty"`
+	IncludeLocation        bool   `json:"includeLocation,omitempty"`
+	IncludeHouseDetails    bool   `json:"includeHouseDetails,omitempty"`
+	IncludeVersion         bool   `json:"includeVersion,omitempty"`
 }
 
 // SelectionRequest wraps the selection criteria for API requests
@@ -99,6 +166,21 @@ func NewThermostatSelection(thermostatID string) Selection {
 	}
 }
 
+// NewThermostatsSelection creates a selection for several thermostats
+func NewThermostatsSelection(thermostatIDs []string) Selection {
+	return NewThermostatSelection(strings.Join(thermostatIDs, ","))
+}
+
+// NewListSelection creates a selection listing every registered thermostat
+// with its location, for the time zone
+func NewListSelection() Selection {
+	return Selection{
+		SelectionType:   "registered",
+		SelectionMatch:  "",
+		IncludeLocation: true,
+	}
+}
+
 // NewSummarySelection creates a selection for thermostat summary
 func NewSummarySelection(thermostatID string) Selection {
 	sel := NewThermostatSelection(thermostatID)
@@ -106,6 +188,16 @@ func NewSummarySelection(thermostatID string) Selection {
 	return sel
 }
 
+// NewRegisteredSummarySelection creates a summary selection covering every
+// thermostat registered to the account
+func NewRegisteredSummarySelection() Selection {
+	return Selection{
+		SelectionType:  "registered",
+		SelectionMatch: "",
+		IncludeAlerts:  true,
+	}
+}
+
 // NewSnapshotSelection creates a selection for thermostat snapshot
 func NewSnapshotSelection(thermostatID string) Selection {
 	sel := NewThermostatSelection(thermostatID)
@@ -114,15 +206,105 @@ func NewSnapshotSelection(thermostatID string) Selection {
 	sel.IncludeEvents = true
 	sel.IncludeProgram = true
 	sel.IncludeEquipmentStatus = true
+	sel.IncludeVersion = true
 	return sel
 }
 
-// RefreshToken refreshes the authentication token
+// NewMetadataSelection creates a selection for thermostat location and house details
+func NewMetadataSelection(thermostatID string) Selection {
+	sel := NewThermostatSelection(thermostatID)
+	sel.IncludeSettings = true
+	sel.IncludeLocation = true
+	sel.IncludeHouseDetails = true
+	return sel
+}
+
+// UseCredentialFiles reads the client ID and refresh token from files, which
+// ReloadCredentials re-reads. Either path may be empty to keep the configured value.
+func (a *AuthManager) UseCredentialFiles(clientIDPath, refreshTokenPath string) error {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	if clientIDPath != "" {
+		file, err := secret.NewFile(clientIDPath)
+		if err != nil {
+			return fmt.Errorf("reading client_id: %w", err)
+		}
+		a.clientIDFile = file
+		a.clientID = file.Value()
+	}
+	if refreshTokenPath != "" {
+		file, err := secret.NewFile(refreshTokenPath)
+		if err != nil {
+			return fmt.Errorf("reading refresh_token: %w", err)
+		}
+		a.refreshTokenFile = file
+		a.refreshToken = file.Value()
+	}
+	return nil
+}
+
+// ReloadCredentials re-reads the credential files. A value is only replaced
+// when its file changed, so a refresh token rotated by Ecobee is kept until
+// the operator writes a new one.
+func (a *AuthManager) ReloadCredentials() (bool, error) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	changed := false
+	if a.clientIDFile != nil {
+		updated, err := a.clientIDFile.Reload()
+		if err != nil {
+			return false, fmt.Errorf("reloading client_id: %w", err)
+		}
+		if updated {
+			a.clientID = a.clientIDFile.Value()
+			changed = true
+		}
+	}
+	if a.refreshTokenFile != nil {
+		updated, err := a.refreshTokenFile.Reload()
+		if err != nil {
+			return changed, fmt.Errorf("reloading refresh_token: %w", err)
+		}
+		if updated {
+			a.refreshToken = a.refreshTokenFile.Value()
+			changed = true
+		}
+	}
+	return changed, nil
+}
+
+// credentials returns the current client ID and refresh token
+func (a *AuthManager) credentials() (string, string) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+	return a.clientID, a.refreshToken
+}
+
+// RefreshToken refreshes the authentication token. Callers arriving while a
+// refresh is in flight wait for its result instead of starting another.
 func (a *AuthManager) RefreshToken(ctx context.Context) error {
+	// The shared request must not fail for every waiter because the caller
+	// that started it gave up; the HTTP client timeout still bounds it
+	result := a.refreshes.DoChan("token", func() (any, error) {
+		return nil, a.requestToken(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-result:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestToken exchanges the refresh token for a new access token
+func (a *AuthManager) requestToken(ctx context.Context) error {
+	clientID, refreshToken := a.credentials()
 	data := url.Values{}
 	data.Set("grant_type", "refresh_token")
-	data.Set("refresh_token", a.refreshToken)
-	data.Set("client_id", a.clientID)
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", clientID)
 
 	req, err := http.NewRequestWithContext(ctx, "POST", ecobeeTokenURL, nil)
 	if err != nil {
@@ -140,41 +322,138 @@ func (a *AuthManager) RefreshToken(ctx context.Context) error {
 		_ = resp.Body.Close()
 	}()
 
+	if resp.StatusCode == http.StatusTooManyRequests {
+		throttled := retry.NewThrottledError(resp.StatusCode, retry.RetryAfterFromResponse(resp))
+		a.throttle.Record(throttled)
+		return fmt.Errorf("refreshing token: %w", throttled)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		return fmt.Errorf("token refresh failed with status %d", resp.StatusCode)
 	}
 
 	var tokenResp tokenResponse
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+	if err := json.NewDecoder(a.bytesFetched.Limit(resp, a.maxResponseBytes).Body).Decode(&tokenResp); err != nil {
 		return fmt.Errorf("decoding token response: %w", err)
 	}
 
-	a.accessToken = tokenResp.AccessToken
 	if tokenResp.RefreshToken != "" {
+		a.credMu.Lock()
 		a.refreshToken = tokenResp.RefreshToken
+		a.credMu.Unlock()
 	}
-	a.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	now := time.Now()
+	a.tokenMu.Lock()
+	a.accessToken = tokenResp.AccessToken
+	a.tokenIssued = now
+	a.tokenExpiry = now.Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	a.tokenMu.Unlock()
 
 	return nil
 }
 
 // GetAccessToken returns the current access token, refreshing if needed
 func (a *AuthManager) GetAccessToken(ctx context.Context) (string, error) {
-	if !a.IsTokenValid(ctx) {
-		if err := a.RefreshToken(ctx); err != nil {
-			return "", fmt.Errorf("refreshing token: %w", err)
-		}
+	if token, ok := a.validToken(); ok {
+		return token, nil
 	}
-	return a.accessToken, nil
+	if err := a.RefreshToken(ctx); err != nil {
+		return "", fmt.Errorf("refreshing token: %w", err)
+	}
+	return a.currentToken(), nil
 }
 
 // IsTokenValid checks if the current token is valid
 func (a *AuthManager) IsTokenValid(ctx context.Context) bool {
-	return a.accessToken != "" && time.Now().Before(a.tokenExpiry.Add(-5*time.Minute))
+	_, ok := a.validToken()
+	return ok
+}
+
+// currentToken returns the access token from the latest refresh
+func (a *AuthManager) currentToken() string {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	return a.accessToken
+}
+
+// validToken returns the access token and whether it can still be used.
+// RunRefresher normally replaces it well before then, so callers only
+// refresh in the request path when the refresher is not running or failing.
+func (a *AuthManager) validToken() (string, bool) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	return a.accessToken, a.accessToken != "" && time.Now().Before(a.tokenExpiry.Add(-expirySkew))
+}
+
+// RunRefresher refreshes the access token in the background until ctx is
+// cancelled, so polls are not delayed by refreshes. Each refresh is due
+// refreshMargin before expiry, brought forward by a random jitter. Failures
+// are retried after failedRefreshDelay; polls report them if the token expires.
+func (a *AuthManager) RunRefresher(ctx context.Context) {
+	timer := time.NewTimer(a.nextRefresh(time.Now()))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		delay := failedRefreshDelay
+		if err := a.RefreshToken(ctx); err == nil {
+			delay = a.nextRefresh(time.Now())
+		} else if wait := time.Until(a.ThrottledUntil()); wait > delay {
+			delay = wait
+		}
+		timer.Reset(delay)
+	}
+}
+
+// nextRefresh returns how long after now the background refresh is due. A
+// token is not refreshed before half its lifetime has passed, so a margin
+// longer than Ecobee's token lifetime cannot refresh continuously.
+func (a *AuthManager) nextRefresh(now time.Time) time.Duration {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	if a.accessToken == "" {
+		return 0
+	}
+
+	due := a.tokenExpiry.Add(-a.refreshMargin)
+	if a.refreshJitter > 0 {
+		due = due.Add(-rand.N(a.refreshJitter))
+	}
+	if halfway := a.tokenIssued.Add(a.tokenExpiry.Sub(a.tokenIssued) / 2); due.Before(halfway) {
+		due = halfway
+	}
+	return max(due.Sub(now), 0)
+}
+
+// refreshRejected refreshes the token after Ecobee rejected the request
+// carrying it. When another request already replaced the rejected token the
+// replacement is used rather than spending another refresh.
+func (a *AuthManager) refreshRejected(ctx context.Context, resp *http.Response) (string, error) {
+	rejected := strings.TrimPrefix(resp.Request.Header.Get("Authorization"), "Bearer ")
+	if token, ok := a.validToken(); ok && token != rejected {
+		return token, nil
+	}
+	if err := a.RefreshToken(ctx); err != nil {
+		return "", err
+	}
+	return a.currentToken(), nil
+}
+
+// ThrottledUntil returns the time before which Ecobee asked us not to call again
+func (a *AuthManager) ThrottledUntil() time.Time {
+	return a.throttle.Until()
 }
 
 // makeAuthenticatedRequest makes an authenticated request to the Ecobee API with retry logic
 func (a *AuthManager) makeAuthenticatedRequest(ctx context.Context, endpoint string, params map[string]string) (*http.Response, error) {
+	if err := a.throttle.Check(); err != nil {
+		return nil, err
+	}
+
 	token, err := a.GetAccessToken(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("getting access token: %w", err)
@@ -210,7 +489,7 @@ func (a *AuthManager) makeAuthenticatedRequest(ctx context.Context, endpoint str
 	req.Header.Set("Content-Type", "application/json")
 
 	// Execute request with retry logic
-	return retry.DoWithResponse(ctx, a.retryConfig, func() (*http.Response, error) {
+	resp, err := retry.DoWithResponse(ctx, a.retryConfig, func() (*http.Response, error) {
 		resp, err := a.httpClient.Do(req)
 		if err != nil {
 			return nil, fmt.Errorf("making request: %w", err)
@@ -220,13 +499,9 @@ func (a *AuthManager) makeAuthenticatedRequest(ctx context.Context, endpoint str
 		if resp.StatusCode == http.StatusUnauthorized {
 			_ = resp.Body.Close()
 			// Try to refresh token
-			if err := a.RefreshToken(ctx); err != nil {
-				return nil, fmt.Errorf("refreshing token after 401: %w", err)
-			}
-
-			refreshedToken, err := a.GetAccessToken(ctx)
+			refreshedToken, err := a.refreshRejected(ctx, resp)
 			if err != nil {
-				return nil, fmt.Errorf("getting refreshed token: %w", err)
+				return nil, fmt.Errorf("refreshing token after 401: %w", err)
 			}
 
 			// Update request header with new token
@@ -241,4 +516,57 @@ func (a *AuthManager) makeAuthenticatedRequest(ctx context.Context, endpoint str
 
 		return resp, nil
 	})
+
+	a.throttle.Record(err)
+
+	return a.bytesFetched.Limit(resp, a.maxResponseBytes), err
+}
+
+// makeAuthenticatedPost posts a JSON body to the Ecobee API with retry logic.
+// The request is rebuilt for each attempt so the body can be resent.
+func (a *AuthManager) makeAuthenticatedPost(ctx context.Context, endpoint string, body []byte) (*http.Response, error) {
+	if err := a.throttle.Check(); err != nil {
+		return nil, err
+	}
+
+	send := func() (*http.Response, error) {
+		token, err := a.GetAccessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", ecobeeAPIURL+endpoint+"?format=json", bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("making request: %w", err)
+		}
+		return resp, nil
+	}
+
+	resp, err := retry.DoWithResponse(ctx, a.retryConfig, func() (*http.Response, error) {
+		resp, err := send()
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		// Refresh the token and try once more
+		_ = resp.Body.Close()
+		if _, err := a.refreshRejected(ctx, resp); err != nil {
+			return nil, fmt.Errorf("refreshing token after 401: %w", err)
+		}
+		return send()
+	})
+
+	a.throttle.Record(err)
+
+	return a.bytesFetched.Limit(resp, a.maxResponseBytes), err
+}
+
+// BytesFetched returns the response bytes read from Ecobee
+func (a *AuthManager) BytesFetched() int64 {
+	return a.bytesFetched.Bytes()
 }
@@ -0,0 +1,226 @@
+package ecobee
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReloadCredentialsKeepsRotatedToken(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.URL.Query().Get("refresh_token"))
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"rotated","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	originalURL := ecobeeTokenURL
+	ecobeeTokenURL = server.URL
+	defer func() { ecobeeTokenURL = originalURL }()
+
+	dir := t.TempDir()
+	clientIDFile := filepath.Join(dir, "client_id")
+	tokenFile := filepath.Join(dir, "refresh_token")
+	write := func(path, value string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	write(clientIDFile, "client\n")
+	write(tokenFile, "from-file\n")
+
+	auth := NewAuthManager("", "")
+	if err := auth.UseCredentialFiles(clientIDFile, tokenFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := auth.RefreshToken(context.Background()); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+
+	// Ecobee rotated the token; an unchanged file must not replace it
+	changed, err := auth.ReloadCredentials()
+	if err != nil || changed {
+		t.Fatalf("Expected no change, got changed=%v error=%v", changed, err)
+	}
+	if _, token := auth.credentials(); token != "rotated" {
+		t.Errorf("Expected rotated token to be kept, got %q", token)
+	}
+
+	// A token written by the operator replaces it
+	write(tokenFile, "reauthorized")
+	changed, err = auth.ReloadCredentials()
+	if err != nil || !changed {
+		t.Fatalf("Expected a change, got changed=%v error=%v", changed, err)
+	}
+	if err := auth.RefreshToken(context.Background()); err != nil {
+		t.Fatalf("Unexpected refresh error: %v", err)
+	}
+	if len(sent) != 2 || sent[0] != "from-file" || sent[1] != "reauthorized" {
+		t.Errorf("Unexpected refresh tokens sent: %v", sent)
+	}
+}
+
+func TestConcurrentCallersShareOneRefresh(t *testing.T) {
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = w.Write([]byte(`{"access_token":"access","refresh_token":"rotated","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	originalURL := ecobeeTokenURL
+	ecobeeTokenURL = server.URL
+	defer func() { ecobeeTokenURL = originalURL }()
+
+	auth := NewAuthManager("client", "refresh")
+
+	// A caller that gives up must not cancel the refresh the others wait on
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan error, 1)
+	go func() {
+		_, err := auth.GetAccessToken(ctx)
+		abandoned <- err
+	}()
+	for requests.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-abandoned; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancelled caller to return context.Canceled, got %v", err)
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	tokens := make([]string, callers)
+	errs := make([]error, callers)
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tokens[i], errs[i] = auth.GetAccessToken(context.Background())
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for i := range callers {
+		if errs[i] != nil || tokens[i] != "access" {
+			t.Errorf("Expected caller %d to get the refreshed token, got %q, %v", i, tokens[i], errs[i])
+		}
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 token request, got %d", got)
+	}
+	if _, token := auth.credentials(); token != "rotated" {
+		t.Errorf("Expected rotated refresh token, got %q", token)
+	}
+}
+
+func TestRejectedTokenRefreshedOnce(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		_, _ = w.Write([]byte(`{"access_token":"fresh","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	originalURL := ecobeeTokenURL
+	ecobeeTokenURL = server.URL
+	defer func() { ecobeeTokenURL = originalURL }()
+
+	// A token the client still considers valid but Ecobee has revoked
+	auth := NewAuthManager("client", "refresh")
+	auth.accessToken = "revoked"
+	auth.tokenExpiry = time.Now().Add(time.Hour)
+
+	rejected := &http.Response{Request: httptest.NewRequest(http.MethodGet, "/thermostat", nil)}
+	rejected.Request.Header.Set("Authorization", "Bearer revoked")
+
+	// The second rejection of the same token arrives after the first one
+	// already replaced it, so no further refresh is spent
+	for range 2 {
+		token, err := auth.refreshRejected(context.Background(), rejected)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if token != "fresh" {
+			t.Errorf("Expected token %q, got %q", "fresh", token)
+		}
+	}
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", got)
+	}
+}
+
+func TestNextRefreshBeforeExpiry(t *testing.T) {
+	now := time.Now()
+	auth := NewAuthManager("client", "refresh")
+	if got := auth.nextRefresh(now); got != 0 {
+		t.Errorf("Expected an immediate refresh without a token, got %v", got)
+	}
+
+	auth.accessToken = "access"
+	auth.tokenIssued = now
+	auth.tokenExpiry = now.Add(time.Hour)
+	auth.SetRefreshMargin(5*time.Minute, time.Minute)
+	for range 50 {
+		got := auth.nextRefresh(now)
+		if got <= 54*time.Minute || got > 55*time.Minute {
+			t.Fatalf("Expected a refresh 54-55 minutes out, got %v", got)
+		}
+	}
+
+	// A margin longer than the token lifetime waits for half of it
+	auth.SetRefreshMargin(2*time.Hour, 0)
+	if got := auth.nextRefresh(now); got != 30*time.Minute {
+		t.Errorf("Expected a refresh at half the lifetime, got %v", got)
+	}
+}
+
+func TestRunRefresherReplacesTokenAheadOfPolls(t *testing.T) {
+	var refreshes atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refreshes.Add(1)
+		_, _ = w.Write([]byte(`{"access_token":"access","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	originalURL := ecobeeTokenURL
+	ecobeeTokenURL = server.URL
+	defer func() { ecobeeTokenURL = originalURL }()
+
+	auth := NewAuthManager("client", "refresh")
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		auth.RunRefresher(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !auth.IsTokenValid(ctx) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	token, err := auth.GetAccessToken(ctx)
+	if err != nil || token != "access" {
+		t.Fatalf("Expected the refreshed token, got %q, %v", token, err)
+	}
+	cancel()
+	<-done
+
+	// The poll used the token the refresher fetched
+	if got := refreshes.Load(); got != 1 {
+		t.Errorf("Expected 1 token refresh, got %d", got)
+	}
+}
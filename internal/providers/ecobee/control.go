@@ -0,0 +1,109 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+// thermostatFunction is one entry of the functions list Ecobee applies to
+// the selected thermostats
+type thermostatFunction struct {
+	Type   string         `json:"type"`
+	Params map[string]any `json:"params"`
+}
+
+// thermostatUpdate is the body of a POST to /thermostat
+type thermostatUpdate struct {
+	Selection Selection            `json:"selection"`
+	Functions []thermostatFunction `json:"functions"`
+}
+
+// Control applies a hold or resumes the program through the setHold and
+// resumeProgram functions. Setpoint holds need both setpoints, as Ecobee
+// requires; climate holds take a climate ref such as "away". Changing
+// settings needs an API key authorized with the smartWrite scope.
+func (p *Provider) Control(ctx context.Context, tr model.ThermostatRef, cmd model.ControlCommand) error {
+	function, err := controlFunction(cmd)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(thermostatUpdate{
+		Selection: NewThermostatSelection(tr.ID),
+		Functions: []thermostatFunction{function},
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling thermostat update: %w", err)
+	}
+
+	resp, err := p.authManager.makeAuthenticatedPost(ctx, "/thermostat", body)
+	if err != nil {
+		return fmt.Errorf("updating thermostat: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result struct {
+		Status struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"status"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("decoding thermostat update response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != 200 || result.Status.Code != 0 {
+		return fmt.Errorf("thermostat update rejected (status %d, code %d): %s", resp.StatusCode, result.Status.Code, result.Status.Message)
+	}
+	return nil
+}
+
+// controlFunction translates a command into an Ecobee function
+func controlFunction(cmd model.ControlCommand) (thermostatFunction, error) {
+	switch cmd.Action {
+	case model.ControlActionResume:
+		return thermostatFunction{Type: "resumeProgram", Params: map[string]any{"resumeAll": true}}, nil
+	case model.ControlActionHold:
+	default:
+		return thermostatFunction{}, fmt.Errorf("unsupported control action %q", cmd.Action)
+	}
+
+	params := map[string]any{"holdType": "nextTransition"}
+	if cmd.HoldHours > 0 {
+		params["holdType"] = "holdHours"
+		params["holdHours"] = cmd.HoldHours
+	}
+	switch {
+	case cmd.Climate != "":
+		params["holdClimateRef"] = strings.ToLower(cmd.Climate)
+	case cmd.HeatSetpointC != nil && cmd.CoolSetpointC != nil:
+		heat, err := ecobeeTemperature(*cmd.HeatSetpointC)
+		if err != nil {
+			return thermostatFunction{}, err
+		}
+		cool, err := ecobeeTemperature(*cmd.CoolSetpointC)
+		if err != nil {
+			return thermostatFunction{}, err
+		}
+		params["heatHoldTemp"] = heat
+		params["coolHoldTemp"] = cool
+	default:
+		return thermostatFunction{}, fmt.Errorf("ecobee holds need a climate or both heat and cool setpoints")
+	}
+	return thermostatFunction{Type: "setHold", Params: params}, nil
+}
+
+// ecobeeTemperature converts Celsius to whole tenths of a degree Fahrenheit
+func ecobeeTemperature(c float64) (int, error) {
+	converted, err := temperature.NewConverter(temperature.StandardCelsius, temperature.EcobeeFormat).Convert(&c)
+	if err != nil {
+		return 0, fmt.Errorf("converting setpoint: %w", err)
+	}
+	return int(math.Round(*converted)), nil
+}
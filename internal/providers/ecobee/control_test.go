@@ -0,0 +1,93 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestControl(t *testing.T) {
+	heat, cool := 20.0, 25.0
+	tr := model.ThermostatRef{ID: "t1", Name: "Hallway", Provider: "ecobee"}
+
+	tests := []struct {
+		name       string
+		cmd        model.ControlCommand
+		response   string
+		wantType   string
+		wantParams map[string]any
+		wantErr    bool
+	}{
+		{
+			name:       "setpoint hold until the next transition",
+			cmd:        model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &heat, CoolSetpointC: &cool},
+			response:   `{"status":{"code":0,"message":""}}`,
+			wantType:   "setHold",
+			wantParams: map[string]any{"holdType": "nextTransition", "heatHoldTemp": 680.0, "coolHoldTemp": 770.0},
+		},
+		{
+			name:       "climate hold for two hours",
+			cmd:        model.ControlCommand{Action: model.ControlActionHold, Climate: "Away", HoldHours: 2},
+			response:   `{"status":{"code":0,"message":""}}`,
+			wantType:   "setHold",
+			wantParams: map[string]any{"holdType": "holdHours", "holdHours": 2.0, "holdClimateRef": "away"},
+		},
+		{
+			name:       "resume",
+			cmd:        model.ControlCommand{Action: model.ControlActionResume},
+			response:   `{"status":{"code":0,"message":""}}`,
+			wantType:   "resumeProgram",
+			wantParams: map[string]any{"resumeAll": true},
+		},
+		{
+			name:     "rejected by ecobee",
+			cmd:      model.ControlCommand{Action: model.ControlActionResume},
+			response: `{"status":{"code":3,"message":"Processing error."}}`,
+			wantType: "resumeProgram",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var update thermostatUpdate
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != "/thermostat" {
+					t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+					t.Errorf("Failed to decode update: %v", err)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			})
+
+			err := provider.Control(context.Background(), tr, tt.cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if update.Selection.SelectionType != "thermostats" || update.Selection.SelectionMatch != "t1" {
+				t.Errorf("Unexpected selection %+v", update.Selection)
+			}
+			if len(update.Functions) != 1 || update.Functions[0].Type != tt.wantType {
+				t.Fatalf("Unexpected functions %+v", update.Functions)
+			}
+			for key, want := range tt.wantParams {
+				if got := update.Functions[0].Params[key]; got != want {
+					t.Errorf("Expected %s %v, got %v", key, want, got)
+				}
+			}
+		})
+	}
+
+	t.Run("hold without setpoints or a climate", func(t *testing.T) {
+		provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+			t.Error("Expected no request")
+		})
+		err := provider.Control(context.Background(), tr, model.ControlCommand{Action: model.ControlActionHold, HeatSetpointC: &heat})
+		if err == nil {
+			t.Error("Expected an error for a hold with only a heat setpoint")
+		}
+	})
+}
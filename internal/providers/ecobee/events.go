@@ -0,0 +1,104 @@
+package ecobee
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+// event mirrors the subset of the Ecobee Event object needed to classify
+// setpoint changes. Hold temperatures are in tenths of Fahrenheit.
+type event struct {
+	Type         string   `json:"type"`
+	Name         string   `json:"name"`
+	Running      bool     `json:"running"`
+	StartDate    string   `json:"startDate"`
+	StartTime    string   `json:"startTime"`
+	EndDate      string   `json:"endDate"`
+	EndTime      string   `json:"endTime"`
+	HeatHoldTemp *float64 `json:"heatHoldTemp,omitempty"`
+	CoolHoldTemp *float64 `json:"coolHoldTemp,omitempty"`
+}
+
+// eventKinds maps Ecobee event types to canonical event kinds. Types not
+// listed are passed through empty so the normalizer infers from the name.
+var eventKinds = map[string]string{
+	"hold":           "hold",
+	"quickSave":      "hold",
+	"vacation":       "vacation",
+	"demandResponse": model.EventKindDemandResponse,
+}
+
+// parseEvents decodes the events array both as raw provider data (kept for
+// device snapshots) and as canonical events used for transition
+// classification. Event times are read in the thermostat's zone loc.
+func parseEvents(raw json.RawMessage, loc *time.Location) ([]any, []model.Event, error) {
+	if len(raw) == 0 {
+		return nil, nil, nil
+	}
+
+	var rawEvents []any
+	if err := json.Unmarshal(raw, &rawEvents); err != nil {
+		return nil, nil, fmt.Errorf("decoding raw events: %w", err)
+	}
+
+	var typed []event
+	if err := json.Unmarshal(raw, &typed); err != nil {
+		return nil, nil, fmt.Errorf("decoding events: %w", err)
+	}
+
+	events := make([]model.Event, 0, len(typed))
+	for _, e := range typed {
+		events = append(events, e.toModel(loc))
+	}
+
+	return rawEvents, events, nil
+}
+
+// toModel converts an Ecobee event to the canonical representation
+func (e event) toModel(loc *time.Location) model.Event {
+	converted := model.Event{
+		Kind:    e.kind(),
+		Name:    e.Name,
+		Running: e.Running,
+		Start:   parseEventTime(e.StartDate, e.StartTime, loc),
+		End:     parseEventTime(e.EndDate, e.EndTime, loc),
+	}
+
+	if heat, err := temperature.ConvertFromEcobeeToCelsius(e.HeatHoldTemp); err == nil {
+		converted.SetHeatC = heat
+	}
+	if cool, err := temperature.ConvertFromEcobeeToCelsius(e.CoolHoldTemp); err == nil {
+		converted.SetCoolC = cool
+	}
+
+	return converted
+}
+
+// kind classifies the event. eco+ programs are delivered as holds whose name
+// identifies the program, so the name is checked before the type.
+func (e event) kind() string {
+	name := strings.ToLower(e.Name)
+	if strings.Contains(name, "eco+") || strings.Contains(name, "ecoplus") {
+		return model.EventKindDemandResponse
+	}
+	return eventKinds[e.Type]
+}
+
+// parseEventTime combines Ecobee's separate date and time fields, which are
+// in thermostat local time
+func parseEventTime(date, clock string, loc *time.Location) time.Time {
+	if date == "" {
+		return time.Time{}
+	}
+
+	t, err := parseThermostatTime(date, clock, loc)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,101 @@
+package ecobee
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestParseEvents(t *testing.T) {
+	raw := json.RawMessage(`[
+		{"type":"demandResponse","name":"Utility Peak","running":true,
+		 "startDate":"2024-07-15","startTime":"16:00:00","endDate":"2024-07-15","endTime":"19:00:00",
+		 "coolHoldTemp":780},
+		{"type":"hold","name":"eco+ Peak Relief","running":false,
+		 "startDate":"2024-07-16","startTime":"15:00:00","endDate":"2024-07-16","endTime":"18:00:00"},
+		{"type":"hold","name":"auto","running":true,"startDate":"2024-07-15","startTime":"08:00:00",
+		 "heatHoldTemp":700,"coolHoldTemp":760},
+		{"type":"autoAway","name":"smartAway","running":false}
+	]`)
+
+	rawEvents, events, err := parseEvents(raw, time.UTC)
+	if err != nil {
+		t.Fatalf("parseEvents failed: %v", err)
+	}
+
+	if len(rawEvents) != 4 {
+		t.Errorf("Expected 4 raw events, got %d", len(rawEvents))
+	}
+	if len(events) != 4 {
+		t.Fatalf("Expected 4 events, got %d", len(events))
+	}
+
+	tests := []struct {
+		name string
+		kind string
+	}{
+		{"Utility Peak", model.EventKindDemandResponse},
+		{"eco+ Peak Relief", model.EventKindDemandResponse},
+		{"auto", "hold"},
+		{"smartAway", ""},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if events[i].Name != tt.name {
+				t.Errorf("Expected name %s, got %s", tt.name, events[i].Name)
+			}
+			if events[i].Kind != tt.kind {
+				t.Errorf("Expected kind %q, got %q", tt.kind, events[i].Kind)
+			}
+		})
+	}
+
+	dr := events[0]
+	if !dr.Running {
+		t.Error("Expected demand response event to be running")
+	}
+	expectedStart := time.Date(2024, 7, 15, 16, 0, 0, 0, time.UTC)
+	if !dr.Start.Equal(expectedStart) {
+		t.Errorf("Expected start %v, got %v", expectedStart, dr.Start)
+	}
+	if dr.SetCoolC == nil || *dr.SetCoolC < 25.5 || *dr.SetCoolC > 25.6 {
+		t.Errorf("Expected cool hold of ~25.56C, got %v", dr.SetCoolC)
+	}
+	if dr.SetHeatC != nil {
+		t.Errorf("Expected no heat hold, got %v", *dr.SetHeatC)
+	}
+}
+
+func TestParseEventsEmpty(t *testing.T) {
+	rawEvents, events, err := parseEvents(nil, time.UTC)
+	if err != nil {
+		t.Fatalf("parseEvents failed: %v", err)
+	}
+	if rawEvents != nil || events != nil {
+		t.Errorf("Expected nil results for missing events, got %v and %v", rawEvents, events)
+	}
+}
+
+func TestParseEventsTimeZone(t *testing.T) {
+	raw := json.RawMessage(`[{"type":"hold","name":"auto","startDate":"2024-07-15","startTime":"16:00:00",
+		"endDate":"2024-01-15","endTime":"16:00:00"}]`)
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatalf("Failed to load zone: %v", err)
+	}
+
+	_, events, err := parseEvents(raw, chicago)
+	if err != nil {
+		t.Fatalf("parseEvents failed: %v", err)
+	}
+	// 16:00 local is 21:00 UTC under daylight saving time and 22:00 without
+	if expected := time.Date(2024, 7, 15, 21, 0, 0, 0, time.UTC); !events[0].Start.Equal(expected) {
+		t.Errorf("Expected start %v, got %v", expected, events[0].Start.UTC())
+	}
+	if expected := time.Date(2024, 1, 15, 22, 0, 0, 0, time.UTC); !events[0].End.Equal(expected) {
+		t.Errorf("Expected end %v, got %v", expected, events[0].End.UTC())
+	}
+}
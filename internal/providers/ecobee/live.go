@@ -0,0 +1,134 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+// ecobeeStatusTimeFormat is the format of runtime.lastStatusModified (UTC)
+const ecobeeStatusTimeFormat = "2006-01-02 15:04:05"
+
+// liveRuntime mirrors the subset of the Ecobee runtime object used for live readings.
+// Temperatures are in tenths of a degree Fahrenheit.
+type liveRuntime struct {
+	LastStatusModified string   `json:"lastStatusModified"`
+	ActualTemperature  *float64 `json:"actualTemperature"`
+	ActualHumidity     *int     `json:"actualHumidity"`
+	DesiredHeat        *float64 `json:"desiredHeat"`
+	DesiredCool        *float64 `json:"desiredCool"`
+}
+
+// equipmentStatusKeys maps Ecobee equipmentStatus entries to runtime report column names
+var equipmentStatusKeys = map[string]string{
+	"heatPump":  "compHeat1",
+	"heatPump2": "compHeat2",
+	"compCool1": "compCool1",
+	"compCool2": "compCool2",
+	"auxHeat1":  "auxHeat1",
+	"auxHeat2":  "auxHeat2",
+	"auxHeat3":  "auxHeat3",
+	"fan":       "fan",
+}
+
+// NewLiveSelection creates a selection for current runtime values
+func NewLiveSelection(thermostatID string) Selection {
+	sel := NewThermostatSelection(thermostatID)
+	sel.IncludeRuntime = true
+	sel.IncludeSettings = true
+	sel.IncludeEquipmentStatus = true
+	return sel
+}
+
+// GetLive returns current readings from the thermostat runtime object. Ecobee
+// refreshes these values roughly every three minutes, well ahead of the
+// runtime report.
+func (p *Provider) GetLive(ctx context.Context, tr model.ThermostatRef) (model.LiveReading, error) {
+	selectionJSON, err := json.Marshal(SelectionRequest{Selection: NewLiveSelection(tr.ID)})
+	if err != nil {
+		return model.LiveReading{}, fmt.Errorf(errMsgMarshalSelection, err)
+	}
+
+	resp, err := p.authManager.makeAuthenticatedRequest(ctx, "/thermostat", map[string]string{
+		"json": string(selectionJSON),
+	})
+	if err != nil {
+		return model.LiveReading{}, fmt.Errorf("requesting live runtime: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result struct {
+		ThermostatList []struct {
+			Identifier      string      `json:"identifier"`
+			Runtime         liveRuntime `json:"runtime"`
+			EquipmentStatus string      `json:"equipmentStatus"`
+			Settings        struct {
+				HVACMode string `json:"hvacMode"`
+			} `json:"settings"`
+		} `json:"thermostatList"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return model.LiveReading{}, fmt.Errorf("decoding live runtime response: %w", err)
+	}
+
+	for _, t := range result.ThermostatList {
+		if t.Identifier == tr.ID {
+			return toLiveReading(tr, t.Runtime, t.Settings.HVACMode, t.EquipmentStatus), nil
+		}
+	}
+
+	return model.LiveReading{}, fmt.Errorf("thermostat %s not found in live runtime", tr.ID)
+}
+
+// toLiveReading converts an Ecobee runtime object to a live reading
+func toLiveReading(tr model.ThermostatRef, runtime liveRuntime, mode, equipmentStatus string) model.LiveReading {
+	reading := model.LiveReading{
+		ThermostatRef: tr,
+		Mode:          mode,
+		Humidity:      runtime.ActualHumidity,
+		Equipment:     parseEquipmentStatus(equipmentStatus),
+	}
+
+	// Fall back to the poll time so a missing timestamp still yields a usable document
+	readingTime, err := time.Parse(ecobeeStatusTimeFormat, runtime.LastStatusModified)
+	if err != nil {
+		readingTime = time.Now().UTC().Truncate(time.Second)
+	}
+	reading.ReadingTime = readingTime
+
+	if converted, err := temperature.ConvertFromEcobeeToCelsius(runtime.ActualTemperature); err == nil {
+		reading.TempC = converted
+	}
+	if converted, err := temperature.ConvertFromEcobeeToCelsius(runtime.DesiredHeat); err == nil {
+		reading.SetHeatC = converted
+	}
+	if converted, err := temperature.ConvertFromEcobeeToCelsius(runtime.DesiredCool); err == nil {
+		reading.SetCoolC = converted
+	}
+
+	return reading
+}
+
+// parseEquipmentStatus converts Ecobee's comma-separated list of running
+// equipment into the runtime report's equipment map. Equipment that is not
+// running is reported as false so consumers see every known key.
+func parseEquipmentStatus(status string) map[string]bool {
+	equipment := make(map[string]bool, len(equipmentStatusKeys))
+	for _, key := range equipmentStatusKeys {
+		equipment[key] = false
+	}
+	for _, entry := range strings.Split(status, ",") {
+		if key, ok := equipmentStatusKeys[strings.TrimSpace(entry)]; ok {
+			equipment[key] = true
+		}
+	}
+	return equipment
+}
@@ -0,0 +1,81 @@
+package ecobee
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestToLiveReading(t *testing.T) {
+	raw := `{
+		"lastStatusModified": "2025-01-10 18:01:30",
+		"actualTemperature": 705,
+		"actualHumidity": 38,
+		"desiredHeat": 680,
+		"desiredCool": 780
+	}`
+
+	var runtime liveRuntime
+	if err := json.Unmarshal([]byte(raw), &runtime); err != nil {
+		t.Fatalf("Failed to decode fixture: %v", err)
+	}
+
+	tr := model.ThermostatRef{ID: "t1", Name: "Hallway"}
+	reading := toLiveReading(tr, runtime, "heat", "heatPump,fan")
+
+	if !reading.ReadingTime.Equal(time.Date(2025, 1, 10, 18, 1, 30, 0, time.UTC)) {
+		t.Errorf("Unexpected reading time %v", reading.ReadingTime)
+	}
+	if reading.TempC == nil || math.Abs(*reading.TempC-21.3889) > 0.001 {
+		t.Errorf("Expected temperature ~21.39C, got %v", reading.TempC)
+	}
+	if reading.SetHeatC == nil || math.Abs(*reading.SetHeatC-20.0) > 0.001 {
+		t.Errorf("Expected heat setpoint 20C, got %v", reading.SetHeatC)
+	}
+	if reading.Humidity == nil || *reading.Humidity != 38 {
+		t.Errorf("Expected humidity 38, got %v", reading.Humidity)
+	}
+	if reading.Mode != "heat" {
+		t.Errorf("Expected mode heat, got %s", reading.Mode)
+	}
+}
+
+func TestParseEquipmentStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  string
+		running []string
+	}{
+		{name: "idle", status: "", running: nil},
+		{name: "heat pump and fan", status: "heatPump,fan", running: []string{"compHeat1", "fan"}},
+		{name: "aux heat", status: "heatPump, heatPump2, auxHeat1, fan", running: []string{"compHeat1", "compHeat2", "auxHeat1", "fan"}},
+		{name: "unmapped equipment is ignored", status: "humidifier", running: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equipment := parseEquipmentStatus(tt.status)
+
+			count := 0
+			for _, on := range equipment {
+				if on {
+					count++
+				}
+			}
+			if count != len(tt.running) {
+				t.Errorf("Expected %d running, got %v", len(tt.running), equipment)
+			}
+			for _, key := range tt.running {
+				if !equipment[key] {
+					t.Errorf("Expected %s to be running, got %v", key, equipment)
+				}
+			}
+			if _, ok := equipment["compCool1"]; !ok {
+				t.Error("Expected idle equipment to be reported as false")
+			}
+		})
+	}
+}
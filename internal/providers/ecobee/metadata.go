@@ -0,0 +1,136 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// location mirrors the Ecobee thermostat location object. Street address and
+// phone number are deliberately not decoded.
+type location struct {
+	City           string `json:"city"`
+	ProvinceState  string `json:"provinceState"`
+	Country        string `json:"country"`
+	PostalCode     string `json:"postalCode"`
+	MapCoordinates string `json:"mapCoordinates"` // "lat,long"
+	TimeZone       string `json:"timeZone"`       // IANA name
+}
+
+// houseDetails mirrors the Ecobee houseDetails object
+type houseDetails struct {
+	Size int `json:"size"` // square feet
+}
+
+// equipmentSettings holds the settings flags that describe installed equipment
+type equipmentSettings struct {
+	HasHeatPump  bool `json:"hasHeatPump"`
+	HasForcedAir bool `json:"hasForcedAir"`
+	HasBoiler    bool `json:"hasBoiler"`
+	HasElectric  bool `json:"hasElectric"`
+}
+
+// GetMetadata returns location and installation details for a thermostat
+func (p *Provider) GetMetadata(ctx context.Context, tr model.ThermostatRef) (model.Metadata, error) {
+	selectionJSON, err := json.Marshal(SelectionRequest{Selection: NewMetadataSelection(tr.ID)})
+	if err != nil {
+		return model.Metadata{}, fmt.Errorf(errMsgMarshalSelection, err)
+	}
+
+	resp, err := p.authManager.makeAuthenticatedRequest(ctx, "/thermostat", map[string]string{
+		"json": string(selectionJSON),
+	})
+	if err != nil {
+		return model.Metadata{}, fmt.Errorf("requesting thermostat metadata: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result struct {
+		ThermostatList []struct {
+			Identifier   string            `json:"identifier"`
+			Location     location          `json:"location"`
+			HouseDetails houseDetails      `json:"houseDetails"`
+			Settings     equipmentSettings `json:"settings"`
+		} `json:"thermostatList"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return model.Metadata{}, fmt.Errorf("decoding metadata response: %w", err)
+	}
+
+	for _, t := range result.ThermostatList {
+		if t.Identifier == tr.ID {
+			return model.Metadata{
+				ThermostatRef: tr,
+				CollectedAt:   time.Now(),
+				Location:      toLocation(t.Location, t.HouseDetails, t.Settings),
+			}, nil
+		}
+	}
+
+	return model.Metadata{}, fmt.Errorf("thermostat %s not found in metadata", tr.ID)
+}
+
+// toLocation converts Ecobee location, house and settings objects to a canonical location
+func toLocation(loc location, house houseDetails, settings equipmentSettings) model.Location {
+	result := model.Location{
+		City:       loc.City,
+		Region:     loc.ProvinceState,
+		Country:    loc.Country,
+		PostalCode: loc.PostalCode,
+		HVACType:   hvacType(settings),
+		TimeZone:   loc.TimeZone,
+	}
+
+	if lat, long, ok := parseMapCoordinates(loc.MapCoordinates); ok {
+		result.Latitude = &lat
+		result.Longitude = &long
+	}
+	if house.Size > 0 {
+		size := house.Size
+		result.SquareFootage = &size
+	}
+
+	return result
+}
+
+// hvacType picks the primary heating equipment from Ecobee settings flags
+func hvacType(settings equipmentSettings) string {
+	switch {
+	case settings.HasHeatPump:
+		return "heat_pump"
+	case settings.HasBoiler:
+		return "boiler"
+	case settings.HasForcedAir:
+		return "forced_air"
+	case settings.HasElectric:
+		return "electric"
+	default:
+		return ""
+	}
+}
+
+// parseMapCoordinates parses Ecobee's "lat,long" (or "lat, long") coordinate string
+func parseMapCoordinates(coordinates string) (float64, float64, bool) {
+	parts := strings.Split(coordinates, ",")
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	long, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	return lat, long, true
+}
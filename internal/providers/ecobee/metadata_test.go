@@ -0,0 +1,99 @@
+package ecobee
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToLocation(t *testing.T) {
+	raw := `{
+		"location": {
+			"city": "Chicago",
+			"provinceState": "IL",
+			"country": "USA",
+			"postalCode": "60601",
+			"streetAddress": "1 Main St",
+			"mapCoordinates": "41.8781, -87.6298",
+			"timeZone": "America/Chicago"
+		},
+		"houseDetails": {"size": 1800},
+		"settings": {"hasHeatPump": true, "hasForcedAir": true}
+	}`
+
+	var thermostat struct {
+		Location     location          `json:"location"`
+		HouseDetails houseDetails      `json:"houseDetails"`
+		Settings     equipmentSettings `json:"settings"`
+	}
+	if err := json.Unmarshal([]byte(raw), &thermostat); err != nil {
+		t.Fatalf("Failed to decode fixture: %v", err)
+	}
+
+	result := toLocation(thermostat.Location, thermostat.HouseDetails, thermostat.Settings)
+
+	if result.City != "Chicago" || result.Region != "IL" || result.Country != "USA" || result.PostalCode != "60601" || result.TimeZone != "America/Chicago" {
+		t.Errorf("Unexpected address fields: %+v", result)
+	}
+	if result.Latitude == nil || *result.Latitude != 41.8781 {
+		t.Errorf("Expected latitude 41.8781, got %v", result.Latitude)
+	}
+	if result.Longitude == nil || *result.Longitude != -87.6298 {
+		t.Errorf("Expected longitude -87.6298, got %v", result.Longitude)
+	}
+	if result.SquareFootage == nil || *result.SquareFootage != 1800 {
+		t.Errorf("Expected square footage 1800, got %v", result.SquareFootage)
+	}
+	if result.HVACType != "heat_pump" {
+		t.Errorf("Expected hvac type heat_pump, got %s", result.HVACType)
+	}
+}
+
+func TestParseMapCoordinates(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		lat     float64
+		long    float64
+		wantErr bool
+	}{
+		{name: "without space", input: "41.8781,-87.6298", lat: 41.8781, long: -87.6298},
+		{name: "with space", input: "41.8781, -87.6298", lat: 41.8781, long: -87.6298},
+		{name: "empty", input: "", wantErr: true},
+		{name: "single value", input: "41.8781", wantErr: true},
+		{name: "not a number", input: "north,west", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lat, long, ok := parseMapCoordinates(tt.input)
+			if ok == tt.wantErr {
+				t.Fatalf("Expected ok=%v, got %v", !tt.wantErr, ok)
+			}
+			if !tt.wantErr && (lat != tt.lat || long != tt.long) {
+				t.Errorf("Expected %v,%v, got %v,%v", tt.lat, tt.long, lat, long)
+			}
+		})
+	}
+}
+
+func TestHVACType(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings equipmentSettings
+		expected string
+	}{
+		{name: "heat pump wins over forced air", settings: equipmentSettings{HasHeatPump: true, HasForcedAir: true}, expected: "heat_pump"},
+		{name: "boiler", settings: equipmentSettings{HasBoiler: true}, expected: "boiler"},
+		{name: "forced air", settings: equipmentSettings{HasForcedAir: true}, expected: "forced_air"},
+		{name: "electric", settings: equipmentSettings{HasElectric: true}, expected: "electric"},
+		{name: "unknown", settings: equipmentSettings{}, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := hvacType(tt.settings); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -15,6 +17,16 @@ import (
 const (
 	ecobeeRuntimeDateFormat = "2006-01-02"
 	errMsgMarshalSelection  = "marshaling selection: %w"
+
+	// maxRuntimeReportThermostats is the most thermostats a runtime report accepts
+	maxRuntimeReportThermostats = 25
+
+	// runtimeColumns are requested from the runtime report. Equipment columns
+	// (compHeat1 through ventilator) hold the seconds run in each 5-minute bin,
+	// the data behind the Home IQ monthly reports.
+	runtimeColumns = "zoneHeatTemp,zoneCoolTemp,zoneAveTemp,outdoorTemp,outdoorHumidity," +
+		"compHeat1,compHeat2,compCool1,compCool2,auxHeat1,auxHeat2,auxHeat3,fan," +
+		"humidifier,dehumidifier,ventilator,hvacMode,zoneClimateRef"
 )
 
 // Provider implements the Ecobee thermostat provider
@@ -38,9 +50,17 @@ func (p *Provider) Info() model.ProviderInfo {
 	}
 }
 
-// ListThermostats returns all thermostats available to this provider
+// ListThermostats returns all thermostats available to this provider, with
+// the time zone each reports local times in
 func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
-	resp, err := p.authManager.makeAuthenticatedRequest(ctx, "/thermostat", map[string]string{})
+	selectionJSON, err := json.Marshal(SelectionRequest{Selection: NewListSelection()})
+	if err != nil {
+		return nil, fmt.Errorf(errMsgMarshalSelection, err)
+	}
+
+	resp, err := p.authManager.makeAuthenticatedRequest(ctx, "/thermostat", map[string]string{
+		"json": string(selectionJSON),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("requesting thermostats: %w", err)
 	}
@@ -50,9 +70,10 @@ func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef,
 
 	var result struct {
 		ThermostatList []struct {
-			Identifier string `json:"identifier"`
-			Name       string `json:"name"`
-			HouseID    string `json:"houseId"`
+			Identifier string   `json:"identifier"`
+			Name       string   `json:"name"`
+			HouseID    string   `json:"houseId"`
+			Location   location `json:"location"`
 		} `json:"thermostatList"`
 	}
 
@@ -67,6 +88,7 @@ func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef,
 			Name:        t.Name,
 			Provider:    "ecobee",
 			HouseholdID: t.HouseID,
+			TimeZone:    t.Location.TimeZone,
 		})
 	}
 
@@ -75,17 +97,37 @@ func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef,
 
 // GetSummary returns high-level information for change detection
 func (p *Provider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
-	selection := NewSummarySelection(tr.ID)
+	summaries, err := p.fetchSummaries(ctx, NewSummarySelection(tr.ID))
+	if err != nil {
+		return model.Summary{}, err
+	}
+
+	summary, ok := summaries[tr.ID]
+	if !ok {
+		return model.Summary{}, fmt.Errorf("thermostat %s not found in summary", tr.ID)
+	}
+	summary.ThermostatRef = tr
+	return summary, nil
+}
+
+// GetSummaries returns summaries for every registered thermostat in a single
+// thermostatSummary request. Summaries carry only the thermostat ID and provider.
+func (p *Provider) GetSummaries(ctx context.Context) (map[string]model.Summary, error) {
+	return p.fetchSummaries(ctx, NewRegisteredSummarySelection())
+}
+
+// fetchSummaries calls the thermostatSummary endpoint and returns summaries keyed by thermostat ID
+func (p *Provider) fetchSummaries(ctx context.Context, selection Selection) (map[string]model.Summary, error) {
 	selectionJSON, err := json.Marshal(selection)
 	if err != nil {
-		return model.Summary{}, fmt.Errorf(errMsgMarshalSelection, err)
+		return nil, fmt.Errorf(errMsgMarshalSelection, err)
 	}
 
 	resp, err := p.authManager.makeAuthenticatedRequest(ctx, "/thermostatSummary", map[string]string{
 		"json": string(selectionJSON),
 	})
 	if err != nil {
-		return model.Summary{}, fmt.Errorf("requesting thermostat summary: %w", err)
+		return nil, fmt.Errorf("requesting thermostat summary: %w", err)
 	}
 	defer func() {
 		_ = resp.Body.Close()
@@ -105,21 +147,22 @@ func (p *Provider) GetSummary(ctx context.Context, tr model.ThermostatRef) (mode
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return model.Summary{}, fmt.Errorf("decoding summary response: %w", err)
+		return nil, fmt.Errorf("decoding summary response: %w", err)
 	}
 
-	// Find the specific thermostat
+	now := time.Now()
+	summaries := make(map[string]model.Summary, len(result.StatusList))
 	for _, status := range result.StatusList {
-		if status.ThermostatIdentifier == tr.ID {
-			return model.Summary{
-				ThermostatRef: tr,
-				Revision:      status.ThermostatRevision,
-				LastUpdate:    time.Now(),
-			}, nil
+		connected := status.Connected
+		summaries[status.ThermostatIdentifier] = model.Summary{
+			ThermostatRef: model.ThermostatRef{ID: status.ThermostatIdentifier, Provider: "ecobee"},
+			Revision:      status.ThermostatRevision,
+			LastUpdate:    now,
+			Connected:     &connected,
 		}
 	}
 
-	return model.Summary{}, fmt.Errorf("thermostat %s not found in summary", tr.ID)
+	return summaries, nil
 }
 
 // GetSnapshot returns current thermostat state
@@ -142,11 +185,15 @@ func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, sinc
 
 	var result struct {
 		ThermostatList []struct {
-			Identifier string `json:"identifier"`
-			Name       string `json:"name"`
-			Runtime    any    `json:"runtime,omitempty"`
-			Events     []any  `json:"events,omitempty"`
-			Program    any    `json:"program,omitempty"`
+			Identifier  string `json:"identifier"`
+			Name        string `json:"name"`
+			ModelNumber string `json:"modelNumber"`
+			Version     struct {
+				ThermostatFirmwareVersion string `json:"thermostatFirmwareVersion"`
+			} `json:"version"`
+			Runtime any             `json:"runtime,omitempty"`
+			Events  json.RawMessage `json:"events,omitempty"`
+			Program any             `json:"program,omitempty"`
 		} `json:"thermostatList"`
 	}
 
@@ -157,11 +204,18 @@ func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, sinc
 	// Find the specific thermostat
 	for _, t := range result.ThermostatList {
 		if t.Identifier == tr.ID {
+			rawEvents, events, err := parseEvents(t.Events, thermostatLocation(tr))
+			if err != nil {
+				return model.Snapshot{}, fmt.Errorf("parsing snapshot events: %w", err)
+			}
 			return model.Snapshot{
-				ThermostatRef: tr,
-				CollectedAt:   time.Now(),
-				Program:       t.Program,
-				EventsActive:  t.Events,
+				ThermostatRef:   tr,
+				CollectedAt:     time.Now(),
+				Model:           t.ModelNumber,
+				FirmwareVersion: t.Version.ThermostatFirmwareVersion,
+				Program:         t.Program,
+				EventsActive:    rawEvents,
+				Events:          events,
 			}, nil
 		}
 	}
@@ -171,11 +225,41 @@ func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, sinc
 
 // GetRuntime returns historical runtime data for the specified time range
 func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	rows, err := p.fetchRuntime(ctx, []model.ThermostatRef{tr}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return rows[tr.ID], nil
+}
+
+// GetRuntimeMulti returns historical runtime data for several thermostats,
+// requesting up to maxRuntimeReportThermostats of them per runtime report
+func (p *Provider) GetRuntimeMulti(ctx context.Context, trs []model.ThermostatRef, from, to time.Time) (map[string][]model.RuntimeRow, error) {
+	rows := make(map[string][]model.RuntimeRow, len(trs))
+	for batch := range slices.Chunk(trs, maxRuntimeReportThermostats) {
+		batchRows, err := p.fetchRuntime(ctx, batch, from, to)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(rows, batchRows)
+	}
+	return rows, nil
+}
+
+// fetchRuntime requests one runtime report covering trs and returns the rows
+// keyed by thermostat ID. Reports for thermostats not in trs are ignored.
+func (p *Provider) fetchRuntime(ctx context.Context, trs []model.ThermostatRef, from, to time.Time) (map[string][]model.RuntimeRow, error) {
 	// Format dates for Ecobee API (YYYY-MM-DD)
-	startDate := from.Format(ecobeeRuntimeDateFormat)
-	endDate := to.Format(ecobeeRuntimeDateFormat)
+	startDate, endDate := reportDates(trs, from, to)
+
+	refs := make(map[string]model.ThermostatRef, len(trs))
+	ids := make([]string, 0, len(trs))
+	for _, tr := range trs {
+		refs[tr.ID] = tr
+		ids = append(ids, tr.ID)
+	}
 
-	selection := NewThermostatSelection(tr.ID)
+	selection := NewThermostatsSelection(ids)
 	selectionJSON, err := json.Marshal(selection)
 	if err != nil {
 		return nil, fmt.Errorf(errMsgMarshalSelection, err)
@@ -184,7 +268,7 @@ func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from,
 	params := map[string]string{
 		"startDate": startDate,
 		"endDate":   endDate,
-		"columns":   "zoneHeatTemp,zoneCoolTemp,zoneAveTemp,outdoorTemp,outdoorHumidity,compHeat1,compHeat2,compCool1,compCool2,fan,hvacMode,zoneClimateRef",
+		"columns":   runtimeColumns,
 		"json":      string(selectionJSON),
 	}
 
@@ -202,6 +286,7 @@ func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from,
 			Columns              string `json:"columns"`
 			Data                 []struct {
 				Date string   `json:"date"`
+				Time string   `json:"time"`
 				Zone string   `json:"zone"`
 				Data []string `json:"data"`
 			} `json:"data"`
@@ -212,31 +297,31 @@ func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from,
 		return nil, fmt.Errorf("decoding runtime report response: %w", err)
 	}
 
-	var runtimeRows []model.RuntimeRow
+	runtimeRows := make(map[string][]model.RuntimeRow, len(trs))
 
 	// Parse the runtime data
 	for _, report := range result.ReportList {
-		if report.ThermostatIdentifier != tr.ID {
+		tr, ok := refs[report.ThermostatIdentifier]
+		if !ok {
 			continue
 		}
 
 		// Parse column headers
 		columns := parseColumns(report.Columns)
+		loc := thermostatLocation(tr)
 
 		for _, dataRow := range report.Data {
 			row := model.RuntimeRow{
 				ThermostatRef: tr,
 			}
 
-			// Parse date and time
-			date, err := time.Parse(ecobeeRuntimeDateFormat, dataRow.Date)
+			// Rows are stamped with the start of their 5-minute bin in
+			// thermostat local time
+			eventTime, err := parseThermostatTime(dataRow.Date, dataRow.Time, loc)
 			if err != nil {
 				continue // Skip invalid dates
 			}
-
-			// Ecobee provides 5-minute intervals, so we need to calculate the time
-			// This is a simplified parsing - in reality you'd need to handle the zone and time components
-			row.EventTime = date
+			row.EventTime = eventTime
 
 			// Parse data values based on column positions
 			for i, value := range dataRow.Data {
@@ -281,21 +366,64 @@ func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from,
 					row.Mode = value
 				case "zoneClimateRef":
 					row.Climate = value
-				case "compHeat1", "compHeat2", "compCool1", "compCool2", "fan":
+				case "compHeat1", "compHeat2", "compCool1", "compCool2", "auxHeat1", "auxHeat2", "auxHeat3", "fan",
+					"humidifier", "dehumidifier", "ventilator":
 					if row.Equipment == nil {
 						row.Equipment = make(map[string]bool)
 					}
-					row.Equipment[columns[i]] = value == "1" || value == "true"
+					// Equipment columns report the seconds the equipment ran in the bin
+					if seconds := parseInt(value); seconds != nil {
+						if row.EquipmentSecs == nil {
+							row.EquipmentSecs = make(map[string]int)
+						}
+						row.EquipmentSecs[columns[i]] = *seconds
+						row.Equipment[columns[i]] = *seconds > 0
+					} else {
+						row.Equipment[columns[i]] = value == "true"
+					}
 				}
 			}
 
-			runtimeRows = append(runtimeRows, row)
+			runtimeRows[tr.ID] = append(runtimeRows[tr.ID], row)
 		}
 	}
 
 	return runtimeRows, nil
 }
 
+// UseCredentialFiles reads the client ID and refresh token from files; see
+// AuthManager.UseCredentialFiles
+func (p *Provider) UseCredentialFiles(clientIDPath, refreshTokenPath string) error {
+	return p.authManager.UseCredentialFiles(clientIDPath, refreshTokenPath)
+}
+
+// ReloadCredentials re-reads the credential files, if any are configured
+func (p *Provider) ReloadCredentials() (bool, error) {
+	return p.authManager.ReloadCredentials()
+}
+
+// SetMaxResponseBytes sets the largest response body read from Ecobee; see
+// AuthManager.SetMaxResponseBytes
+func (p *Provider) SetMaxResponseBytes(limit int64) {
+	p.authManager.SetMaxResponseBytes(limit)
+}
+
+// BytesFetched returns the response bytes read from Ecobee
+func (p *Provider) BytesFetched() int64 {
+	return p.authManager.BytesFetched()
+}
+
+// SetRefreshMargin sets how early the access token is refreshed in the
+// background; see AuthManager.SetRefreshMargin
+func (p *Provider) SetRefreshMargin(margin, jitter time.Duration) {
+	p.authManager.SetRefreshMargin(margin, jitter)
+}
+
+// RunBackground refreshes the access token ahead of expiry until ctx is cancelled
+func (p *Provider) RunBackground(ctx context.Context) {
+	p.authManager.RunRefresher(ctx)
+}
+
 // Auth returns the authentication manager for this provider
 func (p *Provider) Auth() model.AuthManager {
 	return p.authManager
@@ -2,7 +2,14 @@ package ecobee
 
 import (
 	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/providertest"
 )
 
 func TestParseFloat(t *testing.T) {
@@ -246,3 +253,77 @@ func floatPtr(f float64) *float64 {
 func intPtr(i int) *int {
 	return &i
 }
+
+// fakeAPI answers the endpoints the provider polls for two thermostats,
+// honoring the selection of each request
+func fakeAPI(t *testing.T) http.HandlerFunc {
+	thermostats := []map[string]any{
+		{"identifier": "t1", "name": "Hallway", "location": map[string]any{"timeZone": "America/Denver"}},
+		{"identifier": "t2", "name": "Office"},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request SelectionRequest
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("json")), &request); err != nil {
+			t.Errorf("Failed to decode selection: %v", err)
+		}
+		selection := request.Selection
+		if selection.SelectionType == "" {
+			_ = json.Unmarshal([]byte(r.URL.Query().Get("json")), &selection)
+		}
+		selected := func(id string) bool {
+			return selection.SelectionType == "registered" || slices.Contains(strings.Split(selection.SelectionMatch, ","), id)
+		}
+
+		var body map[string]any
+		switch r.URL.Path {
+		case "/thermostat":
+			var list []map[string]any
+			for _, thermostat := range thermostats {
+				if selected(thermostat["identifier"].(string)) {
+					list = append(list, thermostat)
+				}
+			}
+			body = map[string]any{"thermostatList": list}
+		case "/thermostatSummary":
+			var statuses []map[string]any
+			for _, thermostat := range thermostats {
+				if id := thermostat["identifier"].(string); selected(id) {
+					statuses = append(statuses, map[string]any{"thermostatIdentifier": id, "connected": true, "thermostatRevision": "250110120000"})
+				}
+			}
+			body = map[string]any{"thermostatCount": len(statuses), "statusList": statuses}
+		case "/runtimeReport":
+			var reports []map[string]any
+			for _, thermostat := range thermostats {
+				if id := thermostat["identifier"].(string); selected(id) {
+					reports = append(reports, map[string]any{
+						"thermostatIdentifier": id,
+						"columns":              "zoneAveTemp,hvacMode",
+						"data": []map[string]any{
+							{"date": r.URL.Query().Get("startDate"), "time": "00:00:00", "data": []string{"700", "heat"}},
+							{"date": r.URL.Query().Get("startDate"), "time": "00:05:00", "data": []string{"701", "heat"}},
+						},
+					})
+				}
+			}
+			body = map[string]any{"reportList": reports}
+		default:
+			t.Errorf("Unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func TestProviderConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		New: func(t *testing.T) model.Provider {
+			return newTestProvider(t, fakeAPI(t))
+		},
+		RuntimeFrom:         time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		RuntimeTo:           time.Date(2025, 1, 10, 1, 0, 0, 0, time.UTC),
+		RequireRuntime:      true,
+		RequireCancellation: true,
+	})
+}
@@ -0,0 +1,181 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestGetRuntimeMulti(t *testing.T) {
+	var matches []string
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/runtimeReport" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		var selection Selection
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("json")), &selection); err != nil {
+			t.Errorf("Failed to decode selection: %v", err)
+		}
+		matches = append(matches, selection.SelectionMatch)
+
+		var reports []string
+		for _, id := range strings.Split(selection.SelectionMatch, ",") {
+			reports = append(reports, fmt.Sprintf(`{
+				"thermostatIdentifier": %q,
+				"columns": "zoneAveTemp,hvacMode",
+				"data": [{"date": "2025-01-10", "data": ["700", "heat"]}]
+			}`, id))
+		}
+		_, _ = w.Write([]byte(`{"reportList": [` + strings.Join(reports, ",") + `]}`))
+	})
+
+	var thermostats []model.ThermostatRef
+	for i := range 30 {
+		thermostats = append(thermostats, model.ThermostatRef{ID: fmt.Sprintf("t%d", i), Provider: "ecobee"})
+	}
+
+	from := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	rows, err := provider.GetRuntimeMulti(context.Background(), thermostats, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(matches) != 2 || len(strings.Split(matches[0], ",")) != maxRuntimeReportThermostats {
+		t.Errorf("Expected two requests of at most %d thermostats, got %v", maxRuntimeReportThermostats, matches)
+	}
+	if len(rows) != len(thermostats) {
+		t.Fatalf("Expected rows for %d thermostats, got %d", len(thermostats), len(rows))
+	}
+	row := rows["t29"][0]
+	if row.ThermostatRef.ID != "t29" || row.Mode != "heat" {
+		t.Errorf("Unexpected row: %+v", row)
+	}
+}
+
+func TestGetRuntimeEquipmentSeconds(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if columns := r.URL.Query().Get("columns"); !strings.Contains(columns, "humidifier,dehumidifier,ventilator") {
+			t.Errorf("Expected extended equipment columns, got %s", columns)
+		}
+		_, _ = w.Write([]byte(`{"reportList": [{
+			"thermostatIdentifier": "t1",
+			"columns": "compHeat1,fan,humidifier,dehumidifier,ventilator",
+			"data": [{"date": "2025-01-10", "data": ["300", "120", "0", "", "45"]}]
+		}]}`))
+	})
+
+	from := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	rows, err := provider.GetRuntime(context.Background(), model.ThermostatRef{ID: "t1", Provider: "ecobee"}, from, from.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("Expected 1 row, got %d", len(rows))
+	}
+
+	row := rows[0]
+	expectedSeconds := map[string]int{"compHeat1": 300, "fan": 120, "humidifier": 0, "ventilator": 45}
+	for key, seconds := range expectedSeconds {
+		if row.EquipmentSecs[key] != seconds {
+			t.Errorf("Expected %s to run %ds, got %d", key, seconds, row.EquipmentSecs[key])
+		}
+		if row.Equipment[key] != (seconds > 0) {
+			t.Errorf("Expected %s on=%v, got %v", key, seconds > 0, row.Equipment[key])
+		}
+	}
+	if _, ok := row.EquipmentSecs["dehumidifier"]; ok {
+		t.Error("Expected a blank column to have no runtime seconds")
+	}
+}
+
+func TestGetRuntimeTimeZones(t *testing.T) {
+	tests := []struct {
+		name          string
+		timeZone      string
+		expectedStart string // report startDate for a request from 03:00 UTC
+		expectedTime  time.Time
+	}{
+		{name: "no zone is read as UTC", expectedStart: "2025-01-10", expectedTime: time.Date(2025, 1, 10, 6, 30, 0, 0, time.UTC)},
+		{name: "unknown zone is read as UTC", timeZone: "Mars/Olympus", expectedStart: "2025-01-10", expectedTime: time.Date(2025, 1, 10, 6, 30, 0, 0, time.UTC)},
+		{name: "west of UTC", timeZone: "America/Chicago", expectedStart: "2025-01-09", expectedTime: time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)},
+		{name: "east of UTC", timeZone: "Asia/Kolkata", expectedStart: "2025-01-10", expectedTime: time.Date(2025, 1, 10, 1, 0, 0, 0, time.UTC)},
+		{name: "southern hemisphere summer time", timeZone: "Australia/Sydney", expectedStart: "2025-01-10", expectedTime: time.Date(2025, 1, 9, 19, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				if start := r.URL.Query().Get("startDate"); start != tt.expectedStart {
+					t.Errorf("Expected startDate %s, got %s", tt.expectedStart, start)
+				}
+				_, _ = w.Write([]byte(`{"reportList": [{
+					"thermostatIdentifier": "t1",
+					"columns": "hvacMode",
+					"data": [{"date": "2025-01-10", "time": "06:30:00", "data": ["heat"]}]
+				}]}`))
+			})
+
+			from := time.Date(2025, 1, 10, 3, 0, 0, 0, time.UTC)
+			tr := model.ThermostatRef{ID: "t1", Provider: "ecobee", TimeZone: tt.timeZone}
+			rows, err := provider.GetRuntime(context.Background(), tr, from, from.Add(time.Hour))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(rows) != 1 {
+				t.Fatalf("Expected 1 row, got %d", len(rows))
+			}
+			if !rows[0].EventTime.Equal(tt.expectedTime) {
+				t.Errorf("Expected event time %v, got %v", tt.expectedTime, rows[0].EventTime.UTC())
+			}
+		})
+	}
+}
+
+func TestListThermostatsTimeZone(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var request SelectionRequest
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("json")), &request); err != nil {
+			t.Errorf("Failed to decode selection: %v", err)
+		}
+		if !request.Selection.IncludeLocation {
+			t.Error("Expected the listing to include location")
+		}
+		_, _ = w.Write([]byte(`{"thermostatList": [
+			{"identifier": "t1", "name": "Upstairs", "location": {"timeZone": "America/Denver"}},
+			{"identifier": "t2", "name": "Cabin"}
+		]}`))
+	})
+
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thermostats) != 2 {
+		t.Fatalf("Expected 2 thermostats, got %d", len(thermostats))
+	}
+	if thermostats[0].TimeZone != "America/Denver" || thermostats[1].TimeZone != "" {
+		t.Errorf("Unexpected time zones: %q, %q", thermostats[0].TimeZone, thermostats[1].TimeZone)
+	}
+}
+
+func TestGetRuntimeRejectsOversizedResponse(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"reportList":[{"thermostatIdentifier":"t1","rowList":["` + strings.Repeat("x", 4096) + `"]}]}`))
+	})
+	provider.SetMaxResponseBytes(1024)
+
+	from := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	_, err := provider.GetRuntime(context.Background(), model.ThermostatRef{ID: "t1"}, from, from.Add(time.Hour))
+	var tooLarge *bodylimit.TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 1024 {
+		t.Fatalf("Expected a TooLargeError with limit 1024, got %v", err)
+	}
+}
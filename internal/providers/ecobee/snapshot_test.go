@@ -0,0 +1,37 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestGetSnapshotVersion(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		var selection Selection
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("json")), &selection); err != nil {
+			t.Errorf("Failed to decode selection: %v", err)
+		}
+		if !selection.IncludeVersion {
+			t.Error("Expected the snapshot to include the version")
+		}
+		_, _ = w.Write([]byte(`{"thermostatList": [{
+			"identifier": "t1",
+			"name": "Hallway",
+			"modelNumber": "athenaSmart",
+			"version": {"thermostatFirmwareVersion": "4.8.7.132"}
+		}]}`))
+	})
+
+	snapshot, err := provider.GetSnapshot(context.Background(), model.ThermostatRef{ID: "t1", Provider: "ecobee"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if snapshot.Model != "athenaSmart" || snapshot.FirmwareVersion != "4.8.7.132" {
+		t.Errorf("Expected model athenaSmart and firmware 4.8.7.132, got %q and %q", snapshot.Model, snapshot.FirmwareVersion)
+	}
+}
@@ -0,0 +1,88 @@
+package ecobee
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// newTestProvider returns a provider with a valid token pointed at a test server
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	originalURL := ecobeeAPIURL
+	ecobeeAPIURL = server.URL
+	t.Cleanup(func() { ecobeeAPIURL = originalURL })
+
+	provider := NewProvider("client", "refresh")
+	provider.authManager.accessToken = "token"
+	provider.authManager.tokenExpiry = time.Now().Add(time.Hour)
+	return provider
+}
+
+func TestGetSummaries(t *testing.T) {
+	var requests int
+	var selection Selection
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/thermostatSummary" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if err := json.Unmarshal([]byte(r.URL.Query().Get("json")), &selection); err != nil {
+			t.Errorf("Failed to decode selection: %v", err)
+		}
+		_, _ = w.Write([]byte(`{
+			"thermostatCount": 2,
+			"statusList": [
+				{"thermostatIdentifier": "t1", "connected": true, "thermostatRevision": "250110120000"},
+				{"thermostatIdentifier": "t2", "connected": false, "thermostatRevision": "250110120500"}
+			]
+		}`))
+	})
+
+	summaries, err := provider.GetSummaries(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected a single request, got %d", requests)
+	}
+	if selection.SelectionType != "registered" {
+		t.Errorf("Expected registered selection, got %q", selection.SelectionType)
+	}
+	if len(summaries) != 2 || summaries["t2"].Revision != "250110120500" {
+		t.Errorf("Unexpected summaries: %+v", summaries)
+	}
+	if connected := summaries["t1"].Connected; connected == nil || !*connected {
+		t.Errorf("Expected t1 to be connected, got %v", connected)
+	}
+	if connected := summaries["t2"].Connected; connected == nil || *connected {
+		t.Errorf("Expected t2 to be disconnected, got %v", connected)
+	}
+
+	t.Run("single summary keeps the caller's reference", func(t *testing.T) {
+		tr := model.ThermostatRef{ID: "t1", Name: "Hallway", Provider: "ecobee"}
+		summary, err := provider.GetSummary(context.Background(), tr)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if summary.ThermostatRef != tr || summary.Revision != "250110120000" {
+			t.Errorf("Unexpected summary: %+v", summary)
+		}
+	})
+
+	t.Run("missing thermostat is an error", func(t *testing.T) {
+		if _, err := provider.GetSummary(context.Background(), model.ThermostatRef{ID: "t3"}); err == nil {
+			t.Error("Expected error for unknown thermostat")
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package ecobee
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ecobeeDateTimeFormat parses Ecobee's separate date and time fields once
+// joined with a space
+const ecobeeDateTimeFormat = "2006-01-02 15:04:05"
+
+// thermostatLocation returns the time zone a thermostat reports local times
+// in. Thermostats without a known zone fall back to UTC.
+func thermostatLocation(tr model.ThermostatRef) *time.Location {
+	if tr.TimeZone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(tr.TimeZone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// parseThermostatTime combines a date and a time of day in thermostat local
+// time. A missing time of day is midnight.
+func parseThermostatTime(date, clock string, loc *time.Location) (time.Time, error) {
+	if clock == "" {
+		clock = "00:00:00"
+	}
+	return time.ParseInLocation(ecobeeDateTimeFormat, date+" "+clock, loc)
+}
+
+// reportDates returns the runtime report's startDate and endDate. Ecobee
+// reads both in each thermostat's local time, so the range spans the local
+// dates of from and to across every zone in the report.
+func reportDates(trs []model.ThermostatRef, from, to time.Time) (string, string) {
+	var start, end string
+	for _, tr := range trs {
+		loc := thermostatLocation(tr)
+		if date := from.In(loc).Format(ecobeeRuntimeDateFormat); start == "" || date < start {
+			start = date
+		}
+		if date := to.In(loc).Format(ecobeeRuntimeDateFormat); date > end {
+			end = date
+		}
+	}
+	if start == "" {
+		return from.UTC().Format(ecobeeRuntimeDateFormat), to.UTC().Format(ecobeeRuntimeDateFormat)
+	}
+	return start, end
+}
@@ -0,0 +1,299 @@
+package nest
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/secret"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	googleTokenURL = cmp.Or(os.Getenv("NEST_TOKEN_URL"), "https://oauth2.googleapis.com/token")
+	sdmAPIURL      = cmp.Or(os.Getenv("NEST_API_URL"), "https://smartdevicemanagement.googleapis.com/v1")
+)
+
+// expirySkew is how long before expiry a token is no longer used, to allow
+// for clock drift and requests in flight
+const expirySkew = 30 * time.Second
+
+// AuthManager implements OAuth for the Smart Device Management API. Google
+// refresh tokens do not rotate, so the configured one is used for every
+// access token.
+type AuthManager struct {
+	// credMu guards the credentials, which ReloadCredentials may replace
+	credMu           sync.Mutex
+	clientID         string
+	clientSecret     string
+	refreshToken     string
+	clientSecretFile *secret.File
+	refreshTokenFile *secret.File
+
+	// tokenMu guards the access token, which concurrent polls share;
+	// refreshes collapses overlapping refreshes into one token request
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+	refreshes   singleflight.Group
+
+	httpClient  *http.Client
+	retryConfig retry.Config
+
+	// maxResponseBytes bounds every response body read from Google and
+	// bytesFetched counts what was read of them
+	maxResponseBytes int64
+	bytesFetched     bodylimit.Counter
+
+	// throttle records the deadline when the API answers 429 so that
+	// subsequent calls fail fast instead of spending more of the project's quota
+	throttle retry.Throttle
+}
+
+// NewAuthManager creates a new Smart Device Management authentication manager
+func NewAuthManager(clientID, clientSecret, refreshToken string) *AuthManager {
+	retryConfig := retry.DefaultConfig()
+	retryConfig.MaxRetries = 3
+	retryConfig.InitialDelay = 1 * time.Second
+	retryConfig.MaxDelay = 30 * time.Second
+
+	return &AuthManager{
+		clientID:         clientID,
+		clientSecret:     clientSecret,
+		refreshToken:     refreshToken,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		retryConfig:      retryConfig,
+		maxResponseBytes: bodylimit.DefaultMaxBytes,
+	}
+}
+
+// SetMaxResponseBytes sets the largest response body read from Google; a
+// larger one fails with a *bodylimit.TooLargeError. Call it before use.
+func (a *AuthManager) SetMaxResponseBytes(limit int64) {
+	a.maxResponseBytes = limit
+}
+
+// UseCredentialFiles reads the client secret and refresh token from files,
+// which ReloadCredentials re-reads. Either path may be empty to keep the
+// configured value.
+func (a *AuthManager) UseCredentialFiles(clientSecretPath, refreshTokenPath string) error {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	if clientSecretPath != "" {
+		file, err := secret.NewFile(clientSecretPath)
+		if err != nil {
+			return fmt.Errorf("reading client_secret: %w", err)
+		}
+		a.clientSecretFile = file
+		a.clientSecret = file.Value()
+	}
+	if refreshTokenPath != "" {
+		file, err := secret.NewFile(refreshTokenPath)
+		if err != nil {
+			return fmt.Errorf("reading refresh_token: %w", err)
+		}
+		a.refreshTokenFile = file
+		a.refreshToken = file.Value()
+	}
+	return nil
+}
+
+// ReloadCredentials re-reads the credential files. A changed credential
+// discards the access token issued under the old one.
+func (a *AuthManager) ReloadCredentials() (bool, error) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	changed := false
+	if a.clientSecretFile != nil {
+		updated, err := a.clientSecretFile.Reload()
+		if err != nil {
+			return false, fmt.Errorf("reloading client_secret: %w", err)
+		}
+		if updated {
+			a.clientSecret = a.clientSecretFile.Value()
+			changed = true
+		}
+	}
+	if a.refreshTokenFile != nil {
+		updated, err := a.refreshTokenFile.Reload()
+		if err != nil {
+			return changed, fmt.Errorf("reloading refresh_token: %w", err)
+		}
+		if updated {
+			a.refreshToken = a.refreshTokenFile.Value()
+			changed = true
+		}
+	}
+	if changed {
+		a.tokenMu.Lock()
+		a.accessToken = ""
+		a.tokenMu.Unlock()
+	}
+	return changed, nil
+}
+
+// credentials returns the current client ID, client secret and refresh token
+func (a *AuthManager) credentials() (string, string, string) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+	return a.clientID, a.clientSecret, a.refreshToken
+}
+
+// RefreshToken requests a new access token. Callers arriving while a
+// refresh is in flight wait for its result instead of starting another.
+func (a *AuthManager) RefreshToken(ctx context.Context) error {
+	// The shared request must not fail for every waiter because the caller
+	// that started it gave up; the HTTP client timeout still bounds it
+	result := a.refreshes.DoChan("token", func() (any, error) {
+		return nil, a.requestToken(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-result:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestToken exchanges the refresh token for a new access token
+func (a *AuthManager) requestToken(ctx context.Context) error {
+	clientID, clientSecret, refreshToken := a.credentials()
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", clientID)
+	data.Set("client_secret", clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("creating refresh token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("refreshing token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		throttled := retry.NewThrottledError(resp.StatusCode, retry.RetryAfterFromResponse(resp))
+		a.throttle.Record(throttled)
+		return fmt.Errorf("refreshing token: %w", throttled)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token refresh failed with status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(a.bytesFetched.Limit(resp, a.maxResponseBytes).Body).Decode(&tokenResp); err != nil {
+		return fmt.Errorf("decoding token response: %w", err)
+	}
+
+	a.tokenMu.Lock()
+	a.accessToken = tokenResp.AccessToken
+	a.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	a.tokenMu.Unlock()
+	return nil
+}
+
+// GetAccessToken returns the current access token, refreshing if needed
+func (a *AuthManager) GetAccessToken(ctx context.Context) (string, error) {
+	if token, ok := a.validToken(); ok {
+		return token, nil
+	}
+	if err := a.RefreshToken(ctx); err != nil {
+		return "", fmt.Errorf("refreshing token: %w", err)
+	}
+	token, _ := a.validToken()
+	return token, nil
+}
+
+// IsTokenValid checks if the current token is valid
+func (a *AuthManager) IsTokenValid(ctx context.Context) bool {
+	_, ok := a.validToken()
+	return ok
+}
+
+// validToken returns the access token and whether it can still be used
+func (a *AuthManager) validToken() (string, bool) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	return a.accessToken, a.accessToken != "" && time.Now().Before(a.tokenExpiry.Add(-expirySkew))
+}
+
+// ThrottledUntil returns the time before which the API asked us not to call again
+func (a *AuthManager) ThrottledUntil() time.Time {
+	return a.throttle.Until()
+}
+
+// get makes an authenticated GET request for an API resource path, such as
+// enterprises/<project>/devices, with retry logic. Responses other than 200
+// are returned as errors.
+func (a *AuthManager) get(ctx context.Context, resource string) (*http.Response, error) {
+	if err := a.throttle.Check(); err != nil {
+		return nil, err
+	}
+
+	send := func() (*http.Response, error) {
+		token, err := a.GetAccessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", sdmAPIURL+"/"+resource, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("making request: %w", err)
+		}
+		return resp, nil
+	}
+
+	resp, err := retry.DoWithResponse(ctx, a.retryConfig, func() (*http.Response, error) {
+		resp, err := send()
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		// The token was revoked or expired early; refresh it and try once more
+		_ = resp.Body.Close()
+		if err := a.RefreshToken(ctx); err != nil {
+			return nil, fmt.Errorf("refreshing token after 401: %w", err)
+		}
+		return send()
+	})
+
+	a.throttle.Record(err)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("SDM API returned status %d for %s", resp.StatusCode, resource)
+	}
+	return a.bytesFetched.Limit(resp, a.maxResponseBytes), nil
+}
+
+// BytesFetched returns the response bytes read from Google
+func (a *AuthManager) BytesFetched() int64 {
+	return a.bytesFetched.Bytes()
+}
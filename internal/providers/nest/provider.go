@@ -0,0 +1,184 @@
+package nest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/sampled"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Provider implements the Google Nest thermostat provider on the Smart
+// Device Management (SDM) API. The API reports only current state, so
+// runtime is built from the readings taken on each request: every poll adds
+// a reading, and a 5-minute bin is returned once it has closed.
+type Provider struct {
+	projectID   string
+	authManager *AuthManager
+	readings    *sampled.Readings
+}
+
+// NewProvider creates a new Nest provider for the devices shared with a
+// Device Access project
+func NewProvider(projectID, clientID, clientSecret, refreshToken string) *Provider {
+	return &Provider{
+		projectID:   projectID,
+		authManager: NewAuthManager(clientID, clientSecret, refreshToken),
+		readings:    sampled.NewReadings(sampled.DefaultRetention),
+	}
+}
+
+// Info returns metadata about the provider
+func (p *Provider) Info() model.ProviderInfo {
+	return model.ProviderInfo{
+		Name:        ProviderName,
+		Version:     "1.0.0",
+		Description: "Google Nest thermostat provider using the Smart Device Management API",
+	}
+}
+
+// ListThermostats returns the thermostats shared with the project
+func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	devices, err := p.listDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	thermostats := make([]model.ThermostatRef, 0, len(devices))
+	for _, d := range devices {
+		thermostats = append(thermostats, d.ref())
+	}
+	return thermostats, nil
+}
+
+// GetSummary returns the thermostat's settings revision and connectivity
+func (p *Provider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	d, err := p.getDevice(ctx, tr)
+	if err != nil {
+		return model.Summary{}, err
+	}
+	return summary(tr, d), nil
+}
+
+// GetSummaries returns summaries for every thermostat from a single device
+// list request
+func (p *Provider) GetSummaries(ctx context.Context) (map[string]model.Summary, error) {
+	devices, err := p.listDevices(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]model.Summary, len(devices))
+	for _, d := range devices {
+		summaries[d.ID()] = summary(d.ref(), d)
+	}
+	return summaries, nil
+}
+
+// GetSnapshot returns current thermostat state
+func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	d, err := p.getDevice(ctx, tr)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	return d.snapshot(tr, time.Now()), nil
+}
+
+// GetRuntime takes a reading and returns the closed 5-minute bins after the
+// one starting at from and before to, built from the readings taken so far.
+// Bins from before the provider started, or without a reading, are missing.
+func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	if _, err := p.getDevice(ctx, tr); err != nil {
+		return nil, err
+	}
+	return p.readings.Bins(tr.ID, from, to, time.Now()), nil
+}
+
+// listDevices lists the project's thermostats, recording a reading of each
+func (p *Provider) listDevices(ctx context.Context) ([]device, error) {
+	resp, err := p.authManager.get(ctx, "enterprises/"+p.projectID+"/devices")
+	if err != nil {
+		return nil, fmt.Errorf("requesting devices: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var result struct {
+		Devices []device `json:"devices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decoding devices response: %w", err)
+	}
+
+	now := time.Now()
+	var thermostats []device
+	for _, d := range result.Devices {
+		if d.Type != thermostatType {
+			continue
+		}
+		p.readings.Record(d.reading(d.ref(), now))
+		thermostats = append(thermostats, d)
+	}
+	return thermostats, nil
+}
+
+// getDevice fetches one thermostat, recording a reading of it
+func (p *Provider) getDevice(ctx context.Context, tr model.ThermostatRef) (device, error) {
+	resp, err := p.authManager.get(ctx, "enterprises/"+p.projectID+"/devices/"+tr.ID)
+	if err != nil {
+		return device{}, fmt.Errorf("requesting device %s: %w", tr.ID, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var d device
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		return device{}, fmt.Errorf("decoding device response: %w", err)
+	}
+	if d.Type != thermostatType {
+		return device{}, fmt.Errorf("device %s is not a thermostat", tr.ID)
+	}
+	p.readings.Record(d.reading(tr, time.Now()))
+	return d, nil
+}
+
+// summary builds the summary of a device
+func summary(tr model.ThermostatRef, d device) model.Summary {
+	return model.Summary{
+		ThermostatRef: tr,
+		Revision:      d.revision(),
+		LastUpdate:    time.Now(),
+		Connected:     d.connected(),
+	}
+}
+
+// UseCredentialFiles reads the client secret and refresh token from files;
+// see AuthManager.UseCredentialFiles
+func (p *Provider) UseCredentialFiles(clientSecretPath, refreshTokenPath string) error {
+	return p.authManager.UseCredentialFiles(clientSecretPath, refreshTokenPath)
+}
+
+// ReloadCredentials re-reads the credential files, if any are configured
+func (p *Provider) ReloadCredentials() (bool, error) {
+	return p.authManager.ReloadCredentials()
+}
+
+// SetMaxResponseBytes sets the largest response body read from Google; see
+// AuthManager.SetMaxResponseBytes
+func (p *Provider) SetMaxResponseBytes(limit int64) {
+	p.authManager.SetMaxResponseBytes(limit)
+}
+
+// BytesFetched returns the response bytes read from Google
+func (p *Provider) BytesFetched() int64 {
+	return p.authManager.BytesFetched()
+}
+
+// Auth returns the authentication manager for this provider
+func (p *Provider) Auth() model.AuthManager {
+	return p.authManager
+}
@@ -0,0 +1,189 @@
+package nest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/sampled"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/providertest"
+)
+
+// thermostatDevice returns an SDM thermostat resource in project p
+func thermostatDevice(id, hvac string, ambient float64) map[string]any {
+	return map[string]any{
+		"name": "enterprises/p/devices/" + id,
+		"type": thermostatType,
+		"traits": map[string]any{
+			"sdm.devices.traits.Info":                          map[string]any{"customName": ""},
+			"sdm.devices.traits.Connectivity":                  map[string]any{"status": "ONLINE"},
+			"sdm.devices.traits.Temperature":                   map[string]any{"ambientTemperatureCelsius": ambient},
+			"sdm.devices.traits.ThermostatMode":                map[string]any{"mode": "HEAT", "availableModes": []string{"HEAT", "COOL", "HEATCOOL", "OFF"}},
+			"sdm.devices.traits.ThermostatEco":                 map[string]any{"mode": "OFF", "heatCelsius": 15.5, "coolCelsius": 26.5},
+			"sdm.devices.traits.ThermostatHvac":                map[string]any{"status": hvac},
+			"sdm.devices.traits.ThermostatTemperatureSetpoint": map[string]any{"heatCelsius": 21.0},
+			"sdm.devices.traits.Fan":                           map[string]any{"timerMode": "OFF"},
+		},
+		"parentRelations": []map[string]any{
+			{"parent": "enterprises/p/structures/home/rooms/" + id, "displayName": "Hallway " + id},
+		},
+	}
+}
+
+// fakeSDM answers device list and device requests for two thermostats and
+// a camera
+func fakeSDM(t *testing.T) http.HandlerFunc {
+	devices := []map[string]any{
+		thermostatDevice("t1", "HEATING", 20.5),
+		thermostatDevice("t2", "OFF", 21),
+		{"name": "enterprises/p/devices/cam", "type": "sdm.devices.types.CAMERA"},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		if r.URL.Path == "/enterprises/p/devices" {
+			_ = json.NewEncoder(w).Encode(map[string]any{"devices": devices})
+			return
+		}
+		for _, d := range devices {
+			if "/"+d["name"].(string) == r.URL.Path {
+				_ = json.NewEncoder(w).Encode(d)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}
+}
+
+// newTestProvider returns a provider with a valid token pointed at a test server
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	originalURL := sdmAPIURL
+	sdmAPIURL = server.URL
+	t.Cleanup(func() { sdmAPIURL = originalURL })
+
+	provider := NewProvider("p", "client", "secret", "refresh")
+	provider.authManager.accessToken = "token"
+	provider.authManager.tokenExpiry = time.Now().Add(time.Hour)
+	return provider
+}
+
+func TestProviderConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		New: func(t *testing.T) model.Provider {
+			return newTestProvider(t, fakeSDM(t))
+		},
+		RequireCancellation: true,
+	})
+}
+
+func TestListThermostats(t *testing.T) {
+	provider := newTestProvider(t, fakeSDM(t))
+
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thermostats) != 2 {
+		t.Fatalf("Expected the camera to be skipped, got %+v", thermostats)
+	}
+	expected := model.ThermostatRef{ID: "t1", Name: "Hallway t1", Provider: "nest", HouseholdID: "home"}
+	if thermostats[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, thermostats[0])
+	}
+}
+
+func TestSummaryRevisionIgnoresReadings(t *testing.T) {
+	heating := thermostatDevice("t1", "HEATING", 20.5)
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(heating)
+	})
+	ref := model.ThermostatRef{ID: "t1", Provider: "nest"}
+
+	first, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Connected == nil || !*first.Connected {
+		t.Errorf("Expected the thermostat to be connected, got %v", first.Connected)
+	}
+
+	heating = thermostatDevice("t1", "OFF", 19)
+	second, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Revision != first.Revision {
+		t.Errorf("Expected readings not to change the revision, got %s and %s", first.Revision, second.Revision)
+	}
+
+	heating["traits"].(map[string]any)["sdm.devices.traits.ThermostatEco"] = map[string]any{"mode": "MANUAL_ECO", "heatCelsius": 15.5}
+	third, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if third.Revision == first.Revision {
+		t.Error("Expected eco mode to change the revision")
+	}
+}
+
+func TestSnapshotReportsEcoHold(t *testing.T) {
+	eco := thermostatDevice("t1", "OFF", 18)
+	eco["traits"].(map[string]any)["sdm.devices.traits.ThermostatEco"] = map[string]any{"mode": "MANUAL_ECO", "heatCelsius": 15.5, "coolCelsius": 26.5}
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(eco)
+	})
+
+	snapshot, err := provider.GetSnapshot(context.Background(), model.ThermostatRef{ID: "t1", Provider: "nest"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(snapshot.Events) != 1 || snapshot.Events[0].Name != "eco" || !snapshot.Events[0].Running {
+		t.Fatalf("Expected a running eco hold, got %+v", snapshot.Events)
+	}
+	if *snapshot.Events[0].SetHeatC != 15.5 || *snapshot.Events[0].SetCoolC != 26.5 {
+		t.Errorf("Expected eco setpoints, got %v/%v", *snapshot.Events[0].SetHeatC, *snapshot.Events[0].SetCoolC)
+	}
+}
+
+func TestGetRuntimeReturnsClosedBins(t *testing.T) {
+	provider := newTestProvider(t, fakeSDM(t))
+	ref := model.ThermostatRef{ID: "t1", Provider: "nest"}
+	binStart := time.Now().UTC().Truncate(sampled.BinSize).Add(-sampled.BinSize)
+	provider.readings.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(time.Minute), Mode: "heat",
+		AvgTempC: floatPtr(20), Equipment: map[string]bool{"compHeat1": false}})
+
+	rows, err := provider.GetRuntime(context.Background(), ref, binStart.Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The reading taken by this request falls in the open bin
+	if len(rows) != 1 || !rows[0].EventTime.Equal(binStart) {
+		t.Fatalf("Expected one row for the closed bin at %v, got %+v", binStart, rows)
+	}
+}
+
+func TestAPIErrorStatus(t *testing.T) {
+	provider := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":{"status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+	})
+
+	_, err := provider.ListThermostats(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "status 403") {
+		t.Errorf("Expected a 403 error, got %v", err)
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,352 @@
+package nest
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ProviderName identifies imported Nest rows in the provider block of documents
+const ProviderName = "nest"
+
+// sampleInterval is the spacing of rows in the takeout sensors files
+const sampleInterval = 15 * time.Minute
+
+// equipmentKeys maps takeout cycle flags to canonical equipment keys. Nest does
+// not distinguish a heat pump compressor from a furnace, so stage 1 and 2 heat
+// are reported as compHeat1/compHeat2.
+var equipmentKeys = map[string]string{
+	"heat1":     "compHeat1",
+	"heat2":     "compHeat2",
+	"heatAux":   "auxHeat1",
+	"alt_heat":  "auxHeat1",
+	"emer_heat": "auxHeat1",
+	"cool1":     "compCool1",
+	"cool2":     "compCool2",
+	"fan":       "fan",
+}
+
+// modes maps takeout setpoint event types to canonical modes
+var modes = map[string]string{
+	"EVENT_TYPE_HEAT":  "heat",
+	"EVENT_TYPE_COOL":  "cool",
+	"EVENT_TYPE_RANGE": "auto",
+	"EVENT_TYPE_OFF":   "off",
+}
+
+// Thermostat holds the runtime history of one thermostat in a takeout archive
+type Thermostat struct {
+	Ref  model.ThermostatRef
+	Rows []model.RuntimeRow
+}
+
+// OpenTakeout reads a Google Takeout export, either the downloaded .zip or an
+// extracted directory
+func OpenTakeout(archivePath string) ([]Thermostat, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening takeout: %w", err)
+	}
+	if info.IsDir() {
+		return ParseTakeout(os.DirFS(archivePath))
+	}
+
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("opening takeout archive: %w", err)
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return ParseTakeout(reader)
+}
+
+// ParseTakeout reads Nest thermostat history from a takeout file tree. Each
+// thermostat has a directory below Nest/thermostats/<device id>/ holding
+// monthly <YYYY-MM>-sensors.csv files (indoor temperature every 15 minutes) and
+// <YYYY-MM>-summary.json files (HVAC cycles and setpoint events). Sensor
+// samples become runtime rows; cycles and events set their equipment, mode
+// and setpoints. Times are UTC.
+func ParseTakeout(fsys fs.FS) ([]Thermostat, error) {
+	histories := make(map[string]*history)
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		deviceID, ok := thermostatDir(name)
+		if !ok {
+			return nil
+		}
+		h, ok := histories[deviceID]
+		if !ok {
+			h = &history{samples: make(map[time.Time]float64)}
+			histories[deviceID] = h
+		}
+
+		switch base := path.Base(name); {
+		case strings.HasSuffix(base, "-sensors.csv"):
+			if err := readFile(fsys, name, h.readSensors); err != nil {
+				return err
+			}
+		case strings.HasSuffix(base, "-summary.json"):
+			if err := readFile(fsys, name, h.readSummary); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading takeout: %w", err)
+	}
+	if len(histories) == 0 {
+		return nil, fmt.Errorf("no Nest thermostat history found in takeout")
+	}
+
+	ids := make([]string, 0, len(histories))
+	for id := range histories {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	thermostats := make([]Thermostat, 0, len(ids))
+	for _, id := range ids {
+		ref := model.ThermostatRef{Provider: ProviderName, ID: id, Name: id}
+		thermostats = append(thermostats, Thermostat{Ref: ref, Rows: histories[id].rows(ref)})
+	}
+	return thermostats, nil
+}
+
+// thermostatDir returns the device ID of a file below Nest/thermostats/<id>/
+func thermostatDir(name string) (string, bool) {
+	parts := strings.Split(name, "/")
+	for i := 0; i+2 < len(parts); i++ {
+		if strings.EqualFold(parts[i], "thermostats") && i > 0 && strings.EqualFold(parts[i-1], "nest") {
+			return parts[i+1], true
+		}
+	}
+	return "", false
+}
+
+// readFile opens a file in the takeout and passes it to parse
+func readFile(fsys fs.FS, name string, parse func(io.Reader) error) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", name, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err := parse(file); err != nil {
+		return fmt.Errorf("parsing %s: %w", name, err)
+	}
+	return nil
+}
+
+// history collects one thermostat's samples, cycles and setpoint events
+type history struct {
+	samples map[time.Time]float64
+	cycles  []cycle
+	events  []setpointEvent
+}
+
+// cycle is an HVAC run with the equipment it used
+type cycle struct {
+	start, end time.Time
+	equipment  map[string]bool
+}
+
+// setpointEvent is a period with a fixed mode and setpoints
+type setpointEvent struct {
+	start, end time.Time
+	mode       string
+	heatC      *float64
+	coolC      *float64
+}
+
+// readSensors parses a sensors CSV with Date, Time and avg(temp) columns
+func (h *history) readSensors(r io.Reader) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("reading header: %w", err)
+	}
+
+	dateCol, timeCol, tempCol := -1, -1, -1
+	for i, name := range header {
+		switch strings.TrimSpace(name) {
+		case "Date":
+			dateCol = i
+		case "Time":
+			timeCol = i
+		case "avg(temp)":
+			tempCol = i
+		}
+	}
+	if dateCol < 0 || timeCol < 0 || tempCol < 0 {
+		return fmt.Errorf("missing Date, Time or avg(temp) column")
+	}
+
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("reading row: %w", err)
+		}
+		if len(record) <= max(dateCol, timeCol, tempCol) || record[tempCol] == "" {
+			continue
+		}
+		at, err := time.Parse("2006-01-02 15:04", record[dateCol]+" "+record[timeCol])
+		if err != nil {
+			return fmt.Errorf("parsing sample time: %w", err)
+		}
+		temp, err := strconv.ParseFloat(record[tempCol], 64)
+		if err != nil {
+			return fmt.Errorf("parsing temperature at %s: %w", at.Format(time.RFC3339), err)
+		}
+		h.samples[at] = temp
+	}
+}
+
+// summaryDay is one day of a monthly summary file
+type summaryDay struct {
+	Cycles []map[string]any `json:"cycles"`
+	Events []struct {
+		EventType string `json:"eventType"`
+		StartTs   string `json:"startTs"`
+		EndTs     string `json:"endTs"`
+		SetPoint  struct {
+			Targets struct {
+				HeatingTarget *float64 `json:"heatingTarget"`
+				CoolingTarget *float64 `json:"coolingTarget"`
+			} `json:"targets"`
+		} `json:"setPoint"`
+	} `json:"events"`
+}
+
+// readSummary parses a summary JSON object keyed by day
+func (h *history) readSummary(r io.Reader) error {
+	var days map[string]summaryDay
+	if err := json.NewDecoder(r).Decode(&days); err != nil {
+		return err
+	}
+
+	for _, day := range days {
+		for _, raw := range day.Cycles {
+			c, ok := parseCycle(raw)
+			if ok {
+				h.cycles = append(h.cycles, c)
+			}
+		}
+		for _, event := range day.Events {
+			mode, ok := modes[event.EventType]
+			if !ok {
+				continue
+			}
+			start, end, ok := parseSpan(event.StartTs, event.EndTs)
+			if !ok {
+				continue
+			}
+			h.events = append(h.events, setpointEvent{
+				start: start,
+				end:   end,
+				mode:  mode,
+				heatC: event.SetPoint.Targets.HeatingTarget,
+				coolC: event.SetPoint.Targets.CoolingTarget,
+			})
+		}
+	}
+	return nil
+}
+
+// parseCycle reads the span and equipment flags of a cycle
+func parseCycle(raw map[string]any) (cycle, bool) {
+	start, end, ok := parseSpan(fmt.Sprint(raw["startTs"]), fmt.Sprint(raw["endTs"]))
+	if !ok {
+		return cycle{}, false
+	}
+
+	equipment := make(map[string]bool)
+	for flag, key := range equipmentKeys {
+		if on, _ := raw[flag].(bool); on {
+			equipment[key] = true
+		}
+	}
+	return cycle{start: start, end: end, equipment: equipment}, true
+}
+
+// parseSpan parses RFC 3339 start and end times; incomplete or inverted spans
+// are rejected
+func parseSpan(startTs, endTs string) (time.Time, time.Time, bool) {
+	start, startErr := time.Parse(time.RFC3339, startTs)
+	end, endErr := time.Parse(time.RFC3339, endTs)
+	if startErr != nil || endErr != nil || end.Before(start) {
+		return time.Time{}, time.Time{}, false
+	}
+	return start, end, true
+}
+
+// rows builds runtime rows from the sensor samples. A row's equipment is on
+// when a cycle overlaps its 15-minute slot; its mode and setpoints come from
+// the event in effect at the sample time.
+func (h *history) rows(ref model.ThermostatRef) []model.RuntimeRow {
+	times := make([]time.Time, 0, len(h.samples))
+	for at := range h.samples {
+		times = append(times, at)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+
+	slots := make(map[time.Time]map[string]bool)
+	for _, c := range h.cycles {
+		for slot := c.start.Truncate(sampleInterval); slot.Before(c.end); slot = slot.Add(sampleInterval) {
+			if slots[slot] == nil {
+				slots[slot] = make(map[string]bool)
+			}
+			for key := range c.equipment {
+				slots[slot][key] = true
+			}
+		}
+	}
+
+	sort.Slice(h.events, func(i, j int) bool { return h.events[i].start.Before(h.events[j].start) })
+
+	rows := make([]model.RuntimeRow, 0, len(times))
+	for _, at := range times {
+		temp := h.samples[at]
+		row := model.RuntimeRow{
+			ThermostatRef: ref,
+			EventTime:     at,
+			AvgTempC:      &temp,
+			Equipment:     slots[at.Truncate(sampleInterval)],
+		}
+
+		// The event in effect is the last one starting at or before the sample
+		i := sort.Search(len(h.events), func(i int) bool { return h.events[i].start.After(at) }) - 1
+		if i >= 0 && at.Before(h.events[i].end) {
+			row.Mode = h.events[i].mode
+			row.SetHeatC = h.events[i].heatC
+			row.SetCoolC = h.events[i].coolC
+		}
+
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,140 @@
+package nest
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+const sensorsCSV = `Date,Time,avg(temp),avg(humidity)
+2021-01-01,00:00,20.5,40
+2021-01-01,00:15,20.8,40
+2021-01-01,00:30,,40
+2021-01-01,00:45,21.0,41
+`
+
+const summaryJSON = `{
+  "2021-01-01T00:00:00Z": {
+    "cycles": [
+      {"startTs": "2021-01-01T00:05:00Z", "endTs": "2021-01-01T00:20:00Z", "heat1": true, "fan": true, "cool1": false}
+    ],
+    "events": [
+      {"eventType": "EVENT_TYPE_HEAT", "startTs": "2021-01-01T00:00:00Z", "endTs": "2021-01-01T00:40:00Z",
+       "setPoint": {"targets": {"heatingTarget": 21.0}}},
+      {"eventType": "EVENT_TYPE_UNKNOWN", "startTs": "", "endTs": ""}
+    ]
+  }
+}`
+
+func takeoutFS() fstest.MapFS {
+	return fstest.MapFS{
+		"Takeout/Nest/thermostats/DEVICE1/2021/01/2021-01-sensors.csv":  {Data: []byte(sensorsCSV)},
+		"Takeout/Nest/thermostats/DEVICE1/2021/01/2021-01-summary.json": {Data: []byte(summaryJSON)},
+		"Takeout/Nest/cameras/CAM1/2021-01-sensors.csv":                 {Data: []byte("ignored")},
+		"Takeout/archive_browser.html":                                  {Data: []byte("<html></html>")},
+	}
+}
+
+func TestParseTakeout(t *testing.T) {
+	thermostats, err := ParseTakeout(takeoutFS())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thermostats) != 1 {
+		t.Fatalf("Expected 1 thermostat, got %d", len(thermostats))
+	}
+
+	thermostat := thermostats[0]
+	if thermostat.Ref.ID != "DEVICE1" || thermostat.Ref.Provider != ProviderName {
+		t.Errorf("Unexpected thermostat ref: %+v", thermostat.Ref)
+	}
+	if len(thermostat.Rows) != 3 {
+		t.Fatalf("Expected 3 rows (blank samples skipped), got %d", len(thermostat.Rows))
+	}
+
+	tests := []struct {
+		name    string
+		at      time.Time
+		temp    float64
+		heating bool
+		mode    string
+	}{
+		{name: "cycle starts within slot", at: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), temp: 20.5, heating: true, mode: "heat"},
+		{name: "cycle ends within slot", at: time.Date(2021, 1, 1, 0, 15, 0, 0, time.UTC), temp: 20.8, heating: true, mode: "heat"},
+		{name: "after cycle and event", at: time.Date(2021, 1, 1, 0, 45, 0, 0, time.UTC), temp: 21.0, heating: false, mode: ""},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row := thermostat.Rows[i]
+			if !row.EventTime.Equal(tt.at) {
+				t.Errorf("Expected event time %v, got %v", tt.at, row.EventTime)
+			}
+			if row.AvgTempC == nil || *row.AvgTempC != tt.temp {
+				t.Errorf("Expected temperature %v, got %v", tt.temp, row.AvgTempC)
+			}
+			if row.Equipment["compHeat1"] != tt.heating || row.Equipment["fan"] != tt.heating {
+				t.Errorf("Expected heating=%v, got equipment %v", tt.heating, row.Equipment)
+			}
+			if row.Mode != tt.mode {
+				t.Errorf("Expected mode %q, got %q", tt.mode, row.Mode)
+			}
+		})
+	}
+	if setHeat := thermostat.Rows[0].SetHeatC; setHeat == nil || *setHeat != 21.0 {
+		t.Errorf("Expected heat setpoint 21.0, got %v", setHeat)
+	}
+}
+
+func TestParseTakeoutErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{name: "no thermostats", fsys: fstest.MapFS{"Takeout/Nest/cameras/CAM1/x.json": {Data: []byte("{}")}}},
+		{name: "missing columns", fsys: fstest.MapFS{"Takeout/Nest/thermostats/D1/2021-01-sensors.csv": {Data: []byte("Date,Value\n")}}},
+		{name: "bad temperature", fsys: fstest.MapFS{"Takeout/Nest/thermostats/D1/2021-01-sensors.csv": {Data: []byte("Date,Time,avg(temp)\n2021-01-01,00:00,warm\n")}}},
+		{name: "bad summary", fsys: fstest.MapFS{"Takeout/Nest/thermostats/D1/2021-01-summary.json": {Data: []byte("[")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTakeout(tt.fsys); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestOpenTakeoutZip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "takeout.zip")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create archive: %v", err)
+	}
+	writer := zip.NewWriter(file)
+	for name, entry := range takeoutFS() {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+		if _, err := w.Write(entry.Data); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+	if err := file.Close(); err != nil {
+		t.Fatalf("Failed to close file: %v", err)
+	}
+
+	thermostats, err := OpenTakeout(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(thermostats) != 1 || len(thermostats[0].Rows) != 3 {
+		t.Errorf("Unexpected thermostats from archive: %+v", thermostats)
+	}
+}
@@ -0,0 +1,194 @@
+package nest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// thermostatType is the SDM device type of thermostats; other devices, such
+// as cameras and doorbells, are ignored
+const thermostatType = "sdm.devices.types.THERMOSTAT"
+
+// sdmModes maps ThermostatMode trait modes to canonical modes
+var sdmModes = map[string]string{
+	"HEAT":     "heat",
+	"COOL":     "cool",
+	"HEATCOOL": "auto",
+	"OFF":      "off",
+}
+
+// device is an SDM device resource
+type device struct {
+	Name            string                     `json:"name"`
+	Type            string                     `json:"type"`
+	Traits          map[string]json.RawMessage `json:"traits"`
+	ParentRelations []struct {
+		Parent      string `json:"parent"`
+		DisplayName string `json:"displayName"`
+	} `json:"parentRelations"`
+}
+
+// traits holds the thermostat traits the provider reads
+type traits struct {
+	Info struct {
+		CustomName string `json:"customName"`
+	}
+	Connectivity struct {
+		Status string `json:"status"`
+	}
+	Temperature struct {
+		AmbientTemperatureCelsius *float64 `json:"ambientTemperatureCelsius"`
+	}
+	Mode struct {
+		Mode string `json:"mode"`
+	}
+	Eco struct {
+		Mode        string   `json:"mode"`
+		HeatCelsius *float64 `json:"heatCelsius"`
+		CoolCelsius *float64 `json:"coolCelsius"`
+	}
+	Hvac struct {
+		Status string `json:"status"`
+	}
+	Setpoint struct {
+		HeatCelsius *float64 `json:"heatCelsius"`
+		CoolCelsius *float64 `json:"coolCelsius"`
+	}
+	Fan struct {
+		TimerMode string `json:"timerMode"`
+	}
+}
+
+// fields maps SDM trait names to the traits field they decode into
+func (t *traits) fields() map[string]any {
+	return map[string]any{
+		"sdm.devices.traits.Info":                          &t.Info,
+		"sdm.devices.traits.Connectivity":                  &t.Connectivity,
+		"sdm.devices.traits.Temperature":                   &t.Temperature,
+		"sdm.devices.traits.ThermostatMode":                &t.Mode,
+		"sdm.devices.traits.ThermostatEco":                 &t.Eco,
+		"sdm.devices.traits.ThermostatHvac":                &t.Hvac,
+		"sdm.devices.traits.ThermostatTemperatureSetpoint": &t.Setpoint,
+		"sdm.devices.traits.Fan":                           &t.Fan,
+	}
+}
+
+// ID returns the device ID, the last segment of its resource name
+func (d device) ID() string {
+	return d.Name[strings.LastIndex(d.Name, "/")+1:]
+}
+
+// decodeTraits decodes the traits the provider reads; missing or malformed
+// traits are left empty
+func (d device) decodeTraits() traits {
+	var t traits
+	for name, target := range t.fields() {
+		if raw, ok := d.Traits[name]; ok {
+			_ = json.Unmarshal(raw, target)
+		}
+	}
+	return t
+}
+
+// ref returns the thermostat reference for the device. It is named by its
+// custom name or else its room, and belongs to the household of its
+// structure.
+func (d device) ref() model.ThermostatRef {
+	ref := model.ThermostatRef{ID: d.ID(), Provider: ProviderName}
+	ref.Name = d.decodeTraits().Info.CustomName
+	for _, relation := range d.ParentRelations {
+		if ref.Name == "" {
+			ref.Name = relation.DisplayName
+		}
+		// enterprises/<project>/structures/<structure>/rooms/<room>
+		parts := strings.Split(relation.Parent, "/")
+		if len(parts) >= 4 && parts[2] == "structures" {
+			ref.HouseholdID = parts[3]
+		}
+	}
+	return ref
+}
+
+// connected reports whether the device is online; nil when it does not say
+func (d device) connected() *bool {
+	status := d.decodeTraits().Connectivity.Status
+	if status == "" {
+		return nil
+	}
+	online := status == "ONLINE"
+	return &online
+}
+
+// revision returns a hash of the device's settings, leaving out readings
+// that change between polls, so a new revision means a new snapshot
+func (d device) revision() string {
+	settings := make(map[string]json.RawMessage, len(d.Traits))
+	for name, raw := range d.Traits {
+		switch name {
+		case "sdm.devices.traits.Temperature", "sdm.devices.traits.Humidity",
+			"sdm.devices.traits.ThermostatHvac", "sdm.devices.traits.Connectivity":
+			continue
+		}
+		settings[name] = raw
+	}
+	// Map keys are marshaled sorted, so equal settings hash equally
+	encoded, _ := json.Marshal(settings)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// reading converts the device's current traits into a runtime reading
+// stamped at, in Celsius. Eco mode, which Nest enters when everyone is
+// away, reports its own setpoints and the climate Away. Nest does not say
+// which heating or cooling stage runs, so heating is reported as compHeat1
+// and cooling as compCool1.
+func (d device) reading(tr model.ThermostatRef, at time.Time) model.RuntimeRow {
+	t := d.decodeTraits()
+	row := model.RuntimeRow{
+		ThermostatRef: tr,
+		EventTime:     at,
+		Mode:          sdmModes[t.Mode.Mode],
+		AvgTempC:      t.Temperature.AmbientTemperatureCelsius,
+		SetHeatC:      t.Setpoint.HeatCelsius,
+		SetCoolC:      t.Setpoint.CoolCelsius,
+		Equipment: map[string]bool{
+			"compHeat1": t.Hvac.Status == "HEATING",
+			"compCool1": t.Hvac.Status == "COOLING",
+			"fan":       t.Fan.TimerMode == "ON",
+		},
+	}
+	if t.Eco.Mode == "MANUAL_ECO" {
+		row.Climate = "Away"
+		row.SetHeatC = t.Eco.HeatCelsius
+		row.SetCoolC = t.Eco.CoolCelsius
+	}
+	return row
+}
+
+// snapshot converts the device's current traits into a snapshot. The traits
+// are kept as the program, and eco mode is reported as a running hold.
+func (d device) snapshot(tr model.ThermostatRef, at time.Time) model.Snapshot {
+	t := d.decodeTraits()
+	snapshot := model.Snapshot{
+		ThermostatRef: tr,
+		CollectedAt:   at,
+		Revision:      d.revision(),
+		Program:       d.Traits,
+	}
+	if t.Eco.Mode == "MANUAL_ECO" {
+		snapshot.EventsActive = []any{d.Traits["sdm.devices.traits.ThermostatEco"]}
+		snapshot.Events = []model.Event{{
+			Kind:     "hold",
+			Name:     "eco",
+			Running:  true,
+			SetHeatC: t.Eco.HeatCelsius,
+			SetCoolC: t.Eco.CoolCelsius,
+		}}
+	}
+	return snapshot
+}
@@ -0,0 +1,71 @@
+// Package sampled builds runtime rows for providers whose APIs report only
+// current state. Each poll records a reading, and runtime requests are
+// answered from the readings taken so far, aggregated into 5-minute bins.
+package sampled
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/runtimebin"
+)
+
+const (
+	// BinSize is the interval runtime rows are aggregated into
+	BinSize = runtimebin.Size
+
+	// DefaultRetention is how long readings are kept for runtime requests
+	DefaultRetention = 24 * time.Hour
+)
+
+// Readings keeps the thermostat state read on each request, since the
+// provider's API has no runtime history
+type Readings struct {
+	retention    time.Duration
+	mu           sync.Mutex
+	byThermostat map[string][]model.RuntimeRow
+}
+
+// NewReadings returns an empty reading buffer keeping readings for retention
+func NewReadings(retention time.Duration) *Readings {
+	return &Readings{retention: retention, byThermostat: make(map[string][]model.RuntimeRow)}
+}
+
+// Record adds a reading in time order and drops those older than the
+// retention
+func (r *Readings) Record(row model.RuntimeRow) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rows := r.byThermostat[row.ThermostatRef.ID]
+	i, _ := slices.BinarySearchFunc(rows, row.EventTime, func(existing model.RuntimeRow, t time.Time) int {
+		return existing.EventTime.Compare(t)
+	})
+	rows = slices.Insert(rows, i, row)
+	cutoff := row.EventTime.Add(-r.retention)
+	for len(rows) > 0 && rows[0].EventTime.Before(cutoff) {
+		rows = rows[1:]
+	}
+	r.byThermostat[row.ThermostatRef.ID] = rows
+}
+
+// Bins aggregates a thermostat's readings into the 5-minute bins starting
+// after from and before to, as runtimebin.Align does for the scheduler. from
+// is the runtime offset, the start of the last bin reported, so that bin is
+// not reported again. The bin still open at now is left out, so each bin is
+// reported once with every reading it will get.
+func (r *Readings) Bins(thermostatID string, from, to, now time.Time) []model.RuntimeRow {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var readings []model.RuntimeRow
+	for _, row := range r.byThermostat[thermostatID] {
+		start := row.EventTime.Truncate(BinSize)
+		if start.After(from) && start.Before(to) {
+			readings = append(readings, row)
+		}
+	}
+	return runtimebin.Align(readings, now)
+}
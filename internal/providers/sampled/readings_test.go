@@ -0,0 +1,123 @@
+package sampled
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/runtimebin"
+)
+
+func TestReadingBins(t *testing.T) {
+	ref := model.ThermostatRef{ID: "t1", Provider: "test"}
+	binStart := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	reading := func(offset time.Duration, temp float64, heating bool, setHeat float64) model.RuntimeRow {
+		return model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(offset), Mode: "heat",
+			AvgTempC: floatPtr(temp), SetHeatC: floatPtr(setHeat), Equipment: map[string]bool{"compHeat1": heating}}
+	}
+
+	r := NewReadings(DefaultRetention)
+	// recorded out of order, as concurrent requests may
+	r.Record(reading(4*time.Minute, 21, false, 21))
+	r.Record(reading(time.Minute, 20, true, 20))
+	r.Record(reading(6*time.Minute, 22, false, 21))
+	r.Record(reading(11*time.Minute, 23, false, 21))
+
+	bins := r.Bins("t1", binStart.Add(-BinSize), binStart.Add(time.Hour), binStart.Add(12*time.Minute))
+
+	expected := []model.RuntimeRow{
+		{ThermostatRef: ref, EventTime: binStart, Mode: "heat", AvgTempC: floatPtr(20.5), SetHeatC: floatPtr(21),
+			Equipment: map[string]bool{"compHeat1": true}},
+		{ThermostatRef: ref, EventTime: binStart.Add(BinSize), Mode: "heat", AvgTempC: floatPtr(22), SetHeatC: floatPtr(21),
+			Equipment: map[string]bool{"compHeat1": false}},
+	}
+	if !reflect.DeepEqual(bins, expected) {
+		t.Errorf("Expected bins %+v, got %+v", expected, bins)
+	}
+
+	if bins := r.Bins("t1", binStart.Add(BinSize), binStart.Add(time.Hour), binStart.Add(time.Hour)); len(bins) != 1 || !bins[0].EventTime.Equal(binStart.Add(2*BinSize)) {
+		t.Errorf("Expected only the bin after the offset, got %+v", bins)
+	}
+}
+
+func TestReadingBinsDoNotOverlap(t *testing.T) {
+	ref := model.ThermostatRef{ID: "t1", Provider: "test"}
+	binStart := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+
+	r := NewReadings(DefaultRetention)
+	offset := binStart.Add(-BinSize)
+	seen := make(map[time.Time]bool)
+	// Poll every 2 minutes for half an hour, fetching from the offset each
+	// time as the scheduler does
+	for elapsed := time.Duration(0); elapsed <= 30*time.Minute; elapsed += 2 * time.Minute {
+		now := binStart.Add(elapsed)
+		r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: now, AvgTempC: floatPtr(20)})
+		for _, bin := range r.Bins("t1", offset, now, now) {
+			if seen[bin.EventTime] {
+				t.Errorf("Bin %v reported twice", bin.EventTime)
+			}
+			seen[bin.EventTime] = true
+			offset = bin.EventTime
+		}
+	}
+	if len(seen) != 6 {
+		t.Errorf("Expected the 6 bins closed in half an hour, got %d", len(seen))
+	}
+}
+
+func TestReadingBinsAverageOutdoorTemperature(t *testing.T) {
+	ref := model.ThermostatRef{ID: "t1", Provider: "test"}
+	binStart := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+
+	r := NewReadings(DefaultRetention)
+	r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart, OutdoorTempC: floatPtr(4)})
+	r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(time.Minute)})
+	r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(2 * time.Minute), OutdoorTempC: floatPtr(5)})
+
+	bins := r.Bins("t1", binStart.Add(-BinSize), binStart.Add(BinSize), binStart.Add(BinSize))
+	if len(bins) != 1 || bins[0].OutdoorTempC == nil || *bins[0].OutdoorTempC != 4.5 || bins[0].AvgTempC != nil {
+		t.Errorf("Expected an outdoor average of 4.5 and no indoor temperature, got %+v", bins)
+	}
+}
+
+func TestReadingRetention(t *testing.T) {
+	ref := model.ThermostatRef{ID: "t1", Provider: "test"}
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+	r := NewReadings(time.Hour)
+	r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: start})
+	r.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: start.Add(time.Hour + time.Minute)})
+
+	if rows := r.byThermostat["t1"]; len(rows) != 1 || !rows[0].EventTime.After(start) {
+		t.Errorf("Expected the old reading dropped, got %+v", rows)
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestReadingBinsMatchSchedulerAggregation(t *testing.T) {
+	ref := model.ThermostatRef{ID: "t1", Provider: "test"}
+	binStart := time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC)
+	humidity := func(v int) *int { return &v }
+	readings := []model.RuntimeRow{
+		{ThermostatRef: ref, EventTime: binStart, OutdoorHumidity: humidity(60),
+			Sensors: map[string]float64{"return_temp": 18}, EquipmentSecs: map[string]int{"compHeat1": 60}},
+		{ThermostatRef: ref, EventTime: binStart.Add(2 * time.Minute), OutdoorHumidity: humidity(70),
+			Sensors: map[string]float64{"return_temp": 20}, EquipmentSecs: map[string]int{"compHeat1": 120}},
+	}
+
+	r := NewReadings(DefaultRetention)
+	for _, reading := range readings {
+		r.Record(reading)
+	}
+	bins := r.Bins("t1", binStart.Add(-BinSize), binStart.Add(BinSize), binStart.Add(BinSize))
+	if expected := runtimebin.Align(readings, binStart.Add(BinSize)); !reflect.DeepEqual(bins, expected) {
+		t.Errorf("Expected the scheduler's bins %+v, got %+v", expected, bins)
+	}
+	if len(bins) != 1 || bins[0].Sensors["return_temp"] != 19 || *bins[0].OutdoorHumidity != 65 || bins[0].EquipmentSecs["compHeat1"] != 180 {
+		t.Errorf("Expected sensors and humidity averaged and run seconds summed, got %+v", bins)
+	}
+}
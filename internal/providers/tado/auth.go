@@ -0,0 +1,311 @@
+package tado
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/secret"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	tadoTokenURL = cmp.Or(os.Getenv("TADO_TOKEN_URL"), "https://login.tado.com/oauth2/token")
+	tadoAPIURL   = cmp.Or(os.Getenv("TADO_API_URL"), "https://my.tado.com/api/v2")
+)
+
+// DefaultClientID is the public client ID of Tado's device authorization
+// flow, which issues the refresh tokens the provider uses
+const DefaultClientID = "1bb50063-6b0c-4d11-bd99-387f4a91cc46"
+
+// expirySkew is how long before expiry a token is no longer used, to allow
+// for clock drift and requests in flight
+const expirySkew = 30 * time.Second
+
+// AuthManager implements OAuth for the Tado API. Tado rotates the refresh
+// token on every refresh and accepts each one only once, so the latest one
+// is written back to the refresh token file, when one is configured, for
+// the next start to read.
+type AuthManager struct {
+	// credMu guards the credentials, which ReloadCredentials may replace
+	credMu           sync.Mutex
+	clientID         string
+	refreshToken     string
+	refreshTokenFile *secret.File
+
+	// tokenMu guards the access token, which concurrent polls share.
+	// refreshes collapses overlapping refreshes into one token request, since
+	// a racing second request would present a token Tado has already retired.
+	tokenMu     sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+	refreshes   singleflight.Group
+
+	httpClient  *http.Client
+	retryConfig retry.Config
+
+	// maxResponseBytes bounds every response body read from Tado and
+	// bytesFetched counts what was read of them
+	maxResponseBytes int64
+	bytesFetched     bodylimit.Counter
+
+	// throttle records the deadline when the API answers 429 so that
+	// subsequent calls fail fast instead of spending more of the account's
+	// daily request limit
+	throttle retry.Throttle
+}
+
+// NewAuthManager creates a new Tado authentication manager
+func NewAuthManager(clientID, refreshToken string) *AuthManager {
+	retryConfig := retry.DefaultConfig()
+	retryConfig.MaxRetries = 3
+	retryConfig.InitialDelay = 1 * time.Second
+	retryConfig.MaxDelay = 30 * time.Second
+
+	return &AuthManager{
+		clientID:         clientID,
+		refreshToken:     refreshToken,
+		httpClient:       &http.Client{Timeout: 30 * time.Second},
+		retryConfig:      retryConfig,
+		maxResponseBytes: bodylimit.DefaultMaxBytes,
+	}
+}
+
+// SetMaxResponseBytes sets the largest response body read from Tado; a
+// larger one fails with a *bodylimit.TooLargeError. Call it before use.
+func (a *AuthManager) SetMaxResponseBytes(limit int64) {
+	a.maxResponseBytes = limit
+}
+
+// UseCredentialFile reads the refresh token from a file, which
+// ReloadCredentials re-reads and rotated tokens are written back to. The
+// file's directory must be writable.
+func (a *AuthManager) UseCredentialFile(refreshTokenPath string) error {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	file, err := secret.NewFile(refreshTokenPath)
+	if err != nil {
+		return fmt.Errorf("reading refresh_token: %w", err)
+	}
+	a.refreshTokenFile = file
+	a.refreshToken = file.Value()
+	return nil
+}
+
+// ReloadCredentials re-reads the refresh token file. The token is only
+// replaced when the file changed, so writing back a token rotated by Tado
+// does not count as a change, while a token the operator writes does.
+func (a *AuthManager) ReloadCredentials() (bool, error) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	if a.refreshTokenFile == nil {
+		return false, nil
+	}
+	updated, err := a.refreshTokenFile.Reload()
+	if err != nil {
+		return false, fmt.Errorf("reloading refresh_token: %w", err)
+	}
+	if updated {
+		a.refreshToken = a.refreshTokenFile.Value()
+	}
+	return updated, nil
+}
+
+// credentials returns the current client ID and refresh token
+func (a *AuthManager) credentials() (string, string) {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+	return a.clientID, a.refreshToken
+}
+
+// RefreshToken requests a new access token. Callers arriving while a
+// refresh is in flight wait for its result instead of starting another.
+func (a *AuthManager) RefreshToken(ctx context.Context) error {
+	// The shared request must not fail for every waiter because the caller
+	// that started it gave up; the HTTP client timeout still bounds it
+	result := a.refreshes.DoChan("token", func() (any, error) {
+		return nil, a.requestToken(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-result:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// requestToken exchanges the refresh token for a new access token and the
+// refresh token that replaces it
+func (a *AuthManager) requestToken(ctx context.Context) error {
+	clientID, refreshToken := a.credentials()
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
+	data.Set("client_id", clientID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", tadoTokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return fmt.Errorf("creating refresh token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("refreshing token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		throttled := retry.NewThrottledError(resp.StatusCode, retry.RetryAfterFromResponse(resp))
+		a.throttle.Record(throttled)
+		return fmt.Errorf("refreshing token: %w", throttled)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token refresh failed with status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(a.bytesFetched.Limit(resp, a.maxResponseBytes).Body).Decode(&tokenResp); err != nil {
+		return fmt.Errorf("decoding token response: %w", err)
+	}
+
+	a.tokenMu.Lock()
+	a.accessToken = tokenResp.AccessToken
+	a.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	a.tokenMu.Unlock()
+
+	if tokenResp.RefreshToken != "" {
+		if err := a.saveRefreshToken(tokenResp.RefreshToken); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveRefreshToken replaces the refresh token with the one Tado rotated to
+// and writes it back to the refresh token file, if one is configured, since
+// the retired token would be rejected after a restart
+func (a *AuthManager) saveRefreshToken(refreshToken string) error {
+	a.credMu.Lock()
+	defer a.credMu.Unlock()
+
+	a.refreshToken = refreshToken
+	if a.refreshTokenFile == nil {
+		return nil
+	}
+	if err := a.refreshTokenFile.Write(refreshToken); err != nil {
+		return fmt.Errorf("saving rotated refresh_token to %s: %w", a.refreshTokenFile.Path(), err)
+	}
+	return nil
+}
+
+// GetAccessToken returns the current access token, refreshing if needed
+func (a *AuthManager) GetAccessToken(ctx context.Context) (string, error) {
+	if token, ok := a.validToken(); ok {
+		return token, nil
+	}
+	if err := a.RefreshToken(ctx); err != nil {
+		return "", fmt.Errorf("refreshing token: %w", err)
+	}
+	token, _ := a.validToken()
+	return token, nil
+}
+
+// IsTokenValid checks if the current token is valid
+func (a *AuthManager) IsTokenValid(ctx context.Context) bool {
+	_, ok := a.validToken()
+	return ok
+}
+
+// validToken returns the access token and whether it can still be used
+func (a *AuthManager) validToken() (string, bool) {
+	a.tokenMu.Lock()
+	defer a.tokenMu.Unlock()
+	return a.accessToken, a.accessToken != "" && time.Now().Before(a.tokenExpiry.Add(-expirySkew))
+}
+
+// ThrottledUntil returns the time before which the API asked us not to call again
+func (a *AuthManager) ThrottledUntil() time.Time {
+	return a.throttle.Until()
+}
+
+// get makes an authenticated GET request for an API resource path, such as
+// homes/123/zones, with retry logic, and decodes the JSON response into v.
+// Responses other than 200 are returned as errors.
+func (a *AuthManager) get(ctx context.Context, resource string, query url.Values, v any) error {
+	if err := a.throttle.Check(); err != nil {
+		return err
+	}
+
+	endpoint := tadoAPIURL + "/" + resource
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	send := func() (*http.Response, error) {
+		token, err := a.GetAccessToken(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting access token: %w", err)
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("making request: %w", err)
+		}
+		return resp, nil
+	}
+
+	resp, err := retry.DoWithResponse(ctx, a.retryConfig, func() (*http.Response, error) {
+		resp, err := send()
+		if err != nil || resp.StatusCode != http.StatusUnauthorized {
+			return resp, err
+		}
+
+		// The token was revoked or expired early; refresh it and try once more
+		_ = resp.Body.Close()
+		if err := a.RefreshToken(ctx); err != nil {
+			return nil, fmt.Errorf("refreshing token after 401: %w", err)
+		}
+		return send()
+	})
+
+	a.throttle.Record(err)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("tado API returned status %d for %s", resp.StatusCode, resource)
+	}
+	if err := json.NewDecoder(a.bytesFetched.Limit(resp, a.maxResponseBytes).Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %w", resource, err)
+	}
+	return nil
+}
+
+// BytesFetched returns the response bytes read from Tado
+func (a *AuthManager) BytesFetched() int64 {
+	return a.bytesFetched.Bytes()
+}
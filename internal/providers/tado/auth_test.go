@@ -0,0 +1,83 @@
+package tado
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rotatingTokenServer issues a new refresh token on every refresh and, like
+// Tado, accepts each refresh token only once
+func rotatingTokenServer(t *testing.T, initial string) http.HandlerFunc {
+	var mu sync.Mutex
+	valid := initial
+	issued := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("Unexpected form: %v", err)
+		}
+		if r.PostForm.Get("refresh_token") != valid {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		issued++
+		valid = fmt.Sprintf("rotated-%d", issued)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "token",
+			"refresh_token": valid,
+			"expires_in":    600,
+		})
+	}
+}
+
+func TestRotatedRefreshTokenSurvivesRestart(t *testing.T) {
+	tokenServer := httptest.NewServer(rotatingTokenServer(t, "initial"))
+	defer tokenServer.Close()
+	apiServer := httptest.NewServer(fakeAPI(t, defaultStates()))
+	defer apiServer.Close()
+
+	originalTokenURL, originalAPIURL := tadoTokenURL, tadoAPIURL
+	tadoTokenURL, tadoAPIURL = tokenServer.URL, apiServer.URL
+	defer func() { tadoTokenURL, tadoAPIURL = originalTokenURL, originalAPIURL }()
+
+	path := filepath.Join(t.TempDir(), "refresh_token")
+	if err := os.WriteFile(path, []byte("initial\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write refresh token: %v", err)
+	}
+
+	// start builds a provider from the token file, as a fresh process would
+	start := func() *Provider {
+		t.Helper()
+		provider := NewProvider(DefaultClientID, "")
+		if err := provider.UseCredentialFile(path); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := provider.ListThermostats(context.Background()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return provider
+	}
+
+	first := start()
+	if changed, err := first.ReloadCredentials(); err != nil || changed {
+		t.Errorf("Expected the written back token not to count as a change, got changed=%v err=%v", changed, err)
+	}
+
+	start()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if token := strings.TrimSpace(string(data)); token != "rotated-2" {
+		t.Errorf("Expected the file to hold the latest rotated token, got %q", token)
+	}
+}
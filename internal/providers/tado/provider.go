@@ -0,0 +1,249 @@
+// Package tado reads Tado smart thermostats and air conditioning controls
+// through the Tado API. Each heating or air conditioning zone of a home is
+// reported as a thermostat, and runtime is built from the zones' day reports.
+package tado
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ProviderName is the name Tado zones are reported under
+const ProviderName = "tado"
+
+// Provider implements the Tado thermostat provider. Thermostat IDs are
+// "<home ID>-<zone ID>", and each home is a household.
+type Provider struct {
+	authManager *AuthManager
+}
+
+// NewProvider creates a new Tado provider for the homes of the account that
+// authorized refreshToken
+func NewProvider(clientID, refreshToken string) *Provider {
+	return &Provider{authManager: NewAuthManager(clientID, refreshToken)}
+}
+
+// Info returns metadata about the provider
+func (p *Provider) Info() model.ProviderInfo {
+	return model.ProviderInfo{
+		Name:        ProviderName,
+		Version:     "1.0.0",
+		Description: "Tado thermostat provider using the Tado API",
+	}
+}
+
+// ListThermostats returns the heating and air conditioning zones of every
+// home, in the home's time zone
+func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	homes, err := p.listHomes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var thermostats []model.ThermostatRef
+	for _, h := range homes {
+		var details home
+		if err := p.authManager.get(ctx, fmt.Sprintf("homes/%d", h.ID), nil, &details); err != nil {
+			return nil, fmt.Errorf("requesting home %d: %w", h.ID, err)
+		}
+		timeZone := details.DateTimeZone
+		if _, err := time.LoadLocation(timeZone); err != nil {
+			timeZone = ""
+		}
+
+		zones, err := p.listZones(ctx, h.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, z := range zones {
+			if !z.isThermostat() {
+				continue
+			}
+			thermostats = append(thermostats, model.ThermostatRef{
+				ID:          thermostatID(h.ID, z.ID),
+				Name:        z.Name,
+				Provider:    ProviderName,
+				HouseholdID: strconv.FormatInt(h.ID, 10),
+				TimeZone:    timeZone,
+			})
+		}
+	}
+	return thermostats, nil
+}
+
+// GetSummary returns the zone's settings revision and connectivity
+func (p *Provider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	state, err := p.getState(ctx, tr)
+	if err != nil {
+		return model.Summary{}, err
+	}
+	return summary(tr, state), nil
+}
+
+// GetSummaries returns summaries for every zone from one zone states request
+// per home, which spares the account's daily request limit
+func (p *Provider) GetSummaries(ctx context.Context) (map[string]model.Summary, error) {
+	homes, err := p.listHomes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make(map[string]model.Summary)
+	for _, h := range homes {
+		var result struct {
+			ZoneStates map[string]zoneState `json:"zoneStates"`
+		}
+		if err := p.authManager.get(ctx, fmt.Sprintf("homes/%d/zoneStates", h.ID), nil, &result); err != nil {
+			return nil, fmt.Errorf("requesting zone states of home %d: %w", h.ID, err)
+		}
+		for zoneID, state := range result.ZoneStates {
+			id, err := strconv.ParseInt(zoneID, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid zone ID %q in home %d", zoneID, h.ID)
+			}
+			ref := model.ThermostatRef{ID: thermostatID(h.ID, id), Provider: ProviderName}
+			summaries[ref.ID] = summary(ref, state)
+		}
+	}
+	return summaries, nil
+}
+
+// GetSnapshot returns the zone's current settings and the hardware that
+// measures it
+func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	homeID, zoneID, err := parseThermostatID(tr.ID)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	state, err := p.getState(ctx, tr)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	zones, err := p.listZones(ctx, homeID)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	var z zone
+	for _, candidate := range zones {
+		if candidate.ID == zoneID {
+			z = candidate
+		}
+	}
+	return state.snapshot(tr, z, time.Now()), nil
+}
+
+// GetRuntime returns the closed 5-minute bins between from and to, built
+// from one day report per local day of the home. Bins a day report does not
+// cover are missing.
+func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	homeID, zoneID, err := parseThermostatID(tr.ID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(tr.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	now := time.Now()
+	var rows []model.RuntimeRow
+	seen := make(map[time.Time]bool)
+	first := from.In(loc)
+	last := to.Add(-time.Nanosecond).In(loc)
+	for day := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, loc); !day.After(last) && day.Before(now); day = day.AddDate(0, 0, 1) {
+		var report dayReport
+		resource := fmt.Sprintf("homes/%d/zones/%d/dayReport", homeID, zoneID)
+		if err := p.authManager.get(ctx, resource, url.Values{"date": {day.Format(time.DateOnly)}}, &report); err != nil {
+			return nil, fmt.Errorf("requesting day report of %s for %s: %w", tr.ID, day.Format(time.DateOnly), err)
+		}
+		// Reports run past their day, so neighbouring days share a few bins
+		for _, row := range report.rows(tr, from, to, now) {
+			if !seen[row.EventTime] {
+				seen[row.EventTime] = true
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// listHomes returns the homes the account can read
+func (p *Provider) listHomes(ctx context.Context) ([]home, error) {
+	var me struct {
+		Homes []home `json:"homes"`
+	}
+	if err := p.authManager.get(ctx, "me", nil, &me); err != nil {
+		return nil, fmt.Errorf("requesting homes: %w", err)
+	}
+	return me.Homes, nil
+}
+
+// listZones returns the zones of a home
+func (p *Provider) listZones(ctx context.Context, homeID int64) ([]zone, error) {
+	var zones []zone
+	if err := p.authManager.get(ctx, fmt.Sprintf("homes/%d/zones", homeID), nil, &zones); err != nil {
+		return nil, fmt.Errorf("requesting zones of home %d: %w", homeID, err)
+	}
+	return zones, nil
+}
+
+// getState fetches a zone's current state
+func (p *Provider) getState(ctx context.Context, tr model.ThermostatRef) (zoneState, error) {
+	homeID, zoneID, err := parseThermostatID(tr.ID)
+	if err != nil {
+		return zoneState{}, err
+	}
+	var raw json.RawMessage
+	if err := p.authManager.get(ctx, fmt.Sprintf("homes/%d/zones/%d/state", homeID, zoneID), nil, &raw); err != nil {
+		return zoneState{}, fmt.Errorf("requesting state of %s: %w", tr.ID, err)
+	}
+	var state zoneState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return zoneState{}, fmt.Errorf("decoding state of %s: %w", tr.ID, err)
+	}
+	state.raw = raw
+	return state, nil
+}
+
+// summary builds the summary of a zone
+func summary(tr model.ThermostatRef, state zoneState) model.Summary {
+	return model.Summary{
+		ThermostatRef: tr,
+		Revision:      state.revision(),
+		LastUpdate:    time.Now(),
+		Connected:     state.connected(),
+	}
+}
+
+// UseCredentialFile reads the refresh token from a file; see
+// AuthManager.UseCredentialFile
+func (p *Provider) UseCredentialFile(refreshTokenPath string) error {
+	return p.authManager.UseCredentialFile(refreshTokenPath)
+}
+
+// ReloadCredentials re-reads the refresh token file, if one is configured
+func (p *Provider) ReloadCredentials() (bool, error) {
+	return p.authManager.ReloadCredentials()
+}
+
+// SetMaxResponseBytes sets the largest response body read from Tado; see
+// AuthManager.SetMaxResponseBytes
+func (p *Provider) SetMaxResponseBytes(limit int64) {
+	p.authManager.SetMaxResponseBytes(limit)
+}
+
+// BytesFetched returns the response bytes read from Tado
+func (p *Provider) BytesFetched() int64 {
+	return p.authManager.BytesFetched()
+}
+
+// Auth returns the authentication manager for this provider
+func (p *Provider) Auth() model.AuthManager {
+	return p.authManager
+}
@@ -0,0 +1,281 @@
+package tado
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/providertest"
+)
+
+// zoneStateResponse returns a zone state of a heating zone set to target
+func zoneStateResponse(target float64) map[string]any {
+	return map[string]any{
+		"tadoMode": "HOME",
+		"setting":  map[string]any{"type": "HEATING", "power": "ON", "temperature": map[string]any{"celsius": target}},
+		"link":     map[string]any{"state": "ONLINE"},
+		"sensorDataPoints": map[string]any{
+			"insideTemperature": map[string]any{"celsius": 19.8},
+		},
+	}
+}
+
+// reportDay is the day the fake API has a day report for
+var reportDay = time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+
+// dayReportResponse returns a day report of reportDay: 20°C rising to 21°C
+// over the first half hour, heating from 00:10 to 00:20 and away from 00:30
+func dayReportResponse() map[string]any {
+	at := func(minutes int) string {
+		return reportDay.Add(time.Duration(minutes) * time.Minute).Format(time.RFC3339)
+	}
+	interval := func(from, to int, value any) map[string]any {
+		return map[string]any{"from": at(from), "to": at(to), "value": value}
+	}
+	return map[string]any{
+		"interval": map[string]any{"from": at(-15), "to": at(24*60 + 15)},
+		"measuredData": map[string]any{"insideTemperature": map[string]any{"dataPoints": []map[string]any{
+			{"timestamp": at(-15), "value": map[string]any{"celsius": 20}},
+			{"timestamp": at(0), "value": map[string]any{"celsius": 20}},
+			{"timestamp": at(30), "value": map[string]any{"celsius": 21}},
+			{"timestamp": at(24*60 + 15), "value": map[string]any{"celsius": 21}},
+		}}},
+		"stripes": map[string]any{"dataIntervals": []map[string]any{
+			interval(-15, 30, map[string]any{"stripeType": "HOME"}),
+			interval(30, 24*60+15, map[string]any{"stripeType": "AWAY"}),
+		}},
+		"settings": map[string]any{"dataIntervals": []map[string]any{
+			interval(-15, 24*60+15, map[string]any{"type": "HEATING", "power": "ON", "temperature": map[string]any{"celsius": 21}}),
+		}},
+		"callForHeat": map[string]any{"dataIntervals": []map[string]any{
+			interval(-15, 10, "NONE"),
+			interval(10, 20, "HIGH"),
+			interval(20, 24*60+15, "NONE"),
+		}},
+		"weather": map[string]any{"condition": map[string]any{"dataIntervals": []map[string]any{
+			interval(-15, 24*60+15, map[string]any{"state": "CLOUDY", "temperature": map[string]any{"celsius": 4}}),
+		}}},
+	}
+}
+
+// fakeAPI answers requests for one home with a heating zone, a hot water
+// zone and an air conditioning zone, serving state from states by zone ID
+func fakeAPI(t *testing.T, states map[string]map[string]any) http.HandlerFunc {
+	zones := []map[string]any{
+		{"id": 1, "name": "Living Room", "type": "HEATING", "devices": []map[string]any{
+			{"deviceType": "VA02", "serialNo": "VA1", "currentFwVersion": "57.1", "duties": []string{"ZONE_UI"}},
+			{"deviceType": "RU02", "serialNo": "RU1", "currentFwVersion": "67.2", "duties": []string{"ZONE_LEADER", "ZONE_UI"}},
+		}},
+		{"id": 0, "name": "Hot Water", "type": "HOT_WATER"},
+		{"id": 3, "name": "Bedroom", "type": "AIR_CONDITIONING"},
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		var response any
+		switch r.URL.Path {
+		case "/me":
+			response = map[string]any{"homes": []map[string]any{{"id": 42, "name": "Home"}}}
+		case "/homes/42":
+			response = map[string]any{"id": 42, "name": "Home", "dateTimeZone": "Europe/London"}
+		case "/homes/42/zones":
+			response = zones
+		case "/homes/42/zoneStates":
+			response = map[string]any{"zoneStates": states}
+		case "/homes/42/zones/1/state":
+			response = states["1"]
+		case "/homes/42/zones/3/state":
+			response = states["3"]
+		case "/homes/42/zones/1/dayReport", "/homes/42/zones/3/dayReport":
+			if r.URL.Query().Get("date") != reportDay.Format(time.DateOnly) {
+				http.NotFound(w, r)
+				return
+			}
+			response = dayReportResponse()
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(response)
+	}
+}
+
+// newTestProvider returns a provider with a valid token pointed at a test server
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	originalURL := tadoAPIURL
+	tadoAPIURL = server.URL
+	t.Cleanup(func() { tadoAPIURL = originalURL })
+
+	provider := NewProvider(DefaultClientID, "refresh")
+	provider.authManager.accessToken = "token"
+	provider.authManager.tokenExpiry = time.Now().Add(time.Hour)
+	return provider
+}
+
+// defaultStates returns the states of the fake API's thermostat zones
+func defaultStates() map[string]map[string]any {
+	return map[string]map[string]any{"1": zoneStateResponse(21), "3": zoneStateResponse(20)}
+}
+
+func TestProviderConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		New: func(t *testing.T) model.Provider {
+			return newTestProvider(t, fakeAPI(t, defaultStates()))
+		},
+		RuntimeFrom:         reportDay,
+		RuntimeTo:           reportDay.Add(time.Hour),
+		RequireRuntime:      true,
+		RequireCancellation: true,
+	})
+}
+
+func TestListThermostats(t *testing.T) {
+	provider := newTestProvider(t, fakeAPI(t, defaultStates()))
+
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []model.ThermostatRef{
+		{ID: "42-1", Name: "Living Room", Provider: "tado", HouseholdID: "42", TimeZone: "Europe/London"},
+		{ID: "42-3", Name: "Bedroom", Provider: "tado", HouseholdID: "42", TimeZone: "Europe/London"},
+	}
+	if len(thermostats) != len(expected) || thermostats[0] != expected[0] || thermostats[1] != expected[1] {
+		t.Errorf("Expected the heating and air conditioning zones %+v, got %+v", expected, thermostats)
+	}
+}
+
+func TestSummaryRevisionIgnoresReadings(t *testing.T) {
+	states := defaultStates()
+	provider := newTestProvider(t, fakeAPI(t, states))
+	ref := model.ThermostatRef{ID: "42-1", Provider: "tado"}
+
+	first, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Connected == nil || !*first.Connected {
+		t.Errorf("Expected an online zone to be connected, got %+v", first)
+	}
+	states["1"]["sensorDataPoints"] = map[string]any{"insideTemperature": map[string]any{"celsius": 20.4}}
+	second, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Revision != first.Revision {
+		t.Errorf("Expected readings not to change the revision, got %s and %s", first.Revision, second.Revision)
+	}
+	states["1"] = zoneStateResponse(22)
+	third, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if third.Revision == first.Revision {
+		t.Error("Expected a setpoint change to change the revision")
+	}
+}
+
+func TestGetSummariesMatchesGetSummary(t *testing.T) {
+	provider := newTestProvider(t, fakeAPI(t, defaultStates()))
+
+	summaries, err := provider.GetSummaries(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	single, err := provider.GetSummary(context.Background(), model.ThermostatRef{ID: "42-1", Provider: "tado"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(summaries) != 2 || summaries["42-1"].Revision != single.Revision {
+		t.Errorf("Expected summaries of both zones matching GetSummary, got %+v", summaries)
+	}
+}
+
+func TestSnapshotReportsOverlayAndAway(t *testing.T) {
+	states := defaultStates()
+	expiry := time.Date(2025, 1, 10, 18, 0, 0, 0, time.UTC)
+	states["1"]["tadoMode"] = "AWAY"
+	states["1"]["overlay"] = map[string]any{
+		"type":        "MANUAL",
+		"setting":     map[string]any{"type": "HEATING", "power": "ON", "temperature": map[string]any{"celsius": 23}},
+		"termination": map[string]any{"type": "TIMER", "projectedExpiry": expiry.Format(time.RFC3339)},
+	}
+	provider := newTestProvider(t, fakeAPI(t, states))
+
+	snapshot, err := provider.GetSnapshot(context.Background(), model.ThermostatRef{ID: "42-1", Provider: "tado"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if snapshot.Model != "RU02" || snapshot.FirmwareVersion != "67.2" {
+		t.Errorf("Expected the zone leader's model and firmware, got %q and %q", snapshot.Model, snapshot.FirmwareVersion)
+	}
+	if len(snapshot.Events) != 2 || snapshot.Events[0].Name != "overlay" || snapshot.Events[1].Name != "away" {
+		t.Fatalf("Expected overlay and away events, got %+v", snapshot.Events)
+	}
+	overlay := snapshot.Events[0]
+	if overlay.SetHeatC == nil || *overlay.SetHeatC != 23 || !overlay.End.Equal(expiry) {
+		t.Errorf("Expected an overlay at 23°C until %v, got %+v", expiry, overlay)
+	}
+}
+
+func TestGetRuntimeFromDayReport(t *testing.T) {
+	provider := newTestProvider(t, fakeAPI(t, defaultStates()))
+	ref := model.ThermostatRef{ID: "42-1", Provider: "tado", TimeZone: "Europe/London"}
+
+	rows, err := provider.GetRuntime(context.Background(), ref, reportDay, reportDay.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 12 {
+		t.Fatalf("Expected 12 bins, got %d", len(rows))
+	}
+
+	first := rows[0]
+	if !first.EventTime.Equal(reportDay) || first.Mode != "heat" || first.Climate != "Home" || *first.SetHeatC != 21 {
+		t.Errorf("Unexpected first bin %+v", first)
+	}
+	// 2.5 minutes into a rise of 1°C over 30 minutes
+	if !approx(*first.AvgTempC, 20+2.5/30) || *first.OutdoorTempC != 4 {
+		t.Errorf("Expected interpolated inside and reported outdoor temperatures, got %v and %v", *first.AvgTempC, *first.OutdoorTempC)
+	}
+	for i, row := range rows {
+		heating := i == 2 || i == 3
+		if row.Equipment["compHeat1"] != heating || (heating && row.EquipmentSecs["compHeat1"] != 300) {
+			t.Errorf("Unexpected heating in bin %d: %v %v", i, row.Equipment, row.EquipmentSecs)
+		}
+	}
+	if rows[6].Climate != "Away" {
+		t.Errorf("Expected the bin at 00:30 to be away, got %s", rows[6].Climate)
+	}
+}
+
+func TestEquipmentOfAirConditioning(t *testing.T) {
+	var report dayReport
+	report.ACActivity.DataIntervals = []dataInterval[string]{{
+		From:  reportDay.Add(2 * time.Minute),
+		To:    reportDay.Add(time.Hour),
+		Value: "ON",
+	}}
+	for mode, key := range map[string]string{"COOL": "compCool1", "HEAT": "compHeat1", "DRY": "compCool1"} {
+		setting := zoneSetting{Type: zoneTypeAirConditioning, Power: "ON", Mode: mode}
+		equipment, seconds := report.equipment(setting, reportDay, reportDay.Add(binSize))
+		if !equipment[key] || seconds[key] != 180 {
+			t.Errorf("Expected %s to run %s for 180s, got %v %v", mode, key, equipment, seconds)
+		}
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
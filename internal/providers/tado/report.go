@@ -0,0 +1,172 @@
+package tado
+
+import (
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// binSize is the interval runtime rows are built for
+const binSize = 5 * time.Minute
+
+// dataInterval is a value that held from From until To
+type dataInterval[T any] struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Value T         `json:"value"`
+}
+
+// intervalSeries is a report time series of intervals
+type intervalSeries[T any] struct {
+	DataIntervals []dataInterval[T] `json:"dataIntervals"`
+}
+
+// at returns the value of the interval covering t
+func (s intervalSeries[T]) at(t time.Time) (T, bool) {
+	for _, interval := range s.DataIntervals {
+		if !t.Before(interval.From) && t.Before(interval.To) {
+			return interval.Value, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// seconds returns how long intervals whose value matches overlap from
+// start until end
+func (s intervalSeries[T]) seconds(start, end time.Time, match func(T) bool) int {
+	var total time.Duration
+	for _, interval := range s.DataIntervals {
+		if !match(interval.Value) {
+			continue
+		}
+		from, to := interval.From, interval.To
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			total += to.Sub(from)
+		}
+	}
+	return int(total.Seconds())
+}
+
+// temperaturePoint is one inside temperature measurement
+type temperaturePoint struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Value     temperatureValue `json:"value"`
+}
+
+// dayReport is a zone's history for one local day, as shown in the app:
+// measured temperatures about every 15 minutes, and intervals of settings,
+// presence, heating demand, air conditioning activity and outdoor weather.
+// Its interval runs slightly past the day on both sides.
+type dayReport struct {
+	Interval struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"interval"`
+	MeasuredData struct {
+		InsideTemperature struct {
+			DataPoints []temperaturePoint `json:"dataPoints"`
+		} `json:"insideTemperature"`
+	} `json:"measuredData"`
+	Stripes intervalSeries[struct {
+		StripeType string `json:"stripeType"` // HOME, AWAY, OVERLAY_ACTIVE, OPEN_WINDOW_DETECTED...
+	}] `json:"stripes"`
+	Settings    intervalSeries[zoneSetting] `json:"settings"`
+	CallForHeat intervalSeries[string]      `json:"callForHeat"` // NONE, LOW, MEDIUM or HIGH
+	ACActivity  intervalSeries[string]      `json:"acActivity"`  // ON or OFF
+	Weather     struct {
+		Condition intervalSeries[struct {
+			Temperature *temperatureValue `json:"temperature"`
+		}] `json:"condition"`
+	} `json:"weather"`
+}
+
+// rows converts the report into the 5-minute runtime rows starting from from
+// until to. Only bins the report covers and that closed before now are
+// returned. Mode, setpoints, climate and outdoor temperature are those in
+// effect at the start of a bin; the indoor temperature is interpolated
+// between measurements at its middle.
+func (r dayReport) rows(tr model.ThermostatRef, from, to, now time.Time) []model.RuntimeRow {
+	start := from.Truncate(binSize)
+	if start.Before(r.Interval.From) {
+		start = r.Interval.From.Truncate(binSize)
+		if start.Before(r.Interval.From) {
+			start = start.Add(binSize)
+		}
+	}
+
+	var rows []model.RuntimeRow
+	for bin := start; bin.Before(to); bin = bin.Add(binSize) {
+		end := bin.Add(binSize)
+		if end.After(r.Interval.To) || end.After(now) {
+			break
+		}
+		setting, ok := r.Settings.at(bin)
+		if !ok {
+			continue
+		}
+
+		row := model.RuntimeRow{
+			ThermostatRef: tr,
+			EventTime:     bin,
+			Mode:          setting.mode(),
+			Climate:       "Home",
+			AvgTempC:      interpolate(r.MeasuredData.InsideTemperature.DataPoints, bin.Add(binSize/2)),
+		}
+		row.SetHeatC, row.SetCoolC = setting.setpoints()
+		if stripe, ok := r.Stripes.at(bin); ok && stripe.StripeType == "AWAY" {
+			row.Climate = "Away"
+		}
+		if condition, ok := r.Weather.Condition.at(bin); ok && condition.Temperature != nil {
+			outdoor := condition.Temperature.Celsius
+			row.OutdoorTempC = &outdoor
+		}
+		row.Equipment, row.EquipmentSecs = r.equipment(setting, bin, end)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// equipment returns what ran during a bin and for how long. Heating zones
+// report heating demand, which is recorded as compHeat1; air conditioning
+// zones report activity, recorded by mode as compHeat1, compCool1 or fan.
+func (r dayReport) equipment(setting zoneSetting, start, end time.Time) (map[string]bool, map[string]int) {
+	key, seconds := "compHeat1", 0
+	if setting.Type == zoneTypeAirConditioning {
+		switch setting.mode() {
+		case "heat":
+		case "off":
+			key = "fan"
+		default:
+			key = "compCool1"
+		}
+		seconds = r.ACActivity.seconds(start, end, func(v string) bool { return v == "ON" })
+	} else {
+		seconds = r.CallForHeat.seconds(start, end, func(v string) bool { return v != "" && v != "NONE" })
+	}
+	return map[string]bool{key: seconds > 0}, map[string]int{key: seconds}
+}
+
+// interpolate returns the temperature at t on the line between the
+// measurements around it, or nil when t is not between two measurements
+func interpolate(points []temperaturePoint, t time.Time) *float64 {
+	for i := 1; i < len(points); i++ {
+		before, after := points[i-1], points[i]
+		if t.Before(before.Timestamp) || t.After(after.Timestamp) {
+			continue
+		}
+		span := after.Timestamp.Sub(before.Timestamp)
+		value := before.Value.Celsius
+		if span > 0 {
+			value += (after.Value.Celsius - before.Value.Celsius) * float64(t.Sub(before.Timestamp)) / float64(span)
+		}
+		return &value
+	}
+	return nil
+}
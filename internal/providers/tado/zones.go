@@ -0,0 +1,218 @@
+package tado
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Tado zone types; hot water zones are not thermostats and are ignored
+const (
+	zoneTypeHeating         = "HEATING"
+	zoneTypeAirConditioning = "AIR_CONDITIONING"
+)
+
+// acModes maps air conditioning setting modes to canonical modes. Dry mode
+// runs the compressor like cooling; fan mode only runs the fan.
+var acModes = map[string]string{
+	"COOL": "cool",
+	"DRY":  "cool",
+	"HEAT": "heat",
+	"AUTO": "auto",
+	"FAN":  "off",
+}
+
+// home is an entry of the homes a Tado account can read
+type home struct {
+	ID           int64  `json:"id"`
+	Name         string `json:"name"`
+	DateTimeZone string `json:"dateTimeZone"`
+}
+
+// zone is a room or area of a home controlled as a unit
+type zone struct {
+	ID      int64    `json:"id"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Devices []device `json:"devices"`
+}
+
+// device is a piece of Tado hardware in a zone
+type device struct {
+	DeviceType       string   `json:"deviceType"`
+	SerialNo         string   `json:"serialNo"`
+	CurrentFwVersion string   `json:"currentFwVersion"`
+	Duties           []string `json:"duties"`
+}
+
+// temperatureValue is a temperature as Tado reports it, in both units
+type temperatureValue struct {
+	Celsius float64 `json:"celsius"`
+}
+
+// zoneSetting is what a zone is set to: power, the air conditioning mode and
+// the target temperature
+type zoneSetting struct {
+	Type        string            `json:"type"`
+	Power       string            `json:"power"` // ON or OFF
+	Mode        string            `json:"mode,omitempty"`
+	Temperature *temperatureValue `json:"temperature,omitempty"`
+}
+
+// zoneState is a zone's current state: its setting, any manual overlay of
+// the schedule, the home's presence mode and the latest readings
+type zoneState struct {
+	TadoMode string      `json:"tadoMode"` // HOME or AWAY
+	Setting  zoneSetting `json:"setting"`
+	Overlay  *struct {
+		Type        string      `json:"type"`
+		Setting     zoneSetting `json:"setting"`
+		Termination struct {
+			Type            string     `json:"type"` // MANUAL, TIMER or TADO_MODE
+			ProjectedExpiry *time.Time `json:"projectedExpiry"`
+		} `json:"termination"`
+	} `json:"overlay"`
+	OpenWindow *struct {
+		DetectedTime      time.Time `json:"detectedTime"`
+		DurationInSeconds int       `json:"durationInSeconds"`
+	} `json:"openWindow"`
+	Link struct {
+		State string `json:"state"` // ONLINE or OFFLINE
+	} `json:"link"`
+
+	// raw is the response as received, kept as the snapshot program
+	raw json.RawMessage
+}
+
+// thermostatID returns the thermostat ID of a zone, unique across homes
+func thermostatID(homeID, zoneID int64) string {
+	return fmt.Sprintf("%d-%d", homeID, zoneID)
+}
+
+// parseThermostatID splits a thermostat ID into its home and zone IDs
+func parseThermostatID(id string) (homeID, zoneID int64, err error) {
+	homePart, zonePart, ok := strings.Cut(id, "-")
+	if ok {
+		homeID, err = strconv.ParseInt(homePart, 10, 64)
+	}
+	if ok && err == nil {
+		zoneID, err = strconv.ParseInt(zonePart, 10, 64)
+	}
+	if !ok || err != nil {
+		return 0, 0, fmt.Errorf("invalid tado thermostat ID %q", id)
+	}
+	return homeID, zoneID, nil
+}
+
+// isThermostat reports whether a zone has a thermostat to read
+func (z zone) isThermostat() bool {
+	return z.Type == zoneTypeHeating || z.Type == zoneTypeAirConditioning
+}
+
+// leader returns the device that measures the zone, or its first device
+func (z zone) leader() device {
+	for _, d := range z.Devices {
+		if slices.Contains(d.Duties, "ZONE_LEADER") {
+			return d
+		}
+	}
+	if len(z.Devices) > 0 {
+		return z.Devices[0]
+	}
+	return device{}
+}
+
+// mode returns the canonical mode of a setting
+func (s zoneSetting) mode() string {
+	switch {
+	case s.Power != "ON":
+		return "off"
+	case s.Type == zoneTypeAirConditioning:
+		return acModes[s.Mode]
+	default:
+		return "heat"
+	}
+}
+
+// setpoints returns the setting's target temperature as a heat or a cool
+// setpoint, following its mode
+func (s zoneSetting) setpoints() (heat, cool *float64) {
+	if s.Power != "ON" || s.Temperature == nil {
+		return nil, nil
+	}
+	target := s.Temperature.Celsius
+	switch s.mode() {
+	case "heat":
+		return &target, nil
+	case "cool", "auto":
+		return nil, &target
+	default:
+		return nil, nil
+	}
+}
+
+// connected reports whether the zone's devices reach Tado; nil when the
+// state does not say
+func (s zoneState) connected() *bool {
+	if s.Link.State == "" {
+		return nil
+	}
+	connected := s.Link.State == "ONLINE"
+	return &connected
+}
+
+// revision returns a hash of the zone's settings, leaving out readings that
+// change between polls, so a new revision means a new snapshot
+func (s zoneState) revision() string {
+	settings := []any{s.TadoMode, s.Setting, s.OpenWindow != nil}
+	if s.Overlay != nil {
+		settings = append(settings, s.Overlay.Type, s.Overlay.Setting, s.Overlay.Termination.Type)
+	}
+	encoded, _ := json.Marshal(settings)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8])
+}
+
+// snapshot converts a zone's state into a snapshot. The state response is
+// kept as the program; a manual overlay, away mode and a detected open
+// window are reported as running events.
+func (s zoneState) snapshot(tr model.ThermostatRef, z zone, at time.Time) model.Snapshot {
+	leader := z.leader()
+	snapshot := model.Snapshot{
+		ThermostatRef:   tr,
+		CollectedAt:     at,
+		Revision:        s.revision(),
+		Model:           leader.DeviceType,
+		FirmwareVersion: leader.CurrentFwVersion,
+		Program:         s.raw,
+	}
+
+	if s.Overlay != nil {
+		heat, cool := s.Overlay.Setting.setpoints()
+		overlay := model.Event{Kind: "hold", Name: "overlay", Running: true, SetHeatC: heat, SetCoolC: cool}
+		if expiry := s.Overlay.Termination.ProjectedExpiry; expiry != nil {
+			overlay.End = *expiry
+		}
+		snapshot.Events = append(snapshot.Events, overlay)
+	}
+	if s.TadoMode == "AWAY" {
+		snapshot.Events = append(snapshot.Events, model.Event{Kind: "hold", Name: "away", Running: true})
+	}
+	if s.OpenWindow != nil {
+		snapshot.Events = append(snapshot.Events, model.Event{
+			Kind:    "hold",
+			Name:    "open_window",
+			Running: true,
+			Start:   s.OpenWindow.DetectedTime,
+			End:     s.OpenWindow.DetectedTime.Add(time.Duration(s.OpenWindow.DurationInSeconds) * time.Second),
+		})
+	}
+	return snapshot
+}
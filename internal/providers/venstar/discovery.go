@@ -0,0 +1,122 @@
+package venstar
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ssdpAddress is where SSDP searches are sent; a variable so tests can answer
+// them on loopback
+var ssdpAddress = "239.255.255.250:1900"
+
+// ssdpSearchTarget is the search target Venstar units answer
+const ssdpSearchTarget = "venstar:thermostat:ecp"
+
+// discoveredUnit is a unit that answered an SSDP search
+type discoveredUnit struct {
+	ID      string // MAC address without separators
+	Name    string
+	BaseURL string
+}
+
+// discover searches the LAN for Venstar units with SSDP, collecting answers
+// until timeout passes or ctx is done
+func discover(ctx context.Context, timeout time.Duration) ([]discoveredUnit, error) {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return nil, fmt.Errorf("resolving SSDP address: %w", err)
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening SSDP socket: %w", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := conn.SetDeadline(deadline); err != nil {
+		return nil, fmt.Errorf("setting SSDP deadline: %w", err)
+	}
+	// Unblock the read below when ctx is cancelled
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: " + ssdpSearchTarget + "\r\n\r\n"
+	if _, err := conn.WriteToUDP([]byte(search), addr); err != nil {
+		return nil, fmt.Errorf("sending SSDP search: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var units []discoveredUnit
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				return units, nil
+			}
+			return nil, fmt.Errorf("reading SSDP response: %w", err)
+		}
+		unit, ok := parseSearchResponse(buf[:n])
+		if !ok || seen[unit.ID] {
+			continue
+		}
+		seen[unit.ID] = true
+		units = append(units, unit)
+	}
+}
+
+// parseSearchResponse reads a unit from an SSDP answer, whose USN header
+// reads "ecp:00:23:a7:3a:b2:72:name:Living%20Room:type:residential" and
+// whose Location is the unit's API root
+func parseSearchResponse(data []byte) (discoveredUnit, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), nil)
+	if err != nil {
+		return discoveredUnit{}, false
+	}
+	_ = resp.Body.Close()
+	if resp.Header.Get("ST") != ssdpSearchTarget {
+		return discoveredUnit{}, false
+	}
+
+	usn, ok := strings.CutPrefix(resp.Header.Get("USN"), "ecp:")
+	if !ok {
+		return discoveredUnit{}, false
+	}
+	mac, rest, _ := strings.Cut(usn, ":name:")
+	name, _, _ := strings.Cut(rest, ":type:")
+	location := strings.TrimRight(resp.Header.Get("Location"), "/")
+	if mac == "" || location == "" {
+		return discoveredUnit{}, false
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+	return discoveredUnit{
+		ID:      strings.ToLower(strings.ReplaceAll(mac, ":", "")),
+		Name:    name,
+		BaseURL: location,
+	}, true
+}
@@ -0,0 +1,86 @@
+package venstar
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ssdpResponder answers SSDP searches on loopback with the given responses
+func ssdpResponder(t *testing.T, responses ...string) {
+	t.Helper()
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	original := ssdpAddress
+	ssdpAddress = conn.LocalAddr().String()
+	t.Cleanup(func() { ssdpAddress = original })
+
+	go func() {
+		buf := make([]byte, 2048)
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if !strings.Contains(string(buf[:n]), "ST: "+ssdpSearchTarget) {
+			t.Errorf("Unexpected search %q", buf[:n])
+			return
+		}
+		for _, response := range responses {
+			_, _ = conn.WriteToUDP([]byte(response), from)
+		}
+	}()
+}
+
+// searchResponse returns an SSDP answer from a unit
+func searchResponse(st, usn, location string) string {
+	return "HTTP/1.1 200 OK\r\n" +
+		"Cache-Control: max-age=300\r\n" +
+		"ST: " + st + "\r\n" +
+		"Location: " + location + "\r\n" +
+		"USN: " + usn + "\r\n\r\n"
+}
+
+func TestDiscover(t *testing.T) {
+	ssdpResponder(t,
+		searchResponse(ssdpSearchTarget, "ecp:00:23:A7:3A:B2:72:name:Living%20Room:type:residential", "http://192.168.1.20/"),
+		// answered twice, as units do
+		searchResponse(ssdpSearchTarget, "ecp:00:23:A7:3A:B2:72:name:Living%20Room:type:residential", "http://192.168.1.20/"),
+		searchResponse("upnp:rootdevice", "uuid:other", "http://192.168.1.30/"),
+	)
+
+	units, err := discover(context.Background(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := discoveredUnit{ID: "0023a73ab272", Name: "Living Room", BaseURL: "http://192.168.1.20"}
+	if len(units) != 1 || units[0] != expected {
+		t.Errorf("Expected %+v, got %+v", expected, units)
+	}
+}
+
+func TestDiscoveredUnitsAreListed(t *testing.T) {
+	configured := fakeUnit(t, unitInfo)
+	discovered := fakeUnit(t, unitInfo)
+	ssdpResponder(t,
+		searchResponse(ssdpSearchTarget, "ecp:00:23:a7:00:00:01:name:Configured:type:residential", configured.URL+"/"),
+		searchResponse(ssdpSearchTarget, "ecp:00:23:a7:00:00:02:name:Basement:type:residential", discovered.URL+"/"),
+	)
+
+	provider := NewProvider([]string{configured.URL})
+	provider.EnableDiscovery(200 * time.Millisecond)
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	configuredID, _ := hostURL(configured.URL)
+	if len(thermostats) != 2 || thermostats[0].ID != configuredID || thermostats[1].ID != "0023a7000002" {
+		t.Errorf("Expected the configured unit once and the discovered one by MAC, got %+v", thermostats)
+	}
+}
@@ -0,0 +1,194 @@
+package venstar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+// Venstar query/info enumerations
+const (
+	tempUnitsCelsius = 1
+
+	stateHeating = 1
+	stateCooling = 2
+
+	schedulePartNight = 3
+)
+
+// venstarModes maps query/info modes to canonical modes
+var venstarModes = map[int]string{
+	0: "off",
+	1: "heat",
+	2: "cool",
+	3: "auto",
+}
+
+// info is the query/info response: the thermostat's settings and state.
+// Temperatures are in the unit given by TempUnits.
+type info struct {
+	Name          string  `json:"name"`
+	Mode          int     `json:"mode"`
+	State         int     `json:"state"`
+	Fan           int     `json:"fan"`
+	FanState      int     `json:"fanstate"`
+	TempUnits     int     `json:"tempunits"`
+	Schedule      int     `json:"schedule"`
+	SchedulePart  int     `json:"schedulepart"`
+	Away          int     `json:"away"`
+	Holiday       int     `json:"holiday"`
+	Override      int     `json:"override"`
+	OverrideTime  int     `json:"overridetime"` // minutes left in an override
+	SpaceTemp     float64 `json:"spacetemp"`
+	HeatTemp      float64 `json:"heattemp"`
+	CoolTemp      float64 `json:"cooltemp"`
+	SetpointDelta float64 `json:"setpointdelta"`
+
+	// raw is the response as received, kept as the snapshot program
+	raw json.RawMessage
+}
+
+// sensor is one entry of the query/sensors response
+type sensor struct {
+	Name string   `json:"name"`
+	Temp *float64 `json:"temp"`
+}
+
+// apiInfo is the response of the API root, identifying the unit
+type apiInfo struct {
+	APIVersion int    `json:"api_ver"`
+	Type       string `json:"type"`
+	Model      string `json:"model"`
+	Firmware   string `json:"firmware"`
+}
+
+// celsius converts a temperature reported by the unit to Celsius
+func (i info) celsius(value float64) (*float64, error) {
+	if i.TempUnits == tempUnitsCelsius {
+		return &value, nil
+	}
+	return temperature.ConvertToCelsius(&value, temperature.Format{Unit: temperature.Fahrenheit, Scale: temperature.ScaleNone})
+}
+
+// revision returns a hash of the unit's settings, leaving out readings that
+// change between polls and the schedule part, which moves on its own through
+// the day, so a new revision means a new snapshot
+func (i info) revision() string {
+	settings := fmt.Sprintf("%s|%d|%d|%d|%d|%d|%d|%d|%g|%g|%g",
+		i.Name, i.Mode, i.Fan, i.TempUnits, i.Schedule, i.Away, i.Holiday, i.Override,
+		i.HeatTemp, i.CoolTemp, i.SetpointDelta)
+	sum := sha256.Sum256([]byte(settings))
+	return hex.EncodeToString(sum[:8])
+}
+
+// climate names the comfort setting in effect: Away while the unit is set
+// away, Sleep during the night schedule part and Home otherwise
+func (i info) climate() string {
+	switch {
+	case i.Away == 1:
+		return "Away"
+	case i.Schedule == 1 && i.SchedulePart == schedulePartNight:
+		return "Sleep"
+	default:
+		return "Home"
+	}
+}
+
+// reading converts the unit's state and sensors into a runtime reading
+// stamped at, in Celsius. Venstar does not say which heating or cooling
+// stage runs, so heating is reported as compHeat1 and cooling as compCool1.
+// The outdoor sensor becomes the outdoor temperature, and sensors other than
+// the thermostat's own are reported by name.
+func (i info) reading(tr model.ThermostatRef, sensors []sensor, at time.Time) (model.RuntimeRow, error) {
+	row := model.RuntimeRow{
+		ThermostatRef: tr,
+		EventTime:     at,
+		Mode:          venstarModes[i.Mode],
+		Climate:       i.climate(),
+		Equipment: map[string]bool{
+			"compHeat1": i.State == stateHeating,
+			"compCool1": i.State == stateCooling,
+			"fan":       i.FanState == 1,
+		},
+	}
+
+	var err error
+	for _, temp := range []struct {
+		target **float64
+		value  float64
+	}{{&row.AvgTempC, i.SpaceTemp}, {&row.SetHeatC, i.HeatTemp}, {&row.SetCoolC, i.CoolTemp}} {
+		if *temp.target, err = i.celsius(temp.value); err != nil {
+			return model.RuntimeRow{}, err
+		}
+	}
+
+	for _, s := range sensors {
+		if s.Temp == nil {
+			continue
+		}
+		tempC, err := i.celsius(*s.Temp)
+		if err != nil {
+			return model.RuntimeRow{}, err
+		}
+		switch s.Name {
+		case "Thermostat", "Space Temp":
+		case "Outdoor":
+			row.OutdoorTempC = tempC
+		default:
+			if row.Sensors == nil {
+				row.Sensors = make(map[string]float64)
+			}
+			row.Sensors[sensorKey(s.Name)] = *tempC
+		}
+	}
+	return row, nil
+}
+
+// sensorKey turns a sensor name such as "Return Temp" into a key
+// ("return_temp")
+func sensorKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+// snapshot converts the unit's settings into a snapshot. The query/info
+// response is kept as the program; away, holiday and override modes are
+// reported as running events.
+func (i info) snapshot(tr model.ThermostatRef, unit apiInfo, at time.Time) (model.Snapshot, error) {
+	snapshot := model.Snapshot{
+		ThermostatRef:   tr,
+		CollectedAt:     at,
+		Revision:        i.revision(),
+		Model:           unit.Model,
+		FirmwareVersion: unit.Firmware,
+		Program:         i.raw,
+	}
+
+	heat, err := i.celsius(i.HeatTemp)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	cool, err := i.celsius(i.CoolTemp)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	if i.Away == 1 {
+		snapshot.Events = append(snapshot.Events, model.Event{Kind: "hold", Name: "away", Running: true})
+	}
+	if i.Holiday == 1 {
+		snapshot.Events = append(snapshot.Events, model.Event{Kind: "vacation", Name: "holiday", Running: true})
+	}
+	if i.Override == 1 {
+		override := model.Event{Kind: "hold", Name: "override", Running: true, SetHeatC: heat, SetCoolC: cool}
+		if i.OverrideTime > 0 {
+			override.End = at.Add(time.Duration(i.OverrideTime) * time.Minute)
+		}
+		snapshot.Events = append(snapshot.Events, override)
+	}
+	return snapshot, nil
+}
@@ -0,0 +1,313 @@
+// Package venstar reads Venstar ColorTouch thermostats through their local
+// HTTP API. Units are polled directly on the LAN, by configured address or
+// found with SSDP discovery, and need no cloud account or tokens.
+package venstar
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/sampled"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// ProviderName is the name Venstar thermostats are reported under
+const ProviderName = "venstar"
+
+// errUnreachable marks requests that got no answer from a unit, which is
+// reported as the thermostat being disconnected
+var errUnreachable = errors.New("unit unreachable")
+
+// unit is one thermostat on the LAN
+type unit struct {
+	baseURL string
+	name    string
+
+	// mu serializes requests; the unit's web server answers one at a time
+	mu sync.Mutex
+}
+
+// Provider implements the Venstar thermostat provider on the local API. The
+// API reports only current state and daily runtime totals, so runtime is
+// built from the readings taken on each runtime request: every poll adds a
+// reading, and a 5-minute bin is returned once it has closed.
+type Provider struct {
+	httpClient       *http.Client
+	retryConfig      retry.Config
+	maxResponseBytes int64
+	bytesFetched     bodylimit.Counter
+	discoveryTimeout time.Duration
+	readings         *sampled.Readings
+
+	mu    sync.Mutex
+	units map[string]*unit // by thermostat ID
+	order []string         // thermostat IDs, configured units first
+}
+
+// NewProvider creates a new Venstar provider for the units at hosts, given
+// as addresses ("192.168.1.20") or base URLs ("https://192.168.1.20"). A
+// configured unit's thermostat ID is its address.
+func NewProvider(hosts []string) *Provider {
+	retryConfig := retry.DefaultConfig()
+	retryConfig.MaxRetries = 2
+	retryConfig.InitialDelay = 500 * time.Millisecond
+
+	p := &Provider{
+		httpClient:       &http.Client{Timeout: 10 * time.Second},
+		retryConfig:      retryConfig,
+		maxResponseBytes: bodylimit.DefaultMaxBytes,
+		readings:         sampled.NewReadings(sampled.DefaultRetention),
+		units:            make(map[string]*unit),
+	}
+	for _, host := range hosts {
+		id, baseURL := hostURL(host)
+		if _, ok := p.units[id]; ok {
+			continue
+		}
+		p.units[id] = &unit{baseURL: baseURL, name: id}
+		p.order = append(p.order, id)
+	}
+	return p
+}
+
+// hostURL returns the thermostat ID and API base URL of a configured host
+func hostURL(host string) (id, baseURL string) {
+	baseURL = strings.TrimRight(strings.TrimSpace(host), "/")
+	if !strings.Contains(baseURL, "://") {
+		baseURL = "http://" + baseURL
+	}
+	_, id, _ = strings.Cut(baseURL, "://")
+	return id, baseURL
+}
+
+// EnableDiscovery searches the LAN for units with SSDP each time
+// thermostats are listed, waiting timeout for answers. A discovered unit's
+// thermostat ID is its MAC address, so it survives DHCP address changes;
+// units that are also configured keep their configured ID.
+func (p *Provider) EnableDiscovery(timeout time.Duration) {
+	p.discoveryTimeout = timeout
+}
+
+// SetMaxResponseBytes sets the largest response body read from a unit
+func (p *Provider) SetMaxResponseBytes(limit int64) {
+	p.maxResponseBytes = limit
+}
+
+// BytesFetched returns the response bytes read from units
+func (p *Provider) BytesFetched() int64 {
+	return p.bytesFetched.Bytes()
+}
+
+// Info returns metadata about the provider
+func (p *Provider) Info() model.ProviderInfo {
+	return model.ProviderInfo{
+		Name:        ProviderName,
+		Version:     "1.0.0",
+		Description: "Venstar ColorTouch thermostat provider using the local API",
+	}
+}
+
+// Auth returns a no-op authentication manager; the local API needs no tokens
+func (p *Provider) Auth() model.AuthManager {
+	return model.NoAuth{}
+}
+
+// ListThermostats returns the configured units and, with discovery, those
+// that answer an SSDP search. Units that do not answer are still listed,
+// under their last known name, so polling reports them disconnected.
+func (p *Provider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	if p.discoveryTimeout > 0 {
+		found, err := discover(ctx, p.discoveryTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("discovering units: %w", err)
+		}
+		p.addDiscovered(found)
+	}
+
+	p.mu.Lock()
+	ids := slices.Clone(p.order)
+	p.mu.Unlock()
+
+	thermostats := make([]model.ThermostatRef, 0, len(ids))
+	for _, id := range ids {
+		u, err := p.unit(id)
+		if err != nil {
+			return nil, err
+		}
+		i, err := p.getInfo(ctx, u)
+		switch {
+		case err == nil:
+			p.mu.Lock()
+			u.name = i.Name
+			p.mu.Unlock()
+		case !errors.Is(err, errUnreachable):
+			return nil, err
+		}
+
+		p.mu.Lock()
+		name := u.name
+		p.mu.Unlock()
+		thermostats = append(thermostats, model.ThermostatRef{ID: id, Name: name, Provider: ProviderName})
+	}
+	return thermostats, nil
+}
+
+// addDiscovered adds discovered units not already known by ID or address
+func (p *Provider) addDiscovered(found []discoveredUnit) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, d := range found {
+		if _, ok := p.units[d.ID]; ok {
+			continue
+		}
+		known := false
+		for _, u := range p.units {
+			known = known || u.baseURL == d.BaseURL
+		}
+		if known {
+			continue
+		}
+		name := d.Name
+		if name == "" {
+			name = d.ID
+		}
+		p.units[d.ID] = &unit{baseURL: d.BaseURL, name: name}
+		p.order = append(p.order, d.ID)
+	}
+}
+
+// GetSummary returns the unit's settings revision. A unit that does not
+// answer is reported disconnected rather than failing the poll.
+func (p *Provider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	u, err := p.unit(tr.ID)
+	if err != nil {
+		return model.Summary{}, err
+	}
+
+	connected := true
+	i, err := p.getInfo(ctx, u)
+	if errors.Is(err, errUnreachable) {
+		connected = false
+		return model.Summary{ThermostatRef: tr, LastUpdate: time.Now(), Connected: &connected}, nil
+	}
+	if err != nil {
+		return model.Summary{}, err
+	}
+	return model.Summary{ThermostatRef: tr, Revision: i.revision(), LastUpdate: time.Now(), Connected: &connected}, nil
+}
+
+// GetSnapshot returns the unit's current settings, model and firmware
+func (p *Provider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	u, err := p.unit(tr.ID)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	i, err := p.getInfo(ctx, u)
+	if err != nil {
+		return model.Snapshot{}, err
+	}
+	var root apiInfo
+	if err := p.get(ctx, u, "/", &root); err != nil {
+		return model.Snapshot{}, err
+	}
+	return i.snapshot(tr, root, time.Now())
+}
+
+// GetRuntime takes a reading and returns the closed 5-minute bins after the
+// one starting at from and before to, built from the readings taken so far.
+// Bins from before the provider started, or without a reading, are missing.
+func (p *Provider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	u, err := p.unit(tr.ID)
+	if err != nil {
+		return nil, err
+	}
+	i, err := p.getInfo(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	var sensors struct {
+		Sensors []sensor `json:"sensors"`
+	}
+	if err := p.get(ctx, u, "/query/sensors", &sensors); err != nil {
+		return nil, err
+	}
+
+	reading, err := i.reading(tr, sensors.Sensors, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("converting reading of %s: %w", tr.ID, err)
+	}
+	p.readings.Record(reading)
+	return p.readings.Bins(tr.ID, from, to, time.Now()), nil
+}
+
+// unit returns the unit with a thermostat ID
+func (p *Provider) unit(id string) (*unit, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	u, ok := p.units[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown venstar thermostat %s", id)
+	}
+	return u, nil
+}
+
+// getInfo fetches the unit's settings and state
+func (p *Provider) getInfo(ctx context.Context, u *unit) (info, error) {
+	var raw json.RawMessage
+	if err := p.get(ctx, u, "/query/info", &raw); err != nil {
+		return info{}, err
+	}
+	var i info
+	if err := json.Unmarshal(raw, &i); err != nil {
+		return info{}, fmt.Errorf("decoding info from %s: %w", u.baseURL, err)
+	}
+	i.raw = raw
+	return i, nil
+}
+
+// get requests path from the unit and decodes the JSON response into v.
+// Requests that get no answer wrap errUnreachable.
+func (p *Provider) get(ctx context.Context, u *unit, path string, v any) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	resp, err := retry.DoWithResponse(ctx, p.retryConfig, func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.baseURL+path, nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating request: %w", err)
+		}
+		resp, err := p.httpClient.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return nil, fmt.Errorf("%w: %w", errUnreachable, err)
+		}
+		return resp, nil
+	})
+	if err != nil {
+		return fmt.Errorf("requesting %s%s: %w", u.baseURL, path, err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("venstar unit at %s returned status %d for %s", u.baseURL, resp.StatusCode, path)
+	}
+	resp = p.bytesFetched.Limit(resp, p.maxResponseBytes)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s from %s: %w", path, u.baseURL, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package venstar
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/internal/providers/sampled"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/providertest"
+)
+
+// unitInfo returns a query/info response of a heating residential unit in
+// Fahrenheit
+func unitInfo() map[string]any {
+	return map[string]any{
+		"name": "Hallway", "mode": 1, "state": 1, "fan": 0, "fanstate": 1, "tempunits": 0,
+		"schedule": 1, "schedulepart": 1, "away": 0, "holiday": 0, "override": 0, "overridetime": 0,
+		"spacetemp": 68, "heattemp": 70, "cooltemp": 76, "setpointdelta": 2, "hum": 40,
+	}
+}
+
+// fakeUnit serves the local API of one unit
+func fakeUnit(t *testing.T, info func() map[string]any) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			_ = json.NewEncoder(w).Encode(map[string]any{"api_ver": 7, "type": "residential", "model": "COLORTOUCH", "firmware": "6.93"})
+		case "/query/info":
+			_ = json.NewEncoder(w).Encode(info())
+		case "/query/sensors":
+			_ = json.NewEncoder(w).Encode(map[string]any{"sensors": []map[string]any{
+				{"name": "Thermostat", "temp": 68},
+				{"name": "Outdoor", "temp": 32},
+				{"name": "Return Temp", "temp": 66.2},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProviderConformance(t *testing.T) {
+	providertest.Run(t, providertest.Harness{
+		New: func(t *testing.T) model.Provider {
+			return NewProvider([]string{fakeUnit(t, unitInfo).URL})
+		},
+		RequireCancellation: true,
+	})
+}
+
+func TestListThermostats(t *testing.T) {
+	server := fakeUnit(t, unitInfo)
+	provider := NewProvider([]string{server.URL, server.URL + "/"})
+
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	id, _ := hostURL(server.URL)
+	expected := []model.ThermostatRef{{ID: id, Name: "Hallway", Provider: "venstar"}}
+	if len(thermostats) != 1 || thermostats[0] != expected[0] {
+		t.Errorf("Expected %+v, got %+v", expected, thermostats)
+	}
+}
+
+func TestUnreachableUnitIsDisconnected(t *testing.T) {
+	server := fakeUnit(t, unitInfo)
+	server.Close()
+	provider := NewProvider([]string{server.URL})
+	provider.retryConfig.MaxRetries = 0
+
+	thermostats, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Expected unreachable units to be listed, got %v", err)
+	}
+	summary, err := provider.GetSummary(context.Background(), thermostats[0])
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if summary.Connected == nil || *summary.Connected || summary.Revision != "" {
+		t.Errorf("Expected a disconnected summary without a revision, got %+v", summary)
+	}
+}
+
+func TestSummaryRevisionIgnoresReadings(t *testing.T) {
+	current := unitInfo()
+	provider := NewProvider([]string{fakeUnit(t, func() map[string]any { return current }).URL})
+	ref := model.ThermostatRef{ID: provider.order[0], Provider: "venstar"}
+
+	first, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	current["spacetemp"], current["state"], current["schedulepart"] = 71, 0, 2
+	second, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Revision != first.Revision {
+		t.Errorf("Expected readings not to change the revision, got %s and %s", first.Revision, second.Revision)
+	}
+	current["heattemp"] = 72
+	third, err := provider.GetSummary(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if third.Revision == first.Revision {
+		t.Error("Expected a setpoint change to change the revision")
+	}
+}
+
+func TestSnapshotReportsModesAsEvents(t *testing.T) {
+	current := unitInfo()
+	current["away"], current["override"], current["overridetime"] = 1, 1, 30
+	provider := NewProvider([]string{fakeUnit(t, func() map[string]any { return current }).URL})
+	ref := model.ThermostatRef{ID: provider.order[0], Provider: "venstar"}
+
+	snapshot, err := provider.GetSnapshot(context.Background(), ref, time.Time{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if snapshot.Model != "COLORTOUCH" || snapshot.FirmwareVersion != "6.93" {
+		t.Errorf("Expected the model and firmware, got %q and %q", snapshot.Model, snapshot.FirmwareVersion)
+	}
+	if len(snapshot.Events) != 2 || snapshot.Events[0].Name != "away" || snapshot.Events[1].Name != "override" {
+		t.Fatalf("Expected away and override events, got %+v", snapshot.Events)
+	}
+	override := snapshot.Events[1]
+	if !approx(*override.SetHeatC, 21.11) || override.End.Sub(snapshot.CollectedAt) != 30*time.Minute {
+		t.Errorf("Expected a 30 minute override at 21.1°C, got %+v", override)
+	}
+}
+
+func TestReadingConversion(t *testing.T) {
+	var i info
+	encoded, _ := json.Marshal(unitInfo())
+	if err := json.Unmarshal(encoded, &i); err != nil {
+		t.Fatalf("Failed to decode info: %v", err)
+	}
+	ref := model.ThermostatRef{ID: "unit", Provider: "venstar"}
+	temp := func(f float64) *float64 { return &f }
+
+	row, err := i.reading(ref, []sensor{{Name: "Thermostat", Temp: temp(68)}, {Name: "Outdoor", Temp: temp(32)},
+		{Name: "Return Temp", Temp: temp(66.2)}}, time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if row.Mode != "heat" || row.Climate != "Home" || !row.Equipment["compHeat1"] || row.Equipment["compCool1"] || !row.Equipment["fan"] {
+		t.Errorf("Unexpected mode, climate or equipment: %+v", row)
+	}
+	if !approx(*row.AvgTempC, 20) || !approx(*row.OutdoorTempC, 0) || !approx(row.Sensors["return_temp"], 19) {
+		t.Errorf("Expected temperatures in Celsius, got %v, %v and %v", *row.AvgTempC, *row.OutdoorTempC, row.Sensors)
+	}
+
+	i.Away = 1
+	if climate := i.climate(); climate != "Away" {
+		t.Errorf("Expected an away unit to report Away, got %s", climate)
+	}
+}
+
+func TestGetRuntimeReturnsClosedBins(t *testing.T) {
+	provider := NewProvider([]string{fakeUnit(t, unitInfo).URL})
+	ref := model.ThermostatRef{ID: provider.order[0], Provider: "venstar"}
+	binStart := time.Now().UTC().Truncate(sampled.BinSize).Add(-sampled.BinSize)
+	provider.readings.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(time.Minute), Mode: "heat"})
+
+	rows, err := provider.GetRuntime(context.Background(), ref, binStart.Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The reading taken by this request falls in the open bin
+	if len(rows) != 1 || !rows[0].EventTime.Equal(binStart) {
+		t.Fatalf("Expected one row for the closed bin at %v, got %+v", binStart, rows)
+	}
+}
+
+func TestGetRuntimeAveragesSensors(t *testing.T) {
+	provider := NewProvider([]string{fakeUnit(t, unitInfo).URL})
+	ref := model.ThermostatRef{ID: provider.order[0], Provider: "venstar"}
+	binStart := time.Now().UTC().Truncate(sampled.BinSize).Add(-sampled.BinSize)
+	for i, returnTemp := range []float64{18, 20} {
+		provider.readings.Record(model.RuntimeRow{ThermostatRef: ref, EventTime: binStart.Add(time.Duration(i+1) * time.Minute),
+			Mode: "heat", Sensors: map[string]float64{"return_temp": returnTemp}})
+	}
+
+	rows, err := provider.GetRuntime(context.Background(), ref, binStart.Add(-sampled.BinSize), time.Now())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 1 || rows[0].Sensors["return_temp"] != 19 {
+		t.Errorf("Expected the return temperature averaged over the bin, got %+v", rows)
+	}
+}
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 0.01
+}
@@ -0,0 +1,111 @@
+// Package scaffold generates skeleton integrations for contributors to fill
+// in. A generated provider or sink compiles, registers itself with ttr and
+// passes its conformance suite (pkg/providertest or pkg/sinktest) against a
+// fake API from the start, so the work left is replacing the placeholder
+// requests with the real ones.
+package scaffold
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+var templates = template.Must(template.ParseFS(templateFS, "templates/*.tmpl"))
+
+// namePattern is what an integration name may look like: it becomes the Go
+// package name, the build tag and the name used in configuration
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
+// providerFiles maps each template to the path it is written to, relative to
+// the repository root. %s is replaced with the provider name.
+var providerFiles = map[string]string{
+	"auth.go.tmpl":              "internal/providers/%s/auth.go",
+	"provider.go.tmpl":          "internal/providers/%s/provider.go",
+	"provider_test.go.tmpl":     "internal/providers/%s/provider_test.go",
+	"register_provider.go.tmpl": "cmd/ttr/provider_%s.go",
+}
+
+// sinkFiles maps each sink template to the path it is written to
+var sinkFiles = map[string]string{
+	"sink.go.tmpl":          "internal/sinks/%s/sink.go",
+	"settings.go.tmpl":      "internal/sinks/%s/settings.go",
+	"sink_test.go.tmpl":     "internal/sinks/%s/sink_test.go",
+	"register_sink.go.tmpl": "cmd/ttr/sink_%s.go",
+}
+
+// templateData is what the templates render
+type templateData struct {
+	Name   string // package and configuration name, e.g. acme
+	Title  string // name for identifiers and messages, e.g. Acme
+	Module string // module path of the repository
+}
+
+// ValidateName checks that name can serve as an integration's package name,
+// build tag and configuration name
+func ValidateName(name string) error {
+	if !namePattern.MatchString(name) {
+		return fmt.Errorf("name %q must be lowercase letters and digits, starting with a letter", name)
+	}
+	if token.IsKeyword(name) {
+		return fmt.Errorf("name %q is a Go keyword", name)
+	}
+	return nil
+}
+
+// Provider renders a skeleton provider named name for the module at module.
+// It returns the formatted source of each file keyed by its path relative to
+// the repository root.
+func Provider(name, module string) (map[string][]byte, error) {
+	return render(providerFiles, name, module)
+}
+
+// Sink renders a skeleton sink named name for the module at module, keyed
+// like Provider
+func Sink(name, module string) (map[string][]byte, error) {
+	return render(sinkFiles, name, module)
+}
+
+// render executes each template in targets for the named integration
+func render(targets map[string]string, name, module string) (map[string][]byte, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+	if module == "" {
+		return nil, fmt.Errorf("module path is required")
+	}
+
+	data := templateData{Name: name, Title: strings.ToUpper(name[:1]) + name[1:], Module: module}
+	files := make(map[string][]byte, len(targets))
+	for tmpl, target := range targets {
+		var buf bytes.Buffer
+		if err := templates.ExecuteTemplate(&buf, tmpl, data); err != nil {
+			return nil, fmt.Errorf("rendering %s: %w", tmpl, err)
+		}
+		source, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %w", tmpl, err)
+		}
+		files[path.Clean(fmt.Sprintf(target, name))] = source
+	}
+	return files, nil
+}
+
+// ModulePath returns the module path declared in the contents of a go.mod file
+func ModulePath(goMod []byte) (string, error) {
+	for line := range strings.Lines(string(goMod)) {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module directive in go.mod")
+}
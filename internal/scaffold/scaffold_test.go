@@ -0,0 +1,123 @@
+package scaffold
+
+import (
+	"go/parser"
+	"go/token"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name         string
+		render       func(name, module string) (map[string][]byte, error)
+		paths        []string
+		registration string
+	}{
+		{
+			name:   "provider",
+			render: Provider,
+			paths: []string{
+				"cmd/ttr/provider_acme.go",
+				"internal/providers/acme/auth.go",
+				"internal/providers/acme/provider.go",
+				"internal/providers/acme/provider_test.go",
+			},
+			registration: `registerProvider("acme", initializeAcmeProvider)`,
+		},
+		{
+			name:   "sink",
+			render: Sink,
+			paths: []string{
+				"cmd/ttr/sink_acme.go",
+				"internal/sinks/acme/settings.go",
+				"internal/sinks/acme/sink.go",
+				"internal/sinks/acme/sink_test.go",
+			},
+			registration: `registerSink("acme", initializeAcmeSink)`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := tt.render("acme", "example.com/ttr")
+			if err != nil {
+				t.Fatalf("Failed to render %s: %v", tt.name, err)
+			}
+
+			var paths []string
+			for path := range files {
+				paths = append(paths, path)
+			}
+			slices.Sort(paths)
+			if !slices.Equal(paths, tt.paths) {
+				t.Fatalf("Expected files %v, got %v", tt.paths, paths)
+			}
+
+			for path, source := range files {
+				file, err := parser.ParseFile(token.NewFileSet(), path, source, parser.ImportsOnly)
+				if err != nil {
+					t.Errorf("Generated %s does not parse: %v", path, err)
+					continue
+				}
+				expectedPackage := "acme"
+				if strings.HasPrefix(path, "cmd/") {
+					expectedPackage = "main"
+				}
+				if file.Name.Name != expectedPackage {
+					t.Errorf("Expected %s in package %s, got %s", path, expectedPackage, file.Name.Name)
+				}
+				for _, spec := range file.Imports {
+					if strings.Contains(spec.Path.Value, "thermostat-telemetry-reader") {
+						t.Errorf("Expected %s to import from the given module, got %s", path, spec.Path.Value)
+					}
+				}
+			}
+
+			register := string(files[tt.paths[0]])
+			for _, want := range []string{"//go:build acme ||", tt.registration} {
+				if !strings.Contains(register, want) {
+					t.Errorf("Expected registration to contain %q", want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+	}{
+		{"acme", true},
+		{"acme2", true},
+		{"Acme", false},
+		{"2acme", false},
+		{"acme-cloud", false},
+		{"", false},
+		{"func", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateName(tt.name)
+			if (err == nil) != tt.valid {
+				t.Errorf("Expected valid %v for %q, got error %v", tt.valid, tt.name, err)
+			}
+		})
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	module, err := ModulePath([]byte("// comment\nmodule example.com/ttr\n\ngo 1.26\n"))
+	if err != nil {
+		t.Fatalf("Failed to read module path: %v", err)
+	}
+	if module != "example.com/ttr" {
+		t.Errorf("Expected example.com/ttr, got %s", module)
+	}
+	if _, err := ModulePath([]byte("go 1.26\n")); err == nil {
+		t.Error("Expected an error without a module directive")
+	}
+}
@@ -0,0 +1,32 @@
+package schedule
+
+import "time"
+
+// Adaptive polls at its minimum interval while equipment is running and
+// doubles the interval on each idle cycle up to its maximum, so quiet periods
+// use less of the provider's API budget.
+type Adaptive struct {
+	min     time.Duration
+	max     time.Duration
+	current time.Duration
+}
+
+// NewAdaptive creates an adaptive strategy bounded by minInterval and maxInterval
+func NewAdaptive(minInterval, maxInterval time.Duration) *Adaptive {
+	return &Adaptive{min: minInterval, max: maxInterval, current: minInterval}
+}
+
+// Name identifies the strategy in logs
+func (a *Adaptive) Name() string {
+	return "adaptive"
+}
+
+// Next resets to the minimum interval when equipment is active and backs off otherwise
+func (a *Adaptive) Next(now time.Time, active bool) time.Time {
+	if active {
+		a.current = a.min
+	} else {
+		a.current = min(a.current*2, a.max)
+	}
+	return now.Add(a.current)
+}
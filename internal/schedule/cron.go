@@ -0,0 +1,177 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxCronSearch bounds how far ahead Next looks for a matching minute.
+// Expressions such as "0 0 30 2 *" never match.
+const maxCronSearch = 5 * 366 * 24 * time.Hour
+
+// cronField describes the allowed range of one cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12},
+	{name: "day of week", min: 0, max: 6},
+}
+
+// Cron polls at the minutes matched by a standard five-field cron expression
+// (minute hour day-of-month month day-of-week). Fields accept *, lists,
+// ranges and steps, e.g. "*/5 6-22 * * 1-5".
+type Cron struct {
+	expression string
+	location   *time.Location
+	minute     []bool
+	hour       []bool
+	dom        []bool
+	month      []bool
+	dow        []bool
+	domAny     bool
+	dowAny     bool
+}
+
+// NewCron parses a cron expression evaluated in the given location (UTC if nil)
+func NewCron(expression string, location *time.Location) (*Cron, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron expression %q must have %d fields", expression, len(cronFields))
+	}
+	if location == nil {
+		location = time.UTC
+	}
+
+	sets := make([][]bool, len(fields))
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron expression %q: %w", expression, err)
+		}
+		sets[i] = set
+	}
+
+	return &Cron{
+		expression: expression,
+		location:   location,
+		minute:     sets[0],
+		hour:       sets[1],
+		dom:        sets[2],
+		month:      sets[3],
+		dow:        sets[4],
+		domAny:     fields[2] == "*",
+		dowAny:     fields[4] == "*",
+	}, nil
+}
+
+// Name identifies the strategy in logs
+func (c *Cron) Name() string {
+	return "cron"
+}
+
+// Next returns the first matching minute after now. If the expression never
+// matches, it falls back to one day later so the scheduler keeps running.
+func (c *Cron) Next(now time.Time, _ bool) time.Time {
+	t := now.In(c.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxCronSearch)
+
+	for t.Before(limit) {
+		if !c.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, c.location)
+			continue
+		}
+		if !c.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return now.Add(24 * time.Hour)
+}
+
+// dayMatches applies cron's day rule: when both day fields are restricted, a
+// day matches if either does
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom[t.Day()]
+	dowMatch := c.dow[int(t.Weekday())]
+	switch {
+	case c.domAny && c.dowAny:
+		return true
+	case c.domAny:
+		return dowMatch
+	case c.dowAny:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// parseCronField parses one comma-separated cron field into a lookup table
+// indexed by value
+func parseCronField(field string, spec cronField) ([]bool, error) {
+	set := make([]bool, spec.max+1)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, ok := strings.Cut(part, "/"); ok {
+			parsed, err := strconv.Atoi(after)
+			if err != nil || parsed < 1 {
+				return nil, fmt.Errorf("invalid step %q in %s field", after, spec.name)
+			}
+			rangePart, step = before, parsed
+		}
+
+		low, high := spec.min, spec.max
+		if rangePart != "*" {
+			var err error
+			if before, after, ok := strings.Cut(rangePart, "-"); ok {
+				if low, err = parseCronValue(before, spec); err != nil {
+					return nil, err
+				}
+				if high, err = parseCronValue(after, spec); err != nil {
+					return nil, err
+				}
+				if low > high {
+					return nil, fmt.Errorf("invalid range %q in %s field", rangePart, spec.name)
+				}
+			} else {
+				if low, err = parseCronValue(rangePart, spec); err != nil {
+					return nil, err
+				}
+				if step == 1 {
+					high = low
+				}
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// parseCronValue parses a single number and checks it against the field range
+func parseCronValue(value string, spec cronField) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < spec.min || n > spec.max {
+		return 0, fmt.Errorf("invalid value %q in %s field (allowed %d-%d)", value, spec.name, spec.min, spec.max)
+	}
+	return n, nil
+}
@@ -0,0 +1,34 @@
+// Package schedule provides polling strategies for the scheduler: fixed
+// intervals, cron expressions, and an adaptive interval that follows
+// equipment activity.
+package schedule
+
+import "time"
+
+// Fixed polls on a constant interval anchored at the first cycle, like a
+// time.Ticker. Cycles that overrun are skipped rather than queued.
+type Fixed struct {
+	interval time.Duration
+	next     time.Time
+}
+
+// NewFixed creates a fixed-interval strategy
+func NewFixed(interval time.Duration) *Fixed {
+	return &Fixed{interval: interval}
+}
+
+// Name identifies the strategy in logs
+func (f *Fixed) Name() string {
+	return "fixed"
+}
+
+// Next returns the first interval boundary after now
+func (f *Fixed) Next(now time.Time, _ bool) time.Time {
+	if f.next.IsZero() {
+		f.next = now
+	}
+	for !f.next.After(now) {
+		f.next = f.next.Add(f.interval)
+	}
+	return f.next
+}
@@ -0,0 +1,129 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixed(t *testing.T) {
+	start := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	strategy := NewFixed(5 * time.Minute)
+
+	if next := strategy.Next(start, false); !next.Equal(start.Add(5 * time.Minute)) {
+		t.Errorf("Expected first cycle at 12:05, got %v", next)
+	}
+
+	// A cycle that overruns skips the missed boundary instead of firing immediately
+	if next := strategy.Next(start.Add(11*time.Minute), true); !next.Equal(start.Add(15 * time.Minute)) {
+		t.Errorf("Expected next boundary at 12:15, got %v", next)
+	}
+}
+
+func TestAdaptive(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	strategy := NewAdaptive(time.Minute, 10*time.Minute)
+
+	steps := []struct {
+		active   bool
+		expected time.Duration
+	}{
+		{active: false, expected: 2 * time.Minute},
+		{active: false, expected: 4 * time.Minute},
+		{active: false, expected: 8 * time.Minute},
+		{active: false, expected: 10 * time.Minute},
+		{active: false, expected: 10 * time.Minute},
+		{active: true, expected: time.Minute},
+		{active: false, expected: 2 * time.Minute},
+	}
+
+	for i, step := range steps {
+		if got := strategy.Next(now, step.active).Sub(now); got != step.expected {
+			t.Errorf("Step %d: expected %v, got %v", i, step.expected, got)
+		}
+	}
+}
+
+func TestCron(t *testing.T) {
+	chicago, err := time.LoadLocation("America/Chicago")
+	if err != nil {
+		t.Fatalf("Failed to load location: %v", err)
+	}
+
+	// 2025-01-10 is a Friday
+	tests := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		now        time.Time
+		expected   time.Time
+	}{
+		{
+			name:       "every five minutes",
+			expression: "*/5 * * * *",
+			now:        time.Date(2025, 1, 10, 12, 3, 20, 0, time.UTC),
+			expected:   time.Date(2025, 1, 10, 12, 5, 0, 0, time.UTC),
+		},
+		{
+			name:       "exact match moves to the next slot",
+			expression: "*/5 * * * *",
+			now:        time.Date(2025, 1, 10, 12, 5, 0, 0, time.UTC),
+			expected:   time.Date(2025, 1, 10, 12, 10, 0, 0, time.UTC),
+		},
+		{
+			name:       "hour range rolls to the next day",
+			expression: "0 6-22 * * *",
+			now:        time.Date(2025, 1, 10, 22, 30, 0, 0, time.UTC),
+			expected:   time.Date(2025, 1, 11, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "weekdays only",
+			expression: "30 8 * * 1-5",
+			now:        time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC),
+			expected:   time.Date(2025, 1, 13, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:       "list of minutes",
+			expression: "15,45 * * * *",
+			now:        time.Date(2025, 1, 10, 12, 20, 0, 0, time.UTC),
+			expected:   time.Date(2025, 1, 10, 12, 45, 0, 0, time.UTC),
+		},
+		{
+			name:       "evaluated in location",
+			expression: "0 6 * * *",
+			location:   chicago,
+			now:        time.Date(2025, 1, 10, 13, 0, 0, 0, time.UTC),
+			expected:   time.Date(2025, 1, 11, 12, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewCron(tt.expression, tt.location)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if next := strategy.Next(tt.now, false); !next.Equal(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestNewCronErrors(t *testing.T) {
+	tests := []string{
+		"* * * *",
+		"60 * * * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * 13 *",
+	}
+
+	for _, expression := range tests {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := NewCron(expression, nil); err == nil {
+				t.Errorf("Expected error for %q", expression)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package csv
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// eventTimeColumn is always the first column; it orders rows and lets the
+// sink skip bins a file already holds
+const eventTimeColumn = "event_time"
+
+// DefaultColumns are written when no columns are configured
+var DefaultColumns = []string{
+	"event_time",
+	"thermostat_id",
+	"thermostat_name",
+	"mode",
+	"climate",
+	"set_heat_c",
+	"set_cool_c",
+	"avg_temp_c",
+	"outdoor_temp_c",
+	"outdoor_humidity_pct",
+	"equip.compHeat1",
+	"equip.compCool1",
+	"equip.auxHeat1",
+	"equip.fan",
+}
+
+// Sink appends runtime_5m rows to one CSV file per thermostat per UTC day.
+// Other document types are accepted and ignored.
+type Sink struct {
+	mu      sync.Mutex
+	dir     string
+	columns []string
+	// latest is the newest event time written to each file
+	latest map[string]time.Time
+}
+
+// NewSink creates a CSV sink writing below dir. Columns are runtime_5m JSON
+// field names, with dots for nested fields (e.g. "equip.fan").
+func NewSink(dir string, columns []string) *Sink {
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	selected := []string{eventTimeColumn}
+	for _, col := range columns {
+		if col != eventTimeColumn {
+			selected = append(selected, col)
+		}
+	}
+	return &Sink{
+		dir:     dir,
+		columns: selected,
+		latest:  make(map[string]time.Time),
+	}
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "csv",
+		Version:     "1.0.0",
+		Description: "Daily per-thermostat CSV files of runtime_5m rows",
+	}
+}
+
+// Open creates the output directory
+func (s *Sink) Open(ctx context.Context) error {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return fmt.Errorf("creating csv directory: %w", err)
+	}
+	return nil
+}
+
+// csvRow is a runtime row waiting to be appended
+type csvRow struct {
+	eventTime time.Time
+	values    []string
+}
+
+// Write appends runtime_5m documents to their thermostat's daily file. Rows
+// not newer than the file's latest row were already written and are skipped.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+	files := make(map[string][]csvRow)
+
+	for _, doc := range docs {
+		if doc.Type != "runtime_5m" {
+			result.SuccessCount++
+			continue
+		}
+		fields, err := decodeFields(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", doc.ID, err))
+			continue
+		}
+		eventTime, err := time.Parse(time.RFC3339Nano, fmt.Sprint(fields[eventTimeColumn]))
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: parsing event_time: %v", doc.ID, err))
+			continue
+		}
+
+		path := s.fileFor(fmt.Sprint(fields["thermostat_id"]), eventTime)
+		files[path] = append(files[path], csvRow{eventTime: eventTime, values: s.rowValues(fields)})
+		result.SuccessCount++
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for path, rows := range files {
+		if err := s.appendRows(path, rows); err != nil {
+			return model.WriteResult{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// Close is a no-op; files are closed after every append
+func (s *Sink) Close(ctx context.Context) error {
+	return nil
+}
+
+// fileFor returns the daily file for a thermostat
+func (s *Sink) fileFor(thermostatID string, eventTime time.Time) string {
+	return filepath.Join(s.dir, sanitize(thermostatID), eventTime.UTC().Format("2006-01-02")+".csv")
+}
+
+// sanitize keeps a thermostat ID safe to use as a directory name
+func sanitize(id string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, id)
+	if safe == "" {
+		return "unknown"
+	}
+	return safe
+}
+
+// appendRows writes new rows to a file in one append, adding the header when
+// the file is new. Callers must hold the lock.
+func (s *Sink) appendRows(path string, rows []csvRow) error {
+	latest, err := s.latestEventTime(path)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return rows[i].eventTime.Before(rows[j].eventTime) })
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if latest.IsZero() {
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			_ = writer.Write(s.columns)
+		}
+	}
+	for _, row := range rows {
+		if !row.eventTime.After(latest) {
+			continue
+		}
+		_ = writer.Write(row.values)
+		latest = row.eventTime
+	}
+	writer.Flush()
+	if buf.Len() == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("creating csv directory: %w", err)
+	}
+	// #nosec G304 - path is built from the configured directory and a sanitized thermostat ID
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return fmt.Errorf("appending to %s: %w", path, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing %s: %w", path, err)
+	}
+
+	s.latest[path] = latest
+	return nil
+}
+
+// latestEventTime returns the newest event time in a file, reading the file
+// the first time it is seen in this process
+func (s *Sink) latestEventTime(path string) (time.Time, error) {
+	if latest, ok := s.latest[path]; ok {
+		return latest, nil
+	}
+
+	// #nosec G304 - path is built from the configured directory and a sanitized thermostat ID
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.FieldsPerRecord = -1
+	var latest time.Time
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return time.Time{}, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if first || len(record) == 0 {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339Nano, record[0]); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+
+	s.latest[path] = latest
+	return latest, nil
+}
+
+// decodeFields round-trips a document body through JSON so canonical structs
+// and transformed maps are read the same way
+func decodeFields(body any) (map[string]any, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling document: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("decoding document fields: %w", err)
+	}
+	return fields, nil
+}
+
+// rowValues formats the selected columns of a document
+func (s *Sink) rowValues(fields map[string]any) []string {
+	values := make([]string, 0, len(s.columns))
+	for _, col := range s.columns {
+		values = append(values, formatValue(lookup(fields, col)))
+	}
+	return values
+}
+
+// lookup follows a dotted path through nested JSON objects
+func lookup(fields map[string]any, path string) any {
+	var current any = fields
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+	return current
+}
+
+// formatValue renders a decoded JSON value as a CSV cell
+func formatValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return string(raw)
+	}
+}
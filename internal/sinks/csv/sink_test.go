@@ -0,0 +1,168 @@
+package csv
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func runtimeDoc(thermostatID string, at time.Time, avgTemp float64) model.Doc {
+	return model.Doc{
+		ID:   thermostatID + at.Format(time.RFC3339),
+		Type: "runtime_5m",
+		Body: &model.Runtime5m{
+			Type:         "runtime_5m",
+			ThermostatID: thermostatID,
+			EventTime:    at,
+			Mode:         "heat",
+			AvgTempC:     floatPtr(avgTemp),
+			Equipment:    map[string]bool{"fan": true},
+		},
+	}
+}
+
+func readLines(t *testing.T, path string) []string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func TestSinkAppendsDailyFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink := NewSink(dir, []string{"thermostat_id", "avg_temp_c", "equip.fan"})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+
+	start := time.Date(2025, 1, 10, 23, 50, 0, 0, time.UTC)
+	docs := []model.Doc{
+		runtimeDoc("t1", start.Add(5*time.Minute), 21.2),
+		runtimeDoc("t1", start, 21.0),
+		runtimeDoc("t1", start.Add(10*time.Minute), 21.4),
+		{ID: "s1", Type: "device_snapshot", Body: &model.DeviceSnapshot{Type: "device_snapshot"}},
+	}
+	result, err := sink.Write(ctx, docs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SuccessCount != 4 || result.ErrorCount != 0 {
+		t.Errorf("Unexpected write result: %+v", result)
+	}
+
+	day := readLines(t, filepath.Join(dir, "t1", "2025-01-10.csv"))
+	expected := []string{
+		"event_time,thermostat_id,avg_temp_c,equip.fan",
+		"2025-01-10T23:50:00Z,t1,21,true",
+		"2025-01-10T23:55:00Z,t1,21.2,true",
+	}
+	if strings.Join(day, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected file contents:\n%s", strings.Join(day, "\n"))
+	}
+	if next := readLines(t, filepath.Join(dir, "t1", "2025-01-11.csv")); len(next) != 2 {
+		t.Errorf("Expected header and one row in the next day's file, got %d lines", len(next))
+	}
+
+	t.Run("rewritten bins are skipped", func(t *testing.T) {
+		if _, err := sink.Write(ctx, docs[:2]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if lines := readLines(t, filepath.Join(dir, "t1", "2025-01-10.csv")); len(lines) != 3 {
+			t.Errorf("Expected no duplicate rows, got %d lines", len(lines))
+		}
+	})
+
+	t.Run("a new sink resumes after the last row", func(t *testing.T) {
+		restarted := NewSink(dir, []string{"thermostat_id", "avg_temp_c", "equip.fan"})
+		if _, err := restarted.Write(ctx, docs[:2]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if lines := readLines(t, filepath.Join(dir, "t1", "2025-01-10.csv")); len(lines) != 3 {
+			t.Errorf("Expected no duplicate rows after restart, got %d lines", len(lines))
+		}
+	})
+}
+
+func TestSinkConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink := NewSink(dir, nil)
+
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			doc := runtimeDoc("t1", start.Add(time.Duration(i)*5*time.Minute), 20.0)
+			if _, err := sink.Write(ctx, []model.Doc{doc}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := readLines(t, filepath.Join(dir, "t1", "2025-01-10.csv"))
+	if lines[0] != strings.Join(DefaultColumns, ",") {
+		t.Errorf("Expected a single header row first, got %q", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if strings.HasPrefix(line, "event_time") {
+			t.Error("Expected the header to be written once")
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := map[string]string{
+		"123456789012": "123456789012",
+		"../etc":       "___etc",
+		"":             "unknown",
+	}
+	for input, expected := range tests {
+		if got := sanitize(input); got != expected {
+			t.Errorf("sanitize(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			return NewSink(t.TempDir(), nil)
+		},
+		Stored: func(t *testing.T, sink model.Sink) int {
+			files, err := filepath.Glob(filepath.Join(sink.(*Sink).dir, "*", "*.csv"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rows := 0
+			for _, file := range files {
+				rows += len(readLines(t, file)) - 1
+			}
+			return rows
+		},
+		Reject: sinktest.Unencodable,
+	})
+}
@@ -0,0 +1,287 @@
+package duckdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// column maps a canonical document field to a typed table column. Path is the
+// field's JSON name, with dots for nested objects (e.g. "prev.mode").
+type column struct {
+	name    string
+	sqlType string
+	path    string
+}
+
+// table describes the typed table a document type is stored in. Every table
+// also has an id primary key and a doc column holding the full JSON document.
+type table struct {
+	name    string
+	columns []column
+}
+
+// fallbackTable stores document types without a typed table
+const fallbackTable = "documents"
+
+// tables holds the typed tables keyed by document type
+var tables = map[string]table{
+	"runtime_5m": {name: "runtime_5m", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"mode", "VARCHAR", "mode"},
+		{"climate", "VARCHAR", "climate"},
+		{"set_heat_c", "DOUBLE", "set_heat_c"},
+		{"set_cool_c", "DOUBLE", "set_cool_c"},
+		{"avg_temp_c", "DOUBLE", "avg_temp_c"},
+		{"outdoor_temp_c", "DOUBLE", "outdoor_temp_c"},
+		{"outdoor_humidity_pct", "INTEGER", "outdoor_humidity_pct"},
+		{"equip", "JSON", "equip"},
+		{"equip_seconds", "JSON", "equip_seconds"},
+		{"sensors", "JSON", "sensors"},
+		{"location", "JSON", "location"},
+		{"filled", "BOOLEAN", "filled"},
+		{"tags", "JSON", "tags"},
+	}},
+	"runtime_live": {name: "runtime_live", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"mode", "VARCHAR", "mode"},
+		{"set_heat_c", "DOUBLE", "set_heat_c"},
+		{"set_cool_c", "DOUBLE", "set_cool_c"},
+		{"temp_c", "DOUBLE", "temp_c"},
+		{"humidity_pct", "INTEGER", "humidity_pct"},
+		{"equip", "JSON", "equip"},
+		{"tags", "JSON", "tags"},
+	}},
+	"transition": {name: "transition", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"prev_mode", "VARCHAR", "prev.mode"},
+		{"prev_climate", "VARCHAR", "prev.climate"},
+		{"prev_set_heat_c", "DOUBLE", "prev.set_heat_c"},
+		{"prev_set_cool_c", "DOUBLE", "prev.set_cool_c"},
+		{"next_mode", "VARCHAR", "next.mode"},
+		{"next_climate", "VARCHAR", "next.climate"},
+		{"next_set_heat_c", "DOUBLE", "next.set_heat_c"},
+		{"next_set_cool_c", "DOUBLE", "next.set_cool_c"},
+		{"event_kind", "VARCHAR", "event.kind"},
+		{"event_name", "VARCHAR", "event.name"},
+		{"tags", "JSON", "tags"},
+	}},
+	"device_snapshot": {name: "device_snapshot", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"collected_at", "TIMESTAMPTZ", "collected_at"},
+		{"revision", "VARCHAR", "revision"},
+		{"model", "VARCHAR", "model"},
+		{"firmware_version", "VARCHAR", "firmware_version"},
+		{"events", "JSON", "events"},
+		{"tags", "JSON", "tags"},
+	}},
+	"device_metadata": {name: "device_metadata", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"collected_at", "TIMESTAMPTZ", "collected_at"},
+		{"location", "JSON", "location"},
+		{"tags", "JSON", "tags"},
+	}},
+	"analysis": {name: "analysis", columns: []column{
+		{"analyzer", "VARCHAR", "analyzer"},
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"period_start", "TIMESTAMPTZ", "period_start"},
+		{"period_end", "TIMESTAMPTZ", "period_end"},
+		{"results", "JSON", "results"},
+		{"tags", "JSON", "tags"},
+	}},
+	"alert": {name: "alert", columns: []column{
+		{"kind", "VARCHAR", "kind"},
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"sensor_id", "VARCHAR", "sensor_id"},
+		{"value_c", "DOUBLE", "value_c"},
+		{"message", "VARCHAR", "message"},
+		{"details", "JSON", "details"},
+		{"tags", "JSON", "tags"},
+	}},
+	"recommendation": {name: "recommendation", columns: []column{
+		{"kind", "VARCHAR", "kind"},
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"period_start", "TIMESTAMPTZ", "period_start"},
+		{"period_end", "TIMESTAMPTZ", "period_end"},
+		{"climate", "VARCHAR", "climate"},
+		{"current_setpoint_c", "DOUBLE", "current_setpoint_c"},
+		{"suggested_setpoint_c", "DOUBLE", "suggested_setpoint_c"},
+		{"estimated_kwh_saved", "DOUBLE", "estimated_kwh_saved"},
+		{"message", "VARCHAR", "message"},
+		{"details", "JSON", "details"},
+		{"tags", "JSON", "tags"},
+	}},
+	"firmware_change": {name: "firmware_change", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"model", "VARCHAR", "model"},
+		{"prev_version", "VARCHAR", "prev_version"},
+		{"next_version", "VARCHAR", "next_version"},
+		{"tags", "JSON", "tags"},
+	}},
+	"connectivity": {name: "connectivity", columns: []column{
+		{"thermostat_id", "VARCHAR", "thermostat_id"},
+		{"thermostat_name", "VARCHAR", "thermostat_name"},
+		{"household_id", "VARCHAR", "household_id"},
+		{"event_time", "TIMESTAMPTZ", "event_time"},
+		{"connected", "BOOLEAN", "connected"},
+		{"tags", "JSON", "tags"},
+	}},
+	fallbackTable: {name: fallbackTable, columns: []column{
+		{"type", "VARCHAR", "type"},
+	}},
+}
+
+// tableFor returns the table a document type is stored in
+func tableFor(docType string) table {
+	if t, ok := tables[docType]; ok {
+		return t
+	}
+	return tables[fallbackTable]
+}
+
+// createStatement returns the CREATE TABLE statement for a table
+func (t table) createStatement() string {
+	defs := []string{"id VARCHAR PRIMARY KEY"}
+	for _, col := range t.columns {
+		defs = append(defs, col.name+" "+col.sqlType)
+	}
+	defs = append(defs, "doc JSON")
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", t.name, strings.Join(defs, ", "))
+}
+
+// addColumnStatements returns statements adding columns missing from a table
+// created by an earlier version
+func (t table) addColumnStatements() []string {
+	statements := make([]string, 0, len(t.columns))
+	for _, col := range t.columns {
+		statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", t.name, col.name, col.sqlType))
+	}
+	return statements
+}
+
+// insertStatement returns an upsert for a table. Document IDs are
+// deterministic, so rewriting a document replaces the earlier row.
+func (t table) insertStatement() string {
+	names := []string{"id"}
+	for _, col := range t.columns {
+		names = append(names, col.name)
+	}
+	names = append(names, "doc")
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	return fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (%s)", t.name, strings.Join(names, ", "), placeholders)
+}
+
+// values extracts the row for a document from its JSON form. Bodies are
+// round-tripped through JSON so transformed documents (maps) and canonical
+// structs are handled the same way.
+func (t table) values(id string, docType string, body any) ([]any, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling document: %w", err)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("decoding document fields: %w", err)
+	}
+	if _, ok := fields["type"]; !ok {
+		fields["type"] = docType
+	}
+
+	values := []any{id}
+	for _, col := range t.columns {
+		value, err := columnValue(col, lookup(fields, col.path))
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", col.name, err)
+		}
+		values = append(values, value)
+	}
+	return append(values, string(raw)), nil
+}
+
+// lookup follows a dotted path through nested JSON objects
+func lookup(fields map[string]any, path string) any {
+	var current any = fields
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]any)
+		if !ok {
+			return nil
+		}
+		current = object[key]
+	}
+	return current
+}
+
+// columnValue converts a decoded JSON value to the column's SQL type
+func columnValue(col column, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch col.sqlType {
+	case "TIMESTAMPTZ":
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a timestamp string, got %T", value)
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("parsing timestamp: %w", err)
+		}
+		if t.IsZero() {
+			return nil, nil
+		}
+		return t, nil
+	case "DOUBLE":
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %T", value)
+		}
+		return f, nil
+	case "INTEGER":
+		f, ok := value.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected a number, got %T", value)
+		}
+		return int64(f), nil
+	case "BOOLEAN":
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %T", value)
+		}
+		return b, nil
+	case "JSON":
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling JSON column: %w", err)
+		}
+		return string(raw), nil
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return fmt.Sprint(value), nil
+		}
+		return s, nil
+	}
+}
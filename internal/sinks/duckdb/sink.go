@@ -0,0 +1,231 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/duckdb/duckdb-go/v2" // DuckDB driver
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// File rotation periods
+const (
+	RotateNone    = "none"
+	RotateDaily   = "daily"
+	RotateMonthly = "monthly"
+)
+
+// Options configures checkpointing and file rotation
+type Options struct {
+	// CheckpointInterval is how often the write-ahead log is folded into the
+	// database file; zero checkpoints only on rotation and close
+	CheckpointInterval time.Duration
+	// Rotation starts a new database file each day or month (UTC), named
+	// after the configured path with the period appended
+	Rotation string
+}
+
+// Sink implements a DuckDB data sink with one typed table per document type
+type Sink struct {
+	mu             sync.Mutex
+	path           string
+	options        Options
+	db             *sql.DB
+	currentFile    string
+	lastCheckpoint time.Time
+	now            func() time.Time
+}
+
+// NewSink creates a new DuckDB sink writing to the database file at path
+func NewSink(path string, options Options) *Sink {
+	if options.Rotation == "" {
+		options.Rotation = RotateNone
+	}
+	return &Sink{
+		path:    path,
+		options: options,
+		now:     time.Now,
+	}
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "duckdb",
+		Version:     "1.0.0",
+		Description: "DuckDB sink with typed tables for local SQL analytics",
+	}
+}
+
+// Open opens the current database file and creates its tables
+func (s *Sink) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ensureFile(ctx, s.now())
+}
+
+// Write upserts documents into their typed tables in one transaction
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	if len(docs) == 0 {
+		return model.WriteResult{SuccessCount: 0, ErrorCount: 0}, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if err := s.ensureFile(ctx, now); err != nil {
+		return model.WriteResult{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return model.WriteResult{}, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	result := model.WriteResult{Errors: []string{}}
+	statements := make(map[string]*sql.Stmt)
+	for _, doc := range docs {
+		t := tableFor(doc.Type)
+		values, err := t.values(doc.ID, doc.Type, doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", doc.ID, err))
+			continue
+		}
+
+		stmt, ok := statements[t.name]
+		if !ok {
+			stmt, err = tx.PrepareContext(ctx, t.insertStatement())
+			if err != nil {
+				return model.WriteResult{}, fmt.Errorf("preparing insert for %s: %w", t.name, err)
+			}
+			defer func() {
+				_ = stmt.Close()
+			}()
+			statements[t.name] = stmt
+		}
+
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
+			return model.WriteResult{}, fmt.Errorf("inserting document %s into %s: %w", doc.ID, t.name, err)
+		}
+		result.SuccessCount++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return model.WriteResult{}, fmt.Errorf("committing transaction: %w", err)
+	}
+
+	if s.options.CheckpointInterval > 0 && now.Sub(s.lastCheckpoint) >= s.options.CheckpointInterval {
+		if err := s.checkpoint(ctx, now); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
+
+// Close checkpoints and closes the database
+func (s *Sink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFile(ctx)
+}
+
+// OpenConnections returns the number of database connections the sink holds
+// open
+func (s *Sink) OpenConnections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db == nil {
+		return 0
+	}
+	return s.db.Stats().OpenConnections
+}
+
+// fileFor returns the database file for a write at the given time
+func (s *Sink) fileFor(now time.Time) string {
+	var period string
+	switch s.options.Rotation {
+	case RotateDaily:
+		period = now.UTC().Format("2006-01-02")
+	case RotateMonthly:
+		period = now.UTC().Format("2006-01")
+	default:
+		return s.path
+	}
+	ext := filepath.Ext(s.path)
+	return strings.TrimSuffix(s.path, ext) + "-" + period + ext
+}
+
+// ensureFile opens the database file for now, closing the previous file when
+// the rotation period has changed
+func (s *Sink) ensureFile(ctx context.Context, now time.Time) error {
+	file := s.fileFor(now)
+	if s.db != nil && file == s.currentFile {
+		return nil
+	}
+	if err := s.closeFile(ctx); err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(file); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("creating database directory: %w", err)
+		}
+	}
+	db, err := sql.Open("duckdb", file)
+	if err != nil {
+		return fmt.Errorf("opening duckdb database: %w", err)
+	}
+	for _, t := range tables {
+		if _, err := db.ExecContext(ctx, t.createStatement()); err != nil {
+			_ = db.Close()
+			return fmt.Errorf("creating table %s: %w", t.name, err)
+		}
+		for _, statement := range t.addColumnStatements() {
+			if _, err := db.ExecContext(ctx, statement); err != nil {
+				_ = db.Close()
+				return fmt.Errorf("migrating table %s: %w", t.name, err)
+			}
+		}
+	}
+
+	s.db = db
+	s.currentFile = file
+	s.lastCheckpoint = now
+	return nil
+}
+
+// checkpoint folds the write-ahead log into the database file
+func (s *Sink) checkpoint(ctx context.Context, now time.Time) error {
+	if _, err := s.db.ExecContext(ctx, "CHECKPOINT"); err != nil {
+		return fmt.Errorf("checkpointing %s: %w", s.currentFile, err)
+	}
+	s.lastCheckpoint = now
+	return nil
+}
+
+// closeFile checkpoints and closes the open database file, if any
+func (s *Sink) closeFile(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	err := s.checkpoint(ctx, s.now())
+	if closeErr := s.db.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("closing %s: %w", s.currentFile, closeErr)
+	}
+	s.db = nil
+	s.currentFile = ""
+	return err
+}
@@ -0,0 +1,233 @@
+package duckdb
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+func TestSinkWritesTypedTables(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telemetry.duckdb")
+	sink := NewSink(path, Options{})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+
+	eventTime := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	docs := []model.Doc{
+		{ID: "r1", Type: "runtime_5m", Body: &model.Runtime5m{
+			Type:         "runtime_5m",
+			ThermostatID: "t1",
+			EventTime:    eventTime,
+			Mode:         "heat",
+			AvgTempC:     floatPtr(21.5),
+			Equipment:    map[string]bool{"compHeat1": true},
+		}},
+		{ID: "tr1", Type: "transition", Body: &model.Transition{
+			Type:         "transition",
+			ThermostatID: "t1",
+			EventTime:    eventTime,
+			Prev:         model.State{Mode: "heat", SetHeatC: floatPtr(20.0)},
+			Next:         model.State{Mode: "heat", SetHeatC: floatPtr(21.0)},
+			Event:        model.EventInfo{Kind: "manual"},
+		}},
+		{ID: "x1", Type: "custom", Body: map[string]any{"value": 1}},
+	}
+
+	result, err := sink.Write(ctx, docs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SuccessCount != 3 || result.ErrorCount != 0 {
+		t.Errorf("Unexpected write result: %+v", result)
+	}
+
+	t.Run("rewrites replace rows", func(t *testing.T) {
+		if _, err := sink.Write(ctx, docs[:1]); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	})
+
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+
+	var count int
+	var avgTemp float64
+	var at time.Time
+	if err := db.QueryRow("SELECT count(*), max(avg_temp_c), max(event_time) FROM runtime_5m").Scan(&count, &avgTemp, &at); err != nil {
+		t.Fatalf("Failed to query runtime_5m: %v", err)
+	}
+	if count != 1 || avgTemp != 21.5 || !at.Equal(eventTime) {
+		t.Errorf("Unexpected runtime_5m row: count=%d avg=%v at=%v", count, avgTemp, at)
+	}
+
+	var nextHeat float64
+	var kind string
+	if err := db.QueryRow("SELECT next_set_heat_c, event_kind FROM transition WHERE id = 'tr1'").Scan(&nextHeat, &kind); err != nil {
+		t.Fatalf("Failed to query transition: %v", err)
+	}
+	if nextHeat != 21.0 || kind != "manual" {
+		t.Errorf("Unexpected transition row: next_set_heat_c=%v event_kind=%s", nextHeat, kind)
+	}
+
+	var docType string
+	if err := db.QueryRow("SELECT type FROM documents WHERE id = 'x1'").Scan(&docType); err != nil {
+		t.Fatalf("Failed to query documents: %v", err)
+	}
+	if docType != "custom" {
+		t.Errorf("Expected fallback type custom, got %s", docType)
+	}
+}
+
+func TestSinkRotation(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	sink := NewSink(filepath.Join(dir, "telemetry.duckdb"), Options{Rotation: RotateDaily, CheckpointInterval: time.Minute})
+
+	now := time.Date(2025, 1, 10, 23, 59, 0, 0, time.UTC)
+	sink.now = func() time.Time { return now }
+
+	doc := model.Doc{ID: "r1", Type: "runtime_5m", Body: &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1", EventTime: now}}
+	if _, err := sink.Write(ctx, []model.Doc{doc}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if _, err := sink.Write(ctx, []model.Doc{doc}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	for _, name := range []string{"telemetry-2025-01-10.duckdb", "telemetry-2025-01-11.duckdb"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected rotated file %s: %v", name, err)
+		}
+	}
+}
+
+func TestColumnValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		col       column
+		value     any
+		expected  any
+		expectErr bool
+	}{
+		{name: "missing value is null", col: column{sqlType: "DOUBLE"}, value: nil, expected: nil},
+		{name: "integer from JSON number", col: column{sqlType: "INTEGER"}, value: 45.0, expected: int64(45)},
+		{name: "json column", col: column{sqlType: "JSON"}, value: map[string]any{"fan": true}, expected: `{"fan":true}`},
+		{name: "zero time is null", col: column{sqlType: "TIMESTAMPTZ"}, value: "0001-01-01T00:00:00Z", expected: nil},
+		{name: "boolean column", col: column{sqlType: "BOOLEAN"}, value: false, expected: false},
+		{name: "wrong type for double", col: column{sqlType: "DOUBLE"}, value: "warm", expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := columnValue(tt.col, tt.value)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestSinkAddsNewColumns(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "telemetry.duckdb")
+
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	if _, err := db.Exec("CREATE TABLE runtime_5m (id VARCHAR PRIMARY KEY, thermostat_id VARCHAR, doc JSON)"); err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("Failed to close database: %v", err)
+	}
+
+	sink := NewSink(path, Options{})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	doc := model.Doc{ID: "r1", Type: "runtime_5m", Body: &model.Runtime5m{
+		Type:          "runtime_5m",
+		ThermostatID:  "t1",
+		EventTime:     time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC),
+		EquipmentSecs: map[string]int{"fan": 120},
+	}}
+	if _, err := sink.Write(ctx, []model.Doc{doc}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	db, err = sql.Open("duckdb", path)
+	if err != nil {
+		t.Fatalf("Failed to reopen database: %v", err)
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	var fanSeconds int
+	if err := db.QueryRow("SELECT CAST(equip_seconds->>'fan' AS INTEGER) FROM runtime_5m WHERE id = 'r1'").Scan(&fanSeconds); err != nil {
+		t.Fatalf("Failed to query migrated column: %v", err)
+	}
+	if fanSeconds != 120 {
+		t.Errorf("Expected 120 fan seconds, got %d", fanSeconds)
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			return NewSink(filepath.Join(t.TempDir(), "telemetry.duckdb"), Options{})
+		},
+		Stored: func(t *testing.T, sink model.Sink) int {
+			var count int
+			if err := sink.(*Sink).db.QueryRow("SELECT count(*) FROM runtime_5m").Scan(&count); err != nil {
+				t.Fatalf("Failed to count rows: %v", err)
+			}
+			return count
+		},
+		Reject: sinktest.Unencodable,
+	})
+}
@@ -0,0 +1,77 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultIndexName is the index name template used unless UseIndexName sets
+// another: one index per prefix, document type and day
+const DefaultIndexName = `{{.Prefix}}-{{.Type}}-{{.Time.Format "2006.01.02"}}`
+
+// IndexNameData is the data an index name template is executed with
+type IndexNameData struct {
+	Prefix string
+	Type   string
+	// ThermostatID is empty for documents that belong to no thermostat
+	ThermostatID string
+	// Time is when the document is written
+	Time time.Time
+}
+
+// indexNameFuncs are the functions available to index name templates
+var indexNameFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+}
+
+// parseIndexName parses an index name template
+func parseIndexName(text string) (*template.Template, error) {
+	return template.New("index_name").Funcs(indexNameFuncs).Option("missingkey=error").Parse(text)
+}
+
+// UseIndexName sets the Go template that names the index each document is
+// written to, e.g. {{.Prefix}}-{{.Type}}-{{.Time.Format "2006.01"}} for
+// monthly indices. Names must start with <prefix>-<type>- so the index
+// templates and mapping check still apply.
+func (s *Sink) UseIndexName(text string) error {
+	tmpl, err := parseIndexName(text)
+	if err != nil {
+		return fmt.Errorf("parsing index name template: %w", err)
+	}
+
+	sample := IndexNameData{Prefix: s.indexPrefix, Type: "runtime_5m", ThermostatID: "123456789012", Time: time.Now()}
+	name, err := renderIndexName(tmpl, sample)
+	if err != nil {
+		return err
+	}
+	if want := sample.Prefix + "-" + sample.Type + "-"; !strings.HasPrefix(name, want) || name == want {
+		return fmt.Errorf("index name template renders %q; names must start with %q and add a suffix", name, want)
+	}
+
+	s.indexName = tmpl
+	s.indexNameUsesThermostat = strings.Contains(text, ".ThermostatID")
+	return nil
+}
+
+// renderIndexName executes an index name template
+func renderIndexName(tmpl *template.Template, data IndexNameData) (string, error) {
+	var name strings.Builder
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("rendering index name: %w", err)
+	}
+	return name.String(), nil
+}
+
+// thermostatID returns the thermostat_id of a serialized document, if any
+func thermostatID(body []byte) string {
+	var keyed struct {
+		ThermostatID string `json:"thermostat_id"`
+	}
+	if err := json.Unmarshal(body, &keyed); err != nil {
+		return ""
+	}
+	return keyed.ThermostatID
+}
@@ -0,0 +1,54 @@
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// UseIngestPipeline runs every indexed document through the named ingest
+// pipeline, so the cluster can enrich documents as they arrive
+func (s *Sink) UseIngestPipeline(name string) {
+	s.pipeline = name
+}
+
+// UseRouting routes each document by the value of a field, e.g.
+// thermostat_id, so a thermostat's documents share a shard. Nested fields
+// are named with dots. Documents without the field use default routing.
+func (s *Sink) UseRouting(field string) {
+	s.routingField = field
+}
+
+// routingValue returns the routing value of a serialized document, or "" for
+// default routing
+func (s *Sink) routingValue(body []byte) (string, error) {
+	if s.routingField == "" {
+		return "", nil
+	}
+
+	var value any
+	if err := json.Unmarshal(body, &value); err != nil {
+		return "", fmt.Errorf("decoding document for routing: %w", err)
+	}
+	for _, key := range strings.Split(s.routingField, ".") {
+		fields, ok := value.(map[string]any)
+		if !ok {
+			return "", nil
+		}
+		value = fields[key]
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		return "", fmt.Errorf("routing field %s is not a scalar", s.routingField)
+	}
+}
@@ -1,35 +1,121 @@
 package elasticsearch
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/connstats"
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/secret"
 )
 
+// defaultThrottleBackoff is used when Elasticsearch rejects a bulk request
+// without telling us how long to wait
+const defaultThrottleBackoff = 30 * time.Second
+
 // Sink implements the Elasticsearch data sink
 type Sink struct {
+	conns           connstats.Counter
 	client          *http.Client
 	url             string
-	apiKey          string
 	indexPrefix     string
 	createTemplates bool
+	now             func() time.Time
+	logger          *slog.Logger
+
+	// indexName names each document's index; see UseIndexName
+	indexName               *template.Template
+	indexNameUsesThermostat bool
+
+	// pipeline and routingField are added to bulk index actions when set
+	pipeline     string
+	routingField string
+
+	// compress gzips bulk request bodies; see UseCompression
+	compress bool
+
+	// validateOnly sends writes to throwaway validation indices
+	validateOnly bool
+	validationMu sync.Mutex
+	validation   map[string]bool
+
+	// apiKey may be replaced by ReloadCredentials while writes are in flight
+	keyMu      sync.RWMutex
+	apiKey     string
+	apiKeyFile *secret.File
 }
 
 // NewSink creates a new Elasticsearch sink
 func NewSink(url, apiKey, indexPrefix string, createTemplates bool) *Sink {
-	return &Sink{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+	s := &Sink{
 		url:             url,
 		apiKey:          apiKey,
 		indexPrefix:     indexPrefix,
 		createTemplates: createTemplates,
+		now:             time.Now,
+		indexName:       template.Must(parseIndexName(DefaultIndexName)),
+		logger:          slog.Default(),
+	}
+	s.client = s.conns.Client(30 * time.Second)
+	return s
+}
+
+// UseLogger sets the logger for template upgrades and mapping warnings
+func (s *Sink) UseLogger(logger *slog.Logger) {
+	s.logger = logger
+}
+
+// UseAPIKeyFile reads the API key from a file and re-reads it on
+// ReloadCredentials, replacing any key passed to NewSink
+func (s *Sink) UseAPIKeyFile(path string) error {
+	file, err := secret.NewFile(path)
+	if err != nil {
+		return fmt.Errorf("reading api key: %w", err)
+	}
+
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+	s.apiKeyFile = file
+	s.apiKey = file.Value()
+	return nil
+}
+
+// ReloadCredentials re-reads the API key file, if one is configured
+func (s *Sink) ReloadCredentials() (bool, error) {
+	s.keyMu.Lock()
+	defer s.keyMu.Unlock()
+
+	if s.apiKeyFile == nil {
+		return false, nil
+	}
+	changed, err := s.apiKeyFile.Reload()
+	if err != nil {
+		return false, fmt.Errorf("reloading api key: %w", err)
+	}
+	s.apiKey = s.apiKeyFile.Value()
+	return changed, nil
+}
+
+// authorize adds the API key, if any, to a request
+func (s *Sink) authorize(req *http.Request) {
+	s.keyMu.RLock()
+	defer s.keyMu.RUnlock()
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
 	}
 }
 
@@ -42,12 +128,19 @@ func (s *Sink) Info() model.SinkInfo {
 	}
 }
 
-// Open initializes the sink connection and creates index templates if needed
+// Open creates or upgrades the index templates if enabled, and warns about
+// existing indices whose mappings conflict with them
 func (s *Sink) Open(ctx context.Context) error {
+	if s.validateOnly {
+		// Leave the cluster's templates alone; the mapping check only reads
+		s.warnMappingConflicts(ctx)
+		return nil
+	}
 	if s.createTemplates {
-		if err := s.createIndexTemplates(ctx); err != nil {
+		if err := s.ensureTemplates(ctx); err != nil {
 			return fmt.Errorf("creating index templates: %w", err)
 		}
+		s.warnMappingConflicts(ctx)
 	}
 	return nil
 }
@@ -57,17 +150,43 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 	if len(docs) == 0 {
 		return model.WriteResult{SuccessCount: 0, ErrorCount: 0}, nil
 	}
+	if s.validateOnly {
+		if err := s.ensureValidationIndices(ctx, docs); err != nil {
+			return model.WriteResult{}, err
+		}
+	}
 
 	// Prepare bulk request
+	now := s.now()
 	var bulkBody strings.Builder
 	for _, doc := range docs {
+		// Serialize document
+		docBytes, err := json.Marshal(doc.Body)
+		if err != nil {
+			return model.WriteResult{}, fmt.Errorf("marshaling document: %w", err)
+		}
+
+		index, err := s.getIndexName(doc.Type, docBytes, now)
+		if err != nil {
+			return model.WriteResult{}, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+
 		// Create index action
-		indexAction := map[string]any{
-			"index": map[string]any{
-				"_index": s.getIndexName(doc.Type),
-				"_id":    doc.ID,
-			},
+		action := map[string]any{
+			"_index": index,
+			"_id":    doc.ID,
+		}
+		if s.pipeline != "" {
+			action["pipeline"] = s.pipeline
 		}
+		routing, err := s.routingValue(docBytes)
+		if err != nil {
+			return model.WriteResult{}, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		if routing != "" {
+			action["routing"] = routing
+		}
+		indexAction := map[string]any{"index": action}
 
 		// Serialize index action
 		actionBytes, err := json.Marshal(indexAction)
@@ -76,26 +195,30 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 		}
 		bulkBody.Write(actionBytes)
 		bulkBody.WriteString("\n")
-
-		// Serialize document
-		docBytes, err := json.Marshal(doc.Body)
-		if err != nil {
-			return model.WriteResult{}, fmt.Errorf("marshaling document: %w", err)
-		}
 		bulkBody.Write(docBytes)
 		bulkBody.WriteString("\n")
 	}
 
 	// Make bulk request
-	req, err := http.NewRequestWithContext(ctx, "POST", s.url+"/_bulk", strings.NewReader(bulkBody.String()))
+	payload := []byte(bulkBody.String())
+	body := payload
+	if s.compress {
+		compressed, err := gzipBody(payload)
+		if err != nil {
+			return model.WriteResult{}, fmt.Errorf("compressing bulk request: %w", err)
+		}
+		body = compressed
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.url+"/_bulk", bytes.NewReader(body))
 	if err != nil {
 		return model.WriteResult{}, fmt.Errorf("creating bulk request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/x-ndjson")
-	if s.apiKey != "" {
-		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+	if s.compress {
+		req.Header.Set("Content-Encoding", "gzip")
 	}
+	s.authorize(req)
 
 	resp, err := s.client.Do(req)
 	if err != nil {
@@ -105,6 +228,14 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 		_ = resp.Body.Close()
 	}()
 
+	if err := checkBulkRejection(resp); err != nil {
+		return model.WriteResult{}, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return model.WriteResult{}, fmt.Errorf("bulk request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
 	// Parse response
 	var bulkResponse struct {
 		Errors bool `json:"errors"`
@@ -117,14 +248,19 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 		} `json:"items"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&bulkResponse); err != nil {
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &bulkResponse); err != nil {
 		return model.WriteResult{}, fmt.Errorf("decoding bulk response: %w", err)
 	}
+	if len(bulkResponse.Items) != len(docs) {
+		return model.WriteResult{}, fmt.Errorf("bulk response has %d items for %d documents", len(bulkResponse.Items), len(docs))
+	}
 
 	result := model.WriteResult{
 		SuccessCount: 0,
 		ErrorCount:   0,
 		Errors:       []string{},
+		PayloadBytes: int64(len(payload)),
+		SentBytes:    int64(len(body)),
 	}
 
 	// Count successes and errors
@@ -136,6 +272,8 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 			if item.Index.Error != nil {
 				errorBytes, _ := json.Marshal(item.Index.Error)
 				result.Errors = append(result.Errors, fmt.Sprintf("ID %s: %s", item.Index.ID, string(errorBytes)))
+			} else {
+				result.Errors = append(result.Errors, fmt.Sprintf("ID %s: status %d", item.Index.ID, item.Index.Status))
 			}
 		}
 	}
@@ -143,115 +281,115 @@ func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult,
 	return result, nil
 }
 
-// Close closes the sink connection
-func (s *Sink) Close(ctx context.Context) error {
-	// No persistent connections to close for HTTP client
-	return nil
-}
-
-// getIndexName generates the index name for a document type
-func (s *Sink) getIndexName(docType string) string {
-	date := time.Now().Format("2006.01.02")
-	return fmt.Sprintf("%s-%s-%s", s.indexPrefix, docType, date)
+// UseCompression gzips bulk request bodies. Bulk NDJSON compresses well, so
+// this trades a little CPU for much less bandwidth to remote clusters.
+func (s *Sink) UseCompression(enabled bool) {
+	s.compress = enabled
 }
 
-// createIndexTemplates creates Elasticsearch index templates for the document types
-func (s *Sink) createIndexTemplates(ctx context.Context) error {
-	templates := map[string]string{
-		"runtime_5m": `
-{
-	"index_patterns": ["` + s.indexPrefix + `-runtime_5m-*"],
-	"template": {
-		"mappings": {
-			"properties": {
-				"type": {"type": "keyword"},
-				"thermostat_id": {"type": "keyword"},
-				"thermostat_name": {"type": "keyword"},
-				"household_id": {"type": "keyword"},
-				"event_time": {"type": "date"},
-				"mode": {"type": "keyword"},
-				"climate": {"type": "keyword"},
-				"set_heat_c": {"type": "float"},
-				"set_cool_c": {"type": "float"},
-				"avg_temp_c": {"type": "float"},
-				"outdoor_temp_c": {"type": "float"},
-				"outdoor_humidity_pct": {"type": "integer"},
-				"equip": {"type": "object"},
-				"sensors": {"type": "object"},
-				"provider": {"type": "object"}
-			}
-		}
+// gzipBody returns data gzip-compressed
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
 	}
-}`,
-		"transition": `
-{
-	"index_patterns": ["` + s.indexPrefix + `-transition-*"],
-	"template": {
-		"mappings": {
-			"properties": {
-				"type": {"type": "keyword"},
-				"event_time": {"type": "date"},
-				"thermostat_id": {"type": "keyword"},
-				"thermostat_name": {"type": "keyword"},
-				"prev": {"type": "object"},
-				"next": {"type": "object"},
-				"event": {"type": "object"},
-				"provider": {"type": "object"}
-			}
-		}
-	}
-}`,
-		"device_snapshot": `
-{
-	"index_patterns": ["` + s.indexPrefix + `-device_snapshot-*"],
-	"template": {
-		"mappings": {
-			"properties": {
-				"type": {"type": "keyword"},
-				"collected_at": {"type": "date"},
-				"thermostat_id": {"type": "keyword"},
-				"thermostat_name": {"type": "keyword"},
-				"program": {"type": "object"},
-				"events_active": {"type": "object"},
-				"provider": {"type": "object"}
-			}
-		}
+	if err := writer.Close(); err != nil {
+		return nil, err
 	}
-}`,
+	return buf.Bytes(), nil
+}
+
+// checkBulkRejection converts cluster-level bulk rejections (429/503) into a
+// ThrottledError so the scheduler can hold off writes until the cluster recovers
+func checkBulkRejection(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
 	}
 
-	for templateName, templateBody := range templates {
-		if err := s.createTemplate(ctx, templateName, templateBody); err != nil {
-			return fmt.Errorf("creating template %s: %w", templateName, err)
-		}
+	retryAfter := retry.RetryAfterFromResponse(resp)
+	if retryAfter == 0 {
+		retryAfter = defaultThrottleBackoff
 	}
+	return fmt.Errorf("bulk request rejected: %w", retry.NewThrottledError(resp.StatusCode, retryAfter))
+}
 
+// Close deletes any validation indices and releases idle keep-alive
+// connections
+func (s *Sink) Close(ctx context.Context) error {
+	defer s.client.CloseIdleConnections()
+	if s.validateOnly {
+		return s.deleteValidationIndices(ctx)
+	}
 	return nil
 }
 
-// createTemplate creates a single index template
-func (s *Sink) createTemplate(ctx context.Context, templateName, templateBody string) error {
-	req, err := http.NewRequestWithContext(ctx, "PUT", s.url+"/_index_template/"+templateName, strings.NewReader(templateBody))
-	if err != nil {
-		return fmt.Errorf("creating template request: %w", err)
+// OpenConnections returns the number of connections the sink holds open
+func (s *Sink) OpenConnections() int {
+	return s.conns.Open()
+}
+
+// getIndexName returns the index for a serialized document written at the
+// given time
+func (s *Sink) getIndexName(docType string, body []byte, at time.Time) (string, error) {
+	if s.validateOnly {
+		return s.validationIndex(docType), nil
+	}
+	data := IndexNameData{Prefix: s.indexPrefix, Type: docType, Time: at}
+	if s.indexNameUsesThermostat {
+		data.ThermostatID = thermostatID(body)
 	}
+	return renderIndexName(s.indexName, data)
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	if s.apiKey != "" {
-		req.Header.Set("Authorization", "ApiKey "+s.apiKey)
+// ReadDocument fetches a document's _source with a realtime GET. Index names
+// depend on the write time, so a write just before the name changes, e.g. at
+// midnight, is looked up in the index of an hour earlier too.
+func (s *Sink) ReadDocument(ctx context.Context, doc model.Doc) ([]byte, bool, error) {
+	body, err := json.Marshal(doc.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshaling document %s: %w", doc.ID, err)
+	}
+	now := s.now()
+	var indices []string
+	for _, at := range []time.Time{now, now.Add(-time.Hour)} {
+		index, err := s.getIndexName(doc.Type, body, at)
+		if err != nil {
+			return nil, false, fmt.Errorf("document %s: %w", doc.ID, err)
+		}
+		if !slices.Contains(indices, index) {
+			indices = append(indices, index)
+		}
 	}
 
-	resp, err := s.client.Do(req)
+	path := "/_source/" + url.PathEscape(doc.ID)
+	routing, err := s.routingValue(body)
 	if err != nil {
-		return fmt.Errorf("executing template request: %w", err)
+		return nil, false, fmt.Errorf("document %s: %w", doc.ID, err)
 	}
-	defer func() {
+	if routing != "" {
+		path += "?" + url.Values{"routing": {routing}}.Encode()
+	}
+
+	for _, index := range indices {
+		resp, err := s.request(ctx, http.MethodGet, "/"+index+path, nil)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading document %s: %w", doc.ID, err)
+		}
+		body, err := bodylimit.ReadAll(resp.Body, bodylimit.DefaultMaxBytes)
 		_ = resp.Body.Close()
-	}()
+		if err != nil {
+			return nil, false, fmt.Errorf("reading document %s: %w", doc.ID, err)
+		}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("template creation failed with status %d", resp.StatusCode)
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			return body, true, nil
+		case resp.StatusCode == http.StatusNotFound:
+			continue
+		default:
+			return nil, false, fmt.Errorf("reading document %s failed with status %d: %s", doc.ID, resp.StatusCode, strings.TrimSpace(string(body)))
+		}
 	}
-
-	return nil
+	return nil, false, nil
 }
@@ -1,13 +1,35 @@
 package elasticsearch
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"go.uber.org/goleak"
+
 	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
 )
 
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
 func TestGenerateRuntime5mID(t *testing.T) {
 	gen := model.NewIDGenerator()
 
@@ -206,7 +228,10 @@ func TestGetIndexName(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := sink.getIndexName(tt.docType)
+			result, err := sink.getIndexName(tt.docType, nil, time.Now())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
 
 			// Verify prefix and type
 			if !strings.HasPrefix(result, tt.expected) {
@@ -222,6 +247,766 @@ func TestGetIndexName(t *testing.T) {
 	}
 }
 
+func TestIndexNameTemplates(t *testing.T) {
+	at := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"thermostat_id":"T123"}`)
+
+	tests := []struct {
+		name     string
+		template string
+		expected string
+		wantErr  bool
+	}{
+		{name: "default", template: DefaultIndexName, expected: "ttr-runtime_5m-2025.01.10"},
+		{name: "monthly", template: `{{.Prefix}}-{{.Type}}-{{.Time.Format "2006.01"}}`, expected: "ttr-runtime_5m-2025.01"},
+		{name: "per thermostat", template: `{{.Prefix}}-{{.Type}}-{{lower .ThermostatID}}-{{.Time.Format "2006"}}`, expected: "ttr-runtime_5m-t123-2025"},
+		{name: "invalid syntax", template: `{{.Prefix}-{{.Type}}`, wantErr: true},
+		{name: "unknown field", template: `{{.Prefix}}-{{.Type}}-{{.Index}}`, wantErr: true},
+		{name: "outside the template patterns", template: `telemetry-{{.Type}}`, wantErr: true},
+		{name: "no suffix", template: `{{.Prefix}}-{{.Type}}-`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewSink("http://localhost:9200", "", "ttr", false)
+			err := sink.UseIndexName(tt.template)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			name, err := sink.getIndexName("runtime_5m", body, at)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, name)
+			}
+		})
+	}
+}
+
+func TestWriteBulkRejection(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		retryAfter    string
+		expectedDelay time.Duration
+	}{
+		{"429 with Retry-After", http.StatusTooManyRequests, "120", 120 * time.Second},
+		{"503 without Retry-After", http.StatusServiceUnavailable, "", defaultThrottleBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			sink := NewSink(server.URL, "", "ttr", false)
+			docs := []model.Doc{{ID: "doc-1", Type: "runtime_5m", Body: map[string]any{"a": 1}}}
+
+			_, err := sink.Write(context.Background(), docs)
+
+			var throttled *retry.ThrottledError
+			if !errors.As(err, &throttled) {
+				t.Fatalf("Expected ThrottledError, got %v", err)
+			}
+			if throttled.RetryAfter != tt.expectedDelay {
+				t.Errorf("Expected retry after %v, got %v", tt.expectedDelay, throttled.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestReloadCredentials(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"errors":false,"items":[{"index":{"_id":"doc-1","status":201}}]}`))
+	}))
+	defer server.Close()
+
+	keyFile := filepath.Join(t.TempDir(), "api_key")
+	if err := os.WriteFile(keyFile, []byte("old-key\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+
+	sink := NewSink(server.URL, "", "ttr", false)
+	if err := sink.UseAPIKeyFile(keyFile); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	docs := []model.Doc{{ID: "doc-1", Type: "runtime_5m", Body: map[string]any{"a": 1}}}
+
+	tests := []struct {
+		name     string
+		content  string
+		changed  bool
+		wantErr  bool
+		expected string
+	}{
+		{name: "unchanged", content: "old-key", expected: "ApiKey old-key"},
+		{name: "rotated", content: "new-key\n", changed: true, expected: "ApiKey new-key"},
+		{name: "empty file keeps key", content: "", wantErr: true, expected: "ApiKey new-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(keyFile, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write key file: %v", err)
+			}
+			changed, err := sink.ReloadCredentials()
+			if (err != nil) != tt.wantErr || changed != tt.changed {
+				t.Fatalf("Expected changed=%v error=%v, got changed=%v error=%v", tt.changed, tt.wantErr, changed, err)
+			}
+			if _, err := sink.Write(context.Background(), docs); err != nil {
+				t.Fatalf("Unexpected write error: %v", err)
+			}
+			if authorization != tt.expected {
+				t.Errorf("Expected Authorization %q, got %q", tt.expected, authorization)
+			}
+		})
+	}
+}
+
+// fakeCluster is an Elasticsearch stand-in that stores bulk-indexed
+// documents by ID and records template requests. Documents whose source
+// contains "reject" fail with a mapping error.
+type fakeCluster struct {
+	mu   sync.Mutex
+	docs map[string]string
+	// located holds the index each document was written to
+	located   map[string]string
+	bulks     []string
+	templates map[string]string
+	puts      int
+	// mappings holds each live index's mappings JSON
+	mappings map[string]string
+	// indices holds the body each index was created with
+	indices  map[string]string
+	deleted  []string
+	auth     []string
+	requests []string
+}
+
+func newFakeCluster(t *testing.T) (*fakeCluster, *httptest.Server) {
+	t.Helper()
+	cluster := &fakeCluster{docs: make(map[string]string), located: make(map[string]string), templates: make(map[string]string), mappings: make(map[string]string), indices: make(map[string]string)}
+	server := httptest.NewServer(cluster)
+	t.Cleanup(server.Close)
+	return cluster, server
+}
+
+func (c *fakeCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader = gzipReader
+	}
+	body, _ := io.ReadAll(reader)
+	c.auth = append(c.auth, r.Header.Get("Authorization"))
+	c.requests = append(c.requests, r.Method+" "+r.URL.RequestURI())
+
+	switch {
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		c.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = string(body)
+		c.puts++
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		name := strings.TrimPrefix(r.URL.Path, "/_index_template/")
+		body, ok := c.templates[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"index_templates":[{"name":%q,"index_template":%s}]}`, name, body)
+	case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/_mapping"):
+		prefix := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSuffix(r.URL.Path, "/_mapping"), "/"), "*")
+		matched := make(map[string]json.RawMessage)
+		for index, mappings := range c.mappings {
+			if strings.HasPrefix(index, prefix) {
+				matched[index] = json.RawMessage(`{"mappings":` + mappings + `}`)
+			}
+		}
+		_ = json.NewEncoder(w).Encode(matched)
+	case r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/_source/"):
+		index, id, _ := strings.Cut(r.URL.Path[1:], "/_source/")
+		if c.located[id] != index {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(c.docs[id]))
+	case r.Method == http.MethodGet && r.URL.Path == "/_count":
+		_ = json.NewEncoder(w).Encode(map[string]int{"count": len(c.docs)})
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		c.bulks = append(c.bulks, string(body))
+		c.bulk(w, body)
+	case r.Method == http.MethodPut && !strings.Contains(r.URL.Path[1:], "/"):
+		c.indices[r.URL.Path[1:]] = string(body)
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	case r.Method == http.MethodDelete:
+		c.deleted = append(c.deleted, r.URL.Path[1:])
+		_, _ = w.Write([]byte(`{"acknowledged":true}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (c *fakeCluster) bulk(w http.ResponseWriter, body []byte) {
+	type itemResult struct {
+		ID     string `json:"_id"`
+		Status int    `json:"status"`
+		Error  any    `json:"error,omitempty"`
+	}
+	var items []map[string]itemResult
+	hasErrors := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var action struct {
+			Index struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			} `json:"index"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &action); err != nil || !scanner.Scan() {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		source := scanner.Text()
+		result := itemResult{ID: action.Index.ID, Status: http.StatusCreated}
+		if strings.Contains(source, "reject") {
+			result.Status = http.StatusBadRequest
+			result.Error = map[string]any{"type": "mapper_parsing_exception", "reason": "failed to parse"}
+			hasErrors = true
+		} else {
+			c.docs[action.Index.ID] = source
+			c.located[action.Index.ID] = action.Index.Index
+		}
+		items = append(items, map[string]itemResult{"index": result})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"errors": hasErrors, "items": items})
+}
+
+func TestWriteBulkPayload(t *testing.T) {
+	cluster, server := newFakeCluster(t)
+	sink := NewSink(server.URL, "secret", "ttr", false)
+	sink.now = func() time.Time { return time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC) }
+
+	eventTime := time.Date(2025, 1, 10, 11, 55, 0, 0, time.UTC)
+	docs := []model.Doc{
+		{ID: "t1:2025-01-10T11:55:00Z:runtime_5m:0123456789abcdef", Type: "runtime_5m", Body: &model.Runtime5m{
+			Type:         "runtime_5m",
+			ThermostatID: "t1",
+			EventTime:    eventTime,
+			Mode:         "heat",
+			Climate:      "Home",
+			SetHeatC:     floatPtr(20.5),
+			AvgTempC:     floatPtr(20.1),
+			Equipment:    map[string]bool{"compHeat1": true, "fan": true},
+		}},
+		{ID: "t1:2025-01-10T11:57:00Z:transition:fedcba9876543210", Type: "transition", Body: &model.Transition{
+			Type:         "transition",
+			ThermostatID: "t1",
+			EventTime:    eventTime.Add(2 * time.Minute),
+			Prev:         model.State{Mode: "heat", Climate: "Home", SetHeatC: floatPtr(20.5)},
+			Next:         model.State{Mode: "heat", Climate: "Home", SetHeatC: floatPtr(21)},
+			Event:        model.EventInfo{Kind: "manual"},
+		}},
+	}
+
+	result, err := sink.Write(context.Background(), docs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SuccessCount != 2 || result.ErrorCount != 0 {
+		t.Errorf("Unexpected write result: %+v", result)
+	}
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "bulk_payload.ndjson"))
+	if err != nil {
+		t.Fatalf("Failed to read golden payload: %v", err)
+	}
+	if len(cluster.bulks) != 1 || cluster.bulks[0] != string(golden) {
+		t.Errorf("Bulk payload does not match testdata/bulk_payload.ndjson:\n%s", strings.Join(cluster.bulks, "\n---\n"))
+	}
+	if cluster.auth[0] != "ApiKey secret" {
+		t.Errorf("Expected the API key on bulk requests, got %q", cluster.auth[0])
+	}
+	if result.PayloadBytes != int64(len(golden)) || result.SentBytes != result.PayloadBytes {
+		t.Errorf("Expected %d payload and sent bytes, got %+v", len(golden), result)
+	}
+
+	t.Run("compressed", func(t *testing.T) {
+		cluster, server := newFakeCluster(t)
+		sink := NewSink(server.URL, "secret", "ttr", false)
+		sink.now = func() time.Time { return time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC) }
+		sink.UseCompression(true)
+
+		result, err := sink.Write(context.Background(), docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(cluster.bulks) != 1 || cluster.bulks[0] != string(golden) {
+			t.Errorf("Decompressed bulk payload does not match testdata/bulk_payload.ndjson:\n%s", strings.Join(cluster.bulks, "\n---\n"))
+		}
+		if result.PayloadBytes != int64(len(golden)) || result.SentBytes == 0 || result.SentBytes >= result.PayloadBytes {
+			t.Errorf("Expected fewer sent than payload bytes, got %+v", result)
+		}
+	})
+}
+
+func TestWriteBulkResponse(t *testing.T) {
+	docs := []model.Doc{
+		{ID: "doc-1", Type: "runtime_5m", Body: map[string]any{"a": 1}},
+		{ID: "doc-2", Type: "runtime_5m", Body: map[string]any{"a": 2}},
+	}
+
+	tests := []struct {
+		name      string
+		status    int
+		response  string
+		success   int
+		errors    []string
+		wantErr   string
+		noRequest bool
+		docs      []model.Doc
+	}{
+		{
+			name:     "all indexed",
+			response: `{"errors":false,"items":[{"index":{"_id":"doc-1","status":201}},{"index":{"_id":"doc-2","status":200}}]}`,
+			success:  2,
+		},
+		{
+			name: "partial failure",
+			response: `{"errors":true,"items":[{"index":{"_id":"doc-1","status":201}},` +
+				`{"index":{"_id":"doc-2","status":400,"error":{"type":"mapper_parsing_exception","reason":"failed to parse field [a]"}}}]}`,
+			success: 1,
+			errors:  []string{`ID doc-2: {"reason":"failed to parse field [a]","type":"mapper_parsing_exception"}`},
+		},
+		{
+			name:     "failure without error detail",
+			response: `{"errors":true,"items":[{"index":{"_id":"doc-1","status":201}},{"index":{"_id":"doc-2","status":409}}]}`,
+			success:  1,
+			errors:   []string{"ID doc-2: status 409"},
+		},
+		{
+			name:     "malformed response",
+			response: `{"errors":false,"items":[`,
+			wantErr:  "decoding bulk response",
+		},
+		{
+			name:     "missing items",
+			response: `{"errors":false,"items":[{"index":{"_id":"doc-1","status":201}}]}`,
+			wantErr:  "1 items for 2 documents",
+		},
+		{
+			name:     "request error",
+			status:   http.StatusBadRequest,
+			response: `{"error":{"type":"illegal_argument_exception","reason":"Malformed action/metadata line"},"status":400}`,
+			wantErr:  "status 400",
+		},
+		{
+			name:      "empty batch",
+			docs:      []model.Doc{},
+			noRequest: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if r.Header.Get("Content-Type") != "application/x-ndjson" {
+					t.Errorf("Expected an NDJSON bulk request, got %q", r.Header.Get("Content-Type"))
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			batch := docs
+			if tt.docs != nil {
+				batch = tt.docs
+			}
+			sink := NewSink(server.URL, "", "ttr", false)
+			result, err := sink.Write(context.Background(), batch)
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.SuccessCount != tt.success || result.ErrorCount != len(tt.errors) {
+				t.Errorf("Expected %d successes and %d errors, got %+v", tt.success, len(tt.errors), result)
+			}
+			if strings.Join(result.Errors, "\n") != strings.Join(tt.errors, "\n") {
+				t.Errorf("Expected errors %q, got %q", tt.errors, result.Errors)
+			}
+			if tt.noRequest && requests != 0 {
+				t.Errorf("Expected no bulk request, got %d", requests)
+			}
+		})
+	}
+}
+
+func TestOpenCreatesTemplates(t *testing.T) {
+	expected := []string{"runtime_5m", "transition", "device_snapshot", "device_metadata", "runtime_live", "analysis", "alert", "recommendation", "firmware_change", "connectivity"}
+
+	t.Run("templates are written", func(t *testing.T) {
+		cluster, server := newFakeCluster(t)
+		sink := NewSink(server.URL, "secret", "home", true)
+		if err := sink.Open(context.Background()); err != nil {
+			t.Fatalf("Failed to open: %v", err)
+		}
+
+		if len(cluster.templates) != len(expected) {
+			t.Errorf("Expected %d templates, got %d", len(expected), len(cluster.templates))
+		}
+		for _, name := range expected {
+			body, ok := cluster.templates[name]
+			if !ok {
+				t.Errorf("Missing template %s", name)
+				continue
+			}
+			var template struct {
+				IndexPatterns []string `json:"index_patterns"`
+				Template      struct {
+					Mappings struct {
+						Properties map[string]any `json:"properties"`
+					} `json:"mappings"`
+				} `json:"template"`
+			}
+			if err := json.Unmarshal([]byte(body), &template); err != nil {
+				t.Errorf("Template %s is not valid JSON: %v", name, err)
+				continue
+			}
+			if len(template.IndexPatterns) != 1 || template.IndexPatterns[0] != "home-"+name+"-*" {
+				t.Errorf("Unexpected index patterns for %s: %v", name, template.IndexPatterns)
+			}
+			if _, ok := template.Template.Mappings.Properties["thermostat_id"]; !ok {
+				t.Errorf("Template %s does not map thermostat_id", name)
+			}
+		}
+		for _, auth := range cluster.auth {
+			if auth != "ApiKey secret" {
+				t.Errorf("Expected the API key on template requests, got %q", auth)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		cluster, server := newFakeCluster(t)
+		sink := NewSink(server.URL, "", "home", false)
+		if err := sink.Open(context.Background()); err != nil {
+			t.Fatalf("Failed to open: %v", err)
+		}
+		if len(cluster.templates) != 0 {
+			t.Errorf("Expected no templates, got %d", len(cluster.templates))
+		}
+	})
+
+	t.Run("rejected template fails open", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer server.Close()
+		sink := NewSink(server.URL, "", "home", true)
+		err := sink.Open(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "status 403") {
+			t.Errorf("Expected a template error with status 403, got %v", err)
+		}
+	})
+}
+
+func TestTemplateUpgrades(t *testing.T) {
+	ctx := context.Background()
+	versioned := func(version int) string {
+		return fmt.Sprintf(`{"index_patterns":["ttr-runtime_5m-*"],"_meta":{"version":%d}}`, version)
+	}
+
+	tests := []struct {
+		name      string
+		installed map[string]string
+		puts      int
+		version   int
+	}{
+		{name: "fresh cluster", puts: 10, version: TemplateVersion},
+		{name: "unversioned template is upgraded", installed: map[string]string{"runtime_5m": `{"index_patterns":["ttr-runtime_5m-*"]}`}, puts: 10, version: TemplateVersion},
+		{name: "older template is upgraded", installed: map[string]string{"runtime_5m": versioned(TemplateVersion - 1)}, puts: 10, version: TemplateVersion},
+		{name: "newer template is left alone", installed: map[string]string{"runtime_5m": versioned(TemplateVersion + 1)}, puts: 9, version: TemplateVersion + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, server := newFakeCluster(t)
+			for name, body := range tt.installed {
+				cluster.templates[name] = body
+			}
+			sink := NewSink(server.URL, "", "ttr", true)
+			if err := sink.Open(ctx); err != nil {
+				t.Fatalf("Failed to open: %v", err)
+			}
+			if cluster.puts != tt.puts {
+				t.Errorf("Expected %d template writes, got %d", tt.puts, cluster.puts)
+			}
+			var template struct {
+				Version int `json:"version"`
+				Meta    struct {
+					Version int `json:"version"`
+				} `json:"_meta"`
+			}
+			if err := json.Unmarshal([]byte(cluster.templates["runtime_5m"]), &template); err != nil {
+				t.Fatalf("Failed to decode template: %v", err)
+			}
+			if template.Meta.Version != tt.version {
+				t.Errorf("Expected _meta.version %d, got %d", tt.version, template.Meta.Version)
+			}
+
+			// Opening again finds every template current and writes nothing
+			cluster.puts = 0
+			if err := sink.Open(ctx); err != nil {
+				t.Fatalf("Failed to reopen: %v", err)
+			}
+			if cluster.puts != 0 {
+				t.Errorf("Expected no template writes on reopen, got %d", cluster.puts)
+			}
+		})
+	}
+}
+
+func TestMappingConflicts(t *testing.T) {
+	ctx := context.Background()
+	cluster, server := newFakeCluster(t)
+	cluster.mappings["ttr-runtime_5m-2025.01.09"] = `{"properties":{"thermostat_id":{"type":"text"},"set_heat_c":{"type":"long"},` +
+		`"location":{"properties":{"city":{"type":"keyword"},"latitude":{"type":"text"}}},"extra":{"type":"keyword"}}}`
+	cluster.mappings["ttr-runtime_5m-2025.01.10"] = `{"properties":{"thermostat_id":{"type":"text"},"set_heat_c":{"type":"float"}}}`
+	cluster.mappings["ttr-transition-2025.01.10"] = `{"properties":{"thermostat_id":{"type":"keyword"},"prev":{"properties":{"mode":{"type":"text"}}}}}`
+
+	sink := NewSink(server.URL, "", "ttr", true)
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Expected conflicts not to fail Open, got %v", err)
+	}
+
+	conflicts, err := sink.MappingConflicts(ctx)
+	if err != nil {
+		t.Fatalf("Failed to check mappings: %v", err)
+	}
+	expected := []string{
+		"runtime_5m field location.latitude is mapped as text in 1 index(es) (e.g. ttr-runtime_5m-2025.01.09), expected float",
+		"runtime_5m field set_heat_c is mapped as long in 1 index(es) (e.g. ttr-runtime_5m-2025.01.09), expected float",
+		"runtime_5m field thermostat_id is mapped as text in 2 index(es) (e.g. ttr-runtime_5m-2025.01.09), expected keyword",
+	}
+	var got []string
+	for _, conflict := range conflicts {
+		got = append(got, conflict.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected conflicts:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestValidateOnly(t *testing.T) {
+	ctx := context.Background()
+	cluster, server := newFakeCluster(t)
+	sink := NewSink(server.URL, "", "ttr", true)
+	sink.ValidateOnly()
+
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+	if cluster.puts != 0 {
+		t.Errorf("Expected no template writes in validate-only mode, got %d", cluster.puts)
+	}
+
+	docs := []model.Doc{
+		{ID: "r1", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+		{ID: "r2", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1", "note": "reject"}},
+		{ID: "c1", Type: "custom", Body: map[string]any{"value": 1}},
+	}
+	result, err := sink.Write(ctx, docs)
+	if err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if result.SuccessCount != 2 || result.ErrorCount != 1 {
+		t.Errorf("Expected the rejected document to be reported, got %+v", result)
+	}
+	if _, err := sink.Write(ctx, docs[:1]); err != nil {
+		t.Fatalf("Failed to write again: %v", err)
+	}
+
+	if len(cluster.indices) != 2 {
+		t.Errorf("Expected two validation indices, got %v", cluster.indices)
+	}
+	var created struct {
+		Mappings mapping `json:"mappings"`
+	}
+	if err := json.Unmarshal([]byte(cluster.indices["ttr-validate-runtime_5m"]), &created); err != nil {
+		t.Fatalf("Failed to decode index body: %v", err)
+	}
+	if got := created.Mappings.fieldTypes()["thermostat_id"]; got != "keyword" {
+		t.Errorf("Expected the runtime_5m validation index to use the template mappings, got thermostat_id %q", got)
+	}
+	if body := cluster.indices["ttr-validate-custom"]; body != "{}" {
+		t.Errorf("Expected dynamic mappings for a type without a template, got %s", body)
+	}
+	if !strings.Contains(cluster.bulks[0], `"_index":"ttr-validate-runtime_5m"`) || strings.Contains(cluster.bulks[0], `"_index":"ttr-runtime_5m-`) {
+		t.Errorf("Expected documents in validation indices only, got %s", cluster.bulks[0])
+	}
+
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	if strings.Join(cluster.deleted, ",") != "ttr-validate-custom,ttr-validate-runtime_5m" {
+		t.Errorf("Expected validation indices to be deleted, got %v", cluster.deleted)
+	}
+}
+
+func TestReadDocument(t *testing.T) {
+	ctx := context.Background()
+	_, server := newFakeCluster(t)
+	sink := NewSink(server.URL, "", "ttr", false)
+	written := time.Date(2025, 1, 10, 23, 59, 0, 0, time.UTC)
+	sink.now = func() time.Time { return written }
+
+	doc := model.Doc{ID: "t1:runtime", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}}
+	if _, err := sink.Write(ctx, []model.Doc{doc}); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		doc      model.Doc
+		expected bool
+	}{
+		{name: "same day", now: written, doc: doc, expected: true},
+		{name: "after midnight", now: written.Add(2 * time.Minute), doc: doc, expected: true},
+		{name: "next afternoon", now: written.Add(12 * time.Hour), doc: doc, expected: false},
+		{name: "unknown ID", now: written, doc: model.Doc{ID: "missing", Type: "runtime_5m"}, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.now = func() time.Time { return tt.now }
+			data, found, err := sink.ReadDocument(ctx, tt.doc)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if found != tt.expected {
+				t.Fatalf("Expected found %v, got %v", tt.expected, found)
+			}
+			if found && string(data) != `{"thermostat_id":"t1"}` {
+				t.Errorf("Expected the stored source, got %s", data)
+			}
+		})
+	}
+}
+
+func TestRoutingAndPipeline(t *testing.T) {
+	ctx := context.Background()
+	cluster, server := newFakeCluster(t)
+	sink := NewSink(server.URL, "", "ttr", false)
+	sink.UseIngestPipeline("ttr-enrich")
+	sink.UseRouting("thermostat_id")
+
+	docs := []model.Doc{
+		{ID: "r1", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+		{ID: "a1", Type: "analysis", Body: map[string]any{"period": "24h"}},
+	}
+	if _, err := sink.Write(ctx, docs); err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+
+	lines := strings.Split(cluster.bulks[0], "\n")
+	if !strings.Contains(lines[0], `"pipeline":"ttr-enrich"`) || !strings.Contains(lines[0], `"routing":"t1"`) {
+		t.Errorf("Expected pipeline and routing on the first action, got %s", lines[0])
+	}
+	if !strings.Contains(lines[2], `"pipeline":"ttr-enrich"`) || strings.Contains(lines[2], `"routing"`) {
+		t.Errorf("Expected default routing for a document without the field, got %s", lines[2])
+	}
+
+	if _, found, err := sink.ReadDocument(ctx, docs[0]); err != nil || !found {
+		t.Fatalf("Expected to read the document back, got found %v, error %v", found, err)
+	}
+	if last := cluster.requests[len(cluster.requests)-1]; !strings.HasSuffix(last, "/_source/r1?routing=t1") {
+		t.Errorf("Expected the read to carry the routing value, got %s", last)
+	}
+
+	tests := []struct {
+		name     string
+		field    string
+		body     string
+		expected string
+		wantErr  bool
+	}{
+		{name: "nested field", field: "location.postal_code", body: `{"location":{"postal_code":"60601"}}`, expected: "60601"},
+		{name: "number", field: "zone", body: `{"zone":12}`, expected: "12"},
+		{name: "missing field", field: "thermostat_id", body: `{"period":"24h"}`, expected: ""},
+		{name: "object", field: "location", body: `{"location":{"city":"Chicago"}}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink.UseRouting(tt.field)
+			routing, err := sink.routingValue([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if routing != tt.expected {
+				t.Errorf("Expected routing %q, got %q", tt.expected, routing)
+			}
+		})
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			_, server := newFakeCluster(t)
+			return NewSink(server.URL, "", "ttr", false)
+		},
+		Stored: func(t *testing.T, sink model.Sink) int {
+			response, err := http.Get(sink.(*Sink).url + "/_count")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				_ = response.Body.Close()
+			}()
+			var count struct {
+				Count int `json:"count"`
+			}
+			if err := json.NewDecoder(response.Body).Decode(&count); err != nil {
+				t.Fatal(err)
+			}
+			return count.Count
+		},
+		Reject: func() model.Doc {
+			return model.Doc{ID: "rejected", Type: "runtime_5m", Body: map[string]any{"note": "reject"}}
+		},
+		RequireCancellation: true,
+	})
+}
+
 // Helper function
 func floatPtr(f float64) *float64 {
 	return &f
@@ -0,0 +1,558 @@
+package elasticsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+)
+
+// TemplateVersion is stored in each index template's _meta.version and its
+// version field. Bump it whenever a template below changes so Open upgrades
+// the templates on existing clusters.
+const TemplateVersion = 5
+
+// MappingConflict is a field whose mapping in live indices differs from the
+// one the current template gives new indices
+type MappingConflict struct {
+	DocType  string   `json:"doc_type"`
+	Field    string   `json:"field"`
+	Expected string   `json:"expected"`
+	Actual   string   `json:"actual"`
+	Indices  []string `json:"indices"`
+}
+
+// String describes the conflict for logs
+func (c MappingConflict) String() string {
+	return fmt.Sprintf("%s field %s is mapped as %s in %d index(es) (e.g. %s), expected %s",
+		c.DocType, c.Field, c.Actual, len(c.Indices), c.Indices[0], c.Expected)
+}
+
+// ingestMapping maps the ingest metadata every document type carries
+const ingestMapping = `{
+					"properties": {
+						"collector_version": {"type": "keyword"},
+						"instance": {"type": "keyword"},
+						"cycle_id": {"type": "keyword"},
+						"fetched_at": {"type": "date"}
+					}
+				}`
+
+// tagsMapping maps the configured thermostat tags every document type can
+// carry; whatever the tag names, their values are indexed as keywords
+const tagsMapping = `{"type": "flattened"}`
+
+// indexTemplates returns the index template for each document type, keyed by
+// document type
+func (s *Sink) indexTemplates() map[string]string {
+	return map[string]string{
+		"runtime_5m": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-runtime_5m-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"mode": {"type": "keyword"},
+				"climate": {"type": "keyword"},
+				"set_heat_c": {"type": "float"},
+				"set_cool_c": {"type": "float"},
+				"avg_temp_c": {"type": "float"},
+				"outdoor_temp_c": {"type": "float"},
+				"outdoor_humidity_pct": {"type": "integer"},
+				"equip": {"type": "object"},
+				"equip_seconds": {"type": "object"},
+				"sensors": {"type": "object"},
+				"filled": {"type": "boolean"},
+				"location": {
+					"properties": {
+						"city": {"type": "keyword"},
+						"region": {"type": "keyword"},
+						"country": {"type": "keyword"},
+						"postal_code": {"type": "keyword"},
+						"latitude": {"type": "float"},
+						"longitude": {"type": "float"},
+						"square_footage": {"type": "integer"},
+						"hvac_type": {"type": "keyword"}
+					}
+				},
+				"provider": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"transition": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-transition-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"prev": {"type": "object"},
+				"next": {"type": "object"},
+				"event": {"type": "object"},
+				"provider": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"device_snapshot": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-device_snapshot-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"collected_at": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"revision": {"type": "keyword"},
+				"model": {"type": "keyword"},
+				"firmware_version": {"type": "keyword"},
+				"program": {"type": "object"},
+				"events_active": {"type": "object"},
+				"events": {
+					"properties": {
+						"kind": {"type": "keyword"},
+						"name": {"type": "keyword"},
+						"running": {"type": "boolean"},
+						"start": {"type": "date"},
+						"end": {"type": "date"}
+					}
+				},
+				"provider": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"device_metadata": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-device_metadata-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"collected_at": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"location": {
+					"properties": {
+						"city": {"type": "keyword"},
+						"region": {"type": "keyword"},
+						"country": {"type": "keyword"},
+						"postal_code": {"type": "keyword"},
+						"latitude": {"type": "float"},
+						"longitude": {"type": "float"},
+						"square_footage": {"type": "integer"},
+						"hvac_type": {"type": "keyword"}
+					}
+				},
+				"provider": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"runtime_live": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-runtime_live-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"mode": {"type": "keyword"},
+				"set_heat_c": {"type": "float"},
+				"set_cool_c": {"type": "float"},
+				"temp_c": {"type": "float"},
+				"humidity_pct": {"type": "integer"},
+				"equip": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"analysis": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-analysis-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"analyzer": {"type": "keyword"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"period_start": {"type": "date"},
+				"period_end": {"type": "date"},
+				"results": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"alert": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-alert-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"kind": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"sensor_id": {"type": "keyword"},
+				"value_c": {"type": "float"},
+				"message": {"type": "text"},
+				"details": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"recommendation": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-recommendation-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"kind": {"type": "keyword"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"period_start": {"type": "date"},
+				"period_end": {"type": "date"},
+				"climate": {"type": "keyword"},
+				"current_setpoint_c": {"type": "float"},
+				"suggested_setpoint_c": {"type": "float"},
+				"estimated_kwh_saved": {"type": "float"},
+				"message": {"type": "text"},
+				"details": {"type": "object"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"firmware_change": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-firmware_change-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"model": {"type": "keyword"},
+				"prev_version": {"type": "keyword"},
+				"next_version": {"type": "keyword"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+		"connectivity": `
+{
+	"index_patterns": ["` + s.indexPrefix + `-connectivity-*"],
+	"template": {
+		"mappings": {
+			"properties": {
+				"type": {"type": "keyword"},
+				"event_time": {"type": "date"},
+				"thermostat_id": {"type": "keyword"},
+				"thermostat_name": {"type": "keyword"},
+				"household_id": {"type": "keyword"},
+				"connected": {"type": "boolean"},
+				"tags": ` + tagsMapping + `,
+				"ingest": ` + ingestMapping + `
+			}
+		}
+	}
+}`,
+	}
+}
+
+// versionedTemplate adds the template version to a template body
+func versionedTemplate(body string) ([]byte, error) {
+	var template map[string]any
+	if err := json.Unmarshal([]byte(body), &template); err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	template["version"] = TemplateVersion
+	template["_meta"] = map[string]any{"version": TemplateVersion, "managed_by": "ttr"}
+	return json.Marshal(template)
+}
+
+// ensureTemplates creates missing index templates and upgrades ones written
+// with an older TemplateVersion. Templates from a newer version are left as
+// they are. It is safe to run on every Open.
+func (s *Sink) ensureTemplates(ctx context.Context) error {
+	templates := s.indexTemplates()
+	for _, name := range sortedKeys(templates) {
+		installed, found, err := s.installedTemplateVersion(ctx, name)
+		if err != nil {
+			return fmt.Errorf("reading template %s: %w", name, err)
+		}
+		switch {
+		case found && installed == TemplateVersion:
+			continue
+		case found && installed > TemplateVersion:
+			s.logger.Warn("Elasticsearch index template is newer than this version of ttr; leaving it in place",
+				"template", name, "installed_version", installed, "version", TemplateVersion)
+			continue
+		}
+
+		body, err := versionedTemplate(templates[name])
+		if err != nil {
+			return fmt.Errorf("template %s: %w", name, err)
+		}
+		if err := s.putTemplate(ctx, name, body); err != nil {
+			return fmt.Errorf("creating template %s: %w", name, err)
+		}
+		if found {
+			s.logger.Info("Upgraded Elasticsearch index template",
+				"template", name, "from_version", installed, "to_version", TemplateVersion)
+		}
+	}
+	return nil
+}
+
+// installedTemplateVersion returns the _meta.version of an installed
+// template, zero for templates without one, and whether it exists
+func (s *Sink) installedTemplateVersion(ctx context.Context, name string) (int, bool, error) {
+	resp, err := s.request(ctx, http.MethodGet, "/_index_template/"+name, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return 0, false, fmt.Errorf("template lookup failed with status %d", resp.StatusCode)
+	}
+
+	var response struct {
+		IndexTemplates []struct {
+			Name          string `json:"name"`
+			IndexTemplate struct {
+				Meta struct {
+					Version int `json:"version"`
+				} `json:"_meta"`
+			} `json:"index_template"`
+		} `json:"index_templates"`
+	}
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &response); err != nil {
+		return 0, false, fmt.Errorf("decoding template lookup: %w", err)
+	}
+	for _, template := range response.IndexTemplates {
+		if template.Name == name {
+			return template.IndexTemplate.Meta.Version, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// putTemplate writes a single index template
+func (s *Sink) putTemplate(ctx context.Context, name string, body []byte) error {
+	resp, err := s.request(ctx, http.MethodPut, "/_index_template/"+name, body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("template creation failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// MappingConflicts compares the mappings of existing indices with the
+// current templates. Indices created before a template upgrade keep their
+// old mappings, and dynamically mapped fields can take the wrong type; both
+// show up here. Fields the template leaves unmapped are not checked.
+func (s *Sink) MappingConflicts(ctx context.Context) ([]MappingConflict, error) {
+	var conflicts []MappingConflict
+	templates := s.indexTemplates()
+	for _, docType := range sortedKeys(templates) {
+		var template struct {
+			Template struct {
+				Mappings mapping `json:"mappings"`
+			} `json:"template"`
+		}
+		if err := json.Unmarshal([]byte(templates[docType]), &template); err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", docType, err)
+		}
+		expected := template.Template.Mappings.fieldTypes()
+
+		live, err := s.liveMappings(ctx, fmt.Sprintf("%s-%s-*", s.indexPrefix, docType))
+		if err != nil {
+			return nil, fmt.Errorf("reading %s mappings: %w", docType, err)
+		}
+
+		found := make(map[[2]string]*MappingConflict)
+		for _, index := range sortedKeys(live) {
+			actual := live[index].fieldTypes()
+			for field, want := range expected {
+				got, ok := actual[field]
+				if !ok || got == want {
+					continue
+				}
+				key := [2]string{field, got}
+				if found[key] == nil {
+					found[key] = &MappingConflict{DocType: docType, Field: field, Expected: want, Actual: got}
+				}
+				found[key].Indices = append(found[key].Indices, index)
+			}
+		}
+		keys := make([][2]string, 0, len(found))
+		for key := range found {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i][0] < keys[j][0] || (keys[i][0] == keys[j][0] && keys[i][1] < keys[j][1])
+		})
+		for _, key := range keys {
+			conflicts = append(conflicts, *found[key])
+		}
+	}
+	return conflicts, nil
+}
+
+// warnMappingConflicts logs every mapping conflict. Failing to read mappings
+// is logged too; the check never stops the sink from opening.
+func (s *Sink) warnMappingConflicts(ctx context.Context) {
+	conflicts, err := s.MappingConflicts(ctx)
+	if err != nil {
+		s.logger.Warn("Failed to check Elasticsearch index mappings", "error", err)
+		return
+	}
+	for _, conflict := range conflicts {
+		s.logger.Warn("Elasticsearch index mapping conflicts with the current template; reindex to fix",
+			"conflict", conflict.String())
+	}
+}
+
+// liveMappings returns the mappings of the indices matching pattern
+func (s *Sink) liveMappings(ctx context.Context, pattern string) (map[string]mapping, error) {
+	resp, err := s.request(ctx, http.MethodGet, "/"+pattern+"/_mapping?allow_no_indices=true&ignore_unavailable=true", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("mapping lookup failed with status %d", resp.StatusCode)
+	}
+
+	var response map[string]struct {
+		Mappings mapping `json:"mappings"`
+	}
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &response); err != nil {
+		return nil, fmt.Errorf("decoding mapping lookup: %w", err)
+	}
+	mappings := make(map[string]mapping, len(response))
+	for index, entry := range response {
+		mappings[index] = entry.Mappings
+	}
+	return mappings, nil
+}
+
+// mapping is the properties section of an Elasticsearch mapping
+type mapping struct {
+	Type       string             `json:"type"`
+	Properties map[string]mapping `json:"properties"`
+}
+
+// fieldTypes flattens a mapping to dotted field paths and their types;
+// objects are reported as "object"
+func (m mapping) fieldTypes() map[string]string {
+	types := make(map[string]string)
+	var walk func(prefix string, properties map[string]mapping)
+	walk = func(prefix string, properties map[string]mapping) {
+		for name, field := range properties {
+			path := prefix + name
+			fieldType := field.Type
+			if fieldType == "" && field.Properties != nil {
+				fieldType = "object"
+			}
+			types[path] = fieldType
+			walk(path+".", field.Properties)
+		}
+	}
+	walk("", m.Properties)
+	return types
+}
+
+// request sends an authorized request to the cluster
+func (s *Sink) request(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.url+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	s.authorize(req)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	return resp, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,111 @@
+package elasticsearch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// ValidateOnly makes the sink index documents into throwaway indices named
+// <prefix>-validate-<type>, created with the same mappings as the templates
+// and deleted on Close. Bulk item errors then report documents the
+// production mappings would reject, without touching real indices or
+// templates.
+func (s *Sink) ValidateOnly() {
+	s.validateOnly = true
+	s.validation = make(map[string]bool)
+}
+
+// validationIndex returns the validation index for a document type
+func (s *Sink) validationIndex(docType string) string {
+	return fmt.Sprintf("%s-validate-%s", s.indexPrefix, docType)
+}
+
+// ensureValidationIndices creates the validation index of each document type
+// in docs that has not been created yet
+func (s *Sink) ensureValidationIndices(ctx context.Context, docs []model.Doc) error {
+	s.validationMu.Lock()
+	defer s.validationMu.Unlock()
+
+	for _, doc := range docs {
+		if s.validation[doc.Type] {
+			continue
+		}
+		if err := s.createValidationIndex(ctx, doc.Type); err != nil {
+			return fmt.Errorf("creating validation index for %s: %w", doc.Type, err)
+		}
+		s.validation[doc.Type] = true
+	}
+	return nil
+}
+
+// createValidationIndex creates a document type's validation index with its
+// template's mappings; types without a template get dynamic mappings. An
+// index left behind by an earlier run is reused.
+func (s *Sink) createValidationIndex(ctx context.Context, docType string) error {
+	body := []byte(`{}`)
+	if template, ok := s.indexTemplates()[docType]; ok {
+		var parsed struct {
+			Template struct {
+				Mappings json.RawMessage `json:"mappings"`
+			} `json:"template"`
+		}
+		if err := json.Unmarshal([]byte(template), &parsed); err != nil {
+			return fmt.Errorf("parsing template: %w", err)
+		}
+		encoded, err := json.Marshal(map[string]json.RawMessage{"mappings": parsed.Template.Mappings})
+		if err != nil {
+			return fmt.Errorf("encoding mappings: %w", err)
+		}
+		body = encoded
+	}
+
+	resp, err := s.request(ctx, http.MethodPut, "/"+s.validationIndex(docType), body)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusBadRequest && strings.Contains(string(data), "resource_already_exists_exception") {
+		return nil
+	}
+	return fmt.Errorf("index creation failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// deleteValidationIndices removes the validation indices this sink created
+func (s *Sink) deleteValidationIndices(ctx context.Context) error {
+	s.validationMu.Lock()
+	defer s.validationMu.Unlock()
+
+	docTypes := make([]string, 0, len(s.validation))
+	for docType := range s.validation {
+		docTypes = append(docTypes, docType)
+	}
+	sort.Strings(docTypes)
+
+	for _, docType := range docTypes {
+		index := s.validationIndex(docType)
+		resp, err := s.request(ctx, http.MethodDelete, "/"+index, nil)
+		if err != nil {
+			return fmt.Errorf("deleting validation index %s: %w", index, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+			return fmt.Errorf("deleting validation index %s failed with status %d", index, resp.StatusCode)
+		}
+		delete(s.validation, docType)
+	}
+	return nil
+}
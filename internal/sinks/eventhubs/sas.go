@@ -0,0 +1,72 @@
+package eventhubs
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sasTokenLifetime is how long a generated shared access signature is valid
+const sasTokenLifetime = time.Hour
+
+// ConnectionString holds the parts of an Event Hubs connection string
+type ConnectionString struct {
+	// Endpoint is the namespace URL, e.g. https://example.servicebus.windows.net
+	Endpoint string
+	KeyName  string
+	Key      string
+	// EventHub is the EntityPath, when the string is scoped to one event hub
+	EventHub string
+}
+
+// ParseConnectionString parses an Event Hubs connection string of the form
+// Endpoint=sb://<namespace>.servicebus.windows.net/;SharedAccessKeyName=<name>;SharedAccessKey=<key>[;EntityPath=<hub>]
+func ParseConnectionString(value string) (ConnectionString, error) {
+	var parsed ConnectionString
+	for _, part := range strings.Split(value, ";") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return ConnectionString{}, fmt.Errorf("invalid connection string part %q", part)
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "endpoint":
+			endpoint, err := url.Parse(strings.TrimSpace(val))
+			if err != nil || endpoint.Host == "" {
+				return ConnectionString{}, fmt.Errorf("invalid endpoint %q", val)
+			}
+			parsed.Endpoint = "https://" + endpoint.Host
+		case "sharedaccesskeyname":
+			parsed.KeyName = val
+		case "sharedaccesskey":
+			parsed.Key = val
+		case "entitypath":
+			parsed.EventHub = val
+		}
+	}
+	if parsed.Endpoint == "" || parsed.KeyName == "" || parsed.Key == "" {
+		return ConnectionString{}, fmt.Errorf("connection string needs Endpoint, SharedAccessKeyName and SharedAccessKey")
+	}
+	return parsed, nil
+}
+
+// sasToken returns a shared access signature authorizing requests to
+// resource until now plus sasTokenLifetime
+func sasToken(resource, keyName, key string, now time.Time) string {
+	encoded := url.QueryEscape(strings.ToLower(resource))
+	expiry := strconv.FormatInt(now.Add(sasTokenLifetime).Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(encoded + "\n" + expiry))
+	signature := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return fmt.Sprintf("SharedAccessSignature sr=%s&sig=%s&se=%s&skn=%s",
+		encoded, url.QueryEscape(signature), expiry, url.QueryEscape(keyName))
+}
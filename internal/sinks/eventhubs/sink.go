@@ -0,0 +1,208 @@
+package eventhubs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/connstats"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// maxBatchBytes is the send limit for a standard-tier event hub; the JSON
+// envelope is counted, so batches stay a little under the service's limit
+const maxBatchBytes = 1000 * 1000
+
+// defaultThrottleBackoff is used when Event Hubs reports it is busy without
+// saying how long to wait
+const defaultThrottleBackoff = 10 * time.Second
+
+// Sink sends canonical documents to an Azure event hub over the REST batch
+// API. Each document is one event partitioned by thermostat ID, so a
+// thermostat's documents stay ordered within a partition.
+type Sink struct {
+	conns      connstats.Counter
+	client     *http.Client
+	connection ConnectionString
+	resource   string
+	now        func() time.Time
+}
+
+// NewSink creates an Event Hubs sink. eventHub overrides the connection
+// string's EntityPath when set.
+func NewSink(connection ConnectionString, eventHub string) *Sink {
+	if eventHub != "" {
+		connection.EventHub = eventHub
+	}
+	s := &Sink{
+		connection: connection,
+		resource:   strings.TrimSuffix(connection.Endpoint, "/") + "/" + connection.EventHub,
+		now:        time.Now,
+	}
+	s.client = s.conns.Client(30 * time.Second)
+	return s
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "eventhubs",
+		Version:     "1.0.0",
+		Description: "Azure Event Hubs batches partitioned by thermostat",
+	}
+}
+
+// Open checks that an event hub is configured; credentials are first used
+// by the initial write
+func (s *Sink) Open(ctx context.Context) error {
+	if s.connection.EventHub == "" {
+		return fmt.Errorf("no event hub configured: set event_hub or EntityPath in the connection string")
+	}
+	return nil
+}
+
+// IdempotencyKeyProperty is the application property carrying each event's
+// document ID. Event Hubs stores every event it is sent, so a batch retried
+// after a lost response is stored twice; consumers that need each document
+// once drop events whose key they have already processed.
+const IdempotencyKeyProperty = "Idempotency-Key"
+
+// event is one entry of a batch send
+type event struct {
+	Body             string            `json:"Body"`
+	UserProperties   map[string]string `json:"UserProperties"`
+	BrokerProperties brokerProperties  `json:"BrokerProperties"`
+}
+
+type brokerProperties struct {
+	PartitionKey string `json:"PartitionKey"`
+}
+
+// Write sends documents in batches of up to about 1 MB. A batch is accepted
+// or rejected as a whole; a rejected batch fails the write, and busy
+// responses become a retry.ThrottledError.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+
+	var batch []event
+	batchBytes := 2 // enclosing brackets
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		sent, err := s.send(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.SuccessCount += len(batch)
+		result.PayloadBytes += int64(sent)
+		result.SentBytes += int64(sent)
+		batch, batchBytes = nil, 2
+		return nil
+	}
+
+	for _, doc := range docs {
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: marshaling: %v", doc.ID, err))
+			continue
+		}
+		entry := event{
+			Body:             string(data),
+			UserProperties:   map[string]string{IdempotencyKeyProperty: doc.ID},
+			BrokerProperties: brokerProperties{PartitionKey: partitionKey(doc, data)},
+		}
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: marshaling event: %v", doc.ID, err))
+			continue
+		}
+		size := len(encoded) + 1 // separating comma
+		if size+2 > maxBatchBytes {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %d bytes exceeds the batch limit", doc.ID, size))
+			continue
+		}
+		if batchBytes+size > maxBatchBytes {
+			if err := flush(); err != nil {
+				return model.WriteResult{}, err
+			}
+		}
+		batch = append(batch, entry)
+		batchBytes += size
+	}
+	if err := flush(); err != nil {
+		return model.WriteResult{}, err
+	}
+	return result, nil
+}
+
+// send posts one batch of events and returns the size of the request body
+func (s *Sink) send(ctx context.Context, batch []event) (int, error) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling batch: %w", err)
+	}
+
+	endpoint := s.resource + "/messages?" + url.Values{"timeout": {"60"}, "api-version": {"2014-01"}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("creating send request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/vnd.microsoft.servicebus.json")
+	req.Header.Set("Authorization", sasToken(s.resource, s.connection.KeyName, s.connection.Key, s.now()))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("executing send request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return len(body), nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		retryAfter := retry.RetryAfterFromResponse(resp)
+		if retryAfter == 0 {
+			retryAfter = defaultThrottleBackoff
+		}
+		return 0, fmt.Errorf("batch rejected: %w", retry.NewThrottledError(resp.StatusCode, retryAfter))
+	default:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return 0, fmt.Errorf("sending batch failed with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+}
+
+// partitionKey returns the document's thermostat ID, or its type for
+// documents that belong to no thermostat
+func partitionKey(doc model.Doc, data []byte) string {
+	var keyed struct {
+		ThermostatID string `json:"thermostat_id"`
+	}
+	if err := json.Unmarshal(data, &keyed); err == nil && keyed.ThermostatID != "" {
+		return keyed.ThermostatID
+	}
+	return doc.Type
+}
+
+// Close releases idle keep-alive connections
+func (s *Sink) Close(ctx context.Context) error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// OpenConnections returns the number of connections the sink holds open
+func (s *Sink) OpenConnections() int {
+	return s.conns.Open()
+}
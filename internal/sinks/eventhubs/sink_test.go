@@ -0,0 +1,221 @@
+package eventhubs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestParseConnectionString(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    ConnectionString
+		wantErr bool
+	}{
+		{
+			name:  "scoped to an event hub",
+			value: "Endpoint=sb://home.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=abc=;EntityPath=telemetry",
+			want:  ConnectionString{Endpoint: "https://home.servicebus.windows.net", KeyName: "send", Key: "abc=", EventHub: "telemetry"},
+		},
+		{
+			name:  "namespace level",
+			value: "Endpoint=sb://home.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=abc",
+			want:  ConnectionString{Endpoint: "https://home.servicebus.windows.net", KeyName: "send", Key: "abc"},
+		},
+		{
+			name:    "missing key",
+			value:   "Endpoint=sb://home.servicebus.windows.net/;SharedAccessKeyName=send",
+			wantErr: true,
+		},
+		{
+			name:    "malformed part",
+			value:   "Endpoint",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConnectionString(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSASToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	token := sasToken("https://home.servicebus.windows.net/telemetry", "send", "key", now)
+
+	for _, part := range []string{
+		"SharedAccessSignature sr=https%3A%2F%2Fhome.servicebus.windows.net%2Ftelemetry&",
+		"&se=1700003600&",
+		"&skn=send",
+	} {
+		if !strings.Contains(token, part) {
+			t.Errorf("Expected token to contain %q, got %s", part, token)
+		}
+	}
+	if token != sasToken("https://home.servicebus.windows.net/telemetry", "send", "key", now) {
+		t.Error("Expected tokens to be deterministic")
+	}
+	if token == sasToken("https://home.servicebus.windows.net/telemetry", "send", "other", now) {
+		t.Error("Expected the signature to depend on the key")
+	}
+}
+
+func TestSinkWrite(t *testing.T) {
+	ctx := context.Background()
+	large := strings.Repeat("x", 600*1000)
+
+	tests := []struct {
+		name      string
+		docs      []model.Doc
+		status    int
+		success   int
+		errors    int
+		batches   []int
+		keys      []string
+		throttled bool
+	}{
+		{
+			name: "partitioned by thermostat",
+			docs: []model.Doc{
+				{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+				{ID: "b", Type: "transition", Body: map[string]any{"thermostat_id": "t2"}},
+				{ID: "c", Type: "summary", Body: map[string]any{"count": 1}},
+			},
+			success: 3,
+			batches: []int{3},
+			keys:    []string{"t1", "t2", "summary"},
+		},
+		{
+			name: "batches split at the size limit",
+			docs: []model.Doc{
+				{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1", "pad": large}},
+				{ID: "b", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1", "pad": large}},
+			},
+			success: 2,
+			batches: []int{1, 1},
+		},
+		{
+			name:    "oversized documents are counted as errors",
+			docs:    []model.Doc{{ID: "a", Type: "runtime_5m", Body: map[string]any{"pad": large + large}}},
+			errors:  1,
+			batches: []int{},
+		},
+		{
+			name:      "server busy throttles",
+			docs:      []model.Doc{{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}}},
+			status:    http.StatusServiceUnavailable,
+			throttled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var batches [][]event
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/telemetry/messages" || !strings.HasPrefix(r.Header.Get("Authorization"), "SharedAccessSignature ") {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+					return
+				}
+				var batch []event
+				if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				batches = append(batches, batch)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			sink := NewSink(ConnectionString{Endpoint: server.URL, KeyName: "send", Key: "key"}, "telemetry")
+			if err := sink.Open(ctx); err != nil {
+				t.Fatalf("Failed to open: %v", err)
+			}
+
+			result, err := sink.Write(ctx, tt.docs)
+			if tt.throttled {
+				var throttled *retry.ThrottledError
+				if !errors.As(err, &throttled) {
+					t.Fatalf("Expected a throttled error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			if result.SuccessCount != tt.success || result.ErrorCount != tt.errors {
+				t.Errorf("Expected %d successes and %d errors, got %+v", tt.success, tt.errors, result)
+			}
+			if len(batches) != len(tt.batches) {
+				t.Fatalf("Expected %d batches, got %d", len(tt.batches), len(batches))
+			}
+			for i, size := range tt.batches {
+				if len(batches[i]) != size {
+					t.Errorf("Expected batch %d to carry %d events, got %d", i, size, len(batches[i]))
+				}
+			}
+			for i, key := range tt.keys {
+				if got := batches[0][i].BrokerProperties.PartitionKey; got != key {
+					t.Errorf("Expected partition key %s for event %d, got %s", key, i, got)
+				}
+				if got := batches[0][i].UserProperties[IdempotencyKeyProperty]; got != tt.docs[i].ID {
+					t.Errorf("Expected idempotency key %s for event %d, got %s", tt.docs[i].ID, i, got)
+				}
+			}
+		})
+	}
+
+	t.Run("open requires an event hub", func(t *testing.T) {
+		sink := NewSink(ConnectionString{Endpoint: "https://home.servicebus.windows.net", KeyName: "send", Key: "key"}, "")
+		if err := sink.Open(ctx); err == nil {
+			t.Error("Expected an error without an event hub")
+		}
+	})
+}
+
+func TestSinkConformance(t *testing.T) {
+	// Event Hubs does not deduplicate events, so stored counts are not checked
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+			}))
+			t.Cleanup(server.Close)
+			return NewSink(ConnectionString{Endpoint: server.URL, KeyName: "send", Key: "key"}, "telemetry")
+		},
+		Reject:              sinktest.Unencodable,
+		RequireCancellation: true,
+	})
+}
@@ -0,0 +1,320 @@
+package homeassistant
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/connstats"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// DefaultEntityPattern names entities after the thermostat and the reading
+const DefaultEntityPattern = "ttr_{thermostat}_{field}"
+
+// defaultThrottleBackoff is used when Home Assistant reports it is busy
+// without saying how long to wait
+const defaultThrottleBackoff = 10 * time.Second
+
+// nonSlug matches runs of characters not allowed in an entity object ID
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Sink pushes each thermostat's latest runtime reading to Home Assistant's
+// REST API as sensor entities. Only current state is exported: documents
+// older than the last reading pushed for a thermostat are accepted without
+// a request, so backfills do not overwrite live state with history.
+type Sink struct {
+	conns   connstats.Counter
+	client  *http.Client
+	baseURL string
+	token   string
+	pattern string
+
+	mu     sync.Mutex
+	pushed map[string]time.Time // thermostat ID to the latest reading pushed
+}
+
+// NewSink creates a Home Assistant sink. baseURL is the Home Assistant
+// address (e.g. http://homeassistant.local:8123), token a long-lived access
+// token, and pattern the entity object ID pattern; an empty pattern uses
+// DefaultEntityPattern.
+func NewSink(baseURL, token, pattern string) *Sink {
+	if pattern == "" {
+		pattern = DefaultEntityPattern
+	}
+	s := &Sink{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		pattern: pattern,
+		pushed:  make(map[string]time.Time),
+	}
+	s.client = s.conns.Client(30 * time.Second)
+	return s
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "homeassistant",
+		Version:     "1.0.0",
+		Description: "Home Assistant sensor entities for current thermostat state",
+	}
+}
+
+// Open checks the configuration; the token is first used by the initial write
+func (s *Sink) Open(ctx context.Context) error {
+	if _, err := url.ParseRequestURI(s.baseURL); err != nil {
+		return fmt.Errorf("invalid Home Assistant URL %q: %w", s.baseURL, err)
+	}
+	if s.token == "" {
+		return fmt.Errorf("no Home Assistant access token configured")
+	}
+	if !strings.Contains(s.pattern, "{field}") {
+		return fmt.Errorf("entity pattern %q must contain {field}", s.pattern)
+	}
+	return nil
+}
+
+// reading is the part of a runtime_5m or runtime_live document exported as entities
+type reading struct {
+	ThermostatID    string          `json:"thermostat_id"`
+	ThermostatName  string          `json:"thermostat_name"`
+	EventTime       time.Time       `json:"event_time"`
+	Mode            string          `json:"mode"`
+	Climate         string          `json:"climate"`
+	SetHeatC        *float64        `json:"set_heat_c"`
+	SetCoolC        *float64        `json:"set_cool_c"`
+	AvgTempC        *float64        `json:"avg_temp_c"`
+	TempC           *float64        `json:"temp_c"`
+	Humidity        *int            `json:"humidity_pct"`
+	OutdoorTempC    *float64        `json:"outdoor_temp_c"`
+	OutdoorHumidity *int            `json:"outdoor_humidity_pct"`
+	Equipment       map[string]bool `json:"equip"`
+	Tags            map[string]any  `json:"tags"`
+}
+
+// State is the body of a Home Assistant state update
+type State struct {
+	State      string         `json:"state"`
+	Attributes map[string]any `json:"attributes"`
+}
+
+// Write pushes the latest runtime reading of each thermostat in docs. Other
+// document types and superseded readings are accepted without a request. A
+// failed request fails the write; busy responses become a
+// retry.ThrottledError.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+
+	latest := make(map[string]reading)
+	var order []string
+	for _, doc := range docs {
+		if doc.Type != "runtime_5m" && doc.Type != "runtime_live" {
+			result.SuccessCount++
+			continue
+		}
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: marshaling: %v", doc.ID, err))
+			continue
+		}
+		var r reading
+		if err := json.Unmarshal(data, &r); err != nil || r.ThermostatID == "" {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: not a thermostat reading", doc.ID))
+			continue
+		}
+		result.SuccessCount++
+		current, seen := latest[r.ThermostatID]
+		if !seen {
+			order = append(order, r.ThermostatID)
+		}
+		if !seen || !r.EventTime.Before(current.EventTime) {
+			latest[r.ThermostatID] = r
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, thermostatID := range order {
+		r := latest[thermostatID]
+		if pushed, ok := s.pushed[thermostatID]; ok && !r.EventTime.After(pushed) {
+			continue
+		}
+		for _, entity := range s.entities(r) {
+			if err := s.push(ctx, entity.id, entity.state); err != nil {
+				return model.WriteResult{}, err
+			}
+		}
+		s.pushed[thermostatID] = r.EventTime
+	}
+	return result, nil
+}
+
+// entity is one Home Assistant entity derived from a reading
+type entity struct {
+	id    string
+	state State
+}
+
+// entities returns the entities describing r: sensors for temperatures,
+// setpoints, humidity and mode, and a binary sensor per piece of equipment.
+// The thermostat's tags are added to each entity's attributes.
+func (s *Sink) entities(r reading) []entity {
+	name := r.ThermostatName
+	if name == "" {
+		name = r.ThermostatID
+	}
+	updated := r.EventTime.UTC().Format(time.RFC3339)
+
+	var entities []entity
+	add := func(domain, field, label, state string, attributes map[string]any) {
+		attributes["friendly_name"] = name + " " + label
+		attributes["thermostat_id"] = r.ThermostatID
+		attributes["event_time"] = updated
+		if len(r.Tags) > 0 {
+			attributes["tags"] = r.Tags
+		}
+		entities = append(entities, entity{
+			id:    domain + "." + s.objectID(r, field),
+			state: State{State: state, Attributes: attributes},
+		})
+	}
+	temperature := func(field, label string, value *float64) {
+		if value != nil {
+			add("sensor", field, label, fmt.Sprintf("%.1f", *value), map[string]any{
+				"unit_of_measurement": "°C",
+				"device_class":        "temperature",
+				"state_class":         "measurement",
+			})
+		}
+	}
+	humidity := func(field, label string, value *int) {
+		if value != nil {
+			add("sensor", field, label, fmt.Sprintf("%d", *value), map[string]any{
+				"unit_of_measurement": "%",
+				"device_class":        "humidity",
+				"state_class":         "measurement",
+			})
+		}
+	}
+
+	indoor := r.TempC
+	if indoor == nil {
+		indoor = r.AvgTempC
+	}
+	temperature("temperature", "Temperature", indoor)
+	temperature("heat_setpoint", "Heat Setpoint", r.SetHeatC)
+	temperature("cool_setpoint", "Cool Setpoint", r.SetCoolC)
+	humidity("humidity", "Humidity", r.Humidity)
+	temperature("outdoor_temperature", "Outdoor Temperature", r.OutdoorTempC)
+	humidity("outdoor_humidity", "Outdoor Humidity", r.OutdoorHumidity)
+	if r.Mode != "" {
+		add("sensor", "mode", "Mode", r.Mode, map[string]any{})
+	}
+	if r.Climate != "" {
+		add("sensor", "climate", "Climate", r.Climate, map[string]any{})
+	}
+
+	equipment := make([]string, 0, len(r.Equipment))
+	for name := range r.Equipment {
+		equipment = append(equipment, name)
+	}
+	sort.Strings(equipment)
+	for _, name := range equipment {
+		state := "off"
+		if r.Equipment[name] {
+			state = "on"
+		}
+		add("binary_sensor", slug(name), name, state, map[string]any{"device_class": "running"})
+	}
+	return entities
+}
+
+// objectID expands the entity pattern for a reading and field
+func (s *Sink) objectID(r reading, field string) string {
+	thermostat := slug(r.ThermostatName)
+	if thermostat == "" {
+		thermostat = slug(r.ThermostatID)
+	}
+	id := strings.NewReplacer(
+		"{thermostat}", thermostat,
+		"{thermostat_id}", slug(r.ThermostatID),
+		"{field}", field,
+	).Replace(s.pattern)
+	return slug(id)
+}
+
+// slug lowercases s and replaces characters Home Assistant does not allow in
+// object IDs with underscores, splitting camel case words
+func slug(s string) string {
+	var b strings.Builder
+	var prev rune
+	for _, c := range s {
+		if c >= 'A' && c <= 'Z' && (prev >= 'a' && prev <= 'z' || prev >= '0' && prev <= '9') {
+			b.WriteByte('_')
+		}
+		b.WriteRune(c)
+		prev = c
+	}
+	return strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(b.String()), "_"), "_")
+}
+
+// push sets the state of one entity
+func (s *Sink) push(ctx context.Context, entityID string, state State) error {
+	body, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshaling state of %s: %w", entityID, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/api/states/"+entityID, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating state request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.token)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing state request: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		retryAfter := retry.RetryAfterFromResponse(resp)
+		if retryAfter == 0 {
+			retryAfter = defaultThrottleBackoff
+		}
+		return fmt.Errorf("state of %s rejected: %w", entityID, retry.NewThrottledError(resp.StatusCode, retryAfter))
+	default:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("setting state of %s failed with HTTP %d: %s", entityID, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+}
+
+// Close releases idle keep-alive connections
+func (s *Sink) Close(ctx context.Context) error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// OpenConnections returns the number of connections the sink holds open
+func (s *Sink) OpenConnections() int {
+	return s.conns.Open()
+}
@@ -0,0 +1,197 @@
+package homeassistant
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "Main Floor", want: "main_floor"},
+		{value: "compHeat1", want: "comp_heat1"},
+		{value: "HVAC", want: "hvac"},
+		{value: "  Upstairs (Ecobee)  ", want: "upstairs_ecobee"},
+	}
+	for _, tt := range tests {
+		if got := slug(tt.value); got != tt.want {
+			t.Errorf("Expected slug of %q to be %q, got %q", tt.value, tt.want, got)
+		}
+	}
+}
+
+// stateServer records the states pushed to it by entity ID
+type stateServer struct {
+	states   map[string]State
+	requests int
+	status   int
+}
+
+func (s *stateServer) start(t *testing.T) *httptest.Server {
+	t.Helper()
+	s.states = make(map[string]State)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests++
+		if r.Header.Get("Authorization") != "Bearer token" || !strings.HasPrefix(r.URL.Path, "/api/states/") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if s.status != 0 {
+			w.WriteHeader(s.status)
+			return
+		}
+		var state State
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.states[strings.TrimPrefix(r.URL.Path, "/api/states/")] = state
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSinkWrite(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	temp := func(v float64) *float64 { return &v }
+	humidity := 41
+
+	states := &stateServer{}
+	server := states.start(t)
+	sink := NewSink(server.URL+"/", "token", "")
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open: %v", err)
+	}
+
+	docs := []model.Doc{
+		{ID: "a", Type: "runtime_5m", Body: &model.Runtime5m{
+			Type: "runtime_5m", ThermostatID: "t1", ThermostatName: "Main Floor", EventTime: start,
+			Mode: "heat", AvgTempC: temp(20.5), SetHeatC: temp(21),
+		}},
+		{ID: "b", Type: "runtime_live", Body: &model.RuntimeLive{
+			Type: "runtime_live", ThermostatID: "t1", ThermostatName: "Main Floor", EventTime: start.Add(3 * time.Minute),
+			Mode: "heat", TempC: temp(20.8), SetHeatC: temp(21), Humidity: &humidity,
+			Equipment:  map[string]bool{"compHeat1": true, "fan": false},
+			Provenance: model.Provenance{Tags: map[string]string{"floor": "main"}},
+		}},
+		{ID: "c", Type: "transition", Body: map[string]any{"thermostat_id": "t1"}},
+	}
+	result, err := sink.Write(ctx, docs)
+	if err != nil {
+		t.Fatalf("Failed to write: %v", err)
+	}
+	if result.SuccessCount != 3 || result.ErrorCount != 0 {
+		t.Errorf("Expected 3 successes, got %+v", result)
+	}
+
+	expected := map[string]string{
+		"sensor.ttr_main_floor_temperature":       "20.8",
+		"sensor.ttr_main_floor_heat_setpoint":     "21.0",
+		"sensor.ttr_main_floor_humidity":          "41",
+		"sensor.ttr_main_floor_mode":              "heat",
+		"binary_sensor.ttr_main_floor_comp_heat1": "on",
+		"binary_sensor.ttr_main_floor_fan":        "off",
+	}
+	if len(states.states) != len(expected) {
+		t.Errorf("Expected %d entities, got %v", len(expected), states.states)
+	}
+	for id, value := range expected {
+		if got := states.states[id].State; got != value {
+			t.Errorf("Expected %s to be %q, got %q", id, value, got)
+		}
+	}
+	if attributes := states.states["sensor.ttr_main_floor_temperature"].Attributes; attributes["unit_of_measurement"] != "°C" || attributes["friendly_name"] != "Main Floor Temperature" {
+		t.Errorf("Expected temperature attributes, got %v", attributes)
+	}
+	if tags, ok := states.states["sensor.ttr_main_floor_mode"].Attributes["tags"].(map[string]any); !ok || tags["floor"] != "main" {
+		t.Errorf("Expected the thermostat's tags as an attribute, got %v", states.states["sensor.ttr_main_floor_mode"].Attributes)
+	}
+
+	t.Run("older readings are not pushed", func(t *testing.T) {
+		requests := states.requests
+		if _, err := sink.Write(ctx, docs[:1]); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if states.requests != requests {
+			t.Errorf("Expected no requests for an older reading, got %d", states.requests-requests)
+		}
+	})
+
+	t.Run("entity pattern", func(t *testing.T) {
+		states := &stateServer{}
+		server := states.start(t)
+		sink := NewSink(server.URL, "token", "thermostat_{thermostat_id}_{field}")
+		if _, err := sink.Write(ctx, docs[:1]); err != nil {
+			t.Fatalf("Failed to write: %v", err)
+		}
+		if _, ok := states.states["sensor.thermostat_t1_temperature"]; !ok {
+			t.Errorf("Expected entities named by the pattern, got %v", states.states)
+		}
+	})
+
+	t.Run("server busy throttles", func(t *testing.T) {
+		states := &stateServer{status: http.StatusServiceUnavailable}
+		server := states.start(t)
+		_, err := NewSink(server.URL, "token", "").Write(ctx, docs[:1])
+		var throttled *retry.ThrottledError
+		if !errors.As(err, &throttled) {
+			t.Fatalf("Expected a throttled error, got %v", err)
+		}
+	})
+}
+
+func TestSinkOpen(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		token   string
+		pattern string
+	}{
+		{name: "invalid URL", url: "homeassistant", token: "token"},
+		{name: "missing token", url: "http://homeassistant.local:8123"},
+		{name: "pattern without field", url: "http://homeassistant.local:8123", token: "token", pattern: "ttr_{thermostat}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := NewSink(tt.url, tt.token, tt.pattern).Open(context.Background()); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	// Only the latest state is kept, so stored counts are not checked
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			t.Cleanup(server.Close)
+			return NewSink(server.URL, "token", "")
+		},
+		Reject:              sinktest.Unencodable,
+		RequireCancellation: true,
+	})
+}
@@ -0,0 +1,256 @@
+package kinesis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/connstats"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// PutRecords limits
+const (
+	maxBatchRecords = 500
+	maxBatchBytes   = 5 << 20
+	maxRecordBytes  = 1 << 20
+)
+
+// defaultThrottleBackoff is used when Kinesis rejects a request for
+// exceeding stream throughput
+const defaultThrottleBackoff = 10 * time.Second
+
+// Options configures the Kinesis sink
+type Options struct {
+	// Stream is the Kinesis data stream name
+	Stream string
+	// Region is the AWS region of the stream
+	Region string
+	// Endpoint overrides the regional endpoint, e.g. for LocalStack
+	Endpoint    string
+	Credentials Credentials
+}
+
+// Sink writes canonical documents to an AWS Kinesis data stream with
+// PutRecords. Each document is one JSON record partitioned by thermostat ID,
+// so a thermostat's documents stay ordered within a shard.
+type Sink struct {
+	conns    connstats.Counter
+	client   *http.Client
+	stream   string
+	endpoint string
+	signer   signer
+	now      func() time.Time
+}
+
+// NewSink creates a Kinesis sink
+func NewSink(options Options) *Sink {
+	endpoint := options.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://kinesis.%s.amazonaws.com", options.Region)
+	}
+	s := &Sink{
+		stream:   options.Stream,
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		signer:   signer{credentials: options.Credentials, region: options.Region, service: "kinesis"},
+		now:      time.Now,
+	}
+	s.client = s.conns.Client(30 * time.Second)
+	return s
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "kinesis",
+		Version:     "1.0.0",
+		Description: "AWS Kinesis data stream partitioned by thermostat",
+	}
+}
+
+// Open checks that the stream exists and the credentials can reach it
+func (s *Sink) Open(ctx context.Context) error {
+	resp, err := s.call(ctx, "DescribeStreamSummary", map[string]string{"StreamName": s.stream})
+	if err != nil {
+		return fmt.Errorf("describing stream %s: %w", s.stream, err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// putRecord is one record of a PutRecords request
+type putRecord struct {
+	Data         []byte `json:"Data"`
+	PartitionKey string `json:"PartitionKey"`
+}
+
+// Write sends documents in PutRecords batches of up to 500 records and 5 MiB.
+// Records Kinesis rejects individually are counted as errors; a request
+// rejected for throughput stops the write with a retry.ThrottledError.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+
+	var batch []putRecord
+	var batchIDs []string
+	batchBytes := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.putRecords(ctx, batch, batchIDs, &result); err != nil {
+			return err
+		}
+		batch, batchIDs, batchBytes = nil, nil, 0
+		return nil
+	}
+
+	for _, doc := range docs {
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: marshaling: %v", doc.ID, err))
+			continue
+		}
+		record := putRecord{Data: data, PartitionKey: partitionKey(doc, data)}
+		size := len(data) + len(record.PartitionKey)
+		if size > maxRecordBytes {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %d bytes exceeds the 1 MiB record limit", doc.ID, size))
+			continue
+		}
+		if len(batch) == maxBatchRecords || batchBytes+size > maxBatchBytes {
+			if err := flush(); err != nil {
+				return model.WriteResult{}, err
+			}
+		}
+		batch = append(batch, record)
+		batchIDs = append(batchIDs, doc.ID)
+		batchBytes += size
+	}
+	if err := flush(); err != nil {
+		return model.WriteResult{}, err
+	}
+	return result, nil
+}
+
+// putRecords sends one batch and adds its per-record outcomes to result
+func (s *Sink) putRecords(ctx context.Context, records []putRecord, ids []string, result *model.WriteResult) error {
+	resp, err := s.call(ctx, "PutRecords", map[string]any{
+		"StreamName": s.stream,
+		"Records":    records,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var response struct {
+		FailedRecordCount int `json:"FailedRecordCount"`
+		Records           []struct {
+			SequenceNumber string `json:"SequenceNumber"`
+			ErrorCode      string `json:"ErrorCode"`
+			ErrorMessage   string `json:"ErrorMessage"`
+		} `json:"Records"`
+	}
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &response); err != nil {
+		return fmt.Errorf("decoding PutRecords response: %w", err)
+	}
+	if len(response.Records) != len(records) {
+		return fmt.Errorf("PutRecords returned %d results for %d records", len(response.Records), len(records))
+	}
+
+	for i, record := range response.Records {
+		if record.ErrorCode == "" {
+			result.SuccessCount++
+			continue
+		}
+		result.ErrorCount++
+		result.Errors = append(result.Errors, fmt.Sprintf("document %s: %s: %s", ids[i], record.ErrorCode, record.ErrorMessage))
+	}
+	return nil
+}
+
+// call sends a signed Kinesis API request and returns the response if it
+// succeeded
+func (s *Sink) call(ctx context.Context, action string, payload any) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %s request: %w", action, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Kinesis_20131202."+action)
+	s.signer.sign(req, body, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing %s request: %w", action, err)
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	return nil, responseError(action, resp)
+}
+
+// responseError describes a failed Kinesis API call. Throughput and request
+// rate rejections become a ThrottledError.
+func responseError(action string, resp *http.Response) error {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var apiError struct {
+		Type    string `json:"__type"`
+		Message string `json:"message"`
+	}
+	_ = json.Unmarshal(data, &apiError)
+
+	if resp.StatusCode == http.StatusServiceUnavailable ||
+		strings.Contains(apiError.Type, "ProvisionedThroughputExceeded") ||
+		strings.Contains(apiError.Type, "LimitExceeded") {
+		retryAfter := retry.RetryAfterFromResponse(resp)
+		if retryAfter == 0 {
+			retryAfter = defaultThrottleBackoff
+		}
+		return fmt.Errorf("%s rejected: %w", action, retry.NewThrottledError(resp.StatusCode, retryAfter))
+	}
+	if apiError.Type != "" {
+		return fmt.Errorf("%s failed with HTTP %d: %s: %s", action, resp.StatusCode, apiError.Type, apiError.Message)
+	}
+	return fmt.Errorf("%s failed with HTTP %d: %s", action, resp.StatusCode, strings.TrimSpace(string(data)))
+}
+
+// partitionKey returns the document's thermostat ID, or its type for
+// documents that belong to no thermostat
+func partitionKey(doc model.Doc, data []byte) string {
+	var keyed struct {
+		ThermostatID string `json:"thermostat_id"`
+	}
+	if err := json.Unmarshal(data, &keyed); err == nil && keyed.ThermostatID != "" {
+		return keyed.ThermostatID
+	}
+	return doc.Type
+}
+
+// Close releases idle keep-alive connections
+func (s *Sink) Close(ctx context.Context) error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// OpenConnections returns the number of connections the sink holds open
+func (s *Sink) OpenConnections() int {
+	return s.conns.Open()
+}
@@ -0,0 +1,206 @@
+package kinesis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestSignerVanillaRequest(t *testing.T) {
+	// get-vanilla from the AWS Signature Version 4 test suite
+	req, err := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := signer{
+		credentials: Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"},
+		region:      "us-east-1",
+		service:     "service",
+	}
+	s.sign(req, nil, time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != want {
+		t.Errorf("Unexpected authorization header:\n got %s\nwant %s", got, want)
+	}
+}
+
+// fakeKinesis records PutRecords requests and fails records whose data
+// contains "reject"
+type fakeKinesis struct {
+	requests   [][]putRecord
+	throttle   bool
+	authorized bool
+}
+
+func (f *fakeKinesis) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.authorized = strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/")
+	if f.throttle {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"__type":"ProvisionedThroughputExceededException","message":"Rate exceeded"}`))
+		return
+	}
+	if r.Header.Get("X-Amz-Target") != "Kinesis_20131202.PutRecords" {
+		_, _ = w.Write([]byte(`{}`))
+		return
+	}
+
+	var request struct {
+		StreamName string      `json:"StreamName"`
+		Records    []putRecord `json:"Records"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.requests = append(f.requests, request.Records)
+
+	type recordResult struct {
+		SequenceNumber string `json:"SequenceNumber,omitempty"`
+		ErrorCode      string `json:"ErrorCode,omitempty"`
+		ErrorMessage   string `json:"ErrorMessage,omitempty"`
+	}
+	results := make([]recordResult, 0, len(request.Records))
+	failed := 0
+	for _, record := range request.Records {
+		if strings.Contains(string(record.Data), "reject") {
+			failed++
+			results = append(results, recordResult{ErrorCode: "InternalFailure", ErrorMessage: "try again"})
+			continue
+		}
+		results = append(results, recordResult{SequenceNumber: "1"})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]any{"FailedRecordCount": failed, "Records": results})
+}
+
+func newTestSink(t *testing.T, handler http.Handler) *Sink {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewSink(Options{
+		Stream:      "telemetry",
+		Region:      "us-west-2",
+		Endpoint:    server.URL,
+		Credentials: Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+	})
+}
+
+func TestSinkWrite(t *testing.T) {
+	ctx := context.Background()
+
+	many := make([]model.Doc, 501)
+	for i := range many {
+		many[i] = model.Doc{ID: "d", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}}
+	}
+
+	tests := []struct {
+		name      string
+		docs      []model.Doc
+		success   int
+		errors    int
+		requests  []int
+		keys      []string
+		throttled bool
+	}{
+		{
+			name: "partitioned by thermostat",
+			docs: []model.Doc{
+				{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+				{ID: "b", Type: "transition", Body: map[string]any{"thermostat_id": "t2"}},
+				{ID: "c", Type: "summary", Body: map[string]any{"count": 1}},
+			},
+			success:  3,
+			requests: []int{3},
+			keys:     []string{"t1", "t2", "summary"},
+		},
+		{
+			name:     "batches of 500 records",
+			docs:     many,
+			success:  501,
+			requests: []int{500, 1},
+		},
+		{
+			name: "rejected records are counted as errors",
+			docs: []model.Doc{
+				{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+				{ID: "b", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1", "note": "reject"}},
+			},
+			success:  1,
+			errors:   1,
+			requests: []int{2},
+		},
+		{
+			name:      "throughput rejection throttles",
+			docs:      []model.Doc{{ID: "a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}}},
+			throttled: true,
+		},
+		{
+			name: "empty batch sends nothing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeKinesis{throttle: tt.throttled}
+			sink := newTestSink(t, fake)
+
+			result, err := sink.Write(ctx, tt.docs)
+			if tt.throttled {
+				var throttled *retry.ThrottledError
+				if !errors.As(err, &throttled) {
+					t.Fatalf("Expected a throttled error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			if result.SuccessCount != tt.success || result.ErrorCount != tt.errors {
+				t.Errorf("Expected %d successes and %d errors, got %+v", tt.success, tt.errors, result)
+			}
+			if len(fake.requests) != len(tt.requests) {
+				t.Fatalf("Expected %d requests, got %d", len(tt.requests), len(fake.requests))
+			}
+			for i, size := range tt.requests {
+				if len(fake.requests[i]) != size {
+					t.Errorf("Expected request %d to carry %d records, got %d", i, size, len(fake.requests[i]))
+				}
+			}
+			if len(tt.requests) > 0 && !fake.authorized {
+				t.Error("Expected signed requests")
+			}
+			for i, key := range tt.keys {
+				if got := fake.requests[0][i].PartitionKey; got != key {
+					t.Errorf("Expected partition key %s for record %d, got %s", key, i, got)
+				}
+			}
+		})
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	// Kinesis does not deduplicate records, so stored counts are not checked
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			return newTestSink(t, &fakeKinesis{})
+		},
+		Reject:              sinktest.Unencodable,
+		RequireCancellation: true,
+	})
+}
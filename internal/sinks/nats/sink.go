@@ -0,0 +1,264 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+const (
+	// DefaultStream is the JetStream stream documents are stored in
+	DefaultStream = "TTR"
+
+	// DefaultSubjectPrefix prefixes document subjects: <prefix>.<doc type>
+	DefaultSubjectPrefix = "ttr"
+
+	// DefaultDuplicateWindow is how long JetStream remembers document IDs;
+	// re-publishing a document inside it is acknowledged but not stored
+	DefaultDuplicateWindow = 24 * time.Hour
+
+	// embeddedStartTimeout bounds waiting for the embedded server to start
+	embeddedStartTimeout = 10 * time.Second
+)
+
+// Options configures the NATS sink
+type Options struct {
+	// URL is the NATS server to connect to; ignored when Embedded is set
+	URL string
+	// Stream is the JetStream stream name
+	Stream string
+	// SubjectPrefix prefixes every document subject
+	SubjectPrefix string
+	// MaxAge discards stored documents older than this; zero keeps them
+	MaxAge time.Duration
+	// DuplicateWindow is how long document IDs are remembered for deduplication
+	DuplicateWindow time.Duration
+	// Embedded runs a NATS server with JetStream inside the process
+	Embedded *EmbeddedOptions
+}
+
+// EmbeddedOptions configures the embedded NATS server
+type EmbeddedOptions struct {
+	// StoreDir is where JetStream persists streams
+	StoreDir string
+	// Listen is the host:port consumers connect to. Empty accepts no network
+	// connections; the sink connects in-process.
+	Listen string
+}
+
+// Sink publishes canonical documents to a NATS JetStream stream, one message
+// per document on <prefix>.<doc type>. The document ID is the message ID, so
+// JetStream drops re-published documents within the duplicate window and
+// consumers can replay the stream from any point.
+type Sink struct {
+	options Options
+
+	// mu guards the connection: /healthz opens the sink while the scheduler
+	// writes to it
+	mu     sync.Mutex
+	server *server.Server
+	conn   *nats.Conn
+	js     jetstream.JetStream
+}
+
+// NewSink creates a NATS JetStream sink
+func NewSink(options Options) *Sink {
+	if options.Stream == "" {
+		options.Stream = DefaultStream
+	}
+	if options.SubjectPrefix == "" {
+		options.SubjectPrefix = DefaultSubjectPrefix
+	}
+	if options.DuplicateWindow <= 0 {
+		options.DuplicateWindow = DefaultDuplicateWindow
+	}
+	return &Sink{options: options}
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "nats",
+		Version:     "1.0.0",
+		Description: "NATS JetStream stream of canonical documents with replay",
+	}
+}
+
+// Open starts the embedded server if configured, connects, and creates or
+// updates the stream
+func (s *Sink) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn != nil {
+		return nil
+	}
+
+	var err error
+	if s.options.Embedded != nil {
+		s.server, err = startEmbedded(s.options.Embedded)
+		if err != nil {
+			return err
+		}
+		s.conn, err = nats.Connect(s.server.ClientURL(), nats.InProcessServer(s.server))
+	} else {
+		s.conn, err = nats.Connect(s.options.URL)
+	}
+	if err != nil {
+		s.shutdown()
+		return fmt.Errorf("connecting to nats: %w", err)
+	}
+
+	s.js, err = jetstream.New(s.conn)
+	if err != nil {
+		s.shutdown()
+		return fmt.Errorf("creating jetstream context: %w", err)
+	}
+
+	_, err = s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:        s.options.Stream,
+		Description: "Thermostat telemetry canonical documents",
+		Subjects:    []string{s.options.SubjectPrefix + ".>"},
+		Storage:     jetstream.FileStorage,
+		MaxAge:      s.options.MaxAge,
+		Duplicates:  s.options.DuplicateWindow,
+	})
+	if err != nil {
+		s.shutdown()
+		return fmt.Errorf("creating stream %s: %w", s.options.Stream, err)
+	}
+	return nil
+}
+
+// startEmbedded runs a JetStream-enabled NATS server and waits for it to
+// accept connections
+func startEmbedded(options *EmbeddedOptions) (*server.Server, error) {
+	serverOptions := &server.Options{
+		ServerName: "ttr",
+		JetStream:  true,
+		StoreDir:   options.StoreDir,
+		NoSigs:     true,
+		NoLog:      true,
+	}
+	if options.Listen == "" {
+		serverOptions.DontListen = true
+	} else {
+		host, port, err := net.SplitHostPort(options.Listen)
+		if err != nil {
+			return nil, fmt.Errorf("invalid embedded listen address %q: %w", options.Listen, err)
+		}
+		serverOptions.Host = host
+		if serverOptions.Port, err = strconv.Atoi(port); err != nil {
+			return nil, fmt.Errorf("invalid embedded listen port %q: %w", port, err)
+		}
+	}
+
+	ns, err := server.NewServer(serverOptions)
+	if err != nil {
+		return nil, fmt.Errorf("creating embedded nats server: %w", err)
+	}
+	go ns.Start()
+	if !ns.ReadyForConnections(embeddedStartTimeout) {
+		ns.Shutdown()
+		return nil, fmt.Errorf("embedded nats server did not start within %s", embeddedStartTimeout)
+	}
+	return ns, nil
+}
+
+// Subject returns the subject a document type is published on
+func (s *Sink) Subject(docType string) string {
+	return s.options.SubjectPrefix + "." + subjectToken(docType)
+}
+
+// Write publishes documents asynchronously and waits for every
+// acknowledgement. Duplicates JetStream already holds count as successes.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+	if len(docs) == 0 {
+		return result, nil
+	}
+	s.mu.Lock()
+	js := s.js
+	s.mu.Unlock()
+	if js == nil {
+		return model.WriteResult{}, errors.New("nats sink is not open")
+	}
+
+	futures := make([]jetstream.PubAckFuture, 0, len(docs))
+	published := make([]model.Doc, 0, len(docs))
+	for _, doc := range docs {
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: marshaling: %v", doc.ID, err))
+			continue
+		}
+		msg := &nats.Msg{Subject: s.Subject(doc.Type), Data: data}
+		future, err := js.PublishMsgAsync(msg, jetstream.WithMsgID(doc.ID))
+		if err != nil {
+			return model.WriteResult{}, fmt.Errorf("publishing document %s: %w", doc.ID, err)
+		}
+		futures = append(futures, future)
+		published = append(published, doc)
+	}
+
+	select {
+	case <-js.PublishAsyncComplete():
+	case <-ctx.Done():
+		return model.WriteResult{}, fmt.Errorf("waiting for acknowledgements: %w", ctx.Err())
+	}
+
+	for i, future := range futures {
+		select {
+		case <-future.Ok():
+			result.SuccessCount++
+		case err := <-future.Err():
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", published[i].ID, err))
+		}
+	}
+	return result, nil
+}
+
+// subjectToken makes a document type safe to use as one subject token
+func subjectToken(docType string) string {
+	if docType == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer(".", "_", "*", "_", ">", "_", " ", "_").Replace(docType)
+}
+
+// Close disconnects and stops the embedded server. Write waits for every
+// acknowledgement, so nothing is left in flight.
+func (s *Sink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdown()
+	return nil
+}
+
+// shutdown closes the connection and stops the embedded server, if running.
+// The caller holds mu.
+func (s *Sink) shutdown() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+	s.js = nil
+	if s.server != nil {
+		s.server.Shutdown()
+		s.server.WaitForShutdown()
+		s.server = nil
+	}
+}
@@ -0,0 +1,188 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/sinktest"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func newEmbeddedSink(t *testing.T, storeDir string) *Sink {
+	t.Helper()
+	sink := NewSink(Options{Embedded: &EmbeddedOptions{StoreDir: storeDir}})
+	if err := sink.Open(context.Background()); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	return sink
+}
+
+func TestSinkWrite(t *testing.T) {
+	ctx := context.Background()
+	storeDir := t.TempDir()
+	sink := newEmbeddedSink(t, storeDir)
+
+	docs := []model.Doc{
+		{ID: "t1:2025-01-10T12:00:00Z:runtime_5m:a", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+		{ID: "t1:2025-01-10T12:05:00Z:runtime_5m:b", Type: "runtime_5m", Body: map[string]any{"thermostat_id": "t1"}},
+		{ID: "t1:2025-01-10T12:03:00Z:transition:c", Type: "transition", Body: map[string]any{"thermostat_id": "t1"}},
+	}
+
+	tests := []struct {
+		name     string
+		docs     []model.Doc
+		success  int
+		messages uint64
+	}{
+		{name: "new documents are stored", docs: docs, success: 3, messages: 3},
+		{name: "re-published documents are deduplicated", docs: docs[:2], success: 2, messages: 3},
+		{name: "empty batch", docs: nil, success: 0, messages: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := sink.Write(ctx, tt.docs)
+			if err != nil {
+				t.Fatalf("Failed to write: %v", err)
+			}
+			if result.SuccessCount != tt.success || result.ErrorCount != 0 {
+				t.Errorf("Expected %d successes and no errors, got %+v", tt.success, result)
+			}
+			stream, err := sink.js.Stream(ctx, DefaultStream)
+			if err != nil {
+				t.Fatalf("Failed to look up stream: %v", err)
+			}
+			info, err := stream.Info(ctx)
+			if err != nil {
+				t.Fatalf("Failed to read stream info: %v", err)
+			}
+			if info.State.Msgs != tt.messages {
+				t.Errorf("Expected %d messages in the stream, got %d", tt.messages, info.State.Msgs)
+			}
+		})
+	}
+
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+
+	t.Run("documents survive a restart and replay in order", func(t *testing.T) {
+		reopened := newEmbeddedSink(t, storeDir)
+		defer func() {
+			_ = reopened.Close(ctx)
+		}()
+
+		stream, err := reopened.js.Stream(ctx, DefaultStream)
+		if err != nil {
+			t.Fatalf("Failed to look up stream: %v", err)
+		}
+		consumer, err := stream.OrderedConsumer(ctx, jetstream.OrderedConsumerConfig{
+			FilterSubjects: []string{reopened.Subject("runtime_5m")},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create consumer: %v", err)
+		}
+		batch, err := consumer.Fetch(2, jetstream.FetchMaxWait(5*time.Second))
+		if err != nil {
+			t.Fatalf("Failed to fetch: %v", err)
+		}
+		var ids []string
+		for msg := range batch.Messages() {
+			var body map[string]any
+			if err := json.Unmarshal(msg.Data(), &body); err != nil {
+				t.Fatalf("Failed to decode message: %v", err)
+			}
+			if body["thermostat_id"] != "t1" {
+				t.Errorf("Unexpected message body: %s", msg.Data())
+			}
+			ids = append(ids, msg.Headers().Get(jetstream.MsgIDHeader))
+		}
+		if len(ids) != 2 || ids[0] != docs[0].ID || ids[1] != docs[1].ID {
+			t.Errorf("Expected runtime documents %s and %s, got %v", docs[0].ID, docs[1].ID, ids)
+		}
+	})
+}
+
+func TestSinkOpenWhileWriting(t *testing.T) {
+	ctx := context.Background()
+	sink := NewSink(Options{Embedded: &EmbeddedOptions{StoreDir: t.TempDir()}})
+	defer func() {
+		_ = sink.Close(ctx)
+	}()
+
+	// /healthz opens the sink while the scheduler writes to it; writes before
+	// the first open finishes fail cleanly
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := sink.Open(ctx); err != nil {
+				t.Errorf("Failed to open sink: %v", err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			doc := model.Doc{ID: fmt.Sprintf("doc-%d", i), Type: "runtime_5m", Body: map[string]any{"n": i}}
+			if result, err := sink.Write(ctx, []model.Doc{doc}); err == nil && result.SuccessCount != 1 {
+				t.Errorf("Expected the document written, got %+v", result)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if result, err := sink.Write(ctx, []model.Doc{{ID: "after", Type: "runtime_5m", Body: map[string]any{}}}); err != nil || result.SuccessCount != 1 {
+		t.Errorf("Expected a write after opening to succeed, got %+v, %v", result, err)
+	}
+}
+
+func TestSubject(t *testing.T) {
+	sink := NewSink(Options{SubjectPrefix: "home"})
+	tests := []struct {
+		docType string
+		want    string
+	}{
+		{docType: "runtime_5m", want: "home.runtime_5m"},
+		{docType: "a.b*c>", want: "home.a_b_c_"},
+		{docType: "", want: "home.unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := sink.Subject(tt.docType); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSinkConformance(t *testing.T) {
+	sinktest.Run(t, sinktest.Harness{
+		New: func(t *testing.T) model.Sink {
+			return NewSink(Options{Embedded: &EmbeddedOptions{StoreDir: t.TempDir()}})
+		},
+		Stored: func(t *testing.T, sink model.Sink) int {
+			ctx := context.Background()
+			stream, err := sink.(*Sink).js.Stream(ctx, DefaultStream)
+			if err != nil {
+				t.Fatalf("Failed to look up stream: %v", err)
+			}
+			info, err := stream.Info(ctx)
+			if err != nil {
+				t.Fatalf("Failed to read stream info: %v", err)
+			}
+			return int(info.State.Msgs)
+		},
+		Reject: sinktest.Unencodable,
+	})
+}
@@ -0,0 +1,176 @@
+package sheets
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+)
+
+// sheetsScope grants read and write access to spreadsheets
+const sheetsScope = "https://www.googleapis.com/auth/spreadsheets"
+
+// defaultTokenURI is used when the credentials file does not name one
+const defaultTokenURI = "https://oauth2.googleapis.com/token"
+
+// ServiceAccount holds the fields of a Google service account key file
+type ServiceAccount struct {
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// LoadServiceAccount reads a service account key file downloaded from Google Cloud
+func LoadServiceAccount(path string) (ServiceAccount, error) {
+	// #nosec G304 - the credentials path comes from operator configuration
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ServiceAccount{}, fmt.Errorf("reading service account file: %w", err)
+	}
+	var account ServiceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return ServiceAccount{}, fmt.Errorf("decoding service account file: %w", err)
+	}
+	if account.ClientEmail == "" || account.PrivateKey == "" {
+		return ServiceAccount{}, fmt.Errorf("service account file is missing client_email or private_key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = defaultTokenURI
+	}
+	return account, nil
+}
+
+// tokenSource exchanges signed service account assertions for access tokens
+type tokenSource struct {
+	mu          sync.Mutex
+	client      *http.Client
+	account     ServiceAccount
+	key         *rsa.PrivateKey
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// newTokenSource parses the service account's private key
+func newTokenSource(client *http.Client, account ServiceAccount) (*tokenSource, error) {
+	block, _ := pem.Decode([]byte(account.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("service account private key is not PEM encoded")
+	}
+
+	var key *rsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("service account private key is not an RSA key")
+		}
+		key = rsaKey
+	} else {
+		rsaKey, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing service account private key: %w", err)
+		}
+		key = rsaKey
+	}
+
+	return &tokenSource{client: client, account: account, key: key}, nil
+}
+
+// Token returns a valid access token, requesting a new one shortly before
+// the current one expires
+func (t *tokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.accessToken != "" && time.Now().Add(time.Minute).Before(t.tokenExpiry) {
+		return t.accessToken, nil
+	}
+
+	assertion, err := t.assertion(time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", t.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("creating token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting access token: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request failed with status %d", resp.StatusCode)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &tokenResp); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+
+	t.accessToken = tokenResp.AccessToken
+	t.tokenExpiry = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	return t.accessToken, nil
+}
+
+// assertion builds the signed JWT exchanged for an access token
+func (t *tokenSource) assertion(now time.Time) (string, error) {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	claims := map[string]any{
+		"iss":   t.account.ClientEmail,
+		"scope": sheetsScope,
+		"aud":   t.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+
+	encode := func(v any) (string, error) {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return base64.RawURLEncoding.EncodeToString(raw), nil
+	}
+	encodedHeader, err := encode(header)
+	if err != nil {
+		return "", fmt.Errorf("encoding assertion header: %w", err)
+	}
+	encodedClaims, err := encode(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding assertion claims: %w", err)
+	}
+
+	signingInput := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, t.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("signing assertion: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
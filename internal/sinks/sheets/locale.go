@@ -0,0 +1,82 @@
+package sheets
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// Unit systems
+const (
+	// UnitsMetric writes temperatures in Celsius
+	UnitsMetric = "metric"
+	// UnitsImperial writes temperatures in Fahrenheit
+	UnitsImperial = "imperial"
+)
+
+// DefaultDateFormat is the layout of daily summary dates when none is set
+const DefaultDateFormat = time.DateOnly
+
+// Locale controls how summary rows present temperatures and dates. Decimal
+// separators are not part of it: cells hold numbers, which Sheets displays
+// with the spreadsheet's own locale.
+type Locale struct {
+	// Units is UnitsMetric or UnitsImperial; empty means metric
+	Units string
+	// DateFormat is a Go time layout for the daily date column, e.g.
+	// 02/01/2006; empty means DefaultDateFormat
+	DateFormat string
+}
+
+// validate fills in defaults and checks that dates format to distinct days
+func (l *Locale) validate() error {
+	if l.Units == "" {
+		l.Units = UnitsMetric
+	}
+	if l.Units != UnitsMetric && l.Units != UnitsImperial {
+		return fmt.Errorf("invalid sheets units %q: must be %s or %s", l.Units, UnitsMetric, UnitsImperial)
+	}
+	if l.DateFormat == "" {
+		l.DateFormat = DefaultDateFormat
+	}
+
+	// The date column is part of each row's key, so it must identify the day
+	reference := time.Date(2025, 11, 23, 0, 0, 0, 0, time.UTC)
+	parsed, err := time.Parse(l.DateFormat, reference.Format(l.DateFormat))
+	if err != nil || !parsed.Equal(reference) {
+		return fmt.Errorf("invalid sheets date_format %q: must include the year, month and day", l.DateFormat)
+	}
+	return nil
+}
+
+// date formats a row's UTC day
+func (l Locale) date(t time.Time) string {
+	return t.UTC().Format(l.DateFormat)
+}
+
+// temperature converts a Celsius value to the locale's units, rounding
+// Fahrenheit to 0.1
+func (l Locale) temperature(celsius float64) float64 {
+	if l.Units == UnitsImperial {
+		return math.Round((celsius*9/5+32)*10) / 10
+	}
+	return celsius
+}
+
+// header renames the _c temperature columns of a header row for the
+// locale's units
+func (l Locale) header(columns []any) []any {
+	if l.Units != UnitsImperial {
+		return columns
+	}
+	renamed := make([]any, len(columns))
+	for i, column := range columns {
+		name, _ := column.(string)
+		if strings.HasSuffix(name, "_c") {
+			column = strings.TrimSuffix(name, "_c") + "_f"
+		}
+		renamed[i] = column
+	}
+	return renamed
+}
@@ -0,0 +1,324 @@
+package sheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/bodylimit"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/connstats"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// sheetsAPIURL is the Sheets API base URL, overridden in tests
+var sheetsAPIURL = "https://sheets.googleapis.com/v4/spreadsheets"
+
+// defaultThrottleBackoff is used when the Sheets API rejects a request
+// without a Retry-After header
+const defaultThrottleBackoff = 30 * time.Second
+
+// Row modes
+const (
+	// ModeDaily appends one summary row per thermostat per completed UTC day
+	ModeDaily = "daily"
+	// ModeRaw appends one row per runtime_5m bin; only suited to low volumes
+	ModeRaw = "raw"
+)
+
+// Sink appends rows to a Google Sheet using service account credentials.
+// Other document types than runtime_5m are accepted and ignored.
+type Sink struct {
+	mu            sync.Mutex
+	conns         *connstats.Counter
+	client        *http.Client
+	auth          *tokenSource
+	spreadsheetID string
+	sheet         string
+	mode          string
+	locale        Locale
+	// opened is set once Open has read the sheet, so health checks calling
+	// Open again do not re-read it
+	opened bool
+	// existing holds the keys (first two columns) of rows already in the sheet
+	existing map[string]bool
+	// days holds daily summaries that are still accumulating
+	days map[string]*daySummary
+	// latestDay is the newest UTC day seen for each thermostat
+	latestDay map[string]string
+}
+
+// NewSink creates a Sheets sink appending to the named tab of a spreadsheet,
+// writing temperatures and dates as the locale sets out
+func NewSink(account ServiceAccount, spreadsheetID, sheet, mode string, locale Locale) (*Sink, error) {
+	if mode != ModeDaily && mode != ModeRaw {
+		return nil, fmt.Errorf("invalid sheets mode %q: must be %s or %s", mode, ModeDaily, ModeRaw)
+	}
+	if err := locale.validate(); err != nil {
+		return nil, err
+	}
+	conns := &connstats.Counter{}
+	client := conns.Client(30 * time.Second)
+	auth, err := newTokenSource(client, account)
+	if err != nil {
+		return nil, err
+	}
+	return &Sink{
+		conns:         conns,
+		client:        client,
+		auth:          auth,
+		spreadsheetID: spreadsheetID,
+		sheet:         sheet,
+		mode:          mode,
+		locale:        locale,
+		existing:      make(map[string]bool),
+		days:          make(map[string]*daySummary),
+		latestDay:     make(map[string]string),
+	}, nil
+}
+
+// Info returns metadata about the sink
+func (s *Sink) Info() model.SinkInfo {
+	return model.SinkInfo{
+		Name:        "sheets",
+		Version:     "1.0.0",
+		Description: "Google Sheets rows of daily summaries or runtime bins",
+	}
+}
+
+// Open reads the keys of rows already in the sheet and writes the header
+// row when the sheet is empty. Once it has succeeded it returns without
+// calling the API until the sink is closed.
+func (s *Sink) Open(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.opened {
+		return nil
+	}
+
+	rows, err := s.readKeys(ctx)
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		header := dailyHeader
+		if s.mode == ModeRaw {
+			header = runtimeHeader
+		}
+		if err := s.appendRows(ctx, [][]any{s.locale.header(header)}); err != nil {
+			return err
+		}
+	} else {
+		for _, row := range rows[1:] {
+			if len(row) >= 2 {
+				s.existing[rowKey(row[0], row[1])] = true
+			}
+		}
+	}
+	s.opened = true
+	return nil
+}
+
+// Write appends runtime rows or completed daily summaries. Rows whose key is
+// already in the sheet are skipped, so re-sent bins are not duplicated.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := make(map[string][]any)
+	touched := make(map[string]bool)
+	for _, doc := range docs {
+		if doc.Type != "runtime_5m" {
+			result.SuccessCount++
+			continue
+		}
+		row, err := decodeRuntime(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", doc.ID, err))
+			continue
+		}
+		result.SuccessCount++
+
+		if s.mode == ModeRaw {
+			key := rowKey(row.EventTime.UTC().Format(time.RFC3339), row.ThermostatID)
+			if !s.existing[key] {
+				pending[key] = runtimeRow(s.locale, row)
+			}
+			continue
+		}
+
+		date := row.EventTime.UTC().Format(time.DateOnly)
+		key := rowKey(s.locale.date(row.EventTime), row.ThermostatID)
+		if s.existing[key] {
+			continue
+		}
+		summary, ok := s.days[key]
+		if !ok {
+			summary = newDaySummary(row)
+			s.days[key] = summary
+		}
+		summary.add(row)
+		if date > s.latestDay[row.ThermostatID] {
+			s.latestDay[row.ThermostatID] = date
+		}
+		touched[row.ThermostatID] = true
+	}
+
+	// A day is complete once a bin from a later day has arrived
+	for key, summary := range s.days {
+		if touched[summary.thermostatID] && summary.date < s.latestDay[summary.thermostatID] {
+			pending[key] = summary.row(s.locale)
+		}
+	}
+
+	if len(pending) == 0 {
+		return result, nil
+	}
+
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rows := make([][]any, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, pending[key])
+	}
+
+	if err := s.appendRows(ctx, rows); err != nil {
+		return model.WriteResult{}, err
+	}
+	for _, key := range keys {
+		s.existing[key] = true
+		delete(s.days, key)
+	}
+
+	return result, nil
+}
+
+// Close drops summaries of days still in progress, since the next backfill
+// re-sends their bins, and releases idle keep-alive connections
+func (s *Sink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	s.opened = false
+	s.mu.Unlock()
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// OpenConnections returns the number of connections the sink holds open
+func (s *Sink) OpenConnections() int {
+	return s.conns.Open()
+}
+
+// rowKey identifies a row by its first two columns
+func rowKey(first, second any) string {
+	return fmt.Sprint(first) + "|" + fmt.Sprint(second)
+}
+
+// decodeRuntime round-trips a document body through JSON so canonical structs
+// and transformed maps are read the same way
+func decodeRuntime(body any) (*model.Runtime5m, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling document: %w", err)
+	}
+	var row model.Runtime5m
+	if err := json.Unmarshal(raw, &row); err != nil {
+		return nil, fmt.Errorf("decoding runtime row: %w", err)
+	}
+	return &row, nil
+}
+
+// readKeys returns the first two columns of every row in the sheet
+func (s *Sink) readKeys(ctx context.Context) ([][]any, error) {
+	endpoint := fmt.Sprintf("%s/%s/values/%s", sheetsAPIURL, url.PathEscape(s.spreadsheetID), url.PathEscape(s.sheet+"!A:B"))
+	resp, err := s.do(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("reading sheet: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var values struct {
+		Values [][]any `json:"values"`
+	}
+	if err := bodylimit.DecodeJSON(resp.Body, bodylimit.DefaultMaxBytes, &values); err != nil {
+		return nil, fmt.Errorf("decoding sheet values: %w", err)
+	}
+	return values.Values, nil
+}
+
+// appendRows appends rows after the last row of the sheet. Values are stored
+// as entered so the key columns read back unchanged.
+func (s *Sink) appendRows(ctx context.Context, rows [][]any) error {
+	body, err := json.Marshal(map[string]any{"values": rows})
+	if err != nil {
+		return fmt.Errorf("marshaling rows: %w", err)
+	}
+	endpoint := fmt.Sprintf("%s/%s/values/%s:append?valueInputOption=RAW&insertDataOption=INSERT_ROWS",
+		sheetsAPIURL, url.PathEscape(s.spreadsheetID), url.PathEscape(s.sheet+"!A1"))
+	resp, err := s.do(ctx, "POST", endpoint, body)
+	if err != nil {
+		return fmt.Errorf("appending rows: %w", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+	return nil
+}
+
+// do sends an authorized request, returning an error for non-2xx responses
+func (s *Sink) do(ctx context.Context, method, endpoint string, body []byte) (*http.Response, error) {
+	token, err := s.auth.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	if err := checkThrottle(resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("sheets API returned status %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+// checkThrottle converts quota rejections (429/503) into a ThrottledError so
+// the scheduler can hold off writes until the quota recovers
+func checkThrottle(resp *http.Response) error {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusServiceUnavailable {
+		return nil
+	}
+
+	retryAfter := retry.RetryAfterFromResponse(resp)
+	if retryAfter == 0 {
+		retryAfter = defaultThrottleBackoff
+	}
+	return fmt.Errorf("sheets request rejected: %w", retry.NewThrottledError(resp.StatusCode, retryAfter))
+}
@@ -0,0 +1,323 @@
+package sheets
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/retry"
+)
+
+// TestMain fails the package if any test leaves goroutines running
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
+
+// fakeSheets serves the token endpoint and the values API for one sheet
+type fakeSheets struct {
+	mu         sync.Mutex
+	rows       [][]any
+	reads      int
+	tokens     int
+	throttle   bool
+	authorized bool
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/token" {
+		f.tokens++
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" || strings.Count(r.FormValue("assertion"), ".") != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"access_token": "token-1", "expires_in": 3600})
+		return
+	}
+
+	f.authorized = r.Header.Get("Authorization") == "Bearer token-1"
+	if f.throttle {
+		w.Header().Set("Retry-After", "10")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && strings.HasSuffix(r.URL.Path, "/values/Telemetry!A:B"):
+		f.reads++
+		_ = json.NewEncoder(w).Encode(map[string]any{"values": f.rows})
+	case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/values/Telemetry!A1:append"):
+		var body struct {
+			Values [][]any `json:"values"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.rows = append(f.rows, body.Values...)
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestSink(t *testing.T, mode string, locale Locale) (*Sink, *fakeSheets) {
+	t.Helper()
+
+	fake := &fakeSheets{}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	original := sheetsAPIURL
+	sheetsAPIURL = server.URL + "/v4/spreadsheets"
+	t.Cleanup(func() { sheetsAPIURL = original })
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	account := ServiceAccount{
+		ClientEmail: "ttr@example.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    server.URL + "/token",
+	}
+
+	sink, err := NewSink(account, "sheet-1", "Telemetry", mode, locale)
+	if err != nil {
+		t.Fatalf("Failed to create sink: %v", err)
+	}
+	return sink, fake
+}
+
+func runtimeDoc(thermostatID string, at time.Time, avgTemp float64, equip map[string]bool) model.Doc {
+	return model.Doc{
+		ID:   thermostatID + at.Format(time.RFC3339),
+		Type: "runtime_5m",
+		Body: &model.Runtime5m{
+			Type:         "runtime_5m",
+			ThermostatID: thermostatID,
+			EventTime:    at,
+			Mode:         "heat",
+			AvgTempC:     floatPtr(avgTemp),
+			Equipment:    equip,
+		},
+	}
+}
+
+func TestSinkDailySummaries(t *testing.T) {
+	ctx := context.Background()
+	sink, fake := newTestSink(t, ModeDaily, Locale{})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	if len(fake.rows) != 1 || fake.rows[0][0] != "date" {
+		t.Fatalf("Expected a header row, got %v", fake.rows)
+	}
+
+	day := time.Date(2025, 1, 10, 23, 50, 0, 0, time.UTC)
+	heat := map[string]bool{"compHeat1": true, "fan": true}
+	docs := []model.Doc{
+		runtimeDoc("t1", day, 20.0, heat),
+		runtimeDoc("t1", day.Add(5*time.Minute), 21.0, nil),
+		runtimeDoc("t1", day.Add(5*time.Minute), 21.0, nil),
+		{ID: "s1", Type: "device_snapshot", Body: &model.DeviceSnapshot{Type: "device_snapshot"}},
+	}
+	result, err := sink.Write(ctx, docs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SuccessCount != 4 {
+		t.Errorf("Unexpected write result: %+v", result)
+	}
+	if len(fake.rows) != 1 {
+		t.Fatalf("Expected the day in progress to be held back, got %v", fake.rows)
+	}
+
+	if _, err := sink.Write(ctx, []model.Doc{runtimeDoc("t1", day.Add(10*time.Minute), 22.0, nil)}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fake.rows) != 2 {
+		t.Fatalf("Expected the completed day to be appended, got %v", fake.rows)
+	}
+	expected := []any{"2025-01-10", "t1", "", 20.5, 20.0, 21.0, "", 5.0, 0.0, 0.0, 5.0, 2.0}
+	got := fake.rows[1]
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("Column %v: expected %v, got %v", dailyHeader[i], expected[i], got[i])
+		}
+	}
+	if !fake.authorized || fake.tokens != 1 {
+		t.Errorf("Expected requests to reuse one access token, got %d token requests", fake.tokens)
+	}
+
+	t.Run("a new sink skips days already in the sheet", func(t *testing.T) {
+		restarted := &Sink{
+			client: sink.client, auth: sink.auth, spreadsheetID: "sheet-1", sheet: "Telemetry", mode: ModeDaily, locale: sink.locale,
+			existing: map[string]bool{}, days: map[string]*daySummary{}, latestDay: map[string]string{},
+		}
+		if err := restarted.Open(ctx); err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+		if _, err := restarted.Write(ctx, append(docs[:2], runtimeDoc("t1", day.Add(10*time.Minute), 22.0, nil))); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(fake.rows) != 2 {
+			t.Errorf("Expected no duplicate summary, got %v", fake.rows)
+		}
+	})
+}
+
+func TestSinkOpenReadsSheetOnce(t *testing.T) {
+	ctx := context.Background()
+	sink, fake := newTestSink(t, ModeDaily, Locale{})
+
+	// /healthz opens the sink on every request
+	for range 3 {
+		if err := sink.Open(ctx); err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+	}
+	if fake.reads != 1 || len(fake.rows) != 1 {
+		t.Errorf("Expected one read and one header row, got %d reads and rows %v", fake.reads, fake.rows)
+	}
+
+	if err := sink.Close(ctx); err != nil {
+		t.Fatalf("Failed to close sink: %v", err)
+	}
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to reopen sink: %v", err)
+	}
+	if fake.reads != 2 || len(fake.rows) != 1 {
+		t.Errorf("Expected reopening to read the sheet again without a second header, got %d reads and rows %v", fake.reads, fake.rows)
+	}
+}
+
+func TestSinkRawRows(t *testing.T) {
+	ctx := context.Background()
+	sink, fake := newTestSink(t, ModeRaw, Locale{})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+
+	at := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	docs := []model.Doc{runtimeDoc("t1", at, 20.0, map[string]bool{"compCool1": true})}
+	for range 2 {
+		if _, err := sink.Write(ctx, docs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	if len(fake.rows) != 2 {
+		t.Fatalf("Expected header and one row, got %v", fake.rows)
+	}
+	row := fake.rows[1]
+	if row[0] != "2025-01-10T12:00:00Z" || row[1] != "t1" || row[5] != 20.0 || row[10] != true {
+		t.Errorf("Unexpected raw row: %v", row)
+	}
+}
+
+func TestSinkLocale(t *testing.T) {
+	ctx := context.Background()
+	sink, fake := newTestSink(t, ModeDaily, Locale{Units: UnitsImperial, DateFormat: "02/01/2006"})
+	if err := sink.Open(ctx); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	if fake.rows[0][3] != "avg_temp_f" || fake.rows[0][11] != "bins" {
+		t.Errorf("Expected Fahrenheit column names, got %v", fake.rows[0])
+	}
+
+	day := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	docs := []model.Doc{
+		runtimeDoc("t1", day, 20.0, nil),
+		runtimeDoc("t1", day.Add(5*time.Minute), 21.0, nil),
+		runtimeDoc("t1", day.Add(24*time.Hour), 22.0, nil),
+	}
+	if _, err := sink.Write(ctx, docs); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fake.rows) != 2 {
+		t.Fatalf("Expected the completed day to be appended, got %v", fake.rows)
+	}
+	row := fake.rows[1]
+	if row[0] != "10/01/2025" || row[3] != 68.9 || row[4] != 68.0 || row[5] != 69.8 {
+		t.Errorf("Unexpected localized row: %v", row)
+	}
+
+	t.Run("days already in the sheet match in the configured format", func(t *testing.T) {
+		restarted := &Sink{
+			client: sink.client, auth: sink.auth, spreadsheetID: "sheet-1", sheet: "Telemetry", mode: ModeDaily, locale: sink.locale,
+			existing: map[string]bool{}, days: map[string]*daySummary{}, latestDay: map[string]string{},
+		}
+		if err := restarted.Open(ctx); err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+		if _, err := restarted.Write(ctx, docs); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(fake.rows) != 2 {
+			t.Errorf("Expected no duplicate summary, got %v", fake.rows)
+		}
+	})
+}
+
+func TestSinkThrottled(t *testing.T) {
+	ctx := context.Background()
+	sink, fake := newTestSink(t, ModeRaw, Locale{})
+	fake.throttle = true
+
+	err := sink.Open(ctx)
+	var throttled *retry.ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected ThrottledError, got %v", err)
+	}
+	if throttled.RetryAfter != 10*time.Second {
+		t.Errorf("Expected Retry-After of 10s, got %v", throttled.RetryAfter)
+	}
+}
+
+func TestNewSinkRejectsInvalidMode(t *testing.T) {
+	if _, err := NewSink(ServiceAccount{}, "sheet-1", "Telemetry", "hourly", Locale{}); err == nil {
+		t.Error("Expected error for invalid mode")
+	}
+}
+
+func TestNewSinkRejectsInvalidLocale(t *testing.T) {
+	tests := []struct {
+		name   string
+		locale Locale
+	}{
+		{name: "unknown units", locale: Locale{Units: "kelvin"}},
+		{name: "date without year", locale: Locale{DateFormat: "02/01"}},
+		{name: "not a date layout", locale: Locale{DateFormat: "dd.mm.yyyy"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSink(ServiceAccount{}, "sheet-1", "Telemetry", ModeDaily, tt.locale); err == nil {
+				t.Errorf("Expected error for locale %+v", tt.locale)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package sheets
+
+import (
+	"math"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// binMinutes is the length of a runtime_5m bin
+const binMinutes = 5
+
+// dailyHeader names the columns of daily summary rows
+var dailyHeader = []any{
+	"date", "thermostat_id", "thermostat_name", "avg_temp_c", "min_temp_c", "max_temp_c",
+	"avg_outdoor_temp_c", "heat_minutes", "cool_minutes", "aux_heat_minutes", "fan_minutes", "bins",
+}
+
+// runtimeHeader names the columns of raw runtime rows
+var runtimeHeader = []any{
+	"event_time", "thermostat_id", "thermostat_name", "mode", "climate",
+	"avg_temp_c", "set_heat_c", "set_cool_c", "outdoor_temp_c", "heating", "cooling", "fan",
+}
+
+// daySummary accumulates one thermostat's runtime bins for one UTC day
+type daySummary struct {
+	// date is the UTC day as YYYY-MM-DD, which sorts chronologically
+	date           string
+	day            time.Time
+	thermostatID   string
+	thermostatName string
+	bins           map[time.Time]bool
+	tempSum        float64
+	tempCount      int
+	minTemp        float64
+	maxTemp        float64
+	outdoorSum     float64
+	outdoorCount   int
+	heatMinutes    int
+	coolMinutes    int
+	auxMinutes     int
+	fanMinutes     int
+}
+
+// newDaySummary starts a summary for the row's thermostat and day
+func newDaySummary(row *model.Runtime5m) *daySummary {
+	return &daySummary{
+		date:           row.EventTime.UTC().Format(time.DateOnly),
+		day:            row.EventTime,
+		thermostatID:   row.ThermostatID,
+		thermostatName: row.ThermostatName,
+		bins:           make(map[time.Time]bool),
+		minTemp:        math.Inf(1),
+		maxTemp:        math.Inf(-1),
+	}
+}
+
+// add folds a runtime bin into the summary; bins already seen are ignored
+func (d *daySummary) add(row *model.Runtime5m) {
+	if d.bins[row.EventTime] {
+		return
+	}
+	d.bins[row.EventTime] = true
+
+	if row.AvgTempC != nil {
+		d.tempSum += *row.AvgTempC
+		d.tempCount++
+		d.minTemp = math.Min(d.minTemp, *row.AvgTempC)
+		d.maxTemp = math.Max(d.maxTemp, *row.AvgTempC)
+	}
+	if row.OutdoorTempC != nil {
+		d.outdoorSum += *row.OutdoorTempC
+		d.outdoorCount++
+	}
+
+	equip := row.Equipment
+	if equip["compHeat1"] || equip["compHeat2"] || equip["auxHeat1"] || equip["auxHeat2"] || equip["auxHeat3"] {
+		d.heatMinutes += binMinutes
+	}
+	if equip["compCool1"] || equip["compCool2"] {
+		d.coolMinutes += binMinutes
+	}
+	if equip["auxHeat1"] || equip["auxHeat2"] || equip["auxHeat3"] {
+		d.auxMinutes += binMinutes
+	}
+	if equip["fan"] {
+		d.fanMinutes += binMinutes
+	}
+}
+
+// row renders the summary as a sheet row in the locale's units and date format
+func (d *daySummary) row(locale Locale) []any {
+	return []any{
+		locale.date(d.day), d.thermostatID, d.thermostatName,
+		average(locale, d.tempSum, d.tempCount), finite(locale, d.minTemp), finite(locale, d.maxTemp),
+		average(locale, d.outdoorSum, d.outdoorCount),
+		d.heatMinutes, d.coolMinutes, d.auxMinutes, d.fanMinutes, len(d.bins),
+	}
+}
+
+// runtimeRow renders a runtime bin as a sheet row in the locale's units
+func runtimeRow(locale Locale, row *model.Runtime5m) []any {
+	equip := row.Equipment
+	return []any{
+		row.EventTime.UTC().Format(time.RFC3339), row.ThermostatID, row.ThermostatName, row.Mode, row.Climate,
+		optional(locale, row.AvgTempC), optional(locale, row.SetHeatC), optional(locale, row.SetCoolC), optional(locale, row.OutdoorTempC),
+		equip["compHeat1"] || equip["compHeat2"] || equip["auxHeat1"] || equip["auxHeat2"] || equip["auxHeat3"],
+		equip["compCool1"] || equip["compCool2"],
+		equip["fan"],
+	}
+}
+
+// average returns the mean of Celsius samples in the locale's units rounded
+// to 0.1, or an empty cell without samples
+func average(locale Locale, sum float64, count int) any {
+	if count == 0 {
+		return ""
+	}
+	return math.Round(locale.temperature(sum/float64(count))*10) / 10
+}
+
+// finite returns v in the locale's units, or an empty cell for the
+// infinities of an empty min/max
+func finite(locale Locale, v float64) any {
+	if math.IsInf(v, 0) {
+		return ""
+	}
+	return locale.temperature(v)
+}
+
+// optional returns the value of a pointer in the locale's units, or an empty
+// cell
+func optional(locale Locale, v *float64) any {
+	if v == nil {
+		return ""
+	}
+	return locale.temperature(*v)
+}
@@ -0,0 +1,82 @@
+// Package bodylimit bounds how much of an HTTP response body is read, so a
+// pathological response, such as a runtime report for an accidentally
+// unbounded range, fails with a TooLargeError instead of exhausting memory
+// on a small device.
+package bodylimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// DefaultMaxBytes is the response size limit used when none is configured
+const DefaultMaxBytes int64 = 32 << 20
+
+// TooLargeError reports a body that exceeded its limit
+type TooLargeError struct {
+	Limit int64
+}
+
+// Error implements the error interface
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("response body exceeds %d bytes", e.Limit)
+}
+
+// reader returns a TooLargeError once more than limit bytes are read
+type reader struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+// NewReader returns a reader that fails with a TooLargeError when r holds
+// more than limit bytes. Bodies of exactly limit bytes read normally.
+func NewReader(r io.Reader, limit int64) io.Reader {
+	return &reader{r: r, limit: limit, remaining: limit}
+}
+
+// Read implements io.Reader
+func (l *reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if l.remaining <= 0 {
+		// Probe for one more byte to tell a body of exactly limit bytes
+		// from a longer one
+		var probe [1]byte
+		n, err := l.r.Read(probe[:])
+		if n > 0 {
+			return 0, &TooLargeError{Limit: l.limit}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// readCloser limits a body while closing the original
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// NewReadCloser limits body like NewReader; closing it closes body
+func NewReadCloser(body io.ReadCloser, limit int64) io.ReadCloser {
+	return readCloser{Reader: NewReader(body, limit), Closer: body}
+}
+
+// ReadAll reads r to the end, failing with a TooLargeError past limit bytes
+func ReadAll(r io.Reader, limit int64) ([]byte, error) {
+	return io.ReadAll(NewReader(r, limit))
+}
+
+// DecodeJSON decodes a JSON value from r into v, streaming it rather than
+// buffering the body, and fails with a TooLargeError past limit bytes
+func DecodeJSON(r io.Reader, limit int64, v any) error {
+	return json.NewDecoder(NewReader(r, limit)).Decode(v)
+}
@@ -0,0 +1,60 @@
+package bodylimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadAllAtAndPastLimit(t *testing.T) {
+	data, err := ReadAll(strings.NewReader("12345"), 5)
+	if err != nil || string(data) != "12345" {
+		t.Fatalf("Expected a body of exactly the limit to read, got %q, %v", data, err)
+	}
+
+	_, err = ReadAll(strings.NewReader("123456"), 5)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 5 {
+		t.Fatalf("Expected a TooLargeError with limit 5, got %v", err)
+	}
+}
+
+func TestDecodeJSONPastLimit(t *testing.T) {
+	var v struct {
+		Rows []string `json:"rows"`
+	}
+	if err := DecodeJSON(strings.NewReader(`{"rows":["a","b"]}`), 64, &v); err != nil || len(v.Rows) != 2 {
+		t.Fatalf("Expected the body to decode, got %v, %v", v.Rows, err)
+	}
+
+	body := `{"rows":["` + strings.Repeat("x", 100) + `"]}`
+	err := DecodeJSON(strings.NewReader(body), 64, &v)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected a TooLargeError, got %v", err)
+	}
+}
+
+func TestCounterLimitsAndCounts(t *testing.T) {
+	var counter Counter
+	resp := counter.Limit(&http.Response{Body: io.NopCloser(strings.NewReader("12345"))}, 5)
+	if data, err := io.ReadAll(resp.Body); err != nil || string(data) != "12345" {
+		t.Fatalf("Expected the body to read, got %q, %v", data, err)
+	}
+
+	resp = counter.Limit(&http.Response{Body: io.NopCloser(strings.NewReader("123456"))}, 5)
+	_, err := io.ReadAll(resp.Body)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected a TooLargeError, got %v", err)
+	}
+	if counter.Bytes() != 11 {
+		t.Fatalf("Expected 11 bytes counted across both bodies, got %d", counter.Bytes())
+	}
+
+	if counter.Limit(nil, 5) != nil {
+		t.Fatal("Expected a nil response to be returned as is")
+	}
+}
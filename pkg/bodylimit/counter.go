@@ -0,0 +1,41 @@
+package bodylimit
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Counter limits response bodies and counts the bytes read from them, for
+// a provider's bytes fetched. The zero value is ready to use.
+type Counter struct {
+	n atomic.Int64
+}
+
+// Limit bounds the body of resp to limit bytes like NewReadCloser and
+// counts what is read of it. A nil response or body is returned as is.
+func (c *Counter) Limit(resp *http.Response, limit int64) *http.Response {
+	if resp != nil && resp.Body != nil {
+		counted := readCloser{Reader: countingReader{r: resp.Body, n: &c.n}, Closer: resp.Body}
+		resp.Body = NewReadCloser(counted, limit)
+	}
+	return resp
+}
+
+// Bytes returns the bytes read through the counter's bodies
+func (c *Counter) Bytes() int64 {
+	return c.n.Load()
+}
+
+// countingReader adds the bytes read through it to n
+type countingReader struct {
+	r io.Reader
+	n *atomic.Int64
+}
+
+// Read implements io.Reader
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -34,13 +34,37 @@ sinks:
       url: "http://localhost:9200"
 `,
 			envVars: map[string]string{
-				"TTR_LOG_LEVEL":                  "debug",
-				"PROVIDERS_0_SETTINGS_CLIENT_ID": "env-client-id",
+				"TTR_LOG_LEVEL":                   "debug",
+				"TTR_TEMPERATURE_PRECISION":       "0.5",
+				"TTR_FAIL_FAST":                   "true",
+				"TTR_BACKFILL_FAILURE_POLICY":     "retry",
+				"TTR_CREDENTIALS_RELOAD_INTERVAL": "10m",
+				"TTR_BACKFILL_OVERWRITE_WINDOW":   "6h",
+				"TTR_INSTANCE_ID":                 "collector-2",
+				"PROVIDERS_0_SETTINGS_CLIENT_ID":  "env-client-id",
 			},
 			validate: func(t *testing.T, cfg *Config) {
 				if cfg.TTR.LogLevel != "debug" {
 					t.Errorf("Expected log_level to be overridden by env var, got %s", cfg.TTR.LogLevel)
 				}
+				if cfg.TTR.TemperaturePrecision != 0.5 {
+					t.Errorf("Expected temperature_precision to be overridden by env var, got %v", cfg.TTR.TemperaturePrecision)
+				}
+				if !cfg.TTR.FailFast {
+					t.Error("Expected fail_fast to be enabled by env var")
+				}
+				if cfg.TTR.BackfillFailurePolicy != "retry" {
+					t.Errorf("Expected backfill_failure_policy to be overridden by env var, got %s", cfg.TTR.BackfillFailurePolicy)
+				}
+				if cfg.TTR.CredentialsReloadInterval != 10*time.Minute {
+					t.Errorf("Expected credentials_reload_interval to be overridden by env var, got %v", cfg.TTR.CredentialsReloadInterval)
+				}
+				if cfg.TTR.BackfillOverwriteWindow != 6*time.Hour {
+					t.Errorf("Expected backfill_overwrite_window to be overridden by env var, got %v", cfg.TTR.BackfillOverwriteWindow)
+				}
+				if cfg.TTR.InstanceID != "collector-2" {
+					t.Errorf("Expected instance_id to be overridden by env var, got %s", cfg.TTR.InstanceID)
+				}
 				if cfg.Providers[0].Settings["client_id"] != "env-client-id" {
 					t.Errorf("Expected client_id to be overridden by env var, got %v", cfg.Providers[0].Settings["client_id"])
 				}
@@ -311,6 +335,51 @@ sinks:
 	if config.TTR.MetricsPort != 9090 {
 		t.Errorf("Expected default metrics port 9090, got %d", config.TTR.MetricsPort)
 	}
+
+	if config.TTR.TemperaturePrecision != 0.1 {
+		t.Errorf("Expected default temperature precision 0.1, got %v", config.TTR.TemperaturePrecision)
+	}
+
+	if config.TTR.Analysis.HeatPump.Enabled {
+		t.Error("Expected heat pump analysis to be disabled by default")
+	}
+
+	if config.TTR.Analysis.HeatPump.Period != 24*time.Hour {
+		t.Errorf("Expected default heat pump period 24h, got %v", config.TTR.Analysis.HeatPump.Period)
+	}
+
+	if config.TTR.Schedule.Strategy != "fixed" {
+		t.Errorf("Expected default schedule strategy fixed, got %s", config.TTR.Schedule.Strategy)
+	}
+
+	if config.TTR.Schedule.MinInterval != 2*time.Minute || config.TTR.Schedule.MaxInterval != 15*time.Minute {
+		t.Errorf("Expected default adaptive bounds 2m-15m, got %v-%v", config.TTR.Schedule.MinInterval, config.TTR.Schedule.MaxInterval)
+	}
+
+	if config.TTR.Health.ErrorWindow != 15*time.Minute || config.TTR.Health.DegradedErrorRate != 0.2 ||
+		config.TTR.Health.UnhealthyErrorRate != 0.5 || config.TTR.Health.MinRequests != 5 {
+		t.Errorf("Expected default error budget 20%%/50%% over 15m, got %+v", config.TTR.Health)
+	}
+
+	if config.TTR.Live.Enabled || config.TTR.Live.Interval != time.Minute {
+		t.Errorf("Expected live tier disabled with 1m interval by default, got %+v", config.TTR.Live)
+	}
+
+	if !config.TTR.Control.ReadOnly || config.TTR.Control.MinSetpointC != 10 || config.TTR.Control.MaxSetpointC != 32 {
+		t.Errorf("Expected control read-only with 10-32°C bounds by default, got %+v", config.TTR.Control)
+	}
+
+	if config.TTR.Metadata.RefreshInterval != 24*time.Hour {
+		t.Errorf("Expected default metadata refresh interval 24h, got %v", config.TTR.Metadata.RefreshInterval)
+	}
+
+	if config.TTR.OffsetStore.Type != "sqlite" || config.TTR.OffsetStore.Path != "" || config.TTR.OffsetStore.MaxConns != 4 {
+		t.Errorf("Expected default sqlite offset store, got %+v", config.TTR.OffsetStore)
+	}
+
+	if config.TTR.Inflight.MaxDocuments != 5000 || config.TTR.Inflight.MaxBytes != 32<<20 {
+		t.Errorf("Expected default in-flight limits of 5000 documents and 32 MiB, got %+v", config.TTR.Inflight)
+	}
 }
 
 func TestLoadConfigValidation(t *testing.T) {
@@ -389,28 +458,1175 @@ sinks:
 			expectError: true,
 			errorMsg:    "invalid log_level",
 		},
-	}
+		{
+			name: "heat pump period too short",
+			config: `
+ttr:
+  analysis:
+    heat_pump:
+      enabled: true
+      period: "30m"
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			tempDir := t.TempDir()
-			configPath := filepath.Join(tempDir, "test-config.yaml")
-			t.Setenv("TTR_CONFIG_ROOT", tempDir)
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
 
-			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
-				t.Fatalf("Failed to write config file: %v", err)
-			}
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.heat_pump.period must be at least 1 hour",
+		},
+		{
+			name: "household period too short",
+			config: `
+ttr:
+  analysis:
+    household:
+      enabled: true
+      period: "15m"
 
-			_, err := LoadConfig(configPath)
-			if tt.expectError {
-				if err == nil {
-					t.Error("Expected error but got none")
-				} else if tt.errorMsg != "" && !strings.Contains(err.Error(), tt.errorMsg) {
-					t.Errorf("Expected error message to contain %q, got %q", tt.errorMsg, err.Error())
-				}
-			} else {
-				if err != nil {
-					t.Errorf("Unexpected error: %v", err)
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.household.period must be at least 1 hour",
+		},
+		{
+			name: "recommendation period too short",
+			config: `
+ttr:
+  analysis:
+    recommendations:
+      enabled: true
+      period: "24h"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.recommendations requires a period of at least 3 days and positive heating_kw and cooling_kw",
+		},
+		{
+			name: "staging aux window too short",
+			config: `
+ttr:
+  analysis:
+    staging:
+      enabled: true
+      aux_window: "1m"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.staging requires a period of at least 1 hour and an aux_window of at least 5m",
+		},
+		{
+			name: "writable control without admin token",
+			config: `
+ttr:
+  control:
+    read_only: false
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "control.read_only false requires admin_token",
+		},
+		{
+			name: "inverted control setpoint bounds",
+			config: `
+ttr:
+  control:
+    min_setpoint_c: 25
+    max_setpoint_c: 20
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "control requires min_setpoint_c below max_setpoint_c, within 5-35°C",
+		},
+		{
+			name: "conflict thresholds overlap",
+			config: `
+ttr:
+  analysis:
+    conflicts:
+      enabled: true
+      heat_max_outdoor_c: 8
+      cool_min_outdoor_c: 12
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.conflicts requires durations of at least 5m and cool_min_outdoor_c below heat_max_outdoor_c",
+		},
+		{
+			name: "invalid metadata time zone",
+			config: `
+ttr:
+  metadata:
+    thermostats:
+      "123":
+        time_zone: "Mars/Olympus_Mons"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "invalid time_zone",
+		},
+		{
+			name: "data quality interval longer than window",
+			config: `
+ttr:
+  analysis:
+    data_quality:
+      enabled: true
+      window: "2h"
+      interval: "3h"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "analysis.data_quality requires a window of at least 1 hour",
+		},
+		{
+			name: "invalid maintenance window day",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+    maintenance_windows:
+      - days: ["sunday"]
+        start: "01:00"
+        end: "03:00"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "provider ecobee: maintenance window 0: invalid day \"sunday\", must be one of: mon, tue, wed, thu, fri, sat, sun",
+		},
+		{
+			name: "negative request budget",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+    request_budget:
+      per_hour: -1
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "provider ecobee: request_budget limits cannot be negative",
+		},
+		{
+			name: "http write timeout within health timeout",
+			config: `
+ttr:
+  health:
+    timeout: "30s"
+  http:
+    write_timeout: "20s"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "http.write_timeout (20s) must exceed health.timeout (30s)",
+		},
+		{
+			name: "watchdog within poll interval",
+			config: `
+ttr:
+  poll_interval: "30m"
+  health:
+    watchdog_after: "20m"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "health.watchdog_after must be 0 or longer than poll_interval",
+		},
+		{
+			name: "reconcile window within poll interval",
+			config: `
+ttr:
+  poll_interval: "30m"
+  health:
+    reconcile_window: "30m"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "health.reconcile_window must be 0 or longer than poll_interval",
+		},
+		{
+			name: "field selection with empty path",
+			config: `
+ttr:
+  fields:
+    runtime_5m:
+      exclude: ["sensors", "provider."]
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    `fields.runtime_5m: invalid field path "provider."`,
+		},
+		{
+			name: "unknown gap fill policy",
+			config: `
+ttr:
+  gap_fill:
+    policy: "interpolate"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "invalid gap_fill.policy: interpolate, must be one of: none, forward, null",
+		},
+		{
+			name: "fault rate above one",
+			config: `
+ttr:
+  faults:
+    enabled: true
+    drop_rate: 1.5
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "faults.drop_rate must be between 0 and 1",
+		},
+		{
+			name: "telegram channel without chat",
+			config: `
+ttr:
+  notify:
+    channels:
+      - type: "telegram"
+        token: "123:abc"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "notify channel telegram: telegram channels need token and chat_id",
+		},
+		{
+			name: "cors credentials with any origin",
+			config: `
+ttr:
+  http:
+    cors:
+      allowed_origins: ["*"]
+      allow_credentials: true
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "http.cors.allow_credentials cannot be used with the * origin",
+		},
+		{
+			name: "misspelled key",
+			config: `
+ttr:
+  pol_interval: "10m"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    `line 3: unknown key "pol_interval"`,
+		},
+		{
+			name: "unknown sink key",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    setting:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    `line 12: unknown key "setting"`,
+		},
+		{
+			name: "relative http base path",
+			config: `
+ttr:
+  http:
+    base_path: "ttr"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    `http.base_path must be a path starting with /, got "ttr"`,
+		},
+		{
+			name: "negative settling delay",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+    settling_delay: "-5m"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "provider ecobee: settling_delay cannot be negative",
+		},
+		{
+			name: "negative snapshot cache max age",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+    snapshot_cache_max_age: "-1h"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "provider ecobee: snapshot_cache_max_age cannot be negative",
+		},
+		{
+			name: "negative request timeout",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+    request_timeout: "-30s"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "provider ecobee: request_timeout cannot be negative",
+		},
+		{
+			name: "negative write timeout",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    write_timeout: "-1m"
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "sink elasticsearch: write_timeout cannot be negative",
+		},
+		{
+			name: "paced backfill with abort policy",
+			config: `
+ttr:
+  backfill_failure_policy: "abort"
+  backfill_max_requests_per_cycle: 20
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "backfill_max_requests_per_cycle needs backfill_failure_policy skip or retry, since paced backfill runs after polling starts",
+		},
+		{
+			name: "negative backfill overwrite window",
+			config: `
+ttr:
+  backfill_overwrite_window: "-6h"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "backfill_overwrite_window cannot be negative",
+		},
+		{
+			name: "unknown schedule strategy",
+			config: `
+ttr:
+  schedule:
+    strategy: "random"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "invalid schedule.strategy: random, must be one of: fixed, cron, adaptive",
+		},
+		{
+			name: "cron strategy without expression",
+			config: `
+ttr:
+  schedule:
+    strategy: "cron"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "schedule.cron must be a five-field cron expression when strategy is cron",
+		},
+		{
+			name: "adaptive bounds inverted",
+			config: `
+ttr:
+  schedule:
+    strategy: "adaptive"
+    min_interval: "10m"
+    max_interval: "5m"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "schedule.max_interval must not be less than schedule.min_interval",
+		},
+		{
+			name: "degraded error rate above unhealthy rate",
+			config: `
+ttr:
+  health:
+    degraded_error_rate: 0.6
+    unhealthy_error_rate: 0.4
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "health error rates must satisfy 0 < degraded_error_rate <= unhealthy_error_rate <= 1",
+		},
+		{
+			name: "live interval too short",
+			config: `
+ttr:
+  live:
+    enabled: true
+    interval: "10s"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "live.interval must be at least 30 seconds and shorter than poll_interval",
+		},
+		{
+			name: "unknown offset store type",
+			config: `
+ttr:
+  offset_store:
+    type: "redis"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "invalid offset_store.type: redis, must be one of: sqlite, bolt, postgres, memory",
+		},
+		{
+			name: "short admin token",
+			config: `
+ttr:
+  admin_token: "letmein"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "admin_token must be at least 16 characters",
+		},
+		{
+			name: "postgres offset store without dsn",
+			config: `
+ttr:
+  offset_store:
+    type: "postgres"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "offset_store.dsn is required for the postgres offset store",
+		},
+		{
+			name: "negative inflight limit",
+			config: `
+ttr:
+  inflight:
+    max_documents: -1
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "inflight.max_documents and inflight.max_bytes must not be negative",
+		},
+		{
+			name: "unknown metadata inject field",
+			config: `
+ttr:
+  metadata:
+    inject_fields: ["city", "street_address"]
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "invalid metadata.inject_fields entry: street_address",
+		},
+		{
+			name: "temperature precision too coarse",
+			config: `
+ttr:
+  temperature_precision: 2
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "temperature_precision must be greater than 0 and at most 1",
+		},
+		{
+			name: "calibration offset out of range",
+			config: `
+ttr:
+  calibration:
+    thermostats:
+      "t1": -15
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    "calibration offset for thermostat t1 must be within",
+		},
+		{
+			name: "tag name with a dot",
+			config: `
+ttr:
+  tags:
+    thermostats:
+      "t1":
+        building.floor: "2"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+`,
+			expectError: true,
+			errorMsg:    `tags.thermostats.t1: invalid tag name "building.floor"`,
+		},
+		{
+			name: "transform without name",
+			config: `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+    transforms:
+      - settings:
+          fields: ["provider"]
+`,
+			expectError: true,
+			errorMsg:    "transform 0 is missing a name",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			configPath := filepath.Join(tempDir, "test-config.yaml")
+			t.Setenv("TTR_CONFIG_ROOT", tempDir)
+
+			if err := os.WriteFile(configPath, []byte(tt.config), 0644); err != nil {
+				t.Fatalf("Failed to write config file: %v", err)
+			}
+
+			_, err := LoadConfig(configPath)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				} else if tt.errorMsg != "" && !strings.Contains(err.Error(), tt.errorMsg) {
+					t.Errorf("Expected error message to contain %q, got %q", tt.errorMsg, err.Error())
+				}
+			} else {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvReferences(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "env-config.yaml")
+	t.Setenv("TTR_CONFIG_ROOT", tempDir)
+	t.Setenv("TEST_ECOBEE_CLIENT_ID", "from-env")
+	t.Setenv("TEST_POLL_INTERVAL", "15m")
+	t.Setenv("TEST_HEALTH_PORT", "8181")
+	t.Setenv("TEST_API_KEY", "x\"\n      url: \"http://evil.example\" # '")
+
+	configContent := `
+ttr:
+  poll_interval: "${TEST_POLL_INTERVAL}"
+  health_port: ${TEST_HEALTH_PORT}
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "${TEST_ECOBEE_CLIENT_ID}"
+      refresh_token: "pa$$word${TEST_UNSET_VARIABLE}"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+      api_key: "${TEST_API_KEY}"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.TTR.HealthPort != 8181 {
+		t.Errorf("Expected an unquoted reference to decode as a number, got %v", config.TTR.HealthPort)
+	}
+	sinkSettings := config.Sinks[0].Settings
+	if sinkSettings["api_key"] != os.Getenv("TEST_API_KEY") || sinkSettings["url"] != "http://localhost:9200" {
+		t.Errorf("Expected quotes and newlines in a value to stay in that value, got %v", sinkSettings)
+	}
+	if config.TTR.PollInterval != 15*time.Minute {
+		t.Errorf("Expected poll interval 15m from the environment, got %v", config.TTR.PollInterval)
+	}
+	settings := config.Providers[0].Settings
+	if settings["client_id"] != "from-env" {
+		t.Errorf("Expected client_id from the environment, got %v", settings["client_id"])
+	}
+	if settings["refresh_token"] != "pa$$word" {
+		t.Errorf("Expected bare $ to be kept and unset references to be empty, got %v", settings["refresh_token"])
+	}
+}
+
+func TestLoadConfigSinkTransforms(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "transforms-config.yaml")
+	t.Setenv("TTR_CONFIG_ROOT", tempDir)
+
+	configContent := `
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    doc_types: ["runtime_5m", "runtime_live"]
+    settings:
+      url: "http://localhost:9200"
+    transforms:
+      - name: "drop_fields"
+        types: ["runtime_5m"]
+        settings:
+          fields: ["provider"]
+      - name: "add_tags"
+        settings:
+          tags:
+            site: "home"
+`
+
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if docTypes := config.Sinks[0].DocTypes; len(docTypes) != 2 || docTypes[1] != "runtime_live" {
+		t.Errorf("Expected doc_types to be parsed, got %v", docTypes)
+	}
+
+	transforms := config.Sinks[0].Transforms
+	if len(transforms) != 2 {
+		t.Fatalf("Expected 2 transforms, got %d", len(transforms))
+	}
+	if transforms[0].Name != "drop_fields" || len(transforms[0].Types) != 1 || transforms[0].Types[0] != "runtime_5m" {
+		t.Errorf("Unexpected first transform: %+v", transforms[0])
+	}
+	if tags, ok := transforms[1].Settings["tags"].(map[string]any); !ok || tags["site"] != "home" {
+		t.Errorf("Expected add_tags settings to be parsed, got %+v", transforms[1].Settings)
+	}
+}
+
+func TestMaintenanceWindowConfig(t *testing.T) {
+	tests := []struct {
+		name      string
+		window    MaintenanceWindowConfig
+		start     time.Duration
+		end       time.Duration
+		days      []time.Weekday
+		expectErr bool
+	}{
+		{
+			name:   "daily window",
+			window: MaintenanceWindowConfig{Start: "01:00", End: "03:30"},
+			start:  time.Hour,
+			end:    3*time.Hour + 30*time.Minute,
+			days:   []time.Weekday{},
+		},
+		{
+			name:   "overnight window on weekends",
+			window: MaintenanceWindowConfig{Days: []string{"Sat", "sun"}, Start: "23:00", End: "01:00"},
+			start:  23 * time.Hour,
+			end:    time.Hour,
+			days:   []time.Weekday{time.Saturday, time.Sunday},
+		},
+		{name: "bad clock", window: MaintenanceWindowConfig{Start: "1am", End: "03:00"}, expectErr: true},
+		{name: "empty window", window: MaintenanceWindowConfig{Start: "02:00", End: "02:00"}, expectErr: true},
+		{name: "bad day", window: MaintenanceWindowConfig{Days: []string{"funday"}, Start: "01:00", End: "02:00"}, expectErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMaintenanceWindow(tt.window)
+			if tt.expectErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			start, end, _ := tt.window.ClockRange()
+			if start != tt.start || end != tt.end {
+				t.Errorf("Expected %v-%v, got %v-%v", tt.start, tt.end, start, end)
+			}
+			days, _ := tt.window.Weekdays()
+			if len(days) != len(tt.days) {
+				t.Fatalf("Expected days %v, got %v", tt.days, days)
+			}
+			for i := range days {
+				if days[i] != tt.days[i] {
+					t.Errorf("Expected days %v, got %v", tt.days, days)
 				}
 			}
 		})
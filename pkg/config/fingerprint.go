@@ -0,0 +1,75 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue stands in for a secret in the fingerprinted configuration
+const redactedValue = "[REDACTED]"
+
+// Fingerprint returns a short hash of the effective configuration: the files
+// with their profile overlay merged, environment overrides and defaults
+// applied. Equal configurations give equal fingerprints however they were
+// written, so operators can tell which configuration a long-running
+// instance loaded. The fingerprint is published unauthenticated, so secrets
+// (the keys the printed configuration redacts, and URL passwords) are hashed
+// only as set or unset; changing a secret alone keeps the fingerprint.
+func (c *Config) Fingerprint() (string, error) {
+	// Round-trip through YAML to get the configuration under its file keys,
+	// which is what isSensitiveKey matches
+	encoded, err := yaml.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	var tree any
+	if err := yaml.Unmarshal(encoded, &tree); err != nil {
+		return "", err
+	}
+	// Map keys marshal sorted, so the encoding is stable
+	encoded, err = json.Marshal(redactSecrets(tree))
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// redactSecrets returns a copy of a decoded configuration tree with the
+// values of sensitive keys and the passwords of URLs replaced
+func redactSecrets(node any) any {
+	switch v := node.(type) {
+	case map[string]any:
+		redacted := make(map[string]any, len(v))
+		for key, value := range v {
+			if s, ok := value.(string); ok && isSensitiveKey(key) {
+				if s != "" {
+					value = redactedValue
+				}
+				redacted[key] = value
+				continue
+			}
+			redacted[key] = redactSecrets(value)
+		}
+		return redacted
+	case []any:
+		redacted := make([]any, len(v))
+		for i, value := range v {
+			redacted[i] = redactSecrets(value)
+		}
+		return redacted
+	case string:
+		if u, err := url.Parse(v); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				return u.Redacted()
+			}
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,155 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// profilePattern restricts profile names to what can safely be part of a
+// file name
+var profilePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// resolveProfilePath returns the overlay for profile next to the
+// configuration file, e.g. config.prod.yaml for config.yaml and prod, or nil
+// when profile is empty. The overlay must exist.
+func resolveProfilePath(base configPathInfo, profile string) (*configPathInfo, error) {
+	if profile == "" {
+		return nil, nil
+	}
+	if !profilePattern.MatchString(profile) {
+		return nil, fmt.Errorf("profile names may only contain letters, digits, - and _")
+	}
+
+	ext := filepath.Ext(base.Absolute)
+	overlay, err := resolveConfigPath(strings.TrimSuffix(base.Absolute, ext) + "." + profile + ext)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(overlay.Absolute); err != nil {
+		return nil, fmt.Errorf("finding profile config file: %w", err)
+	}
+	return &overlay, nil
+}
+
+// parseProfileConfig parses the configuration file merged with overlay, when
+// there is one. Both files are checked for unknown keys on their own, so
+// errors point at the right file and line.
+func parseProfileConfig(base configPathInfo, overlay *configPathInfo) (*Config, error) {
+	if overlay == nil {
+		return parseYAMLConfig(base)
+	}
+
+	baseNode, err := parseConfigNode(base)
+	if err != nil {
+		return nil, err
+	}
+	overlayNode, err := parseConfigNode(*overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := mergeNodes(baseNode, overlayNode).Decode(&config); err != nil {
+		return nil, fmt.Errorf("decoding merged config: %w", describeYAMLError(err))
+	}
+	return &config, nil
+}
+
+// parseConfigNode reads a configuration file, checks it for unknown keys and
+// returns its top-level node with environment references expanded
+func parseConfigNode(info configPathInfo) (*yaml.Node, error) {
+	data, err := readConfigFile(info)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %s: %w", info.Relative, err)
+	}
+	if err := checkUnknownKeys(data); err != nil {
+		return nil, fmt.Errorf("parsing YAML config %s: %w", info.Relative, err)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("parsing YAML config %s: %w", info.Relative, err)
+	}
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, nil
+	}
+	expandEnvReferences(document.Content[0])
+	return document.Content[0], nil
+}
+
+// mergeNodes merges overlay into base and returns the result. Mappings merge
+// key by key and lists of named entries, such as providers and sinks, merge
+// entry by entry on name, with unmatched entries appended. Anything else in
+// overlay, including an empty list, replaces what base has.
+func mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	switch {
+	case base.Kind == yaml.MappingNode && overlay.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(overlay.Content); i += 2 {
+			key, value := overlay.Content[i], overlay.Content[i+1]
+			if existing := mappingValue(base, key.Value); existing != nil {
+				*existing = *mergeNodes(existing, value)
+			} else {
+				base.Content = append(base.Content, key, value)
+			}
+		}
+		return base
+	case base.Kind == yaml.SequenceNode && overlay.Kind == yaml.SequenceNode &&
+		len(overlay.Content) > 0 && namedEntries(base) && namedEntries(overlay):
+		for _, entry := range overlay.Content {
+			if existing := namedEntry(base, entryName(entry)); existing != nil {
+				*existing = *mergeNodes(existing, entry)
+			} else {
+				base.Content = append(base.Content, entry)
+			}
+		}
+		return base
+	default:
+		return overlay
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// entryName returns the name of a list entry, or "" when it has none
+func entryName(entry *yaml.Node) string {
+	if entry.Kind != yaml.MappingNode {
+		return ""
+	}
+	if name := mappingValue(entry, "name"); name != nil && name.Kind == yaml.ScalarNode {
+		return name.Value
+	}
+	return ""
+}
+
+// namedEntries reports whether every entry of a list has a name
+func namedEntries(list *yaml.Node) bool {
+	for _, entry := range list.Content {
+		if entryName(entry) == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// namedEntry returns the list entry called name, or nil
+func namedEntry(list *yaml.Node, name string) *yaml.Node {
+	for _, entry := range list.Content {
+		if entryName(entry) == name {
+			return entry
+		}
+	}
+	return nil
+}
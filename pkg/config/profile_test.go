@@ -0,0 +1,129 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profileBaseConfig = `
+ttr:
+  poll_interval: "5m"
+  log_level: "info"
+
+providers:
+  - name: "ecobee"
+    enabled: true
+    settings:
+      client_id: "test"
+      refresh_token: "test"
+
+sinks:
+  - name: "elasticsearch"
+    enabled: true
+    settings:
+      url: "http://localhost:9200"
+      index_prefix: "ttr"
+`
+
+// writeProfileConfigs writes the base config and overlays named by profile
+// into a temporary config root and returns the base path
+func writeProfileConfigs(t *testing.T, overlays map[string]string) string {
+	t.Helper()
+	tempDir := t.TempDir()
+	t.Setenv("TTR_CONFIG_ROOT", tempDir)
+	t.Setenv("TTR_PROFILE", "")
+
+	configPath := filepath.Join(tempDir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte(profileBaseConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	for profile, content := range overlays {
+		if err := os.WriteFile(filepath.Join(tempDir, "config."+profile+".yaml"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write profile config file: %v", err)
+		}
+	}
+	return configPath
+}
+
+func TestLoadConfigProfile(t *testing.T) {
+	configPath := writeProfileConfigs(t, map[string]string{"prod": `
+ttr:
+  poll_interval: "10m"
+
+sinks:
+  - name: "elasticsearch"
+    settings:
+      url: "https://es.example:9200"
+  - name: "csv"
+    enabled: true
+    settings:
+      path: "/var/lib/ttr/csv"
+`})
+
+	cfg, err := LoadConfigProfile(configPath, "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.TTR.PollInterval != 10*time.Minute {
+		t.Errorf("Expected the overlay's poll interval 10m, got %v", cfg.TTR.PollInterval)
+	}
+	if cfg.TTR.LogLevel != "info" {
+		t.Errorf("Expected the base log level to be kept, got %q", cfg.TTR.LogLevel)
+	}
+	if len(cfg.Sinks) != 2 {
+		t.Fatalf("Expected the overlay's sink to be appended, got %d sinks", len(cfg.Sinks))
+	}
+	es := cfg.Sinks[0]
+	if es.Settings["url"] != "https://es.example:9200" || es.Settings["index_prefix"] != "ttr" || !es.Enabled {
+		t.Errorf("Expected the elasticsearch sink to be merged, got %+v", es)
+	}
+
+	t.Run("profile from the environment", func(t *testing.T) {
+		t.Setenv("TTR_PROFILE", "prod")
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.TTR.PollInterval != 10*time.Minute {
+			t.Errorf("Expected the overlay's poll interval 10m, got %v", cfg.TTR.PollInterval)
+		}
+	})
+
+	t.Run("no profile reads the base only", func(t *testing.T) {
+		cfg, err := LoadConfig(configPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.TTR.PollInterval != 5*time.Minute || len(cfg.Sinks) != 1 {
+			t.Errorf("Expected the base config, got poll interval %v and %d sinks", cfg.TTR.PollInterval, len(cfg.Sinks))
+		}
+	})
+}
+
+func TestLoadConfigProfileErrors(t *testing.T) {
+	configPath := writeProfileConfigs(t, map[string]string{"typo": `
+ttr:
+  pol_interval: "10m"
+`})
+
+	tests := []struct {
+		name     string
+		profile  string
+		errorMsg string
+	}{
+		{name: "missing overlay", profile: "staging", errorMsg: "config.staging.yaml"},
+		{name: "unsafe name", profile: "../prod", errorMsg: "profile names may only contain"},
+		{name: "unknown key in overlay", profile: "typo", errorMsg: `config.typo.yaml: line 3: unknown key "pol_interval"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfigProfile(configPath, tt.profile)
+			if err == nil || !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
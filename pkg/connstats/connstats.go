@@ -0,0 +1,60 @@
+// Package connstats counts the network connections an HTTP client holds
+// open, so a sink that keeps dialing without reusing or closing connections
+// shows up as a steadily growing count before it runs out of descriptors.
+package connstats
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Counter counts the open connections of the transports it creates
+type Counter struct {
+	open atomic.Int64
+}
+
+// Open returns the number of connections currently open
+func (c *Counter) Open() int {
+	return int(c.open.Load())
+}
+
+// Transport returns a copy of http.DefaultTransport whose connections are
+// counted while open
+func (c *Counter) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		c.open.Add(1)
+		return &countedConn{Conn: conn, counter: c}, nil
+	}
+	return transport
+}
+
+// Client returns an HTTP client with the given timeout whose connections are
+// counted
+func (c *Counter) Client(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: c.Transport()}
+}
+
+// countedConn decrements its counter when first closed
+type countedConn struct {
+	net.Conn
+	counter *Counter
+	once    sync.Once
+}
+
+// Close implements net.Conn
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		c.counter.open.Add(-1)
+	})
+	return c.Conn.Close()
+}
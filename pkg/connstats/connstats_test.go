@@ -0,0 +1,36 @@
+package connstats
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCounterTracksOpenConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	var counter Counter
+	client := counter.Client(5 * time.Second)
+
+	for range 3 {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+	if open := counter.Open(); open != 1 {
+		t.Errorf("Expected one reused keep-alive connection, got %d", open)
+	}
+
+	client.CloseIdleConnections()
+	if open := counter.Open(); open != 0 {
+		t.Errorf("Expected no open connections after closing idle ones, got %d", open)
+	}
+}
@@ -4,6 +4,44 @@ import (
 	"time"
 )
 
+// Ingest records which collector run produced a document, for tracing
+// records back to an instance and polling cycle
+type Ingest struct {
+	CollectorVersion string    `json:"collector_version"`
+	Instance         string    `json:"instance"`            // instance ID, the hostname by default
+	CycleID          string    `json:"cycle_id"`            // scheduler cycle the document was written in
+	FetchedAt        time.Time `json:"fetched_at,omitzero"` // last provider request before the write
+}
+
+// Provenance is embedded in canonical documents to carry their ingest
+// metadata under an "ingest" key and their thermostat's configured tags under
+// "tags". Documents are stamped when written, after their IDs are generated,
+// so it never affects document IDs.
+type Provenance struct {
+	Ingest *Ingest           `json:"ingest,omitempty"`
+	Tags   map[string]string `json:"tags,omitempty"`
+}
+
+// SetIngest stamps the document with ingest metadata
+func (p *Provenance) SetIngest(ingest Ingest) {
+	p.Ingest = &ingest
+}
+
+// SetTags stamps the document with its thermostat's tags
+func (p *Provenance) SetTags(tags map[string]string) {
+	p.Tags = tags
+}
+
+// IngestStamper is implemented by documents that carry ingest metadata
+type IngestStamper interface {
+	SetIngest(ingest Ingest)
+}
+
+// TagStamper is implemented by documents that carry thermostat tags
+type TagStamper interface {
+	SetTags(tags map[string]string)
+}
+
 // Runtime5m represents 5-minute runtime telemetry data
 type Runtime5m struct {
 	Type            string             `json:"type"` // "runtime_5m"
@@ -18,9 +56,30 @@ type Runtime5m struct {
 	AvgTempC        *float64           `json:"avg_temp_c,omitempty"`
 	OutdoorTempC    *float64           `json:"outdoor_temp_c,omitempty"`
 	OutdoorHumidity *int               `json:"outdoor_humidity_pct,omitempty"`
-	Equipment       map[string]bool    `json:"equip,omitempty"`    // compHeat1, compHeat2, compCool1, compCool2, fan
-	Sensors         map[string]float64 `json:"sensors,omitempty"`  // sensor_id: temp_c
-	Provider        map[string]any     `json:"provider,omitempty"` // provider-specific data
+	Equipment       map[string]bool    `json:"equip,omitempty"`         // compHeat1, compHeat2, compCool1, compCool2, auxHeat1-3, fan, humidifier, dehumidifier, ventilator
+	EquipmentSecs   map[string]int     `json:"equip_seconds,omitempty"` // seconds each piece of equipment ran during the bin
+	Sensors         map[string]float64 `json:"sensors,omitempty"`       // sensor_id: temp_c
+	Location        map[string]any     `json:"location,omitempty"`      // selected device_metadata fields
+	Provider        map[string]any     `json:"provider,omitempty"`      // provider-specific data
+	Filled          bool               `json:"filled,omitempty"`        // synthesized to fill a gap; no provider reported the bin
+	Provenance
+}
+
+// RuntimeLive is a lightweight current-state reading for near-real-time displays.
+// It is only delivered to sinks that opt in to the runtime_live type.
+type RuntimeLive struct {
+	Type           string          `json:"type"` // "runtime_live"
+	ThermostatID   string          `json:"thermostat_id"`
+	ThermostatName string          `json:"thermostat_name"`
+	HouseholdID    string          `json:"household_id,omitempty"`
+	EventTime      time.Time       `json:"event_time"` // provider reading time
+	Mode           string          `json:"mode"`
+	SetHeatC       *float64        `json:"set_heat_c,omitempty"`
+	SetCoolC       *float64        `json:"set_cool_c,omitempty"`
+	TempC          *float64        `json:"temp_c,omitempty"`
+	Humidity       *int            `json:"humidity_pct,omitempty"`
+	Equipment      map[string]bool `json:"equip,omitempty"`
+	Provenance
 }
 
 // Transition represents a state change event
@@ -33,6 +92,7 @@ type Transition struct {
 	Next           State          `json:"next"`
 	Event          EventInfo      `json:"event"`
 	Provider       map[string]any `json:"provider,omitempty"`
+	Provenance
 }
 
 // State represents thermostat state at a point in time
@@ -45,20 +105,258 @@ type State struct {
 
 // EventInfo contains information about what triggered the transition
 type EventInfo struct {
-	Kind string         `json:"kind"` // hold/vacation/resume/schedule/manual/unknown
+	Kind string         `json:"kind"` // hold/vacation/resume/schedule/manual/demand_response/unknown
 	Name string         `json:"name,omitempty"`
 	Data map[string]any `json:"data,omitempty"`
 }
 
 // DeviceSnapshot represents current device state
 type DeviceSnapshot struct {
-	Type           string         `json:"type"` // "device_snapshot"
+	Type            string         `json:"type"` // "device_snapshot"
+	CollectedAt     time.Time      `json:"collected_at"`
+	ThermostatID    string         `json:"thermostat_id"`
+	ThermostatName  string         `json:"thermostat_name"`
+	Revision        string         `json:"revision,omitempty"` // provider revision the snapshot reflects
+	Model           string         `json:"model,omitempty"`    // hardware model, e.g. athenaSmart
+	FirmwareVersion string         `json:"firmware_version,omitempty"`
+	Program         any            `json:"program,omitempty"`       // provider metadata
+	EventsActive    []any          `json:"events_active,omitempty"` // active holds/vacations
+	Events          []Event        `json:"events,omitempty"`        // canonical view of EventsActive
+	Provider        map[string]any `json:"provider,omitempty"`
+	Provenance
+}
+
+// EventKindDemandResponse marks setpoint changes made by a utility or
+// provider program (Ecobee eco+, demand-response events) rather than a person
+const EventKindDemandResponse = "demand_response"
+
+// Event is a provider calendar event (hold, vacation, demand response) in canonical form
+type Event struct {
+	Kind     string    `json:"kind"` // hold/vacation/demand_response/...
+	Name     string    `json:"name,omitempty"`
+	Running  bool      `json:"running"`
+	Start    time.Time `json:"start,omitempty"`
+	End      time.Time `json:"end,omitempty"`
+	SetHeatC *float64  `json:"set_heat_c,omitempty"`
+	SetCoolC *float64  `json:"set_cool_c,omitempty"`
+}
+
+// Covers reports whether the event window contains t. Events without an end
+// time are treated as open-ended.
+func (e Event) Covers(t time.Time) bool {
+	if !e.Start.IsZero() && t.Before(e.Start) {
+		return false
+	}
+	return e.End.IsZero() || t.Before(e.End)
+}
+
+// Analysis is a periodic document derived from runtime data by an analyzer
+// (for example heat pump balance point estimation)
+type Analysis struct {
+	Type           string         `json:"type"`     // "analysis"
+	Analyzer       string         `json:"analyzer"` // e.g. "heat_pump"
+	ThermostatID   string         `json:"thermostat_id"`
+	ThermostatName string         `json:"thermostat_name"`
+	HouseholdID    string         `json:"household_id,omitempty"`
+	PeriodStart    time.Time      `json:"period_start"`
+	PeriodEnd      time.Time      `json:"period_end"`
+	Results        map[string]any `json:"results"`
+	Provenance
+}
+
+// Sensor alert kinds
+const (
+	AlertKindStuck      = "sensor_stuck"      // reading unchanged for too long
+	AlertKindJump       = "sensor_jump"       // implausible change between bins
+	AlertKindDivergence = "sensor_divergence" // sustained gap from the thermostat reading
+)
+
+// Conflict alert kinds
+const (
+	AlertKindHeatCoolConflict = "heat_cool_conflict" // zones of a household heating and cooling at once
+	AlertKindModeConflict     = "mode_conflict"      // mode at odds with the outdoor temperature
+)
+
+// Alert flags a suspected sensor fault or HVAC conflict detected in runtime
+// data. Household conflicts have no thermostat ID, and conflicts no sensor ID.
+type Alert struct {
+	Type           string         `json:"type"` // "alert"
+	Kind           string         `json:"kind"` // one of the AlertKind constants
+	EventTime      time.Time      `json:"event_time"`
+	ThermostatID   string         `json:"thermostat_id"`
+	ThermostatName string         `json:"thermostat_name"`
+	HouseholdID    string         `json:"household_id,omitempty"`
+	SensorID       string         `json:"sensor_id,omitempty"`
+	ValueC         float64        `json:"value_c"`
+	Message        string         `json:"message"`
+	Details        map[string]any `json:"details,omitempty"`
+	Provenance
+}
+
+// Recommendation kinds
+const (
+	RecommendationLowerHeatSetpoint = "lower_heat_setpoint" // deeper heating setback
+	RecommendationRaiseCoolSetpoint = "raise_cool_setpoint" // deeper cooling setback
+)
+
+// Recommendation suggests a setpoint change for one climate, with the energy
+// it is estimated to have saved over the analysis period. It is advisory
+// only; TTR never changes thermostat settings.
+type Recommendation struct {
+	Type               string         `json:"type"` // "recommendation"
+	Kind               string         `json:"kind"` // one of the Recommendation constants
+	ThermostatID       string         `json:"thermostat_id"`
+	ThermostatName     string         `json:"thermostat_name"`
+	HouseholdID        string         `json:"household_id,omitempty"`
+	PeriodStart        time.Time      `json:"period_start"`
+	PeriodEnd          time.Time      `json:"period_end"`
+	Climate            string         `json:"climate"`
+	CurrentSetpointC   float64        `json:"current_setpoint_c"`
+	SuggestedSetpointC float64        `json:"suggested_setpoint_c"`
+	EstimatedKWhSaved  float64        `json:"estimated_kwh_saved"`
+	Message            string         `json:"message"`
+	Details            map[string]any `json:"details,omitempty"`
+	Provenance
+}
+
+// FirmwareChange records a thermostat reporting a different firmware version
+// than in its previous snapshot
+type FirmwareChange struct {
+	Type           string    `json:"type"`       // "firmware_change"
+	EventTime      time.Time `json:"event_time"` // collection time of the first snapshot with the new version
+	ThermostatID   string    `json:"thermostat_id"`
+	ThermostatName string    `json:"thermostat_name"`
+	HouseholdID    string    `json:"household_id,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	PrevVersion    string    `json:"prev_version"`
+	NextVersion    string    `json:"next_version"`
+	Provenance
+}
+
+// Connectivity records a thermostat losing or regaining its connection to the
+// provider's service
+type Connectivity struct {
+	Type           string    `json:"type"` // "connectivity"
+	EventTime      time.Time `json:"event_time"`
+	ThermostatID   string    `json:"thermostat_id"`
+	ThermostatName string    `json:"thermostat_name"`
+	HouseholdID    string    `json:"household_id,omitempty"`
+	Connected      bool      `json:"connected"`
+	Provenance
+}
+
+// DeviceMetadata describes where a thermostat is installed and what it controls
+type DeviceMetadata struct {
+	Type           string         `json:"type"` // "device_metadata"
 	CollectedAt    time.Time      `json:"collected_at"`
 	ThermostatID   string         `json:"thermostat_id"`
 	ThermostatName string         `json:"thermostat_name"`
-	Program        any            `json:"program,omitempty"`       // provider metadata
-	EventsActive   []any          `json:"events_active,omitempty"` // active holds/vacations
+	HouseholdID    string         `json:"household_id,omitempty"`
+	Location       Location       `json:"location"`
 	Provider       map[string]any `json:"provider,omitempty"`
+	Provenance
+}
+
+// Location holds household and installation details for a thermostat.
+// Fields are optional; providers fill what they know and config fills the rest.
+type Location struct {
+	City          string   `json:"city,omitempty"`
+	Region        string   `json:"region,omitempty"` // state or province
+	Country       string   `json:"country,omitempty"`
+	PostalCode    string   `json:"postal_code,omitempty"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	SquareFootage *int     `json:"square_footage,omitempty"`
+	HVACType      string   `json:"hvac_type,omitempty"` // heat_pump, forced_air, boiler, ...
+	TimeZone      string   `json:"time_zone,omitempty"` // IANA name, e.g. America/Chicago
+}
+
+// Merge returns l with every field that is set in override replaced
+func (l Location) Merge(override Location) Location {
+	merged := l
+	if override.City != "" {
+		merged.City = override.City
+	}
+	if override.Region != "" {
+		merged.Region = override.Region
+	}
+	if override.Country != "" {
+		merged.Country = override.Country
+	}
+	if override.PostalCode != "" {
+		merged.PostalCode = override.PostalCode
+	}
+	if override.Latitude != nil {
+		merged.Latitude = override.Latitude
+	}
+	if override.Longitude != nil {
+		merged.Longitude = override.Longitude
+	}
+	if override.SquareFootage != nil {
+		merged.SquareFootage = override.SquareFootage
+	}
+	if override.HVACType != "" {
+		merged.HVACType = override.HVACType
+	}
+	if override.TimeZone != "" {
+		merged.TimeZone = override.TimeZone
+	}
+	return merged
+}
+
+// IsZero reports whether no location field is set
+func (l Location) IsZero() bool {
+	return l == Location{}
+}
+
+// Select returns the named fields (by JSON name) that are set, for injection
+// into other documents. Unknown and unset fields are skipped.
+func (l Location) Select(fields []string) map[string]any {
+	selected := make(map[string]any, len(fields))
+	for _, field := range fields {
+		switch field {
+		case "city":
+			if l.City != "" {
+				selected[field] = l.City
+			}
+		case "region":
+			if l.Region != "" {
+				selected[field] = l.Region
+			}
+		case "country":
+			if l.Country != "" {
+				selected[field] = l.Country
+			}
+		case "postal_code":
+			if l.PostalCode != "" {
+				selected[field] = l.PostalCode
+			}
+		case "latitude":
+			if l.Latitude != nil {
+				selected[field] = *l.Latitude
+			}
+		case "longitude":
+			if l.Longitude != nil {
+				selected[field] = *l.Longitude
+			}
+		case "square_footage":
+			if l.SquareFootage != nil {
+				selected[field] = *l.SquareFootage
+			}
+		case "hvac_type":
+			if l.HVACType != "" {
+				selected[field] = l.HVACType
+			}
+		case "time_zone":
+			if l.TimeZone != "" {
+				selected[field] = l.TimeZone
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return nil
+	}
+	return selected
 }
 
 // EquipmentState represents the state of HVAC equipment
@@ -112,4 +410,25 @@ type DocumentIDGenerator interface {
 
 	// GenerateDeviceSnapshotID generates ID for device_snapshot documents
 	GenerateDeviceSnapshotID(doc *DeviceSnapshot) (string, error)
+
+	// GenerateAnalysisID generates ID for analysis documents
+	GenerateAnalysisID(doc *Analysis) (string, error)
+
+	// GenerateDeviceMetadataID generates ID for device_metadata documents
+	GenerateDeviceMetadataID(doc *DeviceMetadata) (string, error)
+
+	// GenerateRuntimeLiveID generates ID for runtime_live documents
+	GenerateRuntimeLiveID(doc *RuntimeLive) (string, error)
+
+	// GenerateAlertID generates ID for alert documents
+	GenerateAlertID(doc *Alert) (string, error)
+
+	// GenerateRecommendationID generates ID for recommendation documents
+	GenerateRecommendationID(doc *Recommendation) (string, error)
+
+	// GenerateConnectivityID generates ID for connectivity documents
+	GenerateConnectivityID(doc *Connectivity) (string, error)
+
+	// GenerateFirmwareChangeID generates ID for firmware_change documents
+	GenerateFirmwareChangeID(doc *FirmwareChange) (string, error)
 }
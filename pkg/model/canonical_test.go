@@ -226,3 +226,61 @@ func TestEquipmentMapRoundTrip(t *testing.T) {
 		t.Errorf("Round trip failed. Original: %+v, RoundTrip: %+v", original, roundTrip)
 	}
 }
+
+func TestLocationMergeAndSelect(t *testing.T) {
+	lat := 41.88
+	sqft := 1800
+	provider := Location{City: "Chicago", Region: "IL", Latitude: &lat}
+	override := Location{City: "Evanston", SquareFootage: &sqft, HVACType: "heat_pump"}
+
+	merged := provider.Merge(override)
+	if merged.City != "Evanston" || merged.Region != "IL" {
+		t.Errorf("Expected override city and provider region, got %+v", merged)
+	}
+	if merged.Latitude == nil || *merged.Latitude != lat {
+		t.Error("Expected provider latitude to be kept")
+	}
+	if merged.SquareFootage == nil || *merged.SquareFootage != sqft {
+		t.Error("Expected override square footage to be applied")
+	}
+
+	tests := []struct {
+		name     string
+		fields   []string
+		expected map[string]any
+	}{
+		{
+			name:     "set fields are selected",
+			fields:   []string{"city", "hvac_type", "square_footage"},
+			expected: map[string]any{"city": "Evanston", "hvac_type": "heat_pump", "square_footage": 1800},
+		},
+		{
+			name:     "unset and unknown fields are skipped",
+			fields:   []string{"country", "bogus", "latitude"},
+			expected: map[string]any{"latitude": 41.88},
+		},
+		{
+			name:     "nothing selected",
+			fields:   []string{"postal_code"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := merged.Select(tt.fields)
+			if len(selected) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, selected)
+			}
+			for key, value := range tt.expected {
+				if selected[key] != value {
+					t.Errorf("Expected %s=%v, got %v", key, value, selected[key])
+				}
+			}
+		})
+	}
+
+	if !(Location{}).IsZero() || merged.IsZero() {
+		t.Error("Expected IsZero to report only the empty location")
+	}
+}
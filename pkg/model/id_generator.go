@@ -19,6 +19,10 @@ const (
 //   - runtime_5m: thermostat_id:event_time:type:hash(body)
 //   - transition: thermostat_id:event_time:hash(prev,next)
 //   - device_snapshot: thermostat_id:collected_at
+//   - analysis: thermostat_id:analyzer:period_start
+//   - device_metadata: thermostat_id:metadata:hash(location)
+//   - runtime_live: thermostat_id:live:event_time
+//   - alert: thermostat_id:alert:sensor_id:kind:event_time
 type IDGenerator struct{}
 
 // NewIDGenerator creates a new ID generator
@@ -34,7 +38,10 @@ func (g *IDGenerator) GenerateRuntime5mID(doc *Runtime5m) (string, error) {
 	}
 
 	eventTimeStr := doc.EventTime.Format(timestampFormat)
-	bodyHash, err := g.hashDocument(doc)
+	// Ingest metadata differs between runs, so it is left out of the hash
+	unstamped := *doc
+	unstamped.Provenance = Provenance{}
+	bodyHash, err := g.hashDocument(&unstamped)
 	if err != nil {
 		return "", fmt.Errorf("hashing runtime document: %w", err)
 	}
@@ -67,6 +74,102 @@ func (g *IDGenerator) GenerateDeviceSnapshotID(doc *DeviceSnapshot) (string, err
 	return fmt.Sprintf("%s:%s", doc.ThermostatID, collectedAtStr), nil
 }
 
+// GenerateAnalysisID generates a deterministic ID for analysis documents
+// Format: thermostat_id:analyzer:period_start, with household_id in place of
+// thermostat_id for household analyses
+// Re-analyzing the same period overwrites the earlier result.
+func (g *IDGenerator) GenerateAnalysisID(doc *Analysis) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	subject := doc.ThermostatID
+	if subject == "" {
+		subject = doc.HouseholdID
+	}
+	periodStartStr := doc.PeriodStart.Format(timestampFormat)
+	return fmt.Sprintf("%s:%s:%s", subject, doc.Analyzer, periodStartStr), nil
+}
+
+// GenerateDeviceMetadataID generates a deterministic ID for device_metadata documents
+// Format: thermostat_id:metadata:hash(location)
+// Refreshing unchanged metadata overwrites the existing document.
+func (g *IDGenerator) GenerateDeviceMetadataID(doc *DeviceMetadata) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	locationHash, err := g.hashDocument(doc.Location)
+	if err != nil {
+		return "", fmt.Errorf("hashing device metadata: %w", err)
+	}
+	return fmt.Sprintf("%s:metadata:%s", doc.ThermostatID, locationHash), nil
+}
+
+// GenerateRuntimeLiveID generates a deterministic ID for runtime_live documents
+// Format: thermostat_id:live:event_time
+// Polls that see no new provider reading overwrite the same document.
+func (g *IDGenerator) GenerateRuntimeLiveID(doc *RuntimeLive) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	eventTimeStr := doc.EventTime.Format(timestampFormat)
+	return fmt.Sprintf("%s:live:%s", doc.ThermostatID, eventTimeStr), nil
+}
+
+// GenerateAlertID generates a deterministic ID for alert documents
+// Format: thermostat_id:alert:sensor_id:kind:event_time, with household_id in
+// place of thermostat_id for household alerts
+// Re-detecting the same fault from re-fetched runtime data overwrites the alert.
+func (g *IDGenerator) GenerateAlertID(doc *Alert) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	subject := doc.ThermostatID
+	if subject == "" {
+		subject = doc.HouseholdID
+	}
+	eventTimeStr := doc.EventTime.Format(timestampFormat)
+	return fmt.Sprintf("%s:alert:%s:%s:%s", subject, doc.SensorID, doc.Kind, eventTimeStr), nil
+}
+
+// GenerateRecommendationID generates a deterministic ID for recommendation documents
+// Format: thermostat_id:recommendation:kind:climate:period_start
+// Re-analyzing the same period overwrites the earlier recommendation.
+func (g *IDGenerator) GenerateRecommendationID(doc *Recommendation) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	periodStartStr := doc.PeriodStart.Format(timestampFormat)
+	return fmt.Sprintf("%s:recommendation:%s:%s:%s", doc.ThermostatID, doc.Kind, doc.Climate, periodStartStr), nil
+}
+
+// GenerateConnectivityID generates a deterministic ID for connectivity documents
+// Format: thermostat_id:connectivity:event_time
+func (g *IDGenerator) GenerateConnectivityID(doc *Connectivity) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	eventTimeStr := doc.EventTime.Format(timestampFormat)
+	return fmt.Sprintf("%s:connectivity:%s", doc.ThermostatID, eventTimeStr), nil
+}
+
+// GenerateFirmwareChangeID generates a deterministic ID for firmware_change documents
+// Format: thermostat_id:firmware:next_version
+// A version is only ever installed once per thermostat, so detecting the same
+// update again overwrites the document.
+func (g *IDGenerator) GenerateFirmwareChangeID(doc *FirmwareChange) (string, error) {
+	if doc == nil {
+		return "", errNilDocument
+	}
+
+	return fmt.Sprintf("%s:firmware:%s", doc.ThermostatID, doc.NextVersion), nil
+}
+
 // hashDocument creates a hash of the document body
 func (g *IDGenerator) hashDocument(doc any) (string, error) {
 	docBytes, err := json.Marshal(doc)
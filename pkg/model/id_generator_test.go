@@ -73,6 +73,29 @@ func TestIDGenerator_GenerateRuntime5mID(t *testing.T) {
 		}
 	})
 
+	t.Run("ingest metadata and tags do not change the ID", func(t *testing.T) {
+		doc := &Runtime5m{
+			Type:         "runtime_5m",
+			ThermostatID: "test-123",
+			EventTime:    time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		}
+
+		id1, err := gen.GenerateRuntime5mID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		doc.SetIngest(Ingest{CollectorVersion: "1.2.3", Instance: "host-a", CycleID: "polling-20240115T103000Z"})
+		doc.SetTags(map[string]string{"floor": "upstairs"})
+		id2, err := gen.GenerateRuntime5mID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+
+		if id1 != id2 {
+			t.Errorf("Stamped document should keep its ID: %s != %s", id1, id2)
+		}
+	})
+
 	t.Run("handles nil document", func(t *testing.T) {
 		_, err := gen.GenerateRuntime5mID(nil)
 		if err == nil {
@@ -173,3 +196,258 @@ func TestIDGenerator_GenerateDeviceSnapshotID(t *testing.T) {
 		}
 	})
 }
+
+func TestIDGenerator_GenerateAnalysisID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+
+	t.Run("generates deterministic ID", func(t *testing.T) {
+		doc := &Analysis{
+			Type:         "analysis",
+			Analyzer:     "heat_pump",
+			ThermostatID: "test-123",
+			PeriodStart:  time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+			PeriodEnd:    time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC),
+			Results:      map[string]any{"defrost_cycles": 3},
+		}
+
+		id, err := gen.GenerateAnalysisID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+
+		expected := "test-123:heat_pump:2024-01-15T00:00:00Z"
+		if id != expected {
+			t.Errorf("Expected ID %s, got %s", expected, id)
+		}
+
+		// Results do not affect the ID so re-analysis overwrites
+		doc.Results = map[string]any{"defrost_cycles": 4}
+		id2, err := gen.GenerateAnalysisID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		if id != id2 {
+			t.Errorf("Expected stable ID across results, got %s and %s", id, id2)
+		}
+	})
+
+	t.Run("uses the household without a thermostat", func(t *testing.T) {
+		doc := &Analysis{
+			Type:        "analysis",
+			Analyzer:    "household",
+			HouseholdID: "house-1",
+			PeriodStart: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		}
+
+		id, err := gen.GenerateAnalysisID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		if expected := "house-1:household:2024-01-15T00:00:00Z"; id != expected {
+			t.Errorf("Expected ID %s, got %s", expected, id)
+		}
+	})
+
+	t.Run("handles nil document", func(t *testing.T) {
+		_, err := gen.GenerateAnalysisID(nil)
+		if err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateDeviceMetadataID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	newDoc := func(city string, collectedAt time.Time) *DeviceMetadata {
+		return &DeviceMetadata{
+			Type:         "device_metadata",
+			ThermostatID: "test-123",
+			CollectedAt:  collectedAt,
+			Location:     Location{City: city, HVACType: "heat_pump"},
+		}
+	}
+
+	t.Run("unchanged location keeps the same ID", func(t *testing.T) {
+		id1, err := gen.GenerateDeviceMetadataID(newDoc("Chicago", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)))
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		id2, err := gen.GenerateDeviceMetadataID(newDoc("Chicago", time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)))
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		if id1 != id2 {
+			t.Errorf("Expected refresh of unchanged metadata to keep ID, got %s and %s", id1, id2)
+		}
+	})
+
+	t.Run("changed location gets a new ID", func(t *testing.T) {
+		collectedAt := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+		id1, _ := gen.GenerateDeviceMetadataID(newDoc("Chicago", collectedAt))
+		id2, _ := gen.GenerateDeviceMetadataID(newDoc("Denver", collectedAt))
+		if id1 == id2 {
+			t.Error("Expected different locations to produce different IDs")
+		}
+	})
+
+	t.Run("handles nil document", func(t *testing.T) {
+		_, err := gen.GenerateDeviceMetadataID(nil)
+		if err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateRuntimeLiveID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	temp := 21.5
+	doc := &RuntimeLive{
+		Type:         "runtime_live",
+		ThermostatID: "test-123",
+		EventTime:    time.Date(2024, 1, 15, 10, 31, 12, 0, time.UTC),
+		TempC:        &temp,
+	}
+
+	id, err := gen.GenerateRuntimeLiveID(doc)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if expected := "test-123:live:2024-01-15T10:31:12Z"; id != expected {
+		t.Errorf("Expected ID %s, got %s", expected, id)
+	}
+
+	t.Run("handles nil document", func(t *testing.T) {
+		if _, err := gen.GenerateRuntimeLiveID(nil); err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateAlertID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	doc := &Alert{
+		Type:         "alert",
+		Kind:         AlertKindStuck,
+		ThermostatID: "test-123",
+		SensorID:     "rs:100",
+		EventTime:    time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		ValueC:       20.5,
+	}
+
+	id, err := gen.GenerateAlertID(doc)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if expected := "test-123:alert:rs:100:sensor_stuck:2024-01-15T10:30:00Z"; id != expected {
+		t.Errorf("Expected ID %s, got %s", expected, id)
+	}
+
+	t.Run("uses the household without a thermostat", func(t *testing.T) {
+		doc := &Alert{
+			Type:        "alert",
+			Kind:        AlertKindHeatCoolConflict,
+			HouseholdID: "house-1",
+			EventTime:   time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC),
+		}
+		id, err := gen.GenerateAlertID(doc)
+		if err != nil {
+			t.Fatalf("Failed to generate ID: %v", err)
+		}
+		if expected := "house-1:alert::heat_cool_conflict:2024-01-15T10:30:00Z"; id != expected {
+			t.Errorf("Expected ID %s, got %s", expected, id)
+		}
+	})
+
+	t.Run("handles nil document", func(t *testing.T) {
+		if _, err := gen.GenerateAlertID(nil); err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateRecommendationID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	doc := &Recommendation{
+		Type:         "recommendation",
+		Kind:         RecommendationLowerHeatSetpoint,
+		ThermostatID: "test-123",
+		PeriodStart:  time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		Climate:      "Sleep",
+	}
+
+	id, err := gen.GenerateRecommendationID(doc)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if expected := "test-123:recommendation:lower_heat_setpoint:Sleep:2024-01-15T00:00:00Z"; id != expected {
+		t.Errorf("Expected ID %s, got %s", expected, id)
+	}
+
+	t.Run("handles nil document", func(t *testing.T) {
+		if _, err := gen.GenerateRecommendationID(nil); err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateConnectivityID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	doc := &Connectivity{
+		Type:         "connectivity",
+		ThermostatID: "test-123",
+		EventTime:    time.Date(2024, 1, 15, 10, 32, 10, 0, time.UTC),
+	}
+
+	id, err := gen.GenerateConnectivityID(doc)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if expected := "test-123:connectivity:2024-01-15T10:32:10Z"; id != expected {
+		t.Errorf("Expected ID %s, got %s", expected, id)
+	}
+
+	t.Run("handles nil document", func(t *testing.T) {
+		if _, err := gen.GenerateConnectivityID(nil); err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
+
+func TestIDGenerator_GenerateFirmwareChangeID(t *testing.T) {
+	t.Parallel()
+
+	gen := NewIDGenerator()
+	doc := &FirmwareChange{
+		Type:         "firmware_change",
+		ThermostatID: "test-123",
+		EventTime:    time.Date(2024, 1, 15, 10, 32, 10, 0, time.UTC),
+		PrevVersion:  "4.8.7.132",
+		NextVersion:  "4.8.8.21",
+	}
+
+	id, err := gen.GenerateFirmwareChangeID(doc)
+	if err != nil {
+		t.Fatalf("Failed to generate ID: %v", err)
+	}
+	if expected := "test-123:firmware:4.8.8.21"; id != expected {
+		t.Errorf("Expected ID %s, got %s", expected, id)
+	}
+
+	t.Run("handles nil document", func(t *testing.T) {
+		if _, err := gen.GenerateFirmwareChangeID(nil); err == nil {
+			t.Error("Expected error for nil document")
+		}
+	})
+}
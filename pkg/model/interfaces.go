@@ -3,6 +3,8 @@ package model
 import (
 	"context"
 	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
 )
 
 // ProviderInfo contains metadata about a provider implementation
@@ -25,6 +27,9 @@ type ThermostatRef struct {
 	Name        string `json:"name"`
 	Provider    string `json:"provider"`
 	HouseholdID string `json:"household_id,omitempty"`
+	// TimeZone is the IANA name of the zone the provider reports local
+	// times in, when known
+	TimeZone string `json:"time_zone,omitempty"`
 }
 
 // AuthManager handles authentication for providers
@@ -39,24 +44,71 @@ type AuthManager interface {
 	IsTokenValid(ctx context.Context) bool
 }
 
+// NoAuth is the AuthManager of providers whose API needs no credentials,
+// such as a thermostat's local API. Its token is always valid and empty.
+type NoAuth struct{}
+
+// RefreshToken does nothing
+func (NoAuth) RefreshToken(ctx context.Context) error {
+	return nil
+}
+
+// GetAccessToken returns an empty token
+func (NoAuth) GetAccessToken(ctx context.Context) (string, error) {
+	return "", nil
+}
+
+// IsTokenValid always reports a valid token
+func (NoAuth) IsTokenValid(ctx context.Context) bool {
+	return true
+}
+
 // Summary contains high-level thermostat information for change detection
 type Summary struct {
 	ThermostatRef ThermostatRef `json:"thermostat_ref"`
 	Revision      string        `json:"revision"`
 	LastUpdate    time.Time     `json:"last_update"`
+	// Connected reports whether the thermostat is connected to the provider's
+	// service; nil when the provider does not say
+	Connected *bool `json:"connected,omitempty"`
+}
+
+// BulkSummaryProvider is implemented by providers that can return summaries
+// for all of their thermostats in one request. It is optional; the scheduler
+// prefers it over per-thermostat GetSummary calls when available.
+type BulkSummaryProvider interface {
+	// GetSummaries returns summaries keyed by thermostat ID
+	GetSummaries(ctx context.Context) (map[string]Summary, error)
+}
+
+// BulkRuntimeProvider is implemented by providers that can return runtime data
+// for several thermostats in one request. It is optional; the scheduler groups
+// a provider's thermostats into bulk requests when available.
+type BulkRuntimeProvider interface {
+	// GetRuntimeMulti returns runtime rows keyed by thermostat ID for the given range
+	GetRuntimeMulti(ctx context.Context, trs []ThermostatRef, from, to time.Time) (map[string][]RuntimeRow, error)
 }
 
 // Snapshot contains current thermostat state and active events
 type Snapshot struct {
 	ThermostatRef ThermostatRef `json:"thermostat_ref"`
 	CollectedAt   time.Time     `json:"collected_at"`
-	Program       any           `json:"program,omitempty"`
-	EventsActive  []any         `json:"events_active,omitempty"`
+	Revision      string        `json:"revision,omitempty"`
+	// Model and FirmwareVersion identify the hardware and the software it
+	// runs, when the provider reports them
+	Model           string  `json:"model,omitempty"`
+	FirmwareVersion string  `json:"firmware_version,omitempty"`
+	Program         any     `json:"program,omitempty"`
+	EventsActive    []any   `json:"events_active,omitempty"`
+	Events          []Event `json:"events,omitempty"`
 }
 
-// RuntimeRow contains 5-minute runtime data
+// RuntimeRow contains 5-minute runtime data. Temperatures are expected in
+// Celsius; a provider that cannot convert declares its Unit and the
+// normalizer converts instead.
 type RuntimeRow struct {
 	ThermostatRef   ThermostatRef      `json:"thermostat_ref"`
+	Unit            temperature.Unit   `json:"unit,omitempty"` // unit of the temperature fields; empty means Celsius
 	EventTime       time.Time          `json:"event_time"`
 	Mode            string             `json:"mode"`
 	Climate         string             `json:"climate"`
@@ -66,7 +118,117 @@ type RuntimeRow struct {
 	OutdoorTempC    *float64           `json:"outdoor_temp_c,omitempty"`
 	OutdoorHumidity *int               `json:"outdoor_humidity_pct,omitempty"`
 	Equipment       map[string]bool    `json:"equip,omitempty"`
+	EquipmentSecs   map[string]int     `json:"equip_seconds,omitempty"` // seconds each piece of equipment ran during the bin
 	Sensors         map[string]float64 `json:"sensors,omitempty"`
+	Filled          bool               `json:"filled,omitempty"` // synthesized by the gap fill policy, not reported
+}
+
+// Metadata contains location and installation details for a thermostat
+type Metadata struct {
+	ThermostatRef ThermostatRef `json:"thermostat_ref"`
+	CollectedAt   time.Time     `json:"collected_at"`
+	Location      Location      `json:"location"`
+}
+
+// CredentialReloader is implemented by providers and sinks that read their
+// credentials from files. It is optional; the application checks for it when
+// reloading credentials on SIGHUP or on the configured interval.
+type CredentialReloader interface {
+	// ReloadCredentials re-reads credential files and reports whether any changed
+	ReloadCredentials() (bool, error)
+}
+
+// BackgroundWorker is implemented by providers with work to do between
+// polls, such as refreshing tokens before they expire. It is optional; the
+// service runs it alongside the scheduler.
+type BackgroundWorker interface {
+	// RunBackground works until ctx is cancelled
+	RunBackground(ctx context.Context)
+}
+
+// TrafficReporter is implemented by providers that count the response bytes
+// they download. It is optional; the metrics collector reports the count.
+type TrafficReporter interface {
+	// BytesFetched returns the response bytes read since the provider was created
+	BytesFetched() int64
+}
+
+// ConnectionReporter is implemented by sinks that count the connections they
+// hold open. It is optional; the resource monitor reports the count and warns
+// when it keeps growing.
+type ConnectionReporter interface {
+	// OpenConnections returns the number of connections currently open
+	OpenConnections() int
+}
+
+// ValidatingSink is implemented by sinks that can check documents against
+// their backend without storing them where readers will see them. It is
+// optional; sinks without it are validated by serialization alone.
+type ValidatingSink interface {
+	// ValidateOnly switches the sink to validating writes; call it before Open
+	ValidateOnly()
+}
+
+// DocumentReader is implemented by sinks that can read back a document they
+// stored. It is optional; the scheduler uses it to verify sampled writes.
+type DocumentReader interface {
+	// ReadDocument returns the stored JSON body of doc, located by its ID and
+	// type, and whether it was found
+	ReadDocument(ctx context.Context, doc Doc) ([]byte, bool, error)
+}
+
+// MetadataProvider is implemented by providers that can report where a
+// thermostat is installed. It is optional; the scheduler checks for it.
+type MetadataProvider interface {
+	// GetMetadata returns location and installation details for a thermostat
+	GetMetadata(ctx context.Context, tr ThermostatRef) (Metadata, error)
+}
+
+// LiveReading contains current thermostat values, as opposed to the
+// historical 5-minute report. Unit works as on RuntimeRow.
+type LiveReading struct {
+	ThermostatRef ThermostatRef    `json:"thermostat_ref"`
+	Unit          temperature.Unit `json:"unit,omitempty"`
+	ReadingTime   time.Time        `json:"reading_time"` // when the provider last updated the values
+	Mode          string           `json:"mode"`
+	SetHeatC      *float64         `json:"set_heat_c,omitempty"`
+	SetCoolC      *float64         `json:"set_cool_c,omitempty"`
+	TempC         *float64         `json:"temp_c,omitempty"`
+	Humidity      *int             `json:"humidity_pct,omitempty"`
+	Equipment     map[string]bool  `json:"equip,omitempty"`
+}
+
+// LiveProvider is implemented by providers that can report current runtime
+// values between historical report intervals. It is optional; the scheduler
+// checks for it.
+type LiveProvider interface {
+	// GetLive returns the thermostat's current readings
+	GetLive(ctx context.Context, tr ThermostatRef) (LiveReading, error)
+}
+
+// Control actions
+const (
+	ControlActionHold   = "hold"   // hold setpoints or a climate
+	ControlActionResume = "resume" // cancel holds and resume the program
+)
+
+// ControlCommand is a change to push to a thermostat. A hold sets either
+// both setpoints or a climate.
+type ControlCommand struct {
+	Action        string   `json:"action"` // one of the ControlAction constants
+	HeatSetpointC *float64 `json:"heat_setpoint_c,omitempty"`
+	CoolSetpointC *float64 `json:"cool_setpoint_c,omitempty"`
+	Climate       string   `json:"climate,omitempty"`
+	// HoldHours ends the hold after this many hours; 0 holds until the
+	// next scheduled program change
+	HoldHours int `json:"hold_hours,omitempty"`
+}
+
+// Controller is implemented by providers that can change thermostat
+// settings. It is optional and only used when control is explicitly enabled.
+type Controller interface {
+	// Control applies a command to a thermostat
+	Control(ctx context.Context, tr ThermostatRef, cmd ControlCommand) error
 }
 
 // Provider defines the interface for thermostat data providers
@@ -102,6 +264,16 @@ type WriteResult struct {
 	SuccessCount int      `json:"success_count"`
 	ErrorCount   int      `json:"error_count"`
 	Errors       []string `json:"errors,omitempty"`
+
+	// PayloadBytes is the size of the serialized request bodies and
+	// SentBytes what went over the wire after compression. Both are zero
+	// when the sink does not report them.
+	PayloadBytes int64 `json:"payload_bytes,omitempty"`
+	SentBytes    int64 `json:"sent_bytes,omitempty"`
+
+	// DroppedCount is the number of documents deliberately not stored,
+	// such as those a write pipeline or document type routing filters out
+	DroppedCount int `json:"dropped_count,omitempty"`
 }
 
 // Sink defines the interface for data storage sinks
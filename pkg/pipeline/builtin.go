@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Built-in transform names
+const (
+	DropFieldsTransform = "drop_fields"
+	KeepFieldsTransform = "keep_fields"
+	RoundTransform      = "round"
+	AddTagsTransform    = "add_tags"
+)
+
+func init() {
+	MustRegister(DropFieldsTransform, newDropFields)
+	MustRegister(KeepFieldsTransform, newKeepFields)
+	MustRegister(RoundTransform, newRound)
+	MustRegister(AddTagsTransform, newAddTags)
+}
+
+// newDropFields removes fields by dotted path, e.g. "provider" or "sensors.rs_1"
+//
+// Settings:
+//   - fields: list of dotted field paths to remove (required)
+func newDropFields(settings map[string]any) (Transform, error) {
+	fields, err := stringList(settings, "fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields is required")
+	}
+
+	paths := make([][]string, len(fields))
+	for i, field := range fields {
+		paths[i] = strings.Split(field, ".")
+	}
+
+	return func(_ string, body map[string]any) (map[string]any, error) {
+		for _, path := range paths {
+			deletePath(body, path)
+		}
+		return body, nil
+	}, nil
+}
+
+// identityFields are kept by keep_fields whatever its settings, since sinks
+// partition, key and deduplicate documents on them
+var identityFields = []string{"type", "thermostat_id", "event_time", "collected_at", "ingest"}
+
+// newKeepFields removes every field except those listed by dotted path, e.g.
+// "equip" keeps the whole equipment map and "sensors.rs_1" one sensor.
+// Identity fields are always kept.
+//
+// Settings:
+//   - fields: list of dotted field paths to keep (required)
+func newKeepFields(settings map[string]any) (Transform, error) {
+	fields, err := stringList(settings, "fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("fields is required")
+	}
+
+	keep := fieldTree{}
+	for _, field := range slices.Concat(fields, identityFields) {
+		keep.add(strings.Split(field, "."))
+	}
+
+	return func(_ string, body map[string]any) (map[string]any, error) {
+		keep.prune(body)
+		return body, nil
+	}, nil
+}
+
+// fieldTree holds a set of dotted paths; a nil subtree keeps everything
+// below it
+type fieldTree map[string]fieldTree
+
+// add inserts a path, which supersedes any longer paths beneath it
+func (t fieldTree) add(path []string) {
+	for len(path) > 1 {
+		next, exists := t[path[0]]
+		if exists && next == nil {
+			return
+		}
+		if !exists {
+			next = fieldTree{}
+			t[path[0]] = next
+		}
+		t, path = next, path[1:]
+	}
+	t[path[0]] = nil
+}
+
+// prune removes the fields of body not in the tree. A kept path through a
+// value that is not an object keeps the value whole.
+func (t fieldTree) prune(body map[string]any) {
+	for key, value := range body {
+		subtree, ok := t[key]
+		if !ok {
+			delete(body, key)
+			continue
+		}
+		if nested, isMap := value.(map[string]any); isMap && subtree != nil {
+			subtree.prune(nested)
+		}
+	}
+}
+
+// newRound rounds floating point values to a fixed number of decimal places
+//
+// Settings:
+//   - decimals: number of decimal places to keep (default 1)
+//   - fields: dotted field paths to round; when empty every number is rounded
+func newRound(settings map[string]any) (Transform, error) {
+	decimals := 1
+	if raw, ok := settings["decimals"]; ok {
+		value, ok := raw.(int)
+		if !ok || value < 0 {
+			return nil, fmt.Errorf("decimals must be a non-negative integer")
+		}
+		decimals = value
+	}
+
+	fields, err := stringList(settings, "fields")
+	if err != nil {
+		return nil, err
+	}
+
+	scale := math.Pow(10, float64(decimals))
+	round := func(v any) any {
+		return roundValue(v, scale)
+	}
+
+	return func(_ string, body map[string]any) (map[string]any, error) {
+		if len(fields) == 0 {
+			return round(body).(map[string]any), nil
+		}
+		for _, field := range fields {
+			updatePath(body, strings.Split(field, "."), round)
+		}
+		return body, nil
+	}, nil
+}
+
+// newAddTags injects static key/value tags into every document
+//
+// Settings:
+//   - tags: map of tag names to values (required)
+//   - field: top-level field holding the tags (default "tags")
+func newAddTags(settings map[string]any) (Transform, error) {
+	tags, ok := settings["tags"].(map[string]any)
+	if !ok || len(tags) == 0 {
+		return nil, fmt.Errorf("tags must be a non-empty map")
+	}
+
+	field := "tags"
+	if raw, ok := settings["field"]; ok {
+		value, ok := raw.(string)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("field must be a non-empty string")
+		}
+		field = value
+	}
+
+	return func(_ string, body map[string]any) (map[string]any, error) {
+		existing, ok := body[field].(map[string]any)
+		if !ok {
+			existing = make(map[string]any, len(tags))
+		}
+		for key, value := range tags {
+			existing[key] = value
+		}
+		body[field] = existing
+		return body, nil
+	}, nil
+}
+
+// roundValue rounds numbers found anywhere within v
+func roundValue(v any, scale float64) any {
+	switch value := v.(type) {
+	case float64:
+		return math.Round(value*scale) / scale
+	case map[string]any:
+		for key, nested := range value {
+			value[key] = roundValue(nested, scale)
+		}
+		return value
+	case []any:
+		for i, nested := range value {
+			value[i] = roundValue(nested, scale)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// deletePath removes the value at a dotted path if present
+func deletePath(body map[string]any, path []string) {
+	for len(path) > 1 {
+		next, ok := body[path[0]].(map[string]any)
+		if !ok {
+			return
+		}
+		body, path = next, path[1:]
+	}
+	delete(body, path[0])
+}
+
+// updatePath replaces the value at a dotted path if present
+func updatePath(body map[string]any, path []string, update func(any) any) {
+	for len(path) > 1 {
+		next, ok := body[path[0]].(map[string]any)
+		if !ok {
+			return
+		}
+		body, path = next, path[1:]
+	}
+	if value, ok := body[path[0]]; ok {
+		body[path[0]] = update(value)
+	}
+}
+
+// stringList reads an optional list of strings from settings
+func stringList(settings map[string]any, key string) ([]string, error) {
+	raw, ok := settings[key]
+	if !ok {
+		return nil, nil
+	}
+
+	switch value := raw.(type) {
+	case []string:
+		return value, nil
+	case []any:
+		out := make([]string, 0, len(value))
+		for _, item := range value {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s must be a list of strings", key)
+			}
+			out = append(out, str)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("%s must be a list of strings", key)
+	}
+}
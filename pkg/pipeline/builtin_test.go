@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuiltinTransforms(t *testing.T) {
+	tests := []struct {
+		name      string
+		transform string
+		settings  map[string]any
+		input     map[string]any
+		expected  map[string]any
+		wantErr   bool
+	}{
+		{
+			name:      "drop top-level and nested fields",
+			transform: DropFieldsTransform,
+			settings:  map[string]any{"fields": []any{"provider", "sensors.rs_1", "missing.path"}},
+			input: map[string]any{
+				"thermostat_id": "t1",
+				"provider":      map[string]any{"raw": "data"},
+				"sensors":       map[string]any{"rs_1": 21.0, "rs_2": 22.0},
+			},
+			expected: map[string]any{
+				"thermostat_id": "t1",
+				"sensors":       map[string]any{"rs_2": 22.0},
+			},
+		},
+		{
+			name:      "drop fields requires fields",
+			transform: DropFieldsTransform,
+			settings:  map[string]any{},
+			wantErr:   true,
+		},
+		{
+			name:      "keep listed fields and identity fields",
+			transform: KeepFieldsTransform,
+			settings:  map[string]any{"fields": []any{"avg_temp_c", "equip", "sensors.rs_1", "equip.fan"}},
+			input: map[string]any{
+				"type":          "runtime_5m",
+				"thermostat_id": "t1",
+				"event_time":    "2024-01-01T00:00:00Z",
+				"avg_temp_c":    21.5,
+				"equip":         map[string]any{"fan": true, "compHeat1": false},
+				"sensors":       map[string]any{"rs_1": 21.0, "rs_2": 22.0},
+				"provider":      map[string]any{"raw": "data"},
+			},
+			expected: map[string]any{
+				"type":          "runtime_5m",
+				"thermostat_id": "t1",
+				"event_time":    "2024-01-01T00:00:00Z",
+				"avg_temp_c":    21.5,
+				"equip":         map[string]any{"fan": true, "compHeat1": false},
+				"sensors":       map[string]any{"rs_1": 21.0},
+			},
+		},
+		{
+			name:      "keep fields requires fields",
+			transform: KeepFieldsTransform,
+			settings:  map[string]any{},
+			wantErr:   true,
+		},
+		{
+			name:      "round every number by default",
+			transform: RoundTransform,
+			settings:  map[string]any{},
+			input: map[string]any{
+				"avg_temp_c": 22.222222222222218,
+				"sensors":    map[string]any{"rs_1": 20.56},
+				"mode":       "heat",
+			},
+			expected: map[string]any{
+				"avg_temp_c": 22.2,
+				"sensors":    map[string]any{"rs_1": 20.6},
+				"mode":       "heat",
+			},
+		},
+		{
+			name:      "round selected fields",
+			transform: RoundTransform,
+			settings:  map[string]any{"decimals": 2, "fields": []any{"avg_temp_c"}},
+			input: map[string]any{
+				"avg_temp_c":     22.222222222222218,
+				"outdoor_temp_c": -3.3333333333333335,
+			},
+			expected: map[string]any{
+				"avg_temp_c":     22.22,
+				"outdoor_temp_c": -3.3333333333333335,
+			},
+		},
+		{
+			name:      "round rejects negative decimals",
+			transform: RoundTransform,
+			settings:  map[string]any{"decimals": -1},
+			wantErr:   true,
+		},
+		{
+			name:      "add tags merges with existing tags",
+			transform: AddTagsTransform,
+			settings:  map[string]any{"tags": map[string]any{"site": "lake-house"}},
+			input: map[string]any{
+				"tags": map[string]any{"floor": "upstairs"},
+			},
+			expected: map[string]any{
+				"tags": map[string]any{"floor": "upstairs", "site": "lake-house"},
+			},
+		},
+		{
+			name:      "add tags to custom field",
+			transform: AddTagsTransform,
+			settings:  map[string]any{"tags": map[string]any{"env": "prod"}, "field": "labels"},
+			input:     map[string]any{},
+			expected: map[string]any{
+				"labels": map[string]any{"env": "prod"},
+			},
+		},
+		{
+			name:      "add tags requires tags",
+			transform: AddTagsTransform,
+			settings:  map[string]any{"tags": map[string]any{}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := Build(tt.transform, tt.settings)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := transform("runtime_5m", tt.input)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
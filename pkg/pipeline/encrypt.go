@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptFieldsTransform encrypts selected string fields before a sink writes them
+const EncryptFieldsTransform = "encrypt_fields"
+
+// DefaultEncryptionKeyEnv is the environment variable holding the encryption
+// key when neither key nor key_env is configured
+const DefaultEncryptionKeyEnv = "TTR_ENCRYPTION_KEY"
+
+// encryptedPrefix marks and versions encrypted values
+const encryptedPrefix = "enc:v1:"
+
+// defaultEncryptedFields are the fields that identify a household
+var defaultEncryptedFields = []string{"household_id", "thermostat_name"}
+
+func init() {
+	MustRegister(EncryptFieldsTransform, newEncryptFields)
+}
+
+// FieldCipher encrypts and decrypts individual field values with AES-GCM.
+// The nonce is derived from the key and plaintext, so equal values encrypt to
+// equal ciphertexts and encrypted fields can still be grouped and filtered on.
+// This reveals which documents share a value, but not the value itself.
+type FieldCipher struct {
+	aead     cipher.AEAD
+	nonceKey []byte
+}
+
+// ParseEncryptionKey decodes a base64 AES key of 16, 24 or 32 bytes
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("decoding encryption key: %w", err)
+	}
+	switch len(key) {
+	case 16, 24, 32:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("encryption key must be 16, 24 or 32 bytes, got %d", len(key))
+	}
+}
+
+// NewFieldCipher creates a cipher from a raw AES key
+func NewFieldCipher(key []byte) (*FieldCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("creating cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("creating GCM: %w", err)
+	}
+
+	// Keep the nonce derivation key separate from the encryption key
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("ttr field nonce"))
+
+	return &FieldCipher{aead: aead, nonceKey: mac.Sum(nil)}, nil
+}
+
+// Encrypt returns plaintext as "enc:v1:<base64 nonce and ciphertext>"
+func (c *FieldCipher) Encrypt(plaintext string) string {
+	mac := hmac.New(sha256.New, c.nonceKey)
+	mac.Write([]byte(plaintext))
+	nonce := mac.Sum(nil)[:c.aead.NonceSize()]
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(sealed)
+}
+
+// Decrypt reverses Encrypt
+func (c *FieldCipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, encryptedPrefix)
+	if !ok {
+		return "", fmt.Errorf("value is not encrypted (missing %q prefix)", encryptedPrefix)
+	}
+	sealed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("decoding encrypted value: %w", err)
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("encrypted value is too short")
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypting value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// newEncryptFields encrypts string fields by dotted path. Values that are
+// empty or already encrypted are left alone.
+//
+// Settings:
+//   - fields: dotted field paths to encrypt (default household_id, thermostat_name)
+//   - key: base64 AES-128/192/256 key
+//   - key_env: environment variable holding the key when key is not set (default TTR_ENCRYPTION_KEY)
+func newEncryptFields(settings map[string]any) (Transform, error) {
+	fields, err := stringList(settings, "fields")
+	if err != nil {
+		return nil, err
+	}
+	if len(fields) == 0 {
+		fields = defaultEncryptedFields
+	}
+
+	encoded, _ := settings["key"].(string)
+	if encoded == "" {
+		keyEnv := DefaultEncryptionKeyEnv
+		if raw, ok := settings["key_env"]; ok {
+			value, ok := raw.(string)
+			if !ok || value == "" {
+				return nil, fmt.Errorf("key_env must be a non-empty string")
+			}
+			keyEnv = value
+		}
+		encoded = os.Getenv(keyEnv)
+		if encoded == "" {
+			return nil, fmt.Errorf("no key configured and %s is not set", keyEnv)
+		}
+	}
+
+	key, err := ParseEncryptionKey(encoded)
+	if err != nil {
+		return nil, err
+	}
+	fieldCipher, err := NewFieldCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	encrypt := func(v any) any {
+		value, ok := v.(string)
+		if !ok || value == "" || strings.HasPrefix(value, encryptedPrefix) {
+			return v
+		}
+		return fieldCipher.Encrypt(value)
+	}
+
+	return func(_ string, body map[string]any) (map[string]any, error) {
+		for _, field := range fields {
+			updatePath(body, strings.Split(field, "."), encrypt)
+		}
+		return body, nil
+	}, nil
+}
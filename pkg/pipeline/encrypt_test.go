@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+
+func TestEncryptFields(t *testing.T) {
+	transform, err := Build(EncryptFieldsTransform, map[string]any{"key": testEncryptionKey})
+	if err != nil {
+		t.Fatalf("Failed to build transform: %v", err)
+	}
+
+	body := map[string]any{
+		"thermostat_id":   "t1",
+		"thermostat_name": "Upstairs",
+		"household_id":    "",
+		"avg_temp_c":      21.5,
+	}
+	out, err := transform("runtime_5m", body)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	name, _ := out["thermostat_name"].(string)
+	if !strings.HasPrefix(name, encryptedPrefix) {
+		t.Fatalf("Expected thermostat_name to be encrypted, got %q", name)
+	}
+	if out["household_id"] != "" || out["thermostat_id"] != "t1" || out["avg_temp_c"] != 21.5 {
+		t.Errorf("Expected other fields untouched, got %v", out)
+	}
+
+	t.Run("equal values encrypt equally", func(t *testing.T) {
+		again, _ := transform("transition", map[string]any{"thermostat_name": "Upstairs"})
+		if again["thermostat_name"] != name {
+			t.Errorf("Expected deterministic ciphertext, got %v and %v", name, again["thermostat_name"])
+		}
+	})
+
+	t.Run("encrypted values are not encrypted twice", func(t *testing.T) {
+		twice, _ := transform("runtime_5m", map[string]any{"thermostat_name": name})
+		if twice["thermostat_name"] != name {
+			t.Errorf("Expected value to be left alone, got %v", twice["thermostat_name"])
+		}
+	})
+
+	t.Run("decrypt round trip", func(t *testing.T) {
+		key, _ := ParseEncryptionKey(testEncryptionKey)
+		fieldCipher, _ := NewFieldCipher(key)
+		plaintext, err := fieldCipher.Decrypt(name)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if plaintext != "Upstairs" {
+			t.Errorf("Expected Upstairs, got %q", plaintext)
+		}
+	})
+}
+
+func TestEncryptFieldsSettings(t *testing.T) {
+	t.Setenv("TTR_TEST_KEY", testEncryptionKey)
+
+	tests := []struct {
+		name     string
+		settings map[string]any
+		wantErr  bool
+	}{
+		{name: "key from custom env", settings: map[string]any{"key_env": "TTR_TEST_KEY", "fields": []any{"location.city"}}},
+		{name: "missing key", settings: map[string]any{"key_env": "TTR_UNSET_KEY"}, wantErr: true},
+		{name: "short key", settings: map[string]any{"key": base64.StdEncoding.EncodeToString([]byte("short"))}, wantErr: true},
+		{name: "invalid base64", settings: map[string]any{"key": "not base64!"}, wantErr: true},
+		{name: "invalid fields", settings: map[string]any{"key": testEncryptionKey, "fields": "household_id"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Build(EncryptFieldsTransform, tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFieldCipherDecryptErrors(t *testing.T) {
+	key, _ := ParseEncryptionKey(testEncryptionKey)
+	fieldCipher, err := NewFieldCipher(key)
+	if err != nil {
+		t.Fatalf("Failed to create cipher: %v", err)
+	}
+	otherKey, _ := ParseEncryptionKey(base64.StdEncoding.EncodeToString([]byte("fedcba9876543210")))
+	otherCipher, _ := NewFieldCipher(otherKey)
+
+	for name, value := range map[string]string{
+		"plaintext":  "Upstairs",
+		"bad base64": encryptedPrefix + "!!",
+		"too short":  encryptedPrefix + "AA",
+		"wrong key":  otherCipher.Encrypt("Upstairs"),
+		"tampered":   fieldCipher.Encrypt("Upstairs") + "A",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := fieldCipher.Decrypt(value); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
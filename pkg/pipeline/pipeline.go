@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Transform modifies a document body between normalization and Write.
+// The body is a private copy of the document decoded into generic JSON form,
+// so transforms may mutate it freely. Returning a nil body drops the document.
+type Transform func(docType string, body map[string]any) (map[string]any, error)
+
+// Factory builds a Transform from its configuration settings
+type Factory func(settings map[string]any) (Transform, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes a transform available by name to sink pipeline configuration.
+// Custom binaries call it from an init function before configuration is loaded.
+func Register(name string, factory Factory) error {
+	if name == "" {
+		return fmt.Errorf("transform name is required")
+	}
+	if factory == nil {
+		return fmt.Errorf("transform %s: factory is nil", name)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[name]; exists {
+		return fmt.Errorf("transform %s already registered", name)
+	}
+	registry[name] = factory
+	return nil
+}
+
+// MustRegister is like Register but panics on error, for use in init functions
+func MustRegister(name string, factory Factory) {
+	if err := Register(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Registered returns the names of all registered transforms in sorted order
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Build creates a registered transform from its settings
+func Build(name string, settings map[string]any) (Transform, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown transform %q (registered: %v)", name, Registered())
+	}
+
+	transform, err := factory(settings)
+	if err != nil {
+		return nil, fmt.Errorf("building transform %s: %w", name, err)
+	}
+	return transform, nil
+}
+
+// Step is a transform applied to a subset of document types
+type Step struct {
+	// Name identifies the step in write errors
+	Name string
+	// Types restricts the step to these document types; empty means all types
+	Types []string
+	// Transform is the function applied to matching documents
+	Transform Transform
+}
+
+// appliesTo reports whether the step should run for a document type
+func (s Step) appliesTo(docType string) bool {
+	return len(s.Types) == 0 || slices.Contains(s.Types, docType)
+}
+
+// Sink wraps a sink and runs transform steps on documents before writing them
+type Sink struct {
+	model.Sink
+	steps []Step
+}
+
+// NewSink wraps a sink with a transform pipeline
+func NewSink(sink model.Sink, steps ...Step) *Sink {
+	return &Sink{
+		Sink:  sink,
+		steps: steps,
+	}
+}
+
+// Unwrap returns the underlying sink
+func (s *Sink) Unwrap() model.Sink {
+	return s.Sink
+}
+
+// Write applies the pipeline to each document and writes the survivors to the
+// wrapped sink. Documents that fail a transform are counted as write errors
+// rather than failing the whole batch.
+func (s *Sink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	transformed := make([]model.Doc, 0, len(docs))
+	var failed model.WriteResult
+
+	for _, doc := range docs {
+		out, keep, err := s.Apply(doc)
+		if err != nil {
+			failed.ErrorCount++
+			failed.Errors = append(failed.Errors, fmt.Sprintf("ID %s: %v", doc.ID, err))
+			continue
+		}
+		if keep {
+			transformed = append(transformed, out)
+		}
+	}
+
+	failed.DroppedCount = len(docs) - len(transformed) - failed.ErrorCount
+	if len(transformed) == 0 {
+		return failed, nil
+	}
+
+	result, err := s.Sink.Write(ctx, transformed)
+	if err != nil {
+		return result, err
+	}
+
+	result.DroppedCount += failed.DroppedCount
+	result.ErrorCount += failed.ErrorCount
+	result.Errors = append(result.Errors, failed.Errors...)
+	return result, nil
+}
+
+// Apply runs all matching steps on a document and reports whether it is
+// kept. Documents with no matching steps pass through untouched.
+func (s *Sink) Apply(doc model.Doc) (model.Doc, bool, error) {
+	var body map[string]any
+	var err error
+	for _, step := range s.steps {
+		if !step.appliesTo(doc.Type) {
+			continue
+		}
+
+		if body == nil {
+			body, err = toMap(doc.Body)
+			if err != nil {
+				return doc, false, err
+			}
+		}
+
+		body, err = step.Transform(doc.Type, body)
+		if err != nil {
+			return doc, false, fmt.Errorf("transform %s: %w", step.Name, err)
+		}
+		if body == nil {
+			return doc, false, nil
+		}
+	}
+
+	if body != nil {
+		doc.Body = body
+	}
+	return doc, true, nil
+}
+
+// toMap converts a document body into a freshly allocated generic JSON map.
+// The same documents are handed to every sink, so transforms must never see
+// the original body.
+func toMap(body any) (map[string]any, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling document body: %w", err)
+	}
+
+	var out map[string]any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, fmt.Errorf("decoding document body: %w", err)
+	}
+	if out == nil {
+		out = map[string]any{}
+	}
+	return out, nil
+}
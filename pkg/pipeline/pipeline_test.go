@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// recordingSink captures the documents it is asked to write
+type recordingSink struct {
+	docs     []model.Doc
+	writeErr error
+}
+
+func (s *recordingSink) Info() model.SinkInfo {
+	return model.SinkInfo{Name: "recording"}
+}
+
+func (s *recordingSink) Open(ctx context.Context) error {
+	return nil
+}
+
+func (s *recordingSink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	if s.writeErr != nil {
+		return model.WriteResult{}, s.writeErr
+	}
+	s.docs = append(s.docs, docs...)
+	return model.WriteResult{SuccessCount: len(docs)}, nil
+}
+
+func (s *recordingSink) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	noop := func(settings map[string]any) (Transform, error) {
+		return func(_ string, body map[string]any) (map[string]any, error) { return body, nil }, nil
+	}
+
+	t.Run("custom transform is buildable", func(t *testing.T) {
+		if err := Register("test_noop", noop); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := Build("test_noop", nil); err != nil {
+			t.Errorf("Expected registered transform to build, got %v", err)
+		}
+	})
+
+	t.Run("duplicate name is rejected", func(t *testing.T) {
+		if err := Register(DropFieldsTransform, noop); err == nil {
+			t.Error("Expected error registering duplicate transform")
+		}
+	})
+
+	t.Run("empty name is rejected", func(t *testing.T) {
+		if err := Register("", noop); err == nil {
+			t.Error("Expected error registering transform without a name")
+		}
+	})
+
+	t.Run("unknown transform lists registered names", func(t *testing.T) {
+		_, err := Build("missing", nil)
+		if err == nil || !strings.Contains(err.Error(), DropFieldsTransform) {
+			t.Errorf("Expected unknown transform error listing built-ins, got %v", err)
+		}
+	})
+}
+
+func TestSinkWrite(t *testing.T) {
+	original := &model.Runtime5m{Type: "runtime_5m", ThermostatID: "t1", Mode: "heat"}
+	docs := []model.Doc{
+		{ID: "r1", Type: "runtime_5m", Body: original},
+		{ID: "s1", Type: "device_snapshot", Body: map[string]any{"thermostat_id": "t1"}},
+	}
+
+	t.Run("steps apply only to matching types", func(t *testing.T) {
+		inner := &recordingSink{}
+		sink := NewSink(inner, Step{
+			Name:  "mark",
+			Types: []string{"runtime_5m"},
+			Transform: func(_ string, body map[string]any) (map[string]any, error) {
+				body["marked"] = true
+				return body, nil
+			},
+		})
+
+		result, err := sink.Write(context.Background(), docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.SuccessCount != 2 {
+			t.Errorf("Expected 2 successes, got %d", result.SuccessCount)
+		}
+
+		body, ok := inner.docs[0].Body.(map[string]any)
+		if !ok || body["marked"] != true {
+			t.Errorf("Expected runtime document to be transformed, got %#v", inner.docs[0].Body)
+		}
+		if _, ok := inner.docs[1].Body.(map[string]any)["marked"]; ok {
+			t.Error("Expected snapshot document to be left untouched")
+		}
+		if original.Mode != "heat" || inner.docs[0].ID != "r1" {
+			t.Error("Expected original document and ID to be unchanged")
+		}
+	})
+
+	t.Run("nil body drops document", func(t *testing.T) {
+		inner := &recordingSink{}
+		sink := NewSink(inner, Step{
+			Name:  "drop",
+			Types: []string{"device_snapshot"},
+			Transform: func(_ string, _ map[string]any) (map[string]any, error) {
+				return nil, nil
+			},
+		})
+
+		result, err := sink.Write(context.Background(), docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(inner.docs) != 1 || inner.docs[0].ID != "r1" {
+			t.Errorf("Expected only runtime document to be written, got %d docs", len(inner.docs))
+		}
+		if result.DroppedCount != 1 {
+			t.Errorf("Expected 1 dropped document, got %d", result.DroppedCount)
+		}
+	})
+
+	t.Run("transform error counts as document error", func(t *testing.T) {
+		inner := &recordingSink{}
+		sink := NewSink(inner, Step{
+			Name:  "fail",
+			Types: []string{"runtime_5m"},
+			Transform: func(_ string, _ map[string]any) (map[string]any, error) {
+				return nil, errors.New("boom")
+			},
+		})
+
+		result, err := sink.Write(context.Background(), docs)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.SuccessCount != 1 || result.ErrorCount != 1 {
+			t.Errorf("Expected 1 success and 1 error, got %d/%d", result.SuccessCount, result.ErrorCount)
+		}
+		if len(result.Errors) != 1 || !strings.Contains(result.Errors[0], "transform fail") {
+			t.Errorf("Expected transform error to be reported, got %v", result.Errors)
+		}
+	})
+
+	t.Run("sink errors are passed through", func(t *testing.T) {
+		inner := &recordingSink{writeErr: errors.New("unavailable")}
+		sink := NewSink(inner)
+
+		if _, err := sink.Write(context.Background(), docs); err == nil {
+			t.Error("Expected wrapped sink error to be returned")
+		}
+	})
+
+	t.Run("unwrap returns inner sink", func(t *testing.T) {
+		inner := &recordingSink{}
+		if NewSink(inner).Unwrap() != inner {
+			t.Error("Expected Unwrap to return the wrapped sink")
+		}
+	})
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"fmt"
+)
+
+// RawPayloadTransform controls how much raw provider data a sink stores
+const RawPayloadTransform = "raw_payload"
+
+// Raw payload modes
+const (
+	// RawPayloadFull keeps the complete provider payload (default)
+	RawPayloadFull = "full"
+	// RawPayloadSummary keeps scalar provider fields and drops nested objects and lists
+	RawPayloadSummary = "summary"
+	// RawPayloadOff replaces the provider payload with a stable reference
+	RawPayloadOff = "off"
+)
+
+func init() {
+	MustRegister(RawPayloadTransform, newRawPayload)
+}
+
+// newRawPayload trims the provider namespace of each document. In summary and
+// off modes every provider entry gains a "ref" that identifies the source
+// record (thermostat revision or report row) so the raw data can be re-fetched.
+//
+// Settings:
+//   - mode: off, summary or full (default full)
+func newRawPayload(settings map[string]any) (Transform, error) {
+	mode := RawPayloadFull
+	if raw, ok := settings["mode"]; ok {
+		value, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("mode must be a string")
+		}
+		mode = value
+	}
+
+	switch mode {
+	case RawPayloadFull:
+		return func(_ string, body map[string]any) (map[string]any, error) {
+			return body, nil
+		}, nil
+	case RawPayloadSummary, RawPayloadOff:
+	default:
+		return nil, fmt.Errorf("invalid mode %q, must be one of: off, summary, full", mode)
+	}
+
+	return func(docType string, body map[string]any) (map[string]any, error) {
+		providers, ok := body["provider"].(map[string]any)
+		if !ok {
+			return body, nil
+		}
+
+		ref := ProviderReference(docType, body)
+		for name, payload := range providers {
+			trimmed := map[string]any{}
+			if mode == RawPayloadSummary {
+				if fields, ok := payload.(map[string]any); ok {
+					for key, value := range fields {
+						if isScalar(value) {
+							trimmed[key] = value
+						}
+					}
+				}
+			}
+			if ref != "" {
+				trimmed["ref"] = ref
+			}
+			providers[name] = trimmed
+		}
+		return body, nil
+	}, nil
+}
+
+// ProviderReference returns a stable identifier for the provider record a
+// document was built from:
+//   - runtime_5m: thermostat_id:runtime:event_time (the runtime report row)
+//   - transition: thermostat_id:transition:event_time
+//   - device_snapshot: thermostat_id:revision:revision, or collected_at when
+//     the provider did not report a revision
+func ProviderReference(docType string, body map[string]any) string {
+	thermostatID, _ := body["thermostat_id"].(string)
+	if thermostatID == "" {
+		return ""
+	}
+
+	switch docType {
+	case "runtime_5m":
+		if eventTime, ok := body["event_time"].(string); ok {
+			return thermostatID + ":runtime:" + eventTime
+		}
+	case "transition":
+		if eventTime, ok := body["event_time"].(string); ok {
+			return thermostatID + ":transition:" + eventTime
+		}
+	case "device_snapshot":
+		if revision, ok := body["revision"].(string); ok && revision != "" {
+			return thermostatID + ":revision:" + revision
+		}
+		if collectedAt, ok := body["collected_at"].(string); ok {
+			return thermostatID + ":snapshot:" + collectedAt
+		}
+	}
+	return ""
+}
+
+// isScalar reports whether a decoded JSON value is a string, number, bool or null
+func isScalar(value any) bool {
+	switch value.(type) {
+	case map[string]any, []any:
+		return false
+	default:
+		return true
+	}
+}
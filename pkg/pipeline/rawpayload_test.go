@@ -0,0 +1,126 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRawPayloadTransform(t *testing.T) {
+	runtimeBody := func() map[string]any {
+		return map[string]any{
+			"thermostat_id": "t1",
+			"event_time":    "2025-01-10T12:00:00Z",
+			"provider": map[string]any{
+				"ecobee": map[string]any{
+					"mode":           "heat",
+					"avg_temp_c":     21.5,
+					"thermostat_ref": map[string]any{"id": "t1"},
+					"equip":          map[string]any{"compHeat1": true},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		mode     string
+		docType  string
+		body     map[string]any
+		expected map[string]any
+	}{
+		{
+			name:     "full keeps payload",
+			mode:     RawPayloadFull,
+			docType:  "runtime_5m",
+			body:     runtimeBody(),
+			expected: runtimeBody()["provider"].(map[string]any),
+		},
+		{
+			name:    "summary keeps scalars and adds reference",
+			mode:    RawPayloadSummary,
+			docType: "runtime_5m",
+			body:    runtimeBody(),
+			expected: map[string]any{
+				"ecobee": map[string]any{
+					"mode":       "heat",
+					"avg_temp_c": 21.5,
+					"ref":        "t1:runtime:2025-01-10T12:00:00Z",
+				},
+			},
+		},
+		{
+			name:    "off stores only reference",
+			mode:    RawPayloadOff,
+			docType: "runtime_5m",
+			body:    runtimeBody(),
+			expected: map[string]any{
+				"ecobee": map[string]any{"ref": "t1:runtime:2025-01-10T12:00:00Z"},
+			},
+		},
+		{
+			name:    "snapshot references revision",
+			mode:    RawPayloadOff,
+			docType: "device_snapshot",
+			body: map[string]any{
+				"thermostat_id": "t1",
+				"collected_at":  "2025-01-10T12:00:00Z",
+				"revision":      "250110120000",
+				"provider":      map[string]any{"ecobee": map[string]any{"program": map[string]any{}}},
+			},
+			expected: map[string]any{
+				"ecobee": map[string]any{"ref": "t1:revision:250110120000"},
+			},
+		},
+		{
+			name:    "snapshot without revision references collection time",
+			mode:    RawPayloadOff,
+			docType: "device_snapshot",
+			body: map[string]any{
+				"thermostat_id": "t1",
+				"collected_at":  "2025-01-10T12:00:00Z",
+				"provider":      map[string]any{"ecobee": map[string]any{}},
+			},
+			expected: map[string]any{
+				"ecobee": map[string]any{"ref": "t1:snapshot:2025-01-10T12:00:00Z"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transform, err := Build(RawPayloadTransform, map[string]any{"mode": tt.mode})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			result, err := transform(tt.docType, tt.body)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result["provider"], tt.expected) {
+				t.Errorf("Expected provider %v, got %v", tt.expected, result["provider"])
+			}
+		})
+	}
+
+	t.Run("documents without provider data are untouched", func(t *testing.T) {
+		transform, err := Build(RawPayloadTransform, map[string]any{"mode": RawPayloadOff})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		body := map[string]any{"thermostat_id": "t1", "analyzer": "heat_pump"}
+		result, err := transform("analysis", body)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, ok := result["provider"]; ok {
+			t.Error("Expected no provider field to be added")
+		}
+	})
+
+	t.Run("invalid mode is rejected", func(t *testing.T) {
+		if _, err := Build(RawPayloadTransform, map[string]any{"mode": "partial"}); err == nil {
+			t.Error("Expected error for invalid mode")
+		}
+	})
+}
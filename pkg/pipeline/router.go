@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"slices"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// optInTypes lists document types a sink receives only when it names them in
+// its routing list. runtime_live documents arrive every 30-60 seconds and are
+// meant for low-latency consumers, not archival storage.
+var optInTypes = map[string]bool{
+	"runtime_live": true,
+}
+
+// IsOptInType reports whether docType is delivered only to sinks that ask for it
+func IsOptInType(docType string) bool {
+	return optInTypes[docType]
+}
+
+// Router wraps a sink and forwards only the document types it accepts
+type Router struct {
+	model.Sink
+	docTypes []string
+}
+
+// NewRouter wraps a sink so it receives only the listed document types.
+// An empty list accepts every type except the opt-in types.
+func NewRouter(sink model.Sink, docTypes []string) *Router {
+	return &Router{
+		Sink:     sink,
+		docTypes: docTypes,
+	}
+}
+
+// Unwrap returns the underlying sink
+func (r *Router) Unwrap() model.Sink {
+	return r.Sink
+}
+
+// Accepts reports whether the sink receives documents of docType
+func (r *Router) Accepts(docType string) bool {
+	if len(r.docTypes) == 0 {
+		return !IsOptInType(docType)
+	}
+	return slices.Contains(r.docTypes, docType)
+}
+
+// Write forwards accepted documents to the wrapped sink and reports the
+// others as dropped. A batch with no accepted documents is not forwarded at
+// all.
+func (r *Router) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	accepted := make([]model.Doc, 0, len(docs))
+	for _, doc := range docs {
+		if r.Accepts(doc.Type) {
+			accepted = append(accepted, doc)
+		}
+	}
+	dropped := len(docs) - len(accepted)
+
+	if len(accepted) == 0 {
+		return model.WriteResult{DroppedCount: dropped}, nil
+	}
+	result, err := r.Sink.Write(ctx, accepted)
+	result.DroppedCount += dropped
+	return result, err
+}
@@ -0,0 +1,68 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestRouter(t *testing.T) {
+	batch := []model.Doc{
+		{ID: "1", Type: "runtime_5m"},
+		{ID: "2", Type: "transition"},
+		{ID: "3", Type: "runtime_live"},
+	}
+
+	tests := []struct {
+		name     string
+		docTypes []string
+		expected []string
+	}{
+		{name: "default skips opt-in types", docTypes: nil, expected: []string{"1", "2"}},
+		{name: "explicit list", docTypes: []string{"runtime_live"}, expected: []string{"3"}},
+		{name: "explicit list with opt-in type", docTypes: []string{"transition", "runtime_live"}, expected: []string{"2", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &recordingSink{}
+			router := NewRouter(inner, tt.docTypes)
+
+			result, err := router.Write(context.Background(), batch)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.SuccessCount != len(tt.expected) || result.DroppedCount != len(batch)-len(tt.expected) {
+				t.Errorf("Expected %d successes and %d dropped, got %+v", len(tt.expected), len(batch)-len(tt.expected), result)
+			}
+
+			var ids []string
+			for _, doc := range inner.docs {
+				ids = append(ids, doc.ID)
+			}
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("Expected documents %v, got %v", tt.expected, ids)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Errorf("Expected documents %v, got %v", tt.expected, ids)
+					break
+				}
+			}
+		})
+	}
+
+	t.Run("batch with nothing accepted is not forwarded", func(t *testing.T) {
+		inner := &recordingSink{writeErr: context.Canceled}
+		router := NewRouter(inner, nil)
+
+		result, err := router.Write(context.Background(), []model.Doc{{ID: "3", Type: "runtime_live"}})
+		if err != nil {
+			t.Errorf("Expected no write to the wrapped sink, got %v", err)
+		}
+		if result.DroppedCount != 1 {
+			t.Errorf("Expected the filtered document reported dropped, got %+v", result)
+		}
+	})
+}
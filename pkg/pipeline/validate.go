@@ -0,0 +1,50 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Validator wraps a sink in validate-only mode for sinks that cannot validate
+// against their backend: documents are serialized and counted but never
+// written, and the sink is never opened
+type Validator struct {
+	model.Sink
+}
+
+// NewValidator wraps a sink so writes only serialize documents
+func NewValidator(sink model.Sink) *Validator {
+	return &Validator{Sink: sink}
+}
+
+// Unwrap returns the underlying sink
+func (v *Validator) Unwrap() model.Sink {
+	return v.Sink
+}
+
+// Open does nothing; the sink is never written to
+func (v *Validator) Open(ctx context.Context) error {
+	return nil
+}
+
+// Write serializes each document and reports those that cannot be encoded
+func (v *Validator) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	result := model.WriteResult{Errors: []string{}}
+	for _, doc := range docs {
+		if _, err := json.Marshal(doc.Body); err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", doc.ID, err))
+			continue
+		}
+		result.SuccessCount++
+	}
+	return result, nil
+}
+
+// Close does nothing; the sink was never opened
+func (v *Validator) Close(ctx context.Context) error {
+	return nil
+}
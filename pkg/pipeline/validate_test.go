@@ -0,0 +1,32 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestValidator(t *testing.T) {
+	inner := &recordingSink{}
+	validator := NewValidator(inner)
+
+	docs := []model.Doc{
+		{ID: "1", Type: "runtime_5m", Body: map[string]any{"avg_temp_c": 21.5}},
+		{ID: "2", Type: "runtime_5m", Body: map[string]any{"bad": make(chan int)}},
+		{ID: "3", Type: "transition", Body: map[string]any{"mode": "heat"}},
+	}
+	result, err := validator.Write(context.Background(), docs)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.SuccessCount != 2 || result.ErrorCount != 1 || len(result.Errors) != 1 {
+		t.Errorf("Expected 2 successes and 1 error, got %+v", result)
+	}
+	if len(inner.docs) != 0 {
+		t.Errorf("Expected nothing written to the sink, got %d documents", len(inner.docs))
+	}
+	if validator.Info().Name != "recording" || validator.Unwrap() != inner {
+		t.Error("Expected the validator to report and unwrap to the wrapped sink")
+	}
+}
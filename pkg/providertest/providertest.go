@@ -0,0 +1,202 @@
+// Package providertest is a conformance suite for model.Provider
+// implementations. A provider's tests point it at a fake backend, describe it
+// with a Harness and call Run, which checks the guarantees the scheduler
+// relies on: stable provider metadata, thermostat references it can route
+// back to the provider, summaries and snapshots for the requested thermostat,
+// runtime rows stamped with the start of their 5-minute bin, and prompt
+// returns on a cancelled context.
+package providertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+	"github.com/benvon/thermostat-telemetry-reader/pkg/temperature"
+)
+
+const (
+	// binSize is the runtime interval every row must be aligned to
+	binSize = 5 * time.Minute
+
+	// cancelTimeout bounds how long a call may run with a cancelled context
+	cancelTimeout = 5 * time.Second
+)
+
+// Harness describes a provider implementation to the suite
+type Harness struct {
+	// New returns a provider ready to make requests, typically against an
+	// httptest server standing in for the provider's API
+	New func(t *testing.T) model.Provider
+	// RuntimeFrom and RuntimeTo bound the runtime request. A zero RuntimeTo
+	// uses the current time rounded down to a bin, and a zero RuntimeFrom the
+	// day before RuntimeTo.
+	RuntimeFrom time.Time
+	RuntimeTo   time.Time
+	// RequireRuntime fails the runtime check when no rows are returned
+	RequireRuntime bool
+	// RequireCancellation makes calls with a cancelled context return an
+	// error. Providers without I/O to interrupt may leave it unset; calls
+	// must still return promptly.
+	RequireCancellation bool
+}
+
+// Run runs every conformance check against the provider
+func Run(t *testing.T, h Harness) {
+	t.Helper()
+	if h.New == nil {
+		t.Fatal("providertest: Harness.New is required")
+	}
+	if h.RuntimeTo.IsZero() {
+		h.RuntimeTo = time.Now().UTC().Truncate(binSize)
+	}
+	if h.RuntimeFrom.IsZero() {
+		h.RuntimeFrom = h.RuntimeTo.Add(-24 * time.Hour)
+	}
+
+	t.Run("info", func(t *testing.T) { testInfo(t, h) })
+	t.Run("thermostats", func(t *testing.T) { testThermostats(t, h) })
+	t.Run("summary", func(t *testing.T) { testSummary(t, h) })
+	t.Run("snapshot", func(t *testing.T) { testSnapshot(t, h) })
+	t.Run("runtime", func(t *testing.T) { testRuntime(t, h) })
+	t.Run("context cancellation", func(t *testing.T) { testCancellation(t, h) })
+}
+
+// thermostats lists the provider's thermostats, failing the test if there
+// are none
+func thermostats(t *testing.T, provider model.Provider) []model.ThermostatRef {
+	t.Helper()
+	refs, err := provider.ListThermostats(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list thermostats: %v", err)
+	}
+	if len(refs) == 0 {
+		t.Fatal("Expected the fake backend to list at least one thermostat")
+	}
+	return refs
+}
+
+func testInfo(t *testing.T, h Harness) {
+	provider := h.New(t)
+	info := provider.Info()
+	if info.Name == "" || info.Version == "" {
+		t.Errorf("Expected a name and version, got %+v", info)
+	}
+	if provider.Auth() == nil {
+		t.Error("Expected an auth manager")
+	}
+}
+
+func testThermostats(t *testing.T, h Harness) {
+	provider := h.New(t)
+	name := provider.Info().Name
+
+	seen := make(map[string]bool)
+	for _, ref := range thermostats(t, provider) {
+		if ref.ID == "" {
+			t.Errorf("Expected every thermostat to have an ID, got %+v", ref)
+		}
+		if seen[ref.ID] {
+			t.Errorf("Thermostat %s listed twice", ref.ID)
+		}
+		seen[ref.ID] = true
+		// The scheduler finds a thermostat's provider by this name
+		if ref.Provider != name {
+			t.Errorf("Expected thermostat %s to name provider %q, got %q", ref.ID, name, ref.Provider)
+		}
+		if ref.TimeZone != "" {
+			if _, err := time.LoadLocation(ref.TimeZone); err != nil {
+				t.Errorf("Thermostat %s has an invalid time zone: %v", ref.ID, err)
+			}
+		}
+	}
+}
+
+func testSummary(t *testing.T, h Harness) {
+	provider := h.New(t)
+	for _, ref := range thermostats(t, provider) {
+		summary, err := provider.GetSummary(context.Background(), ref)
+		if err != nil {
+			t.Errorf("Failed to get summary for %s: %v", ref.ID, err)
+			continue
+		}
+		if summary.ThermostatRef.ID != ref.ID {
+			t.Errorf("Expected a summary for %s, got one for %q", ref.ID, summary.ThermostatRef.ID)
+		}
+	}
+}
+
+func testSnapshot(t *testing.T, h Harness) {
+	provider := h.New(t)
+	for _, ref := range thermostats(t, provider) {
+		snapshot, err := provider.GetSnapshot(context.Background(), ref, time.Time{})
+		if err != nil {
+			t.Errorf("Failed to get snapshot for %s: %v", ref.ID, err)
+			continue
+		}
+		if snapshot.ThermostatRef.ID != ref.ID {
+			t.Errorf("Expected a snapshot of %s, got one of %q", ref.ID, snapshot.ThermostatRef.ID)
+		}
+		if snapshot.CollectedAt.IsZero() {
+			t.Errorf("Expected snapshot of %s to have a collection time", ref.ID)
+		}
+	}
+}
+
+func testRuntime(t *testing.T, h Harness) {
+	provider := h.New(t)
+	total := 0
+	for _, ref := range thermostats(t, provider) {
+		rows, err := provider.GetRuntime(context.Background(), ref, h.RuntimeFrom, h.RuntimeTo)
+		if err != nil {
+			t.Errorf("Failed to get runtime for %s: %v", ref.ID, err)
+			continue
+		}
+		total += len(rows)
+
+		seen := make(map[time.Time]bool)
+		for _, row := range rows {
+			if row.ThermostatRef.ID != ref.ID {
+				t.Errorf("Expected rows for %s, got one for %q", ref.ID, row.ThermostatRef.ID)
+			}
+			// Bins are identified by their start; document IDs depend on it
+			if !row.EventTime.Equal(row.EventTime.Truncate(binSize)) {
+				t.Errorf("Expected row times on a 5-minute boundary, got %v", row.EventTime)
+			}
+			if seen[row.EventTime] {
+				t.Errorf("Runtime for %s has two rows at %v", ref.ID, row.EventTime)
+			}
+			seen[row.EventTime] = true
+			switch row.Unit {
+			case "", temperature.Celsius, temperature.Fahrenheit, temperature.Kelvin:
+			default:
+				t.Errorf("Unknown temperature unit %q", row.Unit)
+			}
+		}
+	}
+	if h.RequireRuntime && total == 0 {
+		t.Errorf("Expected runtime rows between %v and %v", h.RuntimeFrom, h.RuntimeTo)
+	}
+}
+
+func testCancellation(t *testing.T, h Harness) {
+	provider := h.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := provider.ListThermostats(ctx)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil && h.RequireCancellation {
+			t.Error("Expected an error listing thermostats with a cancelled context")
+		}
+	case <-time.After(cancelTimeout):
+		t.Fatalf("ListThermostats with a cancelled context did not return within %s", cancelTimeout)
+	}
+}
@@ -0,0 +1,67 @@
+package providertest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// memoryProvider serves fixed data, the behavior the suite expects
+type memoryProvider struct{}
+
+func (p *memoryProvider) Info() model.ProviderInfo {
+	return model.ProviderInfo{Name: "memory", Version: "1.0.0"}
+}
+
+func (p *memoryProvider) ListThermostats(ctx context.Context) ([]model.ThermostatRef, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []model.ThermostatRef{
+		{ID: "t1", Name: "Hallway", Provider: "memory", TimeZone: "America/Chicago"},
+		{ID: "t2", Name: "Office", Provider: "memory"},
+	}, nil
+}
+
+func (p *memoryProvider) GetSummary(ctx context.Context, tr model.ThermostatRef) (model.Summary, error) {
+	return model.Summary{ThermostatRef: tr, Revision: "1"}, nil
+}
+
+func (p *memoryProvider) GetSnapshot(ctx context.Context, tr model.ThermostatRef, since time.Time) (model.Snapshot, error) {
+	return model.Snapshot{ThermostatRef: tr, CollectedAt: time.Now()}, nil
+}
+
+func (p *memoryProvider) GetRuntime(ctx context.Context, tr model.ThermostatRef, from, to time.Time) ([]model.RuntimeRow, error) {
+	var rows []model.RuntimeRow
+	for at := from.Truncate(binSize); at.Before(to); at = at.Add(binSize) {
+		temp := 20.5
+		rows = append(rows, model.RuntimeRow{ThermostatRef: tr, EventTime: at, Mode: "heat", AvgTempC: &temp})
+	}
+	return rows, nil
+}
+
+func (p *memoryProvider) Auth() model.AuthManager {
+	return &memoryAuth{}
+}
+
+type memoryAuth struct{}
+
+func (a *memoryAuth) RefreshToken(ctx context.Context) error { return nil }
+
+func (a *memoryAuth) GetAccessToken(ctx context.Context) (string, error) { return "token", nil }
+
+func (a *memoryAuth) IsTokenValid(ctx context.Context) bool { return true }
+
+func TestRunMemoryProvider(t *testing.T) {
+	Run(t, Harness{
+		New: func(t *testing.T) model.Provider {
+			return &memoryProvider{}
+		},
+		RuntimeFrom:         time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		RuntimeTo:           time.Date(2025, 1, 10, 1, 0, 0, 0, time.UTC),
+		RequireRuntime:      true,
+		RequireCancellation: true,
+	})
+}
@@ -95,21 +95,43 @@ func Do(ctx context.Context, config Config, fn func() error) error {
 	return fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
-// DoWithResponse executes an HTTP request with retry logic and respects Retry-After headers
+// ThrottledError reports that a server asked the client to back off, either
+// because retries were exhausted on 429/503 responses or because the requested
+// Retry-After exceeds what the retry policy is willing to wait inline. Callers
+// use Until to persist a "do not call before" timestamp across poll cycles.
+type ThrottledError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Until      time.Time
+}
+
+// NewThrottledError creates a ThrottledError anchored at the current time
+func NewThrottledError(statusCode int, retryAfter time.Duration) *ThrottledError {
+	return &ThrottledError{
+		StatusCode: statusCode,
+		RetryAfter: retryAfter,
+		Until:      time.Now().Add(retryAfter),
+	}
+}
+
+// Error implements the error interface
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("throttled with HTTP %d, retry after %s", e.StatusCode, e.RetryAfter)
+}
+
+// DoWithResponse executes an HTTP request with retry logic and respects Retry-After headers.
+// A Retry-After longer than config.MaxDelay is not slept through; a *ThrottledError is
+// returned immediately instead so the caller can defer the work to a later cycle.
 func DoWithResponse(ctx context.Context, config Config, fn func() (*http.Response, error)) (*http.Response, error) {
 	var lastErr error
-	var resp *http.Response
+	var retryAfter time.Duration
 
 	for attempt := 0; attempt <= config.MaxRetries; attempt++ {
 		if attempt > 0 {
-			// Calculate backoff delay
+			// Prefer the server-provided delay over our own backoff
 			delay := config.Backoff(attempt)
-
-			// Check if previous response had Retry-After header
-			if resp != nil && resp.Header.Get("Retry-After") != "" {
-				if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > 0 {
-					delay = retryAfter
-				}
+			if retryAfter > 0 {
+				delay = retryAfter
 			}
 
 			select {
@@ -120,27 +142,59 @@ func DoWithResponse(ctx context.Context, config Config, fn func() (*http.Respons
 			}
 		}
 
-		resp, lastErr = fn()
-		if lastErr == nil && resp != nil {
-			// Check response status
-			if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
-				return resp, nil // Success or client error (don't retry client errors)
+		resp, err := fn()
+		if err != nil {
+			lastErr = err
+			if !IsRetriable(err) {
+				return resp, err // Don't retry non-retriable errors
 			}
+			continue
+		}
 
-			// Close response body for retriable errors
-			if resp.Body != nil {
-				_ = resp.Body.Close()
-			}
+		if !isRetriableStatus(resp.StatusCode) {
+			return resp, nil // Success or client error (don't retry client errors)
+		}
 
-			lastErr = fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+		retryAfter = RetryAfterFromResponse(resp)
+		if resp.Body != nil {
+			_ = resp.Body.Close()
 		}
 
-		if lastErr != nil && !IsRetriable(lastErr) {
-			return resp, lastErr // Don't retry non-retriable errors
+		lastErr = statusError(resp, retryAfter, config)
+		if retryAfter > config.MaxDelay {
+			return nil, lastErr
 		}
 	}
 
-	return resp, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+}
+
+// isRetriableStatus reports whether an HTTP status code warrants another attempt
+func isRetriableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// statusError converts a retriable HTTP response into an error, classifying
+// rate limiting and advertised unavailability as throttling
+func statusError(resp *http.Response, retryAfter time.Duration, config Config) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests && retryAfter == 0:
+		return NewThrottledError(resp.StatusCode, config.MaxDelay)
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusServiceUnavailable && retryAfter > 0:
+		return NewThrottledError(resp.StatusCode, retryAfter)
+	default:
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	}
+}
+
+// RetryAfterFromResponse returns the delay requested by a response's Retry-After
+// header, or zero when the header is absent or unparseable
+func RetryAfterFromResponse(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+	return parseRetryAfter(resp.Header.Get("Retry-After"))
 }
 
 // IsRetriable determines if an error is retriable
@@ -175,6 +229,10 @@ func IsRetriable(err error) bool {
 // parseRetryAfter parses the Retry-After header
 // It can be either a number of seconds or an HTTP date
 func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
 	// Try parsing as seconds
 	if seconds, err := strconv.Atoi(value); err == nil {
 		return time.Duration(seconds) * time.Second
@@ -182,7 +240,9 @@ func parseRetryAfter(value string) time.Duration {
 
 	// Try parsing as HTTP date
 	if t, err := http.ParseTime(value); err == nil {
-		return time.Until(t)
+		if until := time.Until(t); until > 0 {
+			return until
+		}
 	}
 
 	return 0
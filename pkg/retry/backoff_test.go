@@ -3,6 +3,8 @@ package retry
 import (
 	"context"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -244,3 +246,92 @@ func TestParseRetryAfter(t *testing.T) {
 		})
 	}
 }
+
+func TestDoWithResponse_RetriesRateLimit(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.InitialDelay = 10 * time.Millisecond
+
+	resp, err := DoWithResponse(context.Background(), config, func() (*http.Response, error) {
+		return http.Get(server.URL)
+	})
+	if err != nil {
+		t.Fatalf("Expected success after retry, got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if calls != 2 {
+		t.Errorf("Expected 2 calls, got %d", calls)
+	}
+}
+
+func TestDoWithResponse_LongRetryAfterReturnsThrottledError(t *testing.T) {
+	t.Parallel()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.InitialDelay = 10 * time.Millisecond
+
+	_, err := DoWithResponse(context.Background(), config, func() (*http.Response, error) {
+		return http.Get(server.URL)
+	})
+
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected ThrottledError, got %v", err)
+	}
+	if throttled.RetryAfter != time.Hour {
+		t.Errorf("Expected 1h retry after, got %v", throttled.RetryAfter)
+	}
+	if time.Until(throttled.Until) < 59*time.Minute {
+		t.Errorf("Expected Until about an hour out, got %v", throttled.Until)
+	}
+	if calls != 1 {
+		t.Errorf("Expected no inline retries for long Retry-After, got %d calls", calls)
+	}
+}
+
+func TestDoWithResponse_ExhaustedRateLimitIsThrottled(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.MaxRetries = 1
+	config.InitialDelay = 10 * time.Millisecond
+
+	_, err := DoWithResponse(context.Background(), config, func() (*http.Response, error) {
+		return http.Get(server.URL)
+	})
+
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("Expected ThrottledError, got %v", err)
+	}
+	if throttled.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected status 429, got %d", throttled.StatusCode)
+	}
+}
@@ -0,0 +1,50 @@
+package retry
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Throttle remembers the latest deadline an API set when it throttled us, so
+// calls fail fast until it passes instead of hammering the API while it is
+// rate limiting. The zero value is ready to use.
+type Throttle struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// Until returns the time before which the API asked us not to call again
+func (t *Throttle) Until() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.until
+}
+
+// Check returns a ThrottledError while a recorded deadline is in effect
+func (t *Throttle) Check() error {
+	until := t.Until()
+	if remaining := time.Until(until); remaining > 0 {
+		return &ThrottledError{
+			StatusCode: http.StatusTooManyRequests,
+			RetryAfter: remaining,
+			Until:      until,
+		}
+	}
+	return nil
+}
+
+// Record keeps the deadline of err when it is a ThrottledError later than
+// the one already recorded
+func (t *Throttle) Record(err error) {
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if throttled.Until.After(t.until) {
+		t.until = throttled.Until
+	}
+}
@@ -0,0 +1,40 @@
+package retry
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestThrottleKeepsLatestDeadline(t *testing.T) {
+	t.Parallel()
+
+	var throttle Throttle
+	if err := throttle.Check(); err != nil {
+		t.Fatalf("Expected no throttle before one is recorded, got %v", err)
+	}
+
+	later := NewThrottledError(429, time.Hour)
+	throttle.Record(fmt.Errorf("fetching: %w", later))
+	throttle.Record(NewThrottledError(429, time.Minute))
+	throttle.Record(errors.New("unrelated"))
+	if !throttle.Until().Equal(later.Until) {
+		t.Fatalf("Expected the later deadline %v to be kept, got %v", later.Until, throttle.Until())
+	}
+
+	var throttled *ThrottledError
+	if err := throttle.Check(); !errors.As(err, &throttled) || !throttled.Until.Equal(later.Until) {
+		t.Fatalf("Expected a ThrottledError until %v, got %v", later.Until, err)
+	}
+}
+
+func TestThrottleExpires(t *testing.T) {
+	t.Parallel()
+
+	var throttle Throttle
+	throttle.Record(&ThrottledError{StatusCode: 429, Until: time.Now().Add(-time.Second)})
+	if err := throttle.Check(); err != nil {
+		t.Fatalf("Expected a past deadline not to throttle, got %v", err)
+	}
+}
@@ -0,0 +1,132 @@
+// Package runtimebin aggregates runtime readings into canonical 5-minute
+// bins. The scheduler uses it for providers with bin alignment and providers
+// that sample current state use it to answer runtime requests, so the same
+// readings give the same runtime_5m rows whichever path they take.
+package runtimebin
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// Size is the width of a canonical runtime bin
+const Size = 5 * time.Minute
+
+// Align groups readings by the bin they fall in and aggregates each bin that
+// closed by now, in time order
+func Align(rows []model.RuntimeRow, now time.Time) []model.RuntimeRow {
+	sorted := slices.Clone(rows)
+	slices.SortStableFunc(sorted, func(a, b model.RuntimeRow) int {
+		return a.EventTime.Compare(b.EventTime)
+	})
+
+	var bins []model.RuntimeRow
+	for start := 0; start < len(sorted); {
+		binStart := sorted[start].EventTime.Truncate(Size)
+		if binStart.Add(Size).After(now) {
+			break
+		}
+		end := start + 1
+		for end < len(sorted) && sorted[end].EventTime.Truncate(Size).Equal(binStart) {
+			end++
+		}
+		bins = append(bins, Aggregate(binStart, sorted[start:end]))
+		start = end
+	}
+	return bins
+}
+
+// Aggregate combines the readings of one bin, in time order: temperatures,
+// humidity and sensors are averaged, equipment counts as running if any
+// reading had it on, run seconds are summed up to the bin length, and mode,
+// climate and setpoints take their latest reported values. Readings of one
+// thermostat are assumed to share a unit.
+func Aggregate(binStart time.Time, readings []model.RuntimeRow) model.RuntimeRow {
+	bin := model.RuntimeRow{
+		ThermostatRef: readings[0].ThermostatRef,
+		Unit:          readings[0].Unit,
+		EventTime:     binStart,
+	}
+
+	var avg, outdoor, humidity runningMean
+	sensors := make(map[string]*runningMean)
+	for _, reading := range readings {
+		if reading.Mode != "" {
+			bin.Mode = reading.Mode
+		}
+		if reading.Climate != "" {
+			bin.Climate = reading.Climate
+		}
+		if reading.SetHeatC != nil {
+			bin.SetHeatC = reading.SetHeatC
+		}
+		if reading.SetCoolC != nil {
+			bin.SetCoolC = reading.SetCoolC
+		}
+		avg.add(reading.AvgTempC)
+		outdoor.add(reading.OutdoorTempC)
+		if reading.OutdoorHumidity != nil {
+			value := float64(*reading.OutdoorHumidity)
+			humidity.add(&value)
+		}
+
+		for name, on := range reading.Equipment {
+			if bin.Equipment == nil {
+				bin.Equipment = make(map[string]bool)
+			}
+			bin.Equipment[name] = bin.Equipment[name] || on
+		}
+		for name, secs := range reading.EquipmentSecs {
+			if bin.EquipmentSecs == nil {
+				bin.EquipmentSecs = make(map[string]int)
+			}
+			bin.EquipmentSecs[name] = min(bin.EquipmentSecs[name]+secs, int(Size/time.Second))
+		}
+		for id, temp := range reading.Sensors {
+			if sensors[id] == nil {
+				sensors[id] = &runningMean{}
+			}
+			sensors[id].add(&temp)
+		}
+	}
+
+	bin.AvgTempC = avg.value()
+	bin.OutdoorTempC = outdoor.value()
+	if value := humidity.value(); value != nil {
+		rounded := int(math.Round(*value))
+		bin.OutdoorHumidity = &rounded
+	}
+	for id, sensor := range sensors {
+		if bin.Sensors == nil {
+			bin.Sensors = make(map[string]float64, len(sensors))
+		}
+		bin.Sensors[id] = *sensor.value()
+	}
+	return bin
+}
+
+// runningMean averages the values added to it, ignoring missing ones
+type runningMean struct {
+	sum   float64
+	count int
+}
+
+// add includes value in the mean when present
+func (m *runningMean) add(value *float64) {
+	if value != nil {
+		m.sum += *value
+		m.count++
+	}
+}
+
+// value returns the mean, or nil when nothing was added
+func (m *runningMean) value() *float64 {
+	if m.count == 0 {
+		return nil
+	}
+	value := m.sum / float64(m.count)
+	return &value
+}
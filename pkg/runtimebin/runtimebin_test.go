@@ -0,0 +1,54 @@
+package runtimebin
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+func TestAlign(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "push"}
+	binStart := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	humidity := func(v int) *int { return &v }
+
+	readings := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: binStart.Add(3 * time.Minute), Mode: "heat", SetHeatC: floatPtr(21), AvgTempC: floatPtr(20.5),
+			Equipment: map[string]bool{"compHeat1": false, "fan": true}, Sensors: map[string]float64{"rs_1": 19}},
+		{ThermostatRef: thermostat, EventTime: binStart.Add(40 * time.Second), Mode: "heat", SetHeatC: floatPtr(20), AvgTempC: floatPtr(19.5),
+			OutdoorHumidity: humidity(60), Equipment: map[string]bool{"compHeat1": true}, Sensors: map[string]float64{"rs_1": 18}},
+		{ThermostatRef: thermostat, EventTime: binStart.Add(7 * time.Minute), Mode: "off", AvgTempC: floatPtr(22)},
+		// still open at now, so held back
+		{ThermostatRef: thermostat, EventTime: binStart.Add(11 * time.Minute), Mode: "off", AvgTempC: floatPtr(23)},
+	}
+
+	bins := Align(readings, binStart.Add(12*time.Minute))
+
+	expected := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: binStart, Mode: "heat", SetHeatC: floatPtr(21), AvgTempC: floatPtr(20),
+			OutdoorHumidity: humidity(60), Equipment: map[string]bool{"compHeat1": true, "fan": true}, Sensors: map[string]float64{"rs_1": 18.5}},
+		{ThermostatRef: thermostat, EventTime: binStart.Add(5 * time.Minute), Mode: "off", AvgTempC: floatPtr(22)},
+	}
+	if !reflect.DeepEqual(bins, expected) {
+		t.Errorf("Expected bins %+v, got %+v", expected, bins)
+	}
+}
+
+func TestAggregateCapsRunSeconds(t *testing.T) {
+	thermostat := model.ThermostatRef{ID: "t1", Provider: "push"}
+	binStart := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	readings := []model.RuntimeRow{
+		{ThermostatRef: thermostat, EventTime: binStart, EquipmentSecs: map[string]int{"compHeat1": 200, "fan": 30}},
+		{ThermostatRef: thermostat, EventTime: binStart.Add(time.Minute), EquipmentSecs: map[string]int{"compHeat1": 200}},
+	}
+
+	bin := Aggregate(binStart, readings)
+	if bin.EquipmentSecs["compHeat1"] != 300 || bin.EquipmentSecs["fan"] != 30 {
+		t.Errorf("Expected run seconds summed up to the bin length, got %v", bin.EquipmentSecs)
+	}
+}
+
+func floatPtr(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,92 @@
+package secret
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// File is a credential stored in a file. Surrounding whitespace, including
+// the trailing newline most editors and secret stores add, is ignored.
+type File struct {
+	path  string
+	mu    sync.Mutex
+	value string
+}
+
+// NewFile reads the credential at path
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Path returns the file the credential is read from
+func (f *File) Path() string {
+	return f.path
+}
+
+// Value returns the credential as last read
+func (f *File) Value() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.value
+}
+
+// Reload re-reads the file and reports whether the credential changed. An
+// unreadable or empty file is an error and keeps the previous value.
+func (f *File) Reload() (bool, error) {
+	// #nosec G304 - credential paths come from operator configuration
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return false, fmt.Errorf("reading secret file: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return false, fmt.Errorf("secret file %s is empty", f.path)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if value == f.value {
+		return false, nil
+	}
+	f.value = value
+	return true, nil
+}
+
+// Write replaces the credential and the file's contents, for credentials the
+// provider rotates itself. The file is replaced atomically, so a crash never
+// leaves it truncated, and Reload does not report the write as a change.
+func (f *File) Write(value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), "."+filepath.Base(f.path)+".*")
+	if err != nil {
+		return fmt.Errorf("writing secret file: %w", err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.WriteString(value + "\n"); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing secret file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing secret file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing secret file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("replacing secret file: %w", err)
+	}
+	f.value = value
+	return nil
+}
@@ -0,0 +1,84 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_key")
+	write := func(value string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(value), 0o600); err != nil {
+			t.Fatalf("Failed to write secret: %v", err)
+		}
+	}
+
+	write("first\n")
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file.Value() != "first" {
+		t.Errorf("Expected trimmed value, got %q", file.Value())
+	}
+
+	tests := []struct {
+		name     string
+		content  string
+		changed  bool
+		expected string
+		wantErr  bool
+	}{
+		{name: "unchanged", content: "first", changed: false, expected: "first"},
+		{name: "rotated", content: "second\n", changed: true, expected: "second"},
+		{name: "empty keeps previous value", content: "\n", expected: "second", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			write(tt.content)
+			changed, err := file.Reload()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if changed != tt.changed || file.Value() != tt.expected {
+				t.Errorf("Expected changed=%v value=%q, got changed=%v value=%q", tt.changed, tt.expected, changed, file.Value())
+			}
+		})
+	}
+
+	if _, err := NewFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestFileWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "refresh_token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	file, err := NewFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if err := file.Write("second"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if file.Value() != "second" {
+		t.Errorf("Expected the written value, got %q", file.Value())
+	}
+	if changed, err := file.Reload(); err != nil || changed {
+		t.Errorf("Expected the write not to count as a change, got changed=%v err=%v", changed, err)
+	}
+
+	reread, err := NewFile(path)
+	if err != nil || reread.Value() != "second" {
+		t.Errorf("Expected the file to hold the written value, got %q, %v", reread.Value(), err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
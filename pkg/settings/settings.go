@@ -0,0 +1,169 @@
+// Package settings decodes the free-form settings of provider and sink
+// configurations into typed structs, so every integration reports missing
+// and malformed settings the same way.
+//
+// Fields are matched by their settings tag, e.g. `settings:"url,required"`.
+// A setting that is absent or an empty string leaves the field as it was, so
+// defaults are whatever the target holds before decoding. Strings are
+// converted to numbers, booleans and durations, since environment overrides
+// replace settings with strings, and url.URL fields must hold absolute URLs.
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// tagName is the struct tag naming each field's setting
+const tagName = "settings"
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	urlType      = reflect.TypeFor[url.URL]()
+)
+
+// Decode decodes settings into target, a pointer to a struct. Every problem
+// found is reported, one per "; "-separated part of the error.
+func Decode(settings map[string]any, target any) error {
+	value := reflect.ValueOf(target)
+	if value.Kind() != reflect.Pointer || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("settings target must be a pointer to a struct, got %T", target)
+	}
+	fields := value.Elem().Type()
+
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:    tagName,
+		Result:     target,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(stringToDurationHook, stringToURLHook, stringToBasicHook),
+	})
+	if err != nil {
+		return fmt.Errorf("creating settings decoder: %w", err)
+	}
+
+	// Fields are decoded one at a time so one bad setting does not hide others
+	var problems []string
+	for i := range fields.NumField() {
+		field := fields.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get(tagName), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		raw := settings[name]
+		if raw == nil || raw == "" {
+			if options == "required" {
+				problems = append(problems, "missing "+name)
+			}
+			continue
+		}
+		if err := decoder.Decode(map[string]any{name: raw}); err != nil {
+			problems = append(problems, describe(err)...)
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// describe turns a decoding error into one message per invalid setting
+func describe(err error) []string {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var messages []string
+		for _, inner := range joined.Unwrap() {
+			messages = append(messages, describe(inner)...)
+		}
+		return messages
+	}
+
+	var decodeErr *mapstructure.DecodeError
+	if !errors.As(err, &decodeErr) {
+		return []string{err.Error()}
+	}
+	inner := decodeErr.Unwrap()
+	var unconvertible *mapstructure.UnconvertibleTypeError
+	if errors.As(inner, &unconvertible) {
+		return []string{fmt.Sprintf("invalid %s: expected %s, got %T", decodeErr.Name(), typeName(unconvertible.Expected.Type()), unconvertible.Value)}
+	}
+	return []string{fmt.Sprintf("invalid %s: %v", decodeErr.Name(), inner)}
+}
+
+// typeName describes a field type in configuration terms
+func typeName(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "a duration such as 30s"
+	case t.Kind() == reflect.Bool:
+		return "true or false"
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Float64:
+		return "a number"
+	case t.Kind() == reflect.String:
+		return "a string"
+	case t.Kind() == reflect.Slice:
+		return "a list"
+	case t.Kind() == reflect.Map:
+		return "a map"
+	default:
+		return t.String()
+	}
+}
+
+// stringToDurationHook parses duration strings such as "15m"
+func stringToDurationHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || to != durationType {
+		return data, nil
+	}
+	d, err := time.ParseDuration(data.(string))
+	if err != nil {
+		return nil, fmt.Errorf("expected a duration such as 30s, got %q", data)
+	}
+	return d, nil
+}
+
+// stringToBasicHook parses booleans and numbers given as strings
+func stringToBasicHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String {
+		return data, nil
+	}
+	text := strings.TrimSpace(data.(string))
+	var value any
+	var err error
+	switch to.Kind() {
+	case reflect.Bool:
+		value, err = strconv.ParseBool(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value, err = strconv.ParseInt(text, 10, 64)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value, err = strconv.ParseUint(text, 10, 64)
+	case reflect.Float32, reflect.Float64:
+		value, err = strconv.ParseFloat(text, 64)
+	default:
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("expected %s, got %q", typeName(to), data)
+	}
+	return value, nil
+}
+
+// stringToURLHook parses absolute URLs, with a scheme and host
+func stringToURLHook(from, to reflect.Type, data any) (any, error) {
+	if from.Kind() != reflect.String || (to != urlType && to != reflect.PointerTo(urlType)) {
+		return data, nil
+	}
+	u, err := url.Parse(data.(string))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("expected an absolute URL such as https://host:port, got %q", data)
+	}
+	if to == urlType {
+		return *u, nil
+	}
+	return u, nil
+}
@@ -0,0 +1,105 @@
+package settings
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testSettings struct {
+	URL      *url.URL      `settings:"url,required"`
+	Token    string        `settings:"token"`
+	Prefix   string        `settings:"prefix"`
+	Batch    int           `settings:"batch_size"`
+	Compress bool          `settings:"compress"`
+	Interval time.Duration `settings:"interval"`
+	Columns  []string      `settings:"columns"`
+	Ignored  string
+}
+
+func defaults() testSettings {
+	return testSettings{Prefix: "ttr", Batch: 500, Interval: 15 * time.Minute}
+}
+
+func TestDecode(t *testing.T) {
+	s := defaults()
+	err := Decode(map[string]any{
+		"url":        "https://es.example:9200",
+		"token":      "secret",
+		"batch_size": 100,
+		"compress":   true,
+		"interval":   "1h",
+		"columns":    []any{"mode", "avg_temp_c"},
+		"unrelated":  "kept for other readers",
+	}, &s)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if s.URL.String() != "https://es.example:9200" {
+		t.Errorf("Expected URL https://es.example:9200, got %v", s.URL)
+	}
+	if s.Token != "secret" || s.Prefix != "ttr" || s.Batch != 100 || !s.Compress || s.Interval != time.Hour {
+		t.Errorf("Unexpected settings %+v", s)
+	}
+	if !slices.Equal(s.Columns, []string{"mode", "avg_temp_c"}) {
+		t.Errorf("Expected columns [mode avg_temp_c], got %v", s.Columns)
+	}
+}
+
+func TestDecodeStringsFromEnvironment(t *testing.T) {
+	s := defaults()
+	s.Compress = true
+	err := Decode(map[string]any{"url": "http://localhost:9200", "batch_size": "50", "compress": "false", "interval": ""}, &s)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if s.Batch != 50 || s.Compress {
+		t.Errorf("Expected batch 50 and compression off, got %+v", s)
+	}
+	if s.Interval != 15*time.Minute {
+		t.Errorf("Expected an empty interval to keep the default, got %v", s.Interval)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]any
+		want     []string
+	}{
+		{name: "missing required", settings: map[string]any{}, want: []string{"missing url"}},
+		{name: "empty required", settings: map[string]any{"url": ""}, want: []string{"missing url"}},
+		{name: "relative url", settings: map[string]any{"url": "es.example"}, want: []string{"invalid url: expected an absolute URL"}},
+		{name: "wrong type", settings: map[string]any{"url": "http://x", "token": 5}, want: []string{"invalid token: expected a string, got int"}},
+		{name: "bad bool", settings: map[string]any{"url": "http://x", "compress": "maybe"}, want: []string{`invalid compress: expected true or false, got "maybe"`}},
+		{name: "bad duration", settings: map[string]any{"url": "http://x", "interval": "soon"}, want: []string{`invalid interval: expected a duration such as 30s, got "soon"`}},
+		{
+			name:     "every problem",
+			settings: map[string]any{"token": 5, "interval": "soon"},
+			want:     []string{"missing url", "invalid token", "invalid interval"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := defaults()
+			err := Decode(tt.settings, &s)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Expected error to contain %q, got %q", want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeRequiresStructPointer(t *testing.T) {
+	if err := Decode(map[string]any{}, testSettings{}); err == nil {
+		t.Error("Expected an error decoding into a non-pointer")
+	}
+}
@@ -0,0 +1,259 @@
+// Package sinktest is a conformance suite for model.Sink implementations.
+// A sink's tests describe it with a Harness and call Run, which checks the
+// guarantees the scheduler relies on: the Open/Close lifecycle, idempotent
+// re-writes of the same document IDs, per-document failure reporting,
+// context cancellation and large batches.
+package sinktest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+const (
+	// DefaultLargeBatch is the large batch size when a Harness sets none
+	DefaultLargeBatch = 2000
+
+	// cancelTimeout bounds how long Write may run with a cancelled context
+	cancelTimeout = 5 * time.Second
+)
+
+// Harness describes a sink implementation to the suite
+type Harness struct {
+	// New returns a fresh, unopened sink. Each call must return a sink that
+	// shares no stored documents with earlier ones.
+	New func(t *testing.T) model.Sink
+	// Stored returns how many distinct documents an open sink holds. Nil
+	// skips storage checks, e.g. for streams without deduplication.
+	Stored func(t *testing.T, sink model.Sink) int
+	// Reject returns a document the sink must report as failed without
+	// failing the rest of its batch. Nil skips the partial failure check.
+	Reject func() model.Doc
+	// LargeBatch is the document count of the large batch check; zero uses
+	// DefaultLargeBatch
+	LargeBatch int
+	// RequireCancellation makes Write with a cancelled context return an
+	// error. Sinks without I/O to interrupt may leave it unset; Write must
+	// still return promptly.
+	RequireCancellation bool
+}
+
+// Run runs every conformance check against the sink
+func Run(t *testing.T, h Harness) {
+	t.Helper()
+	if h.New == nil {
+		t.Fatal("sinktest: Harness.New is required")
+	}
+	if h.LargeBatch <= 0 {
+		h.LargeBatch = DefaultLargeBatch
+	}
+
+	t.Run("info", func(t *testing.T) { testInfo(t, h) })
+	t.Run("lifecycle", func(t *testing.T) { testLifecycle(t, h) })
+	t.Run("idempotent rewrites", func(t *testing.T) { testIdempotentRewrites(t, h) })
+	t.Run("partial failure", func(t *testing.T) { testPartialFailure(t, h) })
+	t.Run("context cancellation", func(t *testing.T) { testCancellation(t, h) })
+	t.Run("large batch", func(t *testing.T) { testLargeBatch(t, h) })
+}
+
+// Documents returns n runtime_5m documents with generated IDs, alternating
+// between two thermostats in consecutive 5-minute bins
+func Documents(n int) []model.Doc {
+	generator := model.NewIDGenerator()
+	start := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	docs := make([]model.Doc, 0, n)
+	for i := 0; i < n; i++ {
+		temp := 20 + float64(i%10)/10
+		setHeat := 20.0
+		runtime := &model.Runtime5m{
+			Type:           "runtime_5m",
+			ThermostatID:   fmt.Sprintf("sinktest-%d", i%2),
+			ThermostatName: fmt.Sprintf("Sink Test %d", i%2),
+			EventTime:      start.Add(time.Duration(i/2) * 5 * time.Minute),
+			Mode:           "heat",
+			Climate:        "Home",
+			SetHeatC:       &setHeat,
+			AvgTempC:       &temp,
+			Equipment:      map[string]bool{"compHeat1": i%3 == 0},
+		}
+		id, err := generator.GenerateRuntime5mID(runtime)
+		if err != nil {
+			panic(fmt.Sprintf("sinktest: generating document ID: %v", err))
+		}
+		docs = append(docs, model.Doc{ID: id, Type: "runtime_5m", Body: runtime})
+	}
+	return docs
+}
+
+// Unencodable returns a runtime_5m document whose body cannot be encoded as
+// JSON; sinks that serialize documents should reject it on its own
+func Unencodable() model.Doc {
+	return model.Doc{ID: "sinktest-unencodable", Type: "runtime_5m", Body: map[string]any{"value": make(chan int)}}
+}
+
+// open creates and opens a sink that is closed when the test ends
+func open(t *testing.T, h Harness) model.Sink {
+	t.Helper()
+	sink := h.New(t)
+	if err := sink.Open(context.Background()); err != nil {
+		t.Fatalf("Failed to open sink: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = sink.Close(context.Background())
+	})
+	return sink
+}
+
+// write writes docs and checks the result accounts for every document
+func write(t *testing.T, sink model.Sink, docs []model.Doc) model.WriteResult {
+	t.Helper()
+	result, err := sink.Write(context.Background(), docs)
+	if err != nil {
+		t.Fatalf("Failed to write %d documents: %v", len(docs), err)
+	}
+	checkAccounting(t, result, len(docs))
+	return result
+}
+
+// checkAccounting checks that a result counts every document once and
+// explains its errors
+func checkAccounting(t *testing.T, result model.WriteResult, count int) {
+	t.Helper()
+	if result.SuccessCount+result.ErrorCount != count {
+		t.Errorf("Expected success and error counts to total %d, got %+v", count, result)
+	}
+	if result.ErrorCount > 0 && len(result.Errors) == 0 {
+		t.Errorf("Expected error messages for %d failed documents", result.ErrorCount)
+	}
+}
+
+func checkStored(t *testing.T, h Harness, sink model.Sink, want int) {
+	t.Helper()
+	if h.Stored == nil {
+		return
+	}
+	if got := h.Stored(t, sink); got != want {
+		t.Errorf("Expected %d stored documents, got %d", want, got)
+	}
+}
+
+func testInfo(t *testing.T, h Harness) {
+	info := h.New(t).Info()
+	if info.Name == "" || info.Version == "" {
+		t.Errorf("Expected a name and version, got %+v", info)
+	}
+}
+
+func testLifecycle(t *testing.T, h Harness) {
+	ctx := context.Background()
+
+	t.Run("open and close without writes", func(t *testing.T) {
+		sink := h.New(t)
+		if err := sink.Open(ctx); err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+		if err := sink.Close(ctx); err != nil {
+			t.Errorf("Failed to close sink: %v", err)
+		}
+	})
+
+	t.Run("empty batch", func(t *testing.T) {
+		sink := open(t, h)
+		result, err := sink.Write(ctx, nil)
+		if err != nil {
+			t.Fatalf("Failed to write an empty batch: %v", err)
+		}
+		if result.SuccessCount != 0 || result.ErrorCount != 0 {
+			t.Errorf("Expected nothing written, got %+v", result)
+		}
+	})
+
+	t.Run("write then close", func(t *testing.T) {
+		sink := h.New(t)
+		if err := sink.Open(ctx); err != nil {
+			t.Fatalf("Failed to open sink: %v", err)
+		}
+		write(t, sink, Documents(4))
+		if err := sink.Close(ctx); err != nil {
+			t.Errorf("Failed to close sink: %v", err)
+		}
+	})
+}
+
+func testIdempotentRewrites(t *testing.T, h Harness) {
+	sink := open(t, h)
+	docs := Documents(10)
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		result := write(t, sink, docs)
+		if result.ErrorCount != 0 {
+			t.Errorf("Write %d: expected no errors, got %v", attempt, result.Errors)
+		}
+	}
+	checkStored(t, h, sink, len(docs))
+
+	// A batch overlapping stored documents adds only the new ones
+	overlapping := Documents(14)[6:]
+	write(t, sink, overlapping)
+	checkStored(t, h, sink, 14)
+}
+
+func testPartialFailure(t *testing.T, h Harness) {
+	if h.Reject == nil {
+		t.Skip("harness has no rejected document")
+	}
+	sink := open(t, h)
+	docs := Documents(4)
+	batch := append([]model.Doc{docs[0], docs[1], h.Reject()}, docs[2:]...)
+
+	result := write(t, sink, batch)
+	if result.ErrorCount != 1 || result.SuccessCount != len(docs) {
+		t.Errorf("Expected %d successes and 1 error, got %+v", len(docs), result)
+	}
+	checkStored(t, h, sink, len(docs))
+}
+
+func testCancellation(t *testing.T, h Harness) {
+	sink := open(t, h)
+	docs := Documents(20)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	type outcome struct {
+		result model.WriteResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := sink.Write(ctx, docs)
+		done <- outcome{result: result, err: err}
+	}()
+
+	select {
+	case out := <-done:
+		if out.err != nil {
+			return
+		}
+		if h.RequireCancellation {
+			t.Error("Expected an error writing with a cancelled context")
+		}
+		checkAccounting(t, out.result, len(docs))
+	case <-time.After(cancelTimeout):
+		t.Fatalf("Write with a cancelled context did not return within %s", cancelTimeout)
+	}
+}
+
+func testLargeBatch(t *testing.T, h Harness) {
+	sink := open(t, h)
+	docs := Documents(h.LargeBatch)
+
+	result := write(t, sink, docs)
+	if result.ErrorCount != 0 || result.SuccessCount != len(docs) {
+		t.Errorf("Expected %d successes, got %d (%d errors)", len(docs), result.SuccessCount, result.ErrorCount)
+	}
+	checkStored(t, h, sink, len(docs))
+}
@@ -0,0 +1,81 @@
+package sinktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/benvon/thermostat-telemetry-reader/pkg/model"
+)
+
+// memorySink stores documents by ID, the behavior the suite expects
+type memorySink struct {
+	mu   sync.Mutex
+	docs map[string][]byte
+}
+
+func (s *memorySink) Info() model.SinkInfo {
+	return model.SinkInfo{Name: "memory", Version: "1.0.0"}
+}
+
+func (s *memorySink) Open(ctx context.Context) error {
+	s.docs = make(map[string][]byte)
+	return nil
+}
+
+func (s *memorySink) Write(ctx context.Context, docs []model.Doc) (model.WriteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return model.WriteResult{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := model.WriteResult{Errors: []string{}}
+	for _, doc := range docs {
+		data, err := json.Marshal(doc.Body)
+		if err != nil {
+			result.ErrorCount++
+			result.Errors = append(result.Errors, fmt.Sprintf("document %s: %v", doc.ID, err))
+			continue
+		}
+		s.docs[doc.ID] = data
+		result.SuccessCount++
+	}
+	return result, nil
+}
+
+func (s *memorySink) Close(ctx context.Context) error {
+	return nil
+}
+
+func TestRunMemorySink(t *testing.T) {
+	Run(t, Harness{
+		New: func(t *testing.T) model.Sink {
+			return &memorySink{}
+		},
+		Stored: func(t *testing.T, sink model.Sink) int {
+			return len(sink.(*memorySink).docs)
+		},
+		Reject:              Unencodable,
+		RequireCancellation: true,
+	})
+}
+
+func TestDocuments(t *testing.T) {
+	docs := Documents(6)
+	ids := make(map[string]bool)
+	for _, doc := range docs {
+		if doc.ID == "" || doc.Type != "runtime_5m" {
+			t.Errorf("Unexpected document: %+v", doc)
+		}
+		ids[doc.ID] = true
+	}
+	if len(ids) != len(docs) {
+		t.Errorf("Expected %d distinct IDs, got %d", len(docs), len(ids))
+	}
+	if again := Documents(4); again[3].ID != docs[3].ID {
+		t.Error("Expected documents to be deterministic")
+	}
+}